@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// Tags of a repository pointing at the same manifest digest are one image
+// under several names, e.g. latest, v1.4.2 and sha-abc123. The Docker tab
+// shows each tag's aliases in its Same As column. A registry deletes
+// manifests by digest, taking every alias along, so the REST API won't
+// delete a tag that has aliases unless forced, and prune keeps a digest any
+// kept tag still needs.
+
+// tagAliases maps each reference to the other tags of its repository with
+// the same digest, given every reference's digest.
+func tagAliases(digests map[string]string) map[string][]string {
+	tags := make(map[string][]string) // host/repository@digest → tags
+	for reference, digest := range digests {
+		if digest == "" {
+			continue
+		}
+		host, repository, tag := splitRegistryReference(reference)
+		key := host + "/" + repository + "@" + digest
+		tags[key] = append(tags[key], tag)
+	}
+	aliases := make(map[string][]string)
+	for reference, digest := range digests {
+		host, repository, tag := splitRegistryReference(reference)
+		for _, other := range tags[host+"/"+repository+"@"+digest] {
+			if other != tag {
+				aliases[reference] = append(aliases[reference], other)
+			}
+		}
+		slices.SortFunc(aliases[reference], compareVersionTags)
+	}
+	return aliases
+}
+
+// dockerTabAliases are the aliases of the Docker tab's registry images.
+func dockerTabAliases(images []TableData) map[string][]string {
+	digests := make(map[string]string)
+	for _, image := range images {
+		if image.ImageDigest != "" && image.ImageTag != "" && image.ImageTag != "N/A" {
+			digests[image.ImageTag] = image.ImageDigest
+		}
+	}
+	return tagAliases(digests)
+}
+
+// registryTagAliases asks the registry for the other tags of repository
+// whose manifest is digest.
+func registryTagAliases(ctx context.Context, client registryClient, repository, tag, digest string) ([]string, error) {
+	tags, err := client.Tags(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+	var aliases []string
+	for _, other := range tags {
+		if other == tag {
+			continue
+		}
+		if otherDigest, err := client.ManifestDigest(ctx, repository, other); err == nil && otherDigest == digest {
+			aliases = append(aliases, other)
+		}
+	}
+	slices.SortFunc(aliases, compareVersionTags)
+	return aliases, nil
+}
+
+// aliasesCell is the Same As column of a tag.
+func aliasesCell(aliases []string) string {
+	return strings.Join(aliases, ", ")
+}
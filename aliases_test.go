@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/testenv"
+)
+
+func TestTagAliases(t *testing.T) {
+	aliases := tagAliases(map[string]string{
+		"registry:5000/web:latest":     "sha256:a",
+		"registry:5000/web:v1.4.2":     "sha256:a",
+		"registry:5000/web:sha-abc123": "sha256:a",
+		"registry:5000/web:v1.3.0":     "sha256:b",
+		"registry:5000/api:latest":     "sha256:a", // another repository
+		"registry:5000/web:pending":    "",         // digest not known yet
+	})
+	for reference, want := range map[string][]string{
+		"registry:5000/web:latest":     {"sha-abc123", "v1.4.2"},
+		"registry:5000/web:v1.4.2":     {"latest", "sha-abc123"},
+		"registry:5000/web:sha-abc123": {"latest", "v1.4.2"},
+		"registry:5000/web:v1.3.0":     nil,
+		"registry:5000/api:latest":     nil,
+		"registry:5000/web:pending":    nil,
+	} {
+		if got := aliases[reference]; !slices.Equal(got, want) {
+			t.Errorf("aliases of %s are %v, want %v", reference, got, want)
+		}
+	}
+}
+
+func TestRegistryTagAliases(t *testing.T) {
+	fake := testenv.NewRegistry()
+	defer fake.Close()
+	digest := fake.PushImage("web", "v1.0.0", nil, []byte("layer"))
+	fake.PushImage("web", "latest", nil, []byte("layer"))
+	fake.PushImage("web", "stable", nil, []byte("layer"))
+	fake.PushImage("web", "v2.0.0", nil, []byte("other layer"))
+
+	ctx := context.Background()
+	aliases, err := registryTagAliases(ctx, newRegistryClient(ctx, fake.Host()), "web", "latest", digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"stable", "v1.0.0"}; !slices.Equal(aliases, want) {
+		t.Errorf("aliases of web:latest are %v, want %v", aliases, want)
+	}
+
+	if _, err := registryTagAliases(ctx, newRegistryClient(ctx, fake.Host()), "missing", "latest", digest); err == nil {
+		t.Error("found aliases in a repository the registry doesn't know")
+	}
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
+
+// The REST API of serve, under /api/v1. Every endpoint but login needs a
+// user, authenticated with an API token ("Authorization: Bearer lcr_...")
+// or with basic auth, and what they do is recorded in history under their
+// name. Deleting, pruning and changing deployments also need a role.
+
+type userKey struct{}
+
+func withUser(ctx context.Context, user User) context.Context {
+	return withActor(context.WithValue(ctx, userKey{}, user), user.Username)
+}
+
+func userOf(ctx context.Context) (User, bool) {
+	user, ok := ctx.Value(userKey{}).(User)
+	return user, ok
+}
+
+type apiImage struct {
+	Reference string            `json:"reference"`
+	Digest    string            `json:"digest,omitempty"`
+	Size      string            `json:"size"`
+	SizeBytes int64             `json:"size_bytes,omitempty"`
+	Created   string            `json:"created"`
+	Artifact  string            `json:"artifact,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Aliases   []string          `json:"aliases,omitempty"`
+}
+
+type apiPod struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Status    string `json:"status"`
+	Restarts  string `json:"restarts"`
+	Age       string `json:"age"`
+	Node      string `json:"node,omitempty"`
+}
+
+type apiDeployment struct {
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace"`
+	Status      string `json:"status"`
+	Ready       string `json:"ready"`
+	Images      string `json:"images"`
+	HelmRelease string `json:"helm_release,omitempty"`
+}
+
+type apiCommit struct {
+	SHA         string `json:"sha"`
+	Description string `json:"description"`
+	Author      string `json:"author,omitempty"`
+	Committer   string `json:"committer,omitempty"`
+	PushedAt    string `json:"pushed_at"`
+}
+
+type apiHistoryEntry struct {
+	Time    string `json:"time"`
+	User    string `json:"user,omitempty"`
+	Action  string `json:"action"`
+	Target  string `json:"target"`
+	Status  string `json:"status"`
+	Details string `json:"details,omitempty"`
+}
+
+type apiJob struct {
+	Name     string `json:"name"`
+	Running  bool   `json:"running"`
+	NextRun  string `json:"next_run,omitempty"`
+	LastRun  string `json:"last_run,omitempty"`
+	Duration string `json:"duration,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// apiError is the body of every failed request.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func registerAPIRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/v1/login", apiLogin)
+	mux.Handle("GET /api/v1/me", requireUser(apiMe))
+	mux.Handle("GET /api/v1/images", requireUser(apiImages))
+	mux.Handle("DELETE /api/v1/images/{reference...}", requireRole(roleAdmin, apiDeleteImage))
+	mux.Handle("POST /api/v1/prune", requireRole(roleAdmin, apiPrune))
+	mux.Handle("GET /api/v1/pods", requireUser(apiPods))
+	mux.Handle("GET /api/v1/deployments", requireUser(apiDeployments))
+	mux.Handle("PUT /api/v1/deployments/{namespace}/{name}/image", requireRole(roleDeployer, apiDeploy))
+	mux.Handle("POST /api/v1/deployments/{namespace}/{name}/restart", requireRole(roleDeployer, apiRestart))
+	mux.Handle("GET /api/v1/commits", requireUser(apiCommits))
+	mux.Handle("GET /api/v1/history", requireUser(apiHistory))
+	mux.Handle("GET /api/v1/audit", requireRole(roleAdmin, apiAudit))
+	mux.Handle("GET /api/v1/jobs", requireUser(apiJobs))
+	mux.Handle("POST /api/v1/jobs/{name}/run", requireRole(roleAdmin, apiRunJob))
+	mux.Handle("GET /api/v1/auto-deploy/rules", requireUser(apiDeployRules))
+	mux.Handle("POST /api/v1/auto-deploy/rules/{id}/enable", requireRole(roleDeployer, apiChangeDeployRule("enable")))
+	mux.Handle("POST /api/v1/auto-deploy/rules/{id}/disable", requireRole(roleDeployer, apiChangeDeployRule("disable")))
+	mux.Handle("GET /api/v1/events", requireUser(apiEvents))
+}
+
+// requireUser runs handler for authenticated requests only.
+func requireUser(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := authenticateRequest(r)
+		if err != nil {
+			status := http.StatusUnauthorized
+			switch {
+			case errors.Is(err, errTooManyLogins):
+				status = http.StatusTooManyRequests
+			case !errors.Is(err, errInvalidCredentials):
+				status = http.StatusServiceUnavailable
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="local-container-registry"`)
+			writeAPIError(w, status, err)
+			return
+		}
+		handler(w, r.WithContext(withUser(r.Context(), user)))
+	})
+}
+
+// requireRole runs handler for users with role or one above it only. Refused
+// requests are recorded, so the audit log shows who tried what.
+func requireRole(role string, handler http.HandlerFunc) http.Handler {
+	return requireUser(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := userOf(r.Context())
+		if !roleAllows(user.Role, role) {
+			recordHistory(r.Context(), "denied", r.Method+" "+r.URL.Path, "failed", "role "+user.Role)
+			writeAPIError(w, http.StatusForbidden, fmt.Errorf("%s is a %s; this needs the %s role", user.Username, user.Role, role))
+			return
+		}
+		handler(w, r)
+	})
+}
+
+func authenticateRequest(r *http.Request) (User, error) {
+	if db == nil {
+		return User{}, fmt.Errorf("no database to check users against")
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return authenticateToken(r.Context(), strings.TrimSpace(token))
+	}
+	if username, password, ok := r.BasicAuth(); ok {
+		return authenticatePassword(r.Context(), r.RemoteAddr, username, password)
+	}
+	return User{}, errInvalidCredentials
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
+
+// apiLogin trades a username and password for a token that expires after
+// API_SESSION_TTL.
+func apiLogin(w http.ResponseWriter, r *http.Request) {
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&credentials); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid login: %v", err))
+		return
+	}
+	if db == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, fmt.Errorf("no database to check users against"))
+		return
+	}
+	user, err := authenticatePassword(r.Context(), r.RemoteAddr, credentials.Username, credentials.Password)
+	if err != nil {
+		recordHistory(withActor(r.Context(), credentials.Username), "login", r.RemoteAddr, "failed", err.Error())
+		if errors.Is(err, errTooManyLogins) {
+			writeAPIError(w, http.StatusTooManyRequests, err)
+			return
+		}
+		writeAPIError(w, http.StatusUnauthorized, errInvalidCredentials)
+		return
+	}
+	ttl := sessionTTL()
+	token, err := createAPIToken(r.Context(), user, "login", ttl)
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	recordHistory(withUser(r.Context(), user), "login", r.RemoteAddr, "succeeded", "")
+	writeJSON(w, http.StatusOK, map[string]any{
+		"token":      token,
+		"expires_at": time.Now().Add(ttl).UTC().Format(time.RFC3339),
+		"user":       user,
+	})
+}
+
+func apiMe(w http.ResponseWriter, r *http.Request) {
+	user, _ := userOf(r.Context())
+	writeJSON(w, http.StatusOK, user)
+}
+
+func apiImages(w http.ResponseWriter, r *http.Request) {
+	images, err := getDockerImagesInfo(r.Context())
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, err)
+		return
+	}
+	result := []apiImage{}
+	digests := make(map[string]string)
+	for _, image := range images {
+		item := apiImage{Digest: image.Digest, Size: image.Size, SizeBytes: image.SizeBytes, Created: image.CreatedAt, Artifact: image.ArtifactType, Labels: image.Labels}
+		if len(image.RepoTags) > 0 {
+			item.Reference = image.RepoTags[0]
+			digests[item.Reference] = image.Digest
+		}
+		result = append(result, item)
+	}
+	aliases := tagAliases(digests)
+	for i := range result {
+		result[i].Aliases = aliases[result[i].Reference]
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// apiDeleteImage deletes a tag's manifest from the registry, which removes
+// every tag of the same digest. A tag whose digest other tags still use is
+// only deleted with ?force=true.
+func apiDeleteImage(w http.ResponseWriter, r *http.Request) {
+	reference := r.PathValue("reference")
+	host, repository, tag := splitRegistryReference(reference)
+	client := newRegistryClient(r.Context(), host)
+	if err := client.ProbeDelete(r.Context(), repository); err != nil {
+		writeAPIError(w, http.StatusNotImplemented, fmt.Errorf("can't delete %s: %v", reference, err))
+		return
+	}
+	digest, err := client.ManifestDigest(r.Context(), repository, tag)
+	var aliases []string
+	if err == nil {
+		aliases, err = registryTagAliases(r.Context(), client, repository, tag, digest)
+	}
+	if force, _ := strconv.ParseBool(r.URL.Query().Get("force")); err == nil && len(aliases) > 0 && !force {
+		writeAPIError(w, http.StatusConflict, fmt.Errorf("%s is the same image as %s, which deleting its digest would delete too; pass ?force=true to delete them all", reference, strings.Join(aliases, ", ")))
+		return
+	}
+	if err == nil {
+		err = client.DeleteManifest(r.Context(), repository, digest)
+	}
+	details := "digest " + digest
+	if len(aliases) > 0 {
+		details += ", also tagged " + strings.Join(aliases, ", ")
+	}
+	recordActionResult(r.Context(), "delete", reference, err, details)
+	if errors.Is(err, registry.ErrUnsupported) {
+		writeAPIError(w, http.StatusNotImplemented, fmt.Errorf("can't delete %s: %v", reference, err))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, fmt.Errorf("failed to delete %s: %v", reference, err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"deleted": reference, "digest": digest})
+}
+
+// apiPrune applies the retention policy of the RETENTION_* settings;
+// ?dry_run=true only reports what it would delete.
+func apiPrune(w http.ResponseWriter, r *http.Request) {
+	policy, err := loadRetentionPolicy()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !policy.prunesTags() && !policy.untagged {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("no retention policy: set RETENTION_KEEP_LAST, RETENTION_MAX_AGE and/or RETENTION_UNTAGGED"))
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	if err := pruneRegistry(r.Context(), newRegistryClient(r.Context(), getRegistryHost()), policy, nil, dryRun); errors.Is(err, registry.ErrUnsupported) {
+		writeAPIError(w, http.StatusNotImplemented, err)
+		return
+	} else if err != nil {
+		writeAPIError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"dry_run": dryRun})
+}
+
+func apiPods(w http.ResponseWriter, r *http.Request) {
+	pods, err := getKubernetesPodsInfo(r.Context())
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, err)
+		return
+	}
+	result := []apiPod{}
+	for _, pod := range pods {
+		result = append(result, apiPod{Name: pod.PodName, Namespace: pod.Namespace, Status: pod.Status, Restarts: pod.Restarts, Age: pod.Age, Node: pod.NodeName})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func apiDeployments(w http.ResponseWriter, r *http.Request) {
+	deployments, err := getWorkloads(r.Context())
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, err)
+		return
+	}
+	result := []apiDeployment{}
+	for _, deployment := range deployments {
+		result = append(result, apiDeployment{Name: deployment.PodName, Namespace: deployment.Namespace, Status: deployment.Status,
+			Ready: deployment.Restarts, Images: deployment.ImageTag, HelmRelease: deployment.HelmRelease})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// apiDeploy sets the image of a deployment's container, the first one
+// unless the body names another, like deploying from the TUI.
+func apiDeploy(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Image     string `json:"image"`
+		Container string `json:"container"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil || body.Image == "" {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf(`expected {"image": "<reference>", "container": "<optional name>"}`))
+		return
+	}
+	namespace, name := r.PathValue("namespace"), r.PathValue("name")
+	err := deployImageToPod(r.Context(), body.Image, name, namespace, body.Container, nil)
+	recordActionResult(r.Context(), "deploy", namespace+"/"+name, err, "image "+body.Image)
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"deployment": namespace + "/" + name, "image": body.Image})
+}
+
+func apiRestart(w http.ResponseWriter, r *http.Request) {
+	namespace, name := r.PathValue("namespace"), r.PathValue("name")
+	ctx, cancel := withBackendTimeout(r.Context(), backendKubernetes)
+	err := restartDeployment(ctx, newCluster(ctx), name, namespace)
+	cancel()
+	recordActionResult(r.Context(), "restart", namespace+"/"+name, err, "")
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"restarted": namespace + "/" + name})
+}
+
+// apiCommits lists a page of commits, ?page=2 for older ones, and
+// ?service=web for those of a GIT_SERVICE_PATHS service.
+func apiCommits(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	query := gitCommitQuery{page: max(page, 1)}
+	if name := r.URL.Query().Get("service"); name != "" {
+		service, err := findGitService(name)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+		query.path = service.path
+	}
+	commits, more, err := getGitCommits(r.Context(), query)
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, err)
+		return
+	}
+	result := []apiCommit{}
+	for _, commit := range commits {
+		result = append(result, apiCommit{SHA: commit.CommitSHA, Description: commit.PRDescription, Author: commit.Author, Committer: commit.Committer, PushedAt: commit.PushedAt})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"commits": result, "more": more})
+}
+
+// apiHistory lists the latest recorded actions, ?limit= of them (default
+// 100).
+func apiHistory(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	entries, err := dataStore(r.Context()).History(r.Context(), limit)
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, fmt.Errorf("failed to read history: %v", err))
+		return
+	}
+	result := []apiHistoryEntry{}
+	for _, entry := range entries {
+		result = append(result, apiHistoryEntry{
+			Time:    entry.Time.UTC().Format(time.RFC3339),
+			User:    entry.Actor,
+			Action:  entry.Action,
+			Target:  entry.Target,
+			Status:  entry.Status,
+			Details: entry.Details,
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// apiJobs is the scheduled jobs of this daemon with their last and next
+// run.
+func apiJobs(w http.ResponseWriter, r *http.Request) {
+	jobStates.Lock()
+	defer jobStates.Unlock()
+	result := []apiJob{}
+	for _, name := range jobStates.names {
+		state := jobStates.byName[name]
+		job := apiJob{Name: name, Running: state.running}
+		if !state.next.IsZero() {
+			job.NextRun = state.next.UTC().Format(time.RFC3339)
+		}
+		if !state.lastRun.IsZero() {
+			job.LastRun = state.lastRun.UTC().Format(time.RFC3339)
+			job.Duration = state.took.String()
+		}
+		if state.err != nil {
+			job.Error = state.err.Error()
+		}
+		result = append(result, job)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// apiRunJob runs a job now, whether or not it is scheduled, and answers
+// once it is done.
+func apiRunJob(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if _, ok := jobKinds[name]; !ok {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("unknown job %q (have %s)", name, strings.Join(jobNames, ", ")))
+		return
+	}
+	if err := runJob(r.Context(), name); err != nil {
+		writeAPIError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"job": name})
+}
+
+// apiEvents streams the event bus as server-sent events, ?kind=images,pods
+// for only some kinds, so clients update as things change instead of
+// polling.
+func apiEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Errorf("streaming unsupported"))
+		return
+	}
+	var kinds []eventKind
+	if filter := r.URL.Query().Get("kind"); filter != "" {
+		for _, kind := range strings.Split(filter, ",") {
+			kinds = append(kinds, eventKind(kind))
+		}
+	}
+	events, cancel := bus.subscribe(kinds...)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	// Comments keep proxies from closing an idle stream
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case e := <-events:
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Kind, data)
+		}
+		flusher.Flush()
+	}
+}
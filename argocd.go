@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Values of TableData.Drift on the Apps tab
+const (
+	driftCurrent  = "current"
+	driftMissing  = "not in registry"
+	driftExternal = "external"
+)
+
+// argocdApplication is the part of an Argo CD Application the Apps tab shows.
+type argocdApplication struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Destination struct {
+			Namespace string `json:"namespace"`
+		} `json:"destination"`
+	} `json:"spec"`
+	Status struct {
+		Sync struct {
+			Status   string `json:"status"`
+			Revision string `json:"revision"`
+		} `json:"sync"`
+		Health struct {
+			Status string `json:"status"`
+		} `json:"health"`
+		Summary struct {
+			Images []string `json:"images"`
+		} `json:"summary"`
+	} `json:"status"`
+}
+
+// argocdConfigured reports whether ARGOCD_SERVER is set; the Apps tab stays
+// empty otherwise.
+func argocdConfigured() bool {
+	return os.Getenv("ARGOCD_SERVER") != ""
+}
+
+// getArgoCDApplications lists applications through the Argo CD API server,
+// authenticating with ARGOCD_AUTH_TOKEN (an API token, e.g. from
+// `argocd account generate-token`). ARGOCD_INSECURE=true accepts the
+// self-signed certificate a default install serves.
+func getArgoCDApplications(ctx context.Context) ([]argocdApplication, error) {
+	server := os.Getenv("ARGOCD_SERVER")
+	if !strings.HasPrefix(server, "http://") && !strings.HasPrefix(server, "https://") {
+		server = "https://" + server
+	}
+
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(server, "/")+"/api/v1/applications", nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("ARGOCD_AUTH_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	transport := http.DefaultTransport
+	if os.Getenv("ARGOCD_INSECURE") == "true" {
+		insecure := http.DefaultTransport.(*http.Transport).Clone()
+		insecure.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		transport = insecure
+	}
+	resp, err := (&http.Client{Transport: tracingTransport{base: transport}}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET /api/v1/applications: %s", resp.Status)
+	}
+
+	var list struct {
+		Items []argocdApplication `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// registryImageNames are the hosts the cluster may know the local registry
+// by; images from other registries can't be compared with its tags.
+func registryImageNames() map[string]bool {
+	hosts := map[string]bool{
+		getRegistryHost():             true,
+		"localhost:5000":              true,
+		"host.minikube.internal:5000": true,
+	}
+	if host := os.Getenv("KUBERNETES_REGISTRY_HOST"); host != "" {
+		hosts[host] = true
+	}
+	return hosts
+}
+
+// getArgoCDInventory returns one row per image of every application, with
+// the image's drift from the registry: current when no newer tag of the
+// repository was pushed, "behind <tag>" naming the newest one otherwise.
+func getArgoCDInventory(ctx context.Context) ([]TableData, error) {
+	apps, err := getArgoCDApplications(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newRegistryClient(ctx, getRegistryHost())
+	hosts := registryImageNames()
+	newest := make(map[string]string) // repository -> newest tag, memoized across apps
+
+	var rows []TableData
+	for _, app := range apps {
+		row := TableData{
+			AppName:   app.Metadata.Name,
+			Namespace: app.Spec.Destination.Namespace,
+			Status:    app.Status.Sync.Status,
+			Health:    app.Status.Health.Status,
+			CommitSHA: app.Status.Sync.Revision,
+		}
+		if len(app.Status.Summary.Images) == 0 {
+			rows = append(rows, row)
+			continue
+		}
+		for _, image := range app.Status.Summary.Images {
+			row.ImageTag = image
+			row.Drift = driftExternal
+			if host := imageRegistryHost(image); hosts[host] {
+				repository, tag := splitImageTag(strings.TrimPrefix(image, host+"/"))
+				row.Drift = registryDrift(ctx, client, repository, tag, newest)
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func registryDrift(ctx context.Context, client registryClient, repository, tag string, newest map[string]string) string {
+	latest, ok := newest[repository]
+	if !ok {
+		tags, err := client.Tags(ctx, repository)
+		if err != nil {
+			return "unknown"
+		}
+		latest = newestTag(ctx, client.Host(), repository, tags)
+		newest[repository] = latest
+	}
+
+	switch {
+	case latest == "":
+		return driftMissing
+	case latest == tag:
+		return driftCurrent
+	}
+	if exists, err := client.ManifestExists(ctx, repository, tag); err == nil && !exists {
+		return driftMissing
+	}
+	return "behind " + latest
+}
+
+// newestTag picks the tag whose image was built last, ignoring tags whose
+// creation time can't be read.
+func newestTag(ctx context.Context, registryHost, repository string, tags []string) string {
+	type created struct{ tag, at string }
+	var dated []created
+	for _, tag := range tags {
+		// Formatted as 2006-01-02 15:04:05, so they sort as strings
+		if at := getImageCreationTime(ctx, registryHost, repository, tag); at != "Unknown" {
+			dated = append(dated, created{tag, at})
+		}
+	}
+	if len(dated) == 0 {
+		return ""
+	}
+	sort.Slice(dated, func(i, j int) bool { return dated[i].at > dated[j].at })
+	return dated[0].tag
+}
+
+type argocdDataMsg struct {
+	data []TableData
+	err  error
+}
+
+func (m model) renderArgoCDStatus() string {
+	switch {
+	case !argocdConfigured():
+		return "Set ARGOCD_SERVER and ARGOCD_AUTH_TOKEN to show Argo CD applications"
+	case m.argocdData == nil && m.argocdErr == nil:
+		return fmt.Sprintf("Loading applications from %s...", os.Getenv("ARGOCD_SERVER"))
+	case m.argocdErr != nil:
+		return fmt.Sprintf("⚠️  %v", m.argocdErr)
+	}
+	drifted := 0
+	for _, row := range m.argocdData {
+		if strings.HasPrefix(row.Drift, "behind") || row.Drift == driftMissing {
+			drifted++
+		}
+	}
+	return fmt.Sprintf("Argo CD %s · %d images behind the registry or missing from it", os.Getenv("ARGOCD_SERVER"), drifted)
+}
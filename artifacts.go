@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kinds of OCI artifacts other than container images, told apart by the
+// manifest's artifactType, config media type or layer media types.
+const (
+	artifactHelmChart   = "Helm chart"
+	artifactSBOM        = "SBOM"
+	artifactSignature   = "Signature"
+	artifactAttestation = "Attestation"
+	artifactWASM        = "WASM"
+	artifactOther       = "Artifact"
+)
+
+// imageConfigTypes are the config media types of runnable container images.
+var imageConfigTypes = map[string]bool{
+	"application/vnd.docker.container.image.v1+json": true,
+	"application/vnd.oci.image.config.v1+json":       true,
+}
+
+// artifactKinds maps artifact, config and layer media types to a kind.
+var artifactKinds = map[string]string{
+	"application/vnd.cncf.helm.config.v1+json":            artifactHelmChart,
+	"application/vnd.cncf.helm.chart.content.v1.tar+gzip": artifactHelmChart,
+	"application/spdx+json":                               artifactSBOM,
+	"text/spdx":                                           artifactSBOM,
+	"application/vnd.cyclonedx+json":                      artifactSBOM,
+	"application/vnd.syft+json":                           artifactSBOM,
+	"application/vnd.dev.cosign.simplesigning.v1+json":    artifactSignature,
+	"application/vnd.cncf.notary.signature":               artifactSignature,
+	"application/vnd.dsse.envelope.v1+json":               artifactAttestation,
+	"application/vnd.in-toto+json":                        artifactAttestation,
+	"application/vnd.wasm.config.v0+json":                 artifactWASM,
+	"application/vnd.wasm.config.v1+json":                 artifactWASM,
+	"application/vnd.module.wasm.content.layer.v1+wasm":   artifactWASM,
+	"application/vnd.wasm.content.layer.v1+wasm":          artifactWASM,
+}
+
+// ociArtifactManifest is the part of a manifest that tells artifacts apart.
+type ociArtifactManifest struct {
+	MediaType    string               `json:"mediaType"`
+	ArtifactType string               `json:"artifactType"`
+	Config       registryDescriptor   `json:"config"`
+	Layers       []registryDescriptor `json:"layers"`
+	Subject      *registryDescriptor  `json:"subject"`
+	Annotations  map[string]string    `json:"annotations"`
+}
+
+// artifactKind classifies a manifest, returning "" for container images and
+// image indexes. Signatures and attestations pushed by cosign look like
+// images but for their layers, so the layers decide when the config doesn't.
+func artifactKind(manifest ociArtifactManifest) string {
+	if kind, ok := artifactKinds[manifest.ArtifactType]; ok {
+		return kind
+	}
+	if kind, ok := artifactKinds[manifest.Config.MediaType]; ok {
+		return kind
+	}
+	for _, layer := range manifest.Layers {
+		if kind, ok := artifactKinds[layer.MediaType]; ok {
+			return kind
+		}
+	}
+	if manifest.Config.MediaType == "" || imageConfigTypes[manifest.Config.MediaType] {
+		return ""
+	}
+	return artifactOther
+}
+
+// artifactInfo is what the Docker tab shows for a non-image artifact.
+type artifactInfo struct {
+	kind      string
+	detail    string // e.g. the chart's name and version or the signed digest
+	createdAt string
+}
+
+// describeArtifact inspects a registry manifest; ok is false for container
+// images, which the usual columns already describe.
+func describeArtifact(ctx context.Context, client registryClient, repository, tag string, body []byte) (artifactInfo, bool) {
+	var manifest ociArtifactManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return artifactInfo{}, false
+	}
+	info := artifactInfo{kind: artifactKind(manifest), createdAt: "-"}
+	if info.kind == "" {
+		return artifactInfo{}, false
+	}
+
+	if created, err := time.Parse(time.RFC3339, manifest.Annotations["org.opencontainers.image.created"]); err == nil {
+		info.createdAt = created.Format("2006-01-02 15:04:05")
+	}
+	switch {
+	case info.kind == artifactHelmChart:
+		var chart struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}
+		if config, err := client.Blob(ctx, repository, manifest.Config.Digest); err == nil && json.Unmarshal(config, &chart) == nil {
+			info.detail = chart.Name + " " + chart.Version
+		}
+	case manifest.Subject != nil:
+		// OCI 1.1 referrers name the manifest they're about
+		info.detail = "for " + shortDigest(manifest.Subject.Digest)
+	case strings.HasPrefix(tag, "sha256-"):
+		// cosign's tag scheme: sha256-<digest>.sig, .att or .sbom
+		digest, _, _ := strings.Cut(strings.TrimPrefix(tag, "sha256-"), ".")
+		info.detail = "for " + shortDigest("sha256:"+digest)
+	}
+	return info, true
+}
+
+// artifactLabel is the Docker tab's Type column, e.g. "Helm chart web 1.2.0".
+func artifactLabel(item TableData) string {
+	if item.ArtifactType == "" {
+		return "Image"
+	}
+	return strings.TrimSpace(item.ArtifactType + " " + item.ArtifactDetail)
+}
+
+// artifactHint says how to use an artifact the TUI can't deploy or pull
+// with Docker.
+func artifactHint(item TableData) string {
+	if item.ArtifactType == artifactHelmChart {
+		// The tag separator is the colon after the last slash
+		separator := strings.LastIndex(item.ImageTag, ":")
+		reference, version := item.ImageTag[:separator], item.ImageTag[separator+1:]
+		return fmt.Sprintf("⎈ Install the chart with: helm install %s oci://%s --version %s",
+			reference[strings.LastIndex(reference, "/")+1:], reference, version)
+	}
+	return fmt.Sprintf("%s isn't a container image; fetch it with: oras pull %s", item.ArtifactType, item.ImageTag)
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/store"
+)
+
+// The audit log is written as JSON lines or, with AUDIT_LOG_FORMAT=cef, in
+// the Common Event Format SIEMs such as Splunk, QRadar and Sentinel ingest
+// as they are. Besides AUDIT_LOG_FILE, each action can go to AUDIT_LOG_URL:
+// an http(s):// endpoint it's POSTed to, or a udp:// or tcp:// syslog
+// collector. audit-export writes the history table's entries in either
+// format, e.g. to hand a security team the last month or to backfill a SIEM.
+
+const (
+	auditFormatJSON = "json"
+	auditFormatCEF  = "cef"
+)
+
+// backendAudit is the audit log endpoint, for AUDIT_PROXY.
+const backendAudit = "AUDIT"
+
+// auditDeliveryTimeout bounds sending one event to AUDIT_LOG_URL.
+const auditDeliveryTimeout = 10 * time.Second
+
+// auditLogFormat is AUDIT_LOG_FORMAT, JSON lines when unset or unknown.
+func auditLogFormat() string {
+	switch format := os.Getenv("AUDIT_LOG_FORMAT"); format {
+	case "", auditFormatJSON:
+		return auditFormatJSON
+	case auditFormatCEF:
+		return auditFormatCEF
+	default:
+		log.Printf("⚠️  Ignoring AUDIT_LOG_FORMAT=%q: use json or cef", format)
+		return auditFormatJSON
+	}
+}
+
+// formatAuditEvent is the event as one line, without the newline.
+func formatAuditEvent(event auditEvent, format string) string {
+	if format == auditFormatCEF {
+		return cefLine(event)
+	}
+	line, _ := json.Marshal(event)
+	return string(line)
+}
+
+// auditSeverity ranks actions for CEF: failures and denials stand out
+// most, then what removes or replaces images and workloads.
+func auditSeverity(event auditEvent) int {
+	switch {
+	case event.Status == "failed" || event.Status == "denied":
+		return 7
+	case strings.Contains(event.Action, "delete") || event.Action == "prune" || event.Action == "migrate":
+		return 5
+	}
+	return 3
+}
+
+// cefVersion is the version CEF lines name the product by.
+var cefVersion = sync.OnceValue(func() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+})
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// cefLine is the event in the Common Event Format:
+//
+//	CEF:0|anthony-gilbert|local-container-registry|dev|delete|delete succeeded|5|rt=... suser=alice act=delete outcome=succeeded cs1Label=target cs1=web:v1 msg=...
+func cefLine(event auditEvent) string {
+	header := []string{"CEF:0", "anthony-gilbert", "local-container-registry", cefVersion(),
+		event.Action, event.Action + " " + event.Status, fmt.Sprint(auditSeverity(event))}
+	for i := range header[1:] {
+		header[i+1] = cefHeaderEscaper.Replace(header[i+1])
+	}
+	var extension []string
+	add := func(key, value string) {
+		if value != "" {
+			extension = append(extension, key+"="+cefExtensionEscaper.Replace(value))
+		}
+	}
+	if at, err := time.Parse(time.RFC3339Nano, event.Time); err == nil {
+		add("rt", fmt.Sprint(at.UnixMilli()))
+	}
+	add("suser", event.User)
+	add("act", event.Action)
+	add("outcome", event.Status)
+	add("cs1Label", "target")
+	add("cs1", event.Target)
+	add("msg", event.Details)
+	return strings.Join(header, "|") + "|" + strings.Join(extension, " ")
+}
+
+// auditSender delivers audit lines to an endpoint.
+type auditSender struct {
+	endpoint *url.URL
+	client   *http.Client
+	conn     net.Conn // syslog collectors, dialled on first use
+	hostname string
+}
+
+// newAuditSender sends to an http(s)://, udp:// or tcp:// URL.
+func newAuditSender(rawURL string) (*auditSender, error) {
+	endpoint, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("audit log endpoint %q is not a URL: %v", rawURL, err)
+	}
+	switch endpoint.Scheme {
+	case "http", "https", "udp", "tcp":
+	default:
+		return nil, fmt.Errorf("audit log endpoint %q must be an http(s)://, udp:// or tcp:// URL", rawURL)
+	}
+	hostname, _ := os.Hostname()
+	return &auditSender{
+		endpoint: endpoint,
+		client:   &http.Client{Transport: tracingTransport{base: proxiedTransport(backendAudit)}, Timeout: auditDeliveryTimeout},
+		hostname: hostname,
+	}, nil
+}
+
+// send delivers one line: POSTed as is, or as a syslog message.
+func (s *auditSender) send(line, format string) error {
+	if s.endpoint.Scheme == "http" || s.endpoint.Scheme == "https" {
+		contentType := "application/x-ndjson"
+		if format == auditFormatCEF {
+			contentType = "text/plain"
+		}
+		resp, err := s.client.Post(s.endpoint.String(), contentType, bytes.NewReader([]byte(line+"\n")))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s", resp.Status)
+		}
+		return nil
+	}
+
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.endpoint.Scheme, s.endpoint.Host, auditDeliveryTimeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	// <86> is facility authpriv (10), severity informational
+	message := fmt.Sprintf("<86>%s %s local-container-registry: %s\n", time.Now().Format(time.Stamp), s.hostname, line)
+	s.conn.SetWriteDeadline(time.Now().Add(auditDeliveryTimeout))
+	if _, err := io.WriteString(s.conn, message); err != nil {
+		s.conn.Close()
+		s.conn = nil // dialled again for the next event
+		return err
+	}
+	return nil
+}
+
+func (s *auditSender) close() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// auditPollInterval is how often the forwarder looks for actions it
+// wasn't told about, like those of headless commands.
+const auditPollInterval = 5 * time.Second
+
+// auditMaxBackoff caps the wait between attempts while AUDIT_LOG_URL fails.
+const auditMaxBackoff = time.Minute
+
+// auditBatchSize is how many history entries are read at a time.
+const auditBatchSize = 100
+
+// auditCursorPath is where the id of the last forwarded history entry is
+// kept, so forwarding resumes after it across restarts.
+func auditCursorPath() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "local-container-registry", "audit-cursor")
+}
+
+func readAuditCursor() (int64, bool) {
+	content, err := os.ReadFile(auditCursorPath())
+	if err != nil {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	return id, err == nil
+}
+
+func writeAuditCursor(id int64) error {
+	path := auditCursorPath()
+	if path == "" {
+		return fmt.Errorf("no config directory to keep the audit cursor in")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strconv.FormatInt(id, 10)+"\n"), 0644)
+}
+
+// auditForwarder reads the history table as a queue: an entry is only
+// passed once AUDIT_LOG_URL took it, so what's recorded while the endpoint
+// is down, or by other processes, goes out once it's back.
+type auditForwarder struct {
+	sender *auditSender
+	format string
+	cursor int64 // id of the last entry sent
+	ready  bool  // cursor was read
+}
+
+// forward sends the entries recorded after the cursor, stopping at the
+// first that fails.
+func (f *auditForwarder) forward(ctx context.Context) error {
+	history := dataStore(ctx)
+	if history == nil {
+		return nil // the TUI connects later
+	}
+	if !f.ready {
+		cursor, ok := readAuditCursor()
+		if !ok {
+			// A first start forwards from now on; audit-export backfills
+			latest, err := history.History(ctx, 1)
+			if err != nil {
+				return fmt.Errorf("failed to read history: %v", err)
+			}
+			if len(latest) > 0 {
+				cursor = latest[0].ID
+			}
+			if err := writeAuditCursor(cursor); err != nil {
+				return fmt.Errorf("failed to save the audit cursor: %v", err)
+			}
+		}
+		f.cursor, f.ready = cursor, true
+	}
+	for {
+		entries, err := history.HistoryAfter(ctx, f.cursor, auditBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to read history: %v", err)
+		}
+		for _, entry := range entries {
+			if err := f.sender.send(formatAuditEvent(historyAuditEvent(entry), f.format), f.format); err != nil {
+				return fmt.Errorf("failed to forward the %s audit event to %s: %v", entry.Action, f.sender.endpoint.Redacted(), err)
+			}
+			f.cursor = entry.ID
+			if err := writeAuditCursor(f.cursor); err != nil {
+				return fmt.Errorf("failed to save the audit cursor: %v", err)
+			}
+		}
+		if len(entries) < auditBatchSize {
+			return nil
+		}
+	}
+}
+
+// startAuditForwarding sends each action recorded in the history table to
+// AUDIT_LOG_URL, in order and retrying until the endpoint takes it, until
+// the process shuts down. The TUI and serve forward; headless commands
+// only record, and their actions go out with the next forward.
+func startAuditForwarding() {
+	rawURL := os.Getenv("AUDIT_LOG_URL")
+	if rawURL == "" {
+		return
+	}
+	sender, err := newAuditSender(rawURL)
+	if err != nil {
+		log.Printf("⚠️  Not forwarding the audit log: %v", err)
+		return
+	}
+	forwarder := &auditForwarder{sender: sender, format: auditLogFormat()}
+	// A recorded action wakes the forwarder; one the bus drops is picked up
+	// by the next poll
+	events, unsubscribe := bus.subscribe(eventHistory)
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		defer sender.close()
+		defer unsubscribe()
+		var backoff time.Duration
+		for {
+			wait := auditPollInterval
+			if err := forwarder.forward(ctx); err != nil && ctx.Err() == nil {
+				backoff = min(max(2*backoff, time.Second), auditMaxBackoff)
+				wait = backoff
+				log.Printf("%v, retrying in %s", err, backoff)
+			} else {
+				backoff = 0
+			}
+			wake := events
+			if backoff > 0 {
+				wake = nil // an action is no reason to retry sooner
+			}
+			select {
+			case <-ctx.Done():
+				// What's recorded by now goes out, or with the next start
+				final, stop := context.WithTimeout(context.Background(), auditDeliveryTimeout)
+				defer stop()
+				forwarder.forward(final)
+				return
+			case <-wake:
+			case <-time.After(wait):
+			}
+		}
+	}()
+	onShutdown(func() {
+		cancel()
+		select {
+		case <-finished:
+		case <-time.After(auditDeliveryTimeout):
+			log.Printf("gave up waiting for audit events to be forwarded")
+		}
+	})
+}
+
+// historyAuditEvent is a history table entry as an audit log event.
+func historyAuditEvent(entry store.HistoryEntry) auditEvent {
+	return auditEvent{
+		Time:    entry.Time.UTC().Format(time.RFC3339Nano),
+		User:    entry.Actor,
+		Action:  entry.Action,
+		Target:  entry.Target,
+		Status:  entry.Status,
+		Details: entry.Details,
+	}
+}
+
+// parseAuditTime reads --since and --until: a duration back from now, like
+// 720h, or a date or RFC 3339 time.
+func parseAuditTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if at, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return at, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is neither a duration like 720h nor a date like 2024-05-01", value)
+}
+
+// runAuditExport writes the recorded actions as JSON lines or CEF to a
+// file, stdout or an endpoint.
+func runAuditExport(args []string) error {
+	fs := flag.NewFlagSet("audit-export", flag.ContinueOnError)
+	format := fs.String("format", auditFormatJSON, "json (JSON lines) or cef")
+	since := fs.String("since", "", "only actions since, a duration like 720h or a date (default: all)")
+	until := fs.String("until", "", "only actions before, a duration or a date (default: now)")
+	out := fs.String("out", "", "file to write to (default: stdout)")
+	endpoint := fs.String("url", "", "http(s)://, udp:// or tcp:// endpoint to send each action to instead")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != auditFormatJSON && *format != auditFormatCEF {
+		return fmt.Errorf("audit-export: --format must be json or cef")
+	}
+	if *out != "" && *endpoint != "" {
+		return fmt.Errorf("audit-export: --out and --url can't be used together")
+	}
+	now := time.Now()
+	from, err := parseAuditTime(*since, now)
+	if err != nil {
+		return fmt.Errorf("audit-export: --since %v", err)
+	}
+	to, err := parseAuditTime(*until, now)
+	if err != nil {
+		return fmt.Errorf("audit-export: --until %v", err)
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	connectDatabase(ctx)
+	if dataStore(ctx) == nil {
+		return fmt.Errorf("audit-export: the history is kept in the database")
+	}
+	entries, err := dataStore(ctx).HistoryBetween(ctx, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to read history: %v", err)
+	}
+
+	if *endpoint != "" {
+		sender, err := newAuditSender(*endpoint)
+		if err != nil {
+			return err
+		}
+		defer sender.close()
+		for i, entry := range entries {
+			if err := sender.send(formatAuditEvent(historyAuditEvent(entry), *format), *format); err != nil {
+				return fmt.Errorf("sent %d of %d actions, then failed: %v", i, len(entries), err)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "📤 Sent %d actions to %s\n", len(entries), sender.endpoint.Redacted())
+		return nil
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		file, err := os.OpenFile(*out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	for _, entry := range entries {
+		if _, err := fmt.Fprintln(w, formatAuditEvent(historyAuditEvent(entry), *format)); err != nil {
+			return err
+		}
+	}
+	if *out != "" {
+		fmt.Fprintf(os.Stderr, "📤 Wrote %d actions to %s\n", len(entries), *out)
+	}
+	return nil
+}
+
+// apiAudit exports the recorded actions like audit-export: ?format=json or
+// cef, ?since= and ?until= durations or dates.
+func apiAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = auditFormatJSON
+	}
+	if format != auditFormatJSON && format != auditFormatCEF {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("format must be json or cef"))
+		return
+	}
+	now := time.Now()
+	since, err := parseAuditTime(query.Get("since"), now)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("since: %v", err))
+		return
+	}
+	until, err := parseAuditTime(query.Get("until"), now)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("until: %v", err))
+		return
+	}
+	if dataStore(r.Context()) == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, fmt.Errorf("no database to read the history from"))
+		return
+	}
+	entries, err := dataStore(r.Context()).HistoryBetween(r.Context(), since, until)
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, fmt.Errorf("failed to read history: %v", err))
+		return
+	}
+	contentType := "application/x-ndjson"
+	if format == auditFormatCEF {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	for _, entry := range entries {
+		fmt.Fprintln(w, formatAuditEvent(historyAuditEvent(entry), format))
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/kube"
+	"github.com/anthony-gilbert/local-container-registry/pkg/store"
+)
+
+// Auto-deploy rules make serve a minimal CD controller: when a repository
+// of the registry gets a tag matching a rule's pattern, the rule's
+// deployment is rolled to it. Rules live in the database's deploy_rules
+// table and are managed with the auto-deploy command or the REST API; each
+// can be disabled without deleting it. serve sees pushes the way its
+// collectors load images (COLLECT_INTERVAL), so tags pushed while it was
+// down aren't deployed. Every deploy is recorded in the history as an
+// auto-deploy action, and the rule keeps what it deployed last.
+
+// autoDeployActor is who the history attributes auto-deploys to.
+const autoDeployActor = "auto-deploy"
+
+var errNoDeployRule = errors.New("no auto-deploy rule")
+
+// ruleMatches reports whether a pushed image reference is one the rule deploys.
+func ruleMatches(rule store.DeployRule, reference string) bool {
+	_, repository, tag := splitRegistryReference(reference)
+	matched, _ := path.Match(rule.TagPattern, tag)
+	return rule.Enabled && repository == rule.Repository && matched
+}
+
+// validateDeployRule reports why a rule can't be added.
+func validateDeployRule(rule store.DeployRule) error {
+	switch {
+	case rule.Repository == "":
+		return fmt.Errorf("a repository is required")
+	case rule.Deployment == "":
+		return fmt.Errorf("a deployment is required")
+	}
+	if _, err := path.Match(rule.TagPattern, ""); err != nil {
+		return fmt.Errorf("tag pattern %q is malformed, e.g. v* or main-*", rule.TagPattern)
+	}
+	return validateNamespaceName(rule.Namespace)
+}
+
+// startAutoDeploy deploys the pushes serve's collectors find by the rules,
+// read again for every push so rule changes apply right away.
+func startAutoDeploy(ctx context.Context) {
+	if dataStore(ctx) == nil || !kubernetesEnabled() {
+		return
+	}
+	events, cancel := bus.subscribe(eventImages)
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
+	go func() {
+		var watcher tagWatcher
+		for e := range events {
+			images, _ := e.Data.([]DockerImage)
+			pushed := watcher.pushed(images)
+			if len(pushed) == 0 {
+				continue
+			}
+			rules, err := dataStore(ctx).DeployRules(ctx)
+			if err != nil {
+				log.Printf("failed to read the auto-deploy rules: %v", err)
+				continue
+			}
+			for _, rule := range rules {
+				if image, ok := newestMatch(rule, pushed); ok {
+					autoDeploy(ctx, rule, image)
+				}
+			}
+		}
+	}()
+}
+
+// newestMatch is the most recently built of the pushed images the rule
+// deploys; several tags of one build, e.g. v1.2 and latest, are deployed
+// once.
+func newestMatch(rule store.DeployRule, pushed []DockerImage) (string, bool) {
+	var newest *DockerImage
+	for i, image := range pushed {
+		if image.ArtifactType != "" || !ruleMatches(rule, image.RepoTags[0]) {
+			continue
+		}
+		// CreatedAt is "2006-01-02 15:04:05" or Unknown, so it sorts as text
+		if newest == nil || image.CreatedAt >= newest.CreatedAt {
+			newest = &pushed[i]
+		}
+	}
+	if newest == nil {
+		return "", false
+	}
+	return newest.RepoTags[0], true
+}
+
+// autoDeploy rolls a rule's deployment to image and records how it went.
+func autoDeploy(ctx context.Context, rule store.DeployRule, image string) {
+	ctx = withActor(ctx, autoDeployActor)
+	target := rule.Namespace + "/" + rule.Deployment
+	status, err := deployByRule(ctx, rule, image)
+	recordActionResult(ctx, "auto-deploy", target, err, fmt.Sprintf("rule %d: %s", rule.ID, status))
+	result := "succeeded"
+	if err != nil {
+		result = "failed"
+		log.Printf("auto-deploy rule %d: deploying %s to %s failed: %v", rule.ID, image, target, err)
+	} else {
+		log.Printf("🚀 auto-deploy rule %d: %s", rule.ID, status)
+	}
+	if err := dataStore(ctx).RecordDeployRuleRun(ctx, rule.ID, image, result); err != nil {
+		log.Printf("failed to record the run of auto-deploy rule %d: %v", rule.ID, err)
+	}
+}
+
+// deployByRule deploys image the way the TUI does, GitOps export and Helm
+// upgrades included. A tag pushed again, e.g. latest, doesn't change the
+// deployment's image, so its pods are restarted to pull it instead.
+func deployByRule(ctx context.Context, rule store.DeployRule, image string) (string, error) {
+	if gitopsEnabled() {
+		return exportDeployment(ctx, clusterImageName(ctx, image), rule.Deployment, rule.Namespace, rule.Container)
+	}
+	cluster := newCluster(ctx)
+	getCtx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	deployment, err := cluster.Deployment(getCtx, rule.Namespace, rule.Deployment)
+	cancel()
+	if err != nil {
+		return "", fmt.Errorf("error getting deployment %s: %v", rule.Deployment, err)
+	}
+	index, err := kube.ContainerIndex(deployment, rule.Container)
+	if err != nil {
+		return "", err
+	}
+	if release, _ := helmRelease(deployment); release == "" && deployment.Spec.Template.Spec.Containers[index].Image == clusterImageName(ctx, image) {
+		restartCtx, cancel := withBackendTimeout(ctx, backendKubernetes)
+		defer cancel()
+		if err := cluster.Restart(restartCtx, rule.Namespace, rule.Deployment); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("restarted %s/%s to pull %s again", rule.Namespace, rule.Deployment, image), nil
+	}
+	if err := deployImageToPod(ctx, image, rule.Deployment, rule.Namespace, rule.Container, nil); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("deployed %s to %s/%s", image, rule.Namespace, rule.Deployment), nil
+}
+
+// deployRuleLines lists the rules with what they deployed last, for the
+// auto-deploy command.
+func deployRuleLines(rules []store.DeployRule) []string {
+	lines := []string{fmt.Sprintf("%-4s %-8s %-28s %-12s %-32s %s", "ID", "STATE", "REPOSITORY", "TAGS", "DEPLOYMENT", "LAST DEPLOY")}
+	for _, rule := range rules {
+		state := "enabled"
+		if !rule.Enabled {
+			state = "disabled"
+		}
+		target := rule.Namespace + "/" + rule.Deployment
+		if rule.Container != "" {
+			target += ":" + rule.Container
+		}
+		last := "not yet"
+		if !rule.LastRun.IsZero() {
+			_, _, tag := splitRegistryReference(rule.LastImage)
+			last = fmt.Sprintf("%s %s, %s", rule.LastRun.Format("2006-01-02 15:04"), tag, rule.LastStatus)
+		}
+		lines = append(lines, fmt.Sprintf("%-4d %-8s %-28s %-12s %-32s %s", rule.ID, state, truncateString(rule.Repository, 28), truncateString(rule.TagPattern, 12), truncateString(target, 32), last))
+	}
+	return lines
+}
+
+func runAutoDeploy(args []string) error {
+	usage := fmt.Errorf("usage: auto-deploy [list] | add --repo <repository> --deployment <name> [flags] | enable|disable|remove <id>")
+	command := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("auto-deploy "+command, flag.ContinueOnError)
+	repository := fs.String("repo", "", "repository whose new tags are deployed, e.g. team/web (add)")
+	tags := fs.String("tags", "*", "pattern of the tags deployed, e.g. v* or main-* (add)")
+	namespace := fs.String("namespace", envOrDefault("KUBERNETES_NAMESPACE", "default"), "namespace of the deployment (add)")
+	deployment := fs.String("deployment", "", "deployment rolled to the new tags (add)")
+	container := fs.String("container", "", "container of the deployment that gets the image (default: the first) (add)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	if err := requireDatabase(ctx); err != nil {
+		return err
+	}
+	rules := dataStore(ctx)
+
+	switch command {
+	case "list":
+		list, err := rules.DeployRules(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read the rules: %v", err)
+		}
+		if len(list) == 0 {
+			fmt.Println("No auto-deploy rules; add one with: auto-deploy add --repo team/web --tags 'v*' --deployment web")
+			return nil
+		}
+		for _, line := range deployRuleLines(list) {
+			fmt.Println(line)
+		}
+	case "add":
+		rule := store.DeployRule{Repository: strings.Trim(*repository, "/"), TagPattern: *tags, Namespace: *namespace, Deployment: *deployment, Container: *container}
+		if err := validateDeployRule(rule); err != nil {
+			return err
+		}
+		id, err := rules.AddDeployRule(ctx, rule)
+		if err != nil {
+			return fmt.Errorf("failed to add the rule: %v", err)
+		}
+		recordHistory(ctx, "auto-deploy-rule", "rule "+strconv.FormatInt(id, 10), "added", fmt.Sprintf("%s:%s to %s/%s", rule.Repository, rule.TagPattern, rule.Namespace, rule.Deployment))
+		fmt.Printf("✅ Added rule %d: tags of %s matching %s go to %s/%s once serve sees them\n", id, rule.Repository, rule.TagPattern, rule.Namespace, rule.Deployment)
+	case "enable", "disable", "remove":
+		if fs.NArg() != 1 {
+			return usage
+		}
+		id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+		if err != nil {
+			return fmt.Errorf("rule ID %q is not a number", fs.Arg(0))
+		}
+		if err := changeDeployRule(ctx, id, command); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Rule %d %s\n", id, ruleChangeStatus[command])
+	default:
+		return usage
+	}
+	return nil
+}
+
+// ruleChangeStatus is how the history words enabling, disabling and
+// removing a rule.
+var ruleChangeStatus = map[string]string{"enable": "enabled", "disable": "disabled", "remove": "removed"}
+
+// changeDeployRule enables, disables or removes a rule and records it.
+func changeDeployRule(ctx context.Context, id int64, change string) error {
+	var err error
+	switch change {
+	case "enable", "disable":
+		err = dataStore(ctx).SetDeployRuleEnabled(ctx, id, change == "enable")
+	case "remove":
+		err = dataStore(ctx).DeleteDeployRule(ctx, id)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w %d", errNoDeployRule, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to %s rule %d: %v", change, id, err)
+	}
+	recordHistory(ctx, "auto-deploy-rule", "rule "+strconv.FormatInt(id, 10), ruleChangeStatus[change], "")
+	return nil
+}
+
+// apiDeployRule is a rule as the REST API lists it.
+type apiDeployRule struct {
+	ID         int64  `json:"id"`
+	Repository string `json:"repository"`
+	TagPattern string `json:"tag_pattern"`
+	Namespace  string `json:"namespace"`
+	Deployment string `json:"deployment"`
+	Container  string `json:"container,omitempty"`
+	Enabled    bool   `json:"enabled"`
+	LastImage  string `json:"last_image,omitempty"`
+	LastStatus string `json:"last_status,omitempty"`
+	LastRun    string `json:"last_run,omitempty"`
+}
+
+func apiDeployRules(w http.ResponseWriter, r *http.Request) {
+	if dataStore(r.Context()) == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, fmt.Errorf("no database to read the rules from"))
+		return
+	}
+	rules, err := dataStore(r.Context()).DeployRules(r.Context())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	result := []apiDeployRule{}
+	for _, rule := range rules {
+		item := apiDeployRule{ID: rule.ID, Repository: rule.Repository, TagPattern: rule.TagPattern, Namespace: rule.Namespace,
+			Deployment: rule.Deployment, Container: rule.Container, Enabled: rule.Enabled, LastImage: rule.LastImage, LastStatus: rule.LastStatus}
+		if !rule.LastRun.IsZero() {
+			item.LastRun = rule.LastRun.UTC().Format(time.RFC3339)
+		}
+		result = append(result, item)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// apiChangeDeployRule enables or disables the rule of the path, change
+// being enable or disable.
+func apiChangeDeployRule(change string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("rule ID %q is not a number", r.PathValue("id")))
+			return
+		}
+		if dataStore(r.Context()) == nil {
+			writeAPIError(w, http.StatusServiceUnavailable, fmt.Errorf("no database to keep the rules in"))
+			return
+		}
+		if err := changeDeployRule(r.Context(), id, change); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, errNoDeployRule) {
+				status = http.StatusNotFound
+			}
+			writeAPIError(w, status, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"id": id, "enabled": change == "enable"})
+	}
+}
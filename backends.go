@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/backend"
+	"github.com/anthony-gilbert/local-container-registry/pkg/docker"
+	"github.com/anthony-gilbert/local-container-registry/pkg/kube"
+)
+
+// The TUI reaches each backend through its interface in pkg/backend. The
+// model holds the ones newModel is given and runs its commands under a
+// context carrying them, which newRegistryClient, newCluster and the like
+// read.
+// Tests give the model pkg/backend/mock's mocks, and another
+// implementation plugs in the same way; left nil, as for the headless
+// commands and serve, the clients of pkg/ talk to the configured registry,
+// cluster, repository and database.
+type backends struct {
+	registry backend.RegistryAPI // every registry, whatever the host
+	cluster  backend.ClusterAPI  // every cluster, whatever the kubeconfig
+	git      backend.GitProvider
+	store    backend.Store // used even without a database
+	runtime  backend.ContainerRuntime
+}
+
+type backendsKey struct{}
+
+// withBackends has what runs under ctx use b.
+func withBackends(ctx context.Context, b backends) context.Context {
+	return context.WithValue(ctx, backendsKey{}, b)
+}
+
+// backendsOf are the backends ctx carries, none for a context the model
+// didn't start.
+func backendsOf(ctx context.Context) backends {
+	b, _ := ctx.Value(backendsKey{}).(backends)
+	return b
+}
+
+// dockerCLI runs the docker CLI for local images, pulls and pushes.
+var dockerCLI backend.ContainerRuntime = docker.Client{Trace: traceDockerCommand}
+
+// containerRuntime is the local image store: the runtime ctx carries, or
+// the docker CLI.
+func containerRuntime(ctx context.Context) backend.ContainerRuntime {
+	if runtime := backendsOf(ctx).runtime; runtime != nil {
+		return runtime
+	}
+	return dockerCLI
+}
+
+// newCluster works on the active cluster.
+func newCluster(ctx context.Context) backend.ClusterAPI {
+	return newClusterFor(ctx, currentCluster())
+}
+
+// newClusterFor works on cluster c, or is the cluster backend ctx carries.
+// The kubeconfig is only read once it's used, so a missing or broken one is
+// the error of the first call: errKubeconfigNotFound or a
+// clusterConfigError.
+func newClusterFor(ctx context.Context, c cluster) backend.ClusterAPI {
+	if cluster := backendsOf(ctx).cluster; cluster != nil {
+		return cluster
+	}
+	return kube.Connect(func() (kubernetes.Interface, error) {
+		clientset, err := newKubernetesClientsetFor(c)
+		if err != nil && !errors.Is(err, errKubeconfigNotFound) && !errors.Is(err, errKubernetesDisabled) {
+			return nil, clusterConfigError{err}
+		}
+		return clientset, err
+	})
+}
+
+// clusterConfigError is a cluster's kubeconfig that no client could be made
+// from, as opposed to the cluster failing a request.
+type clusterConfigError struct{ err error }
+
+func (e clusterConfigError) Error() string { return e.err.Error() }
+func (e clusterConfigError) Unwrap() error { return e.err }
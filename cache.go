@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
+
+// The registry cache keeps manifests and config blobs keyed by digest, in
+// memory and under REGISTRY_CACHE_DIR (default: the user cache directory).
+// Set REGISTRY_CACHE=false to keep everything in memory only.
+var (
+	sharedRegistryCache     *registry.Cache
+	sharedRegistryCacheOnce sync.Once
+)
+
+func getRegistryCache() *registry.Cache {
+	sharedRegistryCacheOnce.Do(func() {
+		sharedRegistryCache = registry.NewCache(registryCacheDir())
+	})
+	return sharedRegistryCache
+}
+
+func registryCacheDir() string {
+	if os.Getenv("REGISTRY_CACHE") == "false" {
+		return ""
+	}
+	if dir := os.Getenv("REGISTRY_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	userCache, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(userCache, "local-container-registry")
+}
+
+func writeFileAtomic(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/backend"
+	"github.com/anthony-gilbert/local-container-registry/pkg/kube"
+)
+
+// Canary deploys run the new image in a separate <name>-canary deployment
+// next to the existing one before it is promoted. Its pods carry the
+// deployment's pod labels, so Services selecting them send the canary a
+// share of the traffic, plus canaryTrackLabel, which only the canary's own
+// selector includes so the two deployments never own each other's pods.
+const canaryTrackLabel = "local-container-registry/track"
+
+// canaryReplicas is the canary's initial size, CANARY_REPLICAS or 1.
+func canaryReplicas() int32 {
+	if n, err := strconv.Atoi(envOrDefault("CANARY_REPLICAS", "1")); err == nil && n > 0 {
+		return int32(n)
+	}
+	return 1
+}
+
+func canaryName(deploymentName string) string {
+	return deploymentName + "-canary"
+}
+
+// canaryState is what the canary dialog shows while the canary runs.
+type canaryState struct {
+	ready, desired int32
+	restarts       int32
+	problem        string // e.g. CrashLoopBackOff or ImagePullBackOff of a canary pod
+	err            error
+}
+
+type canaryStartedMsg struct {
+	err error
+}
+
+type canaryStatusMsg struct {
+	state canaryState
+}
+
+type canaryTickMsg struct{}
+
+type canaryDoneMsg struct {
+	promoted bool
+	err      error
+}
+
+// createCanary creates or replaces the canary of a deployment, running image
+// in its container called container (the first when empty) of replicas pods
+// that are otherwise configured like the deployment's.
+func createCanary(ctx context.Context, cluster backend.ClusterAPI, deploymentName, namespace, container, image string, env *containerEnv, replicas int32) error {
+	deployment, err := cluster.Deployment(ctx, namespace, deploymentName)
+	if err != nil {
+		return fmt.Errorf("error getting deployment %s: %v", deploymentName, err)
+	}
+	index, err := kube.ContainerIndex(deployment, container)
+	if err != nil {
+		return err
+	}
+
+	selector := map[string]string{canaryTrackLabel: "canary"}
+	if deployment.Spec.Selector != nil {
+		for key, value := range deployment.Spec.Selector.MatchLabels {
+			selector[key] = value
+		}
+	}
+	template := *deployment.Spec.Template.DeepCopy()
+	if template.Labels == nil {
+		template.Labels = map[string]string{}
+	}
+	template.Labels[canaryTrackLabel] = "canary"
+	template.Spec.Containers[index].Image = image
+	template.Spec.Containers[index].ImagePullPolicy = resolvePullPolicy(ctx, cluster, namespace, image, "")
+	if env != nil {
+		updatedEnv := editedEnv(template.Spec.Containers[index], *env)
+		template.Spec.Containers[index].Env, template.Spec.Containers[index].EnvFrom = updatedEnv.Env, updatedEnv.EnvFrom
+	}
+	stampPodTemplate(&template, deployAnnotations(ctx, image))
+
+	canary := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      canaryName(deploymentName),
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "local-container-registry",
+				canaryTrackLabel:               "canary",
+			},
+			Annotations: map[string]string{"local-container-registry/canary-of": deploymentName},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: selector},
+			Template: template,
+		},
+	}
+
+	_, err = cluster.CreateDeployment(ctx, canary)
+	if apierrors.IsAlreadyExists(err) {
+		// Left over from an earlier canary; the selector can't change, so
+		// replace it rather than update it
+		if err = deleteCanary(ctx, cluster, deploymentName, namespace); err == nil {
+			err = waitForCanaryDeletion(ctx, cluster, deploymentName, namespace)
+		}
+		if err == nil {
+			_, err = cluster.CreateDeployment(ctx, canary)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create canary %s: %v", canary.Name, err)
+	}
+	return nil
+}
+
+// deleteCanary removes the canary deployment and, in the foreground, its pods.
+func deleteCanary(ctx context.Context, cluster backend.ClusterAPI, deploymentName, namespace string) error {
+	err := cluster.DeleteDeployment(ctx, namespace, canaryName(deploymentName), metav1.DeletePropagationForeground)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete canary %s: %v", canaryName(deploymentName), err)
+	}
+	return nil
+}
+
+// canaryDeletionTimeout bounds waiting for a foreground delete of the
+// canary to finish, which waits for its pods to go.
+const canaryDeletionTimeout = time.Minute
+
+// waitForCanaryDeletion waits until the canary deployment is gone; until its
+// pods are, a foreground delete leaves it in place and creating it again
+// fails with AlreadyExists.
+func waitForCanaryDeletion(ctx context.Context, cluster backend.ClusterAPI, deploymentName, namespace string) error {
+	ctx, cancel := context.WithTimeout(ctx, canaryDeletionTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		_, err := cluster.Deployment(ctx, namespace, canaryName(deploymentName))
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("canary %s is still being deleted: %v", canaryName(deploymentName), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// getCanaryState reports how the canary's pods are doing.
+func getCanaryState(ctx context.Context, deploymentName, namespace string) canaryState {
+	cluster := newCluster(ctx)
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	canary, err := cluster.Deployment(ctx, namespace, canaryName(deploymentName))
+	if err != nil {
+		return canaryState{err: err}
+	}
+	state := canaryState{ready: canary.Status.ReadyReplicas, desired: kube.DesiredReplicas(*canary)}
+
+	pods, err := cluster.ListPods(ctx, namespace, metav1.FormatLabelSelector(canary.Spec.Selector))
+	if err != nil {
+		state.err = err
+		return state
+	}
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			state.restarts += status.RestartCount
+			if waiting := status.State.Waiting; waiting != nil && waiting.Reason != "ContainerCreating" {
+				state.problem = waiting.Reason
+			}
+		}
+		if pod.Status.Phase == corev1.PodPending && state.problem == "" {
+			if reason := schedulingReason(pod); reason != "not scheduled yet" {
+				state.problem = reason
+			}
+		}
+	}
+	return state
+}
+
+func canaryTick() tea.Cmd {
+	return tea.Tick(3*time.Second, func(time.Time) tea.Msg { return canaryTickMsg{} })
+}
+
+// startCanary creates the canary of the selected deployment and switches the
+// modal to the canary step, where it is watched until promoted or rolled back.
+func (m *model) startCanary(deploymentName, namespace string) tea.Cmd {
+	m.modalStep = 3
+	m.canaryDeployment, m.canaryNamespace = deploymentName, namespace
+	m.canaryState = canaryState{}
+	m.canaryStarted = time.Now()
+
+	historyCtx, image, container, env, replicas := m.ctx, m.selectedImage, m.deployContainer, m.deployEnv, m.canaryReplicas
+	recordSessionStep(sessionStep{Action: sessionCanary, Image: image, Deployment: deploymentName, Namespace: namespace, Container: container, Env: env, Replicas: replicas})
+	return m.startOperation("canary", deploymentName, func(ctx context.Context) tea.Msg {
+		kubeCtx, cancel := withBackendTimeout(ctx, backendKubernetes)
+		defer cancel()
+		err := createCanary(kubeCtx, newCluster(ctx), deploymentName, namespace, container, clusterImageName(kubeCtx, image), env, replicas)
+		recordActionResult(withSpanOf(historyCtx, ctx), "canary", namespace+"/"+deploymentName, err, fmt.Sprintf("image %s; %d replicas", image, replicas))
+		return canaryStartedMsg{err: err}
+	})
+}
+
+// finishCanary removes the canary and, when promoting, deploys its image to
+// the deployment the usual way (Helm upgrade or GitOps export included).
+func (m *model) finishCanary(promote bool) tea.Cmd {
+	deploymentName, namespace := m.canaryDeployment, m.canaryNamespace
+	step := sessionStep{Action: sessionRollbackCanary, Image: m.selectedImage, Deployment: deploymentName, Namespace: namespace, Container: m.deployContainer, Env: m.deployEnv}
+	if promote {
+		step.Action = sessionPromoteCanary
+	}
+	recordSessionStep(step)
+	m.showModal = false
+	m.modalStep = 0
+
+	historyCtx := m.ctx
+	action := "rollback canary"
+	if promote {
+		action = "promote canary"
+	}
+	remove := m.startOperation(action, deploymentName, func(ctx context.Context) tea.Msg {
+		kubeCtx, cancel := withBackendTimeout(ctx, backendKubernetes)
+		defer cancel()
+		err := deleteCanary(kubeCtx, newCluster(ctx), deploymentName, namespace)
+		recordActionResult(withSpanOf(historyCtx, ctx), strings.ReplaceAll(action, " ", "-"), namespace+"/"+deploymentName, err, "")
+		return canaryDoneMsg{promoted: promote, err: err}
+	})
+	if !promote {
+		return remove
+	}
+	return tea.Batch(m.deployImageToPod(m.selectedImage, deploymentName, namespace, m.deployContainer, m.deployEnv), remove)
+}
+
+// updateCanary handles keys while a canary runs. The dialog can't be closed
+// without deciding, so a canary isn't forgotten in the cluster.
+func (m model) updateCanary(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "1":
+		cmd := m.finishCanary(true)
+		return m, cmd
+	case "2":
+		cmd := m.finishCanary(false)
+		return m, cmd
+	}
+	return m, nil
+}
+
+func (m model) loadCanaryState() tea.Cmd {
+	ctx, deploymentName, namespace := m.ctx, m.canaryDeployment, m.canaryNamespace
+	return func() tea.Msg {
+		return canaryStatusMsg{state: getCanaryState(ctx, deploymentName, namespace)}
+	}
+}
+
+func (m model) renderCanary() string {
+	state := m.canaryState
+	var health string
+	switch {
+	case state.err != nil:
+		health = fmt.Sprintf("⚠️  %v", state.err)
+	case state.desired == 0:
+		health = "Creating canary..."
+	case state.problem != "":
+		health = fmt.Sprintf("❌ %d/%d ready · %s · roll back?", state.ready, state.desired, truncateString(state.problem, 50))
+	case state.ready == state.desired:
+		health = fmt.Sprintf("✅ %d/%d ready · %d restarts", state.ready, state.desired, state.restarts)
+	default:
+		health = fmt.Sprintf("⏳ %d/%d ready · %d restarts", state.ready, state.desired, state.restarts)
+	}
+
+	content := fmt.Sprintf(`Canary Deploy
+
+Image: %s
+Canary: %s (%d replicas next to %s)
+Running for %s
+
+%s
+
+[1] Promote: deploy the image to %s and remove the canary
+[2] Roll back: remove the canary, leaving %s unchanged
+
+Press 1 or 2`, m.selectedImage, canaryName(m.canaryDeployment), m.canaryReplicas, m.canaryDeployment,
+		time.Since(m.canaryStarted).Truncate(time.Second), health, m.canaryDeployment, m.canaryDeployment)
+
+	return modalStyle.Width(72).Height(0).Render(content)
+}
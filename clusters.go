@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/homedir"
+)
+
+var errKubeconfigNotFound = errors.New("kubeconfig not found")
+
+var errKubernetesDisabled = errors.New("Kubernetes is disabled; set KUBERNETES_ENABLED=true to use a cluster")
+
+// kubernetesEnabled reports whether the cluster is used at all. Without it
+// the Kubernetes, Nodes, Config and Workloads tabs are gone and nothing
+// loads a kubeconfig. KUBERNETES_ENABLED=true or false
+// decides; otherwise it is on when KUBE_CLUSTERS is set or a kubeconfig
+// exists, which is checked without contacting any cluster.
+var kubernetesEnabled = sync.OnceValue(func() bool {
+	switch strings.ToLower(os.Getenv("KUBERNETES_ENABLED")) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if os.Getenv("KUBE_CLUSTERS") != "" {
+		return true
+	}
+	_, err := os.Stat(defaultKubeconfig())
+	return err == nil
+})
+
+// cluster is a Kubernetes cluster the TUI can switch to: a context of the
+// default kubeconfig, or a kubeconfig file of its own. The zero cluster is
+// the kubeconfig's current context, where KUBERNETES_CONTROL_PLANE applies.
+type cluster struct {
+	name       string
+	kubeconfig string // empty for KUBECONFIG or ~/.kube/config
+	context    string // empty for the kubeconfig's current context
+}
+
+// activeCluster is the cluster every Kubernetes call goes to.
+var activeCluster struct {
+	sync.RWMutex
+	cluster cluster
+}
+
+func currentCluster() cluster {
+	activeCluster.RLock()
+	defer activeCluster.RUnlock()
+	return activeCluster.cluster
+}
+
+func useCluster(c cluster) {
+	activeCluster.Lock()
+	defer activeCluster.Unlock()
+	activeCluster.cluster = c
+}
+
+// defaultKubeconfig is KUBECONFIG, falling back to ~/.kube/config. KUBECONFIG
+// may list several files (separated by ; on Windows, : elsewhere); the first
+// that exists is the one named in messages.
+func defaultKubeconfig() string {
+	if list := filepath.SplitList(os.Getenv("KUBECONFIG")); len(list) > 0 {
+		for _, kubeconfig := range list {
+			if _, err := os.Stat(kubeconfig); err == nil {
+				return kubeconfig
+			}
+		}
+		return list[0]
+	}
+	if home := homedir.HomeDir(); home != "" {
+		return filepath.Join(home, ".kube", "config")
+	}
+	return ""
+}
+
+func (c cluster) kubeconfigPath() string {
+	if c.kubeconfig != "" {
+		return c.kubeconfig
+	}
+	return defaultKubeconfig()
+}
+
+// clientConfig loads the cluster's kubeconfig, or for the default one every
+// file KUBECONFIG lists, merged the way kubectl does.
+func (c cluster) clientConfig() clientcmd.ClientConfig {
+	rules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: c.kubeconfig}
+	if c.kubeconfig == "" {
+		rules = clientcmd.NewDefaultClientConfigLoadingRules()
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules,
+		&clientcmd.ConfigOverrides{CurrentContext: c.context})
+}
+
+// displayName is the cluster's name, or for the zero cluster the
+// kubeconfig's current context.
+func (c cluster) displayName() string {
+	if c.name != "" {
+		return c.name
+	}
+	if config, err := c.clientConfig().RawConfig(); err == nil && config.CurrentContext != "" {
+		return config.CurrentContext
+	}
+	return "default"
+}
+
+// configuredClusters is KUBE_CLUSTERS, a comma-separated list of kube
+// contexts or name=/path/to/kubeconfig entries. Without it every context of
+// the default kubeconfig is offered.
+func configuredClusters() []cluster {
+	var clusters []cluster
+	if list := os.Getenv("KUBE_CLUSTERS"); list != "" {
+		for _, entry := range strings.Split(list, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			if name, path, ok := strings.Cut(entry, "="); ok {
+				clusters = append(clusters, cluster{name: name, kubeconfig: path})
+			} else {
+				clusters = append(clusters, cluster{name: entry, context: entry})
+			}
+		}
+		return clusters
+	}
+
+	config, err := cluster{}.clientConfig().RawConfig()
+	if err != nil {
+		return nil
+	}
+	for name := range config.Contexts {
+		clusters = append(clusters, cluster{name: name, context: name})
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].name < clusters[j].name })
+	return clusters
+}
+
+// kubeRESTConfigFor builds the client config for a cluster.
+func kubeRESTConfigFor(c cluster) (*rest.Config, error) {
+	if _, err := os.Stat(c.kubeconfigPath()); os.IsNotExist(err) {
+		return nil, errKubeconfigNotFound
+	}
+	config, err := c.clientConfig().ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error building config: %v", err)
+	}
+
+	// The override is for the default cluster; clusters picked in the
+	// switcher use the server in their kubeconfig
+	if host := kubernetesControlPlaneHost(); host != "" && c == (cluster{}) {
+		config.Host = host
+	}
+
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper { return tracingTransport{base: rt} })
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return retryTransport{backend: backendKubernetes, base: rt}
+	})
+	return config, nil
+}
+
+func newKubernetesClientsetFor(c cluster) (*kubernetes.Clientset, error) {
+	if !kubernetesEnabled() {
+		return nil, errKubernetesDisabled
+	}
+	config, err := kubeRESTConfigFor(c)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating client: %v", err)
+	}
+	return clientset, nil
+}
+
+// getClusterPods lists the pods of one cluster in all namespaces, each
+// labelled with the cluster's name.
+func getClusterPods(ctx context.Context, c cluster) ([]TableData, error) {
+	cluster := newClusterFor(ctx, c)
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	pods, err := cluster.Pods(ctx, metav1.NamespaceAll)
+	if err != nil {
+		return nil, err
+	}
+	var data []TableData
+	for _, pod := range pods {
+		row := podRow(pod)
+		row.Cluster = c.name
+		data = append(data, row)
+	}
+	return data, nil
+}
+
+// getAllClusterPods lists the pods of every configured cluster side by side.
+// An unreachable cluster gets an error row rather than hiding the others.
+func getAllClusterPods(ctx context.Context, clusters []cluster) []TableData {
+	results := make([][]TableData, len(clusters))
+	var wg sync.WaitGroup
+	for i, c := range clusters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := getClusterPods(ctx, c)
+			if err != nil {
+				data = []TableData{{Cluster: c.name, PodName: fmt.Sprintf("Unreachable: %v", err), Namespace: "N/A", Status: "Error", Restarts: "N/A", Age: "N/A"}}
+			}
+			results[i] = data
+		}()
+	}
+	wg.Wait()
+
+	var data []TableData
+	for _, result := range results {
+		data = append(data, result...)
+	}
+	return data
+}
+
+type kubePodsMsg struct {
+	data        []TableData
+	allClusters bool
+	stale       *staleData // set when data is the last-known-good
+}
+
+func (m model) loadKubePods() tea.Cmd {
+	if !kubernetesEnabled() {
+		return nil
+	}
+	ctx, allClusters, clusters := m.ctx, m.allClusters, m.clusters
+	return func() tea.Msg {
+		if allClusters {
+			return kubePodsMsg{data: getAllClusterPods(ctx, clusters), allClusters: true}
+		}
+		data, err := getKubernetesPodsInfo(ctx)
+		if err == nil {
+			saveLastKnownGood(ctx, 2, data)
+		} else if rows, stale := lastKnownGood(ctx, 2, err); stale != nil {
+			return kubePodsMsg{data: rows, stale: stale}
+		}
+		return kubePodsMsg{data: data}
+	}
+}
+
+// switchCluster points every Kubernetes call at c and reloads what the
+// previous cluster's data was shown for.
+func (m *model) switchCluster(c cluster) tea.Cmd {
+	recordSessionStep(sessionStep{Action: sessionCluster, Cluster: c.displayName()})
+	useCluster(c)
+	m.clusterName = c.displayName()
+	m.statusMessage = fmt.Sprintf("☸️  Switched to cluster %s", m.clusterName)
+
+	m.kubesData, m.stale[2] = nil, nil
+	m.nodesData, m.nodesErr = nil, nil
+	m.configEntries, m.configErr = nil, nil
+	m.workloadsData, m.workloadsErr = nil, nil
+	m.permissions = nil
+	m.switchTab(m.activeTab)
+	return tea.Batch(m.loadKubePods(), m.loadPermissions(), m.loadTabData())
+}
+
+func (m model) updateClusterPicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "up", "k":
+		if m.clusterCursor > 0 {
+			m.clusterCursor--
+		}
+	case "down", "j":
+		if m.clusterCursor < len(m.clusters)-1 {
+			m.clusterCursor++
+		}
+	case "enter":
+		m.showClusterPicker = false
+		if m.clusterCursor < len(m.clusters) {
+			cmd := m.switchCluster(m.clusters[m.clusterCursor])
+			return m, cmd
+		}
+	case "esc", "q":
+		m.showClusterPicker = false
+	}
+	return m, nil
+}
+
+func (m model) renderClusterPicker() string {
+	var b strings.Builder
+	b.WriteString("Switch Cluster\n\n")
+	if len(m.clusters) == 0 {
+		b.WriteString("No clusters found; list them in KUBE_CLUSTERS\n")
+	}
+	for i, c := range m.clusters {
+		line := c.name
+		if c.kubeconfig != "" {
+			line += "  " + c.kubeconfig
+		}
+		if c.name == m.clusterName {
+			line += "  (active)"
+		}
+		prefix := "  "
+		if i == m.clusterCursor {
+			prefix = "→ "
+		}
+		b.WriteString(prefix + line + "\n")
+	}
+	b.WriteString("\nUse ↑/↓ to navigate, Enter to switch, ESC to cancel")
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, modalStyle.Width(72).Height(0).Render(b.String()), lipgloss.WithWhitespaceChars("░"))
+}
+
+// renderKubernetesStatus names the cluster the Kubernetes tab shows.
+func (m model) renderKubernetesStatus() string {
+	if m.kubesData == nil {
+		return fmt.Sprintf("Loading pods of cluster %s...", m.clusterName)
+	}
+	if m.allClusters {
+		names := make([]string, len(m.clusters))
+		for i, c := range m.clusters {
+			names[i] = c.name
+		}
+		return fmt.Sprintf("☸️  All clusters (%s) · A to show only %s", strings.Join(names, ", "), m.clusterName)
+	}
+	return fmt.Sprintf("☸️  Cluster %s · K to switch cluster, A to show pods of all clusters, x to diagnose a crashing pod", m.clusterName)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"cmp"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+)
+
+// Which columns a tab shows, in what order and how wide, is configured per
+// tab with COLUMNS_<TAB>: a comma-separated list of column titles, each
+// optionally followed by :width, e.g.
+//
+//	COLUMNS_KUBERNETES=Pod Name,Status,Restarts,Age
+//	COLUMNS_DOCKER=Repository,Tag:25,Digest,Size,In Use
+//
+// Titles are the ones in the table header, matched case-insensitively.
+// Without the variable a tab shows its default columns.
+
+// hiddenColumns are columns a tab has but only shows when COLUMNS_<TAB>
+// names them.
+var hiddenColumns = map[string][]string{
+	"Git":       {"Committer"},
+	"Docker":    {"Digest"},
+	"Workloads": {"Namespace"},
+}
+
+type columnChoice struct {
+	title string
+	width int // 0 keeps the default width
+}
+
+// columnLayout is the layout COLUMNS_<TAB> configures for a tab, nil when
+// it isn't set.
+func columnLayout(tab string) []columnChoice {
+	value := os.Getenv("COLUMNS_" + strings.ToUpper(tab))
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	var layout []columnChoice
+	for _, entry := range strings.Split(value, ",") {
+		title, width, hasWidth := strings.Cut(entry, ":")
+		choice := columnChoice{title: strings.TrimSpace(title)}
+		if hasWidth {
+			if n, err := strconv.Atoi(strings.TrimSpace(width)); err == nil && n > 0 {
+				choice.width = n
+			}
+		}
+		if choice.title != "" {
+			layout = append(layout, choice)
+		}
+	}
+	return layout
+}
+
+// layoutColumns picks, orders and sizes a tab's columns as configured and
+// cuts every cell to its column's width. Columns a tab doesn't have right
+// now, like Scan on a registry that doesn't scan, are skipped.
+func layoutColumns(tab string, columns []table.Column, rows []table.Row) ([]table.Column, []table.Row) {
+	var picked []int
+	var widths []int
+	if layout := columnLayout(tab); layout != nil {
+		for _, choice := range layout {
+			index := -1
+			for i, column := range columns {
+				if strings.EqualFold(column.Title, choice.title) {
+					index = i
+					break
+				}
+			}
+			if index < 0 {
+				continue
+			}
+			picked = append(picked, index)
+			widths = append(widths, cmp.Or(choice.width, columns[index].Width))
+		}
+	}
+	// A layout naming no column of the tab would leave it blank
+	if len(picked) == 0 {
+		for i, column := range columns {
+			if !isHiddenColumn(tab, column.Title) {
+				picked = append(picked, i)
+				widths = append(widths, column.Width)
+			}
+		}
+	}
+
+	laidOut := make([]table.Column, len(picked))
+	for i, index := range picked {
+		laidOut[i] = table.Column{Title: columns[index].Title, Width: widths[i]}
+	}
+	laidOutRows := make([]table.Row, len(rows))
+	for r, row := range rows {
+		laidOutRows[r] = make(table.Row, len(picked))
+		for i, index := range picked {
+			if index < len(row) {
+				laidOutRows[r][i] = fitCell(row[index], widths[i])
+			}
+		}
+	}
+	return laidOut, laidOutRows
+}
+
+func isHiddenColumn(tab, title string) bool {
+	// Without a cluster no image is in use
+	if tab == "Docker" && title == "In Use" && !kubernetesEnabled() {
+		return true
+	}
+	return slices.Contains(hiddenColumns[tab], title)
+}
+
+// fitCell cuts a cell to width like truncateString, without splitting a
+// multi-byte character, and without an ellipsis when the column is too
+// narrow for one.
+func fitCell(value string, width int) string {
+	runes := []rune(value)
+	if len(runes) <= width {
+		return value
+	}
+	if width > 3 {
+		return string(runes[:width-3]) + "..."
+	}
+	return string(runes[:width])
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runCommand dispatches headless subcommands. It returns false when args do
+// not name a known command so main falls through to the TUI.
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	var err error
+	switch args[0] {
+	case "migrate":
+		err = runMigrate(args[1:])
+	case "serve":
+		err = runServe(args[1:])
+	case "prune":
+		err = runPrune(args[1:])
+	case "sync":
+		err = runSync(args[1:])
+	case "releases":
+		err = runReleases(args[1:])
+	case "pull-secret":
+		err = runPullSecret(args[1:])
+	case "restart":
+		err = runRestart(args[1:])
+	case "registry-info":
+		err = runRegistryInfo(args[1:])
+	case "compare":
+		err = runCompare(args[1:])
+	case "retag":
+		err = runRetag(args[1:])
+	case "promote":
+		err = runPromote(args[1:])
+	case "jobs":
+		err = runJobs(args[1:])
+	case "auto-deploy":
+		err = runAutoDeploy(args[1:])
+	case "image-tag":
+		err = runImageTag(args[1:])
+	case "snapshot":
+		err = runEnvironmentSnapshot(args[1:])
+	case "doctor":
+		err = runDoctor(args[1:])
+	case "install":
+		err = runInstall(args[1:])
+	case "uninstall":
+		err = runUninstall(args[1:])
+	case "login":
+		err = runLogin(args[1:])
+	case "logout":
+		err = runLogout(args[1:])
+	case "replay":
+		err = runReplay(args[1:])
+	case "audit-export":
+		err = runAuditExport(args[1:])
+	case "user":
+		err = runUser(args[1:])
+	case "bug-report":
+		err = runBugReport(args[1:])
+	case "completion":
+		err = runCompletion(args[1:])
+	case "__complete":
+		err = runComplete(args[1:])
+	case "help", "-h", "--help":
+		printUsage()
+	default:
+		return false
+	}
+
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		exit(1)
+	}
+	return true
+}
+
+// signalContext is cancelled on Ctrl+C or SIGTERM so long-running commands
+// can stop between steps instead of being killed mid-request.
+func signalContext() (context.Context, context.CancelFunc) {
+	return context.WithCancel(rootCtx)
+}
+
+func printUsage() {
+	fmt.Println(`Usage: local-container-registry [command] [flags]
+
+Without a command the interactive TUI is started, or a plain-text snapshot
+of its tabs when stdout isn't a terminal:
+  --tab <tab>  Open on this tab; with --plain, comma-separated tabs or "all"
+  --plain      Print the tabs as text even on a terminal
+  --watch, -w  Keep printing rows as they change, every --interval (5s)
+  --record <file> Record the actions taken to a session file for replay
+
+Commands talking to registries, and the TUI, also take:
+  --registry-max-requests <n>         Registry requests in flight at once (0, no cap)
+  --registry-timeout <duration>       Timeout of each registry request (10s)
+  --registry-keepalive-conns <n>      Idle connections kept open per registry (16)
+  --registry-idle-timeout <duration>  How long idle connections are kept (90s)
+
+Commands:
+  migrate   Copy every image from one registry prefix to another
+  serve     Run in daemon mode with the REST API, /healthz and /readyz probes and the GitHub webhook; --web adds a web dashboard
+  prune     Delete registry tags according to the retention policy
+  sync      Mirror repositories to a remote registry, resuming interrupted uploads
+  releases  List Helm releases and the image each one runs
+  pull-secret Create or update the registry pull secret in a namespace
+  restart   Roll out new pods of deployments without changing their image
+  registry-info Show the registry's product and, for Harbor, its projects, quotas and retention
+  compare   Diff the layers, size, env, entrypoint and labels of two image tags
+  retag     Give a registry image another tag without pulling or pushing it
+  promote   Copy an image to the next environment's registry and roll its deployment to it
+  jobs      List the scheduled jobs with their last and next run; "jobs run <job>" runs one now
+  auto-deploy List, add, enable, disable or remove the rules serve deploys new tags by
+  image-tag Print the tag IMAGE_TAG_TEMPLATE gives a build of the checked-out commit
+  snapshot  Archive registry digests, deployment specs and pod statuses; "snapshot diff a b" compares two
+  doctor    Check registry, cluster, database, GitHub and Docker, with hints for failures
+  install   Install the daemon as a systemd/launchd service (--service)
+  uninstall Remove the daemon service (--service)
+  login     Store registry credentials, or the GitHub token with --github, in the OS keyring
+  logout    Remove them from the keyring
+  replay    Take the actions of a session recorded with --record again, without the TUI
+  audit-export Write the recorded actions as JSON lines or CEF to a file, stdout or a SIEM endpoint
+  user      Manage REST API users, roles and tokens: add, passwd, role, delete, list, token, revoke
+  bug-report Bundle crash reports, the doctor's checks and the redacted configuration to attach to an issue
+  completion Print the bash, zsh or fish completion script
+  help      Show this help
+
+Run "local-container-registry <command> -h" for command flags.`)
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
+
+// The completion scripts are thin: they hand the words typed so far to the
+// hidden __complete command, which prints the candidates one per line. That
+// keeps the knowledge of commands and flags here, next to runCommand, and
+// lets candidates like repository names come live from the backends.
+
+// completionTimeout bounds the backend calls of a completion, so a registry
+// or cluster that is down doesn't hang the shell.
+const completionTimeout = 3 * time.Second
+
+// completer lists the values a flag or positional argument can take, given
+// what has been typed so far.
+type completer func(ctx context.Context, typed completionWords, current string) []string
+
+type completionFlag struct {
+	name   string
+	value  bool      // whether the flag takes a value
+	list   bool      // whether the value is a comma-separated list
+	values completer // nil when the values can't be listed
+}
+
+type commandCompletion struct {
+	flags []completionFlag
+	args  completer // the next positional argument, nil when none can be listed
+}
+
+// completionWords is what has been typed after the command.
+type completionWords struct {
+	flags map[string]string
+	args  []string
+}
+
+// registryCompletionFlags are the flags addRegistryFlags adds.
+var registryCompletionFlags = []completionFlag{
+	{name: "registry-max-requests", value: true},
+	{name: "registry-timeout", value: true},
+	{name: "registry-keepalive-conns", value: true},
+	{name: "registry-idle-timeout", value: true},
+}
+
+// commandCompletions describes the commands runCommand dispatches; "" is
+// the TUI started without one.
+var commandCompletions = map[string]commandCompletion{
+	"": {flags: append([]completionFlag{
+		{name: "tab", value: true, list: true, values: completeTabs},
+		{name: "plain"},
+		{name: "watch"},
+		{name: "w"},
+		{name: "interval", value: true},
+		{name: "record", value: true},
+	}, registryCompletionFlags...)},
+	"migrate": {flags: append([]completionFlag{
+		{name: "from", value: true},
+		{name: "to", value: true},
+		{name: "repos", value: true, list: true, values: completeRepositories},
+		{name: "update-workloads"},
+		{name: "cluster-from", value: true},
+		{name: "cluster-to", value: true},
+		{name: "dry-run"},
+		{name: "parallel", value: true},
+	}, registryCompletionFlags...)},
+	"serve": {flags: append([]completionFlag{
+		{name: "listen", value: true},
+		{name: "require", value: true, list: true, values: completeReadyChecks},
+		{name: "web"},
+		{name: "pprof", value: true},
+	}, registryCompletionFlags...)},
+	"prune": {flags: append([]completionFlag{
+		{name: "keep-last", value: true},
+		{name: "older-than", value: true},
+		{name: "protect", value: true},
+		{name: "repos", value: true, list: true, values: completeRepositories},
+		{name: "dry-run"},
+		{name: "every", value: true},
+		{name: "untagged"},
+	}, registryCompletionFlags...)},
+	"sync": {flags: append([]completionFlag{
+		{name: "from", value: true},
+		{name: "to", value: true},
+		{name: "repos", value: true, list: true, values: completeRepositories},
+		{name: "every", value: true},
+		{name: "retries", value: true},
+		{name: "dry-run"},
+		{name: "parallel", value: true},
+	}, registryCompletionFlags...)},
+	"releases": {flags: []completionFlag{
+		{name: "namespace", value: true, values: completeNamespaces},
+	}},
+	"pull-secret": {flags: []completionFlag{
+		{name: "namespace", value: true, list: true, values: completeNamespaces},
+		{name: "server", value: true},
+	}},
+	"restart": {
+		flags: []completionFlag{{name: "namespace", value: true, values: completeNamespaces}},
+		args:  completeDeployments,
+	},
+	"registry-info": {flags: append([]completionFlag{
+		{name: "registry", value: true},
+	}, registryCompletionFlags...)},
+	"compare": {flags: registryCompletionFlags, args: completeArgs(completeImageReferences, completeImageReferences)},
+	"retag":   {flags: registryCompletionFlags, args: completeArgs(completeImageReferences)},
+	"promote": {
+		flags: append([]completionFlag{
+			{name: "from", value: true, values: completeEnvironments},
+			{name: "to", value: true, values: completeEnvironments},
+			{name: "deployment", value: true},
+			{name: "container", value: true},
+		}, registryCompletionFlags...),
+		args: completeArgs(completeImageReferences),
+	},
+	"jobs": {flags: registryCompletionFlags, args: completeJobsArgs},
+	"image-tag": {flags: []completionFlag{
+		{name: "branch", value: true},
+		{name: "sha", value: true},
+	}},
+	"auto-deploy": {
+		flags: []completionFlag{
+			{name: "repo", value: true, values: completeRepositories},
+			{name: "tags", value: true},
+			{name: "namespace", value: true, values: completeNamespaces},
+			{name: "deployment", value: true},
+			{name: "container", value: true},
+		},
+		args: completeArgs(completeWords("list", "add", "enable", "disable", "remove")),
+	},
+	"snapshot": {
+		flags: append([]completionFlag{
+			{name: "out", value: true},
+			{name: "registry", value: true},
+			{name: "repos", value: true, list: true, values: completeRepositories},
+			{name: "namespace", value: true, values: completeNamespaces},
+		}, registryCompletionFlags...),
+		args: completeArgs(completeWords("diff")),
+	},
+	"doctor": {flags: []completionFlag{
+		{name: "check", value: true, list: true, values: completeDoctorChecks},
+		{name: "json"},
+	}},
+	"install": {flags: []completionFlag{
+		{name: "service"},
+		{name: "system"},
+		{name: "env-file", value: true},
+		{name: "listen", value: true},
+		{name: "dry-run"},
+	}},
+	"uninstall": {flags: []completionFlag{
+		{name: "service"},
+		{name: "system"},
+	}},
+	"login": {
+		flags: []completionFlag{
+			{name: "github"},
+			{name: "username", value: true},
+		},
+		args: completeArgs(completeRegistryHosts),
+	},
+	"logout": {flags: []completionFlag{{name: "github"}}, args: completeArgs(completeRegistryHosts)},
+	"replay": {flags: append([]completionFlag{
+		{name: "dry-run"},
+		{name: "keep-going"},
+		{name: "realtime"},
+	}, registryCompletionFlags...)},
+	"audit-export": {flags: []completionFlag{
+		{name: "format", value: true, values: completeWords(auditFormatJSON, auditFormatCEF)},
+		{name: "since", value: true},
+		{name: "until", value: true},
+		{name: "out", value: true},
+		{name: "url", value: true},
+	}},
+	"user": {
+		flags: []completionFlag{
+			{name: "email", value: true},
+			{name: "role", value: true, values: completeWords(roles...)},
+			{name: "name", value: true},
+			{name: "expires", value: true},
+		},
+		args: completeUserArgs,
+	},
+	"bug-report": {flags: []completionFlag{
+		{name: "out", value: true},
+		{name: "no-checks"},
+	}},
+	"completion": {args: completeArgs(completeWords("bash", "zsh", "fish"))},
+	"help":       {},
+}
+
+// runComplete prints the candidates for the last of args, the word being
+// completed, one per line.
+func runComplete(args []string) error {
+	ctx, stop := signalContext()
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, completionTimeout)
+	defer cancel()
+
+	for _, candidate := range completions(ctx, args) {
+		fmt.Println(candidate)
+	}
+	return nil
+}
+
+// completions lists what can follow words, of which the last is the word
+// being completed and may be empty.
+func completions(ctx context.Context, words []string) []string {
+	if len(words) == 0 {
+		return nil
+	}
+	current := words[len(words)-1]
+	typed := words[:len(words)-1]
+
+	command := ""
+	if len(typed) > 0 {
+		if _, ok := commandCompletions[typed[0]]; ok && !strings.HasPrefix(typed[0], "-") {
+			command, typed = typed[0], typed[1:]
+		}
+	}
+	completion := commandCompletions[command]
+
+	given := completionWords{flags: make(map[string]string)}
+	var pending *completionFlag // a flag waiting for its value
+	for _, word := range typed {
+		if pending != nil {
+			given.flags[pending.name] = word
+			pending = nil
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(word, "-"), "=")
+		if !strings.HasPrefix(word, "-") {
+			given.args = append(given.args, word)
+			continue
+		}
+		option := findCompletionFlag(completion.flags, name)
+		switch {
+		case option == nil:
+		case hasValue:
+			given.flags[name] = value
+		case option.value:
+			pending = option
+		default:
+			given.flags[name] = "true"
+		}
+	}
+
+	var prefix string
+	var candidates []string
+	switch {
+	case pending != nil:
+		prefix, candidates = flagValueCompletions(ctx, *pending, given, current)
+	case strings.HasPrefix(current, "-") && strings.Contains(current, "="):
+		name, value, _ := strings.Cut(current, "=")
+		if option := findCompletionFlag(completion.flags, strings.TrimLeft(name, "-")); option != nil {
+			prefix, candidates = flagValueCompletions(ctx, *option, given, value)
+			prefix = name + "=" + prefix
+		}
+	case strings.HasPrefix(current, "-"):
+		for _, option := range completion.flags {
+			if len(option.name) == 1 {
+				candidates = append(candidates, "-"+option.name)
+			} else {
+				candidates = append(candidates, "--"+option.name)
+			}
+		}
+	case command == "" && len(typed) == 0:
+		// runCommand only looks for a command in the first word
+		for name := range commandCompletions {
+			if name != "" {
+				candidates = append(candidates, name)
+			}
+		}
+		slices.Sort(candidates)
+	case completion.args != nil:
+		candidates = completion.args(ctx, given, current)
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		if candidate = prefix + candidate; strings.HasPrefix(candidate, current) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+func findCompletionFlag(flags []completionFlag, name string) *completionFlag {
+	for i := range flags {
+		if flags[i].name == name {
+			return &flags[i]
+		}
+	}
+	return nil
+}
+
+// flagValueCompletions lists the values of flag that can complete current.
+// Of a list only the last element is completed; the ones before it are
+// returned as the prefix every candidate keeps.
+func flagValueCompletions(ctx context.Context, option completionFlag, given completionWords, current string) (prefix string, candidates []string) {
+	if option.values == nil {
+		return "", nil
+	}
+	if option.list {
+		if separator := strings.LastIndex(current, ","); separator >= 0 {
+			prefix, current = current[:separator+1], current[separator+1:]
+		}
+	}
+	for _, value := range option.values(ctx, given, current) {
+		if !option.list || !slices.Contains(strings.Split(prefix, ","), value) {
+			candidates = append(candidates, value)
+		}
+	}
+	return prefix, candidates
+}
+
+// completeArgs completes the positional arguments in order, one completer
+// per argument.
+func completeArgs(completers ...completer) completer {
+	return func(ctx context.Context, typed completionWords, current string) []string {
+		if len(typed.args) >= len(completers) {
+			return nil
+		}
+		return completers[len(typed.args)](ctx, typed, current)
+	}
+}
+
+func completeWords(words ...string) completer {
+	return func(context.Context, completionWords, string) []string {
+		return words
+	}
+}
+
+func completeTabs(context.Context, completionWords, string) []string {
+	var names []string
+	for i, name := range tabNames {
+		if tabAvailable(i) {
+			names = append(names, strings.ToLower(name))
+		}
+	}
+	if kubernetesEnabled() {
+		names = append(names, "pods")
+	}
+	return append(names, "images", "all")
+}
+
+func completeDoctorChecks(context.Context, completionWords, string) []string {
+	var names []string
+	for _, check := range doctorChecks() {
+		names = append(names, check.name)
+	}
+	return names
+}
+
+func completeReadyChecks(context.Context, completionWords, string) []string {
+	var names []string
+	for _, check := range newHealthChecks(nil) {
+		names = append(names, check.name)
+	}
+	return names
+}
+
+func completeUserArgs(_ context.Context, typed completionWords, _ string) []string {
+	switch {
+	case len(typed.args) == 0:
+		return []string{"add", "passwd", "role", "delete", "list", "token", "revoke"}
+	case len(typed.args) == 2 && typed.args[0] == "role":
+		return roles
+	}
+	return nil
+}
+
+func completeJobsArgs(_ context.Context, typed completionWords, _ string) []string {
+	switch len(typed.args) {
+	case 0:
+		return []string{"run"}
+	case 1:
+		return jobNames
+	}
+	return nil
+}
+
+func completeEnvironments(context.Context, completionWords, string) []string {
+	environments, _ := configuredEnvironments()
+	var names []string
+	for _, env := range environments {
+		names = append(names, env.name)
+	}
+	return names
+}
+
+func completeRegistryHosts(context.Context, completionWords, string) []string {
+	var hosts []string
+	for _, endpoint := range configuredRegistries() {
+		hosts = append(hosts, endpoint.host)
+	}
+	return hosts
+}
+
+func completeRepositories(ctx context.Context, _ completionWords, _ string) []string {
+	repositories, _ := listRepositories(ctx, newRegistryClient(ctx, getRegistryHost()))
+	return repositories
+}
+
+// completeImageReferences completes repository names and, after a colon,
+// the repository's tags, of the registry the reference names or the
+// configured one.
+func completeImageReferences(ctx context.Context, _ completionWords, current string) []string {
+	host := registry.ReferenceHost(current)
+	prefix := ""
+	if host != "" {
+		prefix = host + "/"
+	} else {
+		host = getRegistryHost()
+	}
+	name := strings.TrimPrefix(current, prefix)
+	client := newRegistryClient(ctx, host)
+
+	var candidates []string
+	if separator := strings.LastIndex(name, ":"); separator > strings.LastIndex(name, "/") {
+		tags, _ := client.Tags(ctx, name[:separator])
+		for _, tag := range tags {
+			candidates = append(candidates, prefix+name[:separator+1]+tag)
+		}
+		return candidates
+	}
+	repositories, _ := listRepositories(ctx, client)
+	for _, repository := range repositories {
+		candidates = append(candidates, prefix+repository)
+	}
+	return candidates
+}
+
+func completeNamespaces(ctx context.Context, _ completionWords, _ string) []string {
+	namespaces, _ := newCluster(ctx).Namespaces(ctx)
+	return namespaces
+}
+
+// completeDeployments completes the deployments of the namespace given with
+// --namespace, leaving out the ones already named.
+func completeDeployments(ctx context.Context, typed completionWords, _ string) []string {
+	namespace := typed.flags["namespace"]
+	if namespace == "" {
+		namespace = envOrDefault("KUBERNETES_NAMESPACE", "default")
+	}
+	deployments, _ := newCluster(ctx).Deployments(ctx, namespace)
+	var names []string
+	for _, deployment := range deployments {
+		if !slices.Contains(typed.args, deployment.Name) {
+			names = append(names, deployment.Name)
+		}
+	}
+	return names
+}
+
+// The scripts call the program by the name it was run as, so an alias
+// installed as lcr completes as lcr.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for {{.Name}}
+_{{.Function}}() {
+	local line=${COMP_LINE:0:COMP_POINT}
+	local -a words
+	read -ra words <<< "$line"
+	[[ $line == *[[:space:]] ]] && words+=("")
+	local cur=${words[${#words[@]}-1]}
+	local IFS=$'\n'
+	COMPREPLY=($({{.Name}} __complete "${words[@]:1}" 2>/dev/null))
+	# bash breaks words at : and =, so only the part after them is replaced
+	local prefix=${cur%"${cur##*[:=]}"}
+	[[ -n $prefix ]] && COMPREPLY=("${COMPREPLY[@]#"$prefix"}")
+}
+complete -o default -F _{{.Function}} {{.Name}}
+`,
+	"zsh": `#compdef {{.Name}}
+_{{.Function}}() {
+	local -a candidates
+	candidates=(${(f)"$({{.Name}} __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+	compadd -Q -- "${candidates[@]}"
+}
+compdef _{{.Function}} {{.Name}}
+`,
+	"fish": `# fish completion for {{.Name}}
+function __{{.Function}}_complete
+	{{.Name}} __complete (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null
+end
+complete -c {{.Name}} -f -a '(__{{.Function}}_complete)'
+`,
+}
+
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func runCompletion(args []string) error {
+	fs := flag.NewFlagSet("completion", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: completion bash|zsh|fish")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	script, ok := completionScripts[fs.Arg(0)]
+	if fs.NArg() != 1 || !ok {
+		fs.Usage()
+		return fmt.Errorf("completion: name a shell: bash, zsh or fish")
+	}
+
+	name := filepath.Base(os.Args[0])
+	tmpl, err := template.New("completion").Parse(script)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(os.Stdout, struct{ Name, Function string }{
+		Name:     name,
+		Function: nonIdentifier.ReplaceAllString(name, "_"),
+	})
+}
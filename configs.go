@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// configRef is one place a pod template refers to a ConfigMap or Secret.
+type configRef struct {
+	kind, name string
+	key        string // "" when the whole object is used
+	optional   bool
+}
+
+// configEntry is a ConfigMap or Secret on the Config tab, together with the
+// deployments referring to it. Objects that are referenced but don't exist
+// get an entry too, with missing set, since those are what make pods fail
+// with CreateContainerConfigError.
+type configEntry struct {
+	kind, name, namespace string
+	data                  map[string][]byte
+	usedBy                []string
+	missing               bool
+	missingKeys           []string // "deployment: key" for referenced keys that don't exist
+}
+
+type configDataMsg struct {
+	entries []configEntry
+	err     error
+}
+
+// getConfigInventory lists the ConfigMaps and Secrets in
+// KUBERNETES_NAMESPACE and which deployments mount or env-reference them.
+func getConfigInventory(ctx context.Context) ([]configEntry, error) {
+	cluster := newCluster(ctx)
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+	namespace := envOrDefault("KUBERNETES_NAMESPACE", "default")
+
+	configMaps, err := cluster.ConfigMaps(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configmaps: %v", err)
+	}
+	secrets, err := cluster.Secrets(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %v", err)
+	}
+	deployments, err := cluster.Deployments(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %v", err)
+	}
+
+	entries := make(map[string]*configEntry)
+	for _, cm := range configMaps {
+		data := make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+		for key, value := range cm.Data {
+			data[key] = []byte(value)
+		}
+		for key, value := range cm.BinaryData {
+			data[key] = value
+		}
+		entries["ConfigMap/"+cm.Name] = &configEntry{kind: "ConfigMap", name: cm.Name, namespace: namespace, data: data}
+	}
+	for _, secret := range secrets {
+		entries["Secret/"+secret.Name] = &configEntry{kind: "Secret", name: secret.Name, namespace: namespace, data: secret.Data}
+	}
+
+	for _, deployment := range deployments {
+		for _, ref := range configReferences(deployment.Spec.Template.Spec) {
+			entry, ok := entries[ref.kind+"/"+ref.name]
+			if !ok {
+				if ref.optional {
+					continue
+				}
+				entry = &configEntry{kind: ref.kind, name: ref.name, namespace: namespace, missing: true}
+				entries[ref.kind+"/"+ref.name] = entry
+			}
+			if !containsString(entry.usedBy, deployment.Name) {
+				entry.usedBy = append(entry.usedBy, deployment.Name)
+			}
+			if _, found := entry.data[ref.key]; ref.key != "" && !entry.missing && !found && !ref.optional {
+				entry.missingKeys = append(entry.missingKeys, deployment.Name+": "+ref.key)
+			}
+		}
+	}
+
+	list := make([]configEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, *entry)
+	}
+	// Broken references first, then by kind and name
+	sort.Slice(list, func(i, j int) bool {
+		if a, b := list[i].broken(), list[j].broken(); a != b {
+			return a
+		}
+		if list[i].kind != list[j].kind {
+			return list[i].kind < list[j].kind
+		}
+		return list[i].name < list[j].name
+	})
+	return list, nil
+}
+
+// configReferences collects the ConfigMaps and Secrets a pod template uses
+// through volumes, env, envFrom and imagePullSecrets.
+func configReferences(spec corev1.PodSpec) []configRef {
+	var refs []configRef
+	optional := func(o *bool) bool { return o != nil && *o }
+
+	for _, volume := range spec.Volumes {
+		switch {
+		case volume.ConfigMap != nil:
+			refs = append(refs, configRef{kind: "ConfigMap", name: volume.ConfigMap.Name, optional: optional(volume.ConfigMap.Optional)})
+		case volume.Secret != nil:
+			refs = append(refs, configRef{kind: "Secret", name: volume.Secret.SecretName, optional: optional(volume.Secret.Optional)})
+		case volume.Projected != nil:
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					refs = append(refs, configRef{kind: "ConfigMap", name: source.ConfigMap.Name, optional: optional(source.ConfigMap.Optional)})
+				}
+				if source.Secret != nil {
+					refs = append(refs, configRef{kind: "Secret", name: source.Secret.Name, optional: optional(source.Secret.Optional)})
+				}
+			}
+		}
+	}
+
+	for _, container := range append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...) {
+		for _, from := range container.EnvFrom {
+			if from.ConfigMapRef != nil {
+				refs = append(refs, configRef{kind: "ConfigMap", name: from.ConfigMapRef.Name, optional: optional(from.ConfigMapRef.Optional)})
+			}
+			if from.SecretRef != nil {
+				refs = append(refs, configRef{kind: "Secret", name: from.SecretRef.Name, optional: optional(from.SecretRef.Optional)})
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+				refs = append(refs, configRef{kind: "ConfigMap", name: ref.Name, key: ref.Key, optional: optional(ref.Optional)})
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+				refs = append(refs, configRef{kind: "Secret", name: ref.Name, key: ref.Key, optional: optional(ref.Optional)})
+			}
+		}
+	}
+
+	for _, secret := range spec.ImagePullSecrets {
+		refs = append(refs, configRef{kind: "Secret", name: secret.Name})
+	}
+	return refs
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (e configEntry) broken() bool {
+	return e.missing || len(e.missingKeys) > 0
+}
+
+// state is the Config tab's Status column.
+func (e configEntry) state() string {
+	switch {
+	case e.missing:
+		return "❌ missing"
+	case len(e.missingKeys) > 0:
+		return fmt.Sprintf("⚠️  %d keys missing", len(e.missingKeys))
+	case len(e.usedBy) == 0:
+		return "unused"
+	}
+	return "ok"
+}
+
+func (m model) renderConfigStatus() string {
+	namespace := envOrDefault("KUBERNETES_NAMESPACE", "default")
+	switch {
+	case m.configEntries == nil && m.configErr == nil:
+		return fmt.Sprintf("Loading ConfigMaps and Secrets in %s...", namespace)
+	case m.configErr != nil:
+		return fmt.Sprintf("⚠️  %v", m.configErr)
+	}
+	broken := 0
+	for _, entry := range m.configEntries {
+		if entry.broken() {
+			broken++
+		}
+	}
+	return fmt.Sprintf("Namespace %s · %d ConfigMaps and Secrets · %d missing or missing keys deployments need · Enter to view", namespace, len(m.configEntries), broken)
+}
+
+// updateConfigViewer handles keys while a ConfigMap or Secret is shown.
+func (m model) updateConfigViewer(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "v":
+		m.revealSecret = !m.revealSecret
+	case "esc", "q":
+		m.showConfigViewer = false
+		m.revealSecret = false
+	}
+	return m, nil
+}
+
+// renderConfigViewer shows an object's keys read-only. Secret values stay
+// masked until revealed with V, so the screen is safe to share by default.
+func (m model) renderConfigViewer() string {
+	entry := m.configViewerTarget
+	var content strings.Builder
+	fmt.Fprintf(&content, "%s %s/%s\n\n", entry.kind, entry.namespace, entry.name)
+
+	if entry.missing {
+		content.WriteString("This object doesn't exist, but the deployments below need it.\nPods referencing it fail with CreateContainerConfigError.\n")
+	} else if len(entry.data) == 0 {
+		content.WriteString("(no keys)\n")
+	}
+
+	keys := make([]string, 0, len(entry.data))
+	for key := range entry.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&content, "%s: %s\n", key, m.configValue(entry, entry.data[key]))
+	}
+
+	if len(entry.usedBy) > 0 {
+		fmt.Fprintf(&content, "\nUsed by: %s\n", strings.Join(entry.usedBy, ", "))
+	}
+	for _, missing := range entry.missingKeys {
+		fmt.Fprintf(&content, "⚠️  missing key needed by %s\n", missing)
+	}
+
+	content.WriteString("\n")
+	if entry.kind == "Secret" && !entry.missing {
+		if m.revealSecret {
+			content.WriteString("v to mask values, ")
+		} else {
+			content.WriteString("v to reveal values, ")
+		}
+	}
+	content.WriteString("ESC to close")
+
+	style := modalStyle.Width(80).Height(0)
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, style.Render(content.String()), lipgloss.WithWhitespaceChars("░"))
+}
+
+func (m model) configValue(entry configEntry, value []byte) string {
+	if entry.kind == "Secret" && !m.revealSecret {
+		return fmt.Sprintf("•••• (%d bytes)", len(value))
+	}
+	text := strings.TrimRight(string(value), "\n")
+	if lines := strings.Count(text, "\n"); lines > 0 {
+		first, _, _ := strings.Cut(text, "\n")
+		text = fmt.Sprintf("%s … (%d lines)", first, lines+1)
+	}
+	return truncateString(text, 60)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Values of TableData.LocalState for registry images
+const (
+	localStateSame    = "same"
+	localStateDiffers = "differs"
+)
+
+type localImage struct {
+	ref    string // repository:tag as tagged locally
+	id     string
+	digest string // registry digest of this repository, empty if never pushed/pulled
+}
+
+// getLocalImagesByName lists local images keyed by their normalized
+// repository:tag, so "web:v1" and "localhost:5000/web:v1" are compared with
+// the registry's web:v1 alike.
+func getLocalImagesByName(ctx context.Context) (map[string][]localImage, error) {
+	ctx, cancel := withBackendTimeout(ctx, backendDocker)
+	defer cancel()
+
+	local, err := containerRuntime(ctx).Images(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local images: %v", err)
+	}
+
+	images := make(map[string][]localImage)
+	for _, listed := range local {
+		if strings.Contains(listed.Ref(), "<none>") {
+			continue
+		}
+		image := localImage{ref: listed.Ref(), id: listed.ID, digest: listed.Digest}
+		name, _ := normalizeImageReference(image.ref)
+		images[name] = append(images[name], image)
+	}
+	return images, nil
+}
+
+// markLocalConflicts compares registry images with local images of the same
+// name. A local image is in sync when it is the image Docker pushed or
+// pulled with the registry's digest; any other local image under that name
+// has diverged. Rows without a registry digest (the local fallback listing)
+// are left alone.
+func markLocalConflicts(ctx context.Context, data []TableData) []TableData {
+	local, err := getLocalImagesByName(ctx)
+	if err != nil {
+		return data
+	}
+
+	for i := range data {
+		if data[i].ImageDigest == "" {
+			continue
+		}
+		name, _ := normalizeImageReference(data[i].ImageTag)
+		candidates := local[name]
+		if len(candidates) == 0 {
+			continue
+		}
+
+		syncedIDs := make(map[string]bool)
+		for _, image := range candidates {
+			if image.digest == data[i].ImageDigest {
+				syncedIDs[image.id] = true
+			}
+		}
+		data[i].LocalState = localStateSame
+		for _, image := range candidates {
+			if !syncedIDs[image.id] {
+				data[i].LocalState = localStateDiffers
+				data[i].LocalRef = image.ref
+				break
+			}
+		}
+	}
+	return data
+}
+
+// pushLocalImage makes the registry match the local image: the local tag is
+// retagged under the registry name if needed and pushed.
+func pushLocalImage(ctx context.Context, localRef, registryRef string) error {
+	ctx, cancel := withBackendTimeout(ctx, backendDocker)
+	defer cancel()
+
+	if localRef != registryRef {
+		if err := containerRuntime(ctx).Tag(ctx, localRef, registryRef); err != nil {
+			return err
+		}
+	}
+	return containerRuntime(ctx).Push(ctx, registryRef)
+}
+
+// pullRegistryImage makes the local image match the registry, including the
+// local tag the divergent copy was known under.
+func pullRegistryImage(ctx context.Context, registryRef, localRef string) error {
+	ctx, cancel := withBackendTimeout(ctx, backendDocker)
+	defer cancel()
+
+	if err := containerRuntime(ctx).Pull(ctx, registryRef, nil); err != nil {
+		return err
+	}
+	if localRef != "" && localRef != registryRef {
+		return containerRuntime(ctx).Tag(ctx, registryRef, localRef)
+	}
+	return nil
+}
+
+type reconcileMsg struct {
+	direction string // "push" or "pull"
+	image     string
+	err       error
+}
+
+func (m *model) reconcileImage(image TableData, direction string) tea.Cmd {
+	step := sessionStep{Action: sessionPush, Image: image.ImageTag, LocalImage: image.LocalRef}
+	if direction == "pull" {
+		step.Action = sessionPullLocal
+	}
+	recordSessionStep(step)
+	// History is written even when the operation itself is cancelled
+	historyCtx := m.ctx
+	return m.startOperation(direction, image.ImageTag, func(ctx context.Context) tea.Msg {
+		var err error
+		if direction == "push" {
+			err = pushLocalImage(ctx, image.LocalRef, image.ImageTag)
+		} else {
+			err = pullRegistryImage(ctx, image.ImageTag, image.LocalRef)
+		}
+
+		status, details := direction+"ed", fmt.Sprintf("local %s, registry digest %s", image.LocalRef, image.ImageDigest)
+		if err != nil {
+			status, details = "failed", fmt.Sprintf("%s: %v", direction, err)
+		}
+		recordHistory(withSpanOf(historyCtx, ctx), "reconcile", image.ImageTag, status, details)
+		return reconcileMsg{direction: direction, image: image.ImageTag, err: err}
+	})
+}
+
+// updateReconcile handles keys while the reconcile dialog is open.
+func (m model) updateReconcile(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "1":
+		m.showReconcile = false
+		cmd := m.reconcileImage(m.reconcileTarget, "push")
+		return m, cmd
+	case "2":
+		m.showReconcile = false
+		cmd := m.reconcileImage(m.reconcileTarget, "pull")
+		return m, cmd
+	case "esc", "q":
+		m.showReconcile = false
+	}
+	return m, nil
+}
+
+func (m model) renderReconcile() string {
+	image := m.reconcileTarget
+	content := fmt.Sprintf(`Local and Registry Images Differ
+
+Registry: %s
+  digest %s
+Local:    %s
+
+The Docker tab lists the registry copy, but a different image is
+tagged under the same name locally. Choose which copy wins:
+
+[1] Push local image up to the registry
+[2] Pull registry image down over the local tag
+
+Press 1 or 2, or ESC to cancel`, image.ImageTag, truncateString(image.ImageDigest, 26), image.LocalRef)
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, modalStyle.Render(content), lipgloss.WithWhitespaceChars("░"))
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/term"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/kube"
+)
+
+// From the pod details, L shows the log of one of the pod's containers and
+// E opens a shell in one. Pods with several containers ask which first.
+
+// podLogLines is how much of a container's log the logs view reads.
+const podLogLines = 200
+
+// containerShellCommand starts bash where the image has it, sh otherwise.
+var containerShellCommand = []string{"/bin/sh", "-c", "command -v bash >/dev/null && exec bash || exec sh"}
+
+type podLogsMsg struct {
+	container string
+	lines     []string
+	err       error
+}
+
+type podExecMsg struct {
+	container string
+	err       error
+}
+
+// getPodLogs reads the last podLogLines lines of a container's log.
+func getPodLogs(ctx context.Context, podName, namespace, container string) ([]string, error) {
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	logs, err := newCluster(ctx).Logs(ctx, namespace, podName, kube.LogOptions{Container: container, Tail: podLogLines})
+	if err != nil {
+		return nil, err
+	}
+	logs = strings.TrimRight(logs, "\n")
+	if logs == "" {
+		return nil, nil
+	}
+	return strings.Split(logs, "\n"), nil
+}
+
+func (m model) loadPodLogs(container string) tea.Cmd {
+	podName, namespace := m.selectedPod, m.selectedPodNS
+	return func() tea.Msg {
+		lines, err := getPodLogs(m.tabCtx, podName, namespace, container)
+		return podLogsMsg{container: container, lines: lines, err: err}
+	}
+}
+
+// pickContainer runs action ("logs" or "exec") on the pod's only container,
+// or asks which one when it has several.
+func (m model) pickContainer(action string) (tea.Model, tea.Cmd) {
+	switch len(m.podContainers) {
+	case 0:
+		// The details haven't loaded yet
+		return m, nil
+	case 1:
+		return m.runContainerAction(action, m.podContainers[0])
+	}
+	m.containerAction = action
+	m.containerCursor = 0
+	return m, nil
+}
+
+func (m model) runContainerAction(action, container string) (tea.Model, tea.Cmd) {
+	if action == "exec" {
+		if reason := m.roleDenied(permExecPods); reason != "" {
+			m.statusMessage = reason
+			return m, nil
+		}
+		return m, m.execInContainer(container)
+	}
+	m.showPodLogs = true
+	m.podLogs = nil
+	m.podLogsOffset = 0
+	return m, m.loadPodLogs(container)
+}
+
+func (m model) updateContainerPicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "up", "k":
+		if m.containerCursor > 0 {
+			m.containerCursor--
+		}
+	case "down", "j":
+		if m.containerCursor < len(m.podContainers)-1 {
+			m.containerCursor++
+		}
+	case "enter":
+		action := m.containerAction
+		m.containerAction = ""
+		if m.containerCursor < len(m.podContainers) {
+			return m.runContainerAction(action, m.podContainers[m.containerCursor])
+		}
+	case "esc", "q":
+		m.containerAction = ""
+	}
+	return m, nil
+}
+
+func (m model) renderContainerPicker() string {
+	var b strings.Builder
+	if m.containerAction == "exec" {
+		b.WriteString("Open a Shell in Container\n\n")
+	} else {
+		b.WriteString("Show Logs of Container\n\n")
+	}
+	for i, container := range m.podContainers {
+		prefix := "  "
+		if i == m.containerCursor {
+			prefix = "→ "
+		}
+		b.WriteString(prefix + container + "\n")
+	}
+	b.WriteString("\nUse ↑/↓ to navigate, Enter to select, ESC to cancel")
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, modalStyle.Width(72).Height(0).Render(b.String()), lipgloss.WithWhitespaceChars("░"))
+}
+
+func (m model) updatePodLogs(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "up", "k":
+		if m.podLogs != nil && m.podLogsOffset < len(m.podLogs.lines)-1 {
+			m.podLogsOffset++
+		}
+	case "down", "j":
+		if m.podLogsOffset > 0 {
+			m.podLogsOffset--
+		}
+	case "r":
+		if m.podLogs != nil {
+			container := m.podLogs.container
+			m.podLogs = nil
+			m.podLogsOffset = 0
+			return m, m.loadPodLogs(container)
+		}
+	case "esc", "q":
+		m.showPodLogs = false
+		m.podLogs = nil
+	}
+	return m, nil
+}
+
+// renderPodLogs shows as much of the end of the log as fits, scrolled up by
+// podLogsOffset lines.
+func (m model) renderPodLogs() string {
+	var b strings.Builder
+	if m.podLogs == nil {
+		fmt.Fprintf(&b, "Logs: %s\n\nLoading logs...\n", m.selectedPod)
+	} else {
+		fmt.Fprintf(&b, "Logs: %s/%s (last %d lines)\n\n", m.selectedPod, m.podLogs.container, podLogLines)
+		switch {
+		case m.podLogs.err != nil:
+			b.WriteString("❌ " + m.podLogs.err.Error() + "\n")
+		case len(m.podLogs.lines) == 0:
+			b.WriteString("(no output)\n")
+		default:
+			height := max(m.height-8, 5)
+			end := len(m.podLogs.lines) - m.podLogsOffset
+			start := max(end-height, 0)
+			width := max(m.width-6, 20)
+			for _, line := range m.podLogs.lines[start:end] {
+				b.WriteString(truncateString(line, width) + "\n")
+			}
+		}
+	}
+	b.WriteString("\nUse ↑/↓ to scroll, r to reload, ESC to close")
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, modalStyle.Width(max(m.width-4, 72)).Height(0).Render(b.String()), lipgloss.WithWhitespaceChars("░"))
+}
+
+// execInContainer hands the terminal to a shell in the container until it
+// exits.
+func (m model) execInContainer(container string) tea.Cmd {
+	shell := &containerShell{ctx: m.ctx, pod: m.selectedPod, namespace: m.selectedPodNS, container: container}
+	return tea.Exec(shell, func(err error) tea.Msg {
+		return podExecMsg{container: container, err: err}
+	})
+}
+
+// containerShell is an interactive shell in a container, run through the
+// API server's exec endpoint the way kubectl exec -it does.
+type containerShell struct {
+	ctx       context.Context
+	pod       string
+	namespace string
+	container string
+	stdin     io.Reader
+	stdout    io.Writer
+	stderr    io.Writer
+}
+
+func (s *containerShell) SetStdin(r io.Reader)  { s.stdin = r }
+func (s *containerShell) SetStdout(w io.Writer) { s.stdout = w }
+func (s *containerShell) SetStderr(w io.Writer) { s.stderr = w }
+
+func (s *containerShell) Run() error {
+	if !kubernetesEnabled() {
+		return errKubernetesDisabled
+	}
+	config, err := kubeRESTConfigFor(currentCluster())
+	if err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("error creating client: %v", err)
+	}
+
+	request := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(s.namespace).
+		Name(s.pod).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: s.container,
+			Command:   containerShellCommand,
+			Stdin:     true,
+			Stdout:    true,
+			TTY:       true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(config, "POST", request.URL())
+	if err != nil {
+		return fmt.Errorf("error starting a shell in %s: %v", s.container, err)
+	}
+
+	options := remotecommand.StreamOptions{Stdin: s.stdin, Stdout: s.stdout, Tty: true}
+	if file, ok := s.stdin.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+		state, err := term.MakeRaw(int(file.Fd()))
+		if err != nil {
+			return fmt.Errorf("error preparing the terminal: %v", err)
+		}
+		defer term.Restore(int(file.Fd()), state)
+		if width, height, err := term.GetSize(int(file.Fd())); err == nil {
+			options.TerminalSizeQueue = &fixedTerminalSize{size: &remotecommand.TerminalSize{Width: uint16(width), Height: uint16(height)}}
+		}
+	}
+
+	// The shell runs until the user leaves it, so only the caller's
+	// cancellation ends it, not the Kubernetes timeout
+	if err := executor.StreamWithContext(s.ctx, options); err != nil {
+		return fmt.Errorf("shell in %s ended: %v", s.container, err)
+	}
+	return nil
+}
+
+// fixedTerminalSize reports the terminal's size once; the shell keeps it
+// for its lifetime.
+type fixedTerminalSize struct {
+	size *remotecommand.TerminalSize
+}
+
+func (q *fixedTerminalSize) Next() *remotecommand.TerminalSize {
+	size := q.size
+	q.size = nil
+	return size
+}
+
+// Deploying to a deployment with several containers asks which one gets the
+// image, starting at the container that runs the image's repository.
+
+// defaultDeployContainer is the index of the container deploying image most
+// likely means: the one running another tag of it, else the one named after
+// its repository, else the first.
+func defaultDeployContainer(deployment TableData, image string) int {
+	repository, _ := splitImageTag(image)
+	repository = path.Base(repository)
+	for i, current := range deployment.ContainerImages {
+		if currentRepository, _ := splitImageTag(current); path.Base(currentRepository) == repository {
+			return i
+		}
+	}
+	for i, container := range deployment.Containers {
+		if container == repository {
+			return i
+		}
+	}
+	return 0
+}
+
+// confirmDeploy moves to the confirmation step, previewing the deploy to
+// the selected deployment.
+func (m *model) confirmDeploy() tea.Cmd {
+	m.modalStep = 2
+	m.canaryReplicas = canaryReplicas()
+	m.deployPreview = nil
+	m.deployEnv = nil
+	m.editingDeployEnv = false
+	m.deployEnvErr = ""
+	if m.selectedDeployment < 0 || m.selectedDeployment >= len(m.deployments) {
+		return nil
+	}
+	deployment := m.deployments[m.selectedDeployment]
+	return m.loadDeployPreview(deployment.PodName, deployment.Namespace)
+}
+
+func (m model) updateDeployContainerPicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.selectedDeployment < 0 || m.selectedDeployment >= len(m.deployments) {
+		m.modalStep = 0
+		return m, nil
+	}
+	containers := m.deployments[m.selectedDeployment].Containers
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "up", "k":
+		if m.deployContainerIndex > 0 {
+			m.deployContainerIndex--
+		}
+	case "down", "j":
+		if m.deployContainerIndex < len(containers)-1 {
+			m.deployContainerIndex++
+		}
+	case "enter", "1":
+		if m.deployContainerIndex < len(containers) {
+			m.deployContainer = containers[m.deployContainerIndex]
+			return m, m.confirmDeploy()
+		}
+	case "2":
+		m.modalStep = 0
+	case "esc":
+		m.showModal = false
+		m.modalStep = 0
+	}
+	return m, nil
+}
+
+func (m model) renderDeployContainerPicker() string {
+	deployment := m.deployments[m.selectedDeployment]
+	var b strings.Builder
+	fmt.Fprintf(&b, "Deploy %s\n\n%s has %d containers. Set the image of:\n\n", m.selectedImage, deployment.PodName, len(deployment.Containers))
+	for i, container := range deployment.Containers {
+		prefix := "  "
+		if i == m.deployContainerIndex {
+			prefix = "→ "
+		}
+		line := prefix + container
+		if i < len(deployment.ContainerImages) {
+			line += "  (" + truncateString(deployment.ContainerImages[i], 50) + ")"
+		}
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("\nUse ↑/↓ to navigate, Enter to select, 2 to go back, ESC to cancel")
+
+	return modalStyle.Width(72).Height(0).Render(b.String())
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// A panic in the TUI, in one of its commands or in a headless command ends
+// in a crash report rather than a stack trace on a terminal left in raw
+// mode: the panic and its stack, the end of the log and the configuration
+// with secrets redacted. bug-report bundles the reports with the doctor's
+// checks into one file to attach to an issue.
+
+// maxCrashReports are kept; older ones are removed as new ones are written.
+const maxCrashReports = 20
+
+// recentLogs keeps the last lines logged for crash reports. While the TUI
+// runs, the log goes nowhere else.
+var recentLogs = &logRing{size: 200}
+
+// logRing is an io.Writer keeping the last size lines written to it.
+type logRing struct {
+	mu      sync.Mutex
+	size    int
+	lines   []string
+	partial []byte
+}
+
+func (r *logRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.partial = append(r.partial, p...)
+	for {
+		i := bytes.IndexByte(r.partial, '\n')
+		if i < 0 {
+			break
+		}
+		r.lines = append(r.lines, string(r.partial[:i]))
+		r.partial = r.partial[i+1:]
+	}
+	if len(r.lines) > r.size {
+		r.lines = slices.Clone(r.lines[len(r.lines)-r.size:])
+	}
+	return len(p), nil
+}
+
+// Lines are the lines kept, oldest first.
+func (r *logRing) Lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.lines)
+}
+
+// crashDir is next to the undo journal.
+func crashDir() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "local-container-registry", "crashes")
+}
+
+// recoverCrash, deferred, turns a panic into a crash report and exits.
+func recoverCrash() {
+	if r := recover(); r != nil {
+		crash(r, debug.Stack())
+	}
+}
+
+// crashing stops a second panic from reporting over the first one.
+var crashing sync.Mutex
+
+func crash(value any, stack []byte) {
+	crashing.Lock()
+	// The cleanups restore the terminal, so the message is readable
+	runShutdown()
+	fmt.Fprintf(os.Stderr, "💥 local-container-registry crashed: %v\n", value)
+	if path, err := writeCrashReport(value, stack); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Failed to save a crash report: %v\n\n%s", err, stack)
+	} else {
+		fmt.Fprintf(os.Stderr, "📝 Saved a crash report to %s\n", path)
+		fmt.Fprintln(os.Stderr, "   Run `local-container-registry bug-report` and attach the file it writes to an issue.")
+	}
+	os.Exit(2)
+}
+
+// writeCrashReport writes the report of a panic to the crash directory,
+// removing the oldest reports beyond maxCrashReports.
+func writeCrashReport(value any, stack []byte) (string, error) {
+	dir := crashDir()
+	if dir == "" {
+		return "", errors.New("no config directory to keep it in")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	path := filepath.Join(dir, "crash-"+now.Format("20060102-150405")+".txt")
+	if err := os.WriteFile(path, []byte(crashReport(now, value, stack)), 0o600); err != nil {
+		return "", err
+	}
+	if reports := crashReports(); len(reports) > maxCrashReports {
+		for _, old := range reports[maxCrashReports:] {
+			os.Remove(old)
+		}
+	}
+	return path, nil
+}
+
+// crashReport is the text of a crash report.
+func crashReport(at time.Time, value any, stack []byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "local-container-registry crashed at %s\n", at.UTC().Format(time.RFC3339))
+	b.WriteString(systemInfo())
+	fmt.Fprintf(&b, "\npanic: %v\n\n%s\n", value, stack)
+	b.WriteString("Recent log:\n")
+	for _, line := range recentLogs.Lines() {
+		b.WriteString("  " + line + "\n")
+	}
+	b.WriteString("\nConfiguration:\n")
+	for _, line := range redactedConfig() {
+		b.WriteString("  " + line + "\n")
+	}
+	return b.String()
+}
+
+// systemInfo is the version, platform and command the process runs.
+func systemInfo() string {
+	command := "tui"
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		// Only the command: flags can hold credentials
+		command = os.Args[1]
+	}
+	return fmt.Sprintf("Version:  %s (%s %s/%s)\nCommand:  %s\n",
+		cefVersion(), runtime.Version(), runtime.GOOS, runtime.GOARCH, command)
+}
+
+// crashReports are the paths of the crash reports, newest first.
+func crashReports() []string {
+	reports, _ := filepath.Glob(filepath.Join(crashDir(), "crash-*.txt"))
+	slices.Sort(reports)
+	slices.Reverse(reports)
+	return reports
+}
+
+// guardedModel runs the model's commands under recoverCrash. Bubble Tea
+// runs each command in a goroutine of its own, where a panic would end the
+// process before startTUI's recoverCrash sees it.
+type guardedModel struct {
+	tea.Model
+}
+
+func (g guardedModel) Init() tea.Cmd {
+	return guardCmd(g.Model.Init())
+}
+
+func (g guardedModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m, cmd := g.Model.Update(msg)
+	return guardedModel{m}, guardCmd(cmd)
+}
+
+// guardCmd is cmd with a panic turned into a crash report, as are the
+// commands of a batch it returns.
+func guardCmd(cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		defer recoverCrash()
+		msg := cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			for i := range batch {
+				batch[i] = guardCmd(batch[i])
+			}
+		}
+		return msg
+	}
+}
+
+// configPrefixes mark the environment variables that configure the tool,
+// which reports include; the rest of the environment stays out.
+var configPrefixes = []string{
+	"API_", "ARGOCD_", "AUDIT_", "CANARY_", "COLLECT_", "DOCKER_", "ENVIRONMENTS", "GITHUB_", "GITOPS_", "GIT_SERVICE",
+	"HELM_", "IMAGE_", "INGRESS_", "JOBS", "KEYRING_", "KUBE", "MYSQL_", "NOTIFY_", "OTEL_",
+	"PPROF_", "PULL_SECRET_", "REGISTR", "RESOURCE_", "RETENTION_", "RETRY_", "SERVER_",
+	"SIZE_", "SYNC_", "UPLOAD_", "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY",
+}
+
+// secretWords in a variable's name mark its value as secret.
+var secretWords = []string{"PASSWORD", "TOKEN", "SECRET", "CREDENTIAL"}
+
+// redactedConfig is the tool's environment as NAME=value lines, sorted,
+// with secrets left out.
+func redactedConfig() []string {
+	var lines []string
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		upper := strings.ToUpper(name)
+		if !slices.ContainsFunc(configPrefixes, func(prefix string) bool { return strings.HasPrefix(upper, prefix) }) &&
+			!strings.HasSuffix(upper, "_TIMEOUT") && !strings.HasSuffix(upper, "_PROXY") {
+			continue
+		}
+		lines = append(lines, name+"="+redactValue(upper, value))
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+// redactValue hides secret variables and the credentials in URLs: their
+// passwords, query strings and, for webhooks, the secret paths.
+func redactValue(name, value string) string {
+	if value == "" {
+		return ""
+	}
+	if slices.ContainsFunc(secretWords, func(word string) bool { return strings.Contains(name, word) }) {
+		return "[redacted]"
+	}
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	entries := strings.Split(value, ",")
+	for i, entry := range entries {
+		// Lists like REGISTRIES name their entries, name=URL
+		label, rawURL := "", strings.TrimSpace(entry)
+		scheme := strings.Index(rawURL, "://")
+		if scheme < 0 {
+			continue
+		}
+		if j := strings.Index(rawURL, "="); j >= 0 && j < scheme {
+			label, rawURL = rawURL[:j+1], rawURL[j+1:]
+		}
+		u, err := url.Parse(rawURL)
+		switch {
+		case err != nil || u.Host == "":
+			entries[i] = label + "[redacted]"
+		case strings.Contains(name, "WEBHOOK"):
+			entries[i] = label + redactWebhookURL(u.String())
+		default:
+			if u.RawQuery != "" {
+				u.RawQuery = "redacted"
+			}
+			entries[i] = label + u.Redacted()
+		}
+	}
+	return strings.Join(entries, ",")
+}
+
+// appLogLines of app.log go into a bug report.
+const appLogLines = 500
+
+// runBugReport writes the bundle to attach to an issue: the version, the
+// redacted configuration, the doctor's checks, the end of app.log and the
+// crash reports.
+func runBugReport(args []string) error {
+	fs := flag.NewFlagSet("bug-report", flag.ContinueOnError)
+	out := fs.String("out", "", "file to write the bundle to (default local-container-registry-bug-report-<time>.tar.gz)")
+	noChecks := fs.Bool("no-checks", false, "leave out the doctor's checks of the backends")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	now := time.Now()
+	path := *out
+	if path == "" {
+		path = "local-container-registry-bug-report-" + now.Format("20060102-150405") + ".tar.gz"
+	}
+
+	files := []bundleFile{
+		{"system.txt", []byte(fmt.Sprintf("Created:  %s\n%s", now.UTC().Format(time.RFC3339), systemInfo()))},
+		{"config.txt", []byte(strings.Join(redactedConfig(), "\n") + "\n")},
+	}
+	if !*noChecks {
+		ctx, stop := signalContext()
+		defer stop()
+		fixKubeconfigPaths()
+		fmt.Fprintln(os.Stderr, "🩺 Checking the backends...")
+		content, err := json.MarshalIndent(runDoctorChecks(withoutRetries(ctx), doctorChecks()), "", "  ")
+		if err != nil {
+			return err
+		}
+		files = append(files, bundleFile{"doctor.json", content})
+	}
+	if content, err := os.ReadFile("app.log"); err == nil {
+		lines := strings.SplitAfter(string(content), "\n")
+		content = []byte(strings.Join(lines[max(len(lines)-appLogLines, 0):], ""))
+		files = append(files, bundleFile{"app.log", content})
+	}
+	reports := crashReports()
+	for _, report := range reports {
+		content, err := os.ReadFile(report)
+		if err != nil {
+			continue
+		}
+		files = append(files, bundleFile{"crashes/" + filepath.Base(report), content})
+	}
+
+	if err := writeBundle(path, now, files); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "📦 Wrote %s with %d crash reports; secrets in the configuration are redacted, but look it over before attaching it to an issue\n", path, len(reports))
+	return nil
+}
+
+// bundleFile is one file of a bug report.
+type bundleFile struct {
+	name    string
+	content []byte
+}
+
+// writeBundle writes files to a gzipped tar at path.
+func writeBundle(path string, modTime time.Time, files []bundleFile) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(file)
+	archive := tar.NewWriter(gz)
+	for _, part := range files {
+		err = archive.WriteHeader(&tar.Header{Name: part.name, Mode: 0o600, Size: int64(len(part.content)), ModTime: modTime})
+		if err == nil {
+			_, err = archive.Write(part.content)
+		}
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+	}
+	if err := errors.Join(archive.Close(), gz.Close(), file.Close()); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/kube"
+)
+
+// X on a pod diagnoses why it keeps crashing: how its container last
+// exited, the end of the log of the instance that crashed and the pod's
+// events, on one screen.
+
+// diagnosisLogLines is how much of the crashed instance's log is shown.
+const diagnosisLogLines = 50
+
+// crashDiagnosis is what the diagnosis screen shows of a pod.
+type crashDiagnosis struct {
+	container string // the one that crashed, "" when none did
+	state     string // what the container does now, e.g. waiting in CrashLoopBackOff
+	restarts  int32
+	lastState string // how the crashed instance exited
+	exitCode  int32
+	logs      []string
+	logsErr   error
+	events    []string
+}
+
+type crashDiagnosisMsg struct {
+	pod       string
+	diagnosis crashDiagnosis
+	err       error
+}
+
+// diagnosePod gathers the last termination, previous log and events of a
+// pod's crashing container.
+func diagnosePod(ctx context.Context, podName, namespace string) (crashDiagnosis, error) {
+	cluster := newCluster(ctx)
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	pod, err := cluster.Pod(ctx, namespace, podName)
+	if err != nil {
+		return crashDiagnosis{}, fmt.Errorf("error getting pod: %v", err)
+	}
+
+	var diagnosis crashDiagnosis
+	if status, ok := crashedContainer(pod); ok {
+		diagnosis.container = status.Name
+		diagnosis.state = containerState(status.State)
+		diagnosis.restarts = status.RestartCount
+		last := status.LastTerminationState
+		if last.Terminated == nil {
+			// Not restarted yet: the instance that crashed is the current one
+			last = status.State
+		}
+		diagnosis.lastState = containerState(last)
+		if last.Terminated != nil {
+			diagnosis.exitCode = last.Terminated.ExitCode
+		}
+
+		logs, err := cluster.Logs(ctx, namespace, podName, kube.LogOptions{
+			Container: status.Name,
+			Tail:      diagnosisLogLines,
+			Previous:  status.LastTerminationState.Terminated != nil,
+		})
+		if logs = strings.TrimRight(logs, "\n"); logs != "" {
+			diagnosis.logs = strings.Split(logs, "\n")
+		}
+		diagnosis.logsErr = err
+	}
+
+	events, err := cluster.Events(ctx, namespace, fields.Set{"involvedObject.kind": "Pod", "involvedObject.name": podName}.String())
+	if err == nil {
+		items := events
+		sort.Slice(items, func(i, j int) bool { return eventTime(items[i]).After(eventTime(items[j])) })
+		if len(items) > 10 {
+			items = items[:10]
+		}
+		for _, event := range items {
+			age := time.Since(eventTime(event)).Truncate(time.Second)
+			diagnosis.events = append(diagnosis.events, fmt.Sprintf("%s ago %s %s: %s", age, event.Type, event.Reason, event.Message))
+		}
+	}
+	return diagnosis, nil
+}
+
+// crashedContainer is the container of the pod that crashed: one waiting in
+// CrashLoopBackOff, else the one that exited last with an error.
+func crashedContainer(pod *corev1.Pod) (corev1.ContainerStatus, bool) {
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff" {
+			return status, true
+		}
+	}
+	var crashed corev1.ContainerStatus
+	var finished time.Time
+	for _, status := range statuses {
+		terminated := status.LastTerminationState.Terminated
+		if terminated == nil {
+			terminated = status.State.Terminated
+		}
+		if terminated != nil && terminated.ExitCode != 0 && terminated.FinishedAt.After(finished) {
+			crashed, finished = status, terminated.FinishedAt.Time
+		}
+	}
+	return crashed, crashed.Name != ""
+}
+
+// exitCodeMeaning explains the exit codes containers commonly crash with.
+func exitCodeMeaning(code int32) string {
+	switch code {
+	case 1:
+		return "the application failed, its log should say why"
+	case 126:
+		return "the command isn't executable"
+	case 127:
+		return "the command wasn't found in the image"
+	case 134:
+		return "aborted (SIGABRT)"
+	case 137:
+		return "killed (SIGKILL), usually for exceeding its memory limit or failing its liveness probe"
+	case 139:
+		return "segmentation fault (SIGSEGV)"
+	case 143:
+		return "terminated (SIGTERM) and exited"
+	}
+	return ""
+}
+
+func (m model) loadCrashDiagnosis() tea.Cmd {
+	podName, namespace := m.selectedPod, m.selectedPodNS
+	return func() tea.Msg {
+		diagnosis, err := diagnosePod(m.tabCtx, podName, namespace)
+		return crashDiagnosisMsg{pod: podName, diagnosis: diagnosis, err: err}
+	}
+}
+
+func (m model) updateCrashDiagnosis(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "r":
+		m.diagnosis = nil
+		return m, m.loadCrashDiagnosis()
+	case "esc", "q", "x":
+		m.showCrashDiagnosis = false
+		m.diagnosis = nil
+	}
+	return m, nil
+}
+
+func (m model) renderCrashDiagnosis() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Crash Diagnosis: %s/%s\n\n", m.selectedPodNS, m.selectedPod)
+	switch msg := m.diagnosis; {
+	case msg == nil:
+		b.WriteString("Loading...\n")
+	case msg.err != nil:
+		b.WriteString("❌ " + msg.err.Error() + "\n")
+	default:
+		d := msg.diagnosis
+		if d.container == "" {
+			b.WriteString("No container of this pod has crashed.\n")
+		} else {
+			fmt.Fprintf(&b, "Container:  %s (%d restarts)\n", d.container, d.restarts)
+			fmt.Fprintf(&b, "Now:        %s\n", d.state)
+			fmt.Fprintf(&b, "Last exit:  %s\n", d.lastState)
+			if meaning := exitCodeMeaning(d.exitCode); meaning != "" {
+				fmt.Fprintf(&b, "            exit code %d: %s\n", d.exitCode, meaning)
+			}
+
+			fmt.Fprintf(&b, "\nLast %d log lines of the crashed instance:\n", diagnosisLogLines)
+			switch {
+			case d.logsErr != nil:
+				b.WriteString("❌ " + d.logsErr.Error() + "\n")
+			case len(d.logs) == 0:
+				b.WriteString("(no output)\n")
+			}
+			// Only as many as fit, from the end
+			logs := d.logs
+			if room := max(m.height-20-len(d.events), 5); len(logs) > room {
+				logs = logs[len(logs)-room:]
+			}
+			for _, line := range logs {
+				b.WriteString(truncateString(line, max(m.width-8, 20)) + "\n")
+			}
+		}
+
+		b.WriteString("\nEvents:\n")
+		if len(d.events) == 0 {
+			b.WriteString("none recently\n")
+		}
+		for _, event := range d.events {
+			b.WriteString(truncateString(event, max(m.width-8, 20)) + "\n")
+		}
+	}
+	b.WriteString("\nr to reload, ESC to close")
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, modalStyle.Width(max(m.width-4, 72)).Height(0).Render(b.String()), lipgloss.WithWhitespaceChars("░"))
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/99designs/keyring"
+	"golang.org/x/term"
+)
+
+// Registry credentials and the GitHub token can be kept in the OS keyring
+// instead of .env: the macOS Keychain, the Windows Credential Manager, the
+// Secret Service of GNOME or KDE Wallet, or pass. `login` stores them and
+// `logout` removes them. What the keyring holds wins; GITHUB_AUTH_TOKEN and
+// REGISTRY_USERNAME/REGISTRY_PASSWORD are the fallback, e.g. in CI.
+// KEYRING_BACKEND picks a backend; "file" keeps an encrypted file in
+// ~/.config/local-container-registry/keyring, unlocked with KEYRING_PASSWORD.
+
+const keyringService = "local-container-registry"
+
+// githubKeyringKey is the GitHub token's item; registries are
+// "registry:<host>".
+const githubKeyringKey = "github"
+
+func registryKeyringKey(host string) string {
+	return "registry:" + host
+}
+
+// keyringBackends are the backends used unless KEYRING_BACKEND names one.
+// The file backend asks for a password, and keyctl forgets at reboot.
+var keyringBackends = []keyring.BackendType{
+	keyring.KeychainBackend,
+	keyring.WinCredBackend,
+	keyring.SecretServiceBackend,
+	keyring.KWalletBackend,
+	keyring.PassBackend,
+}
+
+// errNoKeyringPassword is the file keyring's password prompt outside
+// login and logout without KEYRING_PASSWORD.
+var errNoKeyringPassword = errors.New("the file keyring needs KEYRING_PASSWORD outside login and logout")
+
+// openKeyring opens the configured keyring; no backend available is an
+// error. Only login and logout, run from a terminal, prompt: elsewhere,
+// like the TUI or serve, the file keyring needs KEYRING_PASSWORD.
+func openKeyring(prompt bool) (keyring.Keyring, error) {
+	backends := keyringBackends
+	if backend := os.Getenv("KEYRING_BACKEND"); backend != "" {
+		backends = []keyring.BackendType{keyring.BackendType(backend)}
+	}
+	password := func(string) (string, error) { return "", errNoKeyringPassword }
+	if value := os.Getenv("KEYRING_PASSWORD"); value != "" {
+		password = keyring.FixedStringPrompt(value)
+	} else if prompt {
+		password = keyring.TerminalPrompt
+	}
+	ring, err := keyring.Open(keyring.Config{
+		ServiceName:              keyringService,
+		AllowedBackends:          backends,
+		KeychainTrustApplication: true,
+		FileDir:                  "~/.config/" + keyringService + "/keyring",
+		FilePasswordFunc:         password,
+		PassPrefix:               keyringService,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("no keyring: %v; set KEYRING_BACKEND to one of %v", err, keyring.AvailableBackends())
+	}
+	return ring, nil
+}
+
+// keyringItems are the items read from the keyring, by key, so it's opened
+// and asked once per process; a missing one is nil.
+var keyringItems struct {
+	sync.Mutex
+	ring   keyring.Keyring
+	err    error
+	opened bool
+	warned bool // a failure was logged
+	items  map[string][]byte
+}
+
+// keyringItem is the keyring's item under key; ok is false when there is
+// none or no keyring. A keyring that fails to open or read is logged once,
+// and the env is the fallback.
+func keyringItem(key string) (data []byte, ok bool) {
+	keyringItems.Lock()
+	defer keyringItems.Unlock()
+	if !keyringItems.opened {
+		keyringItems.ring, keyringItems.err = openKeyring(false)
+		keyringItems.items = make(map[string][]byte)
+		keyringItems.opened = true
+		// Without KEYRING_BACKEND, having no keyring is the CI and
+		// container case the env is for
+		if keyringItems.err != nil && os.Getenv("KEYRING_BACKEND") != "" {
+			log.Printf("⚠️  Using credentials from the env: %v", keyringItems.err)
+			keyringItems.warned = true
+		}
+	}
+	if keyringItems.err != nil {
+		return nil, false
+	}
+	data, cached := keyringItems.items[key]
+	if !cached {
+		item, err := keyringItems.ring.Get(key)
+		switch {
+		case err == nil:
+			data = item.Data
+		case !errors.Is(err, keyring.ErrKeyNotFound) && !keyringItems.warned:
+			log.Printf("⚠️  Using credentials from the env: failed to read the keyring: %v", err)
+			keyringItems.warned = true
+		}
+		keyringItems.items[key] = data
+	}
+	return data, data != nil
+}
+
+// githubToken is the GitHub token in the keyring, else GITHUB_AUTH_TOKEN.
+func githubToken() string {
+	if token, ok := keyringItem(githubKeyringKey); ok {
+		return string(token)
+	}
+	return os.Getenv("GITHUB_AUTH_TOKEN")
+}
+
+// storedCredentials are a registry's credentials in the keyring.
+type storedCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// keyringRegistryCredentials are the credentials stored for host.
+func keyringRegistryCredentials(host string) (username, password string, ok bool) {
+	data, ok := keyringItem(registryKeyringKey(host))
+	if !ok {
+		return "", "", false
+	}
+	var credentials storedCredentials
+	if err := json.Unmarshal(data, &credentials); err != nil || credentials.Username == "" {
+		return "", "", false
+	}
+	return credentials.Username, credentials.Password, true
+}
+
+// runLogin stores a registry's credentials, or the GitHub token with
+// --github, in the keyring.
+func runLogin(args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	github := fs.Bool("github", false, "store the GitHub token instead of registry credentials")
+	username := fs.String("username", "", "registry username (asked when not given)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 || (*github && fs.NArg() > 0) {
+		return fmt.Errorf("usage: login [--username <user>] [registry] | login --github")
+	}
+	ring, err := openKeyring(true)
+	if err != nil {
+		return err
+	}
+
+	if *github {
+		token, err := readPassword("GitHub token: ", false)
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			return fmt.Errorf("login: no token given")
+		}
+		if err := setKeyringItem(ring, githubKeyringKey, "GitHub token", []byte(token)); err != nil {
+			return err
+		}
+		ctx, stop := signalContext()
+		defer stop()
+		ctx, cancel := withBackendTimeout(ctx, backendGitHub)
+		defer cancel()
+		if user, _, err := newGitHubClient().WithAuthToken(token).Users.Get(ctx, ""); err == nil {
+			fmt.Printf("🔑 Stored the GitHub token of %s in the keyring\n", user.GetLogin())
+		} else {
+			fmt.Printf("🔑 Stored the GitHub token in the keyring, but GitHub didn't accept it: %v\n", err)
+		}
+		return nil
+	}
+
+	host := getRegistryHost()
+	if fs.NArg() == 1 {
+		host = fs.Arg(0)
+	}
+	if *username == "" {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("login: pass --username when the password comes from stdin")
+		}
+		fmt.Fprintf(os.Stderr, "Username for %s: ", host)
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read the username: %v", err)
+		}
+		*username = strings.TrimSpace(line)
+	}
+	if *username == "" {
+		return fmt.Errorf("login: no username given")
+	}
+	password, err := readPassword("Password: ", false)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(storedCredentials{Username: *username, Password: password})
+	if err != nil {
+		return err
+	}
+	if err := setKeyringItem(ring, registryKeyringKey(host), "Registry "+host, data); err != nil {
+		return err
+	}
+	fmt.Printf("🔑 Stored the credentials of %s for %s in the keyring\n", *username, host)
+	return nil
+}
+
+// runLogout removes a registry's credentials, or the GitHub token with
+// --github, from the keyring.
+func runLogout(args []string) error {
+	fs := flag.NewFlagSet("logout", flag.ContinueOnError)
+	github := fs.Bool("github", false, "remove the GitHub token instead of registry credentials")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 || (*github && fs.NArg() > 0) {
+		return fmt.Errorf("usage: logout [registry] | logout --github")
+	}
+	ring, err := openKeyring(true)
+	if err != nil {
+		return err
+	}
+	key, name := githubKeyringKey, "the GitHub token"
+	if !*github {
+		host := getRegistryHost()
+		if fs.NArg() == 1 {
+			host = fs.Arg(0)
+		}
+		key, name = registryKeyringKey(host), "the credentials for "+host
+	}
+	if err := ring.Remove(key); errors.Is(err, keyring.ErrKeyNotFound) || os.IsNotExist(err) {
+		fmt.Printf("No %s in the keyring\n", strings.TrimPrefix(name, "the "))
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to remove %s from the keyring: %v", name, err)
+	}
+	fmt.Printf("🔑 Removed %s from the keyring\n", name)
+	return nil
+}
+
+// setKeyringItem stores data under key, labelled for the keyring's UI.
+func setKeyringItem(ring keyring.Keyring, key, label string, data []byte) error {
+	if err := ring.Set(keyring.Item{Key: key, Label: keyringService + ": " + label, Data: data}); err != nil {
+		return fmt.Errorf("failed to store it in the keyring: %v", err)
+	}
+	return nil
+}
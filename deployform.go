@@ -0,0 +1,466 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// deploymentParams are the user-editable settings of a new deployment.
+type deploymentParams struct {
+	Name      string
+	Namespace string
+	Replicas  int32
+	Port      int32
+	Env       containerEnv
+	// PullPolicy is a Kubernetes pull policy or "auto"; empty uses the
+	// configured one
+	PullPolicy string
+	// Service is ClusterIP, NodePort or empty for no Service. IngressHost,
+	// when set, also routes that host to the Service through an Ingress.
+	Service     string
+	IngressHost string
+	// Resources are the container's requests and limits
+	Resources corev1.ResourceRequirements
+	// ReadinessProbe and LivenessProbe are nil for none
+	ReadinessProbe *corev1.Probe
+	LivenessProbe  *corev1.Probe
+	// CreateNamespace creates Namespace first, confirmed in the form when it
+	// didn't exist
+	CreateNamespace bool
+}
+
+// defaultDeploymentParams derives a deployment from an image name the way
+// the create dialog always has: one replica on port 80 in default. The form
+// replaces the port with the one the image exposes once its config is read.
+func defaultDeploymentParams(imageName string) deploymentParams {
+	return deploymentParams{
+		Name:      defaultDeploymentName(imageName),
+		Namespace: "default",
+		Replicas:  1,
+		Port:      80,
+	}
+}
+
+// A new deployment's name also names the Service and Ingress created with
+// it, so it must be a DNS-1035 label, the strictest of their rules: at most
+// 63 lowercase letters, digits and '-', starting with a letter and ending
+// with a letter or digit.
+
+var deploymentNameInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// defaultDeploymentName suggests a valid deployment name for an image, e.g.
+// team-web-v1-2 for team/web:v1.2.
+func defaultDeploymentName(imageName string) string {
+	name := strings.Trim(deploymentNameInvalidChars.ReplaceAllString(strings.ToLower(imageName), "-"), "-")
+	if name == "" || name == "latest" {
+		name = "new-deployment"
+	}
+	if name[0] < 'a' || name[0] > 'z' {
+		name = "app-" + name
+	}
+	return truncateDeploymentName(name, validation.DNS1035LabelMaxLength)
+}
+
+// truncateDeploymentName cuts name to at most n characters, never leaving it
+// ending in '-'.
+func truncateDeploymentName(name string, n int) string {
+	if len(name) > n {
+		name = name[:n]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// validateDeploymentName reports why name can't name a new deployment.
+func validateDeploymentName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("name is required")
+	case len(name) > validation.DNS1035LabelMaxLength:
+		return fmt.Errorf("name is %d characters long, at most %d are allowed", len(name), validation.DNS1035LabelMaxLength)
+	case len(validation.IsDNS1035Label(name)) > 0:
+		return fmt.Errorf("name must be lowercase letters, digits and '-', start with a letter and end with a letter or digit")
+	}
+	return nil
+}
+
+// validateNamespaceName reports why name can't be a namespace, a DNS-1123
+// label.
+func validateNamespaceName(name string) error {
+	if len(validation.IsDNS1123Label(name)) > 0 {
+		return fmt.Errorf("namespace must be at most %d lowercase letters, digits and '-', starting and ending with a letter or digit", validation.DNS1123LabelMaxLength)
+	}
+	return nil
+}
+
+// uniqueDeploymentName is name, or name with the lowest -2, -3, ... suffix
+// that none of the existing deployments in namespace has.
+func uniqueDeploymentName(name, namespace string, existing []TableData) string {
+	taken := make(map[string]bool)
+	for _, deployment := range existing {
+		if deployment.Namespace == namespace {
+			taken[deployment.PodName] = true
+		}
+	}
+	candidate := name
+	for i := 2; taken[candidate]; i++ {
+		suffix := "-" + strconv.Itoa(i)
+		candidate = truncateDeploymentName(name, validation.DNS1035LabelMaxLength-len(suffix)) + suffix
+	}
+	return candidate
+}
+
+// Fields of the create-deployment form, in display order
+const (
+	deployFieldName = iota
+	deployFieldNamespace
+	deployFieldReplicas
+	deployFieldPort
+	deployFieldReadiness
+	deployFieldLiveness
+	deployFieldPullPolicy
+	deployFieldResources
+	deployFieldCPU
+	deployFieldMemory
+	deployFieldEnv
+	deployFieldService
+	deployFieldIngressHost
+	deployFieldCount
+)
+
+var deployFieldLabels = [deployFieldCount]string{"Name", "Namespace", "Replicas", "Container port", "Readiness probe", "Liveness probe", "Pull policy", "Resources", "CPU request/limit", "Memory request/limit", "Env (KEY=value, ...)", "Service", "Ingress host"}
+
+// deployFieldKeys name the fields in saved deploy profiles
+var deployFieldKeys = [deployFieldCount]string{"name", "namespace", "replicas", "port", "readiness", "liveness", "pullPolicy", "resources", "cpu", "memory", "env", "service", "ingressHost"}
+
+var formErrorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5F87"))
+
+// initDeployForm fills the form with the defaults for the selected image.
+func (m *model) initDeployForm() {
+	defaults := defaultDeploymentParams(m.selectedImage)
+	values := [deployFieldCount]string{
+		uniqueDeploymentName(defaults.Name, defaults.Namespace, m.deployments),
+		defaults.Namespace,
+		strconv.Itoa(int(defaults.Replicas)),
+		strconv.Itoa(int(defaults.Port)),
+		defaultReadinessProbe,
+		defaultLivenessProbe,
+		configuredPullPolicy(),
+		defaultResourcePreset(),
+		"",
+		"",
+		"",
+		"none",
+		"",
+	}
+	// What was typed the last time the image was deployed, with a name that
+	// is free now
+	if m.deployProfile != nil && len(m.deployProfile.Form) > 0 {
+		for i, key := range deployFieldKeys {
+			if value, ok := m.deployProfile.Form[key]; ok {
+				values[i] = value
+			}
+		}
+		values[deployFieldName] = uniqueDeploymentName(values[deployFieldName], values[deployFieldNamespace], m.deployments)
+	}
+
+	m.deployInputs = make([]textinput.Model, deployFieldCount)
+	for i := range m.deployInputs {
+		input := textinput.New()
+		input.Prompt = ""
+		input.Width = 40
+		input.SetValue(values[i])
+		m.deployInputs[i] = input
+	}
+	m.deployInputs[deployFieldReadiness].Placeholder = "none, tcp or http /healthz"
+	m.deployInputs[deployFieldLiveness].Placeholder = "none, tcp or http /healthz"
+	m.deployInputs[deployFieldResources].Placeholder = "none, small, medium or large"
+	m.deployInputs[deployFieldEnv].Placeholder = "LOG_LEVEL=debug, DB_PASSWORD=secret:db/password"
+	m.deployInputs[deployFieldService].Placeholder = "none, ClusterIP or NodePort"
+	m.deployInputs[deployFieldIngressHost].Placeholder = "web.localtest.me"
+	m.showPresetPlaceholders()
+	m.deployFocus = 0
+	m.deployInputs[0].Focus()
+	m.deployFormErr = ""
+	m.missingNamespace = nil
+	m.deployExposedPorts = nil
+}
+
+type exposedPortsMsg struct {
+	image string
+	ports []string
+}
+
+// loadExposedPorts reads the ports the selected image's config exposes. An
+// image that can't be read, e.g. one only in the local Docker daemon, keeps
+// the default port.
+func (m model) loadExposedPorts() tea.Cmd {
+	ctx, image := m.tabCtx, m.selectedImage
+	return func() tea.Msg {
+		ctx, cancel := withBackendTimeout(ctx, backendRegistry)
+		defer cancel()
+		inspection, err := inspectRegistryImage(ctx, image)
+		if err != nil {
+			return nil
+		}
+		return exposedPortsMsg{image: image, ports: inspection.exposedPorts}
+	}
+}
+
+// updateExposedPorts shows the image's exposed ports in the form and makes
+// the first TCP one the container port, unless it was edited already.
+func (m model) updateExposedPorts(msg exposedPortsMsg) (tea.Model, tea.Cmd) {
+	if !m.showModal || m.modalStep != 1 || msg.image != m.selectedImage {
+		return m, nil
+	}
+	m.deployExposedPorts = msg.ports
+	port, ok := exposedContainerPort(msg.ports)
+	if ok && m.deployInputs[deployFieldPort].Value() == strconv.Itoa(int(defaultDeploymentParams(m.selectedImage).Port)) {
+		m.deployInputs[deployFieldPort].SetValue(strconv.Itoa(int(port)))
+	}
+	return m, nil
+}
+
+// exposedContainerPort is the lowest TCP port of ExposedPorts entries like
+// 8080/tcp or 53/udp; a port without a protocol is TCP.
+func exposedContainerPort(ports []string) (int32, bool) {
+	var lowest int
+	for _, port := range ports {
+		number, protocol, _ := strings.Cut(port, "/")
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 1 || n > 65535 || (protocol != "" && !strings.EqualFold(protocol, "tcp")) {
+			continue
+		}
+		if lowest == 0 || n < lowest {
+			lowest = n
+		}
+	}
+	return int32(lowest), lowest != 0
+}
+
+func (m *model) focusDeployField(field int) {
+	m.deployInputs[m.deployFocus].Blur()
+	m.deployFocus = (field + deployFieldCount) % deployFieldCount
+	m.deployInputs[m.deployFocus].Focus()
+}
+
+// checkDeploymentName reports why the form can't create a deployment called
+// name in namespace: the name is invalid, or one of the deployments listed
+// in the dialog has it already.
+func (m model) checkDeploymentName(name, namespace string) error {
+	if err := validateDeploymentName(name); err != nil {
+		return err
+	}
+	if uniqueDeploymentName(name, namespace, m.deployments) != name {
+		return fmt.Errorf("deployment %s already exists in %s", name, namespace)
+	}
+	return nil
+}
+
+// deployFormParams validates the form into deployment parameters.
+func (m model) deployFormParams() (deploymentParams, error) {
+	value := func(field int) string { return strings.TrimSpace(m.deployInputs[field].Value()) }
+
+	params := deploymentParams{Name: value(deployFieldName), Namespace: value(deployFieldNamespace)}
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if err := m.checkDeploymentName(params.Name, params.Namespace); err != nil {
+		return params, err
+	}
+	if err := validateNamespaceName(params.Namespace); err != nil {
+		return params, err
+	}
+	replicas, err := strconv.Atoi(value(deployFieldReplicas))
+	if err != nil || replicas < 0 {
+		return params, fmt.Errorf("replicas must be a number of 0 or more")
+	}
+	params.Replicas = int32(replicas)
+	port, err := strconv.Atoi(value(deployFieldPort))
+	if err != nil || port < 1 || port > 65535 {
+		return params, fmt.Errorf("container port must be between 1 and 65535")
+	}
+	params.Port = int32(port)
+	if params.ReadinessProbe, err = parseProbe("readiness", value(deployFieldReadiness), params.Port); err != nil {
+		return params, err
+	}
+	if params.LivenessProbe, err = parseProbe("liveness", value(deployFieldLiveness), params.Port); err != nil {
+		return params, err
+	}
+	if params.PullPolicy, err = parsePullPolicy(value(deployFieldPullPolicy)); err != nil {
+		return params, err
+	}
+	preset, err := findResourcePreset(value(deployFieldResources))
+	if err != nil {
+		return params, err
+	}
+	if params.Resources, err = preset.requirements(value(deployFieldCPU), value(deployFieldMemory)); err != nil {
+		return params, err
+	}
+	if params.Env, err = parseContainerEnv(value(deployFieldEnv)); err != nil {
+		return params, err
+	}
+	if params.Service, err = parseServiceType(value(deployFieldService)); err != nil {
+		return params, err
+	}
+	params.IngressHost = value(deployFieldIngressHost)
+	if params.IngressHost != "" && params.Service == "" {
+		// An Ingress needs a Service to route to
+		params.Service = "ClusterIP"
+	}
+	return params, nil
+}
+
+type namespaceCheckMsg struct {
+	params deploymentParams
+	exists bool
+	err    error
+}
+
+// checkDeployNamespace looks up the namespace the form creates the
+// deployment in, so a missing one can be offered for creation rather than
+// failing the deploy.
+func (m model) checkDeployNamespace(params deploymentParams) tea.Cmd {
+	ctx := m.tabCtx
+	return func() tea.Msg {
+		ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+		defer cancel()
+		exists, err := newCluster(ctx).NamespaceExists(ctx, params.Namespace)
+		return namespaceCheckMsg{params: params, exists: exists, err: err}
+	}
+}
+
+// updateNamespaceCheck creates the deployment once its namespace is known to
+// exist, and otherwise asks whether to create the namespace too. When the
+// lookup fails, e.g. for want of RBAC to read namespaces, the create goes
+// ahead and reports any error itself.
+func (m model) updateNamespaceCheck(msg namespaceCheckMsg) (tea.Model, tea.Cmd) {
+	if !m.showModal || m.modalStep != 1 {
+		return m, nil
+	}
+	if !msg.exists && msg.err == nil {
+		m.missingNamespace = &msg.params
+		return m, nil
+	}
+	m.showModal = false
+	m.modalStep = 0
+	return m, m.createNewDeployment(m.selectedImage, msg.params)
+}
+
+// updateMissingNamespace handles the answer to creating a missing namespace.
+func (m model) updateMissingNamespace(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "y":
+		params := *m.missingNamespace
+		params.CreateNamespace = true
+		m.missingNamespace = nil
+		m.showModal = false
+		m.modalStep = 0
+		return m, m.createNewDeployment(m.selectedImage, params)
+	case "n", "esc":
+		// Back to the form to type another namespace
+		m.missingNamespace = nil
+		m.focusDeployField(deployFieldNamespace)
+	}
+	return m, nil
+}
+
+// updateDeployForm handles keys while the create-deployment form is shown.
+// Every printable key goes to the focused field, so digits no longer pick
+// dialog options here.
+func (m model) updateDeployForm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.missingNamespace != nil {
+		return m.updateMissingNamespace(msg)
+	}
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc":
+		// Back to deployment selection
+		m.modalStep = 0
+		return m, nil
+	case "tab", "down":
+		m.focusDeployField(m.deployFocus + 1)
+		return m, nil
+	case "shift+tab", "up":
+		m.focusDeployField(m.deployFocus - 1)
+		return m, nil
+	case "enter":
+		params, err := m.deployFormParams()
+		if err != nil {
+			m.deployFormErr = err.Error()
+			// Offer a name that works in its place, still editable
+			if m.checkDeploymentName(params.Name, params.Namespace) != nil {
+				suggested := uniqueDeploymentName(defaultDeploymentName(params.Name), params.Namespace, m.deployments)
+				m.deployInputs[deployFieldName].SetValue(suggested)
+				m.deployFormErr += "; suggested " + suggested + " instead"
+			}
+			return m, nil
+		}
+		return m, m.checkDeployNamespace(params)
+	}
+
+	var cmd tea.Cmd
+	m.deployInputs[m.deployFocus], cmd = m.deployInputs[m.deployFocus].Update(msg)
+	m.deployFormErr = ""
+	if m.deployFocus == deployFieldResources {
+		m.showPresetPlaceholders()
+	}
+	return m, cmd
+}
+
+// showPresetPlaceholders shows the chosen preset's values in the CPU and
+// memory fields until they are overridden.
+func (m *model) showPresetPlaceholders() {
+	if preset, err := findResourcePreset(m.deployInputs[deployFieldResources].Value()); err == nil {
+		m.deployInputs[deployFieldCPU].Placeholder = preset.cpu
+		m.deployInputs[deployFieldMemory].Placeholder = preset.memory
+	}
+}
+
+func (m model) renderDeployForm() string {
+	var content strings.Builder
+	content.WriteString("Create New Deployment\n\n")
+	content.WriteString(fmt.Sprintf("Image: %s\n", m.selectedImage))
+	if len(m.deployExposedPorts) > 0 {
+		content.WriteString(fmt.Sprintf("Exposes: %s\n", strings.Join(m.deployExposedPorts, ", ")))
+	}
+	if m.deployProfile != nil && len(m.deployProfile.Form) > 0 {
+		content.WriteString("Filled in from the last deploy of this image\n")
+	}
+	content.WriteString("\n")
+	for i, input := range m.deployInputs {
+		prefix := "  "
+		if i == m.deployFocus {
+			prefix = "→ "
+		}
+		content.WriteString(fmt.Sprintf("%s%-22s %s\n", prefix, deployFieldLabels[i]+":", input.View()))
+	}
+	content.WriteString("\nProbes check the container port unless given one, e.g. http /healthz :8081 delay=10s.\n")
+	content.WriteString("Pull policy auto uses IfNotPresent when the nodes can reach the registry, Never otherwise.\n")
+	content.WriteString("Env takes KEY=secret:name/key or KEY=configmap:name/key, and secret:name for all keys.\n")
+	content.WriteString("CPU and memory override the preset, e.g. 250m/500m and 256Mi/512Mi.\n")
+	content.WriteString("An ingress host also creates a ClusterIP Service if none is chosen.\n")
+	if m.deployFormErr != "" {
+		content.WriteString("\n" + formErrorStyle.Render("❌ "+m.deployFormErr) + "\n")
+	}
+	if m.missingNamespace != nil {
+		fmt.Fprintf(&content, "\nNamespace %s doesn't exist. Create it along with the deployment?\n", m.missingNamespace.Namespace)
+		content.WriteString("\ny to create it, n or ESC to change the namespace")
+		return modalStyle.Width(72).Render(content.String())
+	}
+	content.WriteString("\nTab/↑/↓ to move between fields, Enter to create, ESC to go back")
+	// Labels and inputs don't fit the default modal width
+	return modalStyle.Width(72).Render(content.String())
+}
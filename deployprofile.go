@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Deploying an image from the TUI saves how it was deployed in the
+// database's deploy_profiles table, per image regardless of tag, so the
+// next build goes out the same way: the deploy dialog starts at the
+// deployment it went to last, with the container picked then, and the
+// create form is filled with what was typed into it. Env values are kept
+// out of the table, as they may be secrets: only the secret: and
+// configmap: references of the env field are remembered. Without a
+// database nothing is remembered.
+
+// deployProfile is how an image was last deployed.
+type deployProfile struct {
+	Namespace  string `json:"namespace"`
+	Deployment string `json:"deployment"`
+	Container  string `json:"container,omitempty"`
+	// Form is the create form as last submitted, by deployFieldKeys
+	Form map[string]string `json:"form,omitempty"`
+}
+
+type deployProfileMsg struct {
+	image   string
+	profile *deployProfile
+}
+
+// deployProfileKey is the image without tag or digest, so every build of it
+// shares a profile.
+func deployProfileKey(image string) string {
+	if strings.HasPrefix(image, "sha256:") {
+		// An image only known by its ID
+		return image
+	}
+	host, repository, _ := splitRegistryReference(image)
+	return host + "/" + repository
+}
+
+// loadDeployProfile returns the profile of an image, nil when there is none.
+func loadDeployProfile(ctx context.Context, image string) *deployProfile {
+	if dataStore(ctx) == nil {
+		return nil
+	}
+	row, err := dataStore(ctx).LoadDeployProfile(ctx, deployProfileKey(image))
+	if err != nil {
+		return nil
+	}
+	var profile deployProfile
+	if json.Unmarshal(row.Content, &profile) != nil {
+		return nil
+	}
+	return &profile
+}
+
+// saveDeployProfile keeps profile as how image was deployed. Like history,
+// this is best effort.
+func saveDeployProfile(ctx context.Context, image string, profile deployProfile) {
+	if dataStore(ctx) == nil {
+		return
+	}
+	content, err := json.Marshal(profile)
+	if err != nil {
+		return
+	}
+	if err := dataStore(ctx).SaveDeployProfile(ctx, deployProfileKey(image), content); err != nil {
+		log.Printf("failed to save the deploy profile of %s: %v", image, err)
+	}
+}
+
+func (m model) loadDeployProfile() tea.Cmd {
+	ctx, image := m.tabCtx, m.selectedImage
+	return func() tea.Msg {
+		return deployProfileMsg{image: image, profile: loadDeployProfile(ctx, image)}
+	}
+}
+
+func (m model) updateDeployProfile(msg deployProfileMsg) (tea.Model, tea.Cmd) {
+	if !m.showModal || msg.image != m.selectedImage {
+		return m, nil
+	}
+	m.deployProfile = msg.profile
+	m.selectProfileDeployment()
+	return m, nil
+}
+
+// selectProfileDeployment moves the deployment picker to where the image
+// was deployed last, once both the profile and the deployments are loaded
+// and unless another entry was picked already.
+func (m *model) selectProfileDeployment() {
+	if m.deployProfile == nil || !m.showModal || m.modalStep != 0 || m.selectedDeployment != -1 {
+		return
+	}
+	for i, deployment := range m.deployments {
+		if deployment.PodName == m.deployProfile.Deployment && deployment.Namespace == m.deployProfile.Namespace {
+			m.selectedDeployment = i
+			return
+		}
+	}
+}
+
+// isProfileDeployment reports whether the image was deployed to deployment
+// last.
+func (m model) isProfileDeployment(deployment TableData) bool {
+	return m.deployProfile != nil && deployment.PodName == m.deployProfile.Deployment && deployment.Namespace == m.deployProfile.Namespace
+}
+
+// formProfile is the profile of a deployment created from the form.
+func (m model) formProfile(params deploymentParams) deployProfile {
+	profile := deployProfile{Namespace: params.Namespace, Deployment: params.Name, Form: make(map[string]string)}
+	for i, input := range m.deployInputs {
+		profile.Form[deployFieldKeys[i]] = strings.TrimSpace(input.Value())
+	}
+	profile.Form[deployFieldKeys[deployFieldEnv]] = envReferences(profile.Form[deployFieldKeys[deployFieldEnv]])
+	return profile
+}
+
+// envReferences are the entries of env vars as typed into the dialogs that
+// refer to Secrets and ConfigMaps, without those with a value.
+func envReferences(value string) string {
+	var references []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		reference := entry
+		if _, val, ok := strings.Cut(entry, "="); ok {
+			reference = strings.TrimSpace(val)
+		}
+		if _, _, ok := cutEnvReference(reference); ok {
+			references = append(references, entry)
+		}
+	}
+	return strings.Join(references, ", ")
+}
+
+// deployedProfile is the profile of a deploy to an existing deployment,
+// keeping the create form of the last profile for the next new deployment.
+func (m model) deployedProfile(deploymentName, namespace, container string) deployProfile {
+	profile := deployProfile{Namespace: namespace, Deployment: deploymentName, Container: container}
+	if m.deployProfile != nil {
+		profile.Form = m.deployProfile.Form
+	}
+	return profile
+}
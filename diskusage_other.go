@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+func diskUsage(path string) (float64, error) {
+	return 0, errors.New("disk usage isn't supported on " + runtime.GOOS)
+}
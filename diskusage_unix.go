@@ -0,0 +1,19 @@
+//go:build linux || darwin || freebsd
+
+package main
+
+import "syscall"
+
+// diskUsage is how full the filesystem holding path is, in percent.
+func diskUsage(path string) (float64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	if stat.Blocks == 0 {
+		return 0, nil
+	}
+	used := uint64(stat.Blocks) - uint64(stat.Bfree)
+	// Like df, count the blocks reserved for root as unavailable
+	return float64(used) * 100 / float64(used+uint64(stat.Bavail)), nil
+}
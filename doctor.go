@@ -0,0 +1,266 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// doctorCheck is one backend doctor probes. hint turns a failure into what
+// to do about it.
+type doctorCheck struct {
+	name  string
+	check func(ctx context.Context) (string, error) // detail on success
+	hint  func(err error) string
+}
+
+type doctorResult struct {
+	Name      string `json:"name"`
+	OK        bool   `json:"ok"`
+	LatencyMS int64  `json:"latency_ms"`
+	Detail    string `json:"detail,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Hint      string `json:"hint,omitempty"`
+}
+
+// doctorAliases lets --check use the names the rest of the tool uses.
+var doctorAliases = map[string]string{"kubernetes": "k8s", "database": "db", "mysql": "db"}
+
+// errorHint returns the hint of the first pattern found in err.
+func errorHint(err error, hints ...[2]string) string {
+	for _, hint := range hints {
+		if strings.Contains(err.Error(), hint[0]) {
+			return hint[1]
+		}
+	}
+	return ""
+}
+
+func doctorChecks() []doctorCheck {
+	return []doctorCheck{
+		{
+			name: "registry",
+			check: func(ctx context.Context) (string, error) {
+				client := newRegistryClient(ctx, getRegistryHost())
+				if err := client.Ping(ctx); err != nil {
+					return "", err
+				}
+				detail := fmt.Sprintf("%s (%s)", client.Host(), client.Flavor(ctx))
+				if proxy := describeProxy(backendRegistry, client.BaseURL()); proxy != "direct" {
+					detail += " via proxy " + proxy
+				}
+				if quirks := client.Quirks(ctx); len(quirks.Explain()) > 0 {
+					detail += "; " + strings.Join(quirks.Explain(), "; ")
+				}
+				return detail, nil
+			},
+			hint: func(err error) string {
+				return cmp.Or(errorHint(err,
+					[2]string{"connection refused", "Start the registry with `docker compose up -d registry`, or point REGISTRY_HOST at yours"},
+					[2]string{"no such host", "Check REGISTRY_HOST; the name doesn't resolve"},
+					[2]string{"401", "Run `login` for the registry, or set REGISTRY_USERNAME and REGISTRY_PASSWORD"},
+					[2]string{"HTTP response to HTTPS client", "The registry speaks plain HTTP; set REGISTRY_HOST to host:port without https://"},
+					[2]string{"certificate", "Trust the registry's certificate, or use http:// for a plain-HTTP registry"},
+					[2]string{"proxyconnect", "The proxy refused the connection; check HTTPS_PROXY or REGISTRY_PROXY, or add the registry to NO_PROXY"},
+				), "Check that REGISTRY_HOST is reachable from here")
+			},
+		},
+		{
+			name: "k8s",
+			check: func(ctx context.Context) (string, error) {
+				if !kubernetesEnabled() {
+					return "skipped, " + errKubernetesDisabled.Error(), nil
+				}
+				ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+				defer cancel()
+				version, err := newCluster(ctx).ServerVersion(ctx)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("cluster %s, Kubernetes %s", currentCluster().displayName(), version), nil
+			},
+			hint: func(err error) string {
+				if errors.Is(err, errKubeconfigNotFound) {
+					return fmt.Sprintf("No kubeconfig at %s; set KUBECONFIG or start a cluster, e.g. `minikube start`", defaultKubeconfig())
+				}
+				return cmp.Or(errorHint(err,
+					[2]string{"i/o timeout", "The API server doesn't answer; from a container set KUBERNETES_CONTROL_PLANE to an address it can reach"},
+					[2]string{"connection refused", "The cluster is down; check `minikube status` or start it"},
+					[2]string{"no such host", "The kubeconfig's server name doesn't resolve from here; set KUBERNETES_CONTROL_PLANE"},
+					[2]string{"Unauthorized", "The kubeconfig's credentials were rejected; refresh them, e.g. `minikube update-context`"},
+					[2]string{"certificate", "The API server's certificate doesn't match; refresh the kubeconfig"},
+					[2]string{"no such file or directory", "A certificate the kubeconfig names isn't mounted here; mount its directory and map it with KUBECONFIG_PATH_MAP"},
+				), "Check that `kubectl get pods` works with the same KUBECONFIG")
+			},
+		},
+		{
+			name: "db",
+			check: func(ctx context.Context) (string, error) {
+				handle, err := openDatabase()
+				if err != nil {
+					return "", err
+				}
+				defer handle.Close()
+				ctx, cancel := withBackendTimeout(ctx, backendDatabase)
+				defer cancel()
+				if err := handle.PingContext(ctx); err != nil {
+					return "", err
+				}
+				return "connected", nil
+			},
+			hint: func(err error) string {
+				return cmp.Or(errorHint(err,
+					[2]string{"connection refused", "Start MySQL with `docker compose up -d db`, or point MYSQL_HOST at yours"},
+					[2]string{"Access denied", "Check MYSQL_USER and MYSQL_ROOT_PASSWORD"},
+					[2]string{"Unknown database", "Create the database or set MYSQL_DATABASE to an existing one"},
+				), "Check MYSQL_HOST; history and commits aren't stored without the database")
+			},
+		},
+		{
+			name: "github",
+			check: func(ctx context.Context) (string, error) {
+				owner, repo := os.Getenv("GITHUB_OWNER"), os.Getenv("GITHUB_REPO")
+				if owner == "" || repo == "" {
+					return "", fmt.Errorf("GITHUB_OWNER or GITHUB_REPO not set")
+				}
+				ctx, cancel := withBackendTimeout(ctx, backendGitHub)
+				defer cancel()
+				client := newGitHubClient()
+				_, resp, err := client.Repositories.Get(ctx, owner, repo)
+				if err != nil {
+					return "", err
+				}
+				detail := fmt.Sprintf("%s/%s, %d of %d API calls left", owner, repo, resp.Rate.Remaining, resp.Rate.Limit)
+				if proxy := describeProxy(backendGitHub, client.BaseURL.String()); proxy != "direct" {
+					detail += " via proxy " + proxy
+				}
+				return detail, nil
+			},
+			hint: func(err error) string {
+				return cmp.Or(errorHint(err,
+					[2]string{"not set", "Set GITHUB_OWNER, GITHUB_REPO and GITHUB_AUTH_TOKEN in .env"},
+					[2]string{"401", "The GitHub token is invalid or expired; create a new one and run `login --github`"},
+					[2]string{"404", "Check GITHUB_OWNER and GITHUB_REPO, and that the token can read the repository"},
+					[2]string{"rate limit", "Set GITHUB_AUTH_TOKEN to raise the API rate limit"},
+					[2]string{"proxyconnect", "The proxy refused the connection; check HTTPS_PROXY or GITHUB_PROXY"},
+				), "Check that api.github.com is reachable")
+			},
+		},
+		{
+			name: "docker",
+			check: func(ctx context.Context) (string, error) {
+				ctx, cancel := withBackendTimeout(ctx, backendDocker)
+				defer cancel()
+				version, err := containerRuntime(ctx).Version(ctx)
+				if err != nil {
+					return "", err
+				}
+				return "Docker " + version, nil
+			},
+			hint: func(err error) string {
+				return cmp.Or(errorHint(err,
+					[2]string{"executable file not found", "Install Docker; local images and pulls need the docker CLI"},
+					[2]string{"permission denied", "Add your user to the docker group, or run with access to the Docker socket"},
+					[2]string{"Cannot connect to the Docker daemon", "Start Docker, or set DOCKER_HOST to a running daemon"},
+				), "Check that `docker version` works")
+			},
+		},
+	}
+}
+
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	only := fs.String("check", "registry,k8s,db,github,docker", "comma-separated checks to run")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	all := doctorChecks()
+	var checks []doctorCheck
+	for _, name := range strings.Split(*only, ",") {
+		name = strings.TrimSpace(name)
+		if alias, ok := doctorAliases[name]; ok {
+			name = alias
+		}
+		found := false
+		for _, check := range all {
+			if check.name == name {
+				checks, found = append(checks, check), true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown check %q (have registry, k8s, db, github, docker)", name)
+		}
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	ctx = withoutRetries(ctx)
+	fixKubeconfigPaths()
+
+	results := runDoctorChecks(ctx, checks)
+	failed := 0
+	for _, result := range results {
+		if !result.OK {
+			failed++
+		}
+	}
+	if *asJSON {
+		status := "ok"
+		if failed > 0 {
+			status = "failed"
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(struct {
+			Status string         `json:"status"`
+			Checks []doctorResult `json:"checks"`
+		}{status, results}); err != nil {
+			return err
+		}
+	} else {
+		for _, result := range results {
+			if result.OK {
+				fmt.Printf("✅ %-9s %5dms  %s\n", result.Name, result.LatencyMS, result.Detail)
+				continue
+			}
+			fmt.Printf("❌ %-9s %5dms  %s\n", result.Name, result.LatencyMS, result.Error)
+			fmt.Printf("   💡 %s\n", result.Hint)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+// runDoctorChecks runs checks concurrently, so they take as long as the
+// slowest backend.
+func runDoctorChecks(ctx context.Context, checks []doctorCheck) []doctorResult {
+	results := make([]doctorResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			detail, err := check.check(ctx)
+			results[i] = doctorResult{Name: check.name, OK: err == nil, LatencyMS: time.Since(start).Milliseconds(), Detail: detail}
+			if err != nil {
+				results[i].Error = err.Error()
+				results[i].Hint = check.hint(err)
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
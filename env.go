@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/backend"
+)
+
+// Env vars are typed into the deploy dialogs as a comma-separated list:
+//
+//	LOG_LEVEL=debug                  a value
+//	DB_PASSWORD=secret:db/password   a key of a Secret
+//	FEATURES=configmap:app/features  a key of a ConfigMap
+//	secret:db, configmap:app         every key of a Secret or ConfigMap
+//
+// The last form is envFrom; values of the env entries override it.
+
+// containerEnv is a container's env and envFrom.
+type containerEnv struct {
+	Env     []corev1.EnvVar        `json:"env,omitempty"`
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+}
+
+// parseContainerEnv reads env vars as typed into the dialogs.
+func parseContainerEnv(value string) (containerEnv, error) {
+	var env containerEnv
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(entry, "=")
+		if !ok {
+			source, err := parseEnvSource(entry)
+			if err != nil {
+				return env, err
+			}
+			env.EnvFrom = append(env.EnvFrom, source)
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return env, fmt.Errorf("env %q must be KEY=value", entry)
+		}
+		envVar, err := parseEnvVar(name, strings.TrimSpace(val))
+		if err != nil {
+			return env, err
+		}
+		env.Env = append(env.Env, envVar)
+	}
+	return env, nil
+}
+
+// parseEnvVar reads the value of KEY=value, which may refer to a key of a
+// Secret or ConfigMap.
+func parseEnvVar(name, value string) (corev1.EnvVar, error) {
+	kind, ref, ok := cutEnvReference(value)
+	if !ok {
+		return corev1.EnvVar{Name: name, Value: value}, nil
+	}
+	object, key, _ := strings.Cut(ref, "/")
+	if object == "" || key == "" {
+		return corev1.EnvVar{}, fmt.Errorf("env %s=%s must name the key too, e.g. %s:name/key", name, value, kind)
+	}
+	selector := corev1.LocalObjectReference{Name: object}
+	if kind == "secret" {
+		return corev1.EnvVar{Name: name, ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: selector, Key: key}}}, nil
+	}
+	return corev1.EnvVar{Name: name, ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: selector, Key: key}}}, nil
+}
+
+// parseEnvSource reads secret:name or configmap:name, every key of the
+// object as an env var.
+func parseEnvSource(entry string) (corev1.EnvFromSource, error) {
+	kind, name, ok := cutEnvReference(entry)
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return corev1.EnvFromSource{}, fmt.Errorf("env %q must be KEY=value, secret:name or configmap:name", entry)
+	}
+	selector := corev1.LocalObjectReference{Name: name}
+	if kind == "secret" {
+		return corev1.EnvFromSource{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: selector}}, nil
+	}
+	return corev1.EnvFromSource{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: selector}}, nil
+}
+
+// cutEnvReference splits secret:... and configmap:..., in any case, into the
+// kind and the rest.
+func cutEnvReference(value string) (kind, ref string, ok bool) {
+	for _, kind := range []string{"secret", "configmap"} {
+		if len(value) > len(kind) && strings.EqualFold(value[:len(kind)+1], kind+":") {
+			return kind, strings.TrimSpace(value[len(kind)+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// formatContainerEnv is a container's env as typed into the dialogs, with
+// the entries that can't be typed, e.g. fieldRef values or optional and
+// prefixed references, returned apart as kept.
+func formatContainerEnv(env containerEnv) (text string, kept containerEnv) {
+	var entries []string
+	for _, source := range env.EnvFrom {
+		switch {
+		case source.Prefix != "":
+			kept.EnvFrom = append(kept.EnvFrom, source)
+		case source.SecretRef != nil && !isOptional(source.SecretRef.Optional):
+			entries = append(entries, "secret:"+source.SecretRef.Name)
+		case source.ConfigMapRef != nil && !isOptional(source.ConfigMapRef.Optional):
+			entries = append(entries, "configmap:"+source.ConfigMapRef.Name)
+		default:
+			kept.EnvFrom = append(kept.EnvFrom, source)
+		}
+	}
+	for _, envVar := range env.Env {
+		from := envVar.ValueFrom
+		switch {
+		case from == nil:
+			if _, _, isReference := cutEnvReference(envVar.Value); isReference || strings.Contains(envVar.Value, ",") {
+				kept.Env = append(kept.Env, envVar)
+				continue
+			}
+			entries = append(entries, envVar.Name+"="+envVar.Value)
+		case from.SecretKeyRef != nil && !isOptional(from.SecretKeyRef.Optional):
+			entries = append(entries, fmt.Sprintf("%s=secret:%s/%s", envVar.Name, from.SecretKeyRef.Name, from.SecretKeyRef.Key))
+		case from.ConfigMapKeyRef != nil && !isOptional(from.ConfigMapKeyRef.Optional):
+			entries = append(entries, fmt.Sprintf("%s=configmap:%s/%s", envVar.Name, from.ConfigMapKeyRef.Name, from.ConfigMapKeyRef.Key))
+		default:
+			kept.Env = append(kept.Env, envVar)
+		}
+	}
+	return strings.Join(entries, ", "), kept
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
+
+// editedEnv is the env a deploy sets on a container whose env was edited in
+// the dialog: the entries the dialog couldn't show, kept first so values
+// like $(POD_IP) still resolve, then the edited ones.
+func editedEnv(current corev1.Container, edited containerEnv) containerEnv {
+	_, kept := formatContainerEnv(containerEnv{Env: current.Env, EnvFrom: current.EnvFrom})
+	return containerEnv{
+		Env:     append(kept.Env, edited.Env...),
+		EnvFrom: append(kept.EnvFrom, edited.EnvFrom...),
+	}
+}
+
+// envNames lists what an env sets for the history, without the values,
+// which may be secret.
+func envNames(env containerEnv) string {
+	var names []string
+	for _, source := range env.EnvFrom {
+		switch {
+		case source.SecretRef != nil:
+			names = append(names, "secret:"+source.SecretRef.Name)
+		case source.ConfigMapRef != nil:
+			names = append(names, "configmap:"+source.ConfigMapRef.Name)
+		}
+	}
+	for _, envVar := range env.Env {
+		names = append(names, envVar.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+// missingEnvReferences lists the Secrets and ConfigMaps, and keys of them,
+// that a container's env refers to but namespace doesn't have. Pods of such
+// a container fail with CreateContainerConfigError.
+func missingEnvReferences(ctx context.Context, cluster backend.ClusterAPI, namespace string, container corev1.Container) []string {
+	var missing []string
+	for _, ref := range configReferences(corev1.PodSpec{Containers: []corev1.Container{container}}) {
+		if ref.optional {
+			continue
+		}
+		var data map[string]bool
+		var err error
+		if ref.kind == "Secret" {
+			var secret *corev1.Secret
+			if secret, err = cluster.Secret(ctx, namespace, ref.name); err == nil {
+				data = make(map[string]bool)
+				for key := range secret.Data {
+					data[key] = true
+				}
+			}
+		} else {
+			var configMap *corev1.ConfigMap
+			if configMap, err = cluster.ConfigMap(ctx, namespace, ref.name); err == nil {
+				data = make(map[string]bool)
+				for key := range configMap.Data {
+					data[key] = true
+				}
+				for key := range configMap.BinaryData {
+					data[key] = true
+				}
+			}
+		}
+		switch {
+		case apierrors.IsNotFound(err):
+			missing = append(missing, fmt.Sprintf("%s %s doesn't exist in %s", ref.kind, ref.name, namespace))
+		case err == nil && ref.key != "" && !data[ref.key]:
+			missing = append(missing, fmt.Sprintf("%s %s has no key %s", ref.kind, ref.name, ref.key))
+		}
+	}
+	return missing
+}
+
+// editDeployEnv opens the env editor of the confirmation step, filled with
+// the container's env or the edit made before.
+func (m model) editDeployEnv() (tea.Model, tea.Cmd) {
+	m.deployEnvErr = ""
+	switch {
+	case m.selectedDeployment < 0 || m.selectedDeployment >= len(m.deployments):
+		return m, nil
+	case gitopsEnabled():
+		m.deployEnvErr = "GitOps mode exports only the image; change env in the repository"
+		return m, nil
+	case m.deployments[m.selectedDeployment].HelmRelease != "":
+		m.deployEnvErr = "The Helm chart sets the env; change it in the release's values"
+		return m, nil
+	case m.deployPreview == nil || m.deployPreview.err != nil:
+		m.deployEnvErr = "The deployment's current env hasn't loaded"
+		return m, nil
+	}
+	text := m.deployPreview.env
+	if m.deployEnv != nil {
+		text, _ = formatContainerEnv(*m.deployEnv)
+	}
+	m.deployEnvInput = textinput.New()
+	m.deployEnvInput.Prompt = ""
+	m.deployEnvInput.Width = 64
+	m.deployEnvInput.Placeholder = "LOG_LEVEL=debug, DB_PASSWORD=secret:db/password"
+	m.deployEnvInput.SetValue(text)
+	m.editingDeployEnv = true
+	return m, m.deployEnvInput.Focus()
+}
+
+// updateDeployEnv handles keys while the env editor is open. Enter keeps the
+// edit for the deploy and recomputes the changes shown.
+func (m model) updateDeployEnv(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc":
+		m.editingDeployEnv = false
+		m.deployEnvErr = ""
+		return m, nil
+	case "enter":
+		env, err := parseContainerEnv(m.deployEnvInput.Value())
+		if err != nil {
+			m.deployEnvErr = err.Error()
+			return m, nil
+		}
+		m.deployEnv = &env
+		m.editingDeployEnv = false
+		m.deployEnvErr = ""
+		m.deployPreview = nil
+		deployment := m.deployments[m.selectedDeployment]
+		return m, m.loadDeployPreview(deployment.PodName, deployment.Namespace)
+	}
+	var cmd tea.Cmd
+	m.deployEnvInput, cmd = m.deployEnvInput.Update(msg)
+	m.deployEnvErr = ""
+	return m, cmd
+}
+
+// renderDeployEnvOption is the confirmation step's line about the env, with
+// why it can't be edited when it can't.
+func (m model) renderDeployEnvOption() string {
+	option := "[e] Edit env"
+	if m.deployEnv != nil {
+		names := envNames(*m.deployEnv)
+		if names == "" {
+			names = "nothing"
+		}
+		option = fmt.Sprintf("[e] Edit env: sets %s", truncateString(names, 50))
+	}
+	if m.deployEnvErr != "" {
+		option += "\n" + formErrorStyle.Render("❌ "+m.deployEnvErr)
+	}
+	return option
+}
+
+func (m model) renderDeployEnv() string {
+	var b strings.Builder
+	deployment := m.deployments[m.selectedDeployment]
+	fmt.Fprintf(&b, "Environment of %s", deployment.PodName)
+	if m.deployContainer != "" {
+		b.WriteString(", container " + m.deployContainer)
+	}
+	b.WriteString("\n\n" + m.deployEnvInput.View() + "\n\n")
+	b.WriteString("KEY=value, KEY=secret:name/key, KEY=configmap:name/key,\n")
+	b.WriteString("or secret:name and configmap:name for all of their keys.\n")
+	if kept := m.deployPreview.keptEnv; kept > 0 {
+		fmt.Fprintf(&b, "%d entries that can't be typed here, e.g. fieldRef values, are kept.\n", kept)
+	}
+	if m.deployEnvErr != "" {
+		b.WriteString("\n" + formErrorStyle.Render("❌ "+m.deployEnvErr) + "\n")
+	}
+	b.WriteString("\nEnter to use this env, ESC to keep the previous one")
+	return modalStyle.Width(72).Height(0).Render(b.String())
+}
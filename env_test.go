@@ -0,0 +1,35 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/testenv"
+)
+
+// TestDeployEnvHintOpensEditor presses the key the confirmation step names
+// for editing the env, so the hints and the handler can't drift apart.
+func TestDeployEnvHintOpensEditor(t *testing.T) {
+	m, cancel := newModel(backends{})
+	defer cancel()
+	m.showModal, m.modalStep = true, 2
+	m.selectedImage = "localhost:5000/web:v2"
+	m.deployments = []TableData{{PodName: "web", Namespace: "default", Status: "Available"}}
+	m.selectedDeployment = 0
+	m.deployPreview = &deployPreview{env: "LOG_LEVEL=info"}
+
+	view := m.renderModal()
+	option := regexp.MustCompile(`\[(\S+)\] Edit env`).FindStringSubmatch(view)
+	prompt := regexp.MustCompile(`(\S+) to edit env`).FindStringSubmatch(view)
+	if option == nil || prompt == nil {
+		t.Fatalf("the confirmation step doesn't offer to edit the env:\n%s", view)
+	}
+	if option[1] != prompt[1] {
+		t.Errorf("the option names %q, the prompt %q", option[1], prompt[1])
+	}
+
+	updated, _ := m.Update(testenv.Key(option[1]))
+	if !updated.(model).editingDeployEnv {
+		t.Errorf("pressing %s, as the confirmation step says, didn't open the env editor", option[1])
+	}
+}
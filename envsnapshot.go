@@ -0,0 +1,451 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/kube"
+)
+
+// snapshot writes the state of the environment to one archive: the digest
+// of every tag in the registry, the specs of the deployments and the status
+// of the pods. snapshot diff compares two archives, e.g. to see what changed
+// since yesterday. The archive is a .tar.gz of JSON files, one per part, so
+// it can be read without this tool too.
+
+const (
+	snapshotInfoFile        = "snapshot.json"
+	snapshotRegistryFile    = "registry.json"
+	snapshotDeploymentsFile = "deployments.json"
+	snapshotPodsFile        = "pods.json"
+)
+
+// environmentSnapshot is the content of a snapshot archive.
+type environmentSnapshot struct {
+	Info        snapshotInfo
+	Tags        map[string]map[string]string // repository → tag → digest
+	Deployments map[string]appsv1.Deployment // by namespace/name, without status
+	Pods        map[string]snapshotPod       // by namespace/name
+}
+
+type snapshotInfo struct {
+	Created   time.Time `json:"created"`
+	Registry  string    `json:"registry"`
+	Cluster   string    `json:"cluster,omitempty"`
+	Namespace string    `json:"namespace,omitempty"` // empty for all namespaces
+	Errors    []string  `json:"errors,omitempty"`    // the parts that couldn't be captured
+}
+
+type snapshotPod struct {
+	Phase      string            `json:"phase"`
+	Node       string            `json:"node,omitempty"`
+	Restarts   int32             `json:"restarts"`
+	Containers map[string]string `json:"containers"` // state, e.g. Running or Waiting: CrashLoopBackOff
+	Images     map[string]string `json:"images"`
+}
+
+func runEnvironmentSnapshot(args []string) error {
+	if len(args) > 0 && args[0] == "diff" {
+		return runSnapshotDiff(args[1:])
+	}
+
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	out := fs.String("out", "", "archive to write (default: snapshot-<time>.tar.gz)")
+	registryHost := fs.String("registry", getRegistryHost(), "registry to capture (host:port or URL)")
+	repos := fs.String("repos", "", "comma-separated repositories to capture (default: all)")
+	namespace := fs.String("namespace", envOrDefault("KUBERNETES_NAMESPACE", "default"), `namespace of the deployments and pods, "all" for every namespace`)
+	addRegistryFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: local-container-registry snapshot [flags]")
+		fmt.Fprintln(fs.Output(), "       local-container-registry snapshot diff <archive> <archive>")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *namespace == "all" {
+		*namespace = metav1.NamespaceAll
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	snapshot := environmentSnapshot{Info: snapshotInfo{Created: time.Now(), Registry: *registryHost, Namespace: *namespace}}
+	var repositories []string
+	if *repos != "" {
+		repositories = strings.Split(*repos, ",")
+	}
+	tags, err := captureRegistry(ctx, *registryHost, repositories)
+	if err != nil {
+		snapshot.Info.Errors = append(snapshot.Info.Errors, fmt.Sprintf("registry: %v", err))
+	}
+	snapshot.Tags = tags
+
+	if kubernetesEnabled() {
+		snapshot.Info.Cluster = currentCluster().displayName()
+		if err := captureCluster(ctx, &snapshot); err != nil {
+			snapshot.Info.Errors = append(snapshot.Info.Errors, fmt.Sprintf("cluster: %v", err))
+		}
+	}
+	if tags == nil && snapshot.Deployments == nil {
+		return fmt.Errorf("snapshot: nothing could be captured: %s", strings.Join(snapshot.Info.Errors, "; "))
+	}
+
+	path := *out
+	if path == "" {
+		path = "snapshot-" + snapshot.Info.Created.Format("20060102-150405") + ".tar.gz"
+	}
+	if err := writeSnapshotArchive(path, snapshot); err != nil {
+		return err
+	}
+	for _, problem := range snapshot.Info.Errors {
+		fmt.Printf("⚠️  Not captured, %s\n", problem)
+	}
+	fmt.Printf("📸 Wrote %s: %d repositories, %d deployments, %d pods\n", path, len(snapshot.Tags), len(snapshot.Deployments), len(snapshot.Pods))
+	return nil
+}
+
+// captureRegistry resolves every tag of the repositories, or of all of
+// them, to its digest.
+func captureRegistry(ctx context.Context, host string, repositories []string) (map[string]map[string]string, error) {
+	client := newRegistryClient(ctx, host)
+	if repositories == nil {
+		var err error
+		if repositories, err = listRepositories(ctx, client); err != nil {
+			return nil, err
+		}
+	}
+	captured := make(map[string]map[string]string)
+	for _, repository := range repositories {
+		tags, err := client.Tags(ctx, repository)
+		if err != nil {
+			return captured, fmt.Errorf("%s: %v", repository, err)
+		}
+		captured[repository] = make(map[string]string)
+		for _, tag := range tags {
+			digest, err := client.ManifestDigest(ctx, repository, tag)
+			if err != nil {
+				return captured, fmt.Errorf("%s:%s: %v", repository, tag, err)
+			}
+			captured[repository][tag] = digest
+		}
+	}
+	return captured, nil
+}
+
+// captureCluster reads the deployments and pods of the snapshot's
+// namespace. Deployments keep their spec, labels and annotations; status
+// and the fields the API server maintains would show as changes every time.
+func captureCluster(ctx context.Context, snapshot *environmentSnapshot) error {
+	cluster := newCluster(ctx)
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	deployments, err := cluster.Deployments(ctx, snapshot.Info.Namespace)
+	if err != nil {
+		return err
+	}
+	snapshot.Deployments = make(map[string]appsv1.Deployment)
+	for _, deployment := range deployments {
+		// kubectl apply's copy of the whole object would show up as one
+		// long change next to the fields it repeats
+		annotations := make(map[string]string)
+		for key, value := range deployment.Annotations {
+			if key != corev1.LastAppliedConfigAnnotation {
+				annotations[key] = value
+			}
+		}
+		snapshot.Deployments[deployment.Namespace+"/"+deployment.Name] = appsv1.Deployment{
+			TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        deployment.Name,
+				Namespace:   deployment.Namespace,
+				Labels:      deployment.Labels,
+				Annotations: annotations,
+			},
+			Spec: deployment.Spec,
+		}
+	}
+
+	pods, err := cluster.ListPods(ctx, snapshot.Info.Namespace, "")
+	if err != nil {
+		return err
+	}
+	snapshot.Pods = make(map[string]snapshotPod)
+	for _, pod := range pods {
+		captured := snapshotPod{
+			Phase:      string(pod.Status.Phase),
+			Node:       pod.Spec.NodeName,
+			Containers: make(map[string]string),
+			Images:     make(map[string]string),
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			captured.Restarts += status.RestartCount
+			captured.Containers[status.Name] = snapshotContainerState(status.State)
+		}
+		for _, container := range pod.Spec.Containers {
+			captured.Images[container.Name] = container.Image
+		}
+		snapshot.Pods[pod.Namespace+"/"+pod.Name] = captured
+	}
+	return nil
+}
+
+// snapshotContainerState is a container's state without the times that
+// containerState shows, which differ between any two snapshots.
+func snapshotContainerState(state corev1.ContainerState) string {
+	switch {
+	case state.Running != nil:
+		return "Running"
+	case state.Waiting != nil:
+		return strings.TrimSuffix("Waiting: "+state.Waiting.Reason, ": ")
+	case state.Terminated != nil:
+		return fmt.Sprintf("Terminated: %s, exit code %d", state.Terminated.Reason, state.Terminated.ExitCode)
+	}
+	return "Unknown"
+}
+
+func writeSnapshotArchive(path string, snapshot environmentSnapshot) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(file)
+	archive := tar.NewWriter(gz)
+	for _, part := range []struct {
+		name    string
+		content any
+	}{
+		{snapshotInfoFile, snapshot.Info},
+		{snapshotRegistryFile, snapshot.Tags},
+		{snapshotDeploymentsFile, snapshot.Deployments},
+		{snapshotPodsFile, snapshot.Pods},
+	} {
+		content, err := json.MarshalIndent(part.content, "", "  ")
+		if err == nil {
+			err = archive.WriteHeader(&tar.Header{Name: part.name, Mode: 0644, Size: int64(len(content)), ModTime: snapshot.Info.Created})
+		}
+		if err == nil {
+			_, err = archive.Write(content)
+		}
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+	}
+	err = errors.Join(archive.Close(), gz.Close(), file.Close())
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+func readSnapshotArchive(path string) (environmentSnapshot, error) {
+	var snapshot environmentSnapshot
+	file, err := os.Open(path)
+	if err != nil {
+		return snapshot, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return snapshot, fmt.Errorf("%s is not a snapshot archive: %v", path, err)
+	}
+	archive := tar.NewReader(gz)
+	parts := map[string]any{
+		snapshotInfoFile:        &snapshot.Info,
+		snapshotRegistryFile:    &snapshot.Tags,
+		snapshotDeploymentsFile: &snapshot.Deployments,
+		snapshotPodsFile:        &snapshot.Pods,
+	}
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return snapshot, fmt.Errorf("%s is not a snapshot archive: %v", path, err)
+		}
+		if part, ok := parts[header.Name]; ok {
+			if err := json.NewDecoder(archive).Decode(part); err != nil {
+				return snapshot, fmt.Errorf("%s: %s: %v", path, header.Name, err)
+			}
+		}
+	}
+	if snapshot.Info.Created.IsZero() {
+		return snapshot, fmt.Errorf("%s is not a snapshot archive: no %s", path, snapshotInfoFile)
+	}
+	return snapshot, nil
+}
+
+func runSnapshotDiff(args []string) error {
+	fs := flag.NewFlagSet("snapshot diff", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: local-container-registry snapshot diff <older archive> <newer archive>")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("snapshot diff needs two archives")
+	}
+	a, err := readSnapshotArchive(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := readSnapshotArchive(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📸 %s (%s) → %s (%s)\n\n", fs.Arg(0), a.Info.Created.Format("2006-01-02 15:04"), fs.Arg(1), b.Info.Created.Format("2006-01-02 15:04"))
+	for _, line := range diffSnapshots(a, b) {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// diffSnapshots lists what changed from a to b, section by section, with
+// + for what was added, - for what was removed and ~ for what changed.
+func diffSnapshots(a, b environmentSnapshot) []string {
+	var lines []string
+	section := func(title string, changes []string) {
+		if len(changes) == 0 {
+			lines = append(lines, title+": unchanged")
+			return
+		}
+		lines = append(lines, title)
+		for _, change := range changes {
+			lines = append(lines, "  "+change)
+		}
+	}
+
+	registryTitle := "Registry " + b.Info.Registry
+	if a.Info.Registry != b.Info.Registry {
+		registryTitle = fmt.Sprintf("Registry %s → %s", a.Info.Registry, b.Info.Registry)
+	}
+	section(registryTitle, diffKeyValues(flattenTags(a.Tags), flattenTags(b.Tags)))
+
+	var deploymentChanges []string
+	for _, key := range unionKeys(a.Deployments, b.Deployments) {
+		before, hadBefore := a.Deployments[key]
+		after, hasAfter := b.Deployments[key]
+		switch {
+		case !hadBefore:
+			deploymentChanges = append(deploymentChanges, "+ "+key+" "+strings.Join(kube.Images(after), ", "))
+		case !hasAfter:
+			deploymentChanges = append(deploymentChanges, "- "+key)
+		default:
+			if changes := diffKeyValues(flattenJSON(before), flattenJSON(after)); len(changes) > 0 {
+				deploymentChanges = append(deploymentChanges, "~ "+key)
+				for _, change := range changes {
+					deploymentChanges = append(deploymentChanges, "    "+change)
+				}
+			}
+		}
+	}
+	section("Deployments", deploymentChanges)
+
+	var podChanges []string
+	for _, key := range unionKeys(a.Pods, b.Pods) {
+		before, hadBefore := a.Pods[key]
+		after, hasAfter := b.Pods[key]
+		switch {
+		case !hadBefore:
+			podChanges = append(podChanges, fmt.Sprintf("+ %s %s", key, after.Phase))
+		case !hasAfter:
+			podChanges = append(podChanges, fmt.Sprintf("- %s %s", key, before.Phase))
+		default:
+			if changes := diffKeyValues(flattenJSON(before), flattenJSON(after)); len(changes) > 0 {
+				podChanges = append(podChanges, "~ "+key)
+				for _, change := range changes {
+					podChanges = append(podChanges, "    "+change)
+				}
+			}
+		}
+	}
+	section("Pods", podChanges)
+
+	for _, snapshot := range []environmentSnapshot{a, b} {
+		for _, problem := range snapshot.Info.Errors {
+			lines = append(lines, fmt.Sprintf("⚠️  The snapshot of %s didn't capture %s", snapshot.Info.Created.Format("2006-01-02 15:04"), problem))
+		}
+	}
+	return lines
+}
+
+// flattenTags turns repository → tag → digest into repository:tag →
+// shortened digest.
+func flattenTags(tags map[string]map[string]string) map[string]string {
+	flat := make(map[string]string)
+	for repository, digests := range tags {
+		for tag, digest := range digests {
+			flat[repository+":"+tag] = shortDigest(digest)
+		}
+	}
+	return flat
+}
+
+// flattenJSON turns a value into its leaves by path, e.g.
+// spec.template.spec.containers[0].image, so changes deep inside a spec
+// read as one line each.
+func flattenJSON(value any) map[string]string {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var decoded any
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		return nil
+	}
+	flat := make(map[string]string)
+	var walk func(path string, value any)
+	walk = func(path string, value any) {
+		switch value := value.(type) {
+		case map[string]any:
+			for key, child := range value {
+				walk(strings.TrimPrefix(path+"."+key, "."), child)
+			}
+		case []any:
+			for i, child := range value {
+				walk(fmt.Sprintf("%s[%d]", path, i), child)
+			}
+		case nil:
+		default:
+			encoded, _ := json.Marshal(value)
+			flat[path] = strings.Trim(string(encoded), `"`)
+		}
+	}
+	walk("", decoded)
+	return flat
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range []map[string]V{a, b} {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The event bus decouples where data comes from and who shows it. Loading
+// registry images, pods or commits that changed, recording history and
+// receiving webhooks publish an event, whoever triggered them: the TUI, an
+// API request or serve's collectors. The TUI, the API's event stream, /metrics
+// and webhook builds subscribe to the kinds they care about.
+
+type eventKind string
+
+const (
+	eventImages  eventKind = "images"  // Data is the registry's []DockerImage
+	eventPods    eventKind = "pods"    // Data is the cluster's []TableData
+	eventCommits eventKind = "commits" // Data is the latest page of []TableData
+	eventHistory eventKind = "history" // Action is the recorded action, Data its result if it has one
+	eventWebhook eventKind = "webhook" // Data is the webhookEvent
+)
+
+var eventKinds = []eventKind{eventImages, eventPods, eventCommits, eventHistory, eventWebhook}
+
+type busEvent struct {
+	Kind   eventKind   `json:"kind"`
+	Time   time.Time   `json:"time"`
+	Count  int         `json:"count,omitempty"` // items in Data, for snapshots
+	Error  string      `json:"error,omitempty"` // set when collecting failed, Data is then nil
+	Action *auditEvent `json:"action,omitempty"`
+	Data   any         `json:"-"`
+}
+
+// eventBufferSize is how many events a subscriber may fall behind by before
+// it misses some.
+const eventBufferSize = 64
+
+type eventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan busEvent]map[eventKind]bool
+}
+
+// bus is the process's event bus.
+var bus = &eventBus{subscribers: make(map[chan busEvent]map[eventKind]bool)}
+
+// subscribe delivers events of the given kinds, or of every kind without
+// any, until cancel is called. Delivery never blocks publishers: a
+// subscriber too slow to keep up misses events rather than stalling them.
+func (b *eventBus) subscribe(kinds ...eventKind) (events <-chan busEvent, cancel func()) {
+	ch := make(chan busEvent, eventBufferSize)
+	var filter map[eventKind]bool
+	if len(kinds) > 0 {
+		filter = make(map[eventKind]bool)
+		for _, kind := range kinds {
+			filter[kind] = true
+		}
+	}
+	b.mu.Lock()
+	b.subscribers[ch] = filter
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (b *eventBus) publish(e busEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch, filter := range b.subscribers {
+		if filter != nil && !filter[e.Kind] {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+			log.Printf("event subscriber fell behind, dropped a %s event", e.Kind)
+		}
+	}
+}
+
+// lastSnapshots are the hashes of the snapshots published last, by kind.
+var lastSnapshots struct {
+	sync.Mutex
+	hashes map[eventKind][sha256.Size]byte
+}
+
+// publishSnapshot publishes the latest data of a kind, or the error
+// collecting it, when it differs from the last one. Subscribers reloading
+// on an event would otherwise publish the same snapshot again, forever.
+func publishSnapshot[T any](kind eventKind, data []T, err error) {
+	e := busEvent{Kind: kind, Count: len(data), Data: data}
+	state, _ := json.Marshal(data)
+	if err != nil {
+		e = busEvent{Kind: kind, Error: err.Error()}
+		state = []byte(e.Error)
+	}
+	hash := sha256.Sum256(state)
+
+	lastSnapshots.Lock()
+	if lastSnapshots.hashes == nil {
+		lastSnapshots.hashes = make(map[eventKind][sha256.Size]byte)
+	}
+	unchanged := lastSnapshots.hashes[kind] == hash
+	lastSnapshots.hashes[kind] = hash
+	lastSnapshots.Unlock()
+	if !unchanged {
+		bus.publish(e)
+	}
+}
+
+// collectInterval is how often serve collects images, pods and commits for
+// the bus's subscribers: COLLECT_INTERVAL, default 30s, 0 to only publish
+// what requests load.
+func collectInterval() time.Duration {
+	if value := os.Getenv("COLLECT_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err == nil && interval >= 0 {
+			return interval
+		}
+	}
+	return 30 * time.Second
+}
+
+// startCollectors polls the registry, the cluster and GitHub in the
+// background, each loader publishing what it finds.
+func startCollectors(ctx context.Context) {
+	interval := collectInterval()
+	if interval <= 0 {
+		return
+	}
+	collectors := []func(ctx context.Context){
+		func(ctx context.Context) { getDockerImagesInfo(ctx) },
+		func(ctx context.Context) { getGitCommits(ctx, gitCommitQuery{page: 1}) },
+	}
+	if kubernetesEnabled() {
+		collectors = append(collectors, func(ctx context.Context) { getKubernetesPodsInfo(ctx) })
+	}
+	for _, collect := range collectors {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				collect(ctx)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+// forwardEvents hands the TUI what others publish. Its own loads reach it
+// as messages already, so it only takes webhook deliveries.
+func forwardEvents(p *tea.Program) {
+	events, cancel := bus.subscribe(eventWebhook)
+	onShutdown(cancel)
+	go func() {
+		for e := range events {
+			p.Send(webhookMsg(e.Data.(webhookEvent)))
+		}
+	}()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/backend"
+)
+
+// servicePort is the port the generated Service listens on; it forwards to
+// the container port of the deployment.
+const servicePort = 80
+
+// parseServiceType accepts "none" (or empty), ClusterIP and NodePort in any
+// case.
+func parseServiceType(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "" || strings.EqualFold(value, "none"):
+		return "", nil
+	case strings.EqualFold(value, string(corev1.ServiceTypeClusterIP)):
+		return string(corev1.ServiceTypeClusterIP), nil
+	case strings.EqualFold(value, string(corev1.ServiceTypeNodePort)):
+		return string(corev1.ServiceTypeNodePort), nil
+	}
+	return "", fmt.Errorf("service %q must be none, ClusterIP or NodePort", value)
+}
+
+func buildService(params deploymentParams) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      params.Name,
+			Namespace: params.Namespace,
+			Labels:    map[string]string{"app": params.Name},
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceType(params.Service),
+			Selector: map[string]string{"app": params.Name},
+			Ports: []corev1.ServicePort{{
+				Name:       "http",
+				Port:       servicePort,
+				TargetPort: intstr.FromInt32(params.Port),
+				Protocol:   corev1.ProtocolTCP,
+			}},
+		},
+	}
+}
+
+// buildIngress routes every path of params.IngressHost to the Service.
+// INGRESS_CLASS picks the controller when the cluster has no default class.
+func buildIngress(params deploymentParams) *networkingv1.Ingress {
+	pathType := networkingv1.PathTypePrefix
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      params.Name,
+			Namespace: params.Namespace,
+			Labels:    map[string]string{"app": params.Name},
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{
+				Host: params.IngressHost,
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{
+							Path:     "/",
+							PathType: &pathType,
+							Backend: networkingv1.IngressBackend{
+								Service: &networkingv1.IngressServiceBackend{
+									Name: params.Name,
+									Port: networkingv1.ServiceBackendPort{Number: servicePort},
+								},
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+	if class := os.Getenv("INGRESS_CLASS"); class != "" {
+		ingress.Spec.IngressClassName = &class
+	}
+	return ingress
+}
+
+// exposeDeployment creates the Service and Ingress asked for in params and
+// returns the URL the app is reachable at, empty when nothing was exposed.
+// When the Ingress fails, the Service is removed again.
+func exposeDeployment(ctx context.Context, cluster backend.ClusterAPI, params deploymentParams) (string, error) {
+	if params.Service == "" {
+		return "", nil
+	}
+
+	service, err := cluster.CreateService(ctx, buildService(params))
+	if err != nil {
+		return "", fmt.Errorf("error creating service %s: %v", params.Name, err)
+	}
+	if params.IngressHost != "" {
+		if err := cluster.CreateIngress(ctx, buildIngress(params)); err != nil {
+			// The Service goes with the deployment the caller removes
+			cluster.DeleteService(ctx, params.Namespace, params.Name)
+			return "", fmt.Errorf("error creating ingress %s: %v", params.Name, err)
+		}
+		return "http://" + params.IngressHost + "/", nil
+	}
+	if params.Service == string(corev1.ServiceTypeNodePort) && len(service.Spec.Ports) > 0 {
+		return nodePortURL(ctx, cluster, service.Spec.Ports[0].NodePort), nil
+	}
+	return clusterServiceURL(params), nil
+}
+
+// nodePortURL prefers a node's external address and falls back to its
+// internal one, which is what Minikube and kind expose to the host.
+func nodePortURL(ctx context.Context, cluster backend.ClusterAPI, nodePort int32) string {
+	address := "<node-ip>"
+	if nodes, err := cluster.Nodes(ctx); err == nil && len(nodes) > 0 {
+		for _, want := range []corev1.NodeAddressType{corev1.NodeExternalIP, corev1.NodeInternalIP} {
+			for _, addr := range nodes[0].Status.Addresses {
+				if addr.Type == want && address == "<node-ip>" {
+					address = addr.Address
+				}
+			}
+		}
+	}
+	return fmt.Sprintf("http://%s:%d/", address, nodePort)
+}
+
+// clusterServiceURL is only reachable from inside the cluster (or through
+// kubectl port-forward).
+func clusterServiceURL(params deploymentParams) string {
+	return fmt.Sprintf("http://%s.%s.svc.cluster.local/", params.Name, params.Namespace)
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// gitCommitsPerPage is how many commits each scroll past the end of the
+// Git tab fetches.
+const gitCommitsPerPage = 30
+
+// gitColumns are the Git tab's columns; Committer is only shown when
+// COLUMNS_GIT names it.
+var gitColumns = []table.Column{
+	{Title: "Commit SHA", Width: 42},
+	{Title: "PR Description", Width: 40},
+	{Title: "Author", Width: 24},
+	{Title: "Committer", Width: 24},
+	{Title: "PushedAt", Width: 20},
+}
+
+// commitRow is a commit's row of the Git tab, by gitColumns.
+func commitRow(commit TableData) table.Row {
+	return table.Row{commit.CommitSHA, commit.PRDescription, authorCell(commit.Author), authorCell(commit.Committer), commit.PushedAt}
+}
+
+// authorCell is a name after its initials, standing in for an avatar:
+// "[JD] Jane Doe".
+func authorCell(name string) string {
+	if name == "" {
+		return "N/A"
+	}
+	var initials []rune
+	for _, word := range strings.Fields(name) {
+		if r := []rune(word)[0]; len(initials) < 2 && unicode.IsLetter(r) {
+			initials = append(initials, unicode.ToUpper(r))
+		}
+	}
+	if len(initials) == 0 {
+		return name
+	}
+	return "[" + string(initials) + "] " + name
+}
+
+// gitCommitQuery is a page of the commits in a date range, zero times
+// leaving it open, that touch path, empty for any.
+type gitCommitQuery struct {
+	page  int
+	since time.Time
+	until time.Time
+	path  string
+}
+
+func (q gitCommitQuery) sameFilter(other gitCommitQuery) bool {
+	return q.since.Equal(other.since) && q.until.Equal(other.until) && q.path == other.path
+}
+
+func (q gitCommitQuery) unfiltered() bool {
+	return q.since.IsZero() && q.until.IsZero() && q.path == ""
+}
+
+// gitService is a directory of a monorepo whose commits the Git tab can
+// list on their own.
+type gitService struct {
+	name string
+	path string
+}
+
+// gitServices are GIT_SERVICE_PATHS: comma-separated name=path entries, e.g.
+// "web=services/web,api=services/api". They are read once, warning once
+// about malformed entries.
+var gitServices = sync.OnceValue(func() []gitService {
+	var services []gitService
+	for _, entry := range strings.Split(os.Getenv("GIT_SERVICE_PATHS"), ",") {
+		name, path, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		name, path = strings.TrimSpace(name), strings.Trim(strings.TrimSpace(path), "/")
+		if !ok || name == "" || path == "" {
+			if entry != "" {
+				log.Printf("⚠️  Ignoring GIT_SERVICE_PATHS entry %q: not name=path, e.g. web=services/web", entry)
+			}
+			continue
+		}
+		services = append(services, gitService{name: name, path: path})
+	}
+	return services
+})
+
+// findGitService looks a service up by name.
+func findGitService(name string) (gitService, error) {
+	var names []string
+	for _, service := range gitServices() {
+		if service.name == name {
+			return service, nil
+		}
+		names = append(names, service.name)
+	}
+	if len(names) == 0 {
+		return gitService{}, fmt.Errorf("no service %q: GIT_SERVICE_PATHS isn't set", name)
+	}
+	return gitService{}, fmt.Errorf("no service %q in GIT_SERVICE_PATHS, which has %s", name, strings.Join(names, ", "))
+}
+
+// touchesPath reports whether any of files is path or lies under it.
+func touchesPath(files []string, path string) bool {
+	for _, file := range files {
+		if file == path || strings.HasPrefix(file, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+type gitDataMsg struct {
+	query gitCommitQuery
+	data  []TableData
+	more  bool // further pages exist
+	err   error
+	stale *staleData // set when data is the last-known-good first page
+}
+
+func (m model) loadCommitPage(page int) tea.Cmd {
+	ctx, query := m.ctx, m.gitQuery
+	query.page = page
+	return func() tea.Msg {
+		data, more, err := getGitCommits(ctx, query)
+		// Only the latest commits are kept for offline use
+		if query.page <= 1 && query.unfiltered() {
+			if err == nil {
+				saveLastKnownGood(ctx, 0, data)
+			} else if rows, stale := lastKnownGood(ctx, 0, err); stale != nil {
+				return gitDataMsg{query: query, data: rows, stale: stale}
+			}
+		}
+		return gitDataMsg{query: query, data: data, more: more, err: err}
+	}
+}
+
+// mergeCommits is first followed by the commits of second it doesn't have,
+// so a refreshed first page keeps the older pages already loaded.
+func mergeCommits(first, second []TableData) []TableData {
+	merged := append([]TableData{}, first...)
+	seen := make(map[string]bool, len(first))
+	for _, commit := range first {
+		seen[commit.CommitSHA] = true
+	}
+	for _, commit := range second {
+		if !seen[commit.CommitSHA] {
+			merged = append(merged, commit)
+		}
+	}
+	return merged
+}
+
+// updateGitData takes in a page of commits: the first page, at startup or
+// from polling, goes in front; later pages are appended.
+func (m *model) updateGitData(msg gitDataMsg) {
+	if !msg.query.sameFilter(m.gitQuery) {
+		return // loaded for a date range or service since replaced
+	}
+	m.gitErr = msg.err
+	if msg.query.page <= 1 {
+		m.stale[0] = msg.stale
+	}
+	switch {
+	case msg.err != nil:
+		// A failed refresh keeps the commits already shown
+		if msg.query.page > 1 {
+			m.gitLoadingMore = false
+		}
+	case msg.query.page == 1:
+		m.gitData = mergeCommits(msg.data, m.gitData)
+		if m.gitQuery.page == 0 {
+			m.gitQuery.page, m.gitMore = 1, msg.more
+		}
+	default:
+		m.gitData = mergeCommits(m.gitData, msg.data)
+		m.gitQuery.page, m.gitMore, m.gitLoadingMore = msg.query.page, msg.more, false
+	}
+	if m.activeTab == 0 {
+		m.updateTableForTab()
+	}
+}
+
+// loadMoreCommits fetches the next page once the cursor reaches the last
+// commit of the Git tab.
+func (m *model) loadMoreCommits() tea.Cmd {
+	if m.activeTab != 0 || m.showReleases || m.showModal || !m.gitMore || m.gitLoadingMore || m.showTour {
+		return nil
+	}
+	if len(m.gitData) == 0 || m.table.Cursor() < len(m.gitData)-1 {
+		return nil
+	}
+	m.gitLoadingMore = true
+	return m.loadCommitPage(m.gitQuery.page + 1)
+}
+
+// parseDateRange reads the Git tab's date filter: from..to with either end
+// left open, a single date for commits since then, or a span such as 7d,
+// 2w or 12h back from now. Dates are YYYY-MM-DD; to is inclusive.
+func parseDateRange(value string, now time.Time) (since, until time.Time, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, time.Time{}, nil
+	}
+	if span, ok := parseSpan(value); ok {
+		return now.Add(-span), time.Time{}, nil
+	}
+	from, to, isRange := strings.Cut(value, "..")
+	if from = strings.TrimSpace(from); from != "" {
+		if since, err = time.ParseInLocation(time.DateOnly, from, time.Local); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD", from)
+		}
+	}
+	if to = strings.TrimSpace(to); isRange && to != "" {
+		day, err := time.ParseInLocation(time.DateOnly, to, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD", to)
+		}
+		until = day.AddDate(0, 0, 1)
+	}
+	if !since.IsZero() && !until.IsZero() && !since.Before(until) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%s is after %s", from, to)
+	}
+	return since, until, nil
+}
+
+// parseSpan accepts Go durations plus days (d) and weeks (w).
+func parseSpan(value string) (time.Duration, bool) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			if n, err := strconv.Atoi(number); err == nil && n > 0 {
+				return time.Duration(n) * unit, true
+			}
+		}
+	}
+	if span, err := time.ParseDuration(value); err == nil && span > 0 {
+		return span, true
+	}
+	return 0, false
+}
+
+// startCommitFilter asks for the Git tab's date range.
+func (m *model) startCommitFilter() tea.Cmd {
+	m.commitFilterInput = textinput.New()
+	m.commitFilterInput.Prompt = ""
+	m.commitFilterInput.Placeholder = "e.g. 7d, 2024-05-01..2024-05-31 or 2024-05-01.."
+	m.commitFilterInput.SetValue(m.commitFilter)
+	m.filteringCommits = true
+	return m.commitFilterInput.Focus()
+}
+
+func (m model) updateCommitFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc":
+		m.filteringCommits = false
+		return m, nil
+	case "enter":
+		since, until, err := parseDateRange(m.commitFilterInput.Value(), time.Now())
+		if err != nil {
+			m.statusMessage = "⚠️  " + err.Error()
+			return m, nil
+		}
+		m.filteringCommits = false
+		m.commitFilter = strings.TrimSpace(m.commitFilterInput.Value())
+		return m, m.reloadCommits(gitCommitQuery{since: since, until: until, path: m.gitQuery.path})
+	}
+	var cmd tea.Cmd
+	m.commitFilterInput, cmd = m.commitFilterInput.Update(msg)
+	return m, cmd
+}
+
+// reloadCommits lists the commits of another query from its first page.
+func (m *model) reloadCommits(query gitCommitQuery) tea.Cmd {
+	m.gitQuery = query
+	m.gitData, m.gitErr, m.gitMore, m.gitLoadingMore = nil, nil, false, false
+	m.table.SetCursor(0)
+	m.updateTableForTab()
+	return m.loadCommitPage(1)
+}
+
+// nextGitService moves the Git tab on to the commits of the next service of
+// GIT_SERVICE_PATHS, after the last back to every commit.
+func (m *model) nextGitService() tea.Cmd {
+	services := gitServices()
+	if len(services) == 0 {
+		m.statusMessage = "Set GIT_SERVICE_PATHS, e.g. web=services/web,api=services/api, to list a service's commits"
+		return nil
+	}
+	next := services[0]
+	for i, service := range services {
+		if service.name == m.commitService.name {
+			next = gitService{}
+			if i+1 < len(services) {
+				next = services[i+1]
+			}
+		}
+	}
+	m.commitService = next
+	query := m.gitQuery
+	query.page, query.path = 0, next.path
+	return m.reloadCommits(query)
+}
+
+func (m model) renderCommitFilterPrompt() string {
+	return fmt.Sprintf("📅 Commits from %s  (Enter to apply, empty for all, ESC to cancel)", m.commitFilterInput.View())
+}
+
+// renderCommitPaging is the Git tab's line about what is loaded.
+func (m model) renderCommitPaging() string {
+	status := fmt.Sprintf("%d commits", len(m.gitData))
+	if m.commitService.name != "" {
+		status += fmt.Sprintf(" of %s (%s/)", m.commitService.name, m.commitService.path)
+	}
+	if m.commitFilter != "" {
+		status += fmt.Sprintf(" from %s", m.commitFilter)
+	}
+	switch {
+	case m.gitLoadingMore:
+		status += " · loading more..."
+	case m.gitMore:
+		status += " · scroll past the last for more"
+	default:
+		status += " · all loaded"
+	}
+	status += " · d to filter by date"
+	if len(gitServices()) > 0 {
+		status += " · p for the next service"
+	}
+	return status + " · r for releases"
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/backend/mock"
+	"github.com/anthony-gilbert/local-container-registry/pkg/gitprovider"
+	"github.com/anthony-gilbert/local-container-registry/pkg/store"
+)
+
+// loadCommits runs the Git tab's first page load against git and st and
+// hands its result to Update.
+func loadCommits(t *testing.T, git *mock.MockGitProvider, st *mock.MockStore) model {
+	t.Helper()
+	m, cancel := newModel(backends{git: git, store: st})
+	t.Cleanup(cancel)
+	updated, _ := m.Update(m.loadCommitPage(1)())
+	return updated.(model)
+}
+
+func TestGitTabStoresNewCommits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	git := mock.NewMockGitProvider(ctrl)
+	git.EXPECT().ListCommits(gomock.Any(), gomock.Any()).Return(gitprovider.Page{Commits: []gitprovider.Commit{
+		{SHA: "a1b2c3", Message: "Add health check", Author: "dev", Committer: "ci"},
+		{SHA: "d4e5f6", Message: "Initial commit", Author: "dev", Committer: "dev"},
+	}}, nil)
+	st := mock.NewMockStore(ctrl)
+	st.EXPECT().RecordCommit(gomock.Any(), store.Commit{SHA: "a1b2c3", Message: "Add health check", Author: "dev", Committer: "ci"})
+	// A failed write is logged; the commits are listed all the same
+	st.EXPECT().RecordCommit(gomock.Any(), store.Commit{SHA: "d4e5f6", Message: "Initial commit", Author: "dev", Committer: "dev"}).
+		Return(errors.New("connection refused"))
+	st.EXPECT().SaveTabSnapshot(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+	m := loadCommits(t, git, st)
+	if m.gitErr != nil || len(m.gitData) != 2 {
+		t.Fatalf("got %d commits and %v, want both commits", len(m.gitData), m.gitErr)
+	}
+}
+
+func TestGitTabDoesNotStoreCachedPage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	git := mock.NewMockGitProvider(ctrl)
+	git.EXPECT().ListCommits(gomock.Any(), gomock.Any()).Return(gitprovider.Page{Cached: true, Commits: []gitprovider.Commit{
+		{SHA: "a1b2c3", Message: "Add health check", Author: "dev"},
+	}}, nil)
+	// No RecordCommit is expected: the page was stored when first listed
+	st := mock.NewMockStore(ctrl)
+	st.EXPECT().SaveTabSnapshot(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+	if m := loadCommits(t, git, st); len(m.gitData) != 1 {
+		t.Fatalf("got %d commits, want 1", len(m.gitData))
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/go-github/v63/github"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/backend"
+	"github.com/anthony-gilbert/local-container-registry/pkg/gitprovider"
+)
+
+// GitHub requests are made conditional: the ETag of every page fetched is
+// kept, in memory and under the registry cache directory, and sent back as
+// If-None-Match. GitHub doesn't count a 304 against the rate limit, and the
+// page is then served from the cache.
+
+// githubFromCacheHeader marks responses served from the cache, so callers
+// can skip work for data they have already seen.
+const githubFromCacheHeader = gitprovider.FromCacheHeader
+
+type githubCachedPage struct {
+	ETag   string      `json:"etag"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+var githubPages = struct {
+	sync.Mutex
+	pages map[string]githubCachedPage
+}{pages: map[string]githubCachedPage{}}
+
+func githubPagePath(url string) string {
+	dir := registryCacheDir()
+	if dir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, "github", hex.EncodeToString(sum[:])+".json")
+}
+
+func loadGitHubPage(url string) (githubCachedPage, bool) {
+	githubPages.Lock()
+	defer githubPages.Unlock()
+	if page, ok := githubPages.pages[url]; ok {
+		return page, true
+	}
+	path := githubPagePath(url)
+	if path == "" {
+		return githubCachedPage{}, false
+	}
+	data, err := os.ReadFile(path)
+	var page githubCachedPage
+	if err != nil || json.Unmarshal(data, &page) != nil || page.ETag == "" {
+		return githubCachedPage{}, false
+	}
+	githubPages.pages[url] = page
+	return page, true
+}
+
+func storeGitHubPage(url string, page githubCachedPage) {
+	githubPages.Lock()
+	defer githubPages.Unlock()
+	githubPages.pages[url] = page
+	if path := githubPagePath(url); path != "" {
+		if data, err := json.Marshal(page); err == nil && os.MkdirAll(filepath.Dir(path), 0755) == nil {
+			writeFileAtomic(path, data)
+		}
+	}
+}
+
+// githubCacheTransport sends GETs conditionally and answers 304s from the
+// cache. It also notes the rate limit every response reports.
+type githubCacheTransport struct {
+	base http.RoundTripper
+}
+
+func (t githubCacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		resp, err := t.base.RoundTrip(req)
+		if err == nil {
+			recordGitHubRate(resp.Header)
+		}
+		return resp, err
+	}
+
+	url := req.URL.String()
+	cached, haveCached := loadGitHubPage(url)
+	if haveCached {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	recordGitHubRate(resp.Header)
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && haveCached:
+		resp.Body.Close()
+		header := resp.Header.Clone()
+		for key, values := range cached.Header {
+			header[key] = values
+		}
+		header.Set(githubFromCacheHeader, "1")
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+			ContentLength: int64(len(cached.Body)),
+			Request:       req,
+		}, nil
+	case resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "":
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		// Link carries the pagination, the rest is recomputed on a 304
+		header := http.Header{}
+		for _, key := range []string{"Content-Type", "Link"} {
+			if value := resp.Header.Get(key); value != "" {
+				header.Set(key, value)
+			}
+		}
+		storeGitHubPage(url, githubCachedPage{ETag: resp.Header.Get("ETag"), Header: header, Body: body})
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return resp, nil
+}
+
+// githubRate is the rate limit as of the last GitHub response.
+var githubRate struct {
+	sync.Mutex
+	limit     int
+	remaining int
+	reset     time.Time
+	known     bool
+}
+
+func recordGitHubRate(header http.Header) {
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, _ := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	reset, _ := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	githubRate.Lock()
+	defer githubRate.Unlock()
+	githubRate.limit, githubRate.remaining, githubRate.reset, githubRate.known = limit, remaining, time.Unix(reset, 0), true
+}
+
+// githubRateReserve is how many calls polling leaves for everything else:
+// GITHUB_RATE_RESERVE, by default a tenth of the limit.
+func githubRateReserve(limit int) int {
+	if value, err := strconv.Atoi(os.Getenv("GITHUB_RATE_RESERVE")); err == nil && value >= 0 {
+		return value
+	}
+	return limit / 10
+}
+
+// githubPollPausedUntil is when polling may resume, zero while it runs.
+func githubPollPausedUntil() time.Time {
+	githubRate.Lock()
+	defer githubRate.Unlock()
+	if !githubRate.known || githubRate.remaining > githubRateReserve(githubRate.limit) || time.Now().After(githubRate.reset) {
+		return time.Time{}
+	}
+	return githubRate.reset
+}
+
+// githubHTTPClient makes conditional requests to the GitHub API and retries
+// transient failures. Each request sent is a span, answered from the cache
+// or not.
+var githubHTTPClient = &http.Client{Transport: retryTransport{backend: backendGitHub, base: githubCacheTransport{base: tracingTransport{base: proxiedTransport(backendGitHub)}}}}
+
+// newGitHubClient is the GitHub API client for the token in the keyring or
+// GITHUB_AUTH_TOKEN.
+func newGitHubClient() *github.Client {
+	return github.NewClient(githubHTTPClient).WithAuthToken(githubToken())
+}
+
+// newGitProvider lists the commits of GITHUB_OWNER/GITHUB_REPO, or is the
+// Git backend ctx carries.
+func newGitProvider(ctx context.Context) backend.GitProvider {
+	if git := backendsOf(ctx).git; git != nil {
+		return git
+	}
+	return gitprovider.NewGitHub(os.Getenv("GITHUB_OWNER"), os.Getenv("GITHUB_REPO"), gitprovider.GitHubOptions{
+		HTTPClient: githubHTTPClient,
+		Token:      githubToken(),
+	})
+}
+
+// githubPollInterval is how often the Git tab fetches new commits:
+// GITHUB_POLL_INTERVAL, default a minute, 0 to only load them at startup.
+// Receiving webhooks, the tab doesn't poll unless asked to.
+func githubPollInterval() time.Duration {
+	if value := os.Getenv("GITHUB_POLL_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err == nil && interval >= 0 {
+			return interval
+		}
+	}
+	if webhookReceiverAddr() != "" {
+		return 0
+	}
+	return time.Minute
+}
+
+type gitPollMsg struct{}
+
+func gitPollTick() tea.Cmd {
+	interval := githubPollInterval()
+	if interval == 0 {
+		return nil
+	}
+	return tea.Tick(interval, func(time.Time) tea.Msg { return gitPollMsg{} })
+}
+
+// pollGitCommits refetches the first page of commits unless the rate limit is nearly used
+// up; unchanged pages cost no calls.
+func (m model) pollGitCommits() tea.Cmd {
+	if !githubPollPausedUntil().IsZero() {
+		return gitPollTick()
+	}
+	return tea.Batch(gitPollTick(), m.loadCommitPage(1))
+}
+
+// renderGitHubRate is the Git tab's line about the API rate limit.
+func renderGitHubRate() string {
+	githubRate.Lock()
+	limit, remaining, reset, known := githubRate.limit, githubRate.remaining, githubRate.reset, githubRate.known
+	githubRate.Unlock()
+	if !known {
+		return ""
+	}
+	status := fmt.Sprintf("GitHub API: %d of %d calls left, resets %s", remaining, limit, reset.Format("15:04"))
+	if until := githubPollPausedUntil(); !until.IsZero() {
+		return status + fmt.Sprintf(" · ⏸  refresh paused until %s", until.Format("15:04"))
+	}
+	if interval := githubPollInterval(); interval > 0 {
+		status += fmt.Sprintf(" · refreshes every %s", interval)
+	} else if addr := webhookReceiverAddr(); addr != "" {
+		status += fmt.Sprintf(" · webhooks on %s", addr)
+	}
+	return status
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v63/github"
+	"gopkg.in/yaml.v3"
+)
+
+// GitOps mode: with GITOPS_DIR set, deploying to an existing deployment
+// doesn't touch the cluster. The new image is written to that directory
+// instead, as a kustomize image override (GITOPS_FORMAT=kustomize, the
+// default) or a strategic-merge patch per deployment (GITOPS_FORMAT=patch),
+// for ArgoCD/Flux to roll out. GITOPS_PR=true also commits the change on a
+// new branch and opens a GitHub pull request.
+
+func gitopsEnabled() bool {
+	return os.Getenv("GITOPS_DIR") != ""
+}
+
+// exportDeployment records image as the new image of the deployment and
+// returns a status line saying where it went.
+func exportDeployment(ctx context.Context, image, deploymentName, namespace, container string) (string, error) {
+	dir := os.Getenv("GITOPS_DIR")
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("GITOPS_DIR %s is not a directory", dir)
+	}
+
+	var path string
+	var err error
+	switch format := envOrDefault("GITOPS_FORMAT", "kustomize"); format {
+	case "kustomize":
+		path, err = setKustomizeImage(dir, image)
+	case "patch":
+		path, err = writeImagePatch(ctx, dir, image, deploymentName, namespace, container)
+	default:
+		return "", fmt.Errorf("GITOPS_FORMAT %q must be kustomize or patch", format)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if os.Getenv("GITOPS_PR") != "true" {
+		return fmt.Sprintf("📝 Wrote %s to %s, commit it to roll out", image, path), nil
+	}
+	url, err := openGitOpsPullRequest(ctx, dir, path, deploymentName, fmt.Sprintf("Deploy %s to %s/%s", image, namespace, deploymentName))
+	if err != nil {
+		return "", fmt.Errorf("wrote %s but couldn't open a pull request: %v", path, err)
+	}
+	return "📝 Opened " + url, nil
+}
+
+// setKustomizeImage points the images: entry for the image's repository at
+// the new tag, adding the entry if needed. Entries match on name or newName,
+// so an override that renames "web" to "localhost:5000/web" is updated too.
+// The file is edited as a YAML tree to keep comments and key order.
+func setKustomizeImage(dir, image string) (string, error) {
+	path := filepath.Join(dir, "kustomization.yaml")
+	for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			path = filepath.Join(dir, name)
+			break
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		content = []byte("apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\n")
+	} else if err != nil {
+		return "", err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return "", fmt.Errorf("%s is not a kustomization", path)
+	}
+	root := doc.Content[0]
+
+	images := mappingValue(root, "images")
+	if images == nil {
+		images = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		root.Content = append(root.Content, scalarNode("images"), images)
+	}
+
+	repository, tag := splitImageTag(image)
+	var entry *yaml.Node
+	for _, item := range images.Content {
+		if item.Kind != yaml.MappingNode {
+			continue
+		}
+		name, newName := mappingValue(item, "name"), mappingValue(item, "newName")
+		if (name != nil && name.Value == repository) || (newName != nil && newName.Value == repository) {
+			entry = item
+			break
+		}
+	}
+	if entry == nil {
+		entry = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{scalarNode("name"), scalarNode(repository)}}
+		images.Content = append(images.Content, entry)
+	}
+	// A pinned digest would win over the tag
+	deleteMappingKey(entry, "digest")
+	setMappingValue(entry, "newTag", tag)
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return "", err
+	}
+	encoder.Close()
+	return path, os.WriteFile(path, out.Bytes(), 0644)
+}
+
+// writeImagePatch writes <deployment>-image.yaml, a strategic-merge patch
+// setting the image of the deployment's container, the first one when
+// container is empty.
+func writeImagePatch(ctx context.Context, dir, image, deploymentName, namespace, container string) (string, error) {
+	if container == "" {
+		container = firstContainerName(ctx, deploymentName, namespace)
+	}
+	patch := fmt.Sprintf(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: %s
+  namespace: %s
+spec:
+  template:
+    spec:
+      containers:
+      - name: %s
+        image: %s
+`, deploymentName, namespace, container, image)
+
+	path := filepath.Join(dir, deploymentName+"-image.yaml")
+	return path, os.WriteFile(path, []byte(patch), 0644)
+}
+
+// firstContainerName reads the container a patch has to name from the
+// cluster, falling back to "app" as used by deployments created here.
+func firstContainerName(ctx context.Context, deploymentName, namespace string) string {
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+	deployment, err := newCluster(ctx).Deployment(ctx, namespace, deploymentName)
+	if err != nil || len(deployment.Spec.Template.Spec.Containers) == 0 {
+		return "app"
+	}
+	return deployment.Spec.Template.Spec.Containers[0].Name
+}
+
+// openGitOpsPullRequest commits file on a new branch of the checkout in dir,
+// pushes it and opens a pull request against the branch that was checked
+// out. GITOPS_GITHUB_REPO ("owner/repo") names the repository, defaulting
+// to GITHUB_OWNER/GITHUB_REPO.
+func openGitOpsPullRequest(ctx context.Context, dir, file, deploymentName, title string) (string, error) {
+	git := func(args ...string) (string, error) {
+		output, err := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("git %s failed: %v\nOutput: %s", args[0], err, string(output))
+		}
+		return strings.TrimSpace(string(output)), nil
+	}
+
+	base, err := git("rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	branch := fmt.Sprintf("deploy/%s-%s", deploymentName, time.Now().Format("20060102-150405"))
+	if _, err := git("checkout", "-b", branch); err != nil {
+		return "", err
+	}
+	// Whatever happens, leave the checkout on the branch it was on
+	defer git("checkout", base)
+
+	for _, args := range [][]string{
+		{"add", file},
+		{"commit", "-m", title},
+		{"push", "-u", "origin", branch},
+	} {
+		if _, err := git(args...); err != nil {
+			return "", err
+		}
+	}
+
+	owner, repo := os.Getenv("GITHUB_OWNER"), os.Getenv("GITHUB_REPO")
+	if full := os.Getenv("GITOPS_GITHUB_REPO"); full != "" {
+		owner, repo, _ = strings.Cut(full, "/")
+	}
+	ctx, cancel := withBackendTimeout(ctx, backendGitHub)
+	defer cancel()
+	client := newGitHubClient()
+	pr, _, err := client.PullRequests.Create(ctx, owner, repo, &github.NewPullRequest{
+		Title: github.String(title),
+		Head:  github.String(branch),
+		Base:  github.String(base),
+		Body:  github.String("Opened by local-container-registry. Merging it lets the GitOps controller roll out the new image."),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to open pull request: %v", err)
+	}
+	return pr.GetHTMLURL(), nil
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// mappingValue returns the value node of key in a mapping node, or nil.
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingValue(mapping *yaml.Node, key, value string) {
+	if node := mappingValue(mapping, key); node != nil {
+		node.Kind, node.Tag, node.Value, node.Style = yaml.ScalarNode, "!!str", value, 0
+		return
+	}
+	mapping.Content = append(mapping.Content, scalarNode(key), scalarNode(value))
+}
+
+func deleteMappingKey(mapping *yaml.Node, key string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+	}
+}
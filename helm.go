@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/strvals"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// Annotations Helm puts on every object it installs
+const (
+	helmReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+)
+
+// helmImageKeys are the values paths an image is written to on upgrade. The
+// defaults follow the layout `helm create` generates; charts using another
+// layout can set HELM_IMAGE_REPOSITORY_KEY and HELM_IMAGE_TAG_KEY.
+func helmImageKeys() (repositoryKey, tagKey string) {
+	return envOrDefault("HELM_IMAGE_REPOSITORY_KEY", "image.repository"), envOrDefault("HELM_IMAGE_TAG_KEY", "image.tag")
+}
+
+// helmRelease returns the release managing a deployment, if any.
+func helmRelease(deployment *appsv1.Deployment) (name, namespace string) {
+	name = deployment.Annotations[helmReleaseNameAnnotation]
+	namespace = deployment.Annotations[helmReleaseNamespaceAnnotation]
+	if namespace == "" {
+		namespace = deployment.Namespace
+	}
+	return name, namespace
+}
+
+// newHelmConfig talks to the same cluster as the rest of the app: the
+// cluster picked in the switcher, KUBECONFIG and KUBERNETES_CONTROL_PLANE
+// apply, as do Helm's own HELM_* variables.
+func newHelmConfig(namespace string) (*action.Configuration, error) {
+	settings := cli.New()
+	if c := currentCluster(); c != (cluster{}) {
+		settings.KubeConfig = c.kubeconfigPath()
+		settings.KubeContext = c.context
+	} else if host := kubernetesControlPlaneHost(); host != "" {
+		settings.KubeAPIServer = host
+	}
+
+	config := new(action.Configuration)
+	if err := config.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), log.Printf); err != nil {
+		return nil, fmt.Errorf("failed to initialize helm: %v", err)
+	}
+	return config, nil
+}
+
+// listHelmReleases lists the releases in every namespace.
+func listHelmReleases() ([]*release.Release, error) {
+	config, err := newHelmConfig("")
+	if err != nil {
+		return nil, err
+	}
+	list := action.NewList(config)
+	list.AllNamespaces = true
+	list.StateMask = action.ListAll
+	return list.Run()
+}
+
+// upgradeHelmImage deploys an image by upgrading the release with the image
+// set in its values, keeping every other value. Helm then rolls the
+// deployment out itself and the next `helm upgrade` won't revert the image.
+func upgradeHelmImage(ctx context.Context, name, namespace, image string) error {
+	config, err := newHelmConfig(namespace)
+	if err != nil {
+		return err
+	}
+	current, err := action.NewGet(config).Run(name)
+	if err != nil {
+		return fmt.Errorf("failed to get helm release %s: %v", name, err)
+	}
+
+	repository, tag := splitImageTag(image)
+	repositoryKey, tagKey := helmImageKeys()
+	overrides := make(map[string]interface{})
+	if err := strvals.ParseInto(fmt.Sprintf("%s=%s", repositoryKey, repository), overrides); err != nil {
+		return err
+	}
+	if err := strvals.ParseInto(fmt.Sprintf("%s=%s", tagKey, tag), overrides); err != nil {
+		return err
+	}
+
+	upgrade := action.NewUpgrade(config)
+	upgrade.Namespace = namespace
+	upgrade.ReuseValues = true
+	if _, err := upgrade.RunWithContext(ctx, name, current.Chart, overrides); err != nil {
+		return fmt.Errorf("helm upgrade of %s failed: %v", name, err)
+	}
+	journal(journalEntry{Kind: journalHelmUpgrade, Namespace: namespace, Name: name, Revision: current.Version})
+	return nil
+}
+
+// splitImageTag splits "host:5000/web:v1" into "host:5000/web" and "v1".
+func splitImageTag(image string) (repository, tag string) {
+	slash := strings.LastIndex(image, "/")
+	if colon := strings.LastIndex(image, ":"); colon > slash {
+		return image[:colon], image[colon+1:]
+	}
+	return image, "latest"
+}
+
+// lookupValue reads a dotted path such as image.tag from release values.
+func lookupValue(values map[string]interface{}, path string) string {
+	var current interface{} = values
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		current = m[key]
+	}
+	if current == nil {
+		return ""
+	}
+	return fmt.Sprint(current)
+}
+
+func runReleases(args []string) error {
+	fs := flag.NewFlagSet("releases", flag.ContinueOnError)
+	namespace := fs.String("namespace", "", "only list releases in this namespace")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	releases, err := listHelmReleases()
+	if err != nil {
+		return err
+	}
+
+	repositoryKey, tagKey := helmImageKeys()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tRELEASE\tREVISION\tSTATUS\tCHART\tIMAGE")
+	for _, rel := range releases {
+		if *namespace != "" && rel.Namespace != *namespace {
+			continue
+		}
+		// Values the user set win over the chart's defaults
+		image := lookupValue(rel.Config, repositoryKey)
+		if image == "" && rel.Chart != nil {
+			image = lookupValue(rel.Chart.Values, repositoryKey)
+		}
+		tag := lookupValue(rel.Config, tagKey)
+		if tag == "" && rel.Chart != nil {
+			tag = lookupValue(rel.Chart.Values, tagKey)
+		}
+		if tag != "" {
+			image += ":" + tag
+		}
+
+		chart := "unknown"
+		if rel.Chart != nil && rel.Chart.Metadata != nil {
+			chart = rel.Chart.Metadata.Name + "-" + rel.Chart.Metadata.Version
+		}
+		status := "unknown"
+		if rel.Info != nil {
+			status = rel.Info.Status.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", rel.Namespace, rel.Name, rel.Version, status, chart, image)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	osuser "os/user"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/backend"
+	"github.com/anthony-gilbert/local-container-registry/pkg/store"
+)
+
+// connectDatabase sets up the global db handle for headless commands. An
+// unreachable database only disables history, it never fails the command.
+func connectDatabase(ctx context.Context) {
+	handle, err := openDatabase()
+	if err == nil {
+		pingCtx, cancel := withBackendTimeout(ctx, backendDatabase)
+		err = handle.PingContext(pingCtx)
+		cancel()
+	}
+	if err != nil {
+		fmt.Printf("⚠️  Database unavailable, history will not be recorded: %v\n", err)
+		if handle != nil {
+			handle.Close()
+		}
+		return
+	}
+
+	db = handle
+	closeDatabaseOnShutdown()
+	if err := ensureSchema(ctx); err != nil {
+		log.Printf("failed to update database schema: %v", err)
+	}
+}
+
+// dataStore reads and writes the history and image inventory through the
+// global db handle, or is the store backend ctx carries, used even without
+// a database; nil without either.
+func dataStore(ctx context.Context) backend.Store {
+	if store := backendsOf(ctx).store; store != nil {
+		return store
+	}
+	if db == nil {
+		return nil
+	}
+	return store.New(db, backendTimeout(backendDatabase))
+}
+
+func ensureSchema(ctx context.Context) error {
+	if dataStore(ctx) == nil {
+		return nil
+	}
+	return dataStore(ctx).EnsureSchema(ctx)
+}
+
+type actorKey struct{}
+
+// withActor attributes the actions recorded under ctx to user, e.g. the
+// account an API request authenticated as.
+func withActor(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, actorKey{}, user)
+}
+
+// localActor is the user running the process, who acts from the TUI and
+// the command line.
+var localActor = sync.OnceValue(func() string {
+	if current, err := osuser.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+})
+
+// actorOf is who the actions recorded under ctx are attributed to.
+func actorOf(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok {
+		return actor
+	}
+	return localActor()
+}
+
+// recordHistory appends an entry to the history table and, when
+// AUDIT_LOG_FILE is set, to the JSONL audit log. History is best effort:
+// without a database, or if the insert fails, the action itself is not
+// affected.
+func recordHistory(ctx context.Context, action, target, status, details string) {
+	recordHistoryResult(ctx, action, target, status, details, nil)
+}
+
+// recordHistoryResult is recordHistory for actions whose outcome the bus's
+// subscribers take as a value, like a pruneResult, rather than from the
+// text of details. It is the history event's Data.
+func recordHistoryResult(ctx context.Context, action, target, status, details string, result any) {
+	actor := actorOf(ctx)
+	audit := auditEvent{
+		Time:    time.Now().UTC().Format(time.RFC3339Nano),
+		User:    actor,
+		Action:  action,
+		Target:  target,
+		Status:  status,
+		Details: details,
+	}
+	writeAuditEvent(audit)
+	// Published once recorded, so the audit forwarder finds it in the table
+	defer bus.publish(busEvent{Kind: eventHistory, Action: &audit, Data: result})
+
+	if dataStore(ctx) == nil {
+		return
+	}
+	dbCtx, span := startBackendSpan(ctx, "db insert history",
+		attribute.String("db.system", "mysql"),
+		attribute.String("lcr.action", action))
+	err := dataStore(ctx).RecordHistory(dbCtx, store.HistoryEntry{Actor: actor, Action: action, Target: target, Status: status, Details: details})
+	endSpan(span, err)
+	if err != nil {
+		log.Printf("failed to record %s history for %s: %v", action, target, err)
+	}
+}
+
+// recordActionResult records the outcome of an action that either succeeds
+// or fails with an error.
+func recordActionResult(ctx context.Context, action, target string, err error, details string) {
+	if err != nil {
+		recordHistory(ctx, action, target, "failed", err.Error())
+		return
+	}
+	recordHistory(ctx, action, target, "succeeded", details)
+}
+
+// auditEvent is one line of the audit log. Field names are stable so log
+// shippers (Vector, Fluent Bit, ...) can parse the file without the schema.
+type auditEvent struct {
+	Time    string `json:"time"`
+	User    string `json:"user,omitempty"`
+	Action  string `json:"action"`
+	Target  string `json:"target"`
+	Status  string `json:"status"`
+	Details string `json:"details,omitempty"`
+}
+
+var auditLog struct {
+	once   sync.Once
+	mu     sync.Mutex
+	file   *os.File
+	format string
+}
+
+// writeAuditEvent appends the event to AUDIT_LOG_FILE. The file is only ever
+// opened for appending, one JSON object or CEF event per line, so it can be
+// tailed and rotated by external tools.
+func writeAuditEvent(event auditEvent) {
+	auditLog.once.Do(func() {
+		path := os.Getenv("AUDIT_LOG_FILE")
+		if path == "" {
+			return
+		}
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Printf("failed to open audit log %s: %v", path, err)
+			return
+		}
+		auditLog.file = file
+		auditLog.format = auditLogFormat()
+	})
+	if auditLog.file == nil {
+		return
+	}
+
+	line := formatAuditEvent(event, auditLog.format)
+	auditLog.mu.Lock()
+	defer auditLog.mu.Unlock()
+	if _, err := auditLog.file.WriteString(line + "\n"); err != nil {
+		log.Printf("failed to write audit log: %v", err)
+	}
+}
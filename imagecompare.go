@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
+
+// maxListedLayers keeps the comparison dialog on screen
+const maxListedLayers = 8
+
+// imageInspection is what two tags of an image are compared by.
+type imageInspection struct {
+	reference  string
+	digest     string
+	layers     []registryDescriptor
+	size       int64 // compressed, as pushed
+	env        []string
+	entrypoint []string
+	cmd        []string
+	labels     map[string]string
+	// exposedPorts are the config's ExposedPorts, e.g. 8080/tcp, sorted
+	exposedPorts []string
+}
+
+// splitRegistryReference splits host/repository:tag or host/repository@digest.
+// References without a registry host are looked up in the active registry.
+func splitRegistryReference(ref string) (host, repository, reference string) {
+	return registry.SplitReference(ref, getRegistryHost())
+}
+
+// platformManifest is the image manifest of this machine's platform when
+// body is a manifest list or OCI index, falling back to the first one
+// listed, and body itself otherwise.
+func platformManifest(ctx context.Context, client registryClient, repository string, body []byte) ([]byte, error) {
+	var index struct {
+		Manifests []struct {
+			Digest   string `json:"digest"`
+			Platform struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(body, &index); err != nil || len(index.Manifests) == 0 {
+		return body, nil
+	}
+	child := index.Manifests[0].Digest
+	for _, manifest := range index.Manifests {
+		if manifest.Platform.OS == "linux" && manifest.Platform.Architecture == runtime.GOARCH {
+			child = manifest.Digest
+			break
+		}
+	}
+	body, _, err := client.Manifest(ctx, repository, child)
+	return body, err
+}
+
+// inspectRegistryImage reads an image's manifest and config from its
+// registry. Of a multi-platform image the platform of this machine is
+// inspected, falling back to the first one listed.
+func inspectRegistryImage(ctx context.Context, ref string) (imageInspection, error) {
+	host, repository, reference := splitRegistryReference(ref)
+	client := newRegistryClient(ctx, host)
+	body, _, err := client.Manifest(ctx, repository, reference)
+	if err != nil {
+		return imageInspection{}, fmt.Errorf("failed to fetch manifest of %s: %v", ref, err)
+	}
+	inspection := imageInspection{reference: ref, digest: registry.ComputeDigest(body)}
+
+	if body, err = platformManifest(ctx, client, repository, body); err != nil {
+		return imageInspection{}, fmt.Errorf("failed to fetch manifest of %s: %v", ref, err)
+	}
+
+	var manifest registryManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return imageInspection{}, fmt.Errorf("failed to parse manifest of %s: %v", ref, err)
+	}
+	if !imageConfigTypes[manifest.Config.MediaType] {
+		return imageInspection{}, fmt.Errorf("%s isn't a container image", ref)
+	}
+	inspection.layers = manifest.Layers
+	inspection.size = manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		inspection.size += layer.Size
+	}
+
+	configBlob, err := client.Blob(ctx, repository, manifest.Config.Digest)
+	if err != nil {
+		return imageInspection{}, fmt.Errorf("failed to fetch config of %s: %v", ref, err)
+	}
+	var config struct {
+		Config struct {
+			Env          []string            `json:"Env"`
+			Entrypoint   []string            `json:"Entrypoint"`
+			Cmd          []string            `json:"Cmd"`
+			Labels       map[string]string   `json:"Labels"`
+			ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(configBlob, &config); err != nil {
+		return imageInspection{}, fmt.Errorf("failed to parse config of %s: %v", ref, err)
+	}
+	inspection.env = config.Config.Env
+	inspection.entrypoint = config.Config.Entrypoint
+	inspection.cmd = config.Config.Cmd
+	inspection.labels = config.Config.Labels
+	for port := range config.Config.ExposedPorts {
+		inspection.exposedPorts = append(inspection.exposedPorts, port)
+	}
+	sort.Strings(inspection.exposedPorts)
+	return inspection, nil
+}
+
+// diffKeyValues lists added (+), removed (-) and changed (~) keys, sorted.
+func diffKeyValues(before, after map[string]string) []string {
+	keys := make(map[string]bool)
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []string
+	for _, key := range sorted {
+		old, hadOld := before[key]
+		value, hasNew := after[key]
+		switch {
+		case !hadOld:
+			changes = append(changes, fmt.Sprintf("+ %s=%s", key, value))
+		case !hasNew:
+			changes = append(changes, fmt.Sprintf("- %s=%s", key, old))
+		case old != value:
+			changes = append(changes, fmt.Sprintf("~ %s: %s → %s", key, old, value))
+		}
+	}
+	return changes
+}
+
+func envMap(env []string) map[string]string {
+	values := make(map[string]string, len(env))
+	for _, entry := range env {
+		key, value, _ := strings.Cut(entry, "=")
+		values[key] = value
+	}
+	return values
+}
+
+// formatSizeDelta is e.g. "+4.2MB" or "-120.0KB".
+func formatSizeDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + formatBytes(-delta)
+	}
+	return "+" + formatBytes(delta)
+}
+
+// compareImages describes what changed from image a to image b, one line
+// per difference, for the TUI dialog and the compare command alike.
+func compareImages(a, b imageInspection) []string {
+	if a.digest == b.digest {
+		return []string{fmt.Sprintf("Identical: both point at %s", shortDigest(a.digest))}
+	}
+	lines := []string{
+		fmt.Sprintf("Digest      %s → %s", shortDigest(a.digest), shortDigest(b.digest)),
+		fmt.Sprintf("Size        %s → %s (%s)", formatBytes(a.size), formatBytes(b.size), formatSizeDelta(b.size-a.size)),
+	}
+
+	inA := make(map[string]bool, len(a.layers))
+	for _, layer := range a.layers {
+		inA[layer.Digest] = true
+	}
+	inB := make(map[string]bool, len(b.layers))
+	for _, layer := range b.layers {
+		inB[layer.Digest] = true
+	}
+	var removed, added []string
+	for _, layer := range a.layers {
+		if !inB[layer.Digest] {
+			removed = append(removed, fmt.Sprintf("  - %s (%s)", shortDigest(layer.Digest), formatBytes(layer.Size)))
+		}
+	}
+	for _, layer := range b.layers {
+		if !inA[layer.Digest] {
+			added = append(added, fmt.Sprintf("  + %s (%s)", shortDigest(layer.Digest), formatBytes(layer.Size)))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("Layers      %d shared, %d removed, %d added", len(b.layers)-len(added), len(removed), len(added)))
+	for _, changes := range [][]string{removed, added} {
+		if len(changes) > maxListedLayers {
+			changes = append(changes[:maxListedLayers], fmt.Sprintf("  … %d more", len(changes)-maxListedLayers))
+		}
+		lines = append(lines, changes...)
+	}
+
+	for _, command := range []struct {
+		name          string
+		before, after []string
+	}{
+		{"Entrypoint", a.entrypoint, b.entrypoint},
+		{"Cmd", a.cmd, b.cmd},
+	} {
+		before, after := strings.Join(command.before, " "), strings.Join(command.after, " ")
+		if before == after {
+			lines = append(lines, fmt.Sprintf("%-11s unchanged", command.name))
+		} else {
+			lines = append(lines, fmt.Sprintf("%-11s %q → %q", command.name, before, after))
+		}
+	}
+
+	for _, section := range []struct {
+		name    string
+		changes []string
+	}{
+		{"Env", diffKeyValues(envMap(a.env), envMap(b.env))},
+		{"Labels", diffKeyValues(a.labels, b.labels)},
+	} {
+		if len(section.changes) == 0 {
+			lines = append(lines, fmt.Sprintf("%-11s unchanged", section.name))
+			continue
+		}
+		lines = append(lines, section.name)
+		for _, change := range section.changes {
+			lines = append(lines, "  "+change)
+		}
+	}
+	return lines
+}
+
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	addRegistryFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: local-container-registry compare <image> <image>")
+		fmt.Fprintln(fs.Output(), "\nImages without a registry host are read from REGISTRY_HOST, e.g. web:41 web:42")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("compare needs two images")
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	a, err := inspectRegistryImage(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := inspectRegistryImage(ctx, fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🔍 %s → %s\n\n", a.reference, b.reference)
+	for _, line := range compareImages(a, b) {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+type imageComparisonMsg struct {
+	base, target string
+	lines        []string
+	err          error
+}
+
+func (m model) loadImageComparison(base, target string) tea.Cmd {
+	ctx := m.tabCtx
+	return func() tea.Msg {
+		msg := imageComparisonMsg{base: base, target: target}
+		a, err := inspectRegistryImage(ctx, base)
+		if err != nil {
+			msg.err = err
+			return msg
+		}
+		b, err := inspectRegistryImage(ctx, target)
+		if err != nil {
+			msg.err = err
+			return msg
+		}
+		msg.lines = compareImages(a, b)
+		return msg
+	}
+}
+
+// markForComparison marks the highlighted image on the first press and
+// compares the marked image with the highlighted one on the second.
+func (m *model) markForComparison(item TableData) tea.Cmd {
+	switch {
+	case imageRegistryHost(item.ImageTag) == "" || item.ArtifactType != "":
+		m.statusMessage = "Only container images in a registry can be compared"
+		return nil
+	case m.compareBase == "" || m.compareBase == item.ImageTag:
+		m.compareBase = item.ImageTag
+		m.statusMessage = fmt.Sprintf("📌 Marked %s, press m on another tag to compare", item.ImageTag)
+		return nil
+	}
+	m.imageComparison = &imageComparisonMsg{base: m.compareBase, target: item.ImageTag}
+	m.compareBase = ""
+	m.showImageComparison = true
+	return m.loadImageComparison(m.imageComparison.base, m.imageComparison.target)
+}
+
+func (m model) updateImageComparison(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "q", "m":
+		m.showImageComparison = false
+	}
+	return m, nil
+}
+
+func (m model) renderImageComparison() string {
+	var b strings.Builder
+	comparison := m.imageComparison
+	fmt.Fprintf(&b, "Compare %s\n     to %s\n\n", comparison.base, comparison.target)
+	switch {
+	case comparison.err != nil:
+		fmt.Fprintf(&b, "⚠️  %v\n", comparison.err)
+	case comparison.lines == nil:
+		b.WriteString("Reading manifests and configs...\n")
+	default:
+		for _, line := range comparison.lines {
+			b.WriteString(truncateString(line, 96) + "\n")
+		}
+	}
+	b.WriteString("\nESC to close")
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, modalStyle.Width(100).Height(0).Render(b.String()), lipgloss.WithWhitespaceChars("░"))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/store"
+)
+
+// Every refresh of the Docker tab records the registry's images in the
+// image_records table, one row per tag and digest, so the database keeps an
+// inventory of what was pushed when, and how big each build was, after the
+// tags have been overwritten or pruned.
+
+// revisionLabel is the OCI label builds put their source commit in.
+const revisionLabel = "org.opencontainers.image.revision"
+
+// newImageRecord describes a registry image for image_records; ok is false
+// for local images, which have no digest to tell builds apart.
+func newImageRecord(image DockerImage) (record store.ImageRecord, ok bool) {
+	if image.Digest == "" || len(image.RepoTags) == 0 {
+		return store.ImageRecord{}, false
+	}
+	host, repository, tag := splitRegistryReference(image.RepoTags[0])
+	record = store.ImageRecord{
+		Registry:     host,
+		Repository:   repository,
+		Tag:          tag,
+		Digest:       image.Digest,
+		SizeBytes:    image.SizeBytes,
+		SourceCommit: image.Labels[revisionLabel],
+	}
+	if created, err := time.ParseInLocation("2006-01-02 15:04:05", image.CreatedAt, time.Local); err == nil {
+		record.Created = &created
+	}
+	if commit, ok := tagCommit(tag); record.SourceCommit == "" && ok {
+		record.SourceCommit = commit
+	}
+	return record, true
+}
+
+// recordImages upserts the images in one statement: a digest first seen
+// under a tag gets a row with pushed_at set to now, one seen before just
+// has last_seen moved on. Like history, this is best effort.
+func recordImages(ctx context.Context, images []DockerImage) {
+	if dataStore(ctx) == nil {
+		return
+	}
+	var records []store.ImageRecord
+	for _, image := range images {
+		if record, ok := newImageRecord(image); ok {
+			records = append(records, record)
+		}
+	}
+	if err := dataStore(ctx).RecordImages(ctx, records); err != nil {
+		log.Printf("failed to record %d images: %v", len(records), err)
+	}
+}
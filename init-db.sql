@@ -5,5 +5,78 @@ CREATE TABLE IF NOT EXISTS images (
     id INT AUTO_INCREMENT PRIMARY KEY,
     commit_sha VARCHAR(255),
     PR_Description TEXT,
+    author VARCHAR(255),
+    committer VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS history (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    action VARCHAR(64) NOT NULL,
+    target VARCHAR(512),
+    status VARCHAR(32),
+    details TEXT,
+    actor VARCHAR(64),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS image_records (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    registry VARCHAR(191) NOT NULL,
+    repository VARCHAR(255) NOT NULL,
+    tag VARCHAR(128) NOT NULL,
+    digest VARCHAR(100) NOT NULL,
+    size_bytes BIGINT,
+    image_created DATETIME,
+    source_commit VARCHAR(64),
+    pushed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY image_record (registry, repository, tag, digest),
+    KEY image_record_repository (registry, repository, pushed_at)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    username VARCHAR(64) NOT NULL UNIQUE,
+    email VARCHAR(255),
+    password_hash VARCHAR(72) NOT NULL,
+    role VARCHAR(16) NOT NULL DEFAULT 'viewer',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at DATETIME,
+    last_used TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS tab_snapshots (
+    name VARCHAR(191) PRIMARY KEY,
+    content MEDIUMBLOB NOT NULL,
+    saved_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS deploy_profiles (
+    image VARCHAR(191) PRIMARY KEY,
+    content TEXT NOT NULL,
+    saved_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS deploy_rules (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    repository VARCHAR(255) NOT NULL,
+    tag_pattern VARCHAR(128) NOT NULL,
+    namespace VARCHAR(63) NOT NULL,
+    deployment VARCHAR(253) NOT NULL,
+    container VARCHAR(63) NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    last_image VARCHAR(512),
+    last_status VARCHAR(32),
+    last_run TIMESTAMP NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -0,0 +1,546 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+	"github.com/anthony-gilbert/local-container-registry/pkg/store"
+)
+
+// serve runs recurring maintenance on a schedule: refreshing the registry's
+// images, pruning, garbage collection, mirroring and vulnerability rescans.
+// JOBS schedules them as name=schedule entries separated by semicolons, e.g.
+//
+//	JOBS=refresh=@every 10m;prune=0 3 * * *;gc=30 3 * * 0;sync=@hourly;rescan=@daily
+//
+// A schedule is a five-field cron expression (minute, hour, day of month,
+// month, day of week, in local time), @hourly, @daily, @weekly, @monthly or
+// @every <duration>. RETENTION_SCHEDULE and SYNC_SCHEDULE, which predate
+// JOBS, still run prune and sync every interval when JOBS doesn't schedule
+// them. Each run is recorded in the history as a "job" action, where the
+// Jobs view (J) and the jobs command find the last one.
+
+// jobKind is what a job named after it does.
+type jobKind struct {
+	description string
+	run         func(ctx context.Context) error
+}
+
+// jobNames are the jobs in the order they are listed.
+var jobNames = []string{"refresh", "prune", "gc", "sync", "rescan"}
+
+var jobKinds = map[string]jobKind{
+	"refresh": {"re-read the registry's images and record new builds", refreshJob},
+	"prune":   {"delete tags by the retention policy (RETENTION_*)", pruneJob},
+	"gc":      {"garbage-collect the registry's unreferenced blobs", garbageCollectJob},
+	"sync":    {"mirror the registry to SYNC_REMOTE", syncJob},
+	"rescan":  {"rescan every image for vulnerabilities (Harbor)", rescanJob},
+}
+
+type scheduledJob struct {
+	name     string
+	spec     string
+	schedule schedule
+}
+
+// configuredJobs are the jobs JOBS schedules, in jobNames order. Entries
+// that don't parse are left out and reported in the error.
+func configuredJobs() ([]scheduledJob, error) {
+	specs := make(map[string]string)
+	var problems []error
+	for _, entry := range strings.Split(os.Getenv("JOBS"), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(entry, "=")
+		name, spec = strings.TrimSpace(name), strings.TrimSpace(spec)
+		if _, known := jobKinds[name]; !ok || !known {
+			problems = append(problems, fmt.Errorf("JOBS entry %q is not job=schedule, with a job of %s", entry, strings.Join(jobNames, ", ")))
+			continue
+		}
+		specs[name] = spec
+	}
+	if value := os.Getenv("RETENTION_SCHEDULE"); value != "" && specs["prune"] == "" {
+		specs["prune"] = "@every " + value
+	}
+	if value := os.Getenv("SYNC_SCHEDULE"); value != "" && specs["sync"] == "" && os.Getenv("SYNC_REMOTE") != "" {
+		specs["sync"] = "@every " + value
+	}
+
+	var jobs []scheduledJob
+	for _, name := range jobNames {
+		spec, ok := specs[name]
+		if !ok {
+			continue
+		}
+		schedule, err := parseSchedule(spec)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("schedule of %s: %v", name, err))
+			continue
+		}
+		jobs = append(jobs, scheduledJob{name: name, spec: spec, schedule: schedule})
+	}
+	return jobs, errors.Join(problems...)
+}
+
+// jobState is what serve knows of a job's runs, for the API.
+type jobState struct {
+	running bool
+	next    time.Time
+	lastRun time.Time
+	took    time.Duration
+	err     error
+}
+
+var jobStates struct {
+	sync.Mutex
+	names  []string
+	byName map[string]*jobState
+}
+
+func updateJobState(name string, update func(*jobState)) {
+	jobStates.Lock()
+	defer jobStates.Unlock()
+	if jobStates.byName == nil {
+		jobStates.byName = make(map[string]*jobState)
+	}
+	state, ok := jobStates.byName[name]
+	if !ok {
+		state = &jobState{}
+		jobStates.byName[name] = state
+		jobStates.names = append(jobStates.names, name)
+	}
+	update(state)
+}
+
+// startJobs runs each scheduled job at its times until ctx is done. A job
+// still running when its next time comes skips that time.
+func startJobs(ctx context.Context) {
+	jobs, err := configuredJobs()
+	if err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+	for _, job := range jobs {
+		go func() {
+			for {
+				next := job.schedule.next(time.Now())
+				if next.IsZero() {
+					log.Printf("job %s never runs: %s matches no date", job.name, job.spec)
+					return
+				}
+				updateJobState(job.name, func(state *jobState) { state.next = next })
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Until(next)):
+				}
+				if err := runJob(ctx, job.name); err != nil {
+					log.Printf("scheduled %s failed: %v", job.name, err)
+				}
+			}
+		}()
+	}
+}
+
+// runJob runs a job once and records the run in the history.
+func runJob(ctx context.Context, name string) (err error) {
+	kind, ok := jobKinds[name]
+	if !ok {
+		return fmt.Errorf("unknown job %q (have %s)", name, strings.Join(jobNames, ", "))
+	}
+	ctx, span := startSpan(ctx, "job "+name, attribute.String("lcr.job", name))
+	defer func() { endSpan(span, err) }()
+
+	start := time.Now()
+	updateJobState(name, func(state *jobState) { state.running = true })
+	err = kind.run(ctx)
+	took := time.Since(start).Round(time.Millisecond)
+	updateJobState(name, func(state *jobState) {
+		state.running, state.lastRun, state.took, state.err = false, start, took, err
+	})
+	recordActionResult(ctx, "job", name, err, "took "+took.String())
+	return err
+}
+
+func refreshJob(ctx context.Context) error {
+	client := newRegistryClient(ctx, getRegistryHost())
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("registry %s unreachable: %v", client.Host(), err)
+	}
+	_, err := getDockerImagesInfo(ctx)
+	return err
+}
+
+func pruneJob(ctx context.Context) error {
+	policy, err := loadRetentionPolicy()
+	if err != nil {
+		return err
+	}
+	if !policy.prunesTags() && !policy.untagged {
+		return fmt.Errorf("no retention policy: set RETENTION_KEEP_LAST, RETENTION_MAX_AGE and/or RETENTION_UNTAGGED")
+	}
+	return pruneRegistry(ctx, newRegistryClient(ctx, getRegistryHost()), policy, nil, false)
+}
+
+// garbageCollectJob deletes the blobs no manifest references any more, e.g.
+// those of tags prune deleted. Harbor is asked to through its API; a plain
+// distribution registry runs `registry garbage-collect` in its container,
+// REGISTRY_CONTAINER (default local-container-registry). Pushes during the
+// collection can lose blobs, so schedule it when nobody pushes.
+func garbageCollectJob(ctx context.Context) error {
+	client := newRegistryClient(ctx, getRegistryHost())
+	switch client.Flavor(ctx) {
+	case registry.FlavorHarbor:
+		return client.HarborGarbageCollect(ctx)
+	case registry.FlavorZot:
+		return fmt.Errorf("zot collects garbage itself, see gc in its storage config")
+	}
+	container := envOrDefault("REGISTRY_CONTAINER", "local-container-registry")
+	config := envOrDefault("REGISTRY_CONFIG_PATH", "/etc/docker/registry/config.yml")
+	output, err := exec.CommandContext(ctx, "docker", "exec", container, "registry", "garbage-collect", config).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("registry garbage-collect in container %s failed: %v: %s", container, err, truncateString(strings.TrimSpace(string(output)), 200))
+	}
+	return nil
+}
+
+func syncJob(ctx context.Context) error {
+	remote := os.Getenv("SYNC_REMOTE")
+	if remote == "" {
+		return fmt.Errorf("SYNC_REMOTE is not set")
+	}
+	var repositories []string
+	if repos := os.Getenv("SYNC_REPOS"); repos != "" {
+		repositories = strings.Split(repos, ",")
+	}
+	syncer := &registrySyncer{
+		src:      newRegistryClient(ctx, getRegistryHost()),
+		dst:      newRegistryClient(ctx, remote),
+		state:    loadSyncState(),
+		retries:  syncRetries(),
+		parallel: uploadParallelism(),
+	}
+	return syncer.run(ctx, repositories, false)
+}
+
+// rescanJob asks Harbor to scan every tag again, so images pushed long ago
+// are checked against the vulnerabilities found since. zot rescans by itself.
+func rescanJob(ctx context.Context) error {
+	client := newRegistryClient(ctx, getRegistryHost())
+	if client.Flavor(ctx) != registry.FlavorHarbor {
+		return fmt.Errorf("%s doesn't scan on request; only Harbor does", client.Host())
+	}
+	repositories, err := listRepositories(ctx, client)
+	if err != nil {
+		return fmt.Errorf("failed to list repositories on %s: %v", client.Host(), err)
+	}
+	scanned, failed := 0, 0
+	for _, repo := range repositories {
+		tags, err := client.Tags(ctx, repo)
+		if err != nil {
+			failed++
+			continue
+		}
+		for _, tag := range tags {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err := client.HarborScan(ctx, repo, tag); err != nil {
+				log.Printf("rescan of %s:%s failed: %v", repo, tag, err)
+				failed++
+				continue
+			}
+			scanned++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d scans could not be started", failed, scanned+failed)
+	}
+	return nil
+}
+
+// schedule is when a job runs.
+type schedule interface {
+	// next is the first time after after, zero for never.
+	next(after time.Time) time.Time
+}
+
+type everySchedule time.Duration
+
+func (e everySchedule) next(after time.Time) time.Time {
+	return after.Add(time.Duration(e))
+}
+
+// cronSchedule is a five-field cron expression, each field a bit set of the
+// values it matches.
+type cronSchedule struct {
+	minute, hour, day, month, weekday uint64
+	// A day of month or week of * leaves the day to the other field; when
+	// both are given, either matching is enough, as in cron
+	anyDay, anyWeekday bool
+}
+
+var scheduleMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+func parseSchedule(spec string) (schedule, error) {
+	if value, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("@every needs a positive duration like 30m, not %q", value)
+		}
+		return everySchedule(interval), nil
+	}
+	if expression, ok := scheduleMacros[spec]; ok {
+		spec = expression
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%q is not a cron expression (minute hour day month weekday) or @every <duration>", spec)
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", field, err)
+		}
+		sets[i] = set
+	}
+	// Sunday is 0 or 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return cronSchedule{
+		minute: sets[0], hour: sets[1], day: sets[2], month: sets[3], weekday: sets[4],
+		anyDay: fields[2] == "*", anyWeekday: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField accepts *, values, ranges (a-b) and steps (*/n, a-b/n, a/n),
+// separated by commas.
+func parseCronField(field string, low, high int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		values, stepValue, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepValue); err != nil || step < 1 {
+				return 0, fmt.Errorf("step %q is not a positive number", stepValue)
+			}
+		}
+		start, end := low, high
+		if values != "*" {
+			first, last, isRange := strings.Cut(values, "-")
+			var err error
+			if start, err = strconv.Atoi(first); err != nil {
+				return 0, fmt.Errorf("%q is not a number", first)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(last); err != nil {
+					return 0, fmt.Errorf("%q is not a number", last)
+				}
+			} else if hasStep {
+				end = high
+			}
+		}
+		if start < low || end > high || start > end {
+			return 0, fmt.Errorf("%s is outside %d-%d", values, low, high)
+		}
+		for value := start; value <= end; value += step {
+			set |= 1 << value
+		}
+	}
+	return set, nil
+}
+
+func (c cronSchedule) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// Five years covers every date that exists, e.g. the 29th of February
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c cronSchedule) dayMatches(t time.Time) bool {
+	day := c.day&(1<<t.Day()) != 0
+	weekday := c.weekday&(1<<int(t.Weekday())) != 0
+	switch {
+	case c.anyDay:
+		return weekday
+	case c.anyWeekday:
+		return day
+	}
+	return day || weekday
+}
+
+// lastJobRuns is the last recorded run of each job, by name.
+func lastJobRuns(ctx context.Context) (map[string]store.HistoryEntry, error) {
+	if dataStore(ctx) == nil {
+		return nil, fmt.Errorf("no database to read the runs from")
+	}
+	entries, err := dataStore(ctx).LatestHistory(ctx, "job")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the job runs: %v", err)
+	}
+	runs := make(map[string]store.HistoryEntry)
+	for _, entry := range entries {
+		runs[entry.Target] = entry
+	}
+	return runs, nil
+}
+
+// jobLines lists the scheduled jobs with their next and last run, for the
+// Jobs view and the jobs command.
+func jobLines(jobs []scheduledJob, runs map[string]store.HistoryEntry, now time.Time) []string {
+	lines := []string{fmt.Sprintf("%-8s %-18s %-16s %-16s %s", "JOB", "SCHEDULE", "NEXT RUN", "LAST RUN", "RESULT")}
+	for _, job := range jobs {
+		next := "never"
+		if t := job.schedule.next(now); !t.IsZero() {
+			next = t.Format("2006-01-02 15:04")
+		}
+		last, result := "not yet", ""
+		if run, ok := runs[job.name]; ok {
+			last = run.Time.Format("2006-01-02 15:04")
+			result = run.Status
+			if run.Details != "" {
+				result += ", " + run.Details
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%-8s %-18s %-16s %-16s %s", job.name, truncateString(job.spec, 18), next, last, truncateString(result, 60)))
+	}
+	return lines
+}
+
+func runJobs(args []string) error {
+	fs := flag.NewFlagSet("jobs", flag.ContinueOnError)
+	addRegistryFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: local-container-registry jobs [run <job>]")
+		fmt.Fprintf(fs.Output(), "\nLists the jobs JOBS schedules with their next and last run; run runs one now.\nJobs: %s\n", strings.Join(jobNames, ", "))
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	connectDatabase(ctx)
+
+	switch {
+	case fs.NArg() == 2 && fs.Arg(0) == "run":
+		name := fs.Arg(1)
+		kind, ok := jobKinds[name]
+		if !ok {
+			return fmt.Errorf("unknown job %q (have %s)", name, strings.Join(jobNames, ", "))
+		}
+		fmt.Printf("⏱️  Running %s: %s\n", name, kind.description)
+		if err := runJob(ctx, name); err != nil {
+			return err
+		}
+		fmt.Printf("✅ %s done\n", name)
+		return nil
+	case fs.NArg() != 0:
+		fs.Usage()
+		return fmt.Errorf("unknown jobs arguments %q", strings.Join(fs.Args(), " "))
+	}
+
+	jobs, err := configuredJobs()
+	if err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+	if len(jobs) == 0 {
+		fmt.Println("No jobs scheduled; set JOBS, e.g. JOBS=prune=0 3 * * *;gc=30 3 * * 0")
+		return nil
+	}
+	runs, err := lastJobRuns(ctx)
+	if err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+	for _, line := range jobLines(jobs, runs, time.Now()) {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+type jobsMsg struct {
+	lines []string // nil while loading
+	err   error
+}
+
+func (m model) loadJobs() tea.Cmd {
+	ctx := m.ctx
+	return func() tea.Msg {
+		jobs, err := configuredJobs()
+		runs, runsErr := lastJobRuns(ctx)
+		return jobsMsg{lines: jobLines(jobs, runs, time.Now()), err: errors.Join(err, runsErr)}
+	}
+}
+
+func (m model) updateJobs(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "q", "J":
+		m.showJobs = false
+	case "r":
+		return m, m.loadJobs()
+	}
+	return m, nil
+}
+
+func (m model) renderJobs() string {
+	var b strings.Builder
+	b.WriteString("Scheduled jobs, run by serve\n\n")
+	switch {
+	case m.jobs == nil:
+		b.WriteString("Reading the job runs...\n")
+	case len(m.jobs.lines) <= 1 && m.jobs.err == nil:
+		b.WriteString("No jobs scheduled; set JOBS, e.g. JOBS=prune=0 3 * * *;gc=30 3 * * 0\n")
+	default:
+		for _, line := range m.jobs.lines {
+			b.WriteString(line + "\n")
+		}
+		if m.jobs.err != nil {
+			fmt.Fprintf(&b, "\n⚠️  %v\n", m.jobs.err)
+		}
+	}
+	b.WriteString("\nr to reload, ESC to close")
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, modalStyle.Width(110).Height(0).Render(b.String()), lipgloss.WithWhitespaceChars("░"))
+}
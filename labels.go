@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// imageMetadata reads the labels of an image's config blob and the
+// annotations of its manifest. Artifacts have annotations only.
+func imageMetadata(ctx context.Context, client registryClient, repository string, body []byte) (labels, annotations map[string]string) {
+	var manifest struct {
+		Config      registryDescriptor `json:"config"`
+		Annotations map[string]string  `json:"annotations"`
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, nil
+	}
+	if !imageConfigTypes[manifest.Config.MediaType] {
+		return nil, manifest.Annotations
+	}
+	var config struct {
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"config"`
+	}
+	if content, err := client.Blob(ctx, repository, manifest.Config.Digest); err == nil && json.Unmarshal(content, &config) == nil {
+		labels = config.Config.Labels
+	}
+	return labels, manifest.Annotations
+}
+
+// addLocalImageLabels fills in the labels of local images with a single
+// docker image inspect of all of them.
+func addLocalImageLabels(ctx context.Context, images []DockerImage) {
+	if len(images) == 0 {
+		return
+	}
+	ids := make([]string, len(images))
+	for i, image := range images {
+		ids[i] = image.ID
+	}
+	labels, err := containerRuntime(ctx).Labels(ctx, ids...)
+	if err != nil {
+		return
+	}
+	for i := range images {
+		images[i].Labels = labels[i]
+	}
+}
+
+// matchesImageSearch reports whether an image matches every term of a
+// Docker tab search. key=value matches a label or annotation whose value
+// contains value, key= any image having the key, and other terms the image
+// name or a label or annotation value.
+func matchesImageSearch(item TableData, query string) bool {
+	for _, term := range strings.Fields(strings.ToLower(query)) {
+		if !matchesImageSearchTerm(item, term) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesImageSearchTerm(item TableData, term string) bool {
+	key, value, byKey := strings.Cut(term, "=")
+	if !byKey && strings.Contains(strings.ToLower(item.ImageTag), term) {
+		return true
+	}
+	for _, metadata := range []map[string]string{item.Labels, item.Annotations} {
+		for k, v := range metadata {
+			v = strings.ToLower(v)
+			if byKey && strings.ToLower(k) == key && strings.Contains(v, value) {
+				return true
+			}
+			if !byKey && strings.Contains(v, term) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sortedKeys is the keys of a label or annotation map in order.
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// imageDetailRows is the image detail screen: what the Docker tab shows,
+// followed by the image's labels and annotations.
+func imageDetailRows(item TableData) []table.Row {
+	rows := []table.Row{
+		{"Image", item.ImageTag},
+		{"Image ID", item.ImageID},
+		{"Type", artifactLabel(item)},
+		{"Size", item.ImageSize},
+		{"Created", item.CreatedAt},
+	}
+	if item.ImageDigest != "" {
+		rows = append(rows, table.Row{"Digest", item.ImageDigest})
+	}
+	if item.InUse != "" {
+		rows = append(rows, table.Row{"In Use", item.InUse})
+	}
+	if item.Scan != "" {
+		rows = append(rows, table.Row{"Scan", item.Scan})
+	}
+	if len(item.Labels) == 0 && len(item.Annotations) == 0 {
+		rows = append(rows, table.Row{"Labels", "None"})
+	}
+	for _, key := range sortedKeys(item.Labels) {
+		rows = append(rows, table.Row{"Label " + key, truncateString(item.Labels[key], 70)})
+	}
+	for _, key := range sortedKeys(item.Annotations) {
+		rows = append(rows, table.Row{"Annotation " + key, truncateString(item.Annotations[key], 70)})
+	}
+	return rows
+}
+
+// showImageDetails opens the detail screen for a Docker tab image.
+func (m *model) showImageDetails(item TableData) {
+	m.detailImage = item.ImageTag
+	m.showImageDef = true
+	m.imageDefTable = newDetailTable(imageDetailRows(item))
+	if m.height > 0 {
+		m.imageDefTable.SetWidth(m.width)
+		m.imageDefTable.SetHeight(m.height - 15)
+	}
+}
+
+func (m model) updateImageDef(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "q", "i":
+		m.showImageDef = false
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.imageDefTable, cmd = m.imageDefTable.Update(msg)
+	return m, cmd
+}
+
+// startImageSearch focuses the Docker tab's search box.
+func (m *model) startImageSearch() tea.Cmd {
+	m.searchInput = textinput.New()
+	m.searchInput.Prompt = "/"
+	m.searchInput.Placeholder = "name, label value or label=value"
+	m.searchInput.SetValue(m.searchQuery)
+	m.searching = true
+	return m.searchInput.Focus()
+}
+
+// updateImageSearch filters the Docker tab as the search is typed. Enter
+// keeps the filter, ESC clears it.
+func (m model) updateImageSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "enter":
+		m.searching = false
+		return m, nil
+	case "esc":
+		m.searching = false
+		m.searchQuery = ""
+		m.table.SetCursor(0)
+		m.updateTableForTab()
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.searchInput, cmd = m.searchInput.Update(msg)
+	if m.searchInput.Value() != m.searchQuery {
+		m.searchQuery = m.searchInput.Value()
+		m.table.SetCursor(0)
+		m.updateTableForTab()
+	}
+	return m, cmd
+}
+
+// renderImageSearchStatus is the Docker tab's line about the search.
+func (m model) renderImageSearchStatus() string {
+	if m.searching {
+		return m.searchInput.View() + "  (Enter to keep, ESC to clear)"
+	}
+	return fmt.Sprintf("🔍 Showing images matching %q · / to change, ESC to clear", m.searchQuery)
+}
@@ -3,35 +3,34 @@ package main
 //go:generate go run build.go
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/table"
 	"github.com/go-sql-driver/mysql"
-	"github.com/google/go-github/v63/github"
 	"github.com/joho/godotenv"
+	"go.opentelemetry.io/otel/attribute"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
-)
 
-type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
+	"github.com/anthony-gilbert/local-container-registry/pkg/kube"
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
 
 type Repositories struct {
 	id            int64
@@ -46,24 +45,58 @@ type DockerImage struct {
 	ID        string
 	RepoTags  []string
 	Size      string
+	SizeBytes int64 // 0 when unknown
 	CreatedAt string
+	Digest    string // manifest digest, only known for registry images
+	Scan      string // vulnerability scan summary from Harbor or zot
+	// Set for OCI artifacts that aren't container images, see describeArtifact
+	ArtifactType   string
+	ArtifactDetail string
+	Labels         map[string]string // from the image config
+	Annotations    map[string]string // from the manifest
 }
 
 type TableData struct {
-	CommitSHA     string
-	PRDescription string
-	ImageID       string
-	ImageSize     string
-	ImageTag      string
-	PushedAt      string
-	CreatedAt     string
+	CommitSHA      string
+	PRDescription  string
+	Author         string // of a commit
+	Committer      string
+	ImageID        string
+	ImageSize      string
+	ImageTag       string
+	PushedAt       string
+	CreatedAt      string
+	ImageDigest    string
+	InUse          string // workloads referencing the image, "No" or "Unknown"
+	LocalState     string // whether a local image of the same name matches the registry
+	LocalRef       string // local tag of a diverged copy
+	Source         string // where a cached registry image came from
+	HelmRelease    string // Helm release managing a deployment
+	AppName        string // Argo CD application
+	Health         string // Argo CD health status
+	Drift          string // how a deployed image compares with the registry's tags
+	Scan           string // vulnerability scan summary, empty for plain registries
+	ArtifactType   string // kind of a non-image OCI artifact, e.g. "Helm chart"
+	ArtifactDetail string
+	Labels         map[string]string
+	Annotations    map[string]string
+	// Node overview fields: requests or usage against allocatable
+	KubeletVersion  string
+	NodeCPU         string
+	NodeMemory      string
+	NodeCPUUsage    string
+	NodeMemoryUsage string
 	// Kubernetes specific fields
+	Cluster   string // set in the all-clusters pods view
 	PodName   string
 	Namespace string
 	Status    string
 	Restarts  string
 	Age       string
 	NodeName  string
+	// Containers of a deployment and, in the same order, their images
+	Containers      []string
+	ContainerImages []string
 }
 
 // This init() function loads in the .env file into environment variables
@@ -78,20 +111,55 @@ func setupLogging() {
 	// Redirect logs to a file to avoid interfering with TUI
 	logFile, err := os.OpenFile("app.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
-		// If we can't create log file, just discard logs
-		log.SetOutput(io.Discard)
+		// If we can't create log file, only crash reports get the logs
+		log.SetOutput(recentLogs)
 		return
 	}
-	log.SetOutput(logFile)
+	log.SetOutput(io.MultiWriter(logFile, recentLogs))
 }
 
 func disableLogging() {
-	// Disable logging output to not interfere with TUI
-	log.SetOutput(io.Discard)
+	// Keep logging output off the TUI, only for crash reports
+	log.SetOutput(recentLogs)
 }
 
 var db *sql.DB
 
+// openDatabase returns a handle for the MySQL database described by the
+// MYSQL_* environment variables. Like sql.Open it does not connect yet.
+func openDatabase() (*sql.DB, error) {
+	// Capture connection properties for the MySQL database
+	cfg := mysql.NewConfig()
+	cfg.User = os.Getenv("MYSQL_USER")
+	if cfg.User == "" {
+		cfg.User = "mysql"
+	}
+	cfg.Passwd = os.Getenv("MYSQL_ROOT_PASSWORD")
+	if cfg.Passwd == "" {
+		cfg.Passwd = "mysql_password"
+	}
+	cfg.Net = "tcp"
+
+	// Use service name when running in Docker Compose, fallback to localhost for local development
+	dbHost := os.Getenv("MYSQL_HOST")
+	if dbHost == "" {
+		// Check if we're running in Docker by looking for the db service
+		if inContainer() {
+			dbHost = "db:3306"
+		} else {
+			dbHost = "127.0.0.1:3307"
+		}
+	}
+	cfg.Addr = dbHost
+
+	cfg.DBName = os.Getenv("MYSQL_DATABASE")
+	if cfg.DBName == "" {
+		cfg.DBName = "images"
+	}
+
+	return sql.Open("mysql", cfg.FormatDSN())
+}
+
 type RegistryCatalog struct {
 	Repositories []string `json:"repositories"`
 }
@@ -115,14 +183,17 @@ type ImageConfig struct {
 	Created string `json:"created"`
 }
 
-func getImageCreationTime(registryHost, repository, tag string) string {
+func getImageCreationTime(ctx context.Context, registryHost, repository, tag string) string {
+	client := newRegistryClient(ctx, registryHost)
+
 	// Get the manifest first
-	manifestCmd := exec.Command("curl", "-s", "-H", "Accept: application/vnd.docker.distribution.manifest.v2+json",
-		fmt.Sprintf("http://%s/v2/%s/manifests/%s", registryHost, repository, tag))
-	manifestOutput, err := manifestCmd.Output()
+	manifestOutput, _, err := client.Manifest(ctx, repository, tag)
 	if err != nil {
 		return "Unknown"
 	}
+	if manifestOutput, err = platformManifest(ctx, client, repository, manifestOutput); err != nil {
+		return "Unknown"
+	}
 
 	var manifest ImageManifest
 	if err := json.Unmarshal(manifestOutput, &manifest); err != nil {
@@ -131,9 +202,7 @@ func getImageCreationTime(registryHost, repository, tag string) string {
 
 	// Get the config blob to extract creation time
 	if manifest.Config.Digest != "" {
-		configCmd := exec.Command("curl", "-s",
-			fmt.Sprintf("http://%s/v2/%s/blobs/%s", registryHost, repository, manifest.Config.Digest))
-		configOutput, err := configCmd.Output()
+		configOutput, err := client.Blob(ctx, repository, manifest.Config.Digest)
 		if err != nil {
 			return "Unknown"
 		}
@@ -154,44 +223,51 @@ func getImageCreationTime(registryHost, repository, tag string) string {
 	return "Unknown"
 }
 
-func getImageSize(registryHost, repository, tag string) string {
+func getImageSize(ctx context.Context, registryHost, repository, tag string) string {
+	client := newRegistryClient(ctx, registryHost)
 	// Get the manifest first to find config and layer sizes
-	manifestCmd := exec.Command("curl", "-s", "-H", "Accept: application/vnd.docker.distribution.manifest.v2+json",
-		fmt.Sprintf("http://%s/v2/%s/manifests/%s", registryHost, repository, tag))
-	manifestOutput, err := manifestCmd.Output()
+	manifestOutput, _, err := client.Manifest(ctx, repository, tag)
 	if err != nil {
 		return "Unknown"
 	}
-
-	var manifest ImageManifest
-	if err := json.Unmarshal(manifestOutput, &manifest); err != nil {
+	if manifestOutput, err = platformManifest(ctx, client, repository, manifestOutput); err != nil {
 		return "Unknown"
 	}
-
-	// Calculate total size from config + layers
-	totalSize := int64(manifest.Config.Size)
-
-	// Parse manifest to get layer information
-	var manifestWithLayers struct {
-		SchemaVersion int    `json:"schemaVersion"`
-		MediaType     string `json:"mediaType"`
-		Config        struct {
-			Size int64 `json:"size"`
-		} `json:"config"`
-		Layers []struct {
-			Size int64 `json:"size"`
-		} `json:"layers"`
+	size, err := parseManifestSize(ctx, client, repository, manifestOutput)
+	if err != nil {
+		return "Unknown"
 	}
+	// Format size in human-readable format
+	return formatBytes(size)
+}
 
-	if err := json.Unmarshal(manifestOutput, &manifestWithLayers); err == nil {
-		// Add layer sizes
-		for _, layer := range manifestWithLayers.Layers {
-			totalSize += layer.Size
+// parseManifestSize is the size of an image in the registry: its config
+// plus its layers, compressed. A manifest list or OCI index takes the space
+// of all the images it lists, so their sizes are added up.
+func parseManifestSize(ctx context.Context, client registryClient, repository string, manifestOutput []byte) (int64, error) {
+	var manifest registry.Manifest
+	if err := json.Unmarshal(manifestOutput, &manifest); err != nil {
+		return 0, err
+	}
+	// Indexes (application/vnd.oci.image.index.v1+json) and manifest lists
+	// (application/vnd.docker.distribution.manifest.list.v2+json) list
+	// manifests rather than layers
+	if len(manifest.Manifests) == 0 {
+		return registry.ManifestSize(manifestOutput)
+	}
+	var total int64
+	for _, child := range manifest.Manifests {
+		body, _, err := client.Manifest(ctx, repository, child.Digest)
+		if err != nil {
+			return 0, err
+		}
+		size, err := registry.ManifestSize(body)
+		if err != nil {
+			return 0, err
 		}
+		total += size
 	}
-
-	// Format size in human-readable format
-	return formatBytes(totalSize)
+	return total, nil
 }
 
 func formatBytes(bytes int64) string {
@@ -214,91 +290,119 @@ func formatBytes(bytes int64) string {
 	return fmt.Sprintf("%.1f%s", size, units[unitIndex])
 }
 
-func getRegistryImages() ([]DockerImage, error) {
-	// Use service name when running in Docker Compose, fallback to localhost for local development
-	registryHost := os.Getenv("REGISTRY_HOST")
-	if registryHost == "" {
-		if _, err := os.Stat("/.dockerenv"); err == nil {
-			registryHost = "registry:5000"
-		} else {
-			registryHost = "localhost:5000"
-		}
-	}
+func getRegistryImages(ctx context.Context) ([]DockerImage, error) {
+	registryHost := getRegistryHost()
+	client := newRegistryClient(ctx, registryHost)
 
 	// First, try to get the list of repositories from the registry
-	cmd := exec.Command("curl", "-s", fmt.Sprintf("http://%s/v2/_catalog", registryHost))
-	output, err := cmd.Output()
+	repositories, err := listRepositories(ctx, client)
+	if errors.Is(err, registry.ErrUnsupported) {
+		// Local images would pass for the registry's
+		return nil, err
+	}
 	if err != nil {
 		// Fallback to local images
-		return getLocalDockerImages()
-	}
-
-	// Parse the JSON response
-	var catalog RegistryCatalog
-	if err := json.Unmarshal(output, &catalog); err != nil {
-		return getLocalDockerImages()
+		return getLocalDockerImages(ctx)
 	}
 
 	var images []DockerImage
+	start := time.Now()
+	previous := previousRefresh()
+	refreshed := make(map[string]DockerImage)
+	stats := refreshStats{host: registryHost, repositories: len(repositories)}
 
 	// For each repository, get its tags
-	for _, repo := range catalog.Repositories {
-		tagsCmd := exec.Command("curl", "-s", fmt.Sprintf("http://%s/v2/%s/tags/list", registryHost, repo))
-		tagsOutput, err := tagsCmd.Output()
+	for _, repo := range repositories {
+		tags, err := client.Tags(ctx, repo)
 		if err != nil {
 			continue
 		}
-
-		var repoTags RegistryTags
-		if err := json.Unmarshal(tagsOutput, &repoTags); err != nil {
-			continue
-		}
+		sort.Slice(tags, func(i, j int) bool { return compareVersionTags(tags[i], tags[j]) < 0 })
 
 		// Create an image entry for each tag
-		for _, tag := range repoTags.Tags {
-			imageFullName := fmt.Sprintf("%s/%s:%s", registryHost, repo, tag)
+		for _, tag := range tags {
+			stats.tags++
+			key := refreshKey(registryHost, repo, tag)
+			if image, ok := previous[key]; ok && image.Digest != "" {
+				if digest, err := client.ManifestDigest(ctx, repo, tag); err == nil && digest == image.Digest {
+					if image.ArtifactType == "" {
+						image.Scan = client.ScanSummary(ctx, repo, tag)
+					}
+					images = append(images, image)
+					refreshed[key] = image
+					stats.unchanged++
+					continue
+				}
+			}
 
-			// Try to get creation timestamp from manifest
-			createdAt := getImageCreationTime(registryHost, repo, tag)
+			imageFullName := fmt.Sprintf("%s/%s:%s", client.Host(), repo, tag)
+
+			// The digest lets pods pinned by digest count as using this tag
+			var digest string
+			size, sizeBytes := "Unknown", int64(0)
+			body, _, err := client.Manifest(ctx, repo, tag)
+			if err == nil {
+				digest = registry.ComputeDigest(body)
+				// Try to get image size from manifest
+				if sizeBytes, err = parseManifestSize(ctx, client, repo, body); err == nil {
+					size = formatBytes(sizeBytes)
+				}
+			}
+			labels, annotations := imageMetadata(ctx, client, repo, body)
+
+			// Charts, SBOMs, signatures and the like have no image config
+			// to take a creation time from
+			if artifact, ok := describeArtifact(ctx, client, repo, tag, body); ok {
+				images = append(images, DockerImage{
+					ID:             fmt.Sprintf("registry-%s-%s", repo, tag),
+					RepoTags:       []string{imageFullName},
+					Size:           size,
+					CreatedAt:      artifact.createdAt,
+					Digest:         digest,
+					ArtifactType:   artifact.kind,
+					ArtifactDetail: artifact.detail,
+					Annotations:    annotations,
+				})
+				refreshed[key] = images[len(images)-1]
+				continue
+			}
 
-			// Try to get image size from manifest
-			size := getImageSize(registryHost, repo, tag)
+			// Try to get creation timestamp from manifest
+			createdAt := getImageCreationTime(ctx, registryHost, repo, tag)
 
 			images = append(images, DockerImage{
-				ID:        fmt.Sprintf("registry-%s-%s", repo, tag), // Generate a pseudo-ID
-				RepoTags:  []string{imageFullName},
-				Size:      size,
-				CreatedAt: createdAt,
+				ID:          fmt.Sprintf("registry-%s-%s", repo, tag), // Generate a pseudo-ID
+				RepoTags:    []string{imageFullName},
+				Size:        size,
+				SizeBytes:   sizeBytes,
+				CreatedAt:   createdAt,
+				Digest:      digest,
+				Scan:        client.ScanSummary(ctx, repo, tag),
+				Labels:      labels,
+				Annotations: annotations,
 			})
+			refreshed[key] = images[len(images)-1]
 		}
 	}
+	stats.took = time.Since(start)
+	recordRefresh(refreshed, stats)
 
 	if len(images) == 0 {
-		return getLocalDockerImages()
+		return getLocalDockerImages(ctx)
 	}
 
 	return images, nil
 }
 
-func getLocalDockerImages() ([]DockerImage, error) {
-	// Get all local Docker images with consistent timestamp format
-	cmd := exec.Command("docker", "images", "--format", "{{.ID}},{{.Repository}}:{{.Tag}},{{.Size}},{{.CreatedAt}}")
-	output, err := cmd.Output()
+func getLocalDockerImages(ctx context.Context) ([]DockerImage, error) {
+	ctx, cancel := withBackendTimeout(ctx, backendDocker)
+	defer cancel()
+
+	local, err := containerRuntime(ctx).Images(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get docker images: %v", err)
 	}
-
-	if len(output) == 0 {
-		return []DockerImage{{
-			ID:        "Not Found",
-			RepoTags:  []string{"N/A"},
-			Size:      "N/A",
-			CreatedAt: "N/A",
-		}}, nil
-	}
-
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(lines) == 0 {
+	if len(local) == 0 {
 		return []DockerImage{{
 			ID:        "Not Found",
 			RepoTags:  []string{"N/A"},
@@ -308,52 +412,34 @@ func getLocalDockerImages() ([]DockerImage, error) {
 	}
 
 	var images []DockerImage
-	for _, line := range lines {
-		parts := strings.Split(line, ",")
-		if len(parts) >= 4 {
-			// Format the creation timestamp consistently
-			createdAt := parts[3]
-			// If it's a relative time like "2 hours ago", try to parse it
-			if strings.Contains(createdAt, " ago") {
-				// For relative times, we'll keep them as-is for now
-				// Docker's CreatedAt format is already human-readable
-			}
-
-			images = append(images, DockerImage{
-				ID:        parts[0],
-				RepoTags:  []string{parts[1]},
-				Size:      parts[2],
-				CreatedAt: createdAt,
-			})
-		}
-	}
-
-	if len(images) == 0 {
-		return []DockerImage{{
-			ID:        "Parse Error",
-			RepoTags:  []string{"N/A"},
-			Size:      "N/A",
-			CreatedAt: "N/A",
-		}}, nil
+	for _, image := range local {
+		images = append(images, DockerImage{
+			ID:        image.ShortID(),
+			RepoTags:  []string{image.Ref()},
+			Size:      image.Size,
+			CreatedAt: image.CreatedAt,
+		})
 	}
-
+	addLocalImageLabels(ctx, images)
 	return images, nil
 }
 
-func ensureImageInMinikube(fullImageName string) error {
+func ensureImageInMinikube(ctx context.Context, fullImageName string) error {
+	ctx, cancel := withBackendTimeout(ctx, backendDocker)
+	defer cancel()
+
 	// Check if we're running in Minikube
-	if _, err := exec.Command("minikube", "status").Output(); err != nil {
+	if _, err := exec.CommandContext(ctx, "minikube", "status").Output(); err != nil {
 		return nil // Not in Minikube, no action needed
 	}
 
 	// Pull the image to local Docker first
-	pullCmd := exec.Command("docker", "pull", fullImageName)
-	if err := pullCmd.Run(); err != nil {
+	if err := containerRuntime(ctx).Pull(ctx, fullImageName, nil); err != nil {
 		return err
 	}
 
 	// Load the image into Minikube
-	loadCmd := exec.Command("minikube", "image", "load", fullImageName)
+	loadCmd := exec.CommandContext(ctx, "minikube", "image", "load", fullImageName)
 	if err := loadCmd.Run(); err != nil {
 		return err
 	}
@@ -361,55 +447,67 @@ func ensureImageInMinikube(fullImageName string) error {
 	return nil
 }
 
-func pullFromRegistry(imageName string) error {
-	// Use service name when running in Docker Compose, fallback to localhost for local development
-	registryHost := os.Getenv("REGISTRY_HOST")
-	if registryHost == "" {
-		if _, err := os.Stat("/.dockerenv"); err == nil {
-			registryHost = "registry:5000"
-		} else {
-			registryHost = "localhost:5000"
-		}
-	}
-	fullImageName := fmt.Sprintf("%s/%s", registryHost, imageName)
+func pullFromRegistry(ctx context.Context, imageName string) error {
+	ctx, cancel := withBackendTimeout(ctx, backendDocker)
+	defer cancel()
 
-	cmd := exec.Command("docker", "pull", fullImageName)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	fullImageName := fmt.Sprintf("%s/%s", getRegistryHost(), imageName)
 
-	return cmd.Run()
+	return containerRuntime(ctx).Pull(ctx, fullImageName, os.Stdout)
 }
 
-func getDockerImagesInfo() ([]DockerImage, error) {
+func getDockerImagesInfo(ctx context.Context) (images []DockerImage, err error) {
+	ctx, span := startSpan(ctx, "load images")
+	defer func() { endSpan(span, err) }()
+
 	// Try to get images from registry first, then fallback to local
-	images, err := getRegistryImages()
+	images, err = getRegistryImages(ctx)
+	publishSnapshot(eventImages, images, err)
+	if errors.Is(err, registry.ErrUnsupported) {
+		return nil, err
+	}
 	if err != nil {
-		return getLocalDockerImages()
+		return getLocalDockerImages(ctx)
 	}
 
+	recordImages(ctx, images)
 	return images, nil
 }
 
-func getKubernetesPodsInfo() ([]TableData, error) {
-	// Try kubectl first (works in both container and host environments)
-	podData, err := getPodsViaKubectl()
-	if err == nil && len(podData) > 0 && podData[0].PodName != "kubectl error:" {
-		return podData, nil
+// kubernetesControlPlaneHost is the API server URL from
+// KUBERNETES_CONTROL_PLANE(_PORT), empty when the kubeconfig's applies.
+func kubernetesControlPlaneHost() string {
+	controlPlane := os.Getenv("KUBERNETES_CONTROL_PLANE")
+	if controlPlane == "" {
+		return ""
+	}
+	if !strings.HasPrefix(controlPlane, "http://") && !strings.HasPrefix(controlPlane, "https://") {
+		controlPlane = "https://" + controlPlane
 	}
+	if port := os.Getenv("KUBERNETES_CONTROL_PLANE_PORT"); port != "" {
+		return fmt.Sprintf("%s:%s", controlPlane, port)
+	}
+	return controlPlane
+}
 
-	// Fallback to direct API calls if kubectl fails
-	fmt.Printf("kubectl failed, falling back to direct API calls\n")
+func getKubernetesPodsInfo(ctx context.Context) ([]TableData, error) {
+	ctx, span := startSpan(ctx, "load pods")
+	pods, err := listKubernetesPods(ctx)
+	endSpan(span, err)
+	publishSnapshot(eventPods, pods, err)
+	return pods, err
+}
 
-	// Build kubeconfig path - check environment variable first, then fallback to home
-	var kubeconfig string
-	if kubeconfigEnv := os.Getenv("KUBECONFIG"); kubeconfigEnv != "" {
-		kubeconfig = kubeconfigEnv
-	} else if home := homedir.HomeDir(); home != "" {
-		kubeconfig = filepath.Join(home, ".kube", "config")
+func listKubernetesPods(ctx context.Context) ([]TableData, error) {
+	if !kubernetesEnabled() {
+		return nil, errKubernetesDisabled
 	}
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
 
-	// Check if kubeconfig file exists
-	if _, err := os.Stat(kubeconfig); os.IsNotExist(err) {
+	pods, err := newCluster(ctx).Pods(ctx, metav1.NamespaceAll)
+	var configErr clusterConfigError
+	if errors.Is(err, errKubeconfigNotFound) {
 		return []TableData{{
 			PodName:   "No Kubernetes cluster found",
 			Namespace: "N/A",
@@ -418,10 +516,7 @@ func getKubernetesPodsInfo() ([]TableData, error) {
 			Age:       "N/A",
 		}}, nil
 	}
-
-	// Build config from kubeconfig file
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
+	if errors.As(err, &configErr) {
 		return []TableData{{
 			PodName:   fmt.Sprintf("Config error: %v", err),
 			Namespace: "N/A",
@@ -430,87 +525,26 @@ func getKubernetesPodsInfo() ([]TableData, error) {
 			Age:       "N/A",
 		}}, nil
 	}
-
-	// Override with environment variables if provided
-	if controlPlane := os.Getenv("KUBERNETES_CONTROL_PLANE"); controlPlane != "" {
-		if port := os.Getenv("KUBERNETES_CONTROL_PLANE_PORT"); port != "" {
-			// Check if controlPlane already has protocol
-			if strings.HasPrefix(controlPlane, "http://") || strings.HasPrefix(controlPlane, "https://") {
-				config.Host = fmt.Sprintf("%s:%s", controlPlane, port)
-			} else {
-				config.Host = fmt.Sprintf("https://%s:%s", controlPlane, port)
-			}
-		} else {
-			// Check if controlPlane already has protocol
-			if strings.HasPrefix(controlPlane, "http://") || strings.HasPrefix(controlPlane, "https://") {
-				config.Host = controlPlane
-			} else {
-				config.Host = fmt.Sprintf("https://%s", controlPlane)
-			}
-		}
-	}
-
-	// Create clientset
-	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
+		// The row explains the error where the pods would be
 		return []TableData{{
-			PodName:   fmt.Sprintf("Client error: %v", err),
+			PodName:   podListError(err),
 			Namespace: "N/A",
-			Status:    "N/A",
-			Restarts:  "N/A",
-			Age:       "N/A",
-		}}, nil
-	}
-
-	// Get namespace from environment or use default
-	namespace := os.Getenv("KUBERNETES_NAMESPACE")
-	if namespace == "" {
-		namespace = "default"
-	}
-
-	// List pods
-	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		return []TableData{{
-			PodName:   fmt.Sprintf("List error: %v", err),
-			Namespace: namespace,
-			Status:    "N/A",
+			Status:    "Error",
 			Restarts:  "N/A",
 			Age:       "N/A",
-		}}, nil
+		}}, err
 	}
 
 	var tableData []TableData
-	for _, pod := range pods.Items {
-		// Calculate age
-		age := time.Since(pod.CreationTimestamp.Time).Truncate(time.Second).String()
-
-		// Calculate total restarts
-		restarts := int32(0)
-		for _, containerStatus := range pod.Status.ContainerStatuses {
-			restarts += containerStatus.RestartCount
-		}
-
-		// Get node name
-		nodeName := pod.Spec.NodeName
-		if nodeName == "" {
-			nodeName = "N/A"
-		}
-
-		tableData = append(tableData, TableData{
-			PodName:   pod.Name,
-			Namespace: pod.Namespace,
-			Status:    string(pod.Status.Phase),
-			Restarts:  fmt.Sprintf("%d", restarts),
-			Age:       age,
-			NodeName:  nodeName,
-		})
+	for _, pod := range pods {
+		tableData = append(tableData, podRow(pod))
 	}
 
 	if len(tableData) == 0 {
 		return []TableData{{
 			PodName:   "No pods found",
-			Namespace: namespace,
+			Namespace: "N/A",
 			Status:    "N/A",
 			Restarts:  "N/A",
 			Age:       "N/A",
@@ -520,242 +554,172 @@ func getKubernetesPodsInfo() ([]TableData, error) {
 	return tableData, nil
 }
 
-func getKubernetesPodDetails(podName, namespace string) (map[string]string, error) {
-	// Try kubectl first
-	podDetails, err := getPodDetailsViaKubectl(podName, namespace)
-	if err == nil && len(podDetails) > 0 {
-		return podDetails, nil
+// podListError is what the Kubernetes tab shows when the pods can't be
+// listed, with a hint for the usual ways of not reaching the cluster.
+func podListError(err error) string {
+	switch message := err.Error(); {
+	case strings.Contains(message, "i/o timeout"):
+		return "Cannot reach Kubernetes cluster. Run on host or check Minikube status."
+	case strings.Contains(message, "connection refused"):
+		return "Kubernetes cluster not accessible. Check Minikube status."
 	}
+	return fmt.Sprintf("List error: %v", err)
+}
 
-	// Fallback to direct API calls
-	fmt.Printf("kubectl pod details failed, falling back to direct API calls\n")
-
-	// Build kubeconfig path
-	var kubeconfig string
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = filepath.Join(home, ".kube", "config")
+// podRow is a pod as the Kubernetes tab lists it.
+func podRow(pod kube.Pod) TableData {
+	return TableData{
+		PodName:   pod.Name,
+		Namespace: pod.Namespace,
+		Status:    pod.Phase,
+		Restarts:  fmt.Sprintf("%d", pod.Restarts),
+		Age:       pod.Age().String(),
+		NodeName:  cmp.Or(pod.Node, "N/A"),
 	}
+}
+
+// podDetails is what the pod definition screen shows of a pod.
+type podDetails struct {
+	rows       []table.Row
+	containers []string // init containers first, for the log and exec picker
+}
 
-	// Check if kubeconfig file exists
-	if _, err := os.Stat(kubeconfig); os.IsNotExist(err) {
-		return nil, fmt.Errorf("kubeconfig not found")
+// getKubernetesPodDetails describes a pod as key/value rows, with every
+// container and init container's image, state, restarts and resources.
+func getKubernetesPodDetails(ctx context.Context, podName, namespace string) (podDetails, error) {
+	if !kubernetesEnabled() {
+		return podDetails{}, errKubernetesDisabled
 	}
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
 
-	// Build config from kubeconfig file
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	pod, err := newCluster(ctx).Pod(ctx, namespace, podName)
 	if err != nil {
-		return nil, fmt.Errorf("error building config: %v", err)
+		return podDetails{}, fmt.Errorf("error getting pod: %v", err)
 	}
 
-	// Override with environment variables if provided
-	if controlPlane := os.Getenv("KUBERNETES_CONTROL_PLANE"); controlPlane != "" {
-		if port := os.Getenv("KUBERNETES_CONTROL_PLANE_PORT"); port != "" {
-			if strings.HasPrefix(controlPlane, "http://") || strings.HasPrefix(controlPlane, "https://") {
-				config.Host = fmt.Sprintf("%s:%s", controlPlane, port)
-			} else {
-				config.Host = fmt.Sprintf("https://%s:%s", controlPlane, port)
-			}
-		} else {
-			if strings.HasPrefix(controlPlane, "http://") || strings.HasPrefix(controlPlane, "https://") {
-				config.Host = controlPlane
-			} else {
-				config.Host = fmt.Sprintf("https://%s", controlPlane)
-			}
+	var details podDetails
+	add := func(key, value string) {
+		if value != "" {
+			details.rows = append(details.rows, table.Row{key, truncateString(value, 70)})
 		}
 	}
 
-	// Create clientset
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return nil, fmt.Errorf("error creating client: %v", err)
+	add("Name", pod.Name)
+	add("Namespace", pod.Namespace)
+	add("Status", string(pod.Status.Phase))
+	add("Node", pod.Spec.NodeName)
+	add("Pod IP", pod.Status.PodIP)
+	add("Host IP", pod.Status.HostIP)
+	add("Created", pod.CreationTimestamp.Format("2006-01-02 15:04:05"))
+	if pod.Status.StartTime != nil {
+		add("Start Time", pod.Status.StartTime.Format("2006-01-02 15:04:05"))
 	}
-
-	// Get the specific pod
-	pod, err := clientset.CoreV1().Pods(namespace).Get(context.TODO(), podName, metav1.GetOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("error getting pod: %v", err)
+	add("Service Account", pod.Spec.ServiceAccountName)
+	add("Restart Policy", string(pod.Spec.RestartPolicy))
+	add("DNS Policy", string(pod.Spec.DNSPolicy))
+	for _, condition := range pod.Status.Conditions {
+		add("Condition "+string(condition.Type), string(condition.Status))
 	}
 
-	// Build detailed pod information
-	details := make(map[string]string)
-
-	// Basic information
-	details["Name"] = pod.Name
-	details["Namespace"] = pod.Namespace
-	details["Status"] = string(pod.Status.Phase)
-	details["Node"] = pod.Spec.NodeName
-	details["Start Time"] = pod.Status.StartTime.Format("2006-01-02 15:04:05")
-	details["Created"] = pod.CreationTimestamp.Format("2006-01-02 15:04:05")
-
-	// Pod IP and Host IP
-	details["Pod IP"] = pod.Status.PodIP
-	details["Host IP"] = pod.Status.HostIP
-
-	// Service Account
-	details["Service Account"] = pod.Spec.ServiceAccountName
-
-	// Restart Policy
-	details["Restart Policy"] = string(pod.Spec.RestartPolicy)
-
-	// DNS Policy
-	details["DNS Policy"] = string(pod.Spec.DNSPolicy)
-
-	// Labels
-	if len(pod.Labels) > 0 {
-		labelsStr := ""
-		for k, v := range pod.Labels {
-			if labelsStr != "" {
-				labelsStr += ", "
+	statuses := make(map[string]corev1.ContainerStatus)
+	for _, status := range append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		statuses[status.Name] = status
+	}
+	addContainer := func(kind string, container corev1.Container) {
+		details.containers = append(details.containers, container.Name)
+		add(kind+" "+container.Name, container.Image)
+		if status, ok := statuses[container.Name]; ok {
+			add("  State", containerState(status.State))
+			if status.LastTerminationState.Terminated != nil {
+				add("  Last State", containerState(status.LastTerminationState))
 			}
-			labelsStr += fmt.Sprintf("%s=%s", k, v)
+			add("  Ready", strconv.FormatBool(status.Ready))
+			add("  Restarts", strconv.Itoa(int(status.RestartCount)))
 		}
-		details["Labels"] = labelsStr
-	} else {
-		details["Labels"] = "None"
-	}
-
-	// Annotations count
-	details["Annotations"] = fmt.Sprintf("%d annotations", len(pod.Annotations))
-
-	// Container information
-	if len(pod.Spec.Containers) > 0 {
-		container := pod.Spec.Containers[0] // Show first container
-		details["Container Name"] = container.Name
-		details["Container Image"] = container.Image
-		details["Image Pull Policy"] = string(container.ImagePullPolicy)
-
-		// Ports
+		add("  Pull Policy", string(container.ImagePullPolicy))
 		if len(container.Ports) > 0 {
-			portsStr := ""
+			var ports []string
 			for _, port := range container.Ports {
-				if portsStr != "" {
-					portsStr += ", "
-				}
-				portsStr += fmt.Sprintf("%d/%s", port.ContainerPort, port.Protocol)
-			}
-			details["Container Ports"] = portsStr
-		} else {
-			details["Container Ports"] = "None"
-		}
-
-		// Resource requests and limits
-		if container.Resources.Requests != nil {
-			if cpu := container.Resources.Requests["cpu"]; !cpu.IsZero() {
-				details["CPU Request"] = cpu.String()
-			}
-			if memory := container.Resources.Requests["memory"]; !memory.IsZero() {
-				details["Memory Request"] = memory.String()
-			}
-		}
-		if container.Resources.Limits != nil {
-			if cpu := container.Resources.Limits["cpu"]; !cpu.IsZero() {
-				details["CPU Limit"] = cpu.String()
-			}
-			if memory := container.Resources.Limits["memory"]; !memory.IsZero() {
-				details["Memory Limit"] = memory.String()
+				ports = append(ports, fmt.Sprintf("%d/%s", port.ContainerPort, port.Protocol))
 			}
+			add("  Ports", strings.Join(ports, ", "))
 		}
+		add("  Requests", resourceListString(container.Resources.Requests))
+		add("  Limits", resourceListString(container.Resources.Limits))
 	}
-
-	// Container status information
-	if len(pod.Status.ContainerStatuses) > 0 {
-		containerStatus := pod.Status.ContainerStatuses[0]
-		details["Container Ready"] = fmt.Sprintf("%t", containerStatus.Ready)
-		details["Restart Count"] = fmt.Sprintf("%d", containerStatus.RestartCount)
-		details["Container ID"] = containerStatus.ContainerID
-
-		// Last state
-		if containerStatus.LastTerminationState.Terminated != nil {
-			term := containerStatus.LastTerminationState.Terminated
-			details["Last Exit Code"] = fmt.Sprintf("%d", term.ExitCode)
-			details["Last Exit Reason"] = term.Reason
-		}
+	for _, container := range pod.Spec.InitContainers {
+		addContainer("Init Container", container)
+	}
+	for _, container := range pod.Spec.Containers {
+		addContainer("Container", container)
 	}
 
-	// Conditions
-	readyCondition := "Unknown"
-	scheduledCondition := "Unknown"
-	initializedCondition := "Unknown"
-
-	for _, condition := range pod.Status.Conditions {
-		switch condition.Type {
-		case "Ready":
-			readyCondition = string(condition.Status)
-		case "PodScheduled":
-			scheduledCondition = string(condition.Status)
-		case "Initialized":
-			initializedCondition = string(condition.Status)
+	labels := make([]string, 0, len(pod.Labels))
+	for key, value := range pod.Labels {
+		labels = append(labels, key+"="+value)
+	}
+	sort.Strings(labels)
+	add("Labels", cmp.Or(strings.Join(labels, ", "), "None"))
+	if len(pod.Annotations) == 0 {
+		add("Annotations", "None")
+		return details, nil
+	}
+	add("Annotations", fmt.Sprintf("%d annotations", len(pod.Annotations)))
+	// Who deployed what, when the pod was rolled out by this tool
+	for _, key := range deployAnnotationKeys {
+		if value := pod.Annotations[key]; value != "" {
+			add("  "+key, value)
 		}
 	}
 
-	details["Ready Condition"] = readyCondition
-	details["Scheduled Condition"] = scheduledCondition
-	details["Initialized Condition"] = initializedCondition
-
 	return details, nil
 }
 
-func getKubernetesDeployments() ([]TableData, error) {
-	// Build kubeconfig path
-	var kubeconfig string
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = filepath.Join(home, ".kube", "config")
+// containerState is a container state in a line: what it waits for or why
+// it exited.
+func containerState(state corev1.ContainerState) string {
+	switch {
+	case state.Running != nil:
+		return "Running since " + state.Running.StartedAt.Format("2006-01-02 15:04:05")
+	case state.Waiting != nil:
+		return strings.TrimSuffix("Waiting: "+state.Waiting.Reason+" "+state.Waiting.Message, " ")
+	case state.Terminated != nil:
+		return fmt.Sprintf("Terminated: %s, exit code %d at %s", cmp.Or(state.Terminated.Reason, "Unknown"),
+			state.Terminated.ExitCode, state.Terminated.FinishedAt.Format("2006-01-02 15:04:05"))
 	}
+	return "Unknown"
+}
 
-	// Check if kubeconfig file exists
-	if _, err := os.Stat(kubeconfig); os.IsNotExist(err) {
-		return []TableData{{
-			PodName:   "No Kubernetes cluster found",
-			Namespace: "N/A",
-		}}, nil
+func getKubernetesDeployments(ctx context.Context) ([]TableData, error) {
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	// Get namespace from environment or use default
+	namespace := os.Getenv("KUBERNETES_NAMESPACE")
+	if namespace == "" {
+		namespace = "default"
 	}
 
-	// Build config from kubeconfig file
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
+	// List deployments
+	cluster := newCluster(ctx)
+	deployments, err := cluster.Deployments(ctx, namespace)
+	var configErr clusterConfigError
+	if errors.Is(err, errKubeconfigNotFound) {
 		return []TableData{{
-			PodName:   "Error connecting to cluster",
+			PodName:   "No Kubernetes cluster found",
 			Namespace: "N/A",
 		}}, nil
 	}
-
-	// Override with environment variables if provided
-	if controlPlane := os.Getenv("KUBERNETES_CONTROL_PLANE"); controlPlane != "" {
-		if port := os.Getenv("KUBERNETES_CONTROL_PLANE_PORT"); port != "" {
-			if strings.HasPrefix(controlPlane, "http://") || strings.HasPrefix(controlPlane, "https://") {
-				config.Host = fmt.Sprintf("%s:%s", controlPlane, port)
-			} else {
-				config.Host = fmt.Sprintf("https://%s:%s", controlPlane, port)
-			}
-		} else {
-			if strings.HasPrefix(controlPlane, "http://") || strings.HasPrefix(controlPlane, "https://") {
-				config.Host = controlPlane
-			} else {
-				config.Host = fmt.Sprintf("https://%s", controlPlane)
-			}
-		}
-	}
-
-	// Create clientset
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
+	if errors.As(err, &configErr) {
 		return []TableData{{
-			PodName:   "Error creating Kubernetes client",
+			PodName:   "Error connecting to cluster",
 			Namespace: "N/A",
 		}}, nil
 	}
-
-	// Get namespace from environment or use default
-	namespace := os.Getenv("KUBERNETES_NAMESPACE")
-	if namespace == "" {
-		namespace = "default"
-	}
-
-	// List deployments
-	deployments, err := clientset.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
 		// Fall back to listing pods if deployments fail
-		pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+		pods, err := cluster.ListPods(ctx, namespace, "")
 		if err != nil {
 			return []TableData{{
 				PodName:   "Error listing deployments/pods",
@@ -764,7 +728,7 @@ func getKubernetesDeployments() ([]TableData, error) {
 		}
 
 		var tableData []TableData
-		for _, pod := range pods.Items {
+		for _, pod := range pods {
 			tableData = append(tableData, TableData{
 				PodName:   pod.Name,
 				Namespace: pod.Namespace,
@@ -775,7 +739,7 @@ func getKubernetesDeployments() ([]TableData, error) {
 	}
 
 	var tableData []TableData
-	for _, deployment := range deployments.Items {
+	for _, deployment := range deployments {
 		// Get deployment status
 		status := "Unknown"
 		if deployment.Status.ReadyReplicas == *deployment.Spec.Replicas {
@@ -786,11 +750,15 @@ func getKubernetesDeployments() ([]TableData, error) {
 			status = "NotReady"
 		}
 
+		release, _ := helmRelease(&deployment)
 		tableData = append(tableData, TableData{
-			PodName:   deployment.Name, // Using PodName field for deployment name
-			Namespace: deployment.Namespace,
-			Status:    status,
-			Restarts:  fmt.Sprintf("%d/%d", deployment.Status.ReadyReplicas, *deployment.Spec.Replicas),
+			PodName:         deployment.Name, // Using PodName field for deployment name
+			Namespace:       deployment.Namespace,
+			Status:          status,
+			Restarts:        fmt.Sprintf("%d/%d", deployment.Status.ReadyReplicas, *deployment.Spec.Replicas),
+			HelmRelease:     release,
+			Containers:      kube.Containers(deployment),
+			ContainerImages: kube.Images(deployment),
 		})
 	}
 
@@ -805,114 +773,34 @@ func getKubernetesDeployments() ([]TableData, error) {
 	return tableData, nil
 }
 
-func getPodsForDeployment(deploymentName, namespace string) ([]TableData, error) {
-	// Build kubeconfig path
-	var kubeconfig string
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = filepath.Join(home, ".kube", "config")
-	}
+func getPodsForDeployment(ctx context.Context, deploymentName, namespace string) ([]TableData, error) {
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
 
-	// Check if kubeconfig file exists
-	if _, err := os.Stat(kubeconfig); os.IsNotExist(err) {
+	pods, err := newCluster(ctx).DeploymentPods(ctx, namespace, deploymentName)
+	var configErr clusterConfigError
+	if errors.Is(err, errKubeconfigNotFound) {
 		return []TableData{{
 			PodName:   "No Kubernetes cluster found",
 			Namespace: "N/A",
 		}}, nil
 	}
-
-	// Build config from kubeconfig file
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
+	if errors.As(err, &configErr) {
 		return []TableData{{
 			PodName:   "Error connecting to cluster",
 			Namespace: "N/A",
 		}}, nil
 	}
-
-	// Override with environment variables if provided
-	if controlPlane := os.Getenv("KUBERNETES_CONTROL_PLANE"); controlPlane != "" {
-		if port := os.Getenv("KUBERNETES_CONTROL_PLANE_PORT"); port != "" {
-			if strings.HasPrefix(controlPlane, "http://") || strings.HasPrefix(controlPlane, "https://") {
-				config.Host = fmt.Sprintf("%s:%s", controlPlane, port)
-			} else {
-				config.Host = fmt.Sprintf("https://%s:%s", controlPlane, port)
-			}
-		} else {
-			if strings.HasPrefix(controlPlane, "http://") || strings.HasPrefix(controlPlane, "https://") {
-				config.Host = controlPlane
-			} else {
-				config.Host = fmt.Sprintf("https://%s", controlPlane)
-			}
-		}
-	}
-
-	// Create clientset
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return []TableData{{
-			PodName:   "Error creating Kubernetes client",
-			Namespace: "N/A",
-		}}, nil
-	}
-
-	// Get the deployment first to get label selectors
-	deployment, err := clientset.AppsV1().Deployments(namespace).Get(context.TODO(), deploymentName, metav1.GetOptions{})
-	if err != nil {
-		return []TableData{{
-			PodName:   fmt.Sprintf("Error getting deployment: %v", err),
-			Namespace: namespace,
-		}}, nil
-	}
-
-	// Build label selector from deployment's match labels
-	var labelSelector string
-	if deployment.Spec.Selector != nil && deployment.Spec.Selector.MatchLabels != nil {
-		var selectors []string
-		for key, value := range deployment.Spec.Selector.MatchLabels {
-			selectors = append(selectors, fmt.Sprintf("%s=%s", key, value))
-		}
-		labelSelector = strings.Join(selectors, ",")
-	}
-
-	// List pods with the label selector
-	listOptions := metav1.ListOptions{}
-	if labelSelector != "" {
-		listOptions.LabelSelector = labelSelector
-	}
-
-	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), listOptions)
 	if err != nil {
 		return []TableData{{
-			PodName:   fmt.Sprintf("Error listing pods: %v", err),
+			PodName:   err.Error(),
 			Namespace: namespace,
 		}}, nil
 	}
 
 	var tableData []TableData
-	for _, pod := range pods.Items {
-		// Calculate age
-		age := time.Since(pod.CreationTimestamp.Time).Truncate(time.Second).String()
-
-		// Calculate total restarts
-		restarts := int32(0)
-		for _, containerStatus := range pod.Status.ContainerStatuses {
-			restarts += containerStatus.RestartCount
-		}
-
-		// Get node name
-		nodeName := pod.Spec.NodeName
-		if nodeName == "" {
-			nodeName = "N/A"
-		}
-
-		tableData = append(tableData, TableData{
-			PodName:   pod.Name,
-			Namespace: pod.Namespace,
-			Status:    string(pod.Status.Phase),
-			Restarts:  fmt.Sprintf("%d", restarts),
-			Age:       age,
-			NodeName:  nodeName,
-		})
+	for _, pod := range pods {
+		tableData = append(tableData, podRow(pod))
 	}
 
 	if len(tableData) == 0 {
@@ -926,68 +814,16 @@ func getPodsForDeployment(deploymentName, namespace string) ([]TableData, error)
 	return tableData, nil
 }
 
-func deployImageToPod(imageName, deploymentName, namespace string) error {
-	// When running in Docker container, use kubectl through Docker socket
-	if _, err := os.Stat("/.dockerenv"); err == nil {
-		return deployViaKubectl(imageName, deploymentName, namespace)
-	}
-
-	// Build kubeconfig path - check environment variable first, then fallback to home
-	var kubeconfig string
-	if kubeconfigEnv := os.Getenv("KUBECONFIG"); kubeconfigEnv != "" {
-		kubeconfig = kubeconfigEnv
-	} else if home := homedir.HomeDir(); home != "" {
-		kubeconfig = filepath.Join(home, ".kube", "config")
-	}
+// clusterImageName is the name the cluster pulls a registry image by: the
+// registry prefix is added for images listed without one.
+func clusterImageName(ctx context.Context, imageName string) string {
+	fullImageName := imageName
 
-	// Check if kubeconfig file exists
-	if _, err := os.Stat(kubeconfig); os.IsNotExist(err) {
-		return fmt.Errorf("kubeconfig not found")
+	// Images of a registry picked in the switcher are pulled from it as named
+	if selected := selectedRegistryHost(); selected != "" && imageRegistryHost(imageName) == newRegistryClient(ctx, selected).Host() {
+		return imageName
 	}
 
-	// Build config from kubeconfig file
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
-		return fmt.Errorf("error building config: %v", err)
-	}
-
-	// Override with environment variables if provided
-	if controlPlane := os.Getenv("KUBERNETES_CONTROL_PLANE"); controlPlane != "" {
-		if port := os.Getenv("KUBERNETES_CONTROL_PLANE_PORT"); port != "" {
-			if strings.HasPrefix(controlPlane, "http://") || strings.HasPrefix(controlPlane, "https://") {
-				config.Host = fmt.Sprintf("%s:%s", controlPlane, port)
-			} else {
-				config.Host = fmt.Sprintf("https://%s:%s", controlPlane, port)
-			}
-		} else {
-			if strings.HasPrefix(controlPlane, "http://") || strings.HasPrefix(controlPlane, "https://") {
-				config.Host = controlPlane
-			} else {
-				config.Host = fmt.Sprintf("https://%s", controlPlane)
-			}
-		}
-	}
-
-	// Create clientset
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return fmt.Errorf("error creating client: %v", err)
-	}
-
-	// Get the deployment
-	deployment, err := clientset.AppsV1().Deployments(namespace).Get(context.TODO(), deploymentName, metav1.GetOptions{})
-	if err != nil {
-		return fmt.Errorf("error getting deployment %s: %v", deploymentName, err)
-	}
-
-	// Update the first container's image
-	if len(deployment.Spec.Template.Spec.Containers) == 0 {
-		return fmt.Errorf("deployment %s has no containers", deploymentName)
-	}
-
-	// Ensure image name includes registry if it's from our local registry
-	fullImageName := imageName
-
 	// Always ensure we have the correct registry prefix for local images
 	if !strings.Contains(imageName, "localhost:5000") && !strings.Contains(imageName, "host.minikube.internal:5000") {
 		// This is likely a local image that needs the registry prefix
@@ -996,7 +832,7 @@ func deployImageToPod(imageName, deploymentName, namespace string) error {
 			registryHost = os.Getenv("KUBERNETES_REGISTRY_HOST")
 		} else {
 			// Try to detect if we're running in Minikube
-			if _, err := exec.Command("minikube", "status").Output(); err == nil {
+			if _, err := exec.CommandContext(ctx, "minikube", "status").Output(); err == nil {
 				registryHost = "host.minikube.internal:5000"
 			}
 		}
@@ -1007,143 +843,122 @@ func deployImageToPod(imageName, deploymentName, namespace string) error {
 
 		fullImageName = fmt.Sprintf("%s/%s", registryHost, imageNameAndTag)
 	}
+	return fullImageName
+}
 
-	// Ensure the image is available in Minikube if needed
-	ensureImageInMinikube(fullImageName)
+// deployImageToPod sets the image of the deployment's container called
+// container, its first container when empty.
+func deployImageToPod(ctx context.Context, imageName, deploymentName, namespace, container string, env *containerEnv) (err error) {
+	ctx, span := startSpan(ctx, "kubernetes deploy image",
+		attribute.String("k8s.namespace.name", namespace),
+		attribute.String("k8s.deployment.name", deploymentName),
+		attribute.String("k8s.container.name", container),
+		attribute.String("container.image.name", imageName))
+	defer func() { endSpan(span, err) }()
 
-	// Create a copy of the deployment with updated image
-	deploymentCopy := deployment.DeepCopy()
-	deploymentCopy.Spec.Template.Spec.Containers[0].Image = fullImageName
+	if !kubernetesEnabled() {
+		return errKubernetesDisabled
+	}
 
-	// Set image pull policy for local registry images
-	// For local development, always use "Never" to avoid pulling from remote registries
-	deploymentCopy.Spec.Template.Spec.Containers[0].ImagePullPolicy = "Never"
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
 
-	// Update the deployment
-	_, err = clientset.AppsV1().Deployments(namespace).Update(context.TODO(), deploymentCopy, metav1.UpdateOptions{})
+	// Get the deployment
+	cluster := newCluster(ctx)
+	deployment, err := cluster.Deployment(ctx, namespace, deploymentName)
 	if err != nil {
-		return fmt.Errorf("error updating deployment %s: %v", deploymentName, err)
+		return fmt.Errorf("error getting deployment %s: %v", deploymentName, err)
 	}
 
-	return nil
-}
+	index, err := kube.ContainerIndex(deployment, container)
+	if err != nil {
+		return err
+	}
 
-func deployViaKubectl(imageName, deploymentName, namespace string) error {
-	// Find kubectl binary
-	kubectlPath := findKubectl()
+	// Ensure image name includes registry if it's from our local registry
+	fullImageName := clusterImageName(ctx, imageName)
 
-	// Prepare the full image name
-	fullImageName := imageName
-	if !strings.Contains(imageName, "localhost:5000") && !strings.Contains(imageName, "host.minikube.internal:5000") {
-		registryHost := "localhost:5000"
-		if os.Getenv("KUBERNETES_REGISTRY_HOST") != "" {
-			registryHost = os.Getenv("KUBERNETES_REGISTRY_HOST")
+	// Ensure the image is available in Minikube if needed
+	ensureImageInMinikube(ctx, fullImageName)
+
+	// Patching a Helm-managed deployment would be undone by the next helm
+	// upgrade, so upgrade the release with the new image instead
+	if release, releaseNamespace := helmRelease(deployment); release != "" {
+		if env != nil {
+			return fmt.Errorf("helm release %s sets the env of %s; change it in the release's values", release, deploymentName)
 		}
-		imageParts := strings.Split(imageName, "/")
-		imageNameAndTag := imageParts[len(imageParts)-1]
-		fullImageName = fmt.Sprintf("%s/%s", registryHost, imageNameAndTag)
+		return upgradeHelmImage(ctx, release, releaseNamespace, fullImageName)
 	}
 
-	// Execute kubectl command to patch the deployment
-	kubectlCmd := exec.Command(kubectlPath, "set", "image",
-		fmt.Sprintf("deployment/%s", deploymentName),
-		fmt.Sprintf("app=%s", fullImageName),
-		"--namespace", namespace)
-
-	// If running in container, use the fixed kubeconfig
-	if _, err := os.Stat("/.dockerenv"); err == nil {
-		fixKubeconfigPaths()
-		kubectlCmd = exec.Command(kubectlPath, "--kubeconfig=/tmp/kubeconfig", "set", "image",
-			fmt.Sprintf("deployment/%s", deploymentName),
-			fmt.Sprintf("app=%s", fullImageName),
-			"--namespace", namespace)
+	// Never suits images side-loaded into Minikube; clusters whose nodes can
+	// reach the registry pull instead (see IMAGE_PULL_POLICY)
+	deploymentCopy, err := kube.WithImage(deployment, container, fullImageName, resolvePullPolicy(ctx, cluster, namespace, fullImageName, ""))
+	if err != nil {
+		return err
 	}
+	// The env edited in the deploy dialog, nil when it wasn't
+	if env != nil {
+		updatedEnv := editedEnv(deployment.Spec.Template.Spec.Containers[index], *env)
+		if deploymentCopy, err = kube.WithEnv(deploymentCopy, container, updatedEnv.Env, updatedEnv.EnvFrom); err != nil {
+			return err
+		}
+	}
+	stampDeployment(deployment, deploymentCopy, container, deployAnnotations(ctx, fullImageName))
 
-	output, err := kubectlCmd.CombinedOutput()
+	// Update the deployment
+	_, err = cluster.UpdateDeployment(ctx, deploymentCopy)
 	if err != nil {
-		return fmt.Errorf("kubectl command failed: %v\nOutput: %s", err, string(output))
+		return fmt.Errorf("error updating deployment %s: %v", deploymentName, err)
 	}
 
-	fmt.Printf("✅ Successfully updated deployment %s with image %s\n", deploymentName, fullImageName)
+	previous := deployment.Spec.Template.Spec.Containers[index]
+	entry := journalEntry{Kind: journalDeploy, Namespace: namespace, Name: deploymentName, Container: previous.Name, Image: previous.Image, PullPolicy: string(previous.ImagePullPolicy)}
+	if env != nil {
+		entry.Env = &containerEnv{Env: previous.Env, EnvFrom: previous.EnvFrom}
+	}
+	journal(entry)
 	return nil
 }
 
-func createKubernetesDeployment(imageName, deploymentName, namespace string) error {
-	// When running in Docker container, use kubectl through Docker socket
-	if _, err := os.Stat("/.dockerenv"); err == nil {
-		return createDeploymentViaKubectl(imageName, deploymentName, namespace)
-	}
+// createKubernetesDeployment creates the deployment and, when asked for, its
+// Service and Ingress. It returns the URL the app is reachable at, if any.
+func createKubernetesDeployment(ctx context.Context, imageName string, params deploymentParams) (url string, err error) {
+	ctx, span := startSpan(ctx, "kubernetes create deployment",
+		attribute.String("k8s.namespace.name", params.Namespace),
+		attribute.String("k8s.deployment.name", params.Name),
+		attribute.String("container.image.name", imageName))
+	defer func() { endSpan(span, err) }()
 
-	// Build kubeconfig path - check environment variable first, then fallback to home
-	var kubeconfig string
-	if kubeconfigEnv := os.Getenv("KUBECONFIG"); kubeconfigEnv != "" {
-		kubeconfig = kubeconfigEnv
-	} else if home := homedir.HomeDir(); home != "" {
-		kubeconfig = filepath.Join(home, ".kube", "config")
+	if !kubernetesEnabled() {
+		return "", errKubernetesDisabled
 	}
-
-	// Check if kubeconfig file exists
-	if _, err := os.Stat(kubeconfig); os.IsNotExist(err) {
-		return fmt.Errorf("kubeconfig not found")
+	// Names are checked here too, as not every caller goes through the form
+	deploymentName, namespace := params.Name, params.Namespace
+	if err := validateDeploymentName(deploymentName); err != nil {
+		return "", err
 	}
-
-	// Build config from kubeconfig file
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
-		return fmt.Errorf("error building config: %v", err)
+	if err := validateNamespaceName(namespace); err != nil {
+		return "", err
 	}
 
-	// Override with environment variables if provided
-	if controlPlane := os.Getenv("KUBERNETES_CONTROL_PLANE"); controlPlane != "" {
-		if port := os.Getenv("KUBERNETES_CONTROL_PLANE_PORT"); port != "" {
-			if strings.HasPrefix(controlPlane, "http://") || strings.HasPrefix(controlPlane, "https://") {
-				config.Host = fmt.Sprintf("%s:%s", controlPlane, port)
-			} else {
-				config.Host = fmt.Sprintf("https://%s:%s", controlPlane, port)
-			}
-		} else {
-			if strings.HasPrefix(controlPlane, "http://") || strings.HasPrefix(controlPlane, "https://") {
-				config.Host = controlPlane
-			} else {
-				config.Host = fmt.Sprintf("https://%s", controlPlane)
-			}
-		}
-	}
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
 
-	// Create clientset
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return fmt.Errorf("error creating client: %v", err)
+	cluster := newCluster(ctx)
+	if params.CreateNamespace {
+		if err := cluster.CreateNamespace(ctx, namespace); err != nil {
+			return "", err
+		}
 	}
 
 	// Prepare the full image name
-	fullImageName := imageName
-
-	// Always ensure we have the correct registry prefix for local images
-	if !strings.Contains(imageName, "localhost:5000") && !strings.Contains(imageName, "host.minikube.internal:5000") {
-		// This is likely a local image that needs the registry prefix
-		registryHost := "localhost:5000"
-		if os.Getenv("KUBERNETES_REGISTRY_HOST") != "" {
-			registryHost = os.Getenv("KUBERNETES_REGISTRY_HOST")
-		} else {
-			// Try to detect if we're running in Minikube
-			if _, err := exec.Command("minikube", "status").Output(); err == nil {
-				registryHost = "host.minikube.internal:5000"
-			}
-		}
-
-		// Extract just the image name and tag from the full image name
-		imageParts := strings.Split(imageName, "/")
-		imageNameAndTag := imageParts[len(imageParts)-1] // Get the last part (name:tag)
-
-		fullImageName = fmt.Sprintf("%s/%s", registryHost, imageNameAndTag)
-	}
+	fullImageName := clusterImageName(ctx, imageName)
 
 	// Ensure the image is available in Minikube if needed
-	ensureImageInMinikube(fullImageName)
+	ensureImageInMinikube(ctx, fullImageName)
 
 	// Create deployment specification
-	replicas := int32(1)
+	replicas := params.Replicas
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      deploymentName,
@@ -1168,11 +983,16 @@ func createKubernetesDeployment(imageName, deploymentName, namespace string) err
 				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{
 						{
-							Name:  "app",
-							Image: fullImageName,
+							Name:           "app",
+							Image:          fullImageName,
+							Env:            params.Env.Env,
+							EnvFrom:        params.Env.EnvFrom,
+							Resources:      params.Resources,
+							ReadinessProbe: params.ReadinessProbe,
+							LivenessProbe:  params.LivenessProbe,
 							Ports: []corev1.ContainerPort{
 								{
-									ContainerPort: 80,
+									ContainerPort: params.Port,
 									Protocol:      corev1.ProtocolTCP,
 								},
 							},
@@ -1183,12 +1003,24 @@ func createKubernetesDeployment(imageName, deploymentName, namespace string) err
 		},
 	}
 
-	// Set image pull policy for local registry images
-	// For local development, always use "Never" to avoid pulling from remote registries
-	deployment.Spec.Template.Spec.Containers[0].ImagePullPolicy = "Never"
+	// Authenticated registries need a pull secret next to the deployment
+	if server := imageRegistryHost(fullImageName); server != "" {
+		secret, err := ensurePullSecret(ctx, cluster, namespace, server)
+		if err != nil {
+			return "", err
+		}
+		if secret != "" {
+			deployment.Spec.Template.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: secret}}
+		}
+	}
+
+	// Never suits images side-loaded into Minikube; clusters whose nodes can
+	// reach the registry pull instead (see IMAGE_PULL_POLICY)
+	deployment.Spec.Template.Spec.Containers[0].ImagePullPolicy = resolvePullPolicy(ctx, cluster, namespace, fullImageName, params.PullPolicy)
+	stampPodTemplate(&deployment.Spec.Template, deployAnnotations(ctx, fullImageName))
 
 	// Create the deployment
-	_, err = clientset.AppsV1().Deployments(namespace).Create(context.TODO(), deployment, metav1.CreateOptions{})
+	_, err = cluster.CreateDeployment(ctx, deployment)
 	if err != nil {
 		// Provide helpful error message
 		errorMsg := fmt.Sprintf("error creating deployment %s: %v", deploymentName, err)
@@ -1196,417 +1028,24 @@ func createKubernetesDeployment(imageName, deploymentName, namespace string) err
 		// Add troubleshooting hints
 		if strings.Contains(err.Error(), "already exists") {
 			errorMsg += "\n\nTip: A deployment with this name already exists. Try using a different image name or delete the existing deployment."
+		} else if apierrors.IsNotFound(err) {
+			errorMsg += fmt.Sprintf("\n\nTip: Namespace %s doesn't exist. Create it with kubectl create namespace %s, or let the create dialog create it.", namespace, namespace)
 		} else {
 			errorMsg += fmt.Sprintf("\n\nTroubleshooting:\n1. Make sure the image exists: docker images | grep %s\n2. For Minikube, load the image: minikube image load %s\n3. Check if registry is running: curl -k https://localhost:443/v2/_catalog", imageName, fullImageName)
 		}
 
-		return fmt.Errorf(errorMsg)
+		return "", fmt.Errorf(errorMsg)
 	}
 
-	return nil
-}
-
-func createDeploymentViaKubectl(imageName, deploymentName, namespace string) error {
-	// Find kubectl binary
-	kubectlPath := findKubectl()
-
-	// Prepare the full image name
-	fullImageName := imageName
-	if !strings.Contains(imageName, "localhost:5000") && !strings.Contains(imageName, "host.minikube.internal:5000") {
-		registryHost := "localhost:5000"
-		if os.Getenv("KUBERNETES_REGISTRY_HOST") != "" {
-			registryHost = os.Getenv("KUBERNETES_REGISTRY_HOST")
-		}
-		imageParts := strings.Split(imageName, "/")
-		imageNameAndTag := imageParts[len(imageParts)-1]
-		fullImageName = fmt.Sprintf("%s/%s", registryHost, imageNameAndTag)
-	}
-
-	// Create a temporary YAML file for the deployment
-	yamlContent := fmt.Sprintf(`apiVersion: apps/v1
-kind: Deployment
-metadata:
-  name: %s
-  namespace: %s
-  labels:
-    app: %s
-spec:
-  replicas: 1
-  selector:
-    matchLabels:
-      app: %s
-  template:
-    metadata:
-      labels:
-        app: %s
-    spec:
-      containers:
-      - name: app
-        image: %s
-        imagePullPolicy: Never
-        ports:
-        - containerPort: 80
-`, deploymentName, namespace, deploymentName, deploymentName, deploymentName, fullImageName)
-
-	// Write to temporary file
-	tmpFile := "/tmp/deployment.yaml"
-	err := os.WriteFile(tmpFile, []byte(yamlContent), 0644)
+	url, err = exposeDeployment(ctx, cluster, params)
 	if err != nil {
-		return fmt.Errorf("failed to create deployment YAML: %v", err)
-	}
-
-	// Execute kubectl apply
-	kubectlCmd := exec.Command(kubectlPath, "apply", "-f", tmpFile)
-
-	// If running in container, use the fixed kubeconfig
-	if _, err := os.Stat("/.dockerenv"); err == nil {
-		fixKubeconfigPaths()
-		kubectlCmd = exec.Command(kubectlPath, "--kubeconfig=/tmp/kubeconfig", "apply", "-f", tmpFile)
-	}
-
-	output, err := kubectlCmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("kubectl apply failed: %v\nOutput: %s", err, string(output))
-	}
-
-	fmt.Printf("✅ Successfully created deployment %s with image %s\n", deploymentName, fullImageName)
-	return nil
-}
-
-func fixKubeconfigPaths() {
-	// When running in Docker, the kubeconfig paths need to be adjusted
-	// since we mount ~/.minikube to /root/.minikube
-	if _, err := os.Stat("/.dockerenv"); err == nil {
-		kubeconfigPath := "/root/.kube/config"
-		if _, err := os.Stat(kubeconfigPath); err == nil {
-			// Read the kubeconfig
-			content, err := os.ReadFile(kubeconfigPath)
-			if err == nil {
-				// Replace paths from /home/nova to /root
-				newContent := strings.ReplaceAll(string(content), "/home/nova/.minikube", "/root/.minikube")
-
-				// Write back the modified kubeconfig
-				tempKubeconfig := "/tmp/kubeconfig"
-				err = os.WriteFile(tempKubeconfig, []byte(newContent), 0644)
-				if err == nil {
-					os.Setenv("KUBECONFIG", tempKubeconfig)
-					fmt.Printf("DEBUG: KUBECONFIG set to %s\n", tempKubeconfig)
-				} else {
-					fmt.Printf("DEBUG: Failed to write kubeconfig: %v\n", err)
-				}
-			} else {
-				fmt.Printf("DEBUG: Failed to read kubeconfig: %v\n", err)
-			}
-		} else {
-			fmt.Printf("DEBUG: Kubeconfig not found at %s\n", kubeconfigPath)
+		// Don't leave an app behind that can't be reached as asked
+		if deleteErr := cluster.DeleteDeployment(ctx, namespace, deploymentName, metav1.DeletePropagationBackground); deleteErr != nil && !apierrors.IsNotFound(deleteErr) {
+			return "", fmt.Errorf("%v; deployment %s was created and couldn't be removed again: %v", err, deploymentName, deleteErr)
 		}
+		return "", fmt.Errorf("%v; removed deployment %s again", err, deploymentName)
 	}
-}
-
-func testConnections() {
-	fmt.Println("Testing database connection...")
-
-	// Fix kubeconfig paths for container environment
-	fixKubeconfigPaths()
-
-	// Test database connection
-	cfg := mysql.NewConfig()
-	cfg.User = os.Getenv("MYSQL_USER")
-	if cfg.User == "" {
-		cfg.User = "mysql"
-	}
-	cfg.Passwd = os.Getenv("MYSQL_ROOT_PASSWORD")
-	if cfg.Passwd == "" {
-		cfg.Passwd = "mysql_password"
-	}
-	cfg.Net = "tcp"
-
-	// Use service name when running in Docker Compose
-	dbHost := os.Getenv("MYSQL_HOST")
-	if dbHost == "" {
-		if _, err := os.Stat("/.dockerenv"); err == nil {
-			dbHost = "db:3306"
-		} else {
-			dbHost = "127.0.0.1:3307"
-		}
-	}
-	cfg.Addr = dbHost
-
-	cfg.DBName = os.Getenv("MYSQL_DATABASE")
-	if cfg.DBName == "" {
-		cfg.DBName = "images"
-	}
-
-	db, err := sql.Open("mysql", cfg.FormatDSN())
-	if err != nil {
-		fmt.Printf("❌ Database connection failed: %v\n", err)
-		return
-	}
-	defer db.Close()
-
-	if err := db.Ping(); err != nil {
-		fmt.Printf("❌ Database ping failed: %v\n", err)
-		return
-	}
-
-	fmt.Println("✅ Database connection successful!")
-
-	// Test GitHub connection
-	fmt.Println("Testing GitHub connection...")
-	client := github.NewClient(nil).WithAuthToken(os.Getenv("gitHubAuth"))
-	owner := os.Getenv("GITHUB_OWNER")
-	repo := os.Getenv("GITHUB_REPO")
-
-	if owner == "" || repo == "" {
-		fmt.Println("⚠️  GitHub credentials not configured (GITHUB_OWNER or GITHUB_REPO missing)")
-	} else {
-		_, _, err := client.Repositories.ListCommits(context.Background(), owner, repo, &github.CommitsListOptions{
-			SHA:         "master",
-			ListOptions: github.ListOptions{Page: 1, PerPage: 1},
-		})
-		if err != nil {
-			fmt.Printf("❌ GitHub connection failed: %v\n", err)
-		} else {
-			fmt.Println("✅ GitHub connection successful!")
-		}
-	}
-
-	// Test Docker registry connection
-	fmt.Println("Testing Docker registry connection...")
-	registryHost := os.Getenv("REGISTRY_HOST")
-	if registryHost == "" {
-		if _, err := os.Stat("/.dockerenv"); err == nil {
-			registryHost = "registry:5000"
-		} else {
-			registryHost = "localhost:5000"
-		}
-	}
-
-	cmd := exec.Command("curl", "-s", fmt.Sprintf("http://%s/v2/_catalog", registryHost))
-	output, err := cmd.Output()
-	if err != nil {
-		fmt.Printf("❌ Registry connection failed: %v\n", err)
-	} else {
-		fmt.Println("✅ Registry connection successful!")
-		fmt.Printf("Registry catalog: %s\n", string(output))
-	}
-
-	// Test Kubernetes connection
-	fmt.Println("Testing Kubernetes connection...")
-	if _, err := os.Stat("/.dockerenv"); err == nil {
-		// In container - test kubectl access
-		fixKubeconfigPaths()
-		kubectlCmd := exec.Command("kubectl", "--kubeconfig=/tmp/kubeconfig", "get", "pods", "--all-namespaces")
-		output, err := kubectlCmd.CombinedOutput()
-		if err != nil {
-			fmt.Printf("kubectl output: %s\n", string(output))
-			if strings.Contains(string(output), "dial tcp") && strings.Contains(string(output), "i/o timeout") {
-				fmt.Println("⚠️  Kubernetes API not accessible from container (networking limitation)")
-				fmt.Println("💡 kubectl fallback will be used in the TUI")
-			} else {
-				fmt.Printf("❌ kubectl configuration error\n")
-			}
-		} else {
-			fmt.Println("✅ kubectl connection successful!")
-			// Show first few lines of output
-			lines := strings.Split(string(output), "\n")
-			if len(lines) > 3 {
-				fmt.Printf("Found pods: %s\n", strings.Join(lines[1:4], ", "))
-			}
-		}
-	} else {
-		// On host - test direct API access
-		kubeconfig := os.Getenv("KUBECONFIG")
-		if kubeconfig == "" {
-			if home := homedir.HomeDir(); home != "" {
-				kubeconfig = filepath.Join(home, ".kube", "config")
-			}
-		}
-
-		if kubeconfig == "" {
-			fmt.Println("⚠️  KUBECONFIG environment variable not set")
-		} else if _, err := os.Stat(kubeconfig); os.IsNotExist(err) {
-			fmt.Printf("❌ Kubeconfig not found at: %s\n", kubeconfig)
-		} else {
-			// Try to list pods
-			config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-			if err != nil {
-				fmt.Printf("❌ Kubernetes config error: %v\n", err)
-			} else {
-				clientset, err := kubernetes.NewForConfig(config)
-				if err != nil {
-					fmt.Printf("❌ Kubernetes client error: %v\n", err)
-				} else {
-					pods, err := clientset.CoreV1().Pods("default").List(context.TODO(), metav1.ListOptions{Limit: 1})
-					if err != nil {
-						fmt.Printf("❌ Kubernetes API error: %v\n", err)
-					} else {
-						fmt.Printf("✅ Kubernetes connection successful! Found %d pods in default namespace\n", len(pods.Items))
-					}
-				}
-			}
-		}
-	}
-
-	fmt.Println("🎉 All connection tests completed!")
-}
-
-func getPodsViaKubectl() ([]TableData, error) {
-	// Find kubectl binary
-	kubectlPath := findKubectl()
-
-	// Use kubectl to get pod information
-	kubectlCmd := exec.Command(kubectlPath, "get", "pods", "--all-namespaces",
-		"-o", "jsonpath={range .items[*]}{.metadata.name},{.metadata.namespace},{.status.phase},{.status.containerStatuses[0].restartCount},{.metadata.creationTimestamp}{'\\n'}{end}")
-
-	// If running in container, use the fixed kubeconfig
-	if _, err := os.Stat("/.dockerenv"); err == nil {
-		fixKubeconfigPaths()
-		kubectlCmd = exec.Command(kubectlPath, "--kubeconfig=/tmp/kubeconfig", "get", "pods", "--all-namespaces",
-			"-o", "jsonpath={range .items[*]}{.metadata.name},{.metadata.namespace},{.status.phase},{.status.containerStatuses[0].restartCount},{.metadata.creationTimestamp}{'\\n'}{end}")
-	}
-
-	output, err := kubectlCmd.CombinedOutput()
-	if err != nil {
-		// Provide helpful error message for networking issues
-		errorMsg := "Kubernetes connection error"
-		if strings.Contains(string(output), "dial tcp") && strings.Contains(string(output), "i/o timeout") {
-			errorMsg = "Cannot reach Kubernetes cluster. Run on host or check Minikube status."
-		} else if strings.Contains(string(output), "Unable to connect to the server") {
-			errorMsg = "Kubernetes cluster not accessible. Check Minikube status."
-		}
-		return []TableData{{
-			PodName:   errorMsg,
-			Namespace: "N/A",
-			Status:    "Error",
-			Restarts:  "N/A",
-			Age:       "N/A",
-		}}, nil
-	}
-
-	// Parse the output
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(lines) == 0 || (len(lines) == 1 && lines[0] == "") {
-		return []TableData{{
-			PodName:   "No pods found",
-			Namespace: "N/A",
-			Status:    "N/A",
-			Restarts:  "N/A",
-			Age:       "N/A",
-		}}, nil
-	}
-
-	var tableData []TableData
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-		parts := strings.Split(line, ",")
-		if len(parts) >= 5 {
-			// Calculate age from creation timestamp
-			age := "N/A"
-			if len(parts) >= 5 {
-				if t, err := time.Parse(time.RFC3339, parts[4]); err == nil {
-					age = time.Since(t).Truncate(time.Second).String()
-				}
-			}
-
-			restartCount := "0"
-			if len(parts) >= 4 && parts[3] != "" {
-				restartCount = parts[3]
-			}
-
-			tableData = append(tableData, TableData{
-				PodName:   parts[0],
-				Namespace: parts[1],
-				Status:    parts[2],
-				Restarts:  restartCount,
-				Age:       age,
-			})
-		}
-	}
-
-	if len(tableData) == 0 {
-		return []TableData{{
-			PodName:   "No pods found",
-			Namespace: "N/A",
-			Status:    "N/A",
-			Restarts:  "N/A",
-			Age:       "N/A",
-		}}, nil
-	}
-
-	return tableData, nil
-}
-
-func getPodDetailsViaKubectl(podName, namespace string) (map[string]string, error) {
-	// Find kubectl binary
-	kubectlPath := findKubectl()
-
-	// Use kubectl to get detailed pod information
-	kubectlCmd := exec.Command(kubectlPath, "get", "pod", podName, "-n", namespace, "-o", "yaml")
-
-	// If running in container, use the fixed kubeconfig
-	if _, err := os.Stat("/.dockerenv"); err == nil {
-		fixKubeconfigPaths()
-		kubectlCmd = exec.Command(kubectlPath, "--kubeconfig=/tmp/kubeconfig", "get", "pod", podName, "-n", namespace, "-o", "yaml")
-	}
-
-	output, err := kubectlCmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("kubectl get pod failed: %v\nOutput: %s", err, string(output))
-	}
-
-	// Parse the YAML output to extract key information
-	details := make(map[string]string)
-	lines := strings.Split(string(output), "\n")
-
-	details["Name"] = podName
-	details["Namespace"] = namespace
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.Contains(line, "phase:") {
-			details["Status"] = strings.TrimSpace(strings.TrimPrefix(line, "phase:"))
-		}
-		if strings.Contains(line, "nodeName:") {
-			details["Node"] = strings.TrimSpace(strings.TrimPrefix(line, "nodeName:"))
-		}
-		if strings.Contains(line, "restartCount:") {
-			details["Restarts"] = strings.TrimSpace(strings.TrimPrefix(line, "restartCount:"))
-		}
-		if strings.Contains(line, "image:") {
-			if _, exists := details["Image"]; !exists {
-				details["Image"] = strings.TrimSpace(strings.TrimPrefix(line, "image:"))
-			}
-		}
-	}
-
-	return details, nil
-}
-
-func findKubectl() string {
-	// Try multiple possible kubectl locations
-	possiblePaths := []string{
-		os.Getenv("HOME") + "/bin/kubectl", // User location (highest priority)
-		"/usr/local/bin/kubectl",           // System location
-		"/usr/bin/kubectl",                 // Alternative system location
-		"./kubectl",                        // Current directory
-		"kubectl",                          // Rely on PATH
-	}
-
-	for _, path := range possiblePaths {
-		if _, err := os.Stat(path); err == nil {
-			// Test if it's executable and works
-			cmd := exec.Command(path, "version", "--client")
-			if err := cmd.Run(); err == nil {
-				return path
-			}
-		}
-	}
-
-	// Fallback to PATH
-	return "kubectl"
+	return url, nil
 }
 
 func isTTYAvailable() bool {
@@ -1618,9 +1057,30 @@ func isTTYAvailable() bool {
 }
 
 func main() {
+	// Completion runs on every Tab press: it prints candidates and nothing
+	// more, without tracing, notifying or forwarding audit events
+	if len(os.Args) > 1 && os.Args[1] == "__complete" {
+		runCommand(os.Args[1:])
+		return
+	}
+
+	// Crash reports include the end of the log
+	log.SetOutput(io.MultiWriter(os.Stderr, recentLogs))
+	setupTracing(context.Background())
+	onShutdown(func() { shutdownTracing() })
+	defer runShutdown()
+	defer recoverCrash()
+	watchSignals()
+	startNotifications()
+
+	// Headless subcommands (migrate, ...) run without the TUI
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
 	// Check if TEST_MODE environment variable is set (for non-interactive testing)
 	if os.Getenv("TEST_MODE") == "true" {
-		testConnections()
+		runCommand([]string{"doctor"})
 		return
 	}
 
@@ -1634,7 +1094,7 @@ func main() {
 
 		err := cmd.Run()
 		if err != nil {
-			log.Fatalf("❌ Docker build failed: %v", err)
+			fatal("❌ Docker build failed: ", err)
 		}
 
 		fmt.Println("✅ Docker image built successfully!")
@@ -1642,188 +1102,40 @@ func main() {
 		return
 	}
 
-	// Fix kubeconfig paths for container environment (do this early)
-	fixKubeconfigPaths()
-
-	// Capture connection properties for the MySQL database
-	cfg := mysql.NewConfig()
-	cfg.User = os.Getenv("MYSQL_USER")
-	if cfg.User == "" {
-		cfg.User = "mysql"
-	}
-	cfg.Passwd = os.Getenv("MYSQL_ROOT_PASSWORD")
-	if cfg.Passwd == "" {
-		cfg.Passwd = "mysql_password"
-	}
-	cfg.Net = "tcp"
-
-	// Use service name when running in Docker Compose, fallback to localhost for local development
-	dbHost := os.Getenv("MYSQL_HOST")
-	if dbHost == "" {
-		// Check if we're running in Docker by looking for the db service
-		if _, err := os.Stat("/.dockerenv"); err == nil {
-			dbHost = "db:3306"
-		} else {
-			dbHost = "127.0.0.1:3307"
-		}
-	}
-	cfg.Addr = dbHost
-
-	cfg.DBName = os.Getenv("MYSQL_DATABASE")
-	if cfg.DBName == "" {
-		cfg.DBName = "images"
+	options, err := parseStartOptions(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
-
-	// Get a database handle.
-	var err error
-	db, err = sql.Open("mysql", cfg.FormatDSN())
 	if err != nil {
-		log.Fatal(err)
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		exit(1)
 	}
 
-	pingErr := db.Ping()
-	if pingErr != nil {
-		log.Fatal(pingErr)
-	}
-	fmt.Println("Connected!")
-
-	var (
-		Green  = "\033[32m"
-		Reset  = "\033[0m"
-		Yellow = "\033[33m"
-	)
-
-	fmt.Println("------------------------------------------------------------------------------------------------")
-	println(Yellow + "Logging into Github..." + Reset)
-	fmt.Println("------------------------------------------------------------------------------------------------")
-
-	client := github.NewClient(nil).WithAuthToken(os.Getenv("GITHUB_AUTH_TOKEN"))
-	owner := os.Getenv("GITHUB_OWNER")
-	repo := os.Getenv("GITHUB_REPO")
-
-	branch := "master"
-	// Get multiple commits instead of just one
-	commits, _, err := client.Repositories.ListCommits(context.Background(), owner, repo, &github.CommitsListOptions{
-		SHA: branch,
-		ListOptions: github.ListOptions{
-			Page:    1,
-			PerPage: 10, // Get last 10 commits
-		},
-	})
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	println(Green + "Logged into Github" + Reset)
-	fmt.Println("------------------------------------------------------------------------------------------------")
-	fmt.Printf("Found %d commits on master branch\n", len(commits))
-	if len(commits) > 0 {
-		fmt.Printf("Latest commit: %s\n", commits[0].GetCommit().GetMessage())
-		fmt.Printf("SHA: %s\n", commits[0].GetSHA())
-	}
-
-	// fmt.Printf("Owner: %v\n", repoData.GetOwner())
-	// fmt.Printf("repo: %+v\n", repoData.GetFullName())
-	// fmt.Printf("Date: %s\n", commit.GetCommit().GetAuthor().GetDate())
-	// fmt.Printf("UpdatedAt: %v\n", repoData.GetUpdatedAt())
-	// fmt.Printf("Author: %s\n", commit.GetCommit().GetAuthor().GetName())
-	// fmt.Printf("ID: %d\n", repoData.GetID())
-	// 	fmt.Printf("PushedAt: %v\n", repoData.GetPushedAt())
-	// 	Create a code break
-	// 	fmt.Println("------------------------------------------------------------------------------------------------")
-	// 	fmt.Printf("Size: %d\n", repoData.GetSize())
-	// 	fmt.Printf("CommitsURL: %s\n", repoData.GetCommitsURL())
-	// 	fmt.Printf("FullName: %s\n", repoData.GetFullName())
-	// 	fmt.Printf("Name: %s\n", repoData.GetName())
-	// 	fmt.Printf("Description: %s\n", repoData.GetDescription())
-	// 	fmt.Printf("BranchesURL: %s\n", repoData.GetBranchesURL())
-	// 	fmt.Printf("CreatedAt: %v\n", repoData.GetCreatedAt())
-	// 	fmt.Printf("URL: %s\n", repoData.GetURL())
-	// 	fmt.Println("Logged into Github")
-
-	// Process each commit for database insertion
-	for _, commit := range commits {
-		commitMessage := commit.GetCommit().GetMessage()
-		fmt.Printf("Processing commit: %s\n", commitMessage)
-
-		// Insert into MySQL database
-		_, err = db.Exec("INSERT INTO images (PR_Description) VALUES (?)", commitMessage)
-		if err != nil {
-			// Silently continue on database errors during TUI operation
-		}
-	}
+	// Fix kubeconfig paths for container environment (do this early)
+	fixKubeconfigPaths()
 
-	// Get Docker images information
-	dockerImages, err := getDockerImagesInfo()
+	// Get a database handle; it connects along with the TUI's first loads
+	db, err = openDatabase()
 	if err != nil {
-		dockerImages = []DockerImage{{
-			ID:        "Error",
-			RepoTags:  []string{"N/A"},
-			Size:      "N/A",
-			CreatedAt: "N/A",
-		}}
+		fatal(err)
 	}
+	closeDatabaseOnShutdown()
 
-	// Start TUI with collected data from all commits
-	var gitTableData []TableData
-	for _, commit := range commits {
-		commitMessage := commit.GetCommit().GetMessage()
-
-		// Get PushedAt from individual commit date
-		pushedAt := "N/A"
-		if commit.GetCommit() != nil && commit.GetCommit().GetAuthor() != nil {
-			pushedAt = commit.GetCommit().GetAuthor().GetDate().Format("2006-01-02 15:04:05")
-		}
-
-		gitTableData = append(gitTableData, TableData{
-			CommitSHA:     commit.GetSHA(),
-			PRDescription: commitMessage,
-			PushedAt:      pushedAt,
-		})
+	if options.plain {
+		printSnapshot(options)
+		return
 	}
 
-	// Create Docker table data from actual Docker images
-	var dockerTableData []TableData
-	for _, dockerImg := range dockerImages {
-		imageID := dockerImg.ID
-		if len(imageID) > 20 {
-			imageID = imageID[:20] // Show more of the ID to match column width
-		}
-
-		imageTag := "N/A"
-		if len(dockerImg.RepoTags) > 0 && dockerImg.RepoTags[0] != "<none>:<none>" {
-			imageTag = dockerImg.RepoTags[0]
-		}
-
-		imageSize := dockerImg.Size
-		if dockerImg.Size == "" || dockerImg.Size == "N/A" {
-			imageSize = "N/A"
+	if options.record != "" {
+		if err := startSessionRecording(options.record); err != nil {
+			fatal(err)
 		}
-
-		dockerTableData = append(dockerTableData, TableData{
-			ImageID:   imageID,
-			ImageSize: imageSize,
-			ImageTag:  imageTag,
-			CreatedAt: dockerImg.CreatedAt,
-		})
-	}
-
-	// Get Kubernetes pods information
-	kubernetesData, err := getKubernetesPodsInfo()
-	if err != nil {
-		kubernetesData = []TableData{{
-			PodName:   "Error",
-			Namespace: "N/A",
-			Status:    "N/A",
-			Restarts:  "N/A",
-			Age:       "N/A",
-		}}
 	}
 
 	// Disable logging before starting TUI to prevent interference
 	disableLogging()
 
-	startTUI(gitTableData, dockerTableData, kubernetesData)
+	startTUI(options.tabs[0])
 }
 
 // I need to insert git commits into the mysql database
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain runs the tests against a home directory of their own, where no
+// kubeconfig, credentials or state of a developer's machine are found.
+// Kubernetes is enabled, as the settings read once per process can't differ
+// between tests; each test gives the model the cluster it means.
+func TestMain(m *testing.M) {
+	home, err := os.MkdirTemp("", "lcr-test-home")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("HOME", home)
+	os.Setenv("KUBECONFIG", filepath.Join(home, "no-kubeconfig"))
+	os.Setenv("KUBERNETES_ENABLED", "true")
+	os.Setenv("KUBERNETES_NAMESPACE", "default")
+	// Settled now, before testenv.Start sets KUBERNETES_ENABLED=false
+	kubernetesEnabled()
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// /metrics of serve, in the Prometheus text format, counts what the event
+// bus carries: how often each kind was published, how many items the
+// latest snapshot had and when it changed, and how often collecting
+// failed.
+
+type kindMetrics struct {
+	published int
+	failed    int
+	items     int
+	updated   time.Time
+}
+
+var busMetrics struct {
+	mu    sync.Mutex
+	kinds map[eventKind]*kindMetrics
+}
+
+// registerMetricsRoute serves /metrics, fed by a subscriber for the life
+// of the process.
+func registerMetricsRoute(mux *http.ServeMux) {
+	busMetrics.kinds = make(map[eventKind]*kindMetrics)
+	for _, kind := range eventKinds {
+		busMetrics.kinds[kind] = &kindMetrics{}
+	}
+	events, _ := bus.subscribe()
+	go func() {
+		for e := range events {
+			busMetrics.mu.Lock()
+			metrics := busMetrics.kinds[e.Kind]
+			metrics.published++
+			if e.Error != "" {
+				metrics.failed++
+			} else if e.Kind != eventHistory && e.Kind != eventWebhook {
+				metrics.items, metrics.updated = e.Count, e.Time
+			}
+			busMetrics.mu.Unlock()
+		}
+	}()
+
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		busMetrics.mu.Lock()
+		defer busMetrics.mu.Unlock()
+
+		fmt.Fprintln(w, "# HELP lcr_events_total Events published on the event bus.")
+		fmt.Fprintln(w, "# TYPE lcr_events_total counter")
+		for _, kind := range eventKinds {
+			fmt.Fprintf(w, "lcr_events_total{kind=%q} %d\n", kind, busMetrics.kinds[kind].published)
+		}
+		fmt.Fprintln(w, "# HELP lcr_collect_errors_total Loads of images, pods or commits failing with a new error.")
+		fmt.Fprintln(w, "# TYPE lcr_collect_errors_total counter")
+		for _, kind := range []eventKind{eventImages, eventPods, eventCommits} {
+			fmt.Fprintf(w, "lcr_collect_errors_total{kind=%q} %d\n", kind, busMetrics.kinds[kind].failed)
+		}
+		fmt.Fprintln(w, "# HELP lcr_items Items in the latest snapshot of images, pods or commits.")
+		fmt.Fprintln(w, "# TYPE lcr_items gauge")
+		for _, kind := range []eventKind{eventImages, eventPods, eventCommits} {
+			fmt.Fprintf(w, "lcr_items{kind=%q} %d\n", kind, busMetrics.kinds[kind].items)
+		}
+		fmt.Fprintln(w, "# HELP lcr_last_update_timestamp_seconds When the snapshot last changed.")
+		fmt.Fprintln(w, "# TYPE lcr_last_update_timestamp_seconds gauge")
+		for _, kind := range []eventKind{eventImages, eventPods, eventCommits} {
+			if updated := busMetrics.kinds[kind].updated; !updated.IsZero() {
+				fmt.Fprintf(w, "lcr_last_update_timestamp_seconds{kind=%q} %d\n", kind, updated.Unix())
+			}
+		}
+	})
+}
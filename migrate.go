@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	from := fs.String("from", getRegistryHost(), "source registry (host:port or URL)")
+	to := fs.String("to", "", "destination registry (host:port or URL)")
+	repos := fs.String("repos", "", "comma-separated repositories to migrate (default: all)")
+	updateWorkloads := fs.Bool("update-workloads", false, "rewrite deployment images that reference the old prefix")
+	clusterFrom := fs.String("cluster-from", "", "registry prefix used by cluster workloads (default: --from)")
+	clusterTo := fs.String("cluster-to", "", "registry prefix to write into cluster workloads (default: --to)")
+	dryRun := fs.Bool("dry-run", false, "print what would be copied without changing anything")
+	parallel := fs.Int("parallel", uploadParallelism(), "blobs of an image to upload at once")
+	addRegistryFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *to == "" {
+		return fmt.Errorf("migrate: --to is required")
+	}
+
+	// Ctrl+C aborts the in-flight copy instead of leaving the process hanging
+	ctx, stop := signalContext()
+	defer stop()
+	connectDatabase(ctx)
+
+	src := newRegistryClient(ctx, *from)
+	dst := newRegistryClient(ctx, *to)
+	if src.BaseURL() == dst.BaseURL() {
+		return fmt.Errorf("migrate: source and destination are the same registry")
+	}
+	// Blobs go up the way sync sends them: in resumable chunks, retried
+	syncer := &registrySyncer{src: src, dst: dst, state: loadSyncState(), retries: syncRetries(), parallel: *parallel}
+
+	// Registries without a catalog are migrated by naming the repositories
+	var repositories []string
+	if *repos != "" {
+		repositories = strings.Split(*repos, ",")
+	} else {
+		var err error
+		if repositories, err = listRepositories(ctx, src); err != nil {
+			return fmt.Errorf("failed to list repositories on %s: %v", src.Host(), err)
+		}
+	}
+
+	fmt.Printf("🚚 Migrating %d repositories from %s to %s\n", len(repositories), src.Host(), dst.Host())
+	printBandwidthLimit()
+
+	copied, failed := 0, 0
+	for _, repo := range repositories {
+		repo = strings.TrimSpace(repo)
+		tags, err := src.Tags(ctx, repo)
+		if err != nil {
+			fmt.Printf("❌ %s: failed to list tags: %v\n", repo, err)
+			failed++
+			continue
+		}
+		for _, tag := range tags {
+			if *dryRun {
+				fmt.Printf("📦 %s/%s:%s → %s/%s:%s (dry run)\n", src.Host(), repo, tag, dst.Host(), repo, tag)
+				continue
+			}
+			err := syncer.copyImage(ctx, repo, tag)
+			recordActionResult(ctx, "migrate", fmt.Sprintf("%s:%s", repo, tag), err, fmt.Sprintf("%s to %s", src.Host(), dst.Host()))
+			if err != nil {
+				fmt.Printf("❌ %s:%s: %v\n", repo, tag, err)
+				failed++
+				continue
+			}
+			fmt.Printf("✅ %s:%s\n", repo, tag)
+			copied++
+		}
+	}
+
+	fmt.Printf("Copied %d images, %d failed\n", copied, failed)
+
+	if *updateWorkloads {
+		oldPrefix := *clusterFrom
+		if oldPrefix == "" {
+			oldPrefix = src.Host()
+		}
+		newPrefix := *clusterTo
+		if newPrefix == "" {
+			newPrefix = dst.Host()
+		}
+		if err := rewriteWorkloadImages(ctx, oldPrefix, newPrefix, *dryRun); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d images failed to migrate", failed)
+	}
+	return nil
+}
+
+// copyImage copies a tag and everything it references. Manifest lists are
+// walked so multi-arch images arrive complete; child manifests are pushed by
+// digest before the tag itself.
+func (s *registrySyncer) copyImage(ctx context.Context, repository, tag string) error {
+	body, mediaType, err := s.src.Manifest(ctx, repository, tag)
+	if err != nil {
+		return err
+	}
+	if err := s.copyManifestContent(ctx, repository, body); err != nil {
+		return err
+	}
+	return s.dst.PutManifest(ctx, repository, tag, mediaType, body)
+}
+
+func (s *registrySyncer) copyManifestContent(ctx context.Context, repository string, body []byte) error {
+	var manifest registryManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return fmt.Errorf("failed to parse manifest: %v", err)
+	}
+
+	for _, child := range manifest.Manifests {
+		childBody, childType, err := s.src.Manifest(ctx, repository, child.Digest)
+		if err != nil {
+			return err
+		}
+		if childType == "" {
+			childType = child.MediaType
+		}
+		if err := s.copyManifestContent(ctx, repository, childBody); err != nil {
+			return err
+		}
+		if err := s.dst.PutManifest(ctx, repository, child.Digest, childType, childBody); err != nil {
+			return err
+		}
+	}
+
+	blobs := manifest.Layers
+	if manifest.Config.Digest != "" {
+		blobs = append([]registryDescriptor{manifest.Config}, blobs...)
+	}
+	return s.copyBlobs(ctx, repository, blobs)
+}
+
+// copyBlobs copies an image's blobs, s.parallel at once. The first failure
+// cancels the uploads still running.
+func (s *registrySyncer) copyBlobs(ctx context.Context, repository string, blobs []registryDescriptor) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	limit := make(chan struct{}, max(s.parallel, 1))
+	seen := make(map[string]bool)
+	for _, blob := range blobs {
+		// Two uploads of one blob would share its upload session
+		if seen[blob.Digest] {
+			continue
+		}
+		seen[blob.Digest] = true
+		wg.Add(1)
+		go func(blob registryDescriptor) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			if ctx.Err() != nil {
+				return
+			}
+			if err := s.copyBlob(ctx, repository, blob); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(blob)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// rewriteWorkloadImages points every deployment container that pulls from
+// oldPrefix at newPrefix, keeping the repository and tag unchanged.
+func rewriteWorkloadImages(ctx context.Context, oldPrefix, newPrefix string, dryRun bool) error {
+	cluster := newCluster(ctx)
+	listCtx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	deployments, err := cluster.Deployments(listCtx, metav1.NamespaceAll)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("error listing deployments: %v", err)
+	}
+
+	updated := 0
+	for _, deployment := range deployments {
+		deploymentCopy := deployment.DeepCopy()
+		changed := false
+		podSpec := &deploymentCopy.Spec.Template.Spec
+		for i := range podSpec.InitContainers {
+			if image, ok := rewriteImagePrefix(podSpec.InitContainers[i].Image, oldPrefix, newPrefix); ok {
+				podSpec.InitContainers[i].Image = image
+				changed = true
+			}
+		}
+		for i := range podSpec.Containers {
+			if image, ok := rewriteImagePrefix(podSpec.Containers[i].Image, oldPrefix, newPrefix); ok {
+				podSpec.Containers[i].Image = image
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		if dryRun {
+			fmt.Printf("☸️  deployment %s/%s would be updated (dry run)\n", deployment.Namespace, deployment.Name)
+			continue
+		}
+		updateCtx, cancel := withBackendTimeout(ctx, backendKubernetes)
+		_, err := cluster.UpdateDeployment(updateCtx, deploymentCopy)
+		cancel()
+		if err != nil {
+			fmt.Printf("❌ deployment %s/%s: %v\n", deployment.Namespace, deployment.Name, err)
+			continue
+		}
+		fmt.Printf("☸️  Updated deployment %s/%s\n", deployment.Namespace, deployment.Name)
+		updated++
+	}
+
+	fmt.Printf("Updated %d deployments\n", updated)
+	return nil
+}
+
+func rewriteImagePrefix(image, oldPrefix, newPrefix string) (string, bool) {
+	oldPrefix = strings.TrimSuffix(oldPrefix, "/") + "/"
+	if !strings.HasPrefix(image, oldPrefix) {
+		return image, false
+	}
+	return strings.TrimSuffix(newPrefix, "/") + "/" + strings.TrimPrefix(image, oldPrefix), true
+}
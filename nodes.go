@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/backend"
+)
+
+// nodeUsage is a node's live usage from the metrics API.
+type nodeUsage struct {
+	cpu, memory resource.Quantity
+}
+
+// pendingPod is a pod the scheduler couldn't place, with its explanation.
+type pendingPod struct {
+	name, namespace, reason string
+}
+
+type nodesDataMsg struct {
+	data    []TableData
+	pending []pendingPod
+	metrics bool // whether the metrics API answered
+	err     error
+}
+
+// getNodeOverview compares every node's allocatable CPU and memory with the
+// requests of the pods scheduled on it and, when metrics-server is
+// installed, with actual usage. It also returns the pods stuck in Pending.
+func getNodeOverview(ctx context.Context) nodesDataMsg {
+	cluster := newCluster(ctx)
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	nodes, err := cluster.Nodes(ctx)
+	if err != nil {
+		return nodesDataMsg{err: fmt.Errorf("failed to list nodes: %v", err)}
+	}
+	pods, err := cluster.ListPods(ctx, metav1.NamespaceAll, "")
+	if err != nil {
+		return nodesDataMsg{err: fmt.Errorf("failed to list pods: %v", err)}
+	}
+
+	requested := make(map[string]corev1.ResourceList)
+	var pending []pendingPod
+	for _, pod := range pods {
+		switch pod.Status.Phase {
+		case corev1.PodSucceeded, corev1.PodFailed:
+			continue
+		case corev1.PodPending:
+			if pod.Spec.NodeName == "" {
+				pending = append(pending, pendingPod{name: pod.Name, namespace: pod.Namespace, reason: schedulingReason(pod)})
+			}
+		}
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		total := requested[pod.Spec.NodeName]
+		if total == nil {
+			total = corev1.ResourceList{}
+			requested[pod.Spec.NodeName] = total
+		}
+		for _, container := range pod.Spec.Containers {
+			for name, quantity := range container.Resources.Requests {
+				sum := total[name]
+				sum.Add(quantity)
+				total[name] = sum
+			}
+		}
+	}
+
+	usage := getNodeUsage(ctx, cluster)
+
+	var rows []TableData
+	for _, node := range nodes {
+		allocatable := node.Status.Allocatable
+		requests := requested[node.Name]
+		row := TableData{
+			NodeName:       node.Name,
+			Status:         nodeConditions(node),
+			KubeletVersion: node.Status.NodeInfo.KubeletVersion,
+			NodeCPU:        resourceSummary(requests[corev1.ResourceCPU], allocatable[corev1.ResourceCPU], formatCPU),
+			NodeMemory:     resourceSummary(requests[corev1.ResourceMemory], allocatable[corev1.ResourceMemory], formatMemory),
+		}
+		if used, ok := usage[node.Name]; ok {
+			row.NodeCPUUsage = resourceSummary(used.cpu, allocatable[corev1.ResourceCPU], formatCPU)
+			row.NodeMemoryUsage = resourceSummary(used.memory, allocatable[corev1.ResourceMemory], formatMemory)
+		}
+		rows = append(rows, row)
+	}
+	return nodesDataMsg{data: rows, pending: pending, metrics: usage != nil}
+}
+
+// getNodeUsage is what the nodes use according to metrics-server, nil when
+// it isn't installed.
+func getNodeUsage(ctx context.Context, cluster backend.ClusterAPI) map[string]nodeUsage {
+	metrics, err := cluster.NodeMetrics(ctx)
+	if err != nil {
+		return nil
+	}
+	usage := make(map[string]nodeUsage)
+	for node, used := range metrics {
+		usage[node] = nodeUsage{cpu: used[corev1.ResourceCPU], memory: used[corev1.ResourceMemory]}
+	}
+	return usage
+}
+
+// nodeConditions is "Ready", or what is wrong with the node, e.g.
+// "NotReady,DiskPressure".
+func nodeConditions(node corev1.Node) string {
+	var problems []string
+	for _, condition := range node.Status.Conditions {
+		switch {
+		case condition.Type == corev1.NodeReady && condition.Status != corev1.ConditionTrue:
+			problems = append([]string{"NotReady"}, problems...)
+		case condition.Type != corev1.NodeReady && condition.Status == corev1.ConditionTrue:
+			problems = append(problems, string(condition.Type))
+		}
+	}
+	if node.Spec.Unschedulable {
+		problems = append(problems, "Cordoned")
+	}
+	if len(problems) == 0 {
+		return "Ready"
+	}
+	return strings.Join(problems, ",")
+}
+
+// schedulingReason is the scheduler's message, e.g. "0/1 nodes are
+// available: 1 Insufficient cpu."
+func schedulingReason(pod corev1.Pod) string {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse && condition.Message != "" {
+			return condition.Message
+		}
+	}
+	return "not scheduled yet"
+}
+
+func resourceSummary(used, allocatable resource.Quantity, format func(resource.Quantity) string) string {
+	if allocatable.IsZero() {
+		return format(used)
+	}
+	percent := float64(used.MilliValue()) * 100 / float64(allocatable.MilliValue())
+	return fmt.Sprintf("%s/%s (%.0f%%)", format(used), format(allocatable), percent)
+}
+
+func formatCPU(q resource.Quantity) string {
+	return fmt.Sprintf("%.1f", float64(q.MilliValue())/1000)
+}
+
+func formatMemory(q resource.Quantity) string {
+	return formatBytes(q.Value())
+}
+
+func (m model) renderNodesStatus() string {
+	switch {
+	case m.nodesData == nil && m.nodesErr == nil:
+		return "Loading nodes..."
+	case m.nodesErr != nil:
+		return fmt.Sprintf("⚠️  %v", m.nodesErr)
+	}
+
+	status := fmt.Sprintf("%d nodes · requests vs. allocatable", len(m.nodesData))
+	if !m.nodesMetrics {
+		status += " · install metrics-server for live usage"
+	}
+	if len(m.nodesPending) == 0 {
+		return status + " · no pods Pending"
+	}
+	first := m.nodesPending[0]
+	status += fmt.Sprintf("\n⏳ %d pods Pending, e.g. %s/%s: %s", len(m.nodesPending), first.namespace, first.name, first.reason)
+	return status
+}
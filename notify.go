@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Activity in the shared environment can be posted to team chat through
+// incoming webhooks: NOTIFY_WEBHOOK_URLS lists Slack, Discord or other
+// Slack-compatible (Mattermost, Rocket.Chat) webhook URLs. NOTIFY_EVENTS
+// picks what is posted, by default all of:
+//
+//   - push: an image was pushed, as serve's collectors find new or moved
+//     tags
+//   - deploy: a deploy, deployment creation, canary, promotion or
+//     auto-deploy succeeded or failed
+//   - prune: a prune deleted images, with the space it reclaims
+//
+// Deploys and prunes are posted by whatever records them in the history:
+// the TUI, a command or an API request.
+
+const (
+	notifyPush   = "push"
+	notifyDeploy = "deploy"
+	notifyPrune  = "prune"
+)
+
+var notifyEventNames = []string{notifyPush, notifyDeploy, notifyPrune}
+
+// deployActions are the history actions posted as deploy events, with the
+// verb the message uses.
+var deployActions = map[string]string{
+	"deploy":            "deployed to",
+	"create-deployment": "created",
+	"canary":            "started a canary of",
+	"promote":           "promoted",
+	"auto-deploy":       "auto-deployed to",
+}
+
+// notifyDeliveryTimeout bounds posting one message to one webhook.
+const notifyDeliveryTimeout = 10 * time.Second
+
+func notifyWebhookURLs() []string {
+	var urls []string
+	for _, webhookURL := range strings.Split(os.Getenv("NOTIFY_WEBHOOK_URLS"), ",") {
+		if webhookURL = strings.TrimSpace(webhookURL); webhookURL != "" {
+			urls = append(urls, webhookURL)
+		}
+	}
+	return urls
+}
+
+// notifyEvents is NOTIFY_EVENTS, every event when unset.
+func notifyEvents() []string {
+	value := os.Getenv("NOTIFY_EVENTS")
+	if value == "" {
+		return notifyEventNames
+	}
+	var events []string
+	for _, event := range strings.Split(value, ",") {
+		event = strings.TrimSpace(event)
+		if !slices.Contains(notifyEventNames, event) {
+			log.Printf("⚠️  Ignoring NOTIFY_EVENTS entry %q: not one of %s", event, strings.Join(notifyEventNames, ", "))
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// newNotifier is the configured notifier, nil when there is nothing to post
+// or nowhere to post it.
+func newNotifier() *notifier {
+	urls, events := notifyWebhookURLs(), notifyEvents()
+	if len(urls) == 0 || len(events) == 0 {
+		return nil
+	}
+	return &notifier{urls: urls, events: events}
+}
+
+// startNotifications posts the deploys and prunes recorded in the history
+// until the process shuts down. Messages still queued then are posted
+// first, so a headless command's own prune or deploy is posted before it
+// exits.
+func startNotifications() {
+	notifier := newNotifier()
+	if notifier == nil {
+		return
+	}
+	events, cancel := bus.subscribe(eventHistory)
+	var done sync.WaitGroup
+	done.Add(1)
+	go func() {
+		defer done.Done()
+		for e := range events {
+			if message := notifier.historyMessage(e); message != "" {
+				notifier.post(message)
+			}
+		}
+	}()
+	onShutdown(func() {
+		cancel()
+		finished := make(chan struct{})
+		go func() {
+			done.Wait()
+			close(finished)
+		}()
+		select {
+		case <-finished:
+		case <-time.After(notifyDeliveryTimeout):
+			log.Printf("gave up waiting for notifications to be posted")
+		}
+	})
+}
+
+// startPushNotifications posts the pushes serve's collectors find, as tags
+// that are new or point at another image since the last images they loaded.
+func startPushNotifications(ctx context.Context) {
+	notifier := newNotifier()
+	if notifier == nil || !slices.Contains(notifier.events, notifyPush) {
+		return
+	}
+	events, cancel := bus.subscribe(eventImages)
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
+	go func() {
+		for e := range events {
+			images, _ := e.Data.([]DockerImage)
+			if message := notifier.pushMessage(images); message != "" {
+				notifier.post(message)
+			}
+		}
+	}()
+}
+
+type notifier struct {
+	urls   []string
+	events []string
+	pushes tagWatcher
+}
+
+// tagWatcher finds the pushes in snapshots of the registry's images.
+type tagWatcher struct {
+	tags map[string]string // image reference → digest in the last snapshot, nil before the first
+}
+
+// pushed lists the images whose tag is new or points at another image since
+// the last snapshot. The first snapshot only tells what was there already.
+func (w *tagWatcher) pushed(images []DockerImage) []DockerImage {
+	if images == nil {
+		return nil
+	}
+	tags := make(map[string]string)
+	var pushed []DockerImage
+	for _, image := range images {
+		if image.Digest == "" || len(image.RepoTags) == 0 {
+			continue
+		}
+		reference := image.RepoTags[0]
+		tags[reference] = image.Digest
+		if w.tags != nil && w.tags[reference] != image.Digest {
+			pushed = append(pushed, image)
+		}
+	}
+	w.tags = tags
+	return pushed
+}
+
+// pushMessage lists the tags pushed since the last snapshot.
+func (n *notifier) pushMessage(images []DockerImage) string {
+	var pushed []string
+	for _, image := range n.pushes.pushed(images) {
+		pushed = append(pushed, image.RepoTags[0])
+	}
+	switch {
+	case len(pushed) == 0:
+		return ""
+	case len(pushed) > 10:
+		return fmt.Sprintf("📦 Pushed %s and %d more", strings.Join(pushed[:10], ", "), len(pushed)-10)
+	}
+	return "📦 Pushed " + strings.Join(pushed, ", ")
+}
+
+func (n *notifier) historyMessage(e busEvent) string {
+	action := e.Action
+	if action == nil {
+		return ""
+	}
+	user := action.User
+	if user == "" {
+		user = "someone"
+	}
+	if verb, ok := deployActions[action.Action]; ok && slices.Contains(n.events, notifyDeploy) {
+		switch action.Status {
+		case "succeeded":
+			message := fmt.Sprintf("🚀 %s %s %s", user, verb, action.Target)
+			if action.Details != "" {
+				message += " (" + action.Details + ")"
+			}
+			return message
+		case "failed":
+			return fmt.Sprintf("❌ %s's %s of %s failed: %s", user, strings.ReplaceAll(action.Action, "-", " "), action.Target, action.Details)
+		}
+	}
+	// Only a prune that deleted something has reclaimable space to report
+	if result, ok := e.Data.(pruneResult); ok && result.reclaimed > 0 && slices.Contains(n.events, notifyPrune) {
+		return fmt.Sprintf("🧹 %s pruned %s: %s", user, action.Target, action.Details)
+	}
+	return ""
+}
+
+// post sends a message to every webhook. Discord wants it as content,
+// Slack and the services copying its format as text.
+func (n *notifier) post(message string) {
+	for _, webhookURL := range n.urls {
+		payload := map[string]string{"text": message}
+		if strings.Contains(webhookURL, "discord.com/") || strings.Contains(webhookURL, "discordapp.com/") {
+			payload = map[string]string{"content": message}
+		}
+		if err := postWebhook(webhookURL, payload); err != nil {
+			log.Printf("failed to post notification to %s: %v", redactWebhookURL(webhookURL), err)
+		}
+	}
+}
+
+// notifyHTTPClient posts to the webhooks, through NOTIFY_PROXY.
+var notifyHTTPClient = &http.Client{Transport: tracingTransport{base: proxiedTransport(backendNotify)}}
+
+func postWebhook(webhookURL string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	// Not the root context: queued messages are posted during shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), notifyDeliveryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := notifyHTTPClient.Do(req)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		// Without the URL, which is the webhook's secret
+		return urlErr.Err
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+// redactWebhookURL keeps the secret path of a webhook URL out of the logs.
+func redactWebhookURL(webhookURL string) string {
+	scheme, rest, _ := strings.Cut(webhookURL, "://")
+	host, _, _ := strings.Cut(rest, "/")
+	return scheme + "://" + host + "/..."
+}
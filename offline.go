@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// When a backend can't be reached, e.g. on a train without wifi, the Git,
+// Docker and Kubernetes tabs show the rows they last loaded instead of an
+// error or an empty table. Every load that succeeds saves its rows in the
+// database's tab_snapshots table and a load that fails falls back to them.
+// The tab is then marked 📴 and its status line says when the rows are
+// from, until a load succeeds again. Without a database there is nothing
+// to fall back to.
+
+// staleData marks a tab showing its last-known-good rows.
+type staleData struct {
+	savedAt time.Time
+	err     error // why the tab couldn't be loaded
+}
+
+// snapshotName names the rows of a tab by where they come from, so another
+// registry or cluster doesn't stand in for the one that is down.
+func snapshotName(tab int) string {
+	switch tab {
+	case 0:
+		return "git:" + os.Getenv("GITHUB_OWNER") + "/" + os.Getenv("GITHUB_REPO")
+	case 1:
+		return "docker:" + getRegistryHost()
+	case 2:
+		return "kubernetes:" + currentCluster().displayName()
+	}
+	return ""
+}
+
+// saveLastKnownGood keeps rows as the tab's data to fall back to. Like
+// history, this is best effort.
+func saveLastKnownGood(ctx context.Context, tab int, rows []TableData) {
+	if dataStore(ctx) == nil || len(rows) == 0 {
+		return
+	}
+	content, err := json.Marshal(rows)
+	if err != nil {
+		return
+	}
+	if err := dataStore(ctx).SaveTabSnapshot(ctx, snapshotName(tab), content); err != nil {
+		log.Printf("failed to save the %s tab's data: %v", tabNames[tab], err)
+	}
+}
+
+// lastKnownGood is what the tab last loaded, to show since loading it
+// failed with err; nil when nothing was saved.
+func lastKnownGood(ctx context.Context, tab int, err error) ([]TableData, *staleData) {
+	if dataStore(ctx) == nil {
+		return nil, nil
+	}
+	snapshot, loadErr := dataStore(ctx).LoadTabSnapshot(ctx, snapshotName(tab))
+	if loadErr != nil {
+		return nil, nil
+	}
+	var rows []TableData
+	if json.Unmarshal(snapshot.Content, &rows) != nil || len(rows) == 0 {
+		return nil, nil
+	}
+	return rows, &staleData{savedAt: snapshot.SavedAt, err: err}
+}
+
+func (s staleData) String() string {
+	age := "just now"
+	if since := time.Since(s.savedAt).Truncate(time.Minute); since > 0 {
+		age = strings.TrimSuffix(since.String(), "0s") + " ago"
+	}
+	return fmt.Sprintf("📴 Offline, showing the data loaded %s (%s): %s",
+		s.savedAt.Format("2006-01-02 15:04"), age, truncateString(s.err.Error(), 80))
+}
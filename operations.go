@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var operationStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFA500"))
+
+// operation is a long-running command started from the TUI (pull, deploy,
+// ...). Each one runs under its own context so it can be cancelled without
+// touching the others.
+type operation struct {
+	id        int
+	label     string
+	started   time.Time
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// operationDoneMsg wraps the result of an operation so the model can drop it
+// from the in-flight list before handling the result itself.
+type operationDoneMsg struct {
+	id  int
+	msg tea.Msg
+}
+
+type operationTickMsg struct{}
+
+// startOperation registers an operation and returns the command running it.
+// The operation is the root span of its trace, started at the keypress, so
+// the backend calls it makes show up underneath it. Callers must assign the
+// returned command before returning m, since this mutates the model.
+func (m *model) startOperation(action, target string, run func(ctx context.Context) tea.Msg) tea.Cmd {
+	ctx, span := startSpan(m.ctx, "tui "+action, attribute.String("lcr.target", target))
+	ctx, cancel := context.WithCancel(ctx)
+	m.nextOperationID++
+	id := m.nextOperationID
+	m.operations = append(m.operations, operation{id: id, label: action + " " + target, started: time.Now(), cancel: cancel})
+
+	cmd := func() tea.Msg {
+		defer cancel()
+		msg := run(ctx)
+		if ctx.Err() != nil {
+			span.SetStatus(codes.Error, "cancelled")
+		}
+		span.End()
+		return operationDoneMsg{id: id, msg: msg}
+	}
+	// The elapsed times in the status bar need a redraw every second
+	if len(m.operations) == 1 {
+		return tea.Batch(cmd, operationTick())
+	}
+	return cmd
+}
+
+func operationTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return operationTickMsg{} })
+}
+
+func (m *model) finishOperation(id int) {
+	for i, op := range m.operations {
+		if op.id == id {
+			m.operations = append(m.operations[:i], m.operations[i+1:]...)
+			return
+		}
+	}
+}
+
+// cancelLatestOperation aborts the most recently started operation that
+// hasn't been cancelled yet. Its result still arrives, carrying the
+// context's cancellation error.
+func (m *model) cancelLatestOperation() {
+	for i := len(m.operations) - 1; i >= 0; i-- {
+		if !m.operations[i].cancelled {
+			m.operations[i].cancel()
+			m.operations[i].cancelled = true
+			return
+		}
+	}
+}
+
+// cancelOperation aborts the operation with the given ID, if it is still in
+// flight.
+func (m *model) cancelOperation(id int) {
+	for i := range m.operations {
+		if m.operations[i].id == id && !m.operations[i].cancelled {
+			m.operations[i].cancel()
+			m.operations[i].cancelled = true
+		}
+	}
+}
+
+func (m model) renderOperations() string {
+	if len(m.operations) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, op := range m.operations {
+		elapsed := time.Since(op.started).Truncate(time.Second)
+		if op.cancelled {
+			parts = append(parts, fmt.Sprintf("%s (cancelling)", op.label))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s (%s)", op.label, elapsed))
+		}
+	}
+	return operationStyle.Render("⏳ " + strings.Join(parts, " · ") + " — Ctrl+X to cancel the latest")
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// inContainer reports whether the tool runs in a Docker container, where the
+// compose services are reached by name and the host's ~/.kube and
+// ~/.minikube are mounted into the container's home.
+func inContainer() bool {
+	_, err := os.Stat("/.dockerenv")
+	return err == nil
+}
+
+// containerKubeconfig is where fixKubeconfigPaths writes the kubeconfig with
+// host paths mapped to the container's. It holds the embedded credentials,
+// so each process writes its own and removes it at shutdown; the daemon and
+// a TUI exec'd next to it don't share one.
+func containerKubeconfig() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("kubeconfig-%d", os.Getpid()))
+}
+
+var removeKubeconfigOnShutdown sync.Once
+
+// mountedDirs are the host directories compose mounts into the container's
+// home; kubeconfig paths inside them are mapped there by default.
+var mountedDirs = []string{".kube", ".minikube"}
+
+// kubeconfigPathMappings is KUBECONFIG_PATH_MAP, comma-separated
+// host-prefix=container-prefix entries for certificates kept elsewhere, e.g.
+// "C:\Users\me\certs=/certs".
+func kubeconfigPathMappings() [][2]string {
+	var mappings [][2]string
+	for _, entry := range strings.Split(os.Getenv("KUBECONFIG_PATH_MAP"), ",") {
+		if from, to, ok := strings.Cut(strings.TrimSpace(entry), "="); ok && from != "" {
+			mappings = append(mappings, [2]string{from, to})
+		}
+	}
+	return mappings
+}
+
+// mapHostPath rewrites a file path from the host's kubeconfig to where the
+// file is in the container. Host paths may be Windows or Unix paths whatever
+// the container runs, so both separators are accepted.
+func mapHostPath(path, home string, mappings [][2]string) string {
+	if path == "" {
+		return path
+	}
+	for _, mapping := range mappings {
+		if rest, ok := strings.CutPrefix(path, mapping[0]); ok {
+			return filepath.Join(mapping[1], filepath.FromSlash(strings.ReplaceAll(rest, `\`, "/")))
+		}
+	}
+	parts := strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' })
+	for i, part := range parts {
+		for _, dir := range mountedDirs {
+			if part == dir {
+				return filepath.Join(append([]string{home}, parts[i:]...)...)
+			}
+		}
+	}
+	return path
+}
+
+// fixKubeconfigPaths makes the mounted kubeconfig usable in the container:
+// its certificate and key paths point into the host's home, which is
+// mounted elsewhere. The paths are mapped, relative ones resolved against
+// the kubeconfig's directory, and the files embedded so the copy written to
+// the temp dir stands on its own; KUBECONFIG is pointed at it. Outside a
+// container it does nothing.
+func fixKubeconfigPaths() {
+	if !inContainer() || !kubernetesEnabled() {
+		return
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Printf("DEBUG: No home directory to find the kubeconfig in: %v", err)
+		return
+	}
+	kubeconfigPath := filepath.Join(home, ".kube", "config")
+	config, err := clientcmd.LoadFromFile(kubeconfigPath)
+	if err != nil {
+		log.Printf("DEBUG: Kubeconfig not usable at %s: %v", kubeconfigPath, err)
+		return
+	}
+
+	mappings := kubeconfigPathMappings()
+	for _, cluster := range config.Clusters {
+		cluster.CertificateAuthority = mapHostPath(cluster.CertificateAuthority, home, mappings)
+	}
+	for _, user := range config.AuthInfos {
+		user.ClientCertificate = mapHostPath(user.ClientCertificate, home, mappings)
+		user.ClientKey = mapHostPath(user.ClientKey, home, mappings)
+		user.TokenFile = mapHostPath(user.TokenFile, home, mappings)
+	}
+	if err := clientcmd.ResolveLocalPaths(config); err != nil {
+		log.Printf("DEBUG: Failed to resolve kubeconfig paths: %v", err)
+	}
+	for _, cluster := range config.Clusters {
+		embedKubeconfigFile(&cluster.CertificateAuthority, &cluster.CertificateAuthorityData)
+	}
+	for _, user := range config.AuthInfos {
+		embedKubeconfigFile(&user.ClientCertificate, &user.ClientCertificateData)
+		embedKubeconfigFile(&user.ClientKey, &user.ClientKeyData)
+	}
+
+	tempKubeconfig := containerKubeconfig()
+	if err := clientcmd.WriteToFile(*config, tempKubeconfig); err != nil {
+		log.Printf("DEBUG: Failed to write kubeconfig: %v", err)
+		return
+	}
+	removeKubeconfigOnShutdown.Do(func() {
+		onShutdown(func() { os.Remove(tempKubeconfig) })
+	})
+	os.Setenv("KUBECONFIG", tempKubeconfig)
+}
+
+// embedKubeconfigFile inlines a certificate or key file into the kubeconfig,
+// the way kubectl config view --flatten does. A file that can't be read
+// keeps its path, so client-go names it in the error.
+func embedKubeconfigFile(path *string, data *[]byte) {
+	if *path == "" || len(*data) > 0 {
+		return
+	}
+	content, err := os.ReadFile(*path)
+	if err != nil {
+		log.Printf("DEBUG: Kubeconfig file not mounted: %v", err)
+		return
+	}
+	*data, *path = content, ""
+}
@@ -0,0 +1,171 @@
+// Package backend is what the TUI needs of each backend, as interfaces: the
+// registry, the container runtime, the cluster, the Git provider and the
+// store. The clients in pkg/registry, pkg/docker, pkg/kube, pkg/gitprovider
+// and pkg/store implement them; the mocks in pkg/backend/mock stand in for
+// them in tests, and another implementation, say of ContainerRuntime over
+// containerd or podman, plugs in the same way.
+package backend
+
+//go:generate go run go.uber.org/mock/mockgen@v0.5.2 -destination=mock/mock.go -package=mock . RegistryAPI,ContainerRuntime,ClusterAPI,GitProvider,Store
+
+import (
+	"context"
+	"io"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/docker"
+	"github.com/anthony-gilbert/local-container-registry/pkg/gitprovider"
+	"github.com/anthony-gilbert/local-container-registry/pkg/kube"
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+	"github.com/anthony-gilbert/local-container-registry/pkg/store"
+)
+
+// RegistryAPI is one distribution registry, as *registry.Client talks to
+// it.
+type RegistryAPI interface {
+	Host() string
+	BaseURL() string
+	Ping(ctx context.Context) error
+	GetJSON(ctx context.Context, path string, v interface{}) error
+	PostJSON(ctx context.Context, path string, v interface{}) error
+
+	Catalog(ctx context.Context) ([]string, error)
+	Repositories(ctx context.Context) ([]string, error)
+	Tags(ctx context.Context, repository string) ([]string, error)
+
+	Manifest(ctx context.Context, repository, reference string) ([]byte, string, error)
+	ManifestDigest(ctx context.Context, repository, reference string) (string, error)
+	ManifestExists(ctx context.Context, repository, digest string) (bool, error)
+	PutManifest(ctx context.Context, repository, reference, mediaType string, body []byte) error
+	DeleteManifest(ctx context.Context, repository, digest string) error
+	Referrers(ctx context.Context, repository, digest string) ([]registry.Descriptor, error)
+	KnownDigests(repository string) []string
+
+	Blob(ctx context.Context, repository, digest string) ([]byte, error)
+	BlobExists(ctx context.Context, repository, digest string) (bool, error)
+	GetBlob(ctx context.Context, repository, digest string) (io.ReadCloser, int64, error)
+	GetBlobRange(ctx context.Context, repository, digest string, offset int64) (io.ReadCloser, error)
+	PutBlob(ctx context.Context, repository, digest string, content io.Reader, size int64) error
+	StartUpload(ctx context.Context, repository string) (string, error)
+	UploadOffset(ctx context.Context, location string) (int64, error)
+	PatchUpload(ctx context.Context, location string, chunk []byte, offset int64) (string, error)
+	CompleteUpload(ctx context.Context, location, digest string, content io.Reader, size int64) error
+
+	Flavor(ctx context.Context) string
+	Quirks(ctx context.Context) registry.Quirks
+	ProbeDelete(ctx context.Context, repository string) error
+	ScanSummary(ctx context.Context, repository, reference string) string
+	HarborProjects(ctx context.Context) ([]registry.HarborProject, error)
+	HarborProjectSummary(ctx context.Context, project string) (registry.HarborProjectSummary, error)
+	HarborRetention(ctx context.Context, id string) (registry.HarborRetention, error)
+	HarborScan(ctx context.Context, repository, reference string) error
+	HarborGarbageCollect(ctx context.Context) error
+}
+
+// ContainerRuntime is the local image store, as docker.Client drives it.
+type ContainerRuntime interface {
+	Images(ctx context.Context) ([]docker.Image, error)
+	Labels(ctx context.Context, ids ...string) ([]map[string]string, error)
+	Pull(ctx context.Context, ref string, progress io.Writer) error
+	PullProgress(ctx context.Context, ref string, auth docker.Auth, progress func(docker.Layer)) error
+	Tag(ctx context.Context, source, target string) error
+	Push(ctx context.Context, ref string) error
+	Remove(ctx context.Context, id string) error
+	Version(ctx context.Context) (string, error)
+}
+
+// ClusterAPI is the workloads of one cluster and the objects around them,
+// as *kube.Client reaches them.
+type ClusterAPI interface {
+	Pods(ctx context.Context, namespace string) ([]kube.Pod, error)
+	DeploymentPods(ctx context.Context, namespace, name string) ([]kube.Pod, error)
+	OwnedPods(ctx context.Context, deployment *appsv1.Deployment) ([]kube.Pod, error)
+	Logs(ctx context.Context, namespace, pod string, options kube.LogOptions) (string, error)
+	Namespaces(ctx context.Context) ([]string, error)
+	NamespaceExists(ctx context.Context, name string) (bool, error)
+	CreateNamespace(ctx context.Context, name string) error
+	Deployments(ctx context.Context, namespace string) ([]appsv1.Deployment, error)
+	SetImage(ctx context.Context, namespace, name, container, image string, pullPolicy corev1.PullPolicy, annotations map[string]string) (corev1.Container, error)
+	Restart(ctx context.Context, namespace, name string) error
+	Delete(ctx context.Context, namespace, name string) (*appsv1.Deployment, error)
+
+	Deployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error)
+	CreateDeployment(ctx context.Context, deployment *appsv1.Deployment) (*appsv1.Deployment, error)
+	UpdateDeployment(ctx context.Context, deployment *appsv1.Deployment) (*appsv1.Deployment, error)
+	DeleteDeployment(ctx context.Context, namespace, name string, propagation metav1.DeletionPropagation) error
+	ReplicaSets(ctx context.Context, namespace, selector string) ([]appsv1.ReplicaSet, error)
+
+	Pod(ctx context.Context, namespace, name string) (*corev1.Pod, error)
+	ListPods(ctx context.Context, namespace, selector string) ([]corev1.Pod, error)
+	CreatePod(ctx context.Context, pod *corev1.Pod) (*corev1.Pod, error)
+	DeletePod(ctx context.Context, namespace, name string) error
+	Events(ctx context.Context, namespace, fieldSelector string) ([]corev1.Event, error)
+
+	Secret(ctx context.Context, namespace, name string) (*corev1.Secret, error)
+	Secrets(ctx context.Context, namespace string) ([]corev1.Secret, error)
+	CreateSecret(ctx context.Context, secret *corev1.Secret) error
+	UpdateSecret(ctx context.Context, secret *corev1.Secret) error
+	ConfigMap(ctx context.Context, namespace, name string) (*corev1.ConfigMap, error)
+	ConfigMaps(ctx context.Context, namespace string) ([]corev1.ConfigMap, error)
+
+	CreateService(ctx context.Context, service *corev1.Service) (*corev1.Service, error)
+	DeleteService(ctx context.Context, namespace, name string) error
+	CreateIngress(ctx context.Context, ingress *networkingv1.Ingress) error
+	DeleteIngress(ctx context.Context, namespace, name string) error
+
+	Nodes(ctx context.Context) ([]corev1.Node, error)
+	ServerVersion(ctx context.Context) (string, error)
+	NodeMetrics(ctx context.Context) (map[string]corev1.ResourceList, error)
+	ReviewAccess(ctx context.Context, attributes authorizationv1.ResourceAttributes) (authorizationv1.SubjectAccessReviewStatus, error)
+}
+
+// GitProvider is gitprovider.Provider, here so every backend's interface
+// and mock are in one place.
+type GitProvider interface {
+	gitprovider.Provider
+}
+
+// Store is the commits, history, image inventory, deploy profiles and rules
+// and tab snapshots, as *store.Store keeps them in MySQL.
+type Store interface {
+	EnsureSchema(ctx context.Context) error
+
+	RecordCommit(ctx context.Context, commit store.Commit) error
+	SetPullRequestDescription(ctx context.Context, sha, description string) error
+
+	RecordHistory(ctx context.Context, entry store.HistoryEntry) error
+	History(ctx context.Context, limit int) ([]store.HistoryEntry, error)
+	LatestHistory(ctx context.Context, action string) ([]store.HistoryEntry, error)
+	HistoryBetween(ctx context.Context, since, until time.Time) ([]store.HistoryEntry, error)
+	HistoryAfter(ctx context.Context, afterID int64, limit int) ([]store.HistoryEntry, error)
+
+	RecordImages(ctx context.Context, records []store.ImageRecord) error
+	SizeHistory(ctx context.Context, registry, repository string, limit int) ([]store.SizePoint, error)
+
+	SaveDeployProfile(ctx context.Context, image string, content []byte) error
+	LoadDeployProfile(ctx context.Context, image string) (store.DeployProfile, error)
+
+	AddDeployRule(ctx context.Context, rule store.DeployRule) (int64, error)
+	DeployRules(ctx context.Context) ([]store.DeployRule, error)
+	SetDeployRuleEnabled(ctx context.Context, id int64, enabled bool) error
+	DeleteDeployRule(ctx context.Context, id int64) error
+	RecordDeployRuleRun(ctx context.Context, id int64, image, status string) error
+
+	SaveTabSnapshot(ctx context.Context, name string, content []byte) error
+	LoadTabSnapshot(ctx context.Context, name string) (store.TabSnapshot, error)
+}
+
+// The clients implement the interfaces.
+var (
+	_ RegistryAPI      = (*registry.Client)(nil)
+	_ ContainerRuntime = docker.Client{}
+	_ ClusterAPI       = (*kube.Client)(nil)
+	_ GitProvider      = (*gitprovider.GitHub)(nil)
+	_ Store            = (*store.Store)(nil)
+)
@@ -0,0 +1,1571 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/anthony-gilbert/local-container-registry/pkg/backend (interfaces: RegistryAPI,ContainerRuntime,ClusterAPI,GitProvider,Store)
+//
+// Generated by this command:
+//
+//	mockgen -destination=mock/mock.go -package=mock . RegistryAPI,ContainerRuntime,ClusterAPI,GitProvider,Store
+//
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	io "io"
+	reflect "reflect"
+	time "time"
+
+	docker "github.com/anthony-gilbert/local-container-registry/pkg/docker"
+	gitprovider "github.com/anthony-gilbert/local-container-registry/pkg/gitprovider"
+	kube "github.com/anthony-gilbert/local-container-registry/pkg/kube"
+	registry "github.com/anthony-gilbert/local-container-registry/pkg/registry"
+	store "github.com/anthony-gilbert/local-container-registry/pkg/store"
+	gomock "go.uber.org/mock/gomock"
+	v1 "k8s.io/api/apps/v1"
+	v10 "k8s.io/api/authorization/v1"
+	v11 "k8s.io/api/core/v1"
+	v12 "k8s.io/api/networking/v1"
+	v13 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MockRegistryAPI is a mock of RegistryAPI interface.
+type MockRegistryAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockRegistryAPIMockRecorder
+	isgomock struct{}
+}
+
+// MockRegistryAPIMockRecorder is the mock recorder for MockRegistryAPI.
+type MockRegistryAPIMockRecorder struct {
+	mock *MockRegistryAPI
+}
+
+// NewMockRegistryAPI creates a new mock instance.
+func NewMockRegistryAPI(ctrl *gomock.Controller) *MockRegistryAPI {
+	mock := &MockRegistryAPI{ctrl: ctrl}
+	mock.recorder = &MockRegistryAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRegistryAPI) EXPECT() *MockRegistryAPIMockRecorder {
+	return m.recorder
+}
+
+// BaseURL mocks base method.
+func (m *MockRegistryAPI) BaseURL() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BaseURL")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// BaseURL indicates an expected call of BaseURL.
+func (mr *MockRegistryAPIMockRecorder) BaseURL() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BaseURL", reflect.TypeOf((*MockRegistryAPI)(nil).BaseURL))
+}
+
+// Blob mocks base method.
+func (m *MockRegistryAPI) Blob(ctx context.Context, repository, digest string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Blob", ctx, repository, digest)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Blob indicates an expected call of Blob.
+func (mr *MockRegistryAPIMockRecorder) Blob(ctx, repository, digest any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Blob", reflect.TypeOf((*MockRegistryAPI)(nil).Blob), ctx, repository, digest)
+}
+
+// BlobExists mocks base method.
+func (m *MockRegistryAPI) BlobExists(ctx context.Context, repository, digest string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlobExists", ctx, repository, digest)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BlobExists indicates an expected call of BlobExists.
+func (mr *MockRegistryAPIMockRecorder) BlobExists(ctx, repository, digest any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlobExists", reflect.TypeOf((*MockRegistryAPI)(nil).BlobExists), ctx, repository, digest)
+}
+
+// Catalog mocks base method.
+func (m *MockRegistryAPI) Catalog(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Catalog", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Catalog indicates an expected call of Catalog.
+func (mr *MockRegistryAPIMockRecorder) Catalog(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Catalog", reflect.TypeOf((*MockRegistryAPI)(nil).Catalog), ctx)
+}
+
+// CompleteUpload mocks base method.
+func (m *MockRegistryAPI) CompleteUpload(ctx context.Context, location, digest string, content io.Reader, size int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteUpload", ctx, location, digest, content, size)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteUpload indicates an expected call of CompleteUpload.
+func (mr *MockRegistryAPIMockRecorder) CompleteUpload(ctx, location, digest, content, size any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteUpload", reflect.TypeOf((*MockRegistryAPI)(nil).CompleteUpload), ctx, location, digest, content, size)
+}
+
+// DeleteManifest mocks base method.
+func (m *MockRegistryAPI) DeleteManifest(ctx context.Context, repository, digest string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteManifest", ctx, repository, digest)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteManifest indicates an expected call of DeleteManifest.
+func (mr *MockRegistryAPIMockRecorder) DeleteManifest(ctx, repository, digest any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteManifest", reflect.TypeOf((*MockRegistryAPI)(nil).DeleteManifest), ctx, repository, digest)
+}
+
+// Flavor mocks base method.
+func (m *MockRegistryAPI) Flavor(ctx context.Context) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Flavor", ctx)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Flavor indicates an expected call of Flavor.
+func (mr *MockRegistryAPIMockRecorder) Flavor(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Flavor", reflect.TypeOf((*MockRegistryAPI)(nil).Flavor), ctx)
+}
+
+// GetBlob mocks base method.
+func (m *MockRegistryAPI) GetBlob(ctx context.Context, repository, digest string) (io.ReadCloser, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBlob", ctx, repository, digest)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetBlob indicates an expected call of GetBlob.
+func (mr *MockRegistryAPIMockRecorder) GetBlob(ctx, repository, digest any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlob", reflect.TypeOf((*MockRegistryAPI)(nil).GetBlob), ctx, repository, digest)
+}
+
+// GetBlobRange mocks base method.
+func (m *MockRegistryAPI) GetBlobRange(ctx context.Context, repository, digest string, offset int64) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBlobRange", ctx, repository, digest, offset)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBlobRange indicates an expected call of GetBlobRange.
+func (mr *MockRegistryAPIMockRecorder) GetBlobRange(ctx, repository, digest, offset any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlobRange", reflect.TypeOf((*MockRegistryAPI)(nil).GetBlobRange), ctx, repository, digest, offset)
+}
+
+// GetJSON mocks base method.
+func (m *MockRegistryAPI) GetJSON(ctx context.Context, path string, v any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJSON", ctx, path, v)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetJSON indicates an expected call of GetJSON.
+func (mr *MockRegistryAPIMockRecorder) GetJSON(ctx, path, v any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJSON", reflect.TypeOf((*MockRegistryAPI)(nil).GetJSON), ctx, path, v)
+}
+
+// HarborGarbageCollect mocks base method.
+func (m *MockRegistryAPI) HarborGarbageCollect(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HarborGarbageCollect", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HarborGarbageCollect indicates an expected call of HarborGarbageCollect.
+func (mr *MockRegistryAPIMockRecorder) HarborGarbageCollect(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HarborGarbageCollect", reflect.TypeOf((*MockRegistryAPI)(nil).HarborGarbageCollect), ctx)
+}
+
+// HarborProjectSummary mocks base method.
+func (m *MockRegistryAPI) HarborProjectSummary(ctx context.Context, project string) (registry.HarborProjectSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HarborProjectSummary", ctx, project)
+	ret0, _ := ret[0].(registry.HarborProjectSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HarborProjectSummary indicates an expected call of HarborProjectSummary.
+func (mr *MockRegistryAPIMockRecorder) HarborProjectSummary(ctx, project any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HarborProjectSummary", reflect.TypeOf((*MockRegistryAPI)(nil).HarborProjectSummary), ctx, project)
+}
+
+// HarborProjects mocks base method.
+func (m *MockRegistryAPI) HarborProjects(ctx context.Context) ([]registry.HarborProject, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HarborProjects", ctx)
+	ret0, _ := ret[0].([]registry.HarborProject)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HarborProjects indicates an expected call of HarborProjects.
+func (mr *MockRegistryAPIMockRecorder) HarborProjects(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HarborProjects", reflect.TypeOf((*MockRegistryAPI)(nil).HarborProjects), ctx)
+}
+
+// HarborRetention mocks base method.
+func (m *MockRegistryAPI) HarborRetention(ctx context.Context, id string) (registry.HarborRetention, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HarborRetention", ctx, id)
+	ret0, _ := ret[0].(registry.HarborRetention)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HarborRetention indicates an expected call of HarborRetention.
+func (mr *MockRegistryAPIMockRecorder) HarborRetention(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HarborRetention", reflect.TypeOf((*MockRegistryAPI)(nil).HarborRetention), ctx, id)
+}
+
+// HarborScan mocks base method.
+func (m *MockRegistryAPI) HarborScan(ctx context.Context, repository, reference string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HarborScan", ctx, repository, reference)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HarborScan indicates an expected call of HarborScan.
+func (mr *MockRegistryAPIMockRecorder) HarborScan(ctx, repository, reference any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HarborScan", reflect.TypeOf((*MockRegistryAPI)(nil).HarborScan), ctx, repository, reference)
+}
+
+// Host mocks base method.
+func (m *MockRegistryAPI) Host() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Host")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Host indicates an expected call of Host.
+func (mr *MockRegistryAPIMockRecorder) Host() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Host", reflect.TypeOf((*MockRegistryAPI)(nil).Host))
+}
+
+// KnownDigests mocks base method.
+func (m *MockRegistryAPI) KnownDigests(repository string) []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "KnownDigests", repository)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// KnownDigests indicates an expected call of KnownDigests.
+func (mr *MockRegistryAPIMockRecorder) KnownDigests(repository any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KnownDigests", reflect.TypeOf((*MockRegistryAPI)(nil).KnownDigests), repository)
+}
+
+// Manifest mocks base method.
+func (m *MockRegistryAPI) Manifest(ctx context.Context, repository, reference string) ([]byte, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Manifest", ctx, repository, reference)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Manifest indicates an expected call of Manifest.
+func (mr *MockRegistryAPIMockRecorder) Manifest(ctx, repository, reference any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Manifest", reflect.TypeOf((*MockRegistryAPI)(nil).Manifest), ctx, repository, reference)
+}
+
+// ManifestDigest mocks base method.
+func (m *MockRegistryAPI) ManifestDigest(ctx context.Context, repository, reference string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ManifestDigest", ctx, repository, reference)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ManifestDigest indicates an expected call of ManifestDigest.
+func (mr *MockRegistryAPIMockRecorder) ManifestDigest(ctx, repository, reference any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ManifestDigest", reflect.TypeOf((*MockRegistryAPI)(nil).ManifestDigest), ctx, repository, reference)
+}
+
+// ManifestExists mocks base method.
+func (m *MockRegistryAPI) ManifestExists(ctx context.Context, repository, digest string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ManifestExists", ctx, repository, digest)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ManifestExists indicates an expected call of ManifestExists.
+func (mr *MockRegistryAPIMockRecorder) ManifestExists(ctx, repository, digest any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ManifestExists", reflect.TypeOf((*MockRegistryAPI)(nil).ManifestExists), ctx, repository, digest)
+}
+
+// PatchUpload mocks base method.
+func (m *MockRegistryAPI) PatchUpload(ctx context.Context, location string, chunk []byte, offset int64) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchUpload", ctx, location, chunk, offset)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PatchUpload indicates an expected call of PatchUpload.
+func (mr *MockRegistryAPIMockRecorder) PatchUpload(ctx, location, chunk, offset any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchUpload", reflect.TypeOf((*MockRegistryAPI)(nil).PatchUpload), ctx, location, chunk, offset)
+}
+
+// Ping mocks base method.
+func (m *MockRegistryAPI) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockRegistryAPIMockRecorder) Ping(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockRegistryAPI)(nil).Ping), ctx)
+}
+
+// PostJSON mocks base method.
+func (m *MockRegistryAPI) PostJSON(ctx context.Context, path string, v any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PostJSON", ctx, path, v)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PostJSON indicates an expected call of PostJSON.
+func (mr *MockRegistryAPIMockRecorder) PostJSON(ctx, path, v any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostJSON", reflect.TypeOf((*MockRegistryAPI)(nil).PostJSON), ctx, path, v)
+}
+
+// ProbeDelete mocks base method.
+func (m *MockRegistryAPI) ProbeDelete(ctx context.Context, repository string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProbeDelete", ctx, repository)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ProbeDelete indicates an expected call of ProbeDelete.
+func (mr *MockRegistryAPIMockRecorder) ProbeDelete(ctx, repository any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProbeDelete", reflect.TypeOf((*MockRegistryAPI)(nil).ProbeDelete), ctx, repository)
+}
+
+// PutBlob mocks base method.
+func (m *MockRegistryAPI) PutBlob(ctx context.Context, repository, digest string, content io.Reader, size int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutBlob", ctx, repository, digest, content, size)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutBlob indicates an expected call of PutBlob.
+func (mr *MockRegistryAPIMockRecorder) PutBlob(ctx, repository, digest, content, size any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutBlob", reflect.TypeOf((*MockRegistryAPI)(nil).PutBlob), ctx, repository, digest, content, size)
+}
+
+// PutManifest mocks base method.
+func (m *MockRegistryAPI) PutManifest(ctx context.Context, repository, reference, mediaType string, body []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutManifest", ctx, repository, reference, mediaType, body)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutManifest indicates an expected call of PutManifest.
+func (mr *MockRegistryAPIMockRecorder) PutManifest(ctx, repository, reference, mediaType, body any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutManifest", reflect.TypeOf((*MockRegistryAPI)(nil).PutManifest), ctx, repository, reference, mediaType, body)
+}
+
+// Quirks mocks base method.
+func (m *MockRegistryAPI) Quirks(ctx context.Context) registry.Quirks {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Quirks", ctx)
+	ret0, _ := ret[0].(registry.Quirks)
+	return ret0
+}
+
+// Quirks indicates an expected call of Quirks.
+func (mr *MockRegistryAPIMockRecorder) Quirks(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Quirks", reflect.TypeOf((*MockRegistryAPI)(nil).Quirks), ctx)
+}
+
+// Referrers mocks base method.
+func (m *MockRegistryAPI) Referrers(ctx context.Context, repository, digest string) ([]registry.Descriptor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Referrers", ctx, repository, digest)
+	ret0, _ := ret[0].([]registry.Descriptor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Referrers indicates an expected call of Referrers.
+func (mr *MockRegistryAPIMockRecorder) Referrers(ctx, repository, digest any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Referrers", reflect.TypeOf((*MockRegistryAPI)(nil).Referrers), ctx, repository, digest)
+}
+
+// Repositories mocks base method.
+func (m *MockRegistryAPI) Repositories(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Repositories", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Repositories indicates an expected call of Repositories.
+func (mr *MockRegistryAPIMockRecorder) Repositories(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Repositories", reflect.TypeOf((*MockRegistryAPI)(nil).Repositories), ctx)
+}
+
+// ScanSummary mocks base method.
+func (m *MockRegistryAPI) ScanSummary(ctx context.Context, repository, reference string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScanSummary", ctx, repository, reference)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// ScanSummary indicates an expected call of ScanSummary.
+func (mr *MockRegistryAPIMockRecorder) ScanSummary(ctx, repository, reference any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScanSummary", reflect.TypeOf((*MockRegistryAPI)(nil).ScanSummary), ctx, repository, reference)
+}
+
+// StartUpload mocks base method.
+func (m *MockRegistryAPI) StartUpload(ctx context.Context, repository string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartUpload", ctx, repository)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StartUpload indicates an expected call of StartUpload.
+func (mr *MockRegistryAPIMockRecorder) StartUpload(ctx, repository any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartUpload", reflect.TypeOf((*MockRegistryAPI)(nil).StartUpload), ctx, repository)
+}
+
+// Tags mocks base method.
+func (m *MockRegistryAPI) Tags(ctx context.Context, repository string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Tags", ctx, repository)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Tags indicates an expected call of Tags.
+func (mr *MockRegistryAPIMockRecorder) Tags(ctx, repository any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Tags", reflect.TypeOf((*MockRegistryAPI)(nil).Tags), ctx, repository)
+}
+
+// UploadOffset mocks base method.
+func (m *MockRegistryAPI) UploadOffset(ctx context.Context, location string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadOffset", ctx, location)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UploadOffset indicates an expected call of UploadOffset.
+func (mr *MockRegistryAPIMockRecorder) UploadOffset(ctx, location any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadOffset", reflect.TypeOf((*MockRegistryAPI)(nil).UploadOffset), ctx, location)
+}
+
+// MockContainerRuntime is a mock of ContainerRuntime interface.
+type MockContainerRuntime struct {
+	ctrl     *gomock.Controller
+	recorder *MockContainerRuntimeMockRecorder
+	isgomock struct{}
+}
+
+// MockContainerRuntimeMockRecorder is the mock recorder for MockContainerRuntime.
+type MockContainerRuntimeMockRecorder struct {
+	mock *MockContainerRuntime
+}
+
+// NewMockContainerRuntime creates a new mock instance.
+func NewMockContainerRuntime(ctrl *gomock.Controller) *MockContainerRuntime {
+	mock := &MockContainerRuntime{ctrl: ctrl}
+	mock.recorder = &MockContainerRuntimeMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockContainerRuntime) EXPECT() *MockContainerRuntimeMockRecorder {
+	return m.recorder
+}
+
+// Images mocks base method.
+func (m *MockContainerRuntime) Images(ctx context.Context) ([]docker.Image, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Images", ctx)
+	ret0, _ := ret[0].([]docker.Image)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Images indicates an expected call of Images.
+func (mr *MockContainerRuntimeMockRecorder) Images(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Images", reflect.TypeOf((*MockContainerRuntime)(nil).Images), ctx)
+}
+
+// Labels mocks base method.
+func (m *MockContainerRuntime) Labels(ctx context.Context, ids ...string) ([]map[string]string, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx}
+	for _, a := range ids {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Labels", varargs...)
+	ret0, _ := ret[0].([]map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Labels indicates an expected call of Labels.
+func (mr *MockContainerRuntimeMockRecorder) Labels(ctx any, ids ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx}, ids...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Labels", reflect.TypeOf((*MockContainerRuntime)(nil).Labels), varargs...)
+}
+
+// Pull mocks base method.
+func (m *MockContainerRuntime) Pull(ctx context.Context, ref string, progress io.Writer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pull", ctx, ref, progress)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Pull indicates an expected call of Pull.
+func (mr *MockContainerRuntimeMockRecorder) Pull(ctx, ref, progress any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pull", reflect.TypeOf((*MockContainerRuntime)(nil).Pull), ctx, ref, progress)
+}
+
+// PullProgress mocks base method.
+func (m *MockContainerRuntime) PullProgress(ctx context.Context, ref string, auth docker.Auth, progress func(docker.Layer)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PullProgress", ctx, ref, auth, progress)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PullProgress indicates an expected call of PullProgress.
+func (mr *MockContainerRuntimeMockRecorder) PullProgress(ctx, ref, auth, progress any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PullProgress", reflect.TypeOf((*MockContainerRuntime)(nil).PullProgress), ctx, ref, auth, progress)
+}
+
+// Push mocks base method.
+func (m *MockContainerRuntime) Push(ctx context.Context, ref string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Push", ctx, ref)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Push indicates an expected call of Push.
+func (mr *MockContainerRuntimeMockRecorder) Push(ctx, ref any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Push", reflect.TypeOf((*MockContainerRuntime)(nil).Push), ctx, ref)
+}
+
+// Remove mocks base method.
+func (m *MockContainerRuntime) Remove(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Remove", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Remove indicates an expected call of Remove.
+func (mr *MockContainerRuntimeMockRecorder) Remove(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockContainerRuntime)(nil).Remove), ctx, id)
+}
+
+// Tag mocks base method.
+func (m *MockContainerRuntime) Tag(ctx context.Context, source, target string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Tag", ctx, source, target)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Tag indicates an expected call of Tag.
+func (mr *MockContainerRuntimeMockRecorder) Tag(ctx, source, target any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Tag", reflect.TypeOf((*MockContainerRuntime)(nil).Tag), ctx, source, target)
+}
+
+// Version mocks base method.
+func (m *MockContainerRuntime) Version(ctx context.Context) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Version", ctx)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Version indicates an expected call of Version.
+func (mr *MockContainerRuntimeMockRecorder) Version(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Version", reflect.TypeOf((*MockContainerRuntime)(nil).Version), ctx)
+}
+
+// MockClusterAPI is a mock of ClusterAPI interface.
+type MockClusterAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockClusterAPIMockRecorder
+	isgomock struct{}
+}
+
+// MockClusterAPIMockRecorder is the mock recorder for MockClusterAPI.
+type MockClusterAPIMockRecorder struct {
+	mock *MockClusterAPI
+}
+
+// NewMockClusterAPI creates a new mock instance.
+func NewMockClusterAPI(ctrl *gomock.Controller) *MockClusterAPI {
+	mock := &MockClusterAPI{ctrl: ctrl}
+	mock.recorder = &MockClusterAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClusterAPI) EXPECT() *MockClusterAPIMockRecorder {
+	return m.recorder
+}
+
+// ConfigMap mocks base method.
+func (m *MockClusterAPI) ConfigMap(ctx context.Context, namespace, name string) (*v11.ConfigMap, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfigMap", ctx, namespace, name)
+	ret0, _ := ret[0].(*v11.ConfigMap)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfigMap indicates an expected call of ConfigMap.
+func (mr *MockClusterAPIMockRecorder) ConfigMap(ctx, namespace, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfigMap", reflect.TypeOf((*MockClusterAPI)(nil).ConfigMap), ctx, namespace, name)
+}
+
+// ConfigMaps mocks base method.
+func (m *MockClusterAPI) ConfigMaps(ctx context.Context, namespace string) ([]v11.ConfigMap, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfigMaps", ctx, namespace)
+	ret0, _ := ret[0].([]v11.ConfigMap)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfigMaps indicates an expected call of ConfigMaps.
+func (mr *MockClusterAPIMockRecorder) ConfigMaps(ctx, namespace any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfigMaps", reflect.TypeOf((*MockClusterAPI)(nil).ConfigMaps), ctx, namespace)
+}
+
+// CreateDeployment mocks base method.
+func (m *MockClusterAPI) CreateDeployment(ctx context.Context, deployment *v1.Deployment) (*v1.Deployment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDeployment", ctx, deployment)
+	ret0, _ := ret[0].(*v1.Deployment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDeployment indicates an expected call of CreateDeployment.
+func (mr *MockClusterAPIMockRecorder) CreateDeployment(ctx, deployment any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDeployment", reflect.TypeOf((*MockClusterAPI)(nil).CreateDeployment), ctx, deployment)
+}
+
+// CreateIngress mocks base method.
+func (m *MockClusterAPI) CreateIngress(ctx context.Context, ingress *v12.Ingress) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIngress", ctx, ingress)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateIngress indicates an expected call of CreateIngress.
+func (mr *MockClusterAPIMockRecorder) CreateIngress(ctx, ingress any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIngress", reflect.TypeOf((*MockClusterAPI)(nil).CreateIngress), ctx, ingress)
+}
+
+// CreateNamespace mocks base method.
+func (m *MockClusterAPI) CreateNamespace(ctx context.Context, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateNamespace", ctx, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateNamespace indicates an expected call of CreateNamespace.
+func (mr *MockClusterAPIMockRecorder) CreateNamespace(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNamespace", reflect.TypeOf((*MockClusterAPI)(nil).CreateNamespace), ctx, name)
+}
+
+// CreatePod mocks base method.
+func (m *MockClusterAPI) CreatePod(ctx context.Context, pod *v11.Pod) (*v11.Pod, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePod", ctx, pod)
+	ret0, _ := ret[0].(*v11.Pod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePod indicates an expected call of CreatePod.
+func (mr *MockClusterAPIMockRecorder) CreatePod(ctx, pod any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePod", reflect.TypeOf((*MockClusterAPI)(nil).CreatePod), ctx, pod)
+}
+
+// CreateSecret mocks base method.
+func (m *MockClusterAPI) CreateSecret(ctx context.Context, secret *v11.Secret) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSecret", ctx, secret)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSecret indicates an expected call of CreateSecret.
+func (mr *MockClusterAPIMockRecorder) CreateSecret(ctx, secret any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSecret", reflect.TypeOf((*MockClusterAPI)(nil).CreateSecret), ctx, secret)
+}
+
+// CreateService mocks base method.
+func (m *MockClusterAPI) CreateService(ctx context.Context, service *v11.Service) (*v11.Service, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateService", ctx, service)
+	ret0, _ := ret[0].(*v11.Service)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateService indicates an expected call of CreateService.
+func (mr *MockClusterAPIMockRecorder) CreateService(ctx, service any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateService", reflect.TypeOf((*MockClusterAPI)(nil).CreateService), ctx, service)
+}
+
+// Delete mocks base method.
+func (m *MockClusterAPI) Delete(ctx context.Context, namespace, name string) (*v1.Deployment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, namespace, name)
+	ret0, _ := ret[0].(*v1.Deployment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockClusterAPIMockRecorder) Delete(ctx, namespace, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockClusterAPI)(nil).Delete), ctx, namespace, name)
+}
+
+// DeleteDeployment mocks base method.
+func (m *MockClusterAPI) DeleteDeployment(ctx context.Context, namespace, name string, propagation v13.DeletionPropagation) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDeployment", ctx, namespace, name, propagation)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDeployment indicates an expected call of DeleteDeployment.
+func (mr *MockClusterAPIMockRecorder) DeleteDeployment(ctx, namespace, name, propagation any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDeployment", reflect.TypeOf((*MockClusterAPI)(nil).DeleteDeployment), ctx, namespace, name, propagation)
+}
+
+// DeleteIngress mocks base method.
+func (m *MockClusterAPI) DeleteIngress(ctx context.Context, namespace, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIngress", ctx, namespace, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIngress indicates an expected call of DeleteIngress.
+func (mr *MockClusterAPIMockRecorder) DeleteIngress(ctx, namespace, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIngress", reflect.TypeOf((*MockClusterAPI)(nil).DeleteIngress), ctx, namespace, name)
+}
+
+// DeletePod mocks base method.
+func (m *MockClusterAPI) DeletePod(ctx context.Context, namespace, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePod", ctx, namespace, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeletePod indicates an expected call of DeletePod.
+func (mr *MockClusterAPIMockRecorder) DeletePod(ctx, namespace, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePod", reflect.TypeOf((*MockClusterAPI)(nil).DeletePod), ctx, namespace, name)
+}
+
+// DeleteService mocks base method.
+func (m *MockClusterAPI) DeleteService(ctx context.Context, namespace, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteService", ctx, namespace, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteService indicates an expected call of DeleteService.
+func (mr *MockClusterAPIMockRecorder) DeleteService(ctx, namespace, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteService", reflect.TypeOf((*MockClusterAPI)(nil).DeleteService), ctx, namespace, name)
+}
+
+// Deployment mocks base method.
+func (m *MockClusterAPI) Deployment(ctx context.Context, namespace, name string) (*v1.Deployment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deployment", ctx, namespace, name)
+	ret0, _ := ret[0].(*v1.Deployment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Deployment indicates an expected call of Deployment.
+func (mr *MockClusterAPIMockRecorder) Deployment(ctx, namespace, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deployment", reflect.TypeOf((*MockClusterAPI)(nil).Deployment), ctx, namespace, name)
+}
+
+// DeploymentPods mocks base method.
+func (m *MockClusterAPI) DeploymentPods(ctx context.Context, namespace, name string) ([]kube.Pod, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeploymentPods", ctx, namespace, name)
+	ret0, _ := ret[0].([]kube.Pod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeploymentPods indicates an expected call of DeploymentPods.
+func (mr *MockClusterAPIMockRecorder) DeploymentPods(ctx, namespace, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeploymentPods", reflect.TypeOf((*MockClusterAPI)(nil).DeploymentPods), ctx, namespace, name)
+}
+
+// Deployments mocks base method.
+func (m *MockClusterAPI) Deployments(ctx context.Context, namespace string) ([]v1.Deployment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deployments", ctx, namespace)
+	ret0, _ := ret[0].([]v1.Deployment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Deployments indicates an expected call of Deployments.
+func (mr *MockClusterAPIMockRecorder) Deployments(ctx, namespace any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deployments", reflect.TypeOf((*MockClusterAPI)(nil).Deployments), ctx, namespace)
+}
+
+// Events mocks base method.
+func (m *MockClusterAPI) Events(ctx context.Context, namespace, fieldSelector string) ([]v11.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Events", ctx, namespace, fieldSelector)
+	ret0, _ := ret[0].([]v11.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Events indicates an expected call of Events.
+func (mr *MockClusterAPIMockRecorder) Events(ctx, namespace, fieldSelector any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Events", reflect.TypeOf((*MockClusterAPI)(nil).Events), ctx, namespace, fieldSelector)
+}
+
+// ListPods mocks base method.
+func (m *MockClusterAPI) ListPods(ctx context.Context, namespace, selector string) ([]v11.Pod, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPods", ctx, namespace, selector)
+	ret0, _ := ret[0].([]v11.Pod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPods indicates an expected call of ListPods.
+func (mr *MockClusterAPIMockRecorder) ListPods(ctx, namespace, selector any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPods", reflect.TypeOf((*MockClusterAPI)(nil).ListPods), ctx, namespace, selector)
+}
+
+// Logs mocks base method.
+func (m *MockClusterAPI) Logs(ctx context.Context, namespace, pod string, options kube.LogOptions) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logs", ctx, namespace, pod, options)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Logs indicates an expected call of Logs.
+func (mr *MockClusterAPIMockRecorder) Logs(ctx, namespace, pod, options any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logs", reflect.TypeOf((*MockClusterAPI)(nil).Logs), ctx, namespace, pod, options)
+}
+
+// NamespaceExists mocks base method.
+func (m *MockClusterAPI) NamespaceExists(ctx context.Context, name string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NamespaceExists", ctx, name)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NamespaceExists indicates an expected call of NamespaceExists.
+func (mr *MockClusterAPIMockRecorder) NamespaceExists(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NamespaceExists", reflect.TypeOf((*MockClusterAPI)(nil).NamespaceExists), ctx, name)
+}
+
+// Namespaces mocks base method.
+func (m *MockClusterAPI) Namespaces(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Namespaces", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Namespaces indicates an expected call of Namespaces.
+func (mr *MockClusterAPIMockRecorder) Namespaces(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Namespaces", reflect.TypeOf((*MockClusterAPI)(nil).Namespaces), ctx)
+}
+
+// NodeMetrics mocks base method.
+func (m *MockClusterAPI) NodeMetrics(ctx context.Context) (map[string]v11.ResourceList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NodeMetrics", ctx)
+	ret0, _ := ret[0].(map[string]v11.ResourceList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NodeMetrics indicates an expected call of NodeMetrics.
+func (mr *MockClusterAPIMockRecorder) NodeMetrics(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NodeMetrics", reflect.TypeOf((*MockClusterAPI)(nil).NodeMetrics), ctx)
+}
+
+// Nodes mocks base method.
+func (m *MockClusterAPI) Nodes(ctx context.Context) ([]v11.Node, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Nodes", ctx)
+	ret0, _ := ret[0].([]v11.Node)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Nodes indicates an expected call of Nodes.
+func (mr *MockClusterAPIMockRecorder) Nodes(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Nodes", reflect.TypeOf((*MockClusterAPI)(nil).Nodes), ctx)
+}
+
+// OwnedPods mocks base method.
+func (m *MockClusterAPI) OwnedPods(ctx context.Context, deployment *v1.Deployment) ([]kube.Pod, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OwnedPods", ctx, deployment)
+	ret0, _ := ret[0].([]kube.Pod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OwnedPods indicates an expected call of OwnedPods.
+func (mr *MockClusterAPIMockRecorder) OwnedPods(ctx, deployment any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OwnedPods", reflect.TypeOf((*MockClusterAPI)(nil).OwnedPods), ctx, deployment)
+}
+
+// Pod mocks base method.
+func (m *MockClusterAPI) Pod(ctx context.Context, namespace, name string) (*v11.Pod, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pod", ctx, namespace, name)
+	ret0, _ := ret[0].(*v11.Pod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Pod indicates an expected call of Pod.
+func (mr *MockClusterAPIMockRecorder) Pod(ctx, namespace, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pod", reflect.TypeOf((*MockClusterAPI)(nil).Pod), ctx, namespace, name)
+}
+
+// Pods mocks base method.
+func (m *MockClusterAPI) Pods(ctx context.Context, namespace string) ([]kube.Pod, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pods", ctx, namespace)
+	ret0, _ := ret[0].([]kube.Pod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Pods indicates an expected call of Pods.
+func (mr *MockClusterAPIMockRecorder) Pods(ctx, namespace any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pods", reflect.TypeOf((*MockClusterAPI)(nil).Pods), ctx, namespace)
+}
+
+// ReplicaSets mocks base method.
+func (m *MockClusterAPI) ReplicaSets(ctx context.Context, namespace, selector string) ([]v1.ReplicaSet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplicaSets", ctx, namespace, selector)
+	ret0, _ := ret[0].([]v1.ReplicaSet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplicaSets indicates an expected call of ReplicaSets.
+func (mr *MockClusterAPIMockRecorder) ReplicaSets(ctx, namespace, selector any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplicaSets", reflect.TypeOf((*MockClusterAPI)(nil).ReplicaSets), ctx, namespace, selector)
+}
+
+// Restart mocks base method.
+func (m *MockClusterAPI) Restart(ctx context.Context, namespace, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restart", ctx, namespace, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Restart indicates an expected call of Restart.
+func (mr *MockClusterAPIMockRecorder) Restart(ctx, namespace, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restart", reflect.TypeOf((*MockClusterAPI)(nil).Restart), ctx, namespace, name)
+}
+
+// ReviewAccess mocks base method.
+func (m *MockClusterAPI) ReviewAccess(ctx context.Context, attributes v10.ResourceAttributes) (v10.SubjectAccessReviewStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReviewAccess", ctx, attributes)
+	ret0, _ := ret[0].(v10.SubjectAccessReviewStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReviewAccess indicates an expected call of ReviewAccess.
+func (mr *MockClusterAPIMockRecorder) ReviewAccess(ctx, attributes any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReviewAccess", reflect.TypeOf((*MockClusterAPI)(nil).ReviewAccess), ctx, attributes)
+}
+
+// Secret mocks base method.
+func (m *MockClusterAPI) Secret(ctx context.Context, namespace, name string) (*v11.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Secret", ctx, namespace, name)
+	ret0, _ := ret[0].(*v11.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Secret indicates an expected call of Secret.
+func (mr *MockClusterAPIMockRecorder) Secret(ctx, namespace, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Secret", reflect.TypeOf((*MockClusterAPI)(nil).Secret), ctx, namespace, name)
+}
+
+// Secrets mocks base method.
+func (m *MockClusterAPI) Secrets(ctx context.Context, namespace string) ([]v11.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Secrets", ctx, namespace)
+	ret0, _ := ret[0].([]v11.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Secrets indicates an expected call of Secrets.
+func (mr *MockClusterAPIMockRecorder) Secrets(ctx, namespace any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Secrets", reflect.TypeOf((*MockClusterAPI)(nil).Secrets), ctx, namespace)
+}
+
+// ServerVersion mocks base method.
+func (m *MockClusterAPI) ServerVersion(ctx context.Context) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ServerVersion", ctx)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ServerVersion indicates an expected call of ServerVersion.
+func (mr *MockClusterAPIMockRecorder) ServerVersion(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServerVersion", reflect.TypeOf((*MockClusterAPI)(nil).ServerVersion), ctx)
+}
+
+// SetImage mocks base method.
+func (m *MockClusterAPI) SetImage(ctx context.Context, namespace, name, container, image string, pullPolicy v11.PullPolicy, annotations map[string]string) (v11.Container, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetImage", ctx, namespace, name, container, image, pullPolicy, annotations)
+	ret0, _ := ret[0].(v11.Container)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetImage indicates an expected call of SetImage.
+func (mr *MockClusterAPIMockRecorder) SetImage(ctx, namespace, name, container, image, pullPolicy, annotations any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetImage", reflect.TypeOf((*MockClusterAPI)(nil).SetImage), ctx, namespace, name, container, image, pullPolicy, annotations)
+}
+
+// UpdateDeployment mocks base method.
+func (m *MockClusterAPI) UpdateDeployment(ctx context.Context, deployment *v1.Deployment) (*v1.Deployment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDeployment", ctx, deployment)
+	ret0, _ := ret[0].(*v1.Deployment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateDeployment indicates an expected call of UpdateDeployment.
+func (mr *MockClusterAPIMockRecorder) UpdateDeployment(ctx, deployment any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDeployment", reflect.TypeOf((*MockClusterAPI)(nil).UpdateDeployment), ctx, deployment)
+}
+
+// UpdateSecret mocks base method.
+func (m *MockClusterAPI) UpdateSecret(ctx context.Context, secret *v11.Secret) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSecret", ctx, secret)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSecret indicates an expected call of UpdateSecret.
+func (mr *MockClusterAPIMockRecorder) UpdateSecret(ctx, secret any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSecret", reflect.TypeOf((*MockClusterAPI)(nil).UpdateSecret), ctx, secret)
+}
+
+// MockGitProvider is a mock of GitProvider interface.
+type MockGitProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockGitProviderMockRecorder
+	isgomock struct{}
+}
+
+// MockGitProviderMockRecorder is the mock recorder for MockGitProvider.
+type MockGitProviderMockRecorder struct {
+	mock *MockGitProvider
+}
+
+// NewMockGitProvider creates a new mock instance.
+func NewMockGitProvider(ctrl *gomock.Controller) *MockGitProvider {
+	mock := &MockGitProvider{ctrl: ctrl}
+	mock.recorder = &MockGitProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGitProvider) EXPECT() *MockGitProviderMockRecorder {
+	return m.recorder
+}
+
+// ListCommits mocks base method.
+func (m *MockGitProvider) ListCommits(ctx context.Context, options gitprovider.ListOptions) (gitprovider.Page, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCommits", ctx, options)
+	ret0, _ := ret[0].(gitprovider.Page)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCommits indicates an expected call of ListCommits.
+func (mr *MockGitProviderMockRecorder) ListCommits(ctx, options any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCommits", reflect.TypeOf((*MockGitProvider)(nil).ListCommits), ctx, options)
+}
+
+// ListReleases mocks base method.
+func (m *MockGitProvider) ListReleases(ctx context.Context, limit int) ([]gitprovider.Release, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListReleases", ctx, limit)
+	ret0, _ := ret[0].([]gitprovider.Release)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListReleases indicates an expected call of ListReleases.
+func (mr *MockGitProviderMockRecorder) ListReleases(ctx, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListReleases", reflect.TypeOf((*MockGitProvider)(nil).ListReleases), ctx, limit)
+}
+
+// MockStore is a mock of Store interface.
+type MockStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockStoreMockRecorder
+	isgomock struct{}
+}
+
+// MockStoreMockRecorder is the mock recorder for MockStore.
+type MockStoreMockRecorder struct {
+	mock *MockStore
+}
+
+// NewMockStore creates a new mock instance.
+func NewMockStore(ctrl *gomock.Controller) *MockStore {
+	mock := &MockStore{ctrl: ctrl}
+	mock.recorder = &MockStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStore) EXPECT() *MockStoreMockRecorder {
+	return m.recorder
+}
+
+// AddDeployRule mocks base method.
+func (m *MockStore) AddDeployRule(ctx context.Context, rule store.DeployRule) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDeployRule", ctx, rule)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddDeployRule indicates an expected call of AddDeployRule.
+func (mr *MockStoreMockRecorder) AddDeployRule(ctx, rule any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDeployRule", reflect.TypeOf((*MockStore)(nil).AddDeployRule), ctx, rule)
+}
+
+// DeleteDeployRule mocks base method.
+func (m *MockStore) DeleteDeployRule(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDeployRule", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDeployRule indicates an expected call of DeleteDeployRule.
+func (mr *MockStoreMockRecorder) DeleteDeployRule(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDeployRule", reflect.TypeOf((*MockStore)(nil).DeleteDeployRule), ctx, id)
+}
+
+// DeployRules mocks base method.
+func (m *MockStore) DeployRules(ctx context.Context) ([]store.DeployRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeployRules", ctx)
+	ret0, _ := ret[0].([]store.DeployRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeployRules indicates an expected call of DeployRules.
+func (mr *MockStoreMockRecorder) DeployRules(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeployRules", reflect.TypeOf((*MockStore)(nil).DeployRules), ctx)
+}
+
+// EnsureSchema mocks base method.
+func (m *MockStore) EnsureSchema(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnsureSchema", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnsureSchema indicates an expected call of EnsureSchema.
+func (mr *MockStoreMockRecorder) EnsureSchema(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsureSchema", reflect.TypeOf((*MockStore)(nil).EnsureSchema), ctx)
+}
+
+// History mocks base method.
+func (m *MockStore) History(ctx context.Context, limit int) ([]store.HistoryEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "History", ctx, limit)
+	ret0, _ := ret[0].([]store.HistoryEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// History indicates an expected call of History.
+func (mr *MockStoreMockRecorder) History(ctx, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "History", reflect.TypeOf((*MockStore)(nil).History), ctx, limit)
+}
+
+// HistoryAfter mocks base method.
+func (m *MockStore) HistoryAfter(ctx context.Context, afterID int64, limit int) ([]store.HistoryEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HistoryAfter", ctx, afterID, limit)
+	ret0, _ := ret[0].([]store.HistoryEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HistoryAfter indicates an expected call of HistoryAfter.
+func (mr *MockStoreMockRecorder) HistoryAfter(ctx, afterID, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HistoryAfter", reflect.TypeOf((*MockStore)(nil).HistoryAfter), ctx, afterID, limit)
+}
+
+// HistoryBetween mocks base method.
+func (m *MockStore) HistoryBetween(ctx context.Context, since, until time.Time) ([]store.HistoryEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HistoryBetween", ctx, since, until)
+	ret0, _ := ret[0].([]store.HistoryEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HistoryBetween indicates an expected call of HistoryBetween.
+func (mr *MockStoreMockRecorder) HistoryBetween(ctx, since, until any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HistoryBetween", reflect.TypeOf((*MockStore)(nil).HistoryBetween), ctx, since, until)
+}
+
+// LatestHistory mocks base method.
+func (m *MockStore) LatestHistory(ctx context.Context, action string) ([]store.HistoryEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LatestHistory", ctx, action)
+	ret0, _ := ret[0].([]store.HistoryEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LatestHistory indicates an expected call of LatestHistory.
+func (mr *MockStoreMockRecorder) LatestHistory(ctx, action any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LatestHistory", reflect.TypeOf((*MockStore)(nil).LatestHistory), ctx, action)
+}
+
+// LoadDeployProfile mocks base method.
+func (m *MockStore) LoadDeployProfile(ctx context.Context, image string) (store.DeployProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadDeployProfile", ctx, image)
+	ret0, _ := ret[0].(store.DeployProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadDeployProfile indicates an expected call of LoadDeployProfile.
+func (mr *MockStoreMockRecorder) LoadDeployProfile(ctx, image any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadDeployProfile", reflect.TypeOf((*MockStore)(nil).LoadDeployProfile), ctx, image)
+}
+
+// LoadTabSnapshot mocks base method.
+func (m *MockStore) LoadTabSnapshot(ctx context.Context, name string) (store.TabSnapshot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadTabSnapshot", ctx, name)
+	ret0, _ := ret[0].(store.TabSnapshot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadTabSnapshot indicates an expected call of LoadTabSnapshot.
+func (mr *MockStoreMockRecorder) LoadTabSnapshot(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadTabSnapshot", reflect.TypeOf((*MockStore)(nil).LoadTabSnapshot), ctx, name)
+}
+
+// RecordCommit mocks base method.
+func (m *MockStore) RecordCommit(ctx context.Context, commit store.Commit) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordCommit", ctx, commit)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordCommit indicates an expected call of RecordCommit.
+func (mr *MockStoreMockRecorder) RecordCommit(ctx, commit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordCommit", reflect.TypeOf((*MockStore)(nil).RecordCommit), ctx, commit)
+}
+
+// RecordDeployRuleRun mocks base method.
+func (m *MockStore) RecordDeployRuleRun(ctx context.Context, id int64, image, status string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordDeployRuleRun", ctx, id, image, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordDeployRuleRun indicates an expected call of RecordDeployRuleRun.
+func (mr *MockStoreMockRecorder) RecordDeployRuleRun(ctx, id, image, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordDeployRuleRun", reflect.TypeOf((*MockStore)(nil).RecordDeployRuleRun), ctx, id, image, status)
+}
+
+// RecordHistory mocks base method.
+func (m *MockStore) RecordHistory(ctx context.Context, entry store.HistoryEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordHistory", ctx, entry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordHistory indicates an expected call of RecordHistory.
+func (mr *MockStoreMockRecorder) RecordHistory(ctx, entry any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordHistory", reflect.TypeOf((*MockStore)(nil).RecordHistory), ctx, entry)
+}
+
+// RecordImages mocks base method.
+func (m *MockStore) RecordImages(ctx context.Context, records []store.ImageRecord) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordImages", ctx, records)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordImages indicates an expected call of RecordImages.
+func (mr *MockStoreMockRecorder) RecordImages(ctx, records any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordImages", reflect.TypeOf((*MockStore)(nil).RecordImages), ctx, records)
+}
+
+// SaveDeployProfile mocks base method.
+func (m *MockStore) SaveDeployProfile(ctx context.Context, image string, content []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveDeployProfile", ctx, image, content)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveDeployProfile indicates an expected call of SaveDeployProfile.
+func (mr *MockStoreMockRecorder) SaveDeployProfile(ctx, image, content any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveDeployProfile", reflect.TypeOf((*MockStore)(nil).SaveDeployProfile), ctx, image, content)
+}
+
+// SaveTabSnapshot mocks base method.
+func (m *MockStore) SaveTabSnapshot(ctx context.Context, name string, content []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveTabSnapshot", ctx, name, content)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveTabSnapshot indicates an expected call of SaveTabSnapshot.
+func (mr *MockStoreMockRecorder) SaveTabSnapshot(ctx, name, content any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTabSnapshot", reflect.TypeOf((*MockStore)(nil).SaveTabSnapshot), ctx, name, content)
+}
+
+// SetDeployRuleEnabled mocks base method.
+func (m *MockStore) SetDeployRuleEnabled(ctx context.Context, id int64, enabled bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDeployRuleEnabled", ctx, id, enabled)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDeployRuleEnabled indicates an expected call of SetDeployRuleEnabled.
+func (mr *MockStoreMockRecorder) SetDeployRuleEnabled(ctx, id, enabled any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDeployRuleEnabled", reflect.TypeOf((*MockStore)(nil).SetDeployRuleEnabled), ctx, id, enabled)
+}
+
+// SetPullRequestDescription mocks base method.
+func (m *MockStore) SetPullRequestDescription(ctx context.Context, sha, description string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPullRequestDescription", ctx, sha, description)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPullRequestDescription indicates an expected call of SetPullRequestDescription.
+func (mr *MockStoreMockRecorder) SetPullRequestDescription(ctx, sha, description any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPullRequestDescription", reflect.TypeOf((*MockStore)(nil).SetPullRequestDescription), ctx, sha, description)
+}
+
+// SizeHistory mocks base method.
+func (m *MockStore) SizeHistory(ctx context.Context, arg1, repository string, limit int) ([]store.SizePoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SizeHistory", ctx, arg1, repository, limit)
+	ret0, _ := ret[0].([]store.SizePoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SizeHistory indicates an expected call of SizeHistory.
+func (mr *MockStoreMockRecorder) SizeHistory(ctx, arg1, repository, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SizeHistory", reflect.TypeOf((*MockStore)(nil).SizeHistory), ctx, arg1, repository, limit)
+}
@@ -0,0 +1,174 @@
+// Package docker drives the local Docker engine through the docker CLI:
+// listing, pulling, tagging, pushing and removing images. Going through the
+// CLI rather than the engine API picks up the user's contexts, credential
+// helpers and registry mirrors as docker itself would. Only PullProgress
+// talks to the engine API, for the per-layer progress the CLI keeps to
+// terminals.
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Client runs the docker CLI. The zero value runs "docker" from PATH.
+type Client struct {
+	// Binary is the docker executable, "docker" when empty.
+	Binary string
+	// Trace, when set, is called as each docker command starts with its
+	// arguments, and the function it returns with the command's error once
+	// it finishes. Callers use it to time commands, e.g. as trace spans.
+	Trace func(ctx context.Context, args []string) (context.Context, func(error))
+}
+
+// Image is a local image as docker images lists it, one per tag.
+type Image struct {
+	ID         string // the full image ID, sha256:...
+	Repository string
+	Tag        string
+	Digest     string // the registry digest, empty if never pushed or pulled
+	Size       string // human readable, e.g. "142MB"
+	CreatedAt  string
+}
+
+// Ref is the image's repository:tag.
+func (i Image) Ref() string {
+	return i.Repository + ":" + i.Tag
+}
+
+// ShortID is the image ID as docker images shows it by default.
+func (i Image) ShortID() string {
+	id := strings.TrimPrefix(i.ID, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// trace starts tracing a docker command, see Client.Trace.
+func (c Client) trace(ctx context.Context, args ...string) (context.Context, func(error)) {
+	if c.Trace == nil {
+		return ctx, func(error) {}
+	}
+	return c.Trace(ctx, args)
+}
+
+func (c Client) command(ctx context.Context, args ...string) *exec.Cmd {
+	binary := c.Binary
+	if binary == "" {
+		binary = "docker"
+	}
+	return exec.CommandContext(ctx, binary, args...)
+}
+
+// run runs a docker command, reporting its output when it fails.
+func (c Client) run(ctx context.Context, args ...string) (err error) {
+	ctx, done := c.trace(ctx, args...)
+	defer func() { done(err) }()
+	if output, err := c.command(ctx, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("docker %s failed: %v\nOutput: %s", args[0], err, string(output))
+	}
+	return nil
+}
+
+// Images lists the local images.
+func (c Client) Images(ctx context.Context) ([]Image, error) {
+	args := []string{"images", "--digests", "--no-trunc",
+		"--format", "{{.ID}}\t{{.Repository}}\t{{.Tag}}\t{{.Digest}}\t{{.Size}}\t{{.CreatedAt}}"}
+	ctx, done := c.trace(ctx, args...)
+	output, err := c.command(ctx, args...).Output()
+	done(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list docker images: %v", err)
+	}
+
+	var images []Image
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 6 {
+			continue
+		}
+		image := Image{ID: fields[0], Repository: fields[1], Tag: fields[2], Size: fields[4], CreatedAt: fields[5]}
+		if fields[3] != "<none>" {
+			image.Digest = fields[3]
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// Labels is the labels of each image, in the order asked for, with a single
+// docker image inspect.
+func (c Client) Labels(ctx context.Context, ids ...string) ([]map[string]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := append([]string{"image", "inspect", "--format", "{{json .Config.Labels}}"}, ids...)
+	ctx, done := c.trace(ctx, args...)
+	output, err := c.command(ctx, args...).Output()
+	done(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect docker images: %v", err)
+	}
+	labels := make([]map[string]string, len(ids))
+	// One line per image, in the order asked for
+	for i, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if i < len(labels) {
+			_ = json.Unmarshal([]byte(line), &labels[i])
+		}
+	}
+	return labels, nil
+}
+
+// Pull pulls an image. Docker's progress goes to progress when it isn't nil
+// and is otherwise only reported when the pull fails.
+func (c Client) Pull(ctx context.Context, ref string, progress io.Writer) error {
+	if progress == nil {
+		return c.run(ctx, "pull", ref)
+	}
+	var stderr bytes.Buffer
+	ctx, done := c.trace(ctx, "pull", ref)
+	cmd := c.command(ctx, "pull", ref)
+	cmd.Stdout = progress
+	cmd.Stderr = io.MultiWriter(progress, &stderr)
+	err := cmd.Run()
+	done(err)
+	if err != nil {
+		return fmt.Errorf("docker pull failed: %v\nOutput: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// Tag tags the image source as target.
+func (c Client) Tag(ctx context.Context, source, target string) error {
+	return c.run(ctx, "tag", source, target)
+}
+
+// Push pushes an image to the registry its reference names.
+func (c Client) Push(ctx context.Context, ref string) error {
+	return c.run(ctx, "push", ref)
+}
+
+// Remove removes an image, with all of its tags.
+func (c Client) Remove(ctx context.Context, id string) error {
+	return c.run(ctx, "rmi", "-f", id)
+}
+
+// Version is the version of the Docker engine, which fails when the daemon
+// isn't reachable.
+func (c Client) Version(ctx context.Context) (string, error) {
+	ctx, done := c.trace(ctx, "version", "--format", "{{.Server.Version}}")
+	output, err := c.command(ctx, "version", "--format", "{{.Server.Version}}").CombinedOutput()
+	done(err)
+	if message := strings.TrimSpace(string(output)); err != nil && message != "" {
+		return "", fmt.Errorf("%v: %s", err, message)
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,146 @@
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// The CLI only draws per-layer progress on a terminal, so pulls that show it
+// talk to the engine API, found the way the CLI finds it: DOCKER_HOST, else
+// the current context's endpoint.
+
+// ErrNoEngineAPI is returned by PullProgress when the engine can't be
+// reached directly, e.g. over ssh:// or with TLS; Pull still works.
+var ErrNoEngineAPI = errors.New("the docker engine API isn't reachable directly")
+
+// Layer is one layer of a pull as the engine reports it.
+type Layer struct {
+	ID      string
+	Status  string // e.g. Waiting, Downloading, Extracting, Pull complete
+	Current int64  // bytes of the current step, when the engine reports them
+	Total   int64
+}
+
+// Auth is what the engine sends the registry, empty for anonymous pulls.
+type Auth struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	ServerAddress string `json:"serveraddress,omitempty"`
+}
+
+// PullProgress pulls an image through the engine API, calling progress for
+// every update of a layer. Updates without a layer, like the final digest,
+// come with an empty ID.
+func (c Client) PullProgress(ctx context.Context, ref string, auth Auth, progress func(Layer)) (err error) {
+	ctx, done := c.trace(ctx, "pull", ref)
+	defer func() { done(err) }()
+	client, err := c.engineClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	name, tag := splitRef(ref)
+	query := url.Values{"fromImage": {name}, "tag": {tag}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://docker/images/create?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if auth != (Auth{}) {
+		encoded, err := json.Marshal(auth)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("X-Registry-Auth", base64.URLEncoding.EncodeToString(encoded))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("docker pull failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		var message struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &message) == nil && message.Message != "" {
+			return fmt.Errorf("docker pull failed: %s", message.Message)
+		}
+		return fmt.Errorf("docker pull failed: %s", resp.Status)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var update struct {
+			ID             string `json:"id"`
+			Status         string `json:"status"`
+			ProgressDetail struct {
+				Current int64 `json:"current"`
+				Total   int64 `json:"total"`
+			} `json:"progressDetail"`
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&update); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("docker pull failed: %v", err)
+		}
+		if update.Error != "" {
+			return fmt.Errorf("docker pull failed: %s", update.Error)
+		}
+		progress(Layer{ID: update.ID, Status: update.Status, Current: update.ProgressDetail.Current, Total: update.ProgressDetail.Total})
+	}
+}
+
+// engineClient is an HTTP client connected to the engine's socket or TCP
+// port.
+func (c Client) engineClient(ctx context.Context) (*http.Client, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		output, err := c.command(ctx, "context", "inspect", "--format", "{{.Endpoints.docker.Host}}").Output()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNoEngineAPI, err)
+		}
+		host = strings.TrimSpace(string(output))
+	}
+	if os.Getenv("DOCKER_TLS_VERIFY") != "" {
+		return nil, ErrNoEngineAPI
+	}
+
+	network, address, ok := strings.Cut(host, "://")
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%w: %s", ErrNoEngineAPI, host)
+	case network == "unix":
+	case network == "tcp":
+		address = strings.TrimSuffix(address, "/")
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrNoEngineAPI, host)
+	}
+	dialer := &net.Dialer{}
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+	}}, nil
+}
+
+// splitRef splits an image reference into the image and the tag or digest
+// the engine expects separately; without one it pulls latest.
+func splitRef(ref string) (name, tag string) {
+	if name, digest, ok := strings.Cut(ref, "@"); ok {
+		return name, digest
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, "latest"
+}
@@ -0,0 +1,112 @@
+package gitprovider
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/go-github/v63/github"
+)
+
+// GitHubOptions configures a GitHub provider. The zero value makes
+// unauthenticated requests with http.DefaultClient.
+type GitHubOptions struct {
+	// HTTPClient makes the requests. Callers add retries and conditional
+	// requests through its transport.
+	HTTPClient *http.Client
+	// Token is a personal access token, raising the rate limit and giving
+	// access to private repositories.
+	Token string
+}
+
+// GitHub lists the commits of a GitHub repository.
+type GitHub struct {
+	client *github.Client
+	owner  string
+	repo   string
+}
+
+// NewGitHub is the provider for the repository owner/repo.
+func NewGitHub(owner, repo string, options GitHubOptions) *GitHub {
+	client := github.NewClient(options.HTTPClient)
+	if options.Token != "" {
+		client = client.WithAuthToken(options.Token)
+	}
+	return &GitHub{client: client, owner: owner, repo: repo}
+}
+
+// ListCommits lists a page of the branch's commits.
+func (g *GitHub) ListCommits(ctx context.Context, options ListOptions) (Page, error) {
+	commits, resp, err := g.client.Repositories.ListCommits(ctx, g.owner, g.repo, &github.CommitsListOptions{
+		SHA:   options.Branch,
+		Since: options.Since,
+		Until: options.Until,
+		Path:  options.Path,
+		ListOptions: github.ListOptions{
+			Page:    max(options.Page, 1),
+			PerPage: options.PerPage,
+		},
+	})
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{
+		More:   resp.NextPage != 0,
+		Cached: resp.Header.Get(FromCacheHeader) != "",
+	}
+	for _, commit := range commits {
+		listed := Commit{SHA: commit.GetSHA(), Message: commit.GetCommit().GetMessage()}
+		if author := commit.GetCommit().GetAuthor(); author != nil {
+			listed.Author = author.GetName()
+			listed.Date = author.GetDate().Time
+		}
+		listed.Committer = commit.GetCommit().GetCommitter().GetName()
+		page.Commits = append(page.Commits, listed)
+	}
+	return page, nil
+}
+
+// ListReleases lists the latest published releases, then the tags without
+// one. Drafts are left out: their tag may not exist yet.
+func (g *GitHub) ListReleases(ctx context.Context, limit int) ([]Release, error) {
+	releases, _, err := g.client.Repositories.ListReleases(ctx, g.owner, g.repo, &github.ListOptions{PerPage: limit})
+	if err != nil {
+		return nil, err
+	}
+	tags, _, err := g.client.Repositories.ListTags(ctx, g.owner, g.repo, &github.ListOptions{PerPage: limit})
+	if err != nil {
+		return nil, err
+	}
+
+	var listed []Release
+	released := make(map[string]bool)
+	for _, release := range releases {
+		if release.GetDraft() {
+			continue
+		}
+		item := Release{
+			Tag:        release.GetTagName(),
+			Name:       release.GetName(),
+			Notes:      release.GetBody(),
+			Published:  release.GetPublishedAt().Time,
+			Prerelease: release.GetPrerelease(),
+			URL:        release.GetHTMLURL(),
+		}
+		for _, asset := range release.Assets {
+			item.Assets = append(item.Assets, Asset{
+				Name:      asset.GetName(),
+				Size:      int64(asset.GetSize()),
+				Downloads: asset.GetDownloadCount(),
+				URL:       asset.GetBrowserDownloadURL(),
+			})
+		}
+		listed = append(listed, item)
+		released[item.Tag] = true
+	}
+	for _, tag := range tags {
+		if !released[tag.GetName()] {
+			listed = append(listed, Release{Tag: tag.GetName()})
+		}
+	}
+	return listed, nil
+}
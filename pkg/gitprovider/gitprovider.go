@@ -0,0 +1,68 @@
+// Package gitprovider lists the commits of a repository hosted on a Git
+// provider, page by page, so builds and deployments can be traced back to
+// the source they came from, and its releases and tags, which name the
+// versions built. GitHub is the one provider implemented.
+package gitprovider
+
+import (
+	"context"
+	"time"
+)
+
+// FromCacheHeader marks responses an HTTP transport served from its own
+// cache. Pages answered that way have been seen before, which Page.Cached
+// reports so callers can skip work for them.
+const FromCacheHeader = "X-From-Cache"
+
+// Commit is a commit as the provider lists it.
+type Commit struct {
+	SHA       string
+	Message   string
+	Author    string
+	Committer string    // who applied the commit, the author's name unless e.g. rebased
+	Date      time.Time // the author date, zero when unknown
+}
+
+// ListOptions selects a page of a branch's commits.
+type ListOptions struct {
+	Branch  string    // the default branch when empty
+	Since   time.Time // zero for no lower bound
+	Until   time.Time // zero for no upper bound
+	Path    string    // only commits touching this file or directory, empty for all
+	Page    int       // 1-based
+	PerPage int
+}
+
+// Page is a page of commits, newest first.
+type Page struct {
+	Commits []Commit
+	More    bool // whether there are older pages
+	Cached  bool // whether the page is unchanged since it was last fetched
+}
+
+// Release is a release as the provider lists it, or a tag without one.
+type Release struct {
+	Tag        string
+	Name       string    // the release's title, empty for a bare tag
+	Notes      string    // Markdown
+	Published  time.Time // zero for a bare tag
+	Prerelease bool
+	URL        string // the release's web page, empty for a bare tag
+	Assets     []Asset
+}
+
+// Asset is a file attached to a release.
+type Asset struct {
+	Name      string
+	Size      int64
+	Downloads int
+	URL       string // where the file downloads from
+}
+
+// Provider lists the commits and releases of one repository.
+type Provider interface {
+	ListCommits(ctx context.Context, options ListOptions) (Page, error)
+	// ListReleases lists up to limit of the latest published releases,
+	// newest first, followed by up to limit tags without a release.
+	ListReleases(ctx context.Context, limit int) ([]Release, error)
+}
@@ -0,0 +1,695 @@
+// Package kube inspects and changes the workloads of a Kubernetes cluster:
+// listing pods and deployments, rolling a deployment to another image,
+// restarting it and deleting it, and reading and writing the objects around
+// them. It works on any kubernetes.Interface, so callers choose the
+// kubeconfig and tests can pass a fake clientset.
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// RestartedAtAnnotation is what `kubectl rollout restart` sets on the pod
+// template; changing it makes the deployment roll out new pods.
+const RestartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// Client works on one cluster.
+type Client struct {
+	connect   func() (kubernetes.Interface, error)
+	once      sync.Once
+	clientset kubernetes.Interface
+	err       error
+}
+
+// New wraps a clientset.
+func New(clientset kubernetes.Interface) *Client {
+	return &Client{clientset: clientset}
+}
+
+// Connect is a client that calls connect for its clientset when first
+// used, so making one needs no kubeconfig. If connect fails, every call
+// fails with its error.
+func Connect(connect func() (kubernetes.Interface, error)) *Client {
+	return &Client{connect: connect}
+}
+
+// Clientset is the clientset the client works through.
+func (c *Client) Clientset() (kubernetes.Interface, error) {
+	c.once.Do(func() {
+		if c.connect != nil {
+			c.clientset, c.err = c.connect()
+		}
+	})
+	return c.clientset, c.err
+}
+
+// Pod is what a pod listing shows of a pod.
+type Pod struct {
+	Name      string
+	Namespace string
+	Phase     string // Pending, Running, Succeeded, Failed or Unknown
+	Node      string // empty until scheduled
+	Restarts  int32  // of all its containers
+	Created   time.Time
+}
+
+// Age is how long ago the pod was created, to the second.
+func (p Pod) Age() time.Duration {
+	return time.Since(p.Created).Truncate(time.Second)
+}
+
+func podOf(pod corev1.Pod) Pod {
+	summary := Pod{
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		Phase:     string(pod.Status.Phase),
+		Node:      pod.Spec.NodeName,
+		Created:   pod.CreationTimestamp.Time,
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		summary.Restarts += status.RestartCount
+	}
+	return summary
+}
+
+func (c *Client) listPods(ctx context.Context, namespace string, options metav1.ListOptions) ([]Pod, error) {
+	list, err := c.ListPods(ctx, namespace, options.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]Pod, 0, len(list))
+	for _, pod := range list {
+		pods = append(pods, podOf(pod))
+	}
+	return pods, nil
+}
+
+// Pods lists the pods in a namespace.
+func (c *Client) Pods(ctx context.Context, namespace string) ([]Pod, error) {
+	return c.listPods(ctx, namespace, metav1.ListOptions{})
+}
+
+// DeploymentPods lists the pods a deployment's selector matches.
+func (c *Client) DeploymentPods(ctx context.Context, namespace, name string) ([]Pod, error) {
+	deployment, err := c.Deployment(ctx, namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("error getting deployment: %v", err)
+	}
+	var options metav1.ListOptions
+	if deployment.Spec.Selector != nil && deployment.Spec.Selector.MatchLabels != nil {
+		var selectors []string
+		for key, value := range deployment.Spec.Selector.MatchLabels {
+			selectors = append(selectors, fmt.Sprintf("%s=%s", key, value))
+		}
+		sort.Strings(selectors)
+		options.LabelSelector = strings.Join(selectors, ",")
+	}
+	pods, err := c.listPods(ctx, namespace, options)
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods: %v", err)
+	}
+	return pods, nil
+}
+
+// OwnedPods lists the pods the deployment controls through its ReplicaSets,
+// the ones a rollout replaces. Unlike DeploymentPods it leaves out pods of
+// other workloads that happen to match the selector.
+func (c *Client) OwnedPods(ctx context.Context, deployment *appsv1.Deployment) ([]Pod, error) {
+	selector := metav1.FormatLabelSelector(deployment.Spec.Selector)
+	replicaSets, err := c.ReplicaSets(ctx, deployment.Namespace, selector)
+	if err != nil {
+		return nil, fmt.Errorf("error listing replica sets: %v", err)
+	}
+	owned := make(map[types.UID]bool)
+	for _, replicaSet := range replicaSets {
+		if owner := metav1.GetControllerOf(&replicaSet); owner != nil && owner.UID == deployment.UID {
+			owned[replicaSet.UID] = true
+		}
+	}
+	list, err := c.ListPods(ctx, deployment.Namespace, selector)
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods: %v", err)
+	}
+	var pods []Pod
+	for _, pod := range list {
+		if owner := metav1.GetControllerOf(&pod); owner != nil && owned[owner.UID] {
+			pods = append(pods, podOf(pod))
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	return pods, nil
+}
+
+// LogOptions selects the log lines of one of a pod's containers.
+type LogOptions struct {
+	Container string // may be empty for a pod with a single container
+	Tail      int64  // the last lines only, 0 for all of them
+	Previous  bool   // of the container instance that ran before a restart
+}
+
+// Logs reads a container's log.
+func (c *Client) Logs(ctx context.Context, namespace, pod string, options LogOptions) (string, error) {
+	request := &corev1.PodLogOptions{Container: options.Container, Previous: options.Previous}
+	if options.Tail > 0 {
+		request.TailLines = &options.Tail
+	}
+	clientset, err := c.Clientset()
+	if err != nil {
+		return "", err
+	}
+	raw, err := clientset.CoreV1().Pods(namespace).GetLogs(pod, request).DoRaw(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error reading logs of %s/%s: %v", namespace, pod, err)
+	}
+	return string(raw), nil
+}
+
+// Namespaces lists the names of the cluster's namespaces.
+func (c *Client) Namespaces(ctx context.Context) ([]string, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	list, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(list.Items))
+	for _, namespace := range list.Items {
+		names = append(names, namespace.Name)
+	}
+	return names, nil
+}
+
+// NamespaceExists reports whether the namespace exists.
+func (c *Client) NamespaceExists(ctx context.Context, name string) (bool, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return false, err
+	}
+	_, err = clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CreateNamespace creates a namespace; one that exists already is no error.
+func (c *Client) CreateNamespace(ctx context.Context, name string) error {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return err
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if _, err := clientset.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("error creating namespace %s: %v", name, err)
+	}
+	return nil
+}
+
+// Deployments lists the deployments in a namespace.
+func (c *Client) Deployments(ctx context.Context, namespace string) ([]appsv1.Deployment, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	list, err := clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// DesiredReplicas is the replicas a deployment asks for, 1 when unset.
+func DesiredReplicas(deployment appsv1.Deployment) int32 {
+	if deployment.Spec.Replicas == nil {
+		return 1
+	}
+	return *deployment.Spec.Replicas
+}
+
+// Condition summarizes a deployment the way `kubectl rollout status` would:
+// Stalled, Paused, RollingOut, Unavailable or Available.
+func Condition(deployment appsv1.Deployment) string {
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == "ProgressDeadlineExceeded" {
+			return "Stalled"
+		}
+	}
+	switch {
+	case deployment.Spec.Paused:
+		return "Paused"
+	case deployment.Status.UpdatedReplicas < DesiredReplicas(deployment), deployment.Status.Replicas > deployment.Status.UpdatedReplicas:
+		return "RollingOut"
+	case deployment.Status.AvailableReplicas < DesiredReplicas(deployment):
+		return "Unavailable"
+	}
+	return "Available"
+}
+
+// Images lists the images of a deployment's containers.
+func Images(deployment appsv1.Deployment) []string {
+	var images []string
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		images = append(images, container.Image)
+	}
+	return images
+}
+
+// Containers lists the names of a deployment's containers.
+func Containers(deployment appsv1.Deployment) []string {
+	var names []string
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		names = append(names, container.Name)
+	}
+	return names
+}
+
+// ContainerIndex is the index of the deployment's container called name, the
+// first container when name is empty.
+func ContainerIndex(deployment *appsv1.Deployment, name string) (int, error) {
+	containers := deployment.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return 0, fmt.Errorf("deployment %s has no containers", deployment.Name)
+	}
+	if name == "" {
+		return 0, nil
+	}
+	for i, container := range containers {
+		if container.Name == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("deployment %s has no container %q, only %s", deployment.Name, name, strings.Join(Containers(*deployment), ", "))
+}
+
+// WithImage returns a copy of deployment running image in the container
+// called container, the first one when empty, as SetImage leaves it.
+func WithImage(deployment *appsv1.Deployment, container, image string, pullPolicy corev1.PullPolicy) (*appsv1.Deployment, error) {
+	i, err := ContainerIndex(deployment, container)
+	if err != nil {
+		return nil, err
+	}
+	updated := deployment.DeepCopy()
+	updated.Spec.Template.Spec.Containers[i].Image = image
+	updated.Spec.Template.Spec.Containers[i].ImagePullPolicy = pullPolicy
+	return updated, nil
+}
+
+// WithEnv is a copy of deployment with the env and envFrom of a container,
+// the first one when container is empty, replaced.
+func WithEnv(deployment *appsv1.Deployment, container string, env []corev1.EnvVar, envFrom []corev1.EnvFromSource) (*appsv1.Deployment, error) {
+	i, err := ContainerIndex(deployment, container)
+	if err != nil {
+		return nil, err
+	}
+	updated := deployment.DeepCopy()
+	updated.Spec.Template.Spec.Containers[i].Env = env
+	updated.Spec.Template.Spec.Containers[i].EnvFrom = envFrom
+	return updated, nil
+}
+
+// SetImage rolls a deployment's container, the first one when container is
+// empty, to image and returns the container as it was, so the change can be
+// undone. annotations, if any, are set on the pod template along with it.
+func (c *Client) SetImage(ctx context.Context, namespace, name, container, image string, pullPolicy corev1.PullPolicy, annotations map[string]string) (previous corev1.Container, err error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	deployments := clientset.AppsV1().Deployments(namespace)
+	deployment, err := deployments.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return corev1.Container{}, fmt.Errorf("error getting deployment %s: %v", name, err)
+	}
+	i, err := ContainerIndex(deployment, container)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	updated, _ := WithImage(deployment, container, image, pullPolicy)
+	if len(annotations) > 0 && updated.Spec.Template.Annotations == nil {
+		updated.Spec.Template.Annotations = make(map[string]string)
+	}
+	for key, value := range annotations {
+		updated.Spec.Template.Annotations[key] = value
+	}
+	if _, err := deployments.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return corev1.Container{}, fmt.Errorf("error updating deployment %s: %v", name, err)
+	}
+	return deployment.Spec.Template.Spec.Containers[i], nil
+}
+
+// Restart is the equivalent of `kubectl rollout restart`. Pods are replaced
+// without changing the image, which picks up an image pushed again under
+// the same tag when the pull policy is Always.
+func (c *Client) Restart(ctx context.Context, namespace, name string) error {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return err
+	}
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`, RestartedAtAnnotation, time.Now().Format(time.RFC3339))
+	_, err = clientset.AppsV1().Deployments(namespace).Patch(ctx, name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to restart deployment %s/%s: %v", namespace, name, err)
+	}
+	return nil
+}
+
+// Delete deletes a deployment and returns it as it was, so it can be
+// re-created.
+func (c *Client) Delete(ctx context.Context, namespace, name string) (*appsv1.Deployment, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	deployments := clientset.AppsV1().Deployments(namespace)
+	deployment, err := deployments.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting deployment %s: %v", name, err)
+	}
+	if err := deployments.Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to delete deployment %s: %v", name, err)
+	}
+	return deployment, nil
+}
+
+// The methods below read and write single objects as the API has them;
+// errors are the API's, so apierrors.IsNotFound and the like apply.
+
+// Deployment gets a deployment.
+func (c *Client) Deployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	return clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// CreateDeployment creates a deployment in its namespace.
+func (c *Client) CreateDeployment(ctx context.Context, deployment *appsv1.Deployment) (*appsv1.Deployment, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	return clientset.AppsV1().Deployments(deployment.Namespace).Create(ctx, deployment, metav1.CreateOptions{})
+}
+
+// UpdateDeployment replaces a deployment with this version of it.
+func (c *Client) UpdateDeployment(ctx context.Context, deployment *appsv1.Deployment) (*appsv1.Deployment, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	return clientset.AppsV1().Deployments(deployment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+}
+
+// DeleteDeployment deletes a deployment, its pods as propagation says;
+// empty is the cluster's default.
+func (c *Client) DeleteDeployment(ctx context.Context, namespace, name string, propagation metav1.DeletionPropagation) error {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return err
+	}
+	var options metav1.DeleteOptions
+	if propagation != "" {
+		options.PropagationPolicy = &propagation
+	}
+	return clientset.AppsV1().Deployments(namespace).Delete(ctx, name, options)
+}
+
+// ReplicaSets lists the replica sets in a namespace matching a label
+// selector, all of them when it's empty.
+func (c *Client) ReplicaSets(ctx context.Context, namespace, selector string) ([]appsv1.ReplicaSet, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	list, err := clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// Pod gets a pod.
+func (c *Client) Pod(ctx context.Context, namespace, name string) (*corev1.Pod, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	return clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// ListPods lists the pods in a namespace matching a label selector, all of
+// them when it's empty, whole rather than as Pods summarizes them.
+func (c *Client) ListPods(ctx context.Context, namespace, selector string) ([]corev1.Pod, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	list, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// CreatePod creates a pod in its namespace.
+func (c *Client) CreatePod(ctx context.Context, pod *corev1.Pod) (*corev1.Pod, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	return clientset.CoreV1().Pods(pod.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+}
+
+// DeletePod deletes a pod.
+func (c *Client) DeletePod(ctx context.Context, namespace, name string) error {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return err
+	}
+	return clientset.CoreV1().Pods(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+// Events lists the events in a namespace matching a field selector, e.g.
+// involvedObject.name=web.
+func (c *Client) Events(ctx context.Context, namespace, fieldSelector string) ([]corev1.Event, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	list, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: fieldSelector})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// Secret gets a secret.
+func (c *Client) Secret(ctx context.Context, namespace, name string) (*corev1.Secret, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	return clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// Secrets lists the secrets in a namespace.
+func (c *Client) Secrets(ctx context.Context, namespace string) ([]corev1.Secret, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	list, err := clientset.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// CreateSecret creates a secret in its namespace.
+func (c *Client) CreateSecret(ctx context.Context, secret *corev1.Secret) error {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return err
+	}
+	_, err = clientset.CoreV1().Secrets(secret.Namespace).Create(ctx, secret, metav1.CreateOptions{})
+	return err
+}
+
+// UpdateSecret replaces a secret with this version of it.
+func (c *Client) UpdateSecret(ctx context.Context, secret *corev1.Secret) error {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return err
+	}
+	_, err = clientset.CoreV1().Secrets(secret.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+	return err
+}
+
+// ConfigMap gets a config map.
+func (c *Client) ConfigMap(ctx context.Context, namespace, name string) (*corev1.ConfigMap, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	return clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// ConfigMaps lists the config maps in a namespace.
+func (c *Client) ConfigMaps(ctx context.Context, namespace string) ([]corev1.ConfigMap, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	list, err := clientset.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// CreateService creates a service in its namespace.
+func (c *Client) CreateService(ctx context.Context, service *corev1.Service) (*corev1.Service, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	return clientset.CoreV1().Services(service.Namespace).Create(ctx, service, metav1.CreateOptions{})
+}
+
+// DeleteService deletes a service.
+func (c *Client) DeleteService(ctx context.Context, namespace, name string) error {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return err
+	}
+	return clientset.CoreV1().Services(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+// CreateIngress creates an ingress in its namespace.
+func (c *Client) CreateIngress(ctx context.Context, ingress *networkingv1.Ingress) error {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return err
+	}
+	_, err = clientset.NetworkingV1().Ingresses(ingress.Namespace).Create(ctx, ingress, metav1.CreateOptions{})
+	return err
+}
+
+// DeleteIngress deletes an ingress.
+func (c *Client) DeleteIngress(ctx context.Context, namespace, name string) error {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return err
+	}
+	return clientset.NetworkingV1().Ingresses(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+// Nodes lists the cluster's nodes.
+func (c *Client) Nodes(ctx context.Context) ([]corev1.Node, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	list, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// ServerVersion is the Kubernetes version the API server reports, e.g.
+// v1.30.0.
+func (c *Client) ServerVersion(ctx context.Context) (string, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return "", err
+	}
+	rest := clientset.Discovery().RESTClient()
+	if rest == nil {
+		// Fake clientsets have no REST client, only the version
+		info, err := clientset.Discovery().ServerVersion()
+		if err != nil {
+			return "", err
+		}
+		return info.GitVersion, nil
+	}
+	raw, err := rest.Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		return "", err
+	}
+	var version struct {
+		GitVersion string `json:"gitVersion"`
+	}
+	if err := json.Unmarshal(raw, &version); err != nil {
+		return "", fmt.Errorf("unexpected /version response: %v", err)
+	}
+	return version.GitVersion, nil
+}
+
+// NodeMetrics is the CPU and memory each node uses, as metrics-server
+// reports it under metrics.k8s.io. It's read directly so the metrics client
+// isn't a dependency; without metrics-server it fails.
+func (c *Client) NodeMetrics(ctx context.Context) (map[string]corev1.ResourceList, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	rest := clientset.Discovery().RESTClient()
+	if rest == nil {
+		return nil, fmt.Errorf("no metrics API")
+	}
+	raw, err := rest.Get().AbsPath("/apis/metrics.k8s.io/v1beta1/nodes").DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Usage corev1.ResourceList `json:"usage"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("unexpected node metrics: %v", err)
+	}
+	usage := make(map[string]corev1.ResourceList, len(list.Items))
+	for _, item := range list.Items {
+		usage[item.Metadata.Name] = item.Usage
+	}
+	return usage, nil
+}
+
+// ReviewAccess asks the API server whether the client's own credentials
+// may do what attributes describe.
+func (c *Client) ReviewAccess(ctx context.Context, attributes authorizationv1.ResourceAttributes) (authorizationv1.SubjectAccessReviewStatus, error) {
+	clientset, err := c.Clientset()
+	if err != nil {
+		return authorizationv1.SubjectAccessReviewStatus{}, err
+	}
+	review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attributes},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return authorizationv1.SubjectAccessReviewStatus{}, err
+	}
+	return review.Status, nil
+}
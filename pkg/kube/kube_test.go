@@ -0,0 +1,151 @@
+package kube_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/kube"
+	"github.com/anthony-gilbert/local-container-registry/pkg/testenv"
+)
+
+// webCluster is a cluster running two pods of the web deployment, and a pod
+// of another one.
+func webCluster() *kube.Client {
+	web := testenv.Deployment("default", "web", "localhost:5000/web:v1", 2)
+	api := testenv.Deployment("default", "api", "localhost:5000/api:v1", 1)
+	objects := append(testenv.PodsFor(web, 2), web, api)
+	return kube.New(testenv.FakeClientset(append(objects, testenv.PodsFor(api, 1)...)...))
+}
+
+func podNames(pods []kube.Pod) []string {
+	var names []string
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestPods(t *testing.T) {
+	client := webCluster()
+	ctx := context.Background()
+
+	pods, err := client.Pods(ctx, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := podNames(pods), []string{"api-0", "web-0", "web-1"}; !slices.Equal(got, want) {
+		t.Errorf("pods are %v, want %v", got, want)
+	}
+	if pods[0].Phase != "Running" {
+		t.Errorf("phase is %q, want Running", pods[0].Phase)
+	}
+
+	pods, err = client.DeploymentPods(ctx, "default", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := podNames(pods), []string{"web-0", "web-1"}; !slices.Equal(got, want) {
+		t.Errorf("pods of web are %v, want %v", got, want)
+	}
+
+	if _, err := client.DeploymentPods(ctx, "default", "missing"); err == nil {
+		t.Error("listed the pods of a deployment that doesn't exist")
+	}
+}
+
+func TestSetImage(t *testing.T) {
+	client := webCluster()
+	ctx := context.Background()
+
+	previous, err := client.SetImage(ctx, "default", "web", "", "localhost:5000/web:v2", corev1.PullAlways, map[string]string{"deployed-by": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous.Image != "localhost:5000/web:v1" {
+		t.Errorf("previous image is %q, want localhost:5000/web:v1", previous.Image)
+	}
+	deployment, err := client.Deployment(ctx, "default", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if container.Image != "localhost:5000/web:v2" || container.ImagePullPolicy != corev1.PullAlways {
+		t.Errorf("container is %s with %s, want localhost:5000/web:v2 with Always", container.Image, container.ImagePullPolicy)
+	}
+	if got := deployment.Spec.Template.Annotations["deployed-by"]; got != "test" {
+		t.Errorf("deployed-by annotation is %q, want test", got)
+	}
+
+	if _, err := client.SetImage(ctx, "default", "web", "sidecar", "busybox", "", nil); err == nil {
+		t.Error("set the image of a container the deployment doesn't have")
+	}
+}
+
+func TestRestart(t *testing.T) {
+	client := webCluster()
+	ctx := context.Background()
+
+	if err := client.Restart(ctx, "default", "web"); err != nil {
+		t.Fatal(err)
+	}
+	deployment, err := client.Deployment(ctx, "default", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Spec.Template.Annotations[kube.RestartedAtAnnotation] == "" {
+		t.Errorf("%s isn't set: %v", kube.RestartedAtAnnotation, deployment.Spec.Template.Annotations)
+	}
+	if image := deployment.Spec.Template.Spec.Containers[0].Image; image != "localhost:5000/web:v1" {
+		t.Errorf("image changed to %q", image)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	client := webCluster()
+	ctx := context.Background()
+
+	deleted, err := client.Delete(ctx, "default", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.Name != "web" || kube.Images(*deleted)[0] != "localhost:5000/web:v1" {
+		t.Errorf("got %s running %v, want web as it was", deleted.Name, kube.Images(*deleted))
+	}
+	if _, err := client.Deployment(ctx, "default", "web"); !apierrors.IsNotFound(err) {
+		t.Errorf("got %v getting web after deleting it, want NotFound", err)
+	}
+
+	// Re-creating what Delete returned undoes it
+	deleted.ResourceVersion = ""
+	if _, err := client.CreateDeployment(ctx, deleted); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnectIsLazy(t *testing.T) {
+	errNoKubeconfig := errors.New("no kubeconfig")
+	var connects int
+	client := kube.Connect(func() (kubernetes.Interface, error) {
+		connects++
+		return nil, errNoKubeconfig
+	})
+	if connects != 0 {
+		t.Fatal("Connect connected before the client was used")
+	}
+
+	for range 2 {
+		if _, err := client.Pods(context.Background(), "default"); !errors.Is(err, errNoKubeconfig) {
+			t.Errorf("got %v, want the connect error", err)
+		}
+	}
+	if connects != 1 {
+		t.Errorf("connected %d times, want once", connects)
+	}
+}
@@ -0,0 +1,89 @@
+package registry
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// bandwidthSlice is the most a limited transfer moves between waits, so the
+// rate stays smooth instead of alternating bursts and pauses.
+const bandwidthSlice = 32 << 10
+
+// BandwidthLimit caps the combined rate of the blob transfers sharing it,
+// downloads and uploads alike. Clients given the same limit share it.
+type BandwidthLimit struct {
+	mu             sync.Mutex
+	bytesPerSecond float64
+	paidUntil      time.Time // when the bytes moved so far fit in the limit
+}
+
+// NewBandwidthLimit returns a limit of bytesPerSecond, or nil (no limit)
+// when it isn't positive.
+func NewBandwidthLimit(bytesPerSecond int64) *BandwidthLimit {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &BandwidthLimit{bytesPerSecond: float64(bytesPerSecond)}
+}
+
+// wait blocks until n more bytes fit in the limit.
+func (l *BandwidthLimit) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.paidUntil.Before(now) {
+		l.paidUntil = now
+	}
+	l.paidUntil = l.paidUntil.Add(time.Duration(float64(n) / l.bytesPerSecond * float64(time.Second)))
+	delay := l.paidUntil.Sub(now)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// limitedReader reads no faster than its limit allows.
+type limitedReader struct {
+	ctx   context.Context
+	r     io.Reader
+	limit *BandwidthLimit
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > bandwidthSlice {
+		p = p[:bandwidthSlice]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limit.wait(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// limitReader applies the client's bandwidth limit to a transfer.
+func (c *Client) limitReader(ctx context.Context, r io.Reader) io.Reader {
+	if c.bandwidth == nil || r == nil {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, limit: c.bandwidth}
+}
+
+// limitBody applies the client's bandwidth limit to a response body.
+func (c *Client) limitBody(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+	if c.bandwidth == nil {
+		return body
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{c.limitReader(ctx, body), body}
+}
@@ -0,0 +1,187 @@
+package registry
+
+import (
+	"container/list"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Cache keeps manifests and config blobs keyed by digest. Content addressed
+// by digest never changes, so hits are served without touching the
+// registry; tag lookups remember the last ETag and are revalidated with
+// If-None-Match. A Cache is safe for concurrent use by several Clients.
+type Cache struct {
+	mu    sync.Mutex
+	dir   string
+	blobs map[string]*list.Element
+	// recent orders blobs from the most to the least recently used, for
+	// evicting the least recently used beyond maxMemory
+	recent *list.List
+	size   int
+	refs   map[string]CachedReference
+}
+
+// maxMemory is how many bytes of blobs a Cache keeps in memory; the disk
+// cache keeps the rest.
+const maxMemory = 64 << 20
+
+type cachedBlob struct {
+	digest  string
+	content []byte
+}
+
+// CachedReference is what is known about a tag (or digest) reference the
+// last time the registry answered for it.
+type CachedReference struct {
+	ETag      string `json:"etag"`
+	Digest    string `json:"digest"`
+	MediaType string `json:"mediaType"`
+}
+
+// NewCache keeps blobs in memory and, unless dir is empty, under dir, where
+// the references are kept too so they outlive the process.
+func NewCache(dir string) *Cache {
+	c := &Cache{
+		dir:    dir,
+		blobs:  make(map[string]*list.Element),
+		recent: list.New(),
+		refs:   make(map[string]CachedReference),
+	}
+	if dir != "" {
+		if data, err := os.ReadFile(filepath.Join(dir, "references.json")); err == nil {
+			json.Unmarshal(data, &c.refs)
+		}
+	}
+	return c
+}
+
+func (c *Cache) blobPath(digest string) string {
+	algorithm, hexPart, ok := strings.Cut(digest, ":")
+	if c.dir == "" || !ok || strings.ContainsAny(hexPart, `/\.`) {
+		return ""
+	}
+	return filepath.Join(c.dir, "blobs", algorithm, hexPart)
+}
+
+// GetBlob returns the content stored under digest.
+func (c *Cache) GetBlob(digest string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.blobs[digest]; ok {
+		c.recent.MoveToFront(element)
+		return element.Value.(*cachedBlob).content, true
+	}
+	path := c.blobPath(digest)
+	if path == "" {
+		return nil, false
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || !DigestMatches(digest, content) {
+		return nil, false
+	}
+	c.remember(digest, content)
+	return content, true
+}
+
+// remember keeps content in memory, evicting the least recently used blobs
+// beyond maxMemory. c.mu is held.
+func (c *Cache) remember(digest string, content []byte) {
+	if element, ok := c.blobs[digest]; ok {
+		c.recent.MoveToFront(element)
+		return
+	}
+	c.blobs[digest] = c.recent.PushFront(&cachedBlob{digest: digest, content: content})
+	c.size += len(content)
+	for c.size > maxMemory && c.recent.Len() > 1 {
+		oldest := c.recent.Remove(c.recent.Back()).(*cachedBlob)
+		delete(c.blobs, oldest.digest)
+		c.size -= len(oldest.content)
+	}
+}
+
+// PutBlob stores content under its digest. Content that does not hash to the
+// digest is dropped so a bad response can never poison the cache.
+func (c *Cache) PutBlob(digest string, content []byte) {
+	if !DigestMatches(digest, content) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remember(digest, content)
+	if path := c.blobPath(digest); path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			writeFileAtomic(path, content)
+		}
+	}
+}
+
+// GetReference returns what is known about a reference key, host/repo:tag
+// or host/repo@digest.
+func (c *Cache) GetReference(key string) (CachedReference, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ref, ok := c.refs[key]
+	return ref, ok
+}
+
+// PutReference remembers what the registry answered for a reference key.
+func (c *Cache) PutReference(key string, ref CachedReference) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.refs[key] == ref {
+		return
+	}
+	c.refs[key] = ref
+	if c.dir == "" {
+		return
+	}
+	if data, err := json.Marshal(c.refs); err == nil {
+		if err := os.MkdirAll(c.dir, 0755); err == nil {
+			writeFileAtomic(filepath.Join(c.dir, "references.json"), data)
+		}
+	}
+}
+
+// KnownDigests returns every manifest digest the cache has seen for a
+// repository, whether looked up by tag or by digest.
+func (c *Cache) KnownDigests(host, repository string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := host + "/" + repository
+	seen := make(map[string]bool)
+	var digests []string
+	for key, ref := range c.refs {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok || rest == "" || (rest[0] != ':' && rest[0] != '@') || ref.Digest == "" || seen[ref.Digest] {
+			continue
+		}
+		seen[ref.Digest] = true
+		digests = append(digests, ref.Digest)
+	}
+	return digests
+}
+
+func writeFileAtomic(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
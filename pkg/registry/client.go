@@ -0,0 +1,628 @@
+// Package registry is a client for OCI distribution registries: listing
+// repositories and tags, reading, copying and deleting manifests, and
+// resumable blob uploads. It understands the Harbor and zot APIs beyond the
+// distribution spec, works around registries' Quirks, and keeps manifests
+// and config blobs in a content-addressed Cache.
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds each registry request, except for blob transfers,
+// when Options.Timeout is zero.
+const DefaultTimeout = 30 * time.Second
+
+// Options configures a Client. The zero value is usable.
+type Options struct {
+	// HTTPClient makes the requests, http.DefaultClient when nil. Callers
+	// add retries and tracing through its transport.
+	HTTPClient *http.Client
+	// Timeout bounds each request but blob transfers, whose duration
+	// scales with size and is bounded only by the caller's context.
+	Timeout time.Duration
+	// Cache keeps manifests and configs; an in-memory one when nil.
+	Cache *Cache
+	// Bandwidth caps the rate of blob transfers; unlimited when nil.
+	Bandwidth *BandwidthLimit
+	// Quirks are the registry's deviations from the distribution spec;
+	// detected when nil.
+	Quirks *Quirks
+}
+
+// Client talks to one registry.
+type Client struct {
+	host       string
+	baseURL    string
+	httpClient *http.Client
+	timeout    time.Duration
+	cache      *Cache
+	bandwidth  *BandwidthLimit
+	quirks     *Quirks
+}
+
+// NewClient accepts either a bare host:port (plain HTTP, like a local
+// registry) or a URL with an explicit http:// or https:// scheme.
+func NewClient(host string, options Options) *Client {
+	baseURL := "http://" + host
+	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
+		baseURL = host
+		host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	}
+	c := &Client{
+		host:       strings.TrimSuffix(host, "/"),
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: options.HTTPClient,
+		timeout:    options.Timeout,
+		cache:      options.Cache,
+		bandwidth:  options.Bandwidth,
+		quirks:     options.Quirks,
+	}
+	if c.httpClient == nil {
+		c.httpClient = http.DefaultClient
+	}
+	if c.timeout <= 0 {
+		c.timeout = DefaultTimeout
+	}
+	if c.cache == nil {
+		c.cache = NewCache("")
+	}
+	return c
+}
+
+// Host is the registry's host:port, as image references name it.
+func (c *Client) Host() string {
+	return c.host
+}
+
+// BaseURL is the registry's URL with its scheme.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// newRequest builds a request bounded by the registry timeout. Blob transfers
+// use http.NewRequestWithContext directly since their duration scales with
+// size and is bounded only by the caller's context.
+func (c *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return req, cancel, nil
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// GetJSON decodes the response to a GET of path, relative to the registry's
+// URL, into v. It reaches APIs beyond the distribution spec, like Harbor's.
+func (c *Client) GetJSON(ctx context.Context, path string, v interface{}) error {
+	req, cancel, err := c.newRequest(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// PostJSON posts v, encoded as JSON, to path, relative to the registry's
+// URL, for APIs beyond the distribution spec.
+func (c *Client) PostJSON(ctx context.Context, path string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, cancel, err := c.newRequest(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Ping checks the /v2/ API version endpoint every distribution registry serves.
+func (c *Client) Ping(ctx context.Context) error {
+	req, cancel, err := c.newRequest(ctx, http.MethodGet, c.baseURL+"/v2/", nil)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// getPage is the body of a GET of path, relative to the registry's URL,
+// and its Link header.
+func (c *Client) getPage(ctx context.Context, path string) ([]byte, string, error) {
+	req, cancel, err := c.newRequest(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cancel()
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return body, resp.Header.Get("Link"), err
+}
+
+// Catalog lists the repositories /v2/_catalog reports, page by page; see
+// Repositories for registries restricting it.
+func (c *Client) Catalog(ctx context.Context) ([]string, error) {
+	if c.Quirks(ctx).NoCatalog {
+		return nil, fmt.Errorf("registry %s has no /v2/_catalog to list its repositories, name them instead: %w", c.host, ErrUnsupported)
+	}
+	var repositories []string
+	err := c.getPaged(ctx, "/v2/_catalog", func(page []byte) error {
+		var catalog struct {
+			Repositories []string `json:"repositories"`
+		}
+		err := json.Unmarshal(page, &catalog)
+		repositories = append(repositories, catalog.Repositories...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repositories, nil
+}
+
+// Tags lists the tags of a repository, page by page.
+func (c *Client) Tags(ctx context.Context, repository string) ([]string, error) {
+	var tags []string
+	err := c.getPaged(ctx, fmt.Sprintf("/v2/%s/tags/list", repository), func(page []byte) error {
+		var repoTags struct {
+			Name string   `json:"name"`
+			Tags []string `json:"tags"`
+		}
+		err := json.Unmarshal(page, &repoTags)
+		tags = append(tags, repoTags.Tags...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// Manifest returns the raw manifest bytes exactly as stored so they can be
+// re-pushed without changing the digest, and their media type. Digest
+// references are served from the cache; tag references are revalidated with
+// the ETag from the last fetch.
+func (c *Client) Manifest(ctx context.Context, repository, reference string) ([]byte, string, error) {
+	key := c.referenceKey(repository, reference)
+	cached, haveCached := c.cache.GetReference(key)
+	if IsDigestReference(reference) {
+		if body, ok := c.cache.GetBlob(reference); ok && cached.MediaType != "" {
+			return body, cached.MediaType, nil
+		}
+	}
+
+	body, mediaType, notModified, err := c.fetchManifest(ctx, repository, reference, cached.ETag)
+	if err != nil {
+		return nil, "", err
+	}
+	if notModified && haveCached {
+		if body, ok := c.cache.GetBlob(cached.Digest); ok {
+			return body, cached.MediaType, nil
+		}
+		// The reference is known but the content was evicted; fetch it again
+		body, mediaType, _, err = c.fetchManifest(ctx, repository, reference, "")
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return body, mediaType, nil
+}
+
+func (c *Client) fetchManifest(ctx context.Context, repository, reference, etag string) ([]byte, string, bool, error) {
+	req, cancel, err := c.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL, repository, reference), nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer cancel()
+	req.Header.Set("Accept", strings.Join(ManifestMediaTypes, ", "))
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, "", true, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", false, err
+	}
+	mediaType := resp.Header.Get("Content-Type")
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if !DigestMatches(digest, body) {
+		digest = ComputeDigest(body)
+	}
+	c.cache.PutBlob(digest, body)
+	c.cache.PutReference(c.referenceKey(repository, reference), CachedReference{
+		ETag:      resp.Header.Get("ETag"),
+		Digest:    digest,
+		MediaType: mediaType,
+	})
+	c.cache.PutReference(c.referenceKey(repository, digest), CachedReference{
+		Digest:    digest,
+		MediaType: mediaType,
+	})
+	return body, mediaType, false, nil
+}
+
+// Blob returns a small blob (such as an image config) in full, using the
+// cache since blobs are immutable by digest.
+func (c *Client) Blob(ctx context.Context, repository, digest string) ([]byte, error) {
+	if content, ok := c.cache.GetBlob(digest); ok {
+		return content, nil
+	}
+
+	req, cancel, err := c.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/v2/%s/blobs/%s", c.baseURL, repository, digest), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.PutBlob(digest, content)
+	return content, nil
+}
+
+func (c *Client) referenceKey(repository, reference string) string {
+	if IsDigestReference(reference) {
+		return fmt.Sprintf("%s/%s@%s", c.host, repository, reference)
+	}
+	return fmt.Sprintf("%s/%s:%s", c.host, repository, reference)
+}
+
+// KnownDigests returns every manifest digest the cache has seen for a
+// repository of this registry, whether looked up by tag or by digest.
+func (c *Client) KnownDigests(repository string) []string {
+	return c.cache.KnownDigests(c.host, repository)
+}
+
+// PutManifest stores a manifest under a tag or its digest.
+func (c *Client) PutManifest(ctx context.Context, repository, reference, mediaType string, body []byte) error {
+	req, cancel, err := c.newRequest(ctx, http.MethodPut, fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL, repository, reference), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	req.Header.Set("Content-Type", mediaType)
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ManifestDigest resolves a tag to the digest of the manifest it points at.
+func (c *Client) ManifestDigest(ctx context.Context, repository, reference string) (string, error) {
+	req, cancel, err := c.newRequest(ctx, http.MethodHead, fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL, repository, reference), nil)
+	if err != nil {
+		return "", err
+	}
+	defer cancel()
+	req.Header.Set("Accept", strings.Join(ManifestMediaTypes, ", "))
+	resp, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("registry %s returned no digest for %s:%s", c.host, repository, reference)
+	}
+	return digest, nil
+}
+
+// DeleteManifest removes a manifest by digest, which untags every tag that
+// points at it; registries with the UntagBeforeDelete quirk have those tags
+// deleted first. The registry must run with REGISTRY_STORAGE_DELETE_ENABLED.
+func (c *Client) DeleteManifest(ctx context.Context, repository, digest string) error {
+	if err := c.deleteUnsupported(); err != nil {
+		return err
+	}
+	if c.Quirks(ctx).UntagBeforeDelete {
+		tags, err := c.Tags(ctx, repository)
+		if err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			if tagDigest, err := c.ManifestDigest(ctx, repository, tag); err == nil && tagDigest == digest {
+				if err := c.deleteReference(ctx, repository, tag); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return c.deleteReference(ctx, repository, digest)
+}
+
+// deleteReference deletes a manifest by digest, or a tag.
+func (c *Client) deleteReference(ctx context.Context, repository, reference string) error {
+	req, cancel, err := c.newRequest(ctx, http.MethodDelete, fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL, repository, reference), nil)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	resp, err := c.do(req)
+	if err != nil {
+		if unsupportedStatus(err) {
+			c.learnNoDelete()
+			return c.noDeleteError()
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ManifestExists reports whether the repository has a manifest by digest.
+func (c *Client) ManifestExists(ctx context.Context, repository, digest string) (bool, error) {
+	req, cancel, err := c.newRequest(ctx, http.MethodHead, fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL, repository, digest), nil)
+	if err != nil {
+		return false, err
+	}
+	defer cancel()
+	req.Header.Set("Accept", strings.Join(ManifestMediaTypes, ", "))
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return true, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("HEAD manifest %s: %s", digest, resp.Status)
+	}
+}
+
+// Referrers lists the artifacts (signatures, SBOMs, ...) whose subject is the
+// given manifest. Registries without the OCI referrers API return nothing.
+func (c *Client) Referrers(ctx context.Context, repository, digest string) ([]Descriptor, error) {
+	req, cancel, err := c.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/v2/%s/referrers/%s", c.baseURL, repository, digest), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	req.Header.Set("Accept", "application/vnd.oci.image.index.v1+json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET referrers %s: %s", digest, resp.Status)
+	}
+	var index Manifest
+	if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
+		return nil, err
+	}
+	return index.Manifests, nil
+}
+
+// BlobExists reports whether the repository has a blob by digest.
+func (c *Client) BlobExists(ctx context.Context, repository, digest string) (bool, error) {
+	req, cancel, err := c.newRequest(ctx, http.MethodHead, fmt.Sprintf("%s/v2/%s/blobs/%s", c.baseURL, repository, digest), nil)
+	if err != nil {
+		return false, err
+	}
+	defer cancel()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return true, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("HEAD blob %s: %s", digest, resp.Status)
+	}
+}
+
+// GetBlob streams a blob and reports its size, -1 when unknown.
+func (c *Client) GetBlob(ctx context.Context, repository, digest string) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/v2/%s/blobs/%s", c.baseURL, repository, digest), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	return c.limitBody(ctx, resp.Body), resp.ContentLength, nil
+}
+
+// PutBlob performs a monolithic upload: open an upload session, then PUT the
+// whole blob to the returned location with the digest attached.
+func (c *Client) PutBlob(ctx context.Context, repository, digest string, content io.Reader, size int64) error {
+	location, err := c.StartUpload(ctx, repository)
+	if err != nil {
+		return err
+	}
+	return c.CompleteUpload(ctx, location, digest, content, size)
+}
+
+// GetBlobRange streams a blob starting at offset. Registries that ignore the
+// Range header send the whole blob, so the skipped prefix is discarded here.
+func (c *Client) GetBlobRange(ctx context.Context, repository, digest string, offset int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/v2/%s/blobs/%s", c.baseURL, repository, digest), nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+	return c.limitBody(ctx, resp.Body), nil
+}
+
+// StartUpload opens an upload session and returns its location.
+func (c *Client) StartUpload(ctx context.Context, repository string) (string, error) {
+	req, cancel, err := c.newRequest(ctx, http.MethodPost, fmt.Sprintf("%s/v2/%s/blobs/uploads/", c.baseURL, repository), nil)
+	if err != nil {
+		return "", err
+	}
+	defer cancel()
+	resp, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return c.uploadLocation(resp)
+}
+
+// UploadOffset asks the registry how much of an upload session it has
+// received, so an interrupted upload can continue where it stopped.
+func (c *Client) UploadOffset(ctx context.Context, location string) (int64, error) {
+	req, cancel, err := c.newRequest(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer cancel()
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return parseUploadRange(resp.Header.Get("Range")), nil
+}
+
+// PatchUpload appends a chunk at offset and returns the session's next
+// location, which registries may change after every chunk.
+func (c *Client) PatchUpload(ctx context.Context, location string, chunk []byte, offset int64) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, location, c.limitReader(ctx, bytes.NewReader(chunk)))
+	if err != nil {
+		return "", err
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(c.limitReader(ctx, bytes.NewReader(chunk))), nil
+	}
+	req.ContentLength = int64(len(chunk))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("%d-%d", offset, offset+int64(len(chunk))-1))
+	resp, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return c.uploadLocation(resp)
+}
+
+// CompleteUpload closes an upload session, sending any remaining content
+// along with the digest the registry verifies it against.
+func (c *Client) CompleteUpload(ctx context.Context, location, digest string, content io.Reader, size int64) error {
+	separator := "?"
+	if strings.Contains(location, "?") {
+		separator = "&"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, location+separator+"digest="+digest, c.limitReader(ctx, content))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *Client) uploadLocation(resp *http.Response) (string, error) {
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("registry %s returned no upload location", c.host)
+	}
+	if strings.HasPrefix(location, "/") {
+		location = c.baseURL + location
+	}
+	return location, nil
+}
+
+// parseUploadRange turns a "0-1023" Range header into the next offset (1024).
+// Distribution reports an empty session as "0-0", so that restarts at zero.
+func parseUploadRange(value string) int64 {
+	_, end, ok := strings.Cut(strings.TrimPrefix(value, "bytes="), "-")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.ParseInt(end, 10, 64)
+	if err != nil || n == 0 {
+		return 0
+	}
+	return n + 1
+}
@@ -0,0 +1,149 @@
+package registry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+	"github.com/anthony-gilbert/local-container-registry/pkg/testenv"
+)
+
+// newRegistry starts a fake registry for the test, with quirks set on it
+// before the client's first request, and a client detecting them.
+func newRegistry(t *testing.T, quirks func(*testenv.Registry)) (*testenv.Registry, *registry.Client) {
+	t.Helper()
+	fake := testenv.NewRegistry()
+	t.Cleanup(fake.Close)
+	if quirks != nil {
+		quirks(fake)
+	}
+	return fake, registry.NewClient(fake.Host(), registry.Options{})
+}
+
+func TestCatalogFollowsPages(t *testing.T) {
+	fake, client := newRegistry(t, nil)
+	// One more than a page, so the Link header has to be followed
+	var want []string
+	for i := 0; i < 1001; i++ {
+		repository := fmt.Sprintf("app-%04d", i)
+		fake.PushImage(repository, "v1", nil, []byte("layer"))
+		want = append(want, repository)
+	}
+
+	got, err := client.Catalog(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %d repositories, want %d", len(got), len(want))
+	}
+	var pages int
+	for _, request := range fake.Requests() {
+		if strings.HasPrefix(request, "GET /v2/_catalog?") && strings.Contains(request, "n=1000") {
+			pages++
+		}
+	}
+	if pages != 2 {
+		t.Errorf("catalog fetched in %d pages, want 2: %v", pages, fake.Requests())
+	}
+}
+
+func TestTags(t *testing.T) {
+	fake, client := newRegistry(t, nil)
+	fake.PushImage("web", "v1", nil, []byte("one"))
+	fake.PushImage("web", "v2", nil, []byte("two"))
+	fake.PushImage("web", "latest", nil, []byte("two"))
+
+	got, err := client.Tags(context.Background(), "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(got)
+	if want := []string{"latest", "v1", "v2"}; !slices.Equal(got, want) {
+		t.Errorf("got tags %v, want %v", got, want)
+	}
+	if _, err := client.Tags(context.Background(), "missing"); err == nil {
+		t.Error("listed the tags of a repository the registry doesn't know")
+	}
+}
+
+func TestCatalogUnsupportedWithoutCatalog(t *testing.T) {
+	fake, client := newRegistry(t, func(r *testenv.Registry) { r.NoCatalog = true })
+	fake.PushImage("web", "v1", nil, []byte("layer"))
+
+	if !client.Quirks(context.Background()).NoCatalog {
+		t.Fatal("NoCatalog wasn't detected")
+	}
+	_, err := client.Catalog(context.Background())
+	if !errors.Is(err, registry.ErrUnsupported) {
+		t.Fatalf("got %v, want an error wrapping ErrUnsupported", err)
+	}
+	// Named repositories still work
+	if tags, err := client.Tags(context.Background(), "web"); err != nil || !slices.Equal(tags, []string{"v1"}) {
+		t.Errorf("got tags %v, %v, want [v1]", tags, err)
+	}
+}
+
+func TestDeleteManifest(t *testing.T) {
+	fake, client := newRegistry(t, nil)
+	digest := fake.PushImage("web", "v1", nil, []byte("layer"))
+	fake.PushImage("web", "v2", nil, []byte("other"))
+
+	if err := client.DeleteManifest(context.Background(), "web", digest); err != nil {
+		t.Fatal(err)
+	}
+	if tags := fake.Tags("web"); !slices.Equal(tags, []string{"v2"}) {
+		t.Errorf("tags are %v after deleting v1, want [v2]", tags)
+	}
+}
+
+func TestDeleteManifestUnsupportedWithoutDelete(t *testing.T) {
+	fake, client := newRegistry(t, func(r *testenv.Registry) { r.NoDelete = true })
+	digest := fake.PushImage("web", "v1", nil, []byte("layer"))
+
+	err := client.DeleteManifest(context.Background(), "web", digest)
+	if !errors.Is(err, registry.ErrUnsupported) {
+		t.Fatalf("got %v, want an error wrapping ErrUnsupported", err)
+	}
+	if !strings.Contains(err.Error(), "REGISTRY_STORAGE_DELETE_ENABLED") {
+		t.Errorf("%q doesn't say how to enable deletes", err)
+	}
+	if tags := fake.Tags("web"); !slices.Equal(tags, []string{"v1"}) {
+		t.Errorf("tags are %v, want [v1] kept", tags)
+	}
+
+	// Learned: the next delete fails without asking the registry
+	requests := len(fake.Requests())
+	if err := client.DeleteManifest(context.Background(), "web", digest); !errors.Is(err, registry.ErrUnsupported) {
+		t.Fatalf("got %v on the second delete, want ErrUnsupported", err)
+	}
+	if !client.Quirks(context.Background()).NoDelete {
+		t.Error("NoDelete wasn't remembered")
+	}
+	if got := fake.Requests()[requests:]; len(got) != 0 {
+		t.Errorf("the second delete sent %v", got)
+	}
+}
+
+func TestConfiguredQuirksSkipDetection(t *testing.T) {
+	fake, _ := newRegistry(t, nil)
+	quirks, err := registry.ParseQuirks("no-catalog+no-delete")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := registry.NewClient(fake.Host(), registry.Options{Quirks: &quirks})
+
+	if _, err := client.Catalog(context.Background()); !errors.Is(err, registry.ErrUnsupported) {
+		t.Errorf("Catalog: got %v, want ErrUnsupported", err)
+	}
+	if err := client.DeleteManifest(context.Background(), "web", testenv.Digest([]byte("x"))); !errors.Is(err, registry.ErrUnsupported) {
+		t.Errorf("DeleteManifest: got %v, want ErrUnsupported", err)
+	}
+	if requests := fake.Requests(); len(requests) != 0 {
+		t.Errorf("configured quirks sent %v", requests)
+	}
+}
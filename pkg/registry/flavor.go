@@ -0,0 +1,252 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Registry products with APIs beyond the distribution spec. Everything else
+// is treated as a plain distribution registry.
+const (
+	FlavorDistribution = "distribution"
+	FlavorHarbor       = "harbor"
+	FlavorZot          = "zot"
+)
+
+// flavors caches the flavor per registry URL for the process.
+var flavors sync.Map
+
+// Flavor detects which product serves the registry: Harbor answers
+// /api/version, zot lists its extensions at /v2/_zot/ext/discover.
+func (c *Client) Flavor(ctx context.Context) string {
+	if flavor, ok := flavors.Load(c.baseURL); ok {
+		return flavor.(string)
+	}
+	flavor := FlavorDistribution
+	var version struct {
+		Version string `json:"version"`
+	}
+	var discover struct {
+		Extensions []struct {
+			Name string `json:"name"`
+		} `json:"extensions"`
+	}
+	if err := c.GetJSON(ctx, "/api/version", &version); err == nil && version.Version != "" {
+		flavor = FlavorHarbor
+	} else if err := c.GetJSON(ctx, "/v2/_zot/ext/discover", &discover); err == nil {
+		flavor = FlavorZot
+	} else if ctx.Err() != nil {
+		// Don't remember a guess made while cancelled
+		return FlavorDistribution
+	}
+	flavors.Store(c.baseURL, flavor)
+	return flavor
+}
+
+// CachedFlavor is the flavor Flavor detected earlier for the registry at
+// baseURL, without a request; "" when it hasn't.
+func CachedFlavor(baseURL string) string {
+	if flavor, ok := flavors.Load(baseURL); ok {
+		return flavor.(string)
+	}
+	return ""
+}
+
+// HarborProject is a project as Harbor's API lists it.
+type HarborProject struct {
+	ProjectID int    `json:"project_id"`
+	Name      string `json:"name"`
+	RepoCount int    `json:"repo_count"`
+	Metadata  struct {
+		Public      string `json:"public"`
+		RetentionID string `json:"retention_id"`
+	} `json:"metadata"`
+}
+
+// HarborProjectSummary is a Harbor project's repository count and storage
+// quota.
+type HarborProjectSummary struct {
+	RepoCount int `json:"repo_count"`
+	Quota     struct {
+		Hard struct {
+			Storage int64 `json:"storage"`
+		} `json:"hard"`
+		Used struct {
+			Storage int64 `json:"storage"`
+		} `json:"used"`
+	} `json:"quota"`
+}
+
+// HarborRetention is a Harbor project's tag retention policy.
+type HarborRetention struct {
+	Rules []struct {
+		Disabled     bool           `json:"disabled"`
+		Action       string         `json:"action"`
+		Template     string         `json:"template"`
+		Params       map[string]any `json:"params"`
+		TagSelectors []struct {
+			Pattern string `json:"pattern"`
+		} `json:"tag_selectors"`
+	} `json:"rules"`
+	Trigger struct {
+		Kind     string `json:"kind"`
+		Settings struct {
+			Cron string `json:"cron"`
+		} `json:"settings"`
+	} `json:"trigger"`
+}
+
+// harborPageSize is the largest page Harbor's API hands out.
+const harborPageSize = 100
+
+// HarborProjects lists the Harbor projects the caller may see.
+func (c *Client) HarborProjects(ctx context.Context) ([]HarborProject, error) {
+	var projects []HarborProject
+	for page := 1; ; page++ {
+		var batch []HarborProject
+		if err := c.GetJSON(ctx, fmt.Sprintf("/api/v2.0/projects?page=%d&page_size=%d", page, harborPageSize), &batch); err != nil {
+			return nil, err
+		}
+		projects = append(projects, batch...)
+		if len(batch) < harborPageSize {
+			return projects, nil
+		}
+	}
+}
+
+// HarborProjectSummary is the summary of a Harbor project.
+func (c *Client) HarborProjectSummary(ctx context.Context, project string) (HarborProjectSummary, error) {
+	var summary HarborProjectSummary
+	err := c.GetJSON(ctx, fmt.Sprintf("/api/v2.0/projects/%s/summary", url.PathEscape(project)), &summary)
+	return summary, err
+}
+
+// HarborRetention is the retention policy with this id.
+func (c *Client) HarborRetention(ctx context.Context, id string) (HarborRetention, error) {
+	var policy HarborRetention
+	err := c.GetJSON(ctx, "/api/v2.0/retentions/"+url.PathEscape(id), &policy)
+	return policy, err
+}
+
+// HarborScan asks Harbor's scanner to scan an image again, e.g. after its
+// vulnerability database was updated. The result shows in ScanSummary once
+// the scan is done.
+func (c *Client) HarborScan(ctx context.Context, repository, reference string) error {
+	project, name, ok := strings.Cut(repository, "/")
+	if !ok {
+		return fmt.Errorf("%s is not in a Harbor project", repository)
+	}
+	path := fmt.Sprintf("/api/v2.0/projects/%s/repositories/%s/artifacts/%s/scan",
+		url.PathEscape(project), url.PathEscape(url.PathEscape(name)), url.PathEscape(reference))
+	return c.PostJSON(ctx, path, struct{}{})
+}
+
+// HarborGarbageCollect starts Harbor's garbage collection, deleting the
+// blobs no manifest references and manifests without a tag.
+func (c *Client) HarborGarbageCollect(ctx context.Context) error {
+	request := map[string]any{
+		"schedule":   map[string]string{"type": "Manual"},
+		"parameters": map[string]bool{"delete_untagged": true},
+	}
+	return c.PostJSON(ctx, "/api/v2.0/system/gc/schedule", request)
+}
+
+// Repositories lists the registry's repositories. Harbor restricts
+// /v2/_catalog to administrators, so there the projects API, which lists
+// whatever the caller may see, is used instead.
+func (c *Client) Repositories(ctx context.Context) ([]string, error) {
+	if c.Flavor(ctx) != FlavorHarbor {
+		return c.Catalog(ctx)
+	}
+	projects, err := c.HarborProjects(ctx)
+	if err != nil {
+		return c.Catalog(ctx)
+	}
+	var repositories []string
+	for _, project := range projects {
+		for page := 1; ; page++ {
+			var batch []struct {
+				Name string `json:"name"` // includes the project, e.g. library/web
+			}
+			path := fmt.Sprintf("/api/v2.0/projects/%s/repositories?page=%d&page_size=%d", url.PathEscape(project.Name), page, harborPageSize)
+			if err := c.GetJSON(ctx, path, &batch); err != nil {
+				return nil, err
+			}
+			for _, repository := range batch {
+				repositories = append(repositories, repository.Name)
+			}
+			if len(batch) < harborPageSize {
+				break
+			}
+		}
+	}
+	return repositories, nil
+}
+
+// ScanSummary is the vulnerability scan result of an image as Harbor's
+// scanner or zot's CVE search reports it, e.g. "High (12)", empty when the
+// registry doesn't scan.
+func (c *Client) ScanSummary(ctx context.Context, repository, reference string) string {
+	switch c.Flavor(ctx) {
+	case FlavorHarbor:
+		project, name, ok := strings.Cut(repository, "/")
+		if !ok {
+			return ""
+		}
+		var artifact struct {
+			ScanOverview map[string]struct {
+				ScanStatus string `json:"scan_status"`
+				Severity   string `json:"severity"`
+				Summary    struct {
+					Total int `json:"total"`
+				} `json:"summary"`
+			} `json:"scan_overview"`
+		}
+		// Harbor wants slashes in repository names encoded twice
+		path := fmt.Sprintf("/api/v2.0/projects/%s/repositories/%s/artifacts/%s?with_scan_overview=true",
+			url.PathEscape(project), url.PathEscape(url.PathEscape(name)), url.PathEscape(reference))
+		if err := c.GetJSON(ctx, path, &artifact); err != nil {
+			return ""
+		}
+		for _, report := range artifact.ScanOverview {
+			if report.ScanStatus != "Success" {
+				return report.ScanStatus
+			}
+			if report.Summary.Total == 0 {
+				return "Clean"
+			}
+			return fmt.Sprintf("%s (%d)", report.Severity, report.Summary.Total)
+		}
+		return "Not scanned"
+
+	case FlavorZot:
+		var result struct {
+			Data struct {
+				Image struct {
+					Vulnerabilities struct {
+						MaxSeverity string `json:"MaxSeverity"`
+						Count       int    `json:"Count"`
+					} `json:"Vulnerabilities"`
+				} `json:"Image"`
+			} `json:"data"`
+		}
+		query := fmt.Sprintf(`{Image(image:"%s:%s"){Vulnerabilities{MaxSeverity Count}}}`, repository, reference)
+		if err := c.GetJSON(ctx, "/v2/_zot/ext/search?query="+url.QueryEscape(query), &result); err != nil {
+			return ""
+		}
+		vulnerabilities := result.Data.Image.Vulnerabilities
+		if vulnerabilities.Count == 0 {
+			return "Clean"
+		}
+		// zot reports HIGH where Harbor says High
+		severity := strings.ToLower(vulnerabilities.MaxSeverity)
+		if severity != "" {
+			severity = strings.ToUpper(severity[:1]) + severity[1:]
+		}
+		return fmt.Sprintf("%s (%d)", severity, vulnerabilities.Count)
+	}
+	return ""
+}
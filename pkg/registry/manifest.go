@@ -0,0 +1,103 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// ManifestMediaTypes are the manifest media types the client asks for.
+// Listing all of them lets the registry hand back manifest lists and OCI
+// indexes unchanged.
+var ManifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// Descriptor points at a blob or manifest by digest.
+type Descriptor struct {
+	MediaType string `json:"mediaType"`
+	Size      int64  `json:"size"`
+	Digest    string `json:"digest"`
+}
+
+// Manifest covers both single-image manifests and manifest lists so
+// callers can walk every blob and child manifest a tag depends on.
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+	Manifests     []Descriptor `json:"manifests"`
+}
+
+// ManifestSize is the size of an image in the registry: its config plus its
+// layers, compressed.
+func ManifestSize(body []byte) (int64, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return 0, err
+	}
+	totalSize := manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		totalSize += layer.Size
+	}
+	return totalSize, nil
+}
+
+// IsDigestReference reports whether a reference is a digest rather than a
+// tag; tags can't contain a colon.
+func IsDigestReference(reference string) bool {
+	return strings.Contains(reference, ":")
+}
+
+// DigestMatches reports whether content hashes to a sha256 digest.
+func DigestMatches(digest string, content []byte) bool {
+	algorithm, expected, ok := strings.Cut(digest, ":")
+	if !ok || algorithm != "sha256" {
+		return false
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]) == expected
+}
+
+// ComputeDigest is the sha256 digest of content.
+func ComputeDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ReferenceHost is the registry host an image reference names, or "" for
+// references like "nginx" or "library/nginx" that Docker resolves against
+// Docker Hub.
+func ReferenceHost(image string) string {
+	host, rest, ok := strings.Cut(image, "/")
+	if !ok || rest == "" || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		return ""
+	}
+	return host
+}
+
+// SplitReference splits an image reference into its registry host,
+// defaultHost when it names none, its repository and its tag or digest,
+// "latest" when it has neither.
+func SplitReference(ref, defaultHost string) (host, repository, reference string) {
+	host = ReferenceHost(ref)
+	name := ref
+	if host == "" {
+		host = defaultHost
+	} else {
+		name = strings.TrimPrefix(ref, host+"/")
+	}
+	if repository, digest, ok := strings.Cut(name, "@"); ok {
+		return host, repository, digest
+	}
+	// A colon after the last slash separates the tag
+	if separator := strings.LastIndex(name, ":"); separator > strings.LastIndex(name, "/") {
+		return host, name[:separator], name[separator+1:]
+	}
+	return host, name, "latest"
+}
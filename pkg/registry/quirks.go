@@ -0,0 +1,284 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Quirks are the ways a registry strays from the distribution spec. The
+// client works around those it can, and fails the features it can't serve
+// with an error wrapping ErrUnsupported that says why.
+type Quirks struct {
+	// NoCatalog: /v2/_catalog isn't served, as with ECR and most GCR
+	// emulators, so repositories have to be named.
+	NoCatalog bool
+	// NoPagination: ?n= is rejected or ignored, so lists are fetched in one
+	// request and Link headers aren't followed.
+	NoPagination bool
+	// NoDelete: manifests can't be deleted through the API, e.g. when
+	// distribution runs without REGISTRY_STORAGE_DELETE_ENABLED.
+	NoDelete bool
+	// UntagBeforeDelete: a manifest is only deleted once none of its tags
+	// point at it, as with GCR and Artifact Registry.
+	UntagBeforeDelete bool
+}
+
+// ErrUnsupported is wrapped by the errors of features a registry's quirks
+// rule out.
+var ErrUnsupported = errors.New("not supported by this registry")
+
+// QuirkNames are the names ParseQuirks accepts.
+var QuirkNames = []string{"no-catalog", "no-pagination", "no-delete", "untag-before-delete"}
+
+// ParseQuirks reads a '+'-separated list of QuirkNames, e.g.
+// "no-catalog+no-delete"; "spec" is a registry without any.
+func ParseQuirks(text string) (Quirks, error) {
+	var quirks Quirks
+	if text == "spec" {
+		return quirks, nil
+	}
+	for _, name := range strings.Split(text, "+") {
+		switch strings.TrimSpace(name) {
+		case "no-catalog":
+			quirks.NoCatalog = true
+		case "no-pagination":
+			quirks.NoPagination = true
+		case "no-delete":
+			quirks.NoDelete = true
+		case "untag-before-delete":
+			quirks.UntagBeforeDelete = true
+		default:
+			return Quirks{}, fmt.Errorf("unknown registry quirk %q; use spec or %s", name, strings.Join(QuirkNames, ", "))
+		}
+	}
+	return quirks, nil
+}
+
+// String names the quirks the way ParseQuirks reads them.
+func (q Quirks) String() string {
+	var names []string
+	for i, set := range []bool{q.NoCatalog, q.NoPagination, q.NoDelete, q.UntagBeforeDelete} {
+		if set {
+			names = append(names, QuirkNames[i])
+		}
+	}
+	if len(names) == 0 {
+		return "spec"
+	}
+	return strings.Join(names, "+")
+}
+
+// Explain describes what each quirk disables or changes.
+func (q Quirks) Explain() []string {
+	var lines []string
+	if q.NoCatalog {
+		lines = append(lines, "no /v2/_catalog: repositories can't be listed, only named")
+	}
+	if q.NoPagination {
+		lines = append(lines, "no pagination: tags and repositories come in one request")
+	}
+	if q.NoDelete {
+		lines = append(lines, "no manifest deletes: deleting and pruning images is disabled")
+	}
+	if q.UntagBeforeDelete {
+		lines = append(lines, "untag before delete: every tag of a manifest is deleted before it")
+	}
+	return lines
+}
+
+// registryQuirks are the quirks detected per registry URL for the process.
+type registryQuirks struct {
+	mu       sync.Mutex
+	listing  bool // NoCatalog and NoPagination are known
+	deleting bool // NoDelete is known
+	quirks   Quirks
+}
+
+var detectedQuirks sync.Map
+
+// untagBeforeDeleteHosts are the registries known to keep tagged manifests
+// from being deleted.
+var untagBeforeDeleteHosts = regexp.MustCompile(`(^|\.)(gcr\.io|pkg\.dev)$`)
+
+// probeDigest is a digest no manifest has, deleted to learn whether deletes
+// are allowed without deleting anything.
+const probeDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+
+func (c *Client) detected() *registryQuirks {
+	state, _ := detectedQuirks.LoadOrStore(c.baseURL, &registryQuirks{})
+	return state.(*registryQuirks)
+}
+
+// Quirks are the registry's quirks: those Options.Quirks names, or else
+// those detected, which costs a request or two the first time. Whether
+// deletes are allowed is only known once something was deleted or
+// ProbeDelete ran.
+func (c *Client) Quirks(ctx context.Context) Quirks {
+	if c.quirks != nil {
+		return *c.quirks
+	}
+	state := c.detected()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if !state.listing {
+		if c.detectListing(ctx, &state.quirks) {
+			state.listing = true
+		}
+		host, _, _ := strings.Cut(c.host, "/")
+		host, _, _ = strings.Cut(host, ":")
+		state.quirks.UntagBeforeDelete = untagBeforeDeleteHosts.MatchString(host)
+	}
+	return state.quirks
+}
+
+// CachedQuirks are the quirks Quirks found earlier for the registry at
+// baseURL, without a request; ok is false when it hasn't looked yet.
+func CachedQuirks(baseURL string) (quirks Quirks, ok bool) {
+	state, found := detectedQuirks.Load(baseURL)
+	if !found {
+		return Quirks{}, false
+	}
+	s := state.(*registryQuirks)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.quirks, s.listing
+}
+
+// detectListing asks for one repository of the catalog: a registry without
+// one answers 404 or 405, one ignoring ?n= sends more, and one rejecting it
+// answers 400 but serves the plain catalog. It's false when the registry
+// couldn't be reached.
+func (c *Client) detectListing(ctx context.Context, quirks *Quirks) bool {
+	var catalog struct {
+		Repositories []string `json:"repositories"`
+	}
+	err := c.GetJSON(ctx, "/v2/_catalog?n=1", &catalog)
+	if strings.Contains(fmt.Sprint(err), ": 400 ") {
+		if err = c.GetJSON(ctx, "/v2/_catalog", &catalog); err == nil {
+			quirks.NoPagination = true
+			return true
+		}
+	}
+	var urlErr *url.Error
+	switch {
+	case err == nil:
+		quirks.NoPagination = len(catalog.Repositories) > 1
+	case errors.As(err, &urlErr) || ctx.Err() != nil:
+		return false
+	case unsupportedStatus(err) || (strings.Contains(err.Error(), ": 404 ") && !strings.Contains(err.Error(), "_UNKNOWN")):
+		quirks.NoCatalog = true
+	}
+	return true
+}
+
+// unsupportedStatus reports whether a request failed because the registry
+// doesn't serve it at all: 405, or distribution's UNSUPPORTED code.
+func unsupportedStatus(err error) bool {
+	return strings.Contains(err.Error(), ": 405 ") || strings.Contains(err.Error(), "UNSUPPORTED")
+}
+
+// ProbeDelete learns whether the registry deletes manifests, by deleting
+// one that doesn't exist from repository, and returns the error deleting
+// would fail with.
+func (c *Client) ProbeDelete(ctx context.Context, repository string) error {
+	if c.quirks != nil {
+		return c.deleteUnsupported()
+	}
+	state := c.detected()
+	state.mu.Lock()
+	if !state.deleting {
+		req, cancel, err := c.newRequest(ctx, http.MethodDelete, fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL, repository, probeDigest), nil)
+		if err == nil {
+			resp, err := c.do(req)
+			if err == nil {
+				resp.Body.Close()
+			} else if unsupportedStatus(err) {
+				state.quirks.NoDelete = true
+			}
+			state.deleting = ctx.Err() == nil
+			cancel()
+		}
+	}
+	state.mu.Unlock()
+	return c.deleteUnsupported()
+}
+
+// deleteUnsupported is the explained ErrUnsupported of a registry known not
+// to delete manifests, nil otherwise.
+func (c *Client) deleteUnsupported() error {
+	if c.quirks != nil {
+		if c.quirks.NoDelete {
+			return fmt.Errorf("registry %s is configured with the no-delete quirk: %w", c.host, ErrUnsupported)
+		}
+		return nil
+	}
+	state := c.detected()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.quirks.NoDelete {
+		return c.noDeleteError()
+	}
+	return nil
+}
+
+func (c *Client) noDeleteError() error {
+	return fmt.Errorf("registry %s doesn't delete manifests through its API (distribution needs REGISTRY_STORAGE_DELETE_ENABLED=true): %w", c.host, ErrUnsupported)
+}
+
+// learnNoDelete remembers a registry refusing a delete as unsupported.
+func (c *Client) learnNoDelete() {
+	state := c.detected()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.quirks.NoDelete, state.deleting = true, true
+}
+
+// getPaged decodes the list at path and each page its Link headers point
+// to after it, asking for pageSize entries at a time.
+func (c *Client) getPaged(ctx context.Context, path string, decode func(page []byte) error) error {
+	const pageSize = 1000
+	quirks := c.Quirks(ctx)
+	next := path
+	if !quirks.NoPagination {
+		next += fmt.Sprintf("?n=%d", pageSize)
+	}
+	seen := make(map[string]bool)
+	for next != "" && !seen[next] {
+		seen[next] = true
+		body, link, err := c.getPage(ctx, next)
+		if err != nil {
+			return err
+		}
+		if err := decode(body); err != nil {
+			return err
+		}
+		next = ""
+		if !quirks.NoPagination {
+			next = nextPage(link)
+		}
+	}
+	return nil
+}
+
+// linkNext is the target of a Link header's rel="next".
+var linkNext = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+// nextPage is the path of the page a Link header points to next, "" at the
+// last one.
+func nextPage(link string) string {
+	match := linkNext.FindStringSubmatch(link)
+	if match == nil {
+		return ""
+	}
+	next, err := url.Parse(match[1])
+	if err != nil {
+		return ""
+	}
+	return next.RequestURI()
+}
@@ -0,0 +1,45 @@
+package store
+
+import "context"
+
+// Commit is a row of images: a commit of the repository with the message,
+// or the description of its pull request, it was pushed with.
+type Commit struct {
+	SHA       string
+	Message   string
+	Author    string
+	Committer string
+}
+
+// RecordCommit stores a commit unless it is there already, filling in the
+// author of one stored before authors were.
+func (s *Store) RecordCommit(ctx context.Context, commit Commit) error {
+	dbCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	_, err := s.db.ExecContext(dbCtx, "INSERT INTO images (commit_sha, PR_Description, author, committer) "+
+		"SELECT ?, ?, ?, ? FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM images WHERE commit_sha = ?)",
+		commit.SHA, commit.Message, commit.Author, commit.Committer, commit.SHA)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(dbCtx, "UPDATE images SET author = ?, committer = ? WHERE commit_sha = ? AND author IS NULL",
+		commit.Author, commit.Committer, commit.SHA)
+	return err
+}
+
+// SetPullRequestDescription replaces the message stored for a commit with
+// the description of the pull request it heads, storing the commit if it
+// isn't there yet.
+func (s *Store) SetPullRequestDescription(ctx context.Context, sha, description string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	result, err := s.db.ExecContext(dbCtx, "UPDATE images SET PR_Description = ? WHERE commit_sha = ?", description, sha)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows > 0 {
+		return err
+	}
+	_, err = s.db.ExecContext(dbCtx, "INSERT INTO images (commit_sha, PR_Description) VALUES (?, ?)", sha, description)
+	return err
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// HistoryEntry is a row of the history table: an action taken on a target,
+// who took it and how it went.
+type HistoryEntry struct {
+	ID      int64     // set by the database when recording, in the order entries were
+	Time    time.Time // set by the database when recording
+	Actor   string
+	Action  string
+	Target  string
+	Status  string // e.g. succeeded, failed, denied
+	Details string
+}
+
+// RecordHistory appends an entry to the history table.
+func (s *Store) RecordHistory(ctx context.Context, entry HistoryEntry) error {
+	dbCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	_, err := s.db.ExecContext(dbCtx, "INSERT INTO history (action, target, status, details, actor) VALUES (?, ?, ?, ?, ?)",
+		entry.Action, entry.Target, entry.Status, entry.Details, entry.Actor)
+	return err
+}
+
+// historyColumns are the columns queryHistory scans.
+const historyColumns = "SELECT id, UNIX_TIMESTAMP(created_at), COALESCE(actor, ''), action, COALESCE(target, ''), COALESCE(status, ''), COALESCE(details, '') FROM history "
+
+// History is the latest limit entries, newest first.
+func (s *Store) History(ctx context.Context, limit int) ([]HistoryEntry, error) {
+	return s.queryHistory(ctx, historyColumns+"ORDER BY id DESC LIMIT ?", limit)
+}
+
+// LatestHistory is the latest entry of each target of an action, e.g. the
+// last run of each scheduled job.
+func (s *Store) LatestHistory(ctx context.Context, action string) ([]HistoryEntry, error) {
+	return s.queryHistory(ctx, historyColumns+"WHERE id IN (SELECT MAX(id) FROM history WHERE action = ? GROUP BY target)", action)
+}
+
+// HistoryBetween is every entry recorded from since until until, oldest
+// first; a zero time leaves that end open.
+func (s *Store) HistoryBetween(ctx context.Context, since, until time.Time) ([]HistoryEntry, error) {
+	if since.IsZero() {
+		since = time.Unix(0, 0)
+	}
+	if until.IsZero() {
+		until = time.Now().Add(time.Minute)
+	}
+	return s.queryHistory(ctx, historyColumns+"WHERE created_at >= FROM_UNIXTIME(?) AND created_at < FROM_UNIXTIME(?) ORDER BY id", since.Unix(), until.Unix())
+}
+
+// HistoryAfter is up to limit entries recorded after the one with afterID,
+// oldest first, to read the history as a queue.
+func (s *Store) HistoryAfter(ctx context.Context, afterID int64, limit int) ([]HistoryEntry, error) {
+	return s.queryHistory(ctx, historyColumns+"WHERE id > ? ORDER BY id LIMIT ?", afterID, limit)
+}
+
+func (s *Store) queryHistory(ctx context.Context, query string, args ...any) ([]HistoryEntry, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	rows, err := s.db.QueryContext(dbCtx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []HistoryEntry{}
+	for rows.Next() {
+		var entry HistoryEntry
+		var created int64
+		if err := rows.Scan(&entry.ID, &created, &entry.Actor, &entry.Action, &entry.Target, &entry.Status, &entry.Details); err != nil {
+			return entries, err
+		}
+		entry.Time = time.Unix(created, 0)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// ImageRecord is a row of image_records: one tag and digest of a registry
+// repository. Rows outlive the tag, so the table keeps an inventory of what
+// was pushed when, and how big each build was, after tags are overwritten
+// or pruned.
+type ImageRecord struct {
+	Registry     string
+	Repository   string
+	Tag          string
+	Digest       string
+	SizeBytes    int64      // 0 when unknown
+	Created      *time.Time // when the image was built, nil when unknown
+	SourceCommit string     // the commit built, "" when unknown
+}
+
+// RecordImages upserts records in one statement: a digest first seen under
+// a tag gets a row with pushed_at set to now, one seen before just has
+// last_seen moved on.
+func (s *Store) RecordImages(ctx context.Context, records []ImageRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	var rows []string
+	var args []any
+	for _, record := range records {
+		var size any
+		if record.SizeBytes > 0 {
+			size = record.SizeBytes
+		}
+		var sourceCommit any
+		if record.SourceCommit != "" {
+			sourceCommit = record.SourceCommit
+		}
+		rows = append(rows, "(?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, record.Registry, record.Repository, record.Tag, record.Digest, size, record.Created, sourceCommit)
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	_, err := s.db.ExecContext(dbCtx, "INSERT INTO image_records (registry, repository, tag, digest, size_bytes, image_created, source_commit) VALUES "+
+		strings.Join(rows, ", ")+
+		" ON DUPLICATE KEY UPDATE last_seen = CURRENT_TIMESTAMP, size_bytes = COALESCE(VALUES(size_bytes), size_bytes), source_commit = COALESCE(VALUES(source_commit), source_commit)",
+		args...)
+	return err
+}
+
+// SizePoint is the size of one build of a repository.
+type SizePoint struct {
+	Tag      string
+	Digest   string
+	Size     int64
+	PushedAt time.Time
+}
+
+// SizeHistory is the latest limit builds of a repository with a known
+// size, oldest first.
+func (s *Store) SizeHistory(ctx context.Context, registry, repository string, limit int) ([]SizePoint, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	rows, err := s.db.QueryContext(dbCtx, "SELECT tag, digest, size_bytes, UNIX_TIMESTAMP(pushed_at) FROM image_records "+
+		"WHERE registry = ? AND repository = ? AND size_bytes IS NOT NULL ORDER BY pushed_at DESC, id DESC LIMIT ?",
+		registry, repository, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []SizePoint{}
+	for rows.Next() {
+		var point SizePoint
+		var pushedAt int64
+		if err := rows.Scan(&point.Tag, &point.Digest, &point.Size, &pushedAt); err != nil {
+			return nil, err
+		}
+		point.PushedAt = time.Unix(pushedAt, 0)
+		points = append([]SizePoint{point}, points...)
+	}
+	return points, rows.Err()
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// DeployProfile is a row of deploy_profiles: how an image was last
+// deployed, so the next build of it can be deployed the same way.
+type DeployProfile struct {
+	Image   string // the image without tag or digest, e.g. localhost:5000/team/web
+	Content []byte // encoded by the caller
+	SavedAt time.Time
+}
+
+// SaveDeployProfile replaces the profile of an image.
+func (s *Store) SaveDeployProfile(ctx context.Context, image string, content []byte) error {
+	dbCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	_, err := s.db.ExecContext(dbCtx, "INSERT INTO deploy_profiles (image, content) VALUES (?, ?) "+
+		"ON DUPLICATE KEY UPDATE content = VALUES(content), saved_at = CURRENT_TIMESTAMP",
+		image, content)
+	return err
+}
+
+// LoadDeployProfile returns the profile of an image, sql.ErrNoRows when it
+// wasn't deployed yet.
+func (s *Store) LoadDeployProfile(ctx context.Context, image string) (DeployProfile, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	profile := DeployProfile{Image: image}
+	var savedAt int64
+	err := s.db.QueryRowContext(dbCtx, "SELECT content, UNIX_TIMESTAMP(saved_at) FROM deploy_profiles WHERE image = ?", image).
+		Scan(&profile.Content, &savedAt)
+	profile.SavedAt = time.Unix(savedAt, 0)
+	return profile, err
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// DeployRule is a row of deploy_rules: when a repository gets a tag
+// matching TagPattern, it is deployed to a deployment.
+type DeployRule struct {
+	ID         int64
+	Repository string // e.g. team/web
+	TagPattern string // a path.Match pattern, e.g. v* or main-*
+	Namespace  string
+	Deployment string
+	Container  string // "" for the first
+	Enabled    bool
+	LastImage  string    // what the rule last deployed, "" before it first ran
+	LastStatus string    // e.g. succeeded or failed
+	LastRun    time.Time // zero before it first ran
+}
+
+// AddDeployRule stores a new, enabled rule and returns its ID.
+func (s *Store) AddDeployRule(ctx context.Context, rule DeployRule) (int64, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	result, err := s.db.ExecContext(dbCtx, "INSERT INTO deploy_rules (repository, tag_pattern, namespace, deployment, container) VALUES (?, ?, ?, ?, ?)",
+		rule.Repository, rule.TagPattern, rule.Namespace, rule.Deployment, rule.Container)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// DeployRules is every rule, oldest first.
+func (s *Store) DeployRules(ctx context.Context) ([]DeployRule, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	rows, err := s.db.QueryContext(dbCtx, "SELECT id, repository, tag_pattern, namespace, deployment, container, enabled, "+
+		"COALESCE(last_image, ''), COALESCE(last_status, ''), COALESCE(UNIX_TIMESTAMP(last_run), 0) FROM deploy_rules ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []DeployRule{}
+	for rows.Next() {
+		var rule DeployRule
+		var lastRun int64
+		if err := rows.Scan(&rule.ID, &rule.Repository, &rule.TagPattern, &rule.Namespace, &rule.Deployment, &rule.Container, &rule.Enabled,
+			&rule.LastImage, &rule.LastStatus, &lastRun); err != nil {
+			return rules, err
+		}
+		if lastRun > 0 {
+			rule.LastRun = time.Unix(lastRun, 0)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// SetDeployRuleEnabled enables or disables a rule, sql.ErrNoRows when there
+// is no rule id.
+func (s *Store) SetDeployRuleEnabled(ctx context.Context, id int64, enabled bool) error {
+	return s.execRule(ctx, "UPDATE deploy_rules SET enabled = ? WHERE id = ?", enabled, id)
+}
+
+// DeleteDeployRule deletes a rule, sql.ErrNoRows when there is no rule id.
+func (s *Store) DeleteDeployRule(ctx context.Context, id int64) error {
+	return s.execRule(ctx, "DELETE FROM deploy_rules WHERE id = ?", id)
+}
+
+// RecordDeployRuleRun keeps what a rule deployed last and how it went.
+func (s *Store) RecordDeployRuleRun(ctx context.Context, id int64, image, status string) error {
+	return s.execRule(ctx, "UPDATE deploy_rules SET last_image = ?, last_status = ?, last_run = CURRENT_TIMESTAMP WHERE id = ?", image, status, id)
+}
+
+// execRule runs a statement changing one rule. MySQL counts rows matched
+// but left as they were as unaffected, so a rule that exists is looked up
+// before reporting it missing.
+func (s *Store) execRule(ctx context.Context, statement string, args ...any) error {
+	dbCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	result, err := s.db.ExecContext(dbCtx, statement, args...)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected > 0 {
+		return err
+	}
+	var exists int
+	return s.db.QueryRowContext(dbCtx, "SELECT 1 FROM deploy_rules WHERE id = ?", args[len(args)-1]).Scan(&exists)
+}
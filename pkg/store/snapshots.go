@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// TabSnapshot is a row of tab_snapshots: the last data loaded for a view,
+// kept so it can still be shown when its backend can't be reached.
+type TabSnapshot struct {
+	Name    string // the view and where its data came from, e.g. docker:localhost:5000
+	Content []byte // encoded by the caller
+	SavedAt time.Time
+}
+
+// SaveTabSnapshot replaces the snapshot of a view.
+func (s *Store) SaveTabSnapshot(ctx context.Context, name string, content []byte) error {
+	dbCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	_, err := s.db.ExecContext(dbCtx, "INSERT INTO tab_snapshots (name, content) VALUES (?, ?) "+
+		"ON DUPLICATE KEY UPDATE content = VALUES(content), saved_at = CURRENT_TIMESTAMP",
+		name, content)
+	return err
+}
+
+// LoadTabSnapshot returns the snapshot of a view, sql.ErrNoRows when none
+// was saved.
+func (s *Store) LoadTabSnapshot(ctx context.Context, name string) (TabSnapshot, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	snapshot := TabSnapshot{Name: name}
+	var savedAt int64
+	err := s.db.QueryRowContext(dbCtx, "SELECT content, UNIX_TIMESTAMP(saved_at) FROM tab_snapshots WHERE name = ?", name).
+		Scan(&snapshot.Content, &savedAt)
+	snapshot.SavedAt = time.Unix(savedAt, 0)
+	return snapshot, err
+}
@@ -0,0 +1,160 @@
+// Package store keeps the registry's history and image inventory in MySQL:
+// the commits seen with their authors, the actions taken on images and
+// deployments, every tag and digest seen in the registry with its size and
+// source commit, the last data of each view for when its backend can't be
+// reached, how each image was last deployed and the rules deploying new
+// tags automatically. The schema matches init-db.sql; EnsureSchema brings
+// older databases up to date.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DefaultTimeout bounds each query when New is given no timeout.
+const DefaultTimeout = 10 * time.Second
+
+// Statements bring databases created from an older init-db.sql up to date.
+// Every statement must be safe to run on each start.
+var Statements = []string{
+	`CREATE TABLE IF NOT EXISTS images (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		commit_sha VARCHAR(255),
+		PR_Description TEXT,
+		author VARCHAR(255),
+		committer VARCHAR(255),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS history (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		action VARCHAR(64) NOT NULL,
+		target VARCHAR(512),
+		status VARCHAR(32),
+		details TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS image_records (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		registry VARCHAR(191) NOT NULL,
+		repository VARCHAR(255) NOT NULL,
+		tag VARCHAR(128) NOT NULL,
+		digest VARCHAR(100) NOT NULL,
+		size_bytes BIGINT,
+		image_created DATETIME,
+		source_commit VARCHAR(64),
+		pushed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE KEY image_record (registry, repository, tag, digest),
+		KEY image_record_repository (registry, repository, pushed_at)
+	)`,
+	`CREATE TABLE IF NOT EXISTS users (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		username VARCHAR(64) NOT NULL UNIQUE,
+		email VARCHAR(255),
+		password_hash VARCHAR(72) NOT NULL,
+		role VARCHAR(16) NOT NULL DEFAULT 'viewer',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS api_tokens (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		name VARCHAR(64) NOT NULL,
+		token_hash CHAR(64) NOT NULL UNIQUE,
+		expires_at DATETIME,
+		last_used TIMESTAMP NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+	)`,
+	`CREATE TABLE IF NOT EXISTS tab_snapshots (
+		name VARCHAR(191) PRIMARY KEY,
+		content MEDIUMBLOB NOT NULL,
+		saved_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS deploy_profiles (
+		image VARCHAR(191) PRIMARY KEY,
+		content TEXT NOT NULL,
+		saved_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS deploy_rules (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		repository VARCHAR(255) NOT NULL,
+		tag_pattern VARCHAR(128) NOT NULL,
+		namespace VARCHAR(63) NOT NULL,
+		deployment VARCHAR(253) NOT NULL,
+		container VARCHAR(63) NOT NULL DEFAULT '',
+		enabled BOOLEAN NOT NULL DEFAULT TRUE,
+		last_image VARCHAR(512),
+		last_status VARCHAR(32),
+		last_run TIMESTAMP NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+}
+
+// Column is a column added to an existing table.
+type Column struct {
+	Table, Name, Definition string
+}
+
+// Columns are the columns added since the tables were first created, as
+// MySQL has no ADD COLUMN IF NOT EXISTS.
+var Columns = []Column{
+	{"history", "actor", "VARCHAR(64)"},
+	{"users", "role", "VARCHAR(16) NOT NULL DEFAULT 'viewer'"},
+	{"images", "author", "VARCHAR(255)"},
+	{"images", "committer", "VARCHAR(255)"},
+}
+
+// Store reads and writes the tables through a database handle it does not
+// own; closing the handle is up to the caller.
+type Store struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// New wraps an open MySQL handle. Each query is bounded by timeout, or
+// DefaultTimeout when it is zero.
+func New(db *sql.DB, timeout time.Duration) *Store {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Store{db: db, timeout: timeout}
+}
+
+// DB is the handle the store queries.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// EnsureSchema creates missing tables and adds missing columns.
+func (s *Store) EnsureSchema(ctx context.Context) error {
+	for _, statement := range Statements {
+		dbCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		_, err := s.db.ExecContext(dbCtx, statement)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	for _, column := range Columns {
+		if err := s.addColumnIfMissing(ctx, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) addColumnIfMissing(ctx context.Context, column Column) error {
+	dbCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	var count int
+	err := s.db.QueryRowContext(dbCtx, "SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?",
+		column.Table, column.Name).Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+	_, err = s.db.ExecContext(dbCtx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", column.Table, column.Name, column.Definition))
+	return err
+}
@@ -0,0 +1,54 @@
+//go:build envtest
+
+package testenv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// StartAPIServer starts an API server and writes its kubeconfig; Stop stops
+// it. It takes the binaries from KUBEBUILDER_ASSETS, failing with
+// ErrNoEnvtestAssets without it.
+func StartAPIServer() (*APIServer, error) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		return nil, fmt.Errorf("%w: KUBEBUILDER_ASSETS isn't set; install the envtest binaries with setup-envtest", ErrNoEnvtestAssets)
+	}
+	env := &envtest.Environment{}
+	config, err := env.Start()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the envtest API server: %v", err)
+	}
+	server := &APIServer{stop: env.Stop}
+	if err := server.setup(env, config.Host); err != nil {
+		server.Stop()
+		return nil, err
+	}
+	return server, nil
+}
+
+func (s *APIServer) setup(env *envtest.Environment, host string) error {
+	admin, err := env.AddUser(envtest.User{Name: "admin", Groups: []string{"system:masters"}}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to add the envtest admin: %v", err)
+	}
+	kubeconfig, err := admin.KubeConfig()
+	if err != nil {
+		return fmt.Errorf("failed to write the envtest kubeconfig: %v", err)
+	}
+	if s.dir, err = os.MkdirTemp("", "testenv-kube-"); err != nil {
+		return err
+	}
+	s.Kubeconfig = filepath.Join(s.dir, "kubeconfig")
+	if err := os.WriteFile(s.Kubeconfig, kubeconfig, 0o600); err != nil {
+		return err
+	}
+	if s.Clientset, err = kubernetes.NewForConfig(admin.Config()); err != nil {
+		return fmt.Errorf("failed to connect to the envtest API server at %s: %v", host, err)
+	}
+	return nil
+}
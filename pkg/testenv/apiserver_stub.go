@@ -0,0 +1,11 @@
+//go:build !envtest
+
+package testenv
+
+import "fmt"
+
+// StartAPIServer would start an API server; built without -tags envtest it
+// returns ErrNoEnvtestAssets.
+func StartAPIServer() (*APIServer, error) {
+	return nil, fmt.Errorf("%w: the tests weren't built with -tags envtest", ErrNoEnvtestAssets)
+}
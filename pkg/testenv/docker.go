@@ -0,0 +1,284 @@
+package testenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Docker is an in-process Docker Engine API: ping, version, listing,
+// inspecting, pulling, tagging, pushing and removing images, enough for
+// pkg/docker's engine client and for a docker CLI pointed at it with
+// DOCKER_HOST. Pulls stream per-layer progress the way the engine does.
+type Docker struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	images   []*dockerImage
+	remote   map[string]int // reference → number of layers a pull reports
+	pushed   []string
+	requests []string
+}
+
+type dockerImage struct {
+	id      string
+	tags    []string
+	digests []string
+	labels  map[string]string
+	size    int64
+	created time.Time
+}
+
+// NewDocker starts an engine with no images; Close stops it.
+func NewDocker() *Docker {
+	d := &Docker{remote: make(map[string]int)}
+	d.server = httptest.NewServer(http.HandlerFunc(d.serveHTTP))
+	return d
+}
+
+// Close stops the engine.
+func (d *Docker) Close() {
+	d.server.Close()
+}
+
+// Host is the engine's address as DOCKER_HOST takes it.
+func (d *Docker) Host() string {
+	return "tcp://" + strings.TrimPrefix(d.server.URL, "http://")
+}
+
+// Requests are the requests served so far, as "METHOD /path?query", with the
+// API version prefix left out.
+func (d *Docker) Requests() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.requests)
+}
+
+// Pushed are the references pushed so far, in order.
+func (d *Docker) Pushed() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.pushed)
+}
+
+// AddImage stores a local image tagged ref, e.g. nginx:1.27, with labels,
+// and returns its ID.
+func (d *Docker) AddImage(ref string, labels map[string]string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.addImage(ref, labels, 0)
+}
+
+func (d *Docker) addImage(ref string, labels map[string]string, layers int) string {
+	ref = withTag(ref)
+	id := Digest([]byte(ref))
+	var tags []string
+	if !strings.Contains(ref, "@") {
+		// Pulls by digest leave the image untagged
+		tags = []string{ref}
+		for _, image := range d.images {
+			image.tags = slices.DeleteFunc(image.tags, func(tag string) bool { return tag == ref })
+		}
+	}
+	d.images = append(d.images, &dockerImage{
+		id:      id,
+		tags:    tags,
+		labels:  labels,
+		size:    int64(1+layers) << 20,
+		created: time.Now().UTC().Truncate(time.Second),
+	})
+	return id
+}
+
+// AddRemote makes ref pullable, reporting layers layers as it downloads.
+// Pulls of anything else fail the way a missing image does.
+func (d *Docker) AddRemote(ref string, layers int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.remote[withTag(ref)] = layers
+}
+
+// withTag is ref with latest when it names neither a tag nor a digest.
+func withTag(ref string) string {
+	if strings.Contains(ref, "@") || strings.LastIndex(ref, ":") > strings.LastIndex(ref, "/") {
+		return ref
+	}
+	return ref + ":latest"
+}
+
+// find is the image named by an ID, a short ID or a reference.
+func (d *Docker) find(name string) *dockerImage {
+	ref := withTag(name)
+	for _, image := range d.images {
+		if image.id == name || strings.TrimPrefix(image.id, "sha256:") == name ||
+			strings.HasPrefix(strings.TrimPrefix(image.id, "sha256:"), name) && len(name) >= 12 ||
+			slices.Contains(image.tags, ref) || slices.Contains(image.digests, name) {
+			return image
+		}
+	}
+	return nil
+}
+
+var (
+	apiVersion = regexp.MustCompile(`^/v[0-9]+\.[0-9]+/`)
+	imagePath  = regexp.MustCompile(`^/images/(.+?)(/json|/tag|/push)?$`)
+)
+
+func (d *Docker) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	path := apiVersion.ReplaceAllString(req.URL.Path, "/")
+	request := req.Method + " " + path
+	if req.URL.RawQuery != "" {
+		request += "?" + req.URL.RawQuery
+	}
+	d.requests = append(d.requests, request)
+	w.Header().Set("Api-Version", "1.45")
+
+	switch {
+	case path == "/_ping":
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "OK")
+	case path == "/version":
+		writeJSON(w, http.StatusOK, map[string]any{
+			"Version":    "27.0.0-testenv",
+			"ApiVersion": "1.45",
+			"Os":         "linux",
+			"Arch":       "amd64",
+		})
+	case path == "/images/json" && req.Method == http.MethodGet:
+		var list []map[string]any
+		for _, image := range d.images {
+			list = append(list, map[string]any{
+				"Id":          image.id,
+				"RepoTags":    image.tags,
+				"RepoDigests": image.digests,
+				"Labels":      image.labels,
+				"Size":        image.size,
+				"Created":     image.created.Unix(),
+			})
+		}
+		writeJSON(w, http.StatusOK, list)
+	case path == "/images/create" && req.Method == http.MethodPost:
+		d.servePull(w, req)
+	case imagePath.MatchString(path):
+		match := imagePath.FindStringSubmatch(path)
+		d.serveImage(w, req, match[1], match[2])
+	default:
+		engineError(w, http.StatusNotFound, "page not found")
+	}
+}
+
+func (d *Docker) serveImage(w http.ResponseWriter, req *http.Request, name, action string) {
+	image := d.find(name)
+	if image == nil {
+		engineError(w, http.StatusNotFound, "No such image: "+name)
+		return
+	}
+	switch {
+	case action == "/json" && req.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{
+			"Id":          image.id,
+			"RepoTags":    image.tags,
+			"RepoDigests": image.digests,
+			"Size":        image.size,
+			"Created":     image.created.Format(time.RFC3339Nano),
+			"Config":      map[string]any{"Labels": image.labels},
+		})
+	case action == "/tag" && req.Method == http.MethodPost:
+		target := req.URL.Query().Get("repo")
+		if tag := req.URL.Query().Get("tag"); tag != "" {
+			target += ":" + tag
+		}
+		target = withTag(target)
+		for _, other := range d.images {
+			other.tags = slices.DeleteFunc(other.tags, func(tag string) bool { return tag == target })
+		}
+		image.tags = append(image.tags, target)
+		w.WriteHeader(http.StatusCreated)
+	case action == "/push" && req.Method == http.MethodPost:
+		ref := name
+		if tag := req.URL.Query().Get("tag"); tag != "" {
+			ref += ":" + tag
+		}
+		ref = withTag(ref)
+		d.pushed = append(d.pushed, ref)
+		digest := Digest([]byte("manifest " + image.id))
+		repository, tag := ref[:strings.LastIndex(ref, ":")], ref[strings.LastIndex(ref, ":")+1:]
+		if !slices.Contains(image.digests, repository+"@"+digest) {
+			image.digests = append(image.digests, repository+"@"+digest)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.Encode(map[string]any{"status": "The push refers to repository [" + name + "]"})
+		encoder.Encode(map[string]any{"status": fmt.Sprintf("%s: digest: %s size: 1024", tag, digest)})
+	case action == "" && req.Method == http.MethodDelete:
+		d.images = slices.DeleteFunc(d.images, func(other *dockerImage) bool { return other == image })
+		var deleted []map[string]string
+		for _, tag := range image.tags {
+			deleted = append(deleted, map[string]string{"Untagged": tag})
+		}
+		writeJSON(w, http.StatusOK, append(deleted, map[string]string{"Deleted": image.id}))
+	default:
+		engineError(w, http.StatusNotFound, "page not found")
+	}
+}
+
+// servePull answers /images/create with the engine's stream of progress
+// updates: one line per change of a layer, then the digest and status.
+func (d *Docker) servePull(w http.ResponseWriter, req *http.Request) {
+	name, tag := req.URL.Query().Get("fromImage"), req.URL.Query().Get("tag")
+	ref := name
+	switch {
+	case strings.HasPrefix(tag, "sha256:"):
+		ref += "@" + tag
+	case tag != "":
+		ref += ":" + tag
+	}
+	ref = withTag(ref)
+	layers, ok := d.remote[ref]
+	if !ok {
+		engineError(w, http.StatusNotFound, fmt.Sprintf("pull access denied for %s, repository does not exist or may require 'docker login'", name))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.Encode(map[string]any{"status": "Pulling from " + name, "id": tag})
+	for i := range layers {
+		id := fmt.Sprintf("%012x", i+1)
+		total := int64(i+1) << 20
+		encoder.Encode(map[string]any{"status": "Pulling fs layer", "id": id, "progressDetail": map[string]any{}})
+		encoder.Encode(map[string]any{"status": "Downloading", "id": id, "progressDetail": map[string]any{"current": total / 2, "total": total}})
+		encoder.Encode(map[string]any{"status": "Download complete", "id": id, "progressDetail": map[string]any{}})
+		encoder.Encode(map[string]any{"status": "Extracting", "id": id, "progressDetail": map[string]any{"current": total, "total": total}})
+		encoder.Encode(map[string]any{"status": "Pull complete", "id": id, "progressDetail": map[string]any{}})
+	}
+	id := d.addImage(ref, nil, layers)
+	digest := Digest([]byte("manifest " + id))
+	image := d.find(id)
+	repository, _, _ := strings.Cut(ref, "@")
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository = repository[:i]
+	}
+	image.digests = append(image.digests, repository+"@"+digest)
+	encoder.Encode(map[string]any{"status": "Digest: " + digest})
+	encoder.Encode(map[string]any{"status": "Status: Downloaded newer image for " + ref})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// engineError answers with the engine's error body.
+func engineError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
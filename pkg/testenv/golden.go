@@ -0,0 +1,48 @@
+package testenv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Golden compares got, e.g. a model's View, with testdata/<name>.golden
+// next to the test, failing the test with both when they differ. Colors and
+// trailing spaces are left out, so styling changes don't break snapshots.
+// Replacements are old, new pairs applied first, for what changes between
+// runs, e.g. env.Registry.Host(), "registry:5000". UPDATE_GOLDEN=1
+// rewrites the file with got instead.
+func Golden(t testing.TB, name, got string, replacements ...string) {
+	t.Helper()
+	got = normalize(strings.NewReplacer(replacements...).Replace(got))
+	path := filepath.Join("testdata", name+".golden")
+	if os.Getenv("UPDATE_GOLDEN") == "1" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run with UPDATE_GOLDEN=1 to create it", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from the golden file; run with UPDATE_GOLDEN=1 if the change is intended\n--- got\n%s\n--- want\n%s", name, got, want)
+	}
+}
+
+// normalize strips escape sequences and trailing spaces and ends the text
+// with one newline.
+func normalize(view string) string {
+	lines := strings.Split(ansi.Strip(view), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n") + "\n"
+}
@@ -0,0 +1,139 @@
+package testenv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Kubernetes fixtures come two ways: FakeClientset for pkg/kube, which
+// works on kubernetes.Interface, and StartAPIServer for the app, which
+// builds its clientsets from a kubeconfig. The fake clientset needs
+// nothing installed; the API server needs etcd and kube-apiserver, e.g.
+// from `setup-envtest use -p path`, in KUBEBUILDER_ASSETS, and the tests
+// built with -tags envtest, which keeps controller-runtime out of every
+// other build.
+
+// ErrNoEnvtestAssets is wrapped by the error of StartAPIServer when the
+// tests weren't built with -tags envtest or KUBEBUILDER_ASSETS isn't set;
+// tests skip on it.
+var ErrNoEnvtestAssets = errors.New("no envtest API server")
+
+// Namespace is a namespace named name.
+func Namespace(name string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+// Deployment is a deployment of replicas pods running image, with the
+// app=name selector the TUI's create-deployment uses.
+func Deployment(namespace, name, image string, replicas int32) *appsv1.Deployment {
+	labels := map[string]string{"app": name}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: name, Image: image}},
+				},
+			},
+		},
+		Status: appsv1.DeploymentStatus{
+			Replicas:          replicas,
+			ReadyReplicas:     replicas,
+			AvailableReplicas: replicas,
+			UpdatedReplicas:   replicas,
+		},
+	}
+}
+
+// PodsFor are n running, ready pods of deployment, as its controller would
+// have made them.
+func PodsFor(deployment *appsv1.Deployment, n int) []runtime.Object {
+	var pods []runtime.Object
+	for i := range n {
+		spec := deployment.Spec.Template.Spec
+		pods = append(pods, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-%d", deployment.Name, i),
+				Namespace: deployment.Namespace,
+				Labels:    deployment.Spec.Template.Labels,
+			},
+			Spec: *spec.DeepCopy(),
+			Status: corev1.PodStatus{
+				Phase:      corev1.PodRunning,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  spec.Containers[0].Name,
+					Image: spec.Containers[0].Image,
+					Ready: true,
+					State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				}},
+			},
+		})
+	}
+	return pods
+}
+
+// FakeClientset is a clientset holding objects, for pkg/kube's functions.
+func FakeClientset(objects ...runtime.Object) kubernetes.Interface {
+	return fake.NewSimpleClientset(objects...)
+}
+
+// APIServer is a real etcd and kube-apiserver started by envtest, with a
+// kubeconfig for its admin.
+type APIServer struct {
+	Kubeconfig string // path of the kubeconfig file
+	Clientset  kubernetes.Interface
+
+	stop func() error
+	dir  string
+}
+
+// Create creates objects on the API server, e.g. the fixtures above.
+// Statuses are dropped, as the API server sets them itself.
+func (s *APIServer) Create(objects ...runtime.Object) error {
+	ctx := context.Background()
+	for _, object := range objects {
+		var err error
+		switch object := object.(type) {
+		case *corev1.Namespace:
+			_, err = s.Clientset.CoreV1().Namespaces().Create(ctx, object, metav1.CreateOptions{})
+		case *appsv1.Deployment:
+			_, err = s.Clientset.AppsV1().Deployments(object.Namespace).Create(ctx, object, metav1.CreateOptions{})
+		case *corev1.Pod:
+			_, err = s.Clientset.CoreV1().Pods(object.Namespace).Create(ctx, object, metav1.CreateOptions{})
+		case *corev1.Secret:
+			_, err = s.Clientset.CoreV1().Secrets(object.Namespace).Create(ctx, object, metav1.CreateOptions{})
+		case *corev1.Service:
+			_, err = s.Clientset.CoreV1().Services(object.Namespace).Create(ctx, object, metav1.CreateOptions{})
+		default:
+			err = fmt.Errorf("unsupported fixture %T", object)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop stops the API server and removes its kubeconfig.
+func (s *APIServer) Stop() error {
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+	}
+	if s.stop == nil {
+		return nil
+	}
+	return s.stop()
+}
@@ -0,0 +1,382 @@
+package testenv
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry is an in-process distribution registry: the /v2/ API with the
+// catalog and tag lists paged by ?n= and Link headers, manifests by tag or
+// digest, blobs, monolithic and chunked uploads and deletes. Images are
+// pushed through the API or seeded with PushImage.
+type Registry struct {
+	// Quirks make the registry behave like the ones pkg/registry works
+	// around; set them before the first request.
+	NoCatalog bool // /v2/_catalog answers 404
+	NoDelete  bool // deletes answer 405 UNSUPPORTED, as without REGISTRY_STORAGE_DELETE_ENABLED
+
+	server *httptest.Server
+
+	mu        sync.Mutex
+	manifests map[string]map[string]storedManifest // repository → digest → manifest
+	tags      map[string]map[string]string         // repository → tag → digest
+	blobs     map[string][]byte                    // digest → content, shared by every repository
+	uploads   map[string][]byte                    // upload session → content so far
+	nextID    int
+	requests  []string
+}
+
+type storedManifest struct {
+	mediaType string
+	body      []byte
+}
+
+// NewRegistry starts a registry; Close stops it.
+func NewRegistry() *Registry {
+	r := &Registry{
+		manifests: make(map[string]map[string]storedManifest),
+		tags:      make(map[string]map[string]string),
+		blobs:     make(map[string][]byte),
+		uploads:   make(map[string][]byte),
+	}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	return r
+}
+
+// Close stops the registry.
+func (r *Registry) Close() {
+	r.server.Close()
+}
+
+// URL is the registry's base URL, e.g. http://127.0.0.1:34567.
+func (r *Registry) URL() string {
+	return r.server.URL
+}
+
+// Host is the registry's host:port, as REGISTRY_HOST takes it.
+func (r *Registry) Host() string {
+	return strings.TrimPrefix(r.server.URL, "http://")
+}
+
+// Requests are the requests served so far, as "METHOD /path?query".
+func (r *Registry) Requests() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.requests)
+}
+
+// Digest is the sha256 digest of content.
+func Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// PushImage stores an image of one layer per entry of layers under
+// repository:tag, with a config carrying labels, and returns its manifest
+// digest.
+func (r *Registry) PushImage(repository, tag string, labels map[string]string, layers ...[]byte) string {
+	config, _ := json.Marshal(map[string]any{
+		"architecture": "amd64",
+		"os":           "linux",
+		"config":       map[string]any{"Labels": labels},
+		"rootfs":       map[string]any{"type": "layers"},
+	})
+	manifest := map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config":        r.putBlob("application/vnd.oci.image.config.v1+json", config),
+	}
+	descriptors := []map[string]any{}
+	for _, layer := range layers {
+		descriptors = append(descriptors, r.putBlob("application/vnd.oci.image.layer.v1.tar+gzip", layer))
+	}
+	manifest["layers"] = descriptors
+	body, _ := json.Marshal(manifest)
+	return r.PutManifest(repository, tag, "application/vnd.oci.image.manifest.v1+json", body)
+}
+
+// PutManifest stores a manifest under repository, tagged with reference
+// unless that is a digest, and returns its digest.
+func (r *Registry) PutManifest(repository, reference, mediaType string, body []byte) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.putManifest(repository, reference, mediaType, body)
+}
+
+func (r *Registry) putManifest(repository, reference, mediaType string, body []byte) string {
+	digest := Digest(body)
+	if r.manifests[repository] == nil {
+		r.manifests[repository] = make(map[string]storedManifest)
+		r.tags[repository] = make(map[string]string)
+	}
+	r.manifests[repository][digest] = storedManifest{mediaType: mediaType, body: body}
+	if !strings.Contains(reference, ":") {
+		r.tags[repository][reference] = digest
+	}
+	return digest
+}
+
+func (r *Registry) putBlob(mediaType string, content []byte) map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	digest := Digest(content)
+	r.blobs[digest] = content
+	return map[string]any{"mediaType": mediaType, "size": len(content), "digest": digest}
+}
+
+// Tags are the tags of repository, sorted.
+func (r *Registry) Tags(repository string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var tags []string
+	for tag := range r.tags[repository] {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+var (
+	manifestPath = regexp.MustCompile(`^/v2/(.+)/manifests/([^/]+)$`)
+	blobPath     = regexp.MustCompile(`^/v2/(.+)/blobs/(sha256:[0-9a-f]{64})$`)
+	uploadsPath  = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/$`)
+	uploadPath   = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/([0-9]+)$`)
+	tagsPath     = regexp.MustCompile(`^/v2/(.+)/tags/list$`)
+)
+
+func (r *Registry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req.Method+" "+req.URL.RequestURI())
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+
+	path := req.URL.Path
+	switch {
+	case path == "/v2/" || path == "/v2":
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, "{}")
+	case path == "/v2/_catalog":
+		if r.NoCatalog {
+			registryError(w, http.StatusNotFound, "NOT_FOUND", "the catalog isn't served")
+			return
+		}
+		var repositories []string
+		for repository, tags := range r.tags {
+			if len(tags) > 0 {
+				repositories = append(repositories, repository)
+			}
+		}
+		r.servePage(w, req, "repositories", repositories, nil)
+	case tagsPath.MatchString(path):
+		repository := tagsPath.FindStringSubmatch(path)[1]
+		tags, ok := r.tags[repository]
+		if !ok {
+			registryError(w, http.StatusNotFound, "NAME_UNKNOWN", "repository name not known to registry")
+			return
+		}
+		var names []string
+		for tag := range tags {
+			names = append(names, tag)
+		}
+		r.servePage(w, req, "tags", names, map[string]any{"name": repository})
+	case manifestPath.MatchString(path):
+		match := manifestPath.FindStringSubmatch(path)
+		r.serveManifest(w, req, match[1], match[2])
+	case blobPath.MatchString(path):
+		match := blobPath.FindStringSubmatch(path)
+		r.serveBlob(w, req, match[2])
+	case uploadsPath.MatchString(path) && req.Method == http.MethodPost:
+		repository := uploadsPath.FindStringSubmatch(path)[1]
+		if from, digest := req.URL.Query().Get("from"), req.URL.Query().Get("mount"); from != "" && digest != "" {
+			if _, ok := r.blobs[digest]; ok {
+				w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", repository, digest))
+				w.Header().Set("Docker-Content-Digest", digest)
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+		}
+		r.nextID++
+		id := strconv.Itoa(r.nextID)
+		r.uploads[id] = nil
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", repository, id))
+		w.Header().Set("Range", "0-0")
+		w.WriteHeader(http.StatusAccepted)
+	case uploadPath.MatchString(path):
+		match := uploadPath.FindStringSubmatch(path)
+		r.serveUpload(w, req, match[1], match[2])
+	default:
+		registryError(w, http.StatusNotFound, "NOT_FOUND", "no route for "+path)
+	}
+}
+
+// servePage answers a list request with the entries after ?last=, at most
+// ?n= of them, and a Link header to the next page.
+func (r *Registry) servePage(w http.ResponseWriter, req *http.Request, key string, entries []string, extra map[string]any) {
+	slices.Sort(entries)
+	query := req.URL.Query()
+	if last := query.Get("last"); last != "" {
+		i, _ := slices.BinarySearch(entries, last)
+		for i < len(entries) && entries[i] <= last {
+			i++
+		}
+		entries = entries[i:]
+	}
+	if n, err := strconv.Atoi(query.Get("n")); err == nil && n > 0 && n < len(entries) {
+		entries = entries[:n]
+		next := url.Values{"n": {strconv.Itoa(n)}, "last": {entries[n-1]}}
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, req.URL.Path, next.Encode()))
+	}
+	body := map[string]any{key: append([]string{}, entries...)}
+	for k, v := range extra {
+		body[k] = v
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func (r *Registry) serveManifest(w http.ResponseWriter, req *http.Request, repository, reference string) {
+	digest := reference
+	if !strings.Contains(reference, ":") {
+		digest = r.tags[repository][reference]
+	}
+	stored, found := r.manifests[repository][digest]
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		if !found {
+			registryError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown")
+			return
+		}
+		w.Header().Set("Content-Type", stored.mediaType)
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Content-Length", strconv.Itoa(len(stored.body)))
+		if req.Method == http.MethodGet {
+			w.Write(stored.body)
+		}
+	case http.MethodPut:
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			registryError(w, http.StatusBadRequest, "MANIFEST_INVALID", err.Error())
+			return
+		}
+		mediaType := req.Header.Get("Content-Type")
+		digest := r.putManifest(repository, reference, mediaType, body)
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/manifests/%s", repository, digest))
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if r.NoDelete {
+			registryError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "The operation is unsupported.")
+			return
+		}
+		if !found {
+			registryError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown")
+			return
+		}
+		if digest == reference {
+			// Deleting by digest takes every tag pointing at it along
+			delete(r.manifests[repository], digest)
+			for tag, tagged := range r.tags[repository] {
+				if tagged == digest {
+					delete(r.tags[repository], tag)
+				}
+			}
+		} else {
+			delete(r.tags[repository], reference)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		registryError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "The operation is unsupported.")
+	}
+}
+
+func (r *Registry) serveBlob(w http.ResponseWriter, req *http.Request, digest string) {
+	content, ok := r.blobs[digest]
+	if !ok {
+		registryError(w, http.StatusNotFound, "BLOB_UNKNOWN", "blob unknown to registry")
+		return
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		// Range requests resume interrupted downloads
+		http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(content))
+	default:
+		registryError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "The operation is unsupported.")
+	}
+}
+
+func (r *Registry) serveUpload(w http.ResponseWriter, req *http.Request, repository, id string) {
+	content, ok := r.uploads[id]
+	if !ok {
+		registryError(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "blob upload unknown to registry")
+		return
+	}
+	location := fmt.Sprintf("/v2/%s/blobs/uploads/%s", repository, id)
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Set("Location", location)
+		w.Header().Set("Range", uploadRange(content))
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPatch, http.MethodPut:
+		chunk, err := io.ReadAll(req.Body)
+		if err != nil {
+			registryError(w, http.StatusBadRequest, "BLOB_UPLOAD_INVALID", err.Error())
+			return
+		}
+		content = append(content, chunk...)
+		if req.Method == http.MethodPatch {
+			r.uploads[id] = content
+			w.Header().Set("Location", location)
+			w.Header().Set("Range", uploadRange(content))
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		digest := req.URL.Query().Get("digest")
+		if digest != Digest(content) {
+			registryError(w, http.StatusBadRequest, "DIGEST_INVALID", "provided digest did not match uploaded content")
+			return
+		}
+		delete(r.uploads, id)
+		r.blobs[digest] = content
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", repository, digest))
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		delete(r.uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		registryError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "The operation is unsupported.")
+	}
+}
+
+// uploadRange is the Range header of an upload session holding content.
+func uploadRange(content []byte) string {
+	if len(content) == 0 {
+		return "0-0"
+	}
+	return fmt.Sprintf("0-%d", len(content)-1)
+}
+
+// registryError answers with distribution's error body.
+func registryError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"errors": []map[string]string{{"code": code, "message": message}},
+	})
+}
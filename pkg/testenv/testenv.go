@@ -0,0 +1,52 @@
+// Package testenv runs the app's backends in-process for tests: a
+// distribution registry, a Docker Engine API, Kubernetes fixtures on a fake
+// clientset or an envtest API server, and a headless driver with golden
+// files for the TUI. None of it needs Docker, minikube or network access,
+// except StartAPIServer, which needs the envtest binaries and -tags envtest.
+package testenv
+
+import (
+	"errors"
+	"testing"
+)
+
+// Env is a registry, a Docker engine and, when asked for, an API server,
+// with the environment pointing the app at them.
+type Env struct {
+	Registry *Registry
+	Docker   *Docker
+	Kube     *APIServer // nil unless Start was asked for Kubernetes
+}
+
+// Start starts an Env for the test and stops it when the test ends. It sets
+// REGISTRY_HOST and DOCKER_HOST, points MYSQL_HOST where no database
+// answers and, with kube, KUBECONFIG and
+// KUBERNETES_ENABLED=true, skipping the test when the envtest binaries
+// aren't installed; without kube, Kubernetes is disabled.
+func Start(t testing.TB, kube bool) *Env {
+	t.Helper()
+	env := &Env{Registry: NewRegistry(), Docker: NewDocker()}
+	t.Cleanup(env.Registry.Close)
+	t.Cleanup(env.Docker.Close)
+	t.Setenv("REGISTRY_HOST", env.Registry.Host())
+	t.Setenv("DOCKER_HOST", env.Docker.Host())
+	t.Setenv("DOCKER_TLS_VERIFY", "")
+	// Nothing listens on port 1, so the database is down right away
+	t.Setenv("MYSQL_HOST", "127.0.0.1:1")
+
+	if !kube {
+		t.Setenv("KUBERNETES_ENABLED", "false")
+		return env
+	}
+	server, err := StartAPIServer()
+	if errors.Is(err, ErrNoEnvtestAssets) {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Stop() })
+	env.Kube = server
+	t.Setenv("KUBECONFIG", server.Kubeconfig)
+	t.Setenv("KUBERNETES_ENABLED", "true")
+	return env
+}
@@ -0,0 +1,84 @@
+package testenv
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// RunTUI drives a Bubble Tea model without a terminal: it sizes the window,
+// sends msgs in order, lets the commands they start run for settle and
+// quits, returning the model as it ended up. View on the result is what a
+// golden file compares.
+func RunTUI(m tea.Model, width, height int, settle time.Duration, msgs ...tea.Msg) (tea.Model, error) {
+	program := tea.NewProgram(m,
+		tea.WithInput(nil),
+		tea.WithOutput(io.Discard),
+		tea.WithoutSignalHandler(),
+		tea.WithoutRenderer(),
+		// A panic fails the test instead of being reported as a quit
+		tea.WithoutCatchPanics(),
+	)
+	type result struct {
+		model tea.Model
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		model, err := program.Run()
+		done <- result{model, err}
+	}()
+
+	// Send blocks until the program reads it, so each message is handled
+	// before the next goes out
+	program.Send(tea.WindowSizeMsg{Width: width, Height: height})
+	for _, msg := range msgs {
+		program.Send(msg)
+	}
+	select {
+	case r := <-done:
+		// The model quit by itself
+		return r.model, r.err
+	case <-time.After(settle):
+	}
+	program.Quit()
+	r := <-done
+	return r.model, r.err
+}
+
+// keyTypes are Bubble Tea's key types by name, e.g. enter or ctrl+c.
+var keyTypes = func() map[string]tea.KeyType {
+	types := make(map[string]tea.KeyType)
+	for t := tea.KeyType(-128); t < 128; t++ {
+		if name := t.String(); name != "" && name != "runes" {
+			if _, ok := types[name]; !ok {
+				types[name] = t
+			}
+		}
+	}
+	return types
+}()
+
+// Key is the key press named as tea.KeyMsg.String names it, e.g. "enter",
+// "shift+tab", "alt+x" or "q".
+func Key(name string) tea.KeyMsg {
+	alt := false
+	if rest, ok := strings.CutPrefix(name, "alt+"); ok && name != "alt+" {
+		alt, name = true, rest
+	}
+	if t, ok := keyTypes[name]; ok {
+		return tea.KeyMsg{Type: t, Alt: alt}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(name), Alt: alt}
+}
+
+// Type is the key presses typing text, one rune at a time.
+func Type(text string) []tea.Msg {
+	var msgs []tea.Msg
+	for _, r := range text {
+		msgs = append(msgs, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	return msgs
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/kube"
+)
+
+var (
+	diffAddedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575"))
+	diffRemovedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5F87"))
+	diffHunkStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#7D56F4"))
+)
+
+// maxPreviewLines and maxPreviewPods keep the confirmation dialog on screen
+const (
+	maxPreviewLines = 16
+	maxPreviewPods  = 6
+)
+
+// deployPreview is what deploying an image to an existing deployment would
+// change, shown on the confirmation step like `kubectl diff`.
+type deployPreview struct {
+	diff    []string   // unified diff of the deployment spec
+	effects []string   // side effects beyond the spec, e.g. how many pods restart
+	pods    []kube.Pod // the pods the rollout recreates
+	env     string     // the container's env before the deploy, as typed into the env editor
+	keptEnv int        // env entries the editor can't show, kept as they are
+	err     error
+}
+
+type deployPreviewMsg struct {
+	deployment string
+	preview    deployPreview
+}
+
+// previewDeploy computes the change deployImageToPod would make without
+// making it. Helm releases and GitOps mode change the cluster indirectly, so
+// for those the diff is the expected result rather than what is sent.
+func previewDeploy(ctx context.Context, imageName, deploymentName, namespace, container string, env *containerEnv) deployPreview {
+	cluster := newCluster(ctx)
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	deployment, err := cluster.Deployment(ctx, namespace, deploymentName)
+	if err != nil {
+		return deployPreview{err: fmt.Errorf("error getting deployment %s: %v", deploymentName, err)}
+	}
+
+	image := clusterImageName(ctx, imageName)
+	policy := resolvePullPolicy(ctx, cluster, namespace, image, "")
+	updated, err := kube.WithImage(deployment, container, image, policy)
+	if err != nil {
+		return deployPreview{err: err}
+	}
+	index, _ := kube.ContainerIndex(deployment, container)
+	current := deployment.Spec.Template.Spec.Containers[index]
+	if env != nil {
+		updatedEnv := editedEnv(current, *env)
+		if updated, err = kube.WithEnv(updated, container, updatedEnv.Env, updatedEnv.EnvFrom); err != nil {
+			return deployPreview{err: err}
+		}
+	}
+	stampDeployment(deployment, updated, container, deployAnnotations(ctx, image))
+
+	before, err := specYAML(deployment)
+	if err != nil {
+		return deployPreview{err: err}
+	}
+	after, err := specYAML(updated)
+	if err != nil {
+		return deployPreview{err: err}
+	}
+
+	var preview deployPreview
+	preview.diff = unifiedDiff(before, after, 2)
+	var kept containerEnv
+	preview.env, kept = formatContainerEnv(containerEnv{Env: current.Env, EnvFrom: current.EnvFrom})
+	preview.keptEnv = len(kept.Env) + len(kept.EnvFrom)
+
+	switch release, _ := helmRelease(deployment); {
+	case gitopsEnabled():
+		preview.effects = append(preview.effects, fmt.Sprintf("GitOps mode: the image is written to %s, the cluster changes once the controller syncs it", os.Getenv("GITOPS_DIR")))
+	case release != "":
+		preview.effects = append(preview.effects, fmt.Sprintf("Helm release %s is upgraded to a new revision; the chart renders the final manifest", release))
+	}
+	if len(preview.diff) == 0 {
+		preview.effects = append(preview.effects, "Nothing changes and no pods restart; use r on the Workloads tab to pull the tag again")
+		return preview
+	}
+	preview.effects = append(preview.effects,
+		fmt.Sprintf("%d pods are replaced (%s)", kube.DesiredReplicas(*deployment), deploymentStrategy(deployment.Spec.Strategy)),
+		fmt.Sprintf("Nodes pull %s with imagePullPolicy %s", image, policy))
+	if env != nil {
+		for _, missing := range missingEnvReferences(ctx, cluster, namespace, updated.Spec.Template.Spec.Containers[index]) {
+			preview.effects = append(preview.effects, "⚠️  "+missing+"; the new pods won't start")
+		}
+	}
+	// Not knowing which pods restart doesn't stop the deploy
+	if pods, err := cluster.OwnedPods(ctx, deployment); err != nil {
+		preview.effects = append(preview.effects, fmt.Sprintf("Can't tell which pods restart: %v", err))
+	} else {
+		preview.pods = pods
+	}
+	return preview
+}
+
+// specYAML renders the parts of a deployment a deploy can change.
+func specYAML(deployment *appsv1.Deployment) ([]string, error) {
+	out, err := yaml.Marshal(deployment.Spec)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(out), "\n"), "\n"), nil
+}
+
+// unifiedDiff compares two line slices and returns the differing hunks in
+// unified format, with context lines around each change.
+func unifiedDiff(a, b []string, context int) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type edit struct {
+		op   byte // ' ', '-' or '+'
+		line string
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i]})
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+
+	var out []string
+	aLine, bLine := 1, 1 // line numbers at edits[k]
+	for k := 0; k < len(edits); {
+		if edits[k].op == ' ' {
+			aLine, bLine, k = aLine+1, bLine+1, k+1
+			continue
+		}
+		// A hunk starts context lines before the change and runs until
+		// more than 2*context unchanged lines follow the last change
+		start := max(0, k-context)
+		end, unchanged := k, 0
+		for end < len(edits) && unchanged <= 2*context {
+			if edits[end].op == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+			end++
+		}
+		end -= max(0, unchanged-context)
+
+		aStart, bStart := aLine-(k-start), bLine-(k-start)
+		var body []string
+		aCount, bCount := 0, 0
+		for _, e := range edits[start:end] {
+			body = append(body, string(e.op)+e.line)
+			if e.op != '+' {
+				aCount++
+			}
+			if e.op != '-' {
+				bCount++
+			}
+		}
+		out = append(out, fmt.Sprintf("@@ -%d,%d +%d,%d @@", aStart, aCount, bStart, bCount))
+		out = append(out, body...)
+
+		for _, e := range edits[k:end] {
+			if e.op != '+' {
+				aLine++
+			}
+			if e.op != '-' {
+				bLine++
+			}
+		}
+		k = end
+	}
+	return out
+}
+
+func (m model) loadDeployPreview(deploymentName, namespace string) tea.Cmd {
+	ctx, image, container, env := m.tabCtx, m.selectedImage, m.deployContainer, m.deployEnv
+	return func() tea.Msg {
+		return deployPreviewMsg{deployment: deploymentName, preview: previewDeploy(ctx, image, deploymentName, namespace, container, env)}
+	}
+}
+
+// renderDeployPreview is the diff and side effects for the confirmation step.
+func (m model) renderDeployPreview() string {
+	preview := m.deployPreview
+	switch {
+	case preview == nil:
+		return "Computing changes..."
+	case preview.err != nil:
+		return fmt.Sprintf("⚠️  Can't preview the change: %v", preview.err)
+	}
+
+	var b strings.Builder
+	lines := preview.diff
+	if len(lines) > maxPreviewLines {
+		lines = lines[:maxPreviewLines]
+	}
+	for _, line := range lines {
+		line = truncateString(line, 66)
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			line = diffHunkStyle.Render(line)
+		case strings.HasPrefix(line, "+"):
+			line = diffAddedStyle.Render(line)
+		case strings.HasPrefix(line, "-"):
+			line = diffRemovedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	if hidden := len(preview.diff) - len(lines); hidden > 0 {
+		fmt.Fprintf(&b, "… %d more lines\n", hidden)
+	}
+	if len(preview.diff) > 0 {
+		b.WriteString("\n")
+	}
+	for _, effect := range preview.effects {
+		b.WriteString("• " + effect + "\n")
+	}
+	if len(preview.pods) > 0 {
+		b.WriteString("\nRecreated:\n")
+		for i, pod := range preview.pods {
+			if i == maxPreviewPods {
+				fmt.Fprintf(&b, "… and %d more\n", len(preview.pods)-i)
+				break
+			}
+			b.WriteString(previewPodLine(pod) + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// previewPodLine is a pod about to be recreated, with where it runs and how
+// often it restarted, which the rollout resets.
+func previewPodLine(pod kube.Pod) string {
+	node := pod.Node
+	if node == "" {
+		node = "unscheduled"
+	}
+	line := fmt.Sprintf("%-36s %-16s %3d restarts", truncateString(pod.Name, 36), truncateString(node, 16), pod.Restarts)
+	if pod.Phase != "Running" {
+		line += ", " + pod.Phase
+	}
+	return line
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Created deployments get readiness and liveness probes typed into the
+// create dialog the way the describe screen shows them: a kind, then for
+// http a path, optionally a :port and a delay, e.g.
+//
+//	http /healthz :8080 delay=10s
+//	tcp delay=5s
+//	none
+//
+// The port defaults to the container port, so the defaults, a TCP check of
+// that port, suit any image that listens on what it exposes.
+
+const (
+	defaultReadinessProbe = "tcp"
+	defaultLivenessProbe  = "tcp delay=15s"
+)
+
+// parseProbe reads a probe as typed into the dialog; none (or empty) is no
+// probe. port is the container port, probed when no other is given.
+func parseProbe(kind, value string, port int32) (*corev1.Probe, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || (len(fields) == 1 && strings.EqualFold(fields[0], "none")) {
+		return nil, nil
+	}
+	usage := fmt.Errorf("%s probe %q must be none, tcp or http /path, optionally with :port and delay=10s", kind, value)
+
+	var path string
+	var delay time.Duration
+	for _, field := range fields[1:] {
+		switch {
+		case strings.HasPrefix(field, "/"):
+			path = field
+		case strings.HasPrefix(field, ":"):
+			n, err := strconv.Atoi(field[1:])
+			if err != nil || n < 1 || n > 65535 {
+				return nil, fmt.Errorf("%s probe port must be between 1 and 65535", kind)
+			}
+			port = int32(n)
+		case strings.HasPrefix(field, "delay="):
+			d, err := time.ParseDuration(strings.TrimPrefix(field, "delay="))
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("%s probe delay %q must be a duration like 10s", kind, strings.TrimPrefix(field, "delay="))
+			}
+			delay = d
+		default:
+			return nil, usage
+		}
+	}
+
+	probe := &corev1.Probe{InitialDelaySeconds: int32(delay / time.Second)}
+	switch strings.ToLower(fields[0]) {
+	case "http", "http-get":
+		if path == "" {
+			path = "/"
+		}
+		probe.HTTPGet = &corev1.HTTPGetAction{Path: path, Port: intstr.FromInt32(port)}
+	case "tcp", "tcp-socket":
+		if path != "" {
+			return nil, usage
+		}
+		probe.TCPSocket = &corev1.TCPSocketAction{Port: intstr.FromInt32(port)}
+	default:
+		return nil, usage
+	}
+	return probe, nil
+}
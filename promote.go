@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
+
+// An environment is where a stage of the release runs: a cluster, a
+// namespace in it and the registry its images are pulled from. Promoting an
+// image copies it from the registry of one environment to the next one's
+// and rolls the next one's deployment to it, e.g. from dev to staging.
+
+type environment struct {
+	name      string
+	cluster   cluster
+	namespace string
+	registry  string // host:port or URL, as accepted by newRegistryClient
+}
+
+// configuredEnvironments is ENVIRONMENTS, a comma-separated list of
+// name=cluster/namespace/registry entries in promotion order, e.g.
+// "dev=minikube/default/default,staging=staging/web/https://registry.staging.example.com".
+// The cluster is a KUBE_CLUSTERS name or kube context, empty for the current
+// one; the registry a REGISTRIES name or a host.
+func configuredEnvironments() ([]environment, error) {
+	var environments []environment
+	for _, entry := range strings.Split(os.Getenv("ENVIRONMENTS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(entry, "=")
+		parts := strings.SplitN(spec, "/", 3)
+		if !ok || name == "" || len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("ENVIRONMENTS entry %q is not name=cluster/namespace/registry", entry)
+		}
+		environments = append(environments, environment{
+			name:      name,
+			cluster:   environmentCluster(parts[0]),
+			namespace: parts[1],
+			registry:  environmentRegistry(parts[2]),
+		})
+	}
+	return environments, nil
+}
+
+func environmentCluster(name string) cluster {
+	if name == "" {
+		return cluster{}
+	}
+	for _, c := range configuredClusters() {
+		if c.name == name {
+			return c
+		}
+	}
+	return cluster{name: name, context: name}
+}
+
+func environmentRegistry(name string) string {
+	for _, registry := range configuredRegistries() {
+		if registry.name == name {
+			return registry.host
+		}
+	}
+	return name
+}
+
+// findEnvironment returns the environment called name; an empty name is the
+// one after previous, or the first when previous is nil.
+func findEnvironment(environments []environment, name string, previous *environment) (environment, error) {
+	for i, env := range environments {
+		switch {
+		case name != "" && env.name == name:
+			return env, nil
+		case name == "" && previous == nil:
+			return env, nil
+		case name == "" && env.name == previous.name && i+1 < len(environments):
+			return environments[i+1], nil
+		}
+	}
+	if name == "" {
+		return environment{}, fmt.Errorf("there is no environment after %s to promote to", previous.name)
+	}
+	return environment{}, fmt.Errorf("unknown environment %q", name)
+}
+
+// promoteImage copies repository:tag from the registry of from to that of
+// to, unless it is there already, and points the container of to's
+// deployment at it, the first container when container is empty. The
+// deployment's previous image is journaled so undo can roll it back.
+func promoteImage(ctx context.Context, from, to environment, repository, tag, deploymentName, container string) (image string, err error) {
+	src, dst := newRegistryClient(ctx, from.registry), newRegistryClient(ctx, to.registry)
+	if src.BaseURL() != dst.BaseURL() {
+		body, _, err := src.Manifest(ctx, repository, tag)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch manifest of %s:%s from %s: %v", repository, tag, src.Host(), err)
+		}
+		if digest, err := dst.ManifestDigest(ctx, repository, tag); err != nil || digest != registry.ComputeDigest(body) {
+			syncer := &registrySyncer{src: src, dst: dst, state: loadSyncState(), retries: syncRetries(), parallel: uploadParallelism()}
+			if err := syncer.copyImage(ctx, repository, tag); err != nil {
+				return "", fmt.Errorf("failed to copy %s:%s to %s: %v", repository, tag, dst.Host(), err)
+			}
+		}
+	}
+
+	cluster := newClusterFor(ctx, to.cluster)
+	image = fmt.Sprintf("%s/%s:%s", dst.Host(), repository, tag)
+	kubeCtx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+	pullPolicy := resolvePullPolicy(kubeCtx, cluster, to.namespace, image, "")
+	previous, err := cluster.SetImage(kubeCtx, to.namespace, deploymentName, container, image, pullPolicy, deployAnnotations(kubeCtx, image))
+	if err != nil {
+		return "", err
+	}
+	journal(journalEntry{Kind: journalDeploy, Cluster: to.cluster.displayName(), Namespace: to.namespace, Name: deploymentName,
+		Container: previous.Name, Image: previous.Image, PullPolicy: string(previous.ImagePullPolicy)})
+	return image, nil
+}
+
+func runPromote(args []string) error {
+	fs := flag.NewFlagSet("promote", flag.ContinueOnError)
+	fromName := fs.String("from", "", "environment to promote from (default: the first in ENVIRONMENTS)")
+	toName := fs.String("to", "", "environment to promote to (default: the one after --from)")
+	deploymentName := fs.String("deployment", "", "deployment to roll in the target environment (default: the repository's last path element)")
+	container := fs.String("container", "", "container of the deployment to set the image of (default: the first)")
+	addRegistryFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: local-container-registry promote [flags] <repository:tag>")
+		fmt.Fprintln(fs.Output(), "\nEnvironments come from ENVIRONMENTS, e.g. dev=minikube/default/default,staging=staging/web/staging")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("promote needs an image")
+	}
+	i := strings.LastIndex(fs.Arg(0), ":")
+	repository, tag := fs.Arg(0)[:max(i, 0)], fs.Arg(0)[i+1:]
+	if i < 0 || repository == "" || !validTag.MatchString(tag) {
+		return fmt.Errorf("promote: %q is not repository:tag", fs.Arg(0))
+	}
+
+	environments, err := configuredEnvironments()
+	if err != nil {
+		return err
+	}
+	if len(environments) < 2 {
+		return fmt.Errorf("promote: ENVIRONMENTS must name at least two environments")
+	}
+	from, err := findEnvironment(environments, *fromName, nil)
+	if err != nil {
+		return err
+	}
+	to, err := findEnvironment(environments, *toName, &from)
+	if err != nil {
+		return err
+	}
+	if from.name == to.name {
+		return fmt.Errorf("promote: cannot promote %s to itself", from.name)
+	}
+	if *deploymentName == "" {
+		*deploymentName = path.Base(repository)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	connectDatabase(ctx)
+
+	fmt.Printf("🚀 Promoting %s:%s from %s to %s\n", repository, tag, from.name, to.name)
+	printBandwidthLimit()
+	image, err := promoteImage(ctx, from, to, repository, tag, *deploymentName, *container)
+	recordActionResult(ctx, "promote", fmt.Sprintf("%s:%s", repository, tag), err,
+		fmt.Sprintf("%s to %s, deployment %s/%s on %s", from.name, to.name, to.namespace, *deploymentName, to.cluster.displayName()))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ %s/%s on %s now runs %s\n", to.namespace, *deploymentName, to.cluster.displayName(), image)
+	return nil
+}
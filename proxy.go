@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Outbound HTTP goes through the proxy HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// name, the way curl and docker read them; localhost never is. A backend's
+// <BACKEND>_PROXY overrides them for its requests: REGISTRY_PROXY for
+// registries and the pull-through cache's upstream, GITHUB_PROXY for the
+// GitHub API, NOTIFY_PROXY for chat notification webhooks and AUDIT_PROXY
+// for AUDIT_LOG_URL. It's a proxy URL, with NO_PROXY still exempting hosts,
+// or "direct" for none. kubectl's client and the Docker daemon read the
+// standard variables themselves.
+
+// backendNotify is the chat notification webhooks, for NOTIFY_PROXY.
+const backendNotify = "NOTIFY"
+
+// proxyFuncs are the proxy functions by backend, read on first use so .env
+// has been loaded by then.
+var proxyFuncs sync.Map
+
+// backendProxy is the proxy of a request to backend, nil for none.
+func backendProxy(backend string, req *http.Request) (*url.URL, error) {
+	proxy, _ := proxyFuncs.LoadOrStore(backend, sync.OnceValue(func() func(*url.URL) (*url.URL, error) {
+		return proxyConfig(backend).ProxyFunc()
+	}))
+	return proxy.(func() func(*url.URL) (*url.URL, error))()(req.URL)
+}
+
+// proxyConfig is the standard variables' proxy configuration with the
+// backend's override applied.
+func proxyConfig(backend string) *httpproxy.Config {
+	config := httpproxy.FromEnvironment()
+	override := strings.TrimSpace(os.Getenv(backend + "_PROXY"))
+	switch {
+	case override == "":
+	case override == "direct":
+		config.HTTPProxy, config.HTTPSProxy = "", ""
+	default:
+		if proxyURL, err := url.Parse(override); err != nil || proxyURL.Host == "" {
+			log.Printf("⚠️  Ignoring %s_PROXY=%q: not a proxy URL like http://proxy.example.com:3128", backend, override)
+			break
+		}
+		config.HTTPProxy, config.HTTPSProxy = override, override
+	}
+	return config
+}
+
+// proxiedTransport is http.DefaultTransport sending backend's requests
+// through its proxy.
+func proxiedTransport(backend string) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return backendProxy(backend, req)
+	}
+	return transport
+}
+
+// describeProxy names the proxy requests to rawURL go through for backend,
+// "direct" for none.
+func describeProxy(backend, rawURL string) string {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "direct"
+	}
+	proxyURL, err := backendProxy(backend, req)
+	if err != nil || proxyURL == nil {
+		return "direct"
+	}
+	proxyURL.User = nil // credentials stay out of the output
+	return proxyURL.String()
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
+
+// Values of TableData.Source on the Cache tab
+const (
+	sourceUpstream = "upstream"
+	sourceStale    = "upstream (changed)"
+	sourceLocal    = "local push"
+	sourceUnknown  = "unknown"
+)
+
+// proxyMetrics mirrors the counters distribution publishes for pull-through
+// caches under registry.proxy in its expvar output (/debug/vars on the
+// REGISTRY_HTTP_DEBUG_ADDR listener).
+type proxyMetrics struct {
+	Requests    uint64
+	Hits        uint64
+	Misses      uint64
+	BytesPulled uint64
+	BytesPushed uint64
+}
+
+type proxyStats struct {
+	Blobs     proxyMetrics `json:"blobs"`
+	Manifests proxyMetrics `json:"manifests"`
+}
+
+func (m proxyMetrics) hitRate() string {
+	if m.Requests == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.0f%%", float64(m.Hits)*100/float64(m.Requests))
+}
+
+// upstreamRegistryURL is the registry the local one proxies, configured with
+// the same variable distribution itself reads.
+func upstreamRegistryURL() string {
+	return envOrDefault("REGISTRY_PROXY_REMOTEURL", "https://registry-1.docker.io")
+}
+
+// getProxyStats reads the proxy counters from the registry's debug listener.
+// It returns nil without an error when REGISTRY_DEBUG_ADDR isn't set or the
+// registry isn't running as a proxy.
+func getProxyStats(ctx context.Context) (*proxyStats, error) {
+	addr := envOrDefault("REGISTRY_DEBUG_ADDR", "")
+	if addr == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
+		addr = "http://" + addr
+	}
+
+	ctx, cancel := withBackendTimeout(ctx, backendRegistry)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(addr, "/")+"/debug/vars", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET /debug/vars: %s", resp.Status)
+	}
+
+	var vars struct {
+		Registry struct {
+			Proxy *proxyStats `json:"proxy"`
+		} `json:"registry"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		return nil, err
+	}
+	return vars.Registry.Proxy, nil
+}
+
+// upstreamManifestDigest resolves a tag on the upstream registry. Docker Hub
+// and most public registries answer anonymous requests with a Bearer
+// challenge, so one anonymous token is fetched when asked for. An empty
+// digest without an error means the upstream doesn't have the tag.
+func upstreamManifestDigest(ctx context.Context, upstream, repository, tag string) (string, error) {
+	ctx, cancel := withBackendTimeout(ctx, backendRegistry)
+	defer cancel()
+
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", strings.TrimSuffix(upstream, "/"), repository, tag)
+	head := func(token string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", strings.Join(registry.ManifestMediaTypes, ", "))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := tracedHTTPClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		return resp, nil
+	}
+
+	resp, err := head("")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err := fetchAnonymousToken(ctx, resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return "", err
+		}
+		if resp, err = head(token); err != nil {
+			return "", err
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Header.Get("Docker-Content-Digest"), nil
+	case http.StatusNotFound, http.StatusUnauthorized:
+		// Hub answers 401 rather than 404 for repositories that don't exist
+		return "", nil
+	default:
+		return "", fmt.Errorf("HEAD %s: %s", manifestURL, resp.Status)
+	}
+}
+
+// fetchAnonymousToken answers a `Bearer realm="...",service="...",scope="..."`
+// challenge without credentials.
+func fetchAnonymousToken(ctx context.Context, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported auth challenge %q", challenge)
+	}
+	values := make(map[string]string)
+	for _, part := range strings.Split(params, ",") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+			values[key] = strings.Trim(value, `"`)
+		}
+	}
+	if values["realm"] == "" {
+		return "", fmt.Errorf("auth challenge without realm: %q", challenge)
+	}
+
+	query := url.Values{}
+	if service := values["service"]; service != "" {
+		query.Set("service", service)
+	}
+	if scope := values["scope"]; scope != "" {
+		query.Set("scope", scope)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, values["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := tracedHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request: %s", resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// getCacheInventory classifies every tag in the local registry by comparing
+// it with the same repository:tag upstream: identical digests mean the image
+// came through the pull-through cache, a missing upstream tag means it was
+// pushed locally.
+func getCacheInventory(ctx context.Context) ([]TableData, error) {
+	client := newRegistryClient(ctx, getRegistryHost())
+	upstream := upstreamRegistryURL()
+
+	repositories, err := listRepositories(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repositories on %s: %v", client.Host(), err)
+	}
+
+	var rows []TableData
+	for _, repo := range repositories {
+		tags, err := client.Tags(ctx, repo)
+		if err != nil {
+			continue
+		}
+		for _, tag := range tags {
+			rows = append(rows, TableData{ImageTag: fmt.Sprintf("%s:%s", repo, tag)})
+		}
+	}
+
+	// Upstream lookups are slow round trips to the internet, so run a few at once
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, 4)
+	for i := range rows {
+		wg.Add(1)
+		go func(row *TableData) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			repo, tag, _ := strings.Cut(row.ImageTag, ":")
+			row.ImageSize = getImageSize(ctx, client.Host(), repo, tag)
+			row.Source = sourceUnknown
+			body, _, err := client.Manifest(ctx, repo, tag)
+			if err != nil {
+				return
+			}
+			row.ImageDigest = registry.ComputeDigest(body)
+			upstreamDigest, err := upstreamManifestDigest(ctx, upstream, repo, tag)
+			switch {
+			case err != nil:
+				row.Source = sourceUnknown
+			case upstreamDigest == "":
+				row.Source = sourceLocal
+			case upstreamDigest == row.ImageDigest:
+				row.Source = sourceUpstream
+			default:
+				row.Source = sourceStale
+			}
+		}(&rows[i])
+	}
+	wg.Wait()
+	return rows, nil
+}
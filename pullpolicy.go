@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/backend"
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
+
+// pullPolicyAuto picks Never or IfNotPresent depending on whether the
+// cluster's nodes can pull from the registry themselves.
+const pullPolicyAuto = "auto"
+
+// parsePullPolicy accepts the Kubernetes policy names in any case, plus
+// "auto". An empty value stays empty.
+func parsePullPolicy(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	for _, policy := range []string{pullPolicyAuto, string(corev1.PullNever), string(corev1.PullIfNotPresent), string(corev1.PullAlways)} {
+		if strings.EqualFold(value, policy) {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("pull policy %q must be auto, Never, IfNotPresent or Always", value)
+}
+
+// configuredPullPolicy is the pull policy for the current kube context:
+// its entry in IMAGE_PULL_POLICY_CONTEXTS ("minikube=Never,k3d-dev=Always"),
+// else IMAGE_PULL_POLICY, else auto.
+func configuredPullPolicy() string {
+	if overrides := os.Getenv("IMAGE_PULL_POLICY_CONTEXTS"); overrides != "" {
+		if current := currentKubeContext(); current != "" {
+			for _, pair := range strings.Split(overrides, ",") {
+				name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || strings.TrimSpace(name) != current {
+					continue
+				}
+				if policy, err := parsePullPolicy(value); err == nil && policy != "" {
+					return policy
+				}
+			}
+		}
+	}
+	if policy, err := parsePullPolicy(os.Getenv("IMAGE_PULL_POLICY")); err == nil && policy != "" {
+		return policy
+	}
+	return pullPolicyAuto
+}
+
+func currentKubeContext() string {
+	c := currentCluster()
+	if c.context != "" {
+		return c.context
+	}
+	config, err := c.clientConfig().RawConfig()
+	if err != nil {
+		return ""
+	}
+	return config.CurrentContext
+}
+
+// resolvePullPolicy turns the requested policy (empty means the configured
+// one) into the policy written to the pod spec. Auto-detection needs a
+// cluster; without one it keeps the historical Never, which works for
+// images side-loaded into Minikube.
+func resolvePullPolicy(ctx context.Context, cluster backend.ClusterAPI, namespace, image, requested string) corev1.PullPolicy {
+	policy := requested
+	if policy == "" {
+		policy = configuredPullPolicy()
+	}
+	if policy != pullPolicyAuto {
+		return corev1.PullPolicy(policy)
+	}
+	if cluster != nil && nodesCanPull(ctx, cluster, namespace, image) {
+		return corev1.PullIfNotPresent
+	}
+	return corev1.PullNever
+}
+
+// imageRegistryHost returns the registry part of an image reference, empty
+// for Docker Hub images.
+func imageRegistryHost(image string) string {
+	return registry.ReferenceHost(image)
+}
+
+// pullReachability caches nodesCanPull per registry host for the session;
+// the answer only changes when the cluster is reconfigured.
+var pullReachability sync.Map
+
+// nodesCanPull reports whether the cluster's nodes can pull from the
+// image's registry. A pod that already pulled from it settles the question;
+// otherwise, with IMAGE_PULL_PROBE=true, a throwaway probe pod tries to pull
+// the image. Without the probe, nothing is created on the cluster and the
+// answer is no until a pod has pulled from the registry.
+func nodesCanPull(ctx context.Context, cluster backend.ClusterAPI, namespace, image string) bool {
+	host := imageRegistryHost(image)
+	if host == "" {
+		return true
+	}
+	if cached, ok := pullReachability.Load(host); ok {
+		return cached.(bool)
+	}
+
+	reachable := registryPulledBefore(ctx, cluster, host)
+	if !reachable {
+		if os.Getenv("IMAGE_PULL_PROBE") != "true" {
+			return false
+		}
+		var err error
+		if reachable, err = probeRegistryPull(ctx, cluster, namespace, image); err != nil {
+			// Undecided (no permission to create pods, timeout, ...): don't cache
+			return false
+		}
+	}
+	pullReachability.Store(host, reachable)
+	return reachable
+}
+
+// registryPulledBefore looks for running containers whose image ID records
+// a pull from host. Side-loaded images carry no repo digest from it.
+func registryPulledBefore(ctx context.Context, cluster backend.ClusterAPI, host string) bool {
+	pods, err := cluster.ListPods(ctx, metav1.NamespaceAll, "")
+	if err != nil {
+		return false
+	}
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			imageID := strings.TrimPrefix(status.ImageID, "docker-pullable://")
+			if strings.HasPrefix(imageID, host+"/") && strings.Contains(imageID, "@sha256:") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// probeRegistryPull starts a pod that must pull image and watches how far it
+// gets. Its command doesn't exist, so the image is never actually run: a
+// start error after the pull still proves the node reached the registry.
+func probeRegistryPull(ctx context.Context, cluster backend.ClusterAPI, namespace, image string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "lcr-pull-probe-",
+			Namespace:    namespace,
+			Labels:       map[string]string{"app.kubernetes.io/managed-by": "local-container-registry"},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{{
+				Name:            "probe",
+				Image:           image,
+				ImagePullPolicy: corev1.PullAlways,
+				Command:         []string{"/lcr-pull-probe"},
+			}},
+		},
+	}
+	if _, _, ok := registryCredentials(imageRegistryHost(image)); ok {
+		pod.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: pullSecretName()}}
+	}
+	created, err := cluster.CreatePod(ctx, pod)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		// The probe's own context may be spent; clean up regardless
+		deleteCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		cluster.DeletePod(deleteCtx, namespace, created.Name)
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false, fmt.Errorf("pull probe timed out: %v", ctx.Err())
+		case <-ticker.C:
+		}
+		current, err := cluster.Pod(ctx, namespace, created.Name)
+		if err != nil {
+			continue
+		}
+		for _, status := range current.Status.ContainerStatuses {
+			if status.ImageID != "" {
+				return true, nil
+			}
+			if waiting := status.State.Waiting; waiting != nil {
+				switch waiting.Reason {
+				case "ErrImagePull", "ImagePullBackOff", "InvalidImageName":
+					return false, nil
+				case "CreateContainerError", "RunContainerError":
+					return true, nil
+				}
+			}
+		}
+	}
+}
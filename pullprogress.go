@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/docker"
+)
+
+// Ctrl+P pulls with a progress bar per layer, in a modal that X cancels the
+// pull from. ESC hides the modal and lets the pull go on in the status bar.
+
+// pullProgress is the state of a pull, updated by the pull as the engine
+// reports it and drawn by the modal.
+type pullProgress struct {
+	mu          sync.Mutex
+	image       string
+	operationID int
+	order       []string // layer IDs in the order the engine named them
+	layers      map[string]docker.Layer
+	status      string // the latest update not about a layer
+}
+
+type pullTickMsg struct{}
+
+func newPullProgress(image string) *pullProgress {
+	return &pullProgress{image: image, layers: make(map[string]docker.Layer)}
+}
+
+func (p *pullProgress) update(layer docker.Layer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if layer.ID == "" || layer.ID == tagOf(p.image) {
+		// "Pulling from ..." names the tag, not a layer
+		p.status = strings.TrimSpace(layer.Status)
+		return
+	}
+	if _, seen := p.layers[layer.ID]; !seen {
+		p.order = append(p.order, layer.ID)
+	}
+	p.layers[layer.ID] = layer
+}
+
+// finish reports how the pull ended.
+func (p *pullProgress) finish(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case errors.Is(err, context.Canceled):
+		p.status = "Cancelled"
+	case err != nil:
+		p.status = "❌ " + err.Error()
+	default:
+		p.status = "✅ Pulled " + p.image
+	}
+}
+
+func tagOf(ref string) string {
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[i+1:]
+	}
+	return "latest"
+}
+
+// pullImage pulls ref, reporting each layer to progress. When the engine
+// API isn't reachable directly it pulls through the CLI, without per-layer
+// progress.
+func pullImage(ctx context.Context, ref string, progress *pullProgress) error {
+	var auth docker.Auth
+	host := imageRegistryHost(ref)
+	if username, password, ok := registryCredentials(host); ok && host != "" {
+		auth = docker.Auth{Username: username, Password: password, ServerAddress: host}
+	}
+	err := containerRuntime(ctx).PullProgress(ctx, ref, auth, progress.update)
+	if errors.Is(err, docker.ErrNoEngineAPI) {
+		progress.update(docker.Layer{Status: "Pulling through the docker CLI, which doesn't report progress"})
+		return containerRuntime(ctx).Pull(ctx, ref, nil)
+	}
+	return err
+}
+
+func pullTick() tea.Cmd {
+	return tea.Tick(200*time.Millisecond, func(time.Time) tea.Msg { return pullTickMsg{} })
+}
+
+// pullRunning reports whether the modal's pull is still in flight.
+func (m model) pullRunning() bool {
+	if m.pull == nil {
+		return false
+	}
+	for _, op := range m.operations {
+		if op.id == m.pull.operationID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m model) updatePullProgress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "x", "ctrl+x":
+		if m.pullRunning() {
+			m.cancelOperation(m.pull.operationID)
+		}
+	case "esc", "q", "enter":
+		m.showPull = false
+	}
+	return m, nil
+}
+
+func (m model) renderPullProgress() string {
+	p := m.pull
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Pulling %s\n\n", p.image)
+	if len(p.order) == 0 && p.status == "" {
+		b.WriteString("Waiting for the docker engine...\n")
+	}
+	for _, id := range p.order {
+		layer := p.layers[id]
+		line := fmt.Sprintf("%-12s %-18s", truncateString(id, 12), truncateString(layer.Status, 18))
+		if layer.Total > 0 {
+			line += " " + progressBar(layer.Current, layer.Total, 24) +
+				fmt.Sprintf(" %s / %s", formatBytes(layer.Current), formatBytes(layer.Total))
+		}
+		b.WriteString(line + "\n")
+	}
+	if p.status != "" {
+		b.WriteString("\n" + truncateString(p.status, 90) + "\n")
+	}
+
+	if m.pullRunning() {
+		b.WriteString("\nx to cancel the pull, ESC to continue it in the background")
+	} else {
+		b.WriteString("\nESC to close")
+	}
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, modalStyle.Width(100).Height(0).Render(b.String()), lipgloss.WithWhitespaceChars("░"))
+}
+
+func progressBar(current, total int64, width int) string {
+	filled := int(min(current, total) * int64(width) / total)
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]"
+}
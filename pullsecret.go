@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/homedir"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/backend"
+)
+
+func pullSecretName() string {
+	return envOrDefault("PULL_SECRET_NAME", "local-registry")
+}
+
+// registryCredentials returns the credentials for the registry known as
+// host: those `login` stored in the keyring, else
+// REGISTRY_USERNAME/REGISTRY_PASSWORD when set, else what `docker login`
+// stored for host (or the local registry's own host) in
+// ~/.docker/config.json. Credential helpers (credsStore) aren't consulted.
+func registryCredentials(host string) (username, password string, ok bool) {
+	if username, password, ok := keyringRegistryCredentials(host); ok {
+		return username, password, true
+	}
+	if username, password := os.Getenv("REGISTRY_USERNAME"), os.Getenv("REGISTRY_PASSWORD"); username != "" {
+		return username, password, true
+	}
+
+	configDir := os.Getenv("DOCKER_CONFIG")
+	if configDir == "" {
+		configDir = filepath.Join(homedir.HomeDir(), ".docker")
+	}
+	content, err := os.ReadFile(filepath.Join(configDir, "config.json"))
+	if err != nil {
+		return "", "", false
+	}
+	var config struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return "", "", false
+	}
+	for _, candidate := range []string{host, getRegistryHost()} {
+		entry, found := config.Auths[candidate]
+		if !found {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			continue
+		}
+		if username, password, ok := strings.Cut(string(decoded), ":"); ok {
+			return username, password, true
+		}
+	}
+	return "", "", false
+}
+
+// dockerConfigJSON is the .dockerconfigjson payload of a
+// kubernetes.io/dockerconfigjson Secret.
+func dockerConfigJSON(server, username, password string) ([]byte, error) {
+	auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			server: map[string]string{"username": username, "password": password, "auth": auth},
+		},
+	})
+}
+
+// ensurePullSecret creates or updates the pull secret for server in
+// namespace. It returns the secret's name, or "" when no credentials are
+// configured and pods can only pull anonymously.
+func ensurePullSecret(ctx context.Context, cluster backend.ClusterAPI, namespace, server string) (string, error) {
+	username, password, ok := registryCredentials(server)
+	if !ok {
+		return "", nil
+	}
+	payload, err := dockerConfigJSON(server, username, password)
+	if err != nil {
+		return "", err
+	}
+
+	name := pullSecretName()
+	existing, err := cluster.Secret(ctx, namespace, name)
+	switch {
+	case apierrors.IsNotFound(err):
+		err = cluster.CreateSecret(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    map[string]string{"app.kubernetes.io/managed-by": "local-container-registry"},
+			},
+			Type: corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{corev1.DockerConfigJsonKey: payload},
+		})
+	case err == nil:
+		if existing.Type != corev1.SecretTypeDockerConfigJson {
+			return "", fmt.Errorf("secret %s/%s exists but is of type %s", namespace, name, existing.Type)
+		}
+		existing.Data = map[string][]byte{corev1.DockerConfigJsonKey: payload}
+		err = cluster.UpdateSecret(ctx, existing)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write pull secret %s/%s: %v", namespace, name, err)
+	}
+	return name, nil
+}
+
+func runPullSecret(args []string) error {
+	defaultNamespace := envOrDefault("KUBERNETES_NAMESPACE", "default")
+	defaultServer := envOrDefault("KUBERNETES_REGISTRY_HOST", getRegistryHost())
+
+	fs := flag.NewFlagSet("pull-secret", flag.ContinueOnError)
+	namespaces := fs.String("namespace", defaultNamespace, "comma-separated namespaces to create the secret in")
+	server := fs.String("server", defaultServer, "registry host as the cluster pulls from it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if _, _, ok := registryCredentials(*server); !ok {
+		return fmt.Errorf("pull-secret: no credentials for %s; run login %s, set REGISTRY_USERNAME/REGISTRY_PASSWORD or run docker login %s", *server, *server, *server)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	cluster := newCluster(ctx)
+	for _, namespace := range strings.Split(*namespaces, ",") {
+		namespace = strings.TrimSpace(namespace)
+		name, err := ensurePullSecret(ctx, cluster, namespace, *server)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Secret %s/%s holds the credentials for %s\n", namespace, name, *server)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+var disabledStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+
+// permission is a verb on a resource the TUI's actions need.
+type permission struct {
+	verb, group, resource string
+}
+
+var (
+	permCreateDeployments = permission{"create", "apps", "deployments"}
+	permUpdateDeployments = permission{"update", "apps", "deployments"}
+	permPatchDeployments  = permission{"patch", "apps", "deployments"}
+	permDeleteDeployments = permission{"delete", "apps", "deployments"}
+)
+
+// accessDecision is the API server's answer for one permission.
+type accessDecision struct {
+	allowed bool
+	reason  string
+}
+
+type permissionsMsg struct {
+	namespace string
+	decisions map[permission]accessDecision
+}
+
+// checkPermissions asks the API server with SelfSubjectAccessReviews which of
+// perms the current credentials have in namespace. Permissions that couldn't
+// be checked are left out, so actions stay available and fail, if at all,
+// with the API server's own error.
+func checkPermissions(ctx context.Context, namespace string, perms ...permission) map[permission]accessDecision {
+	decisions := make(map[permission]accessDecision)
+	cluster := newCluster(ctx)
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	for _, p := range perms {
+		status, err := cluster.ReviewAccess(ctx, authorizationv1.ResourceAttributes{
+			Namespace: namespace,
+			Verb:      p.verb,
+			Group:     p.group,
+			Resource:  p.resource,
+		})
+		if err != nil {
+			continue
+		}
+		decisions[p] = accessDecision{allowed: status.Allowed, reason: status.Reason}
+	}
+	return decisions
+}
+
+func (m model) loadPermissions() tea.Cmd {
+	if !kubernetesEnabled() {
+		return nil
+	}
+	ctx := m.ctx
+	namespace := envOrDefault("KUBERNETES_NAMESPACE", "default")
+	return func() tea.Msg {
+		decisions := checkPermissions(ctx, namespace, permCreateDeployments, permUpdateDeployments, permPatchDeployments, permDeleteDeployments)
+		return permissionsMsg{namespace: namespace, decisions: decisions}
+	}
+}
+
+// denied explains why an action needing p isn't allowed, by the local
+// user's role or the cluster's RBAC, or returns "" when it is or the
+// permission couldn't be checked.
+func (m model) denied(p permission) string {
+	if reason := m.roleDenied(p); reason != "" {
+		return reason
+	}
+	decision, ok := m.permissions[p]
+	if !ok || decision.allowed {
+		return ""
+	}
+	explanation := fmt.Sprintf("🔒 You can't %s %s in namespace %s", p.verb, p.resource, m.permissionsNamespace)
+	if decision.reason != "" {
+		explanation += " (" + decision.reason + ")"
+	}
+	return explanation
+}
+
+// option renders a dialog option, greyed out with the reason when p is denied.
+func (m model) option(label string, p permission) string {
+	if reason := m.denied(p); reason != "" {
+		return disabledStyle.Render(label) + "\n    " + reason
+	}
+	return label
+}
+
+// deployPermission is what deploying to an existing deployment needs; in
+// GitOps mode the cluster isn't touched, so nothing.
+func (m model) deployPermission() permission {
+	if gitopsEnabled() {
+		return permission{}
+	}
+	return permUpdateDeployments
+}
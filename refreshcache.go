@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A refresh of the Docker tab HEADs each tag's manifest first. When the
+// Docker-Content-Digest is the one the last refresh saw, the tag's row is
+// reused as it was, without fetching its manifest, config blob or referrers
+// again; only the scan summary is asked again, since scans finish after the
+// push. The Docker tab's status line shows how long refreshes take and how
+// many tags they skipped.
+
+// refreshStats describes a refresh of the registry's images.
+type refreshStats struct {
+	host         string
+	repositories int
+	tags         int
+	unchanged    int // tags whose digest the HEAD found unchanged
+	took         time.Duration
+	previousTook time.Duration // of the refresh before, 0 for the first
+}
+
+var (
+	refreshMu sync.Mutex
+	// refreshedImages are the images of the last refresh, by registry host
+	// and repository:tag
+	refreshedImages  map[string]DockerImage
+	lastRefreshStats *refreshStats
+)
+
+func refreshKey(host, repository, tag string) string {
+	return host + "/" + repository + ":" + tag
+}
+
+// previousRefresh returns a copy of the images of the last refresh.
+func previousRefresh() map[string]DockerImage {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+	images := make(map[string]DockerImage, len(refreshedImages))
+	for key, image := range refreshedImages {
+		images[key] = image
+	}
+	return images
+}
+
+// recordRefresh replaces the last refresh's images, so tags deleted since
+// are forgotten, and its stats.
+func recordRefresh(images map[string]DockerImage, stats refreshStats) {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+	if lastRefreshStats != nil && lastRefreshStats.host == stats.host {
+		stats.previousTook = lastRefreshStats.took
+	}
+	refreshedImages = images
+	lastRefreshStats = &stats
+}
+
+// registryRefreshStats are the stats of the last refresh, nil before one
+// reached the registry.
+func registryRefreshStats() *refreshStats {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+	return lastRefreshStats
+}
+
+func (s refreshStats) String() string {
+	status := fmt.Sprintf("Refreshed %d tags of %d repositories in %s, %d unchanged since the last refresh",
+		s.tags, s.repositories, s.took.Round(time.Millisecond), s.unchanged)
+	if s.previousTook > 0 {
+		status += fmt.Sprintf(" (previous refresh %s)", s.previousTook.Round(time.Millisecond))
+	}
+	return status
+}
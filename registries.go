@@ -0,0 +1,281 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
+
+// maxComparedTags keeps the comparison dialog on screen
+const maxComparedTags = 20
+
+// registryEndpoint is a registry the Docker tab can switch to.
+type registryEndpoint struct {
+	name string
+	host string // host:port or URL, as accepted by newRegistryClient
+}
+
+// activeRegistry is the registry picked in the switcher, empty for
+// REGISTRY_HOST.
+var activeRegistry struct {
+	sync.RWMutex
+	host string
+}
+
+func useRegistry(host string) {
+	activeRegistry.Lock()
+	defer activeRegistry.Unlock()
+	activeRegistry.host = host
+}
+
+func selectedRegistryHost() string {
+	activeRegistry.RLock()
+	defer activeRegistry.RUnlock()
+	return activeRegistry.host
+}
+
+// configuredRegistries is the default registry followed by REGISTRIES, a
+// comma-separated list of name=host entries or bare hosts, e.g.
+// "k3d=k3d-registry.localhost:5050,staging=https://registry.staging.example.com".
+func configuredRegistries() []registryEndpoint {
+	registries := []registryEndpoint{{name: "default", host: defaultRegistryHost()}}
+	for _, entry := range strings.Split(os.Getenv("REGISTRIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, host, ok := strings.Cut(entry, "=")
+		if !ok {
+			name, host = entry, entry
+		}
+		if host == registries[0].host {
+			registries[0].name = name
+			continue
+		}
+		registries = append(registries, registryEndpoint{name: name, host: host})
+	}
+	return registries
+}
+
+// registryName is the configured name of the active registry.
+func registryName() string {
+	host := getRegistryHost()
+	for _, registry := range configuredRegistries() {
+		if registry.host == host {
+			return registry.name
+		}
+	}
+	return host
+}
+
+// tagComparison is which tags of a repository exist in which registry.
+type tagComparison struct {
+	repository string
+	registries []registryEndpoint
+	errs       []error             // per registry, set when it couldn't be listed
+	digests    map[string][]string // tag -> digest per registry, "" where missing
+	tags       []string            // sorted
+}
+
+// compareTags looks up every tag of repository in each registry, with the
+// digest it points to there so diverged copies stand out.
+func compareTags(ctx context.Context, registries []registryEndpoint, repository string) tagComparison {
+	comparison := tagComparison{
+		repository: repository,
+		registries: registries,
+		errs:       make([]error, len(registries)),
+		digests:    make(map[string][]string),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, endpoint := range registries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client := newRegistryClient(ctx, endpoint.host)
+			tags, err := client.Tags(ctx, repository)
+			if err != nil {
+				// A registry without the repository has none of its tags
+				if !strings.Contains(err.Error(), "404 Not Found") {
+					comparison.errs[i] = err
+				}
+				return
+			}
+			for _, tag := range tags {
+				var digest string
+				if body, _, err := client.Manifest(ctx, repository, tag); err == nil {
+					digest = registry.ComputeDigest(body)
+				}
+				mu.Lock()
+				if comparison.digests[tag] == nil {
+					comparison.digests[tag] = make([]string, len(registries))
+				}
+				// A tag whose manifest can't be read still exists
+				comparison.digests[tag][i] = cmp.Or(digest, "?")
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for tag := range comparison.digests {
+		comparison.tags = append(comparison.tags, tag)
+	}
+	sort.Slice(comparison.tags, func(i, j int) bool { return compareVersionTags(comparison.tags[i], comparison.tags[j]) < 0 })
+	return comparison
+}
+
+// repositoryOf is the repository part of a registry image reference.
+func repositoryOf(imageTag string) string {
+	name, _ := normalizeImageReference(imageTag)
+	return name[:strings.LastIndex(name, ":")]
+}
+
+type tagComparisonMsg struct {
+	comparison tagComparison
+}
+
+func (m model) loadTagComparison(repository string) tea.Cmd {
+	ctx, registries := m.tabCtx, m.registries
+	return func() tea.Msg {
+		return tagComparisonMsg{comparison: compareTags(ctx, registries, repository)}
+	}
+}
+
+// switchRegistry points the Docker tab, deploys and pulls at host and lists
+// its images.
+func (m *model) switchRegistry(registry registryEndpoint) tea.Cmd {
+	recordSessionStep(sessionStep{Action: sessionRegistry, Registry: registry.host})
+	if registry.host == defaultRegistryHost() {
+		useRegistry("")
+	} else {
+		useRegistry(registry.host)
+	}
+	m.statusMessage = fmt.Sprintf("📦 Switched to registry %s (%s)", registry.name, registry.host)
+	m.dockerData, m.stale[1] = nil, nil
+	m.updateTableForTab()
+	return m.refreshDockerData()
+}
+
+func (m model) updateRegistryPicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "up", "k":
+		if m.registryCursor > 0 {
+			m.registryCursor--
+		}
+	case "down", "j":
+		if m.registryCursor < len(m.registries)-1 {
+			m.registryCursor++
+		}
+	case "enter":
+		m.showRegistryPicker = false
+		if m.registryCursor < len(m.registries) {
+			cmd := m.switchRegistry(m.registries[m.registryCursor])
+			return m, cmd
+		}
+	case "esc", "q":
+		m.showRegistryPicker = false
+	}
+	return m, nil
+}
+
+func (m model) renderRegistryPicker() string {
+	var b strings.Builder
+	b.WriteString("Switch Registry\n\n")
+	active := getRegistryHost()
+	for i, registry := range m.registries {
+		prefix := "  "
+		if i == m.registryCursor {
+			prefix = "→ "
+		}
+		line := fmt.Sprintf("%s  %s", registry.name, registry.host)
+		if registry.host == active {
+			line += "  (active)"
+		}
+		b.WriteString(prefix + line + "\n")
+	}
+	if len(m.registries) == 1 {
+		b.WriteString("\nList more registries in REGISTRIES to switch between them\n")
+	}
+	b.WriteString("\nUse ↑/↓ to navigate, Enter to switch, ESC to cancel")
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, modalStyle.Width(72).Height(0).Render(b.String()), lipgloss.WithWhitespaceChars("░"))
+}
+
+func (m model) updateTagComparison(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "q", "w":
+		m.showTagComparison = false
+	}
+	return m, nil
+}
+
+// renderTagComparison shows a tag per line and a column per registry: ✓
+// where the tag matches the first registry having it, ≠ where it points at
+// a different image, - where it's missing.
+func (m model) renderTagComparison() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tags of %s across registries\n\n", m.comparisonRepository)
+
+	comparison := m.tagComparison
+	if comparison == nil || comparison.repository != m.comparisonRepository {
+		b.WriteString("Comparing registries...\n")
+	} else {
+		fmt.Fprintf(&b, "%-24s", "TAG")
+		for _, registry := range comparison.registries {
+			fmt.Fprintf(&b, " %-14s", truncateString(registry.name, 14))
+		}
+		b.WriteString("\n")
+
+		tags := comparison.tags
+		if len(tags) > maxComparedTags {
+			tags = tags[len(tags)-maxComparedTags:]
+		}
+		for _, tag := range tags {
+			fmt.Fprintf(&b, "%-24s", truncateString(tag, 24))
+			reference := ""
+			for _, digest := range comparison.digests[tag] {
+				mark := "-"
+				switch {
+				case digest == "":
+				case reference == "" || digest == reference:
+					reference, mark = cmp.Or(reference, digest), "✓"
+				default:
+					mark = "≠ " + shortDigest(digest)
+				}
+				fmt.Fprintf(&b, " %-14s", mark)
+			}
+			b.WriteString("\n")
+		}
+		if hidden := len(comparison.tags) - len(tags); hidden > 0 {
+			fmt.Fprintf(&b, "… %d more tags\n", hidden)
+		}
+		if len(comparison.tags) == 0 {
+			b.WriteString("No registry has this repository\n")
+		}
+		for i, err := range comparison.errs {
+			if err != nil {
+				fmt.Fprintf(&b, "⚠️  %s: %v\n", comparison.registries[i].name, truncateString(err.Error(), 60))
+			}
+		}
+	}
+	b.WriteString("\nESC to close")
+
+	style := modalStyle.Width(max(72, 30+15*len(m.registries))).Height(0)
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, style.Render(b.String()), lipgloss.WithWhitespaceChars("░"))
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/backend"
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
+
+// The registry client lives in pkg/registry so other programs can embed it;
+// these names keep the rest of the tool reading as before. The tool holds
+// clients as backend.RegistryAPI, so tests can swap in a mock.
+type (
+	registryClient     = backend.RegistryAPI
+	registryDescriptor = registry.Descriptor
+	registryManifest   = registry.Manifest
+)
+
+// registryHTTPClient traces every attempt at a registry request and retries
+// the ones failing transiently, over the registry connection pool.
+var registryHTTPClient = &http.Client{Transport: retryTransport{backend: backendRegistry, base: tracingTransport{base: pooledRegistryTransport{}}}}
+
+// getRegistryHost is the registry picked in the switcher, REGISTRY_HOST
+// until one is.
+func getRegistryHost() string {
+	if host := selectedRegistryHost(); host != "" {
+		return host
+	}
+	return defaultRegistryHost()
+}
+
+func defaultRegistryHost() string {
+	// Use service name when running in Docker Compose, fallback to localhost for local development
+	registryHost := os.Getenv("REGISTRY_HOST")
+	if registryHost == "" {
+		if inContainer() {
+			registryHost = "registry:5000"
+		} else {
+			registryHost = "localhost:5000"
+		}
+	}
+	return registryHost
+}
+
+// newRegistryClient accepts either a bare host:port (plain HTTP, like the
+// local registry) or a URL with an explicit http:// or https:// scheme.
+// The registry backend ctx carries replaces it, whatever the host.
+func newRegistryClient(ctx context.Context, host string) registryClient {
+	if registry := backendsOf(ctx).registry; registry != nil {
+		return registry
+	}
+	return registry.NewClient(host, registry.Options{
+		HTTPClient: registryHTTPClient,
+		Timeout:    backendTimeout(backendRegistry),
+		Cache:      getRegistryCache(),
+		Bandwidth:  registryBandwidth(),
+		Quirks:     configuredQuirks(host),
+	})
+}
+
+// registryQuirkModes are the modes REGISTRY_QUIRKS sets, by registry name
+// or host. It's a comma-separated list of registry=mode entries, the mode
+// being auto (detect the quirks, the default), spec (assume none) or quirks
+// joined by +, e.g. "ecr=no-catalog+no-delete,default=spec".
+var registryQuirkModes = sync.OnceValue(func() map[string]*registry.Quirks {
+	modes := make(map[string]*registry.Quirks)
+	for _, entry := range strings.Split(os.Getenv("REGISTRY_QUIRKS"), ",") {
+		entry = strings.TrimSpace(entry)
+		name, mode, ok := strings.Cut(entry, "=")
+		if !ok {
+			if entry != "" {
+				log.Printf("⚠️  Ignoring REGISTRY_QUIRKS entry %q: want registry=mode", entry)
+			}
+			continue
+		}
+		if mode == "auto" {
+			continue
+		}
+		quirks, err := registry.ParseQuirks(mode)
+		if err != nil {
+			log.Printf("⚠️  Ignoring REGISTRY_QUIRKS entry %q: %v", entry, err)
+			continue
+		}
+		modes[name] = &quirks
+	}
+	return modes
+})
+
+// configuredQuirks are the quirks REGISTRY_QUIRKS sets for the registry at
+// host, nil to detect them.
+func configuredQuirks(host string) *registry.Quirks {
+	modes := registryQuirkModes()
+	if len(modes) == 0 {
+		return nil
+	}
+	if quirks, ok := modes[host]; ok {
+		return quirks
+	}
+	for _, endpoint := range configuredRegistries() {
+		if endpoint.host == host {
+			return modes[endpoint.name]
+		}
+	}
+	return nil
+}
+
+// registryRepositoryLists are the repositories REGISTRY_REPOSITORIES names,
+// by registry name or host, for registries without /v2/_catalog. It's a
+// comma-separated list of registry=repositories entries, the repositories
+// joined by +, e.g. "ecr=web+api+team/worker".
+var registryRepositoryLists = sync.OnceValue(func() map[string][]string {
+	lists := make(map[string][]string)
+	for _, entry := range strings.Split(os.Getenv("REGISTRY_REPOSITORIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		name, list, ok := strings.Cut(entry, "=")
+		if !ok || list == "" {
+			if entry != "" {
+				log.Printf("⚠️  Ignoring REGISTRY_REPOSITORIES entry %q: want registry=repository+repository", entry)
+			}
+			continue
+		}
+		for _, repository := range strings.Split(list, "+") {
+			lists[name] = append(lists[name], strings.TrimSpace(repository))
+		}
+	}
+	return lists
+})
+
+// configuredRepositories are the repositories REGISTRY_REPOSITORIES names
+// for the registry at host, nil to list them.
+func configuredRepositories(host string) []string {
+	lists := registryRepositoryLists()
+	if len(lists) == 0 {
+		return nil
+	}
+	if repositories, ok := lists[host]; ok {
+		return repositories
+	}
+	for _, endpoint := range configuredRegistries() {
+		if endpoint.host == host {
+			return lists[endpoint.name]
+		}
+	}
+	return nil
+}
+
+// listRepositories are the repositories REGISTRY_REPOSITORIES names for the
+// client's registry, or else those the registry lists.
+func listRepositories(ctx context.Context, client registryClient) ([]string, error) {
+	if repositories := configuredRepositories(client.Host()); repositories != nil {
+		return repositories, nil
+	}
+	repositories, err := client.Repositories(ctx)
+	if errors.Is(err, registry.ErrUnsupported) {
+		return nil, fmt.Errorf("%w; list them in REGISTRY_REPOSITORIES, e.g. %s=web+api", err, client.Host())
+	}
+	return repositories, err
+}
+
+// registryBaseURLs are the base URLs of registries by host, so what View
+// shows of them doesn't build a client each time.
+var registryBaseURLs sync.Map
+
+func registryBaseURL(ctx context.Context, host string) string {
+	if registry := backendsOf(ctx).registry; registry != nil {
+		return registry.BaseURL()
+	}
+	if baseURL, ok := registryBaseURLs.Load(host); ok {
+		return baseURL.(string)
+	}
+	baseURL := newRegistryClient(ctx, host).BaseURL()
+	registryBaseURLs.Store(host, baseURL)
+	return baseURL
+}
+
+// registryBandwidth is the limit REGISTRY_BANDWIDTH_LIMIT puts on the blob
+// transfers of all registry clients together, e.g. those of sync and
+// migrate; nil without one. Pulls and pushes through docker aren't limited.
+var registryBandwidth = sync.OnceValue(func() *registry.BandwidthLimit {
+	limit, err := parseBandwidth(os.Getenv("REGISTRY_BANDWIDTH_LIMIT"))
+	if err != nil {
+		log.Printf("⚠️  Ignoring REGISTRY_BANDWIDTH_LIMIT: %v", err)
+	}
+	return registry.NewBandwidthLimit(limit)
+})
+
+// printBandwidthLimit tells a command's user why its transfers are slow.
+func printBandwidthLimit() {
+	if registryBandwidth() != nil {
+		fmt.Printf("🐢 Transfers limited to %s/s by REGISTRY_BANDWIDTH_LIMIT\n", strings.TrimSuffix(os.Getenv("REGISTRY_BANDWIDTH_LIMIT"), "/s"))
+	}
+}
+
+// parseBandwidth reads a rate like 10MB, 512K/s or 1.5GiB in bytes per
+// second. Units are binary, as docker's are; a bare number is in bytes.
+func parseBandwidth(value string) (int64, error) {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "/s")
+	if value == "" {
+		return 0, nil
+	}
+	number := strings.TrimRightFunc(value, func(r rune) bool { return r < '0' || r > '9' && r != '.' })
+	unit := strings.ToUpper(strings.TrimSpace(value[len(number):]))
+	multiplier := map[string]float64{
+		"": 1, "B": 1,
+		"K": 1 << 10, "KB": 1 << 10, "KIB": 1 << 10,
+		"M": 1 << 20, "MB": 1 << 20, "MIB": 1 << 20,
+		"G": 1 << 30, "GB": 1 << 30, "GIB": 1 << 30,
+	}[unit]
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || multiplier == 0 || n < 0 {
+		return 0, fmt.Errorf("invalid rate %q, expected e.g. 10MB or 512K", value)
+	}
+	return int64(n * multiplier), nil
+}
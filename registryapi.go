@@ -0,0 +1,159 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
+
+// cachedFlavor is the flavor detected earlier, without a request.
+func cachedFlavor(ctx context.Context, host string) string {
+	return registry.CachedFlavor(registryBaseURL(ctx, host))
+}
+
+// flavorSuffix names Harbor and zot after a registry's name.
+func flavorSuffix(flavor string) string {
+	switch flavor {
+	case registry.FlavorHarbor:
+		return " (Harbor)"
+	case registry.FlavorZot:
+		return " (zot)"
+	}
+	return ""
+}
+
+// quirksNote explains the quirks known of a registry, set or detected
+// earlier, without a request; "" for one following the spec.
+func quirksNote(ctx context.Context, host string) string {
+	quirks, ok := registry.CachedQuirks(registryBaseURL(ctx, host))
+	if configured := configuredQuirks(host); configured != nil {
+		quirks, ok = *configured, true
+	}
+	if !ok || len(quirks.Explain()) == 0 {
+		return ""
+	}
+	return " · ⚠️  " + strings.Join(quirks.Explain(), "; ")
+}
+
+// describeRetention summarizes a Harbor retention policy, e.g.
+// "retain latestPushedK 10 of ** · Schedule 0 0 0 * * *".
+func describeRetention(policy registry.HarborRetention) string {
+	var rules []string
+	for _, rule := range policy.Rules {
+		if rule.Disabled {
+			continue
+		}
+		description := rule.Action + " " + rule.Template
+		for _, value := range rule.Params {
+			description += fmt.Sprintf(" %v", value)
+		}
+		var patterns []string
+		for _, selector := range rule.TagSelectors {
+			patterns = append(patterns, selector.Pattern)
+		}
+		if len(patterns) > 0 {
+			description += " of " + strings.Join(patterns, ",")
+		}
+		rules = append(rules, description)
+	}
+	if len(rules) == 0 {
+		return "none"
+	}
+	description := strings.Join(rules, "; ")
+	if policy.Trigger.Kind != "" {
+		description += " · " + strings.TrimSpace(policy.Trigger.Kind+" "+policy.Trigger.Settings.Cron)
+	}
+	return description
+}
+
+func runRegistryInfo(args []string) error {
+	fs := flag.NewFlagSet("registry-info", flag.ContinueOnError)
+	host := fs.String("registry", getRegistryHost(), "registry to describe (host:port or URL)")
+	addRegistryFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	client := newRegistryClient(ctx, *host)
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("registry %s not reachable: %v", *host, err)
+	}
+
+	flavor := client.Flavor(ctx)
+	fmt.Printf("📦 %s: %s\n", *host, flavor)
+	quirks := client.Quirks(ctx)
+	quirks.NoDelete = client.ProbeDelete(ctx, "lcr-probe") != nil
+	source := "detected"
+	if configuredQuirks(*host) != nil {
+		source = "set by REGISTRY_QUIRKS"
+	}
+	fmt.Printf("Conformance: %s (%s)\n", quirks, source)
+	for _, line := range quirks.Explain() {
+		fmt.Printf("  %s\n", line)
+	}
+	switch flavor {
+	case registry.FlavorHarbor:
+		var info struct {
+			HarborVersion string `json:"harbor_version"`
+		}
+		if err := client.GetJSON(ctx, "/api/v2.0/systeminfo", &info); err == nil && info.HarborVersion != "" {
+			fmt.Printf("Version: %s\n", info.HarborVersion)
+		}
+		projects, err := client.HarborProjects(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list projects: %v", err)
+		}
+		sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROJECT\tPUBLIC\tREPOSITORIES\tQUOTA\tRETENTION")
+		for _, project := range projects {
+			quota := "unknown"
+			if summary, err := client.HarborProjectSummary(ctx, project.Name); err == nil {
+				hard := "unlimited"
+				if summary.Quota.Hard.Storage >= 0 {
+					hard = formatBytes(summary.Quota.Hard.Storage)
+				}
+				quota = formatBytes(summary.Quota.Used.Storage) + " / " + hard
+			}
+			retention := "none"
+			if project.Metadata.RetentionID != "" {
+				if policy, err := client.HarborRetention(ctx, project.Metadata.RetentionID); err == nil {
+					retention = describeRetention(policy)
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", project.Name, cmp.Or(project.Metadata.Public, "false"), project.RepoCount, quota, retention)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Println("\nHarbor applies its own retention policies; prune only deletes what the local policy selects.")
+
+	case registry.FlavorZot:
+		var discover struct {
+			Extensions []struct {
+				Name      string   `json:"name"`
+				Endpoints []string `json:"endpoints"`
+			} `json:"extensions"`
+		}
+		if err := client.GetJSON(ctx, "/v2/_zot/ext/discover", &discover); err == nil {
+			for _, extension := range discover.Extensions {
+				fmt.Printf("Extension %s: %s\n", extension.Name, strings.Join(extension.Endpoints, ", "))
+			}
+		}
+
+	default:
+		fmt.Println("Plain distribution registry: no projects, quotas or scan results to show")
+	}
+	return nil
+}
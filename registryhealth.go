@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
+
+// The status bar warns for as long as the registry is unreachable, slow to
+// answer /v2/, failing its own health checks or running out of disk. Every
+// REGISTRY_HEALTH_INTERVAL (default 30s, 0 to never check) the TUI checks:
+//
+//   - that GET /v2/ answers within REGISTRY_SLOW_THRESHOLD (default 2s)
+//   - with REGISTRY_DEBUG_ADDR, that the debug listener's /debug/health
+//     reports no failing checks, like the storage driver's
+//   - with REGISTRY_STORAGE_DIR, the registry's storage volume as mounted
+//     where the TUI runs, that it is less than REGISTRY_DISK_WARN percent
+//     (default 85) full
+
+var healthWarningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5F5F"))
+
+type registryHealth struct {
+	host        string
+	err         error         // /v2/ didn't answer
+	latency     time.Duration // of /v2/
+	failing     []string      // health checks of the debug listener
+	storageDir  string
+	diskUsed    float64 // percent
+	diskErr     error
+	diskChecked bool
+}
+
+type registryHealthMsg struct {
+	health registryHealth
+}
+
+type registryHealthTickMsg struct{}
+
+func registryHealthInterval() time.Duration {
+	if value := os.Getenv("REGISTRY_HEALTH_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err == nil && interval >= 0 {
+			return interval
+		}
+	}
+	return 30 * time.Second
+}
+
+// registrySlowThreshold is the /v2/ latency past which the registry counts
+// as slow.
+func registrySlowThreshold() time.Duration {
+	if threshold, err := time.ParseDuration(os.Getenv("REGISTRY_SLOW_THRESHOLD")); err == nil && threshold > 0 {
+		return threshold
+	}
+	return 2 * time.Second
+}
+
+// registryDiskWarning is how full, in percent, the storage volume may get
+// before the status bar warns.
+func registryDiskWarning() float64 {
+	if value, err := strconv.ParseFloat(os.Getenv("REGISTRY_DISK_WARN"), 64); err == nil && value > 0 {
+		return value
+	}
+	return 85
+}
+
+// checkRegistryHealth runs the checks once. /v2/ is asked without retries:
+// retrying would hide both an outage and the latency.
+func checkRegistryHealth(ctx context.Context) registryHealth {
+	health := registryHealth{host: getRegistryHost()}
+
+	client := registry.NewClient(health.host, registry.Options{HTTPClient: tracedHTTPClient, Timeout: backendTimeout(backendRegistry)})
+	start := time.Now()
+	err := client.Ping(ctx)
+	health.latency = time.Since(start)
+	// Asking for credentials is an answer too
+	if err != nil && !strings.Contains(err.Error(), "401") {
+		health.err = err
+	}
+
+	if addr := envOrDefault("REGISTRY_DEBUG_ADDR", ""); addr != "" && health.err == nil {
+		if failing, err := registryDebugHealth(ctx, addr); err == nil {
+			health.failing = failing
+		}
+	}
+
+	if dir := envOrDefault("REGISTRY_STORAGE_DIR", ""); dir != "" {
+		health.storageDir = dir
+		health.diskUsed, health.diskErr = diskUsage(dir)
+		health.diskChecked = true
+	}
+	return health
+}
+
+// registryDebugHealth lists the health checks distribution's debug listener
+// reports failing, as "name: error". It answers {} when all pass.
+func registryDebugHealth(ctx context.Context, addr string) ([]string, error) {
+	if !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
+		addr = "http://" + addr
+	}
+	ctx, cancel := withBackendTimeout(ctx, backendRegistry)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(addr, "/")+"/debug/health", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var checks map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&checks); err != nil {
+		return nil, fmt.Errorf("GET /debug/health: %s", resp.Status)
+	}
+	var failing []string
+	for name, message := range checks {
+		failing = append(failing, name+": "+message)
+	}
+	sort.Strings(failing)
+	return failing, nil
+}
+
+func (m model) loadRegistryHealth() tea.Cmd {
+	if registryHealthInterval() == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		return registryHealthMsg{health: checkRegistryHealth(m.ctx)}
+	}
+}
+
+func registryHealthTick() tea.Cmd {
+	return tea.Tick(registryHealthInterval(), func(time.Time) tea.Msg { return registryHealthTickMsg{} })
+}
+
+// renderRegistryHealth is the status bar's warning about the registry, ""
+// while it is healthy.
+func (m model) renderRegistryHealth() string {
+	health := m.registryHealth
+	if health == nil {
+		return ""
+	}
+	var warnings []string
+	switch {
+	case health.err != nil:
+		warnings = append(warnings, fmt.Sprintf("🚨 Registry %s unreachable: %s", health.host, truncateString(health.err.Error(), 60)))
+	case health.latency > registrySlowThreshold():
+		warnings = append(warnings, fmt.Sprintf("🐢 Registry %s took %s to answer (over %s)",
+			health.host, health.latency.Round(100*time.Millisecond), registrySlowThreshold()))
+	}
+	for _, check := range health.failing {
+		warnings = append(warnings, "🚨 Registry health check failing: "+truncateString(check, 60))
+	}
+	if health.diskChecked {
+		if health.diskErr != nil {
+			warnings = append(warnings, fmt.Sprintf("💾 Can't check the registry's storage: %v", health.diskErr))
+		} else if warn := registryDiskWarning(); health.diskUsed >= warn {
+			warnings = append(warnings, fmt.Sprintf("💾 Registry storage %s is %.0f%% full (warning at %.0f%%)", health.storageDir, health.diskUsed, warn))
+		}
+	}
+	if len(warnings) == 0 {
+		return ""
+	}
+	return healthWarningStyle.Render(strings.Join(warnings, " · "))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Registry requests share one connection pool whose defaults suit a registry
+// on localhost. One further away, e.g. over a VPN, may need fewer requests at
+// once, longer timeouts or a different keep-alive pool:
+//
+//   - REGISTRY_MAX_REQUESTS caps the requests in flight at once, blob
+//     transfers included (default 0, no cap)
+//   - REGISTRY_TIMEOUT bounds each request but blob transfers (default 10s)
+//   - REGISTRY_KEEPALIVE_CONNS is how many idle connections to each registry
+//     are kept for reuse (default 16, 0 closes each after its request)
+//   - REGISTRY_IDLE_TIMEOUT is how long an idle connection is kept (default
+//     90s)
+//
+// The commands talking to registries take a --registry-* flag for each,
+// which wins over the variable.
+
+type registryPoolOptions struct {
+	maxRequests    int
+	keepAliveConns int
+	idleTimeout    time.Duration
+}
+
+func currentRegistryPoolOptions() registryPoolOptions {
+	options := registryPoolOptions{keepAliveConns: 16, idleTimeout: 90 * time.Second}
+	if value, err := strconv.Atoi(os.Getenv("REGISTRY_MAX_REQUESTS")); err == nil && value >= 0 {
+		options.maxRequests = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("REGISTRY_KEEPALIVE_CONNS")); err == nil && value >= 0 {
+		options.keepAliveConns = value
+	}
+	if value, err := time.ParseDuration(os.Getenv("REGISTRY_IDLE_TIMEOUT")); err == nil && value > 0 {
+		options.idleTimeout = value
+	}
+	return options
+}
+
+// registryTransport is the pool, built on first use so the flags of the
+// command have been parsed by then.
+var registryTransport = sync.OnceValue(func() http.RoundTripper {
+	options := currentRegistryPoolOptions()
+	transport := proxiedTransport(backendRegistry)
+	transport.MaxIdleConnsPerHost = options.keepAliveConns
+	transport.MaxIdleConns = max(transport.MaxIdleConns, options.keepAliveConns)
+	transport.IdleConnTimeout = options.idleTimeout
+	transport.DisableKeepAlives = options.keepAliveConns == 0
+	if options.maxRequests == 0 {
+		return transport
+	}
+	return limitedTransport{base: transport, slots: make(chan struct{}, options.maxRequests)}
+})
+
+// pooledRegistryTransport sends requests through registryTransport.
+type pooledRegistryTransport struct{}
+
+func (pooledRegistryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return registryTransport().RoundTrip(req)
+}
+
+// limitedTransport lets at most cap(slots) requests wait for a response at
+// once. A request gives its slot back when the response headers arrive: a
+// sync streams a blob's body into its upload, which holding the slot for
+// the whole transfer would deadlock with a single one.
+type limitedTransport struct {
+	base  http.RoundTripper
+	slots chan struct{}
+}
+
+func (t limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case t.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	defer func() { <-t.slots }()
+	return t.base.RoundTrip(req)
+}
+
+// addRegistryFlags adds the --registry-* flags to the flags of a command
+// talking to registries. They set the variables they override, which the
+// pool and the clients read when first used.
+func addRegistryFlags(fs *flag.FlagSet) {
+	fs.Func("registry-max-requests", "registry requests in flight at once, 0 for no cap (REGISTRY_MAX_REQUESTS)", setEnvFlag("REGISTRY_MAX_REQUESTS", parseCount))
+	fs.Func("registry-timeout", "timeout of each registry request but blob transfers, e.g. 30s (REGISTRY_TIMEOUT, default 10s)", setEnvFlag("REGISTRY_TIMEOUT", parsePositiveDuration))
+	fs.Func("registry-keepalive-conns", "idle connections kept open to each registry, 0 to close them after each request (REGISTRY_KEEPALIVE_CONNS, default 16)", setEnvFlag("REGISTRY_KEEPALIVE_CONNS", parseCount))
+	fs.Func("registry-idle-timeout", "how long an idle registry connection is kept open (REGISTRY_IDLE_TIMEOUT, default 90s)", setEnvFlag("REGISTRY_IDLE_TIMEOUT", parsePositiveDuration))
+}
+
+func setEnvFlag(name string, validate func(string) error) func(string) error {
+	return func(value string) error {
+		if err := validate(value); err != nil {
+			return err
+		}
+		return os.Setenv(name, value)
+	}
+}
+
+func parseCount(value string) error {
+	if n, err := strconv.Atoi(value); err != nil || n < 0 {
+		return fmt.Errorf("expected a number of at least 0")
+	}
+	return nil
+}
+
+func parsePositiveDuration(value string) error {
+	if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+		return fmt.Errorf("expected a positive duration like 30s")
+	}
+	return nil
+}
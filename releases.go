@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/gitprovider"
+)
+
+// The Git tab's releases view lists the releases and tags of GITHUB_REPO
+// instead of its commits. A release opens with its notes and assets, and
+// with the registry images tagged with its version, any of which goes
+// straight to the deploy dialog.
+
+// gitReleasesLimit is how many releases, and how many bare tags, the
+// releases view lists.
+const gitReleasesLimit = 50
+
+// releaseNotesLines is how much of a release's notes its details show.
+const releaseNotesLines = 15
+
+type gitReleasesMsg struct {
+	releases []gitprovider.Release
+	err      error
+}
+
+// getGitReleases fetches the latest releases and tags of GITHUB_REPO.
+func getGitReleases(ctx context.Context) (releases []gitprovider.Release, err error) {
+	ctx, span := startSpan(ctx, "load releases", attribute.Int("lcr.limit", gitReleasesLimit))
+	defer func() { endSpan(span, err) }()
+	githubCtx, cancel := withBackendTimeout(ctx, backendGitHub)
+	defer cancel()
+	releases, err = newGitProvider(ctx).ListReleases(githubCtx, gitReleasesLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list releases of %s/%s: %v", os.Getenv("GITHUB_OWNER"), os.Getenv("GITHUB_REPO"), err)
+	}
+	return releases, nil
+}
+
+func (m model) loadReleases() tea.Cmd {
+	ctx := m.ctx
+	return func() tea.Msg {
+		releases, err := getGitReleases(ctx)
+		return gitReleasesMsg{releases: releases, err: err}
+	}
+}
+
+// updateReleases takes in the releases; a failed reload keeps those shown.
+func (m *model) updateReleases(msg gitReleasesMsg) {
+	m.releasesErr = msg.err
+	if msg.err == nil {
+		m.releases = msg.releases
+		if m.releases == nil {
+			m.releases = []gitprovider.Release{}
+		}
+	}
+	if m.activeTab == 0 && m.showReleases {
+		m.updateTableForTab()
+	}
+}
+
+// toggleReleases switches the Git tab between commits and releases,
+// reloading the releases each time they're shown.
+func (m *model) toggleReleases() tea.Cmd {
+	m.showReleases = !m.showReleases
+	m.table.SetCursor(0)
+	m.updateTableForTab()
+	if m.showReleases {
+		return m.loadReleases()
+	}
+	return nil
+}
+
+// releaseImages are the registry images tagged with the release's version,
+// its tag with or without a leading v. Those of the repository named like
+// GITHUB_REPO come first.
+func releaseImages(release gitprovider.Release, images []TableData) []string {
+	version := strings.TrimPrefix(release.Tag, "v")
+	var matches []string
+	for _, image := range images {
+		if image.ArtifactType != "" || image.ImageTag == "" || image.ImageTag == "N/A" {
+			continue
+		}
+		_, _, tag := splitRegistryReference(image.ImageTag)
+		if tag == release.Tag || tag == version || tag == "v"+version {
+			matches = append(matches, image.ImageTag)
+		}
+	}
+	repo := os.Getenv("GITHUB_REPO")
+	slices.SortStableFunc(matches, func(a, b string) int {
+		_, repositoryA, _ := splitRegistryReference(a)
+		_, repositoryB, _ := splitRegistryReference(b)
+		switch matchA, matchB := path.Base(repositoryA) == repo, path.Base(repositoryB) == repo; {
+		case matchA && !matchB:
+			return -1
+		case matchB && !matchA:
+			return 1
+		}
+		return 0
+	})
+	return matches
+}
+
+// releaseRows are the releases view's rows.
+func (m model) releaseRows() []table.Row {
+	var rows []table.Row
+	for _, release := range m.releases {
+		name, published, assets := release.Name, "tag only", ""
+		if release.Prerelease {
+			name += " (pre-release)"
+		}
+		if !release.Published.IsZero() {
+			published = release.Published.Local().Format("2006-01-02 15:04")
+			assets = fmt.Sprint(len(release.Assets))
+		}
+		image := "—"
+		if images := releaseImages(release, m.dockerData); len(images) > 0 {
+			image = images[0]
+			if len(images) > 1 {
+				image += fmt.Sprintf(" +%d", len(images)-1)
+			}
+		}
+		rows = append(rows, table.Row{release.Tag, strings.TrimSpace(name), published, assets, image})
+	}
+	return rows
+}
+
+// openRelease shows the details of the highlighted release.
+func (m *model) openRelease() {
+	if selectedRow := m.table.Cursor(); selectedRow < len(m.releases) {
+		m.selectedRelease = m.releases[selectedRow]
+		m.releaseCursor = 0
+		m.showRelease = true
+	}
+}
+
+func (m model) updateRelease(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	images := releaseImages(m.selectedRelease, m.dockerData)
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "q":
+		m.showRelease = false
+	case "up", "k":
+		if m.releaseCursor > 0 {
+			m.releaseCursor--
+		}
+	case "down", "j":
+		if m.releaseCursor < len(images)-1 {
+			m.releaseCursor++
+		}
+	case "enter", "1":
+		if m.releaseCursor >= len(images) {
+			return m, nil
+		}
+		if !kubernetesEnabled() {
+			m.statusMessage = fmt.Sprintf("⚠️  Can't deploy: %v", errKubernetesDisabled)
+			return m, nil
+		}
+		m.showRelease = false
+		cmd := m.openDeployDialog(images[m.releaseCursor])
+		return m, cmd
+	}
+	return m, nil
+}
+
+func (m model) renderRelease() string {
+	release := m.selectedRelease
+	var b strings.Builder
+	title := release.Tag
+	if release.Name != "" && release.Name != release.Tag {
+		title += " · " + release.Name
+	}
+	b.WriteString(title + "\n")
+	if release.Published.IsZero() {
+		b.WriteString("A tag without a release\n")
+	} else {
+		fmt.Fprintf(&b, "Published %s", release.Published.Local().Format("2006-01-02 15:04"))
+		if release.Prerelease {
+			b.WriteString(" as a pre-release")
+		}
+		b.WriteString("\n")
+	}
+
+	if notes := strings.TrimSpace(release.Notes); notes != "" {
+		b.WriteString("\n")
+		lines := strings.Split(strings.ReplaceAll(notes, "\r\n", "\n"), "\n")
+		for _, line := range lines[:min(len(lines), releaseNotesLines)] {
+			b.WriteString(line + "\n")
+		}
+		if len(lines) > releaseNotesLines {
+			fmt.Fprintf(&b, "… %d more lines at %s\n", len(lines)-releaseNotesLines, release.URL)
+		}
+	}
+
+	if len(release.Assets) > 0 {
+		b.WriteString("\nAssets:\n")
+		for _, asset := range release.Assets {
+			fmt.Fprintf(&b, "  %-40s %10s  %d downloads\n", truncateString(asset.Name, 40), formatBytes(asset.Size), asset.Downloads)
+		}
+	}
+
+	b.WriteString("\nImages tagged with this version:\n")
+	images := releaseImages(release, m.dockerData)
+	switch {
+	case m.dockerData == nil:
+		b.WriteString("  Loading the registry's images...\n")
+	case len(images) == 0:
+		fmt.Fprintf(&b, "  None in %s; push one tagged %s to deploy this release\n", registryName(), release.Tag)
+	}
+	for i, image := range images {
+		cursor := "  "
+		if i == m.releaseCursor {
+			cursor = "▶ "
+		}
+		b.WriteString(cursor + image + "\n")
+	}
+
+	hint := "\nESC to close"
+	if len(images) > 0 {
+		hint = "\n↑/↓ to pick an image, Enter to deploy it, ESC to close"
+	}
+	b.WriteString(hint)
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, modalStyle.Width(110).Height(0).Render(b.String()), lipgloss.WithWhitespaceChars("░"))
+}
+
+// renderReleasesStatus is the releases view's line about what is loaded.
+func (m model) renderReleasesStatus() string {
+	switch {
+	case m.releases == nil && m.releasesErr == nil:
+		return fmt.Sprintf("Loading releases of %s/%s...", os.Getenv("GITHUB_OWNER"), os.Getenv("GITHUB_REPO"))
+	case m.releasesErr != nil:
+		return fmt.Sprintf("⚠️  %v", m.releasesErr)
+	}
+	return fmt.Sprintf("%d releases and tags · Enter for notes, assets and deploy · r for commits", len(m.releases))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Created deployments get CPU and memory requests and limits from a preset,
+// so they aren't the first pods evicted when a node runs short. The create
+// dialog picks the preset and can override either resource of it.
+
+// resourcePreset is a request/limit pair for CPU and for memory, each
+// "request/limit", "request" alone or empty.
+type resourcePreset struct {
+	name   string
+	cpu    string
+	memory string
+}
+
+// builtinResourcePresets suit a small service, a typical one and a heavy one.
+var builtinResourcePresets = []resourcePreset{
+	{name: "small", cpu: "100m/250m", memory: "128Mi/256Mi"},
+	{name: "medium", cpu: "250m/500m", memory: "256Mi/512Mi"},
+	{name: "large", cpu: "500m/1", memory: "512Mi/1Gi"},
+}
+
+// resourcePresetNone leaves a deployment without requests or limits.
+const resourcePresetNone = "none"
+
+// resourcePresets are the built-in presets with RESOURCE_PRESETS applied: a
+// semicolon-separated list of name=cpu,memory entries, e.g.
+// "small=50m/100m,64Mi/128Mi;gpu-box=2/4,4Gi/8Gi", replacing the preset of
+// the same name or adding one. They are read once, warning once about bad
+// entries.
+var resourcePresets = sync.OnceValue(func() []resourcePreset {
+	presets := slices.Clone(builtinResourcePresets)
+	for _, entry := range strings.Split(os.Getenv("RESOURCE_PRESETS"), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, spec, _ := strings.Cut(entry, "=")
+		cpu, memory, ok := strings.Cut(spec, ",")
+		preset := resourcePreset{name: strings.TrimSpace(name), cpu: strings.TrimSpace(cpu), memory: strings.TrimSpace(memory)}
+		if _, err := preset.requirements("", ""); !ok || preset.name == "" || preset.name == resourcePresetNone || err != nil {
+			log.Printf("⚠️  Ignoring RESOURCE_PRESETS entry %q: not name=cpu,memory, e.g. small=100m/250m,128Mi/256Mi", entry)
+			continue
+		}
+		if i := slices.IndexFunc(presets, func(p resourcePreset) bool { return p.name == preset.name }); i >= 0 {
+			presets[i] = preset
+		} else {
+			presets = append(presets, preset)
+		}
+	}
+	return presets
+})
+
+// defaultResourcePreset is RESOURCE_PRESET, small when unset.
+func defaultResourcePreset() string {
+	return envOrDefault("RESOURCE_PRESET", "small")
+}
+
+// findResourcePreset looks a preset up by name, case-insensitively; none is
+// a preset without requests or limits.
+func findResourcePreset(name string) (resourcePreset, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.EqualFold(name, resourcePresetNone) {
+		return resourcePreset{name: resourcePresetNone}, nil
+	}
+	presets := resourcePresets()
+	for _, preset := range presets {
+		if strings.EqualFold(preset.name, name) {
+			return preset, nil
+		}
+	}
+	names := []string{resourcePresetNone}
+	for _, preset := range presets {
+		names = append(names, preset.name)
+	}
+	return resourcePreset{}, fmt.Errorf("resources %q must be one of %s", name, strings.Join(names, ", "))
+}
+
+// requirements are the preset's requests and limits, with cpu and memory,
+// when not empty, overriding its own.
+func (p resourcePreset) requirements(cpu, memory string) (corev1.ResourceRequirements, error) {
+	var requirements corev1.ResourceRequirements
+	for _, r := range []struct {
+		name     corev1.ResourceName
+		value    string
+		override string
+	}{
+		{corev1.ResourceCPU, p.cpu, cpu},
+		{corev1.ResourceMemory, p.memory, memory},
+	} {
+		value := strings.TrimSpace(r.override)
+		if value == "" {
+			value = r.value
+		}
+		if value == "" {
+			continue
+		}
+		request, limit, _ := strings.Cut(value, "/")
+		if err := addQuantity(&requirements.Requests, r.name, request); err != nil {
+			return requirements, err
+		}
+		if err := addQuantity(&requirements.Limits, r.name, limit); err != nil {
+			return requirements, err
+		}
+		if request, limit := requirements.Requests[r.name], requirements.Limits[r.name]; !request.IsZero() && !limit.IsZero() && request.Cmp(limit) > 0 {
+			return requirements, fmt.Errorf("%s request %s is above its limit %s", r.name, request.String(), limit.String())
+		}
+	}
+	return requirements, nil
+}
+
+// addQuantity parses value, e.g. 250m or 512Mi, into list; empty adds nothing.
+func addQuantity(list *corev1.ResourceList, name corev1.ResourceName, value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return fmt.Errorf("%s %q is not a quantity like 250m or 512Mi", name, value)
+	}
+	if *list == nil {
+		*list = corev1.ResourceList{}
+	}
+	(*list)[name] = quantity
+	return nil
+}
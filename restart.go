@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/backend"
+)
+
+type restartMsg struct {
+	deployment string
+	err        error
+}
+
+// restartDeployment is the equivalent of `kubectl rollout restart`. Pods are
+// replaced without changing the image, which picks up an image pushed again
+// under the same tag when the pull policy is Always.
+func restartDeployment(ctx context.Context, cluster backend.ClusterAPI, name, namespace string) (err error) {
+	ctx, span := startSpan(ctx, "k8s rollout restart", attribute.String("k8s.deployment.name", name), attribute.String("k8s.namespace.name", namespace))
+	defer func() { endSpan(span, err) }()
+
+	return cluster.Restart(ctx, namespace, name)
+}
+
+func (m *model) restartDeployment(name, namespace string) tea.Cmd {
+	recordSessionStep(sessionStep{Action: sessionRestart, Deployment: name, Namespace: namespace})
+	historyCtx := m.ctx
+	return m.startOperation("restart", name, func(ctx context.Context) tea.Msg {
+		kubeCtx, cancel := withBackendTimeout(ctx, backendKubernetes)
+		defer cancel()
+		err := restartDeployment(kubeCtx, newCluster(ctx), name, namespace)
+		recordActionResult(withSpanOf(historyCtx, ctx), "restart", namespace+"/"+name, err, "")
+		return restartMsg{deployment: name, err: err}
+	})
+}
+
+func runRestart(args []string) error {
+	fs := flag.NewFlagSet("restart", flag.ContinueOnError)
+	namespace := fs.String("namespace", envOrDefault("KUBERNETES_NAMESPACE", "default"), "namespace of the deployments")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("restart: name at least one deployment")
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	cluster := newCluster(ctx)
+	for _, name := range fs.Args() {
+		err := restartDeployment(ctx, cluster, name, *namespace)
+		recordActionResult(ctx, "restart", *namespace+"/"+name, err, "")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🔄 Restarted deployment %s/%s\n", *namespace, name)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"regexp"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// validTag is the distribution spec's tag grammar.
+var validTag = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// retagImage puts the manifest tag points at under newTag as well, on the
+// registry itself: no layers are pulled or pushed. When newTag already
+// pointed elsewhere the old manifest is journaled so undo can move it back.
+func retagImage(ctx context.Context, client registryClient, repository, tag, newTag string) error {
+	if !validTag.MatchString(newTag) {
+		return fmt.Errorf("invalid tag %q: use letters, digits, _, . and -, at most 128 characters", newTag)
+	}
+	body, mediaType, err := client.Manifest(ctx, repository, tag)
+	if err != nil {
+		return fmt.Errorf("failed to fetch manifest of %s:%s: %v", repository, tag, err)
+	}
+
+	var undo *journalEntry
+	if previous, previousType, err := client.Manifest(ctx, repository, newTag); err == nil {
+		if bytes.Equal(previous, body) {
+			return nil
+		}
+		undo = &journalEntry{Kind: journalRetag, Name: repository + ":" + newTag, Registry: client.BaseURL(),
+			Repository: repository, Tag: newTag, MediaType: previousType, Manifest: previous}
+	}
+
+	if err := client.PutManifest(ctx, repository, newTag, mediaType, body); err != nil {
+		return fmt.Errorf("failed to tag %s:%s as %s: %v", repository, tag, newTag, err)
+	}
+	if undo != nil {
+		journal(*undo)
+	}
+	return nil
+}
+
+func runRetag(args []string) error {
+	fs := flag.NewFlagSet("retag", flag.ContinueOnError)
+	addRegistryFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: local-container-registry retag <image> <new-tag>")
+		fmt.Fprintln(fs.Output(), "\nImages without a registry host are in REGISTRY_HOST, e.g. web:sha-abc123 stable")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("retag needs an image and a new tag")
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	connectDatabase(ctx)
+
+	host, repository, tag := splitRegistryReference(fs.Arg(0))
+	newTag := fs.Arg(1)
+	err := retagImage(ctx, newRegistryClient(ctx, host), repository, tag, newTag)
+	recordActionResult(ctx, "retag", fmt.Sprintf("%s:%s", repository, newTag), err, "from "+tag)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🏷️  Tagged %s:%s as %s\n", repository, tag, newTag)
+	return nil
+}
+
+type retagMsg struct {
+	image  string
+	newTag string
+	err    error
+}
+
+// startRetag asks for the tag to give the highlighted registry image.
+func (m *model) startRetag(item TableData) tea.Cmd {
+	if imageRegistryHost(item.ImageTag) == "" {
+		m.statusMessage = "Only registry images can be retagged"
+		return nil
+	}
+	m.retagSource = item.ImageTag
+	m.retagInput = textinput.New()
+	m.retagInput.Prompt = ""
+	m.retagInput.Placeholder = "e.g. stable"
+	m.retagInput.CharLimit = 128
+	m.retagging = true
+	return m.retagInput.Focus()
+}
+
+func (m model) updateRetag(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc":
+		m.retagging = false
+		return m, nil
+	case "enter":
+		m.retagging = false
+		if newTag := m.retagInput.Value(); newTag != "" {
+			cmd := m.retagImage(m.retagSource, newTag)
+			return m, cmd
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.retagInput, cmd = m.retagInput.Update(msg)
+	return m, cmd
+}
+
+func (m *model) retagImage(image, newTag string) tea.Cmd {
+	recordSessionStep(sessionStep{Action: sessionRetag, Image: image, Tag: newTag})
+	historyCtx := m.ctx
+	return m.startOperation("retag", image, func(ctx context.Context) tea.Msg {
+		host, repository, tag := splitRegistryReference(image)
+		err := retagImage(ctx, newRegistryClient(ctx, host), repository, tag, newTag)
+		recordActionResult(withSpanOf(historyCtx, ctx), "retag", fmt.Sprintf("%s:%s", repository, newTag), err, "from "+tag)
+		return retagMsg{image: image, newTag: newTag, err: err}
+	})
+}
+
+func (m model) renderRetagPrompt() string {
+	return fmt.Sprintf("🏷️  Tag %s as %s  (Enter to tag, ESC to cancel)", m.retagSource, m.retagInput.View())
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
+
+// retentionPolicy decides which tags survive a prune. A tag is kept when any
+// rule retains it: it matches the protect pattern, it is among the newest
+// keepLast tags of its repository, or it is younger than maxAge. A zero
+// keepLast or maxAge disables that rule. With untagged set, manifests no tag
+// refers to any more are deleted as well.
+type retentionPolicy struct {
+	keepLast int
+	maxAge   time.Duration
+	protect  *regexp.Regexp
+	untagged bool
+}
+
+func (p retentionPolicy) prunesTags() bool {
+	return p.keepLast > 0 || p.maxAge > 0
+}
+
+type registryTag struct {
+	repository string
+	tag        string
+	digest     string
+	created    time.Time // zero when the config blob has no creation time
+}
+
+type pruneDecision struct {
+	registryTag
+	keep   bool
+	reason string
+}
+
+func loadRetentionPolicy() (retentionPolicy, error) {
+	var policy retentionPolicy
+	if value := os.Getenv("RETENTION_KEEP_LAST"); value != "" {
+		keepLast, err := strconv.Atoi(value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid RETENTION_KEEP_LAST %q: %v", value, err)
+		}
+		policy.keepLast = keepLast
+	}
+	if value := os.Getenv("RETENTION_MAX_AGE"); value != "" {
+		maxAge, err := parseRetentionAge(value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid RETENTION_MAX_AGE %q: %v", value, err)
+		}
+		policy.maxAge = maxAge
+	}
+	if value := os.Getenv("RETENTION_PROTECT"); value != "" {
+		protect, err := regexp.Compile(value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid RETENTION_PROTECT %q: %v", value, err)
+		}
+		policy.protect = protect
+	}
+	policy.untagged = os.Getenv("RETENTION_UNTAGGED") == "true"
+	return policy, nil
+}
+
+// parseRetentionAge accepts Go durations plus a day suffix ("30d"), since
+// retention windows are usually expressed in days.
+func parseRetentionAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+func runPrune(args []string) error {
+	policy, err := loadRetentionPolicy()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+	keepLast := fs.Int("keep-last", policy.keepLast, "keep the newest N tags per repository (0 disables)")
+	olderThan := fs.String("older-than", "", "delete tags older than this age, e.g. 30d or 72h")
+	protect := fs.String("protect", "", "regular expression of tags that are never deleted")
+	repos := fs.String("repos", "", "comma-separated repositories to prune (default: all)")
+	dryRun := fs.Bool("dry-run", false, "show what would be deleted without deleting")
+	every := fs.Duration("every", 0, "keep running and prune on this interval, e.g. 24h")
+	untagged := fs.Bool("untagged", policy.untagged, "also delete manifests no tag refers to any more")
+	addRegistryFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	policy.keepLast = *keepLast
+	policy.untagged = *untagged
+	if *olderThan != "" {
+		if policy.maxAge, err = parseRetentionAge(*olderThan); err != nil {
+			return fmt.Errorf("invalid --older-than %q: %v", *olderThan, err)
+		}
+	}
+	if *protect != "" {
+		if policy.protect, err = regexp.Compile(*protect); err != nil {
+			return fmt.Errorf("invalid --protect %q: %v", *protect, err)
+		}
+	}
+	if !policy.prunesTags() && !policy.untagged {
+		return fmt.Errorf("prune: set --keep-last, --older-than and/or --untagged (or RETENTION_KEEP_LAST/RETENTION_MAX_AGE/RETENTION_UNTAGGED)")
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	connectDatabase(ctx)
+
+	var repositories []string
+	if *repos != "" {
+		repositories = strings.Split(*repos, ",")
+	}
+	if newRegistryClient(ctx, getRegistryHost()).Flavor(ctx) == registry.FlavorHarbor {
+		fmt.Println("ℹ️  Harbor also applies its own per-project retention policies; see registry-info")
+	}
+
+	for {
+		if err := pruneRegistry(ctx, newRegistryClient(ctx, getRegistryHost()), policy, repositories, *dryRun); err != nil {
+			if *every == 0 {
+				return err
+			}
+			fmt.Printf("❌ %v\n", err)
+		}
+		if *every == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*every):
+		}
+	}
+}
+
+// pruneRegistry applies the policy to the given repositories (all of them
+// when empty) and records every deletion in the history table.
+func pruneRegistry(ctx context.Context, client registryClient, policy retentionPolicy, repositories []string, dryRun bool) error {
+	if len(repositories) == 0 {
+		var err error
+		repositories, err = listRepositories(ctx, client)
+		if err != nil {
+			return fmt.Errorf("failed to list repositories on %s: %w", client.Host(), err)
+		}
+	}
+	if !dryRun && len(repositories) > 0 {
+		if err := client.ProbeDelete(ctx, strings.TrimSpace(repositories[0])); err != nil {
+			return fmt.Errorf("can't prune: %w", err)
+		}
+	}
+
+	deleted, failed, kept, untagged := 0, 0, 0, 0
+	var reclaimed int64
+	for _, repo := range repositories {
+		repo = strings.TrimSpace(repo)
+		if policy.prunesTags() {
+			d, f, k, r := pruneTags(ctx, client, policy, repo, dryRun)
+			deleted, failed, kept, reclaimed = deleted+d, failed+f, kept+k, reclaimed+r
+		}
+		if policy.untagged {
+			d, f, r := pruneUntagged(ctx, client, repo, dryRun)
+			untagged, failed, reclaimed = untagged+d, failed+f, reclaimed+r
+		}
+	}
+
+	result := pruneResult{deleted: deleted, untagged: untagged, kept: kept, failed: failed, reclaimed: reclaimed}
+	if dryRun {
+		fmt.Printf("Dry run: would have %s\n", result)
+		return nil
+	}
+	fmt.Printf("Prune finished: %s\n", result)
+	recordHistoryResult(ctx, "prune", client.Host(), "completed", result.String(), result)
+	return nil
+}
+
+// pruneResult is what a prune of the registry did.
+type pruneResult struct {
+	deleted, untagged, kept, failed int
+	reclaimed                       int64 // bytes of the deleted manifests no kept one shares
+}
+
+func (r pruneResult) String() string {
+	summary := fmt.Sprintf("deleted %d tags and %d untagged manifests, kept %d, %d failures", r.deleted, r.untagged, r.kept, r.failed)
+	if r.reclaimed > 0 {
+		summary += fmt.Sprintf(", up to %s reclaimable after registry garbage-collect", formatBytes(r.reclaimed))
+	}
+	return summary
+}
+
+// pruneTags applies the tag rules of the policy to one repository and
+// returns the deleted, failed and kept counts and the bytes freed.
+func pruneTags(ctx context.Context, client registryClient, policy retentionPolicy, repo string, dryRun bool) (deleted, failed, kept int, reclaimed int64) {
+	tags, err := listRegistryTags(ctx, client, repo)
+	if err != nil {
+		fmt.Printf("❌ %s: %v\n", repo, err)
+		return 0, 1, 0, 0
+	}
+
+	// Deleting a manifest removes it for every tag, so each digest is deleted
+	// once; the manifest is kept for the journal so every tag can be restored
+	deletedDigests := make(map[string]journalEntry)
+	for _, decision := range planPrune(tags, policy, time.Now()) {
+		if decision.keep {
+			kept++
+			continue
+		}
+		name := fmt.Sprintf("%s:%s", decision.repository, decision.tag)
+		if dryRun {
+			fmt.Printf("🗑️  %s would be deleted (%s)\n", name, decision.reason)
+			deleted++
+			continue
+		}
+		undo := journalEntry{Kind: journalDeleteTag, Name: name, Registry: client.BaseURL(), Repository: decision.repository, Tag: decision.tag}
+		if manifest, ok := deletedDigests[decision.digest]; ok {
+			if manifest.Manifest != nil {
+				undo.MediaType, undo.Manifest = manifest.MediaType, manifest.Manifest
+				journal(undo)
+			}
+			deleted++
+			continue
+		}
+		undo.Manifest, undo.MediaType, _ = client.Manifest(ctx, decision.repository, decision.digest)
+		size := manifestSize(ctx, client, decision.repository, decision.digest)
+		if err := client.DeleteManifest(ctx, decision.repository, decision.digest); err != nil {
+			fmt.Printf("❌ %s: %v\n", name, err)
+			recordHistory(ctx, "prune", name, "failed", err.Error())
+			failed++
+			continue
+		}
+		deletedDigests[decision.digest] = undo
+		if undo.Manifest != nil {
+			journal(undo)
+		}
+		reclaimed += size
+		fmt.Printf("🗑️  Deleted %s (%s)\n", name, decision.reason)
+		recordHistory(ctx, "prune", name, "deleted", fmt.Sprintf("%s; digest %s", decision.reason, decision.digest))
+		deleted++
+	}
+	return deleted, failed, kept, reclaimed
+}
+
+// pruneUntagged deletes the untagged manifests of one repository and returns
+// the deleted and failed counts and the bytes freed.
+func pruneUntagged(ctx context.Context, client registryClient, repo string, dryRun bool) (deleted, failed int, reclaimed int64) {
+	manifests, err := findUntaggedManifests(ctx, client, repo)
+	if err != nil {
+		fmt.Printf("❌ %s: %v\n", repo, err)
+		return 0, 1, 0
+	}
+
+	for _, manifest := range manifests {
+		name := fmt.Sprintf("%s@%s", manifest.repository, manifest.digest)
+		size := formatBytes(manifest.reclaimable)
+		if dryRun {
+			fmt.Printf("🗑️  %s would be deleted (untagged, %s reclaimable)\n", name, size)
+			deleted++
+			reclaimed += manifest.reclaimable
+			continue
+		}
+		if err := client.DeleteManifest(ctx, manifest.repository, manifest.digest); err != nil {
+			fmt.Printf("❌ %s: %v\n", name, err)
+			recordHistory(ctx, "prune", name, "failed", err.Error())
+			failed++
+			continue
+		}
+		fmt.Printf("🗑️  Deleted %s (untagged, %s reclaimable)\n", name, size)
+		recordHistory(ctx, "prune", name, "deleted", fmt.Sprintf("untagged manifest; %s reclaimable", size))
+		deleted++
+		reclaimed += manifest.reclaimable
+	}
+	return deleted, failed, reclaimed
+}
+
+func listRegistryTags(ctx context.Context, client registryClient, repository string) ([]registryTag, error) {
+	tags, err := client.Tags(ctx, repository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %v", err)
+	}
+
+	var result []registryTag
+	for _, tag := range tags {
+		body, _, err := client.Manifest(ctx, repository, tag)
+		if err != nil {
+			log.Printf("failed to get manifest for %s:%s: %v", repository, tag, err)
+			continue
+		}
+		result = append(result, registryTag{
+			repository: repository,
+			tag:        tag,
+			digest:     registry.ComputeDigest(body),
+			created:    manifestCreated(ctx, client, repository, body),
+		})
+	}
+	return result, nil
+}
+
+func manifestCreated(ctx context.Context, client registryClient, repository string, body []byte) time.Time {
+	var manifest registryManifest
+	if err := json.Unmarshal(body, &manifest); err != nil || manifest.Config.Digest == "" {
+		return time.Time{}
+	}
+	content, err := client.Blob(ctx, repository, manifest.Config.Digest)
+	if err != nil {
+		return time.Time{}
+	}
+	var config ImageConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return time.Time{}
+	}
+	created, _ := time.Parse(time.RFC3339, config.Created)
+	return created
+}
+
+func manifestSize(ctx context.Context, client registryClient, repository, digest string) int64 {
+	body, _, err := client.Manifest(ctx, repository, digest)
+	if err != nil {
+		return 0
+	}
+	size, _ := parseManifestSize(ctx, client, repository, body)
+	return size
+}
+
+// planPrune decides per tag of one repository. Tags without a known creation
+// time are always kept, and a digest still referenced by a kept tag is never
+// deleted since that would remove the kept tag too.
+func planPrune(tags []registryTag, policy retentionPolicy, now time.Time) []pruneDecision {
+	sorted := append([]registryTag(nil), tags...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].created.After(sorted[j].created)
+	})
+
+	decisions := make([]pruneDecision, len(sorted))
+	keptDigests := make(map[string]string)
+	for i, tag := range sorted {
+		decision := pruneDecision{registryTag: tag, keep: true}
+		age := now.Sub(tag.created)
+		switch {
+		case policy.protect != nil && policy.protect.MatchString(tag.tag):
+			decision.reason = "protected"
+		case tag.created.IsZero():
+			decision.reason = "unknown creation time"
+		case policy.keepLast > 0 && i < policy.keepLast:
+			decision.reason = fmt.Sprintf("newest %d", policy.keepLast)
+		case policy.maxAge > 0 && age < policy.maxAge:
+			decision.reason = fmt.Sprintf("younger than %s", policy.maxAge)
+		default:
+			decision.keep = false
+			if policy.maxAge > 0 {
+				decision.reason = fmt.Sprintf("%d days old", int(age.Hours()/24))
+			} else {
+				decision.reason = fmt.Sprintf("not among newest %d", policy.keepLast)
+			}
+		}
+		if decision.keep {
+			keptDigests[tag.digest] = tag.tag
+		}
+		decisions[i] = decision
+	}
+
+	for i := range decisions {
+		if keptTag, shared := keptDigests[decisions[i].digest]; shared && !decisions[i].keep {
+			decisions[i].keep = true
+			decisions[i].reason = fmt.Sprintf("same image as kept tag %s", keptTag)
+		}
+	}
+	return decisions
+}
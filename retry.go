@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Calls failing transiently, e.g. against a registry container that is
+// still starting, are retried with exponential backoff: RETRY_ATTEMPTS
+// attempts in all (default 4), waiting RETRY_BACKOFF (default 500ms)
+// doubled after every attempt up to 10s, with jitter so clients started
+// together don't retry in lockstep.
+const maxRetryBackoff = 10 * time.Second
+
+type retryPolicy struct {
+	attempts int
+	backoff  time.Duration
+}
+
+func currentRetryPolicy() retryPolicy {
+	policy := retryPolicy{attempts: 4, backoff: 500 * time.Millisecond}
+	if value, err := strconv.Atoi(os.Getenv("RETRY_ATTEMPTS")); err == nil && value > 0 {
+		policy.attempts = value
+	}
+	if value, err := time.ParseDuration(os.Getenv("RETRY_BACKOFF")); err == nil && value > 0 {
+		policy.backoff = value
+	}
+	return policy
+}
+
+// wait is the pause after the given failed attempt: the backoff doubled
+// per attempt, capped, and jittered to between half and all of it.
+func (p retryPolicy) wait(attempt int) time.Duration {
+	wait := p.backoff << (attempt - 1)
+	if wait > maxRetryBackoff || wait <= 0 {
+		wait = maxRetryBackoff
+	}
+	return wait/2 + rand.N(wait/2+1)
+}
+
+type noRetryKey struct{}
+
+// withoutRetries makes calls under ctx fail on the first error, for doctor,
+// which is there to report what's wrong right now.
+func withoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+// retryStatusError is a response worth retrying: rate limited, or a
+// gateway in front of a backend that isn't up yet.
+type retryStatusError struct {
+	status     string
+	retryAfter time.Duration // from the Retry-After header, if any
+}
+
+func (e *retryStatusError) Error() string {
+	return e.status
+}
+
+// isTransient reports whether err may go away by trying again.
+func isTransient(err error) bool {
+	var statusErr *retryStatusError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.As(err, &statusErr):
+		return true
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	}
+	// docker and helm only report errors as text
+	message := err.Error()
+	return strings.Contains(message, "connection refused") || strings.Contains(message, "actively refused") ||
+		strings.Contains(message, "connection reset")
+}
+
+// retrying is the backoff in progress for each call, shown in the status bar.
+var retrying struct {
+	sync.Mutex
+	calls map[int]retryState
+	next  int
+}
+
+type retryState struct {
+	backend  string
+	attempt  int // the attempt waited for
+	attempts int
+	err      error
+	until    time.Time
+}
+
+func trackRetry(id int, state *retryState) {
+	retrying.Lock()
+	defer retrying.Unlock()
+	if state == nil {
+		delete(retrying.calls, id)
+		return
+	}
+	if retrying.calls == nil {
+		retrying.calls = map[int]retryState{}
+	}
+	retrying.calls[id] = *state
+}
+
+// withRetry runs call until it succeeds, fails with an error that isn't
+// transient or runs out of attempts, backing off in between. backend names
+// the failing service in logs and the status bar.
+func withRetry(ctx context.Context, backend string, call func() error) error {
+	policy := currentRetryPolicy()
+	if ctx.Value(noRetryKey{}) != nil {
+		policy.attempts = 1
+	}
+	retrying.Lock()
+	retrying.next++
+	id := retrying.next
+	retrying.Unlock()
+	defer trackRetry(id, nil)
+
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= policy.attempts || !isTransient(err) {
+			return err
+		}
+		wait := policy.wait(attempt)
+		var statusErr *retryStatusError
+		if errors.As(err, &statusErr) && statusErr.retryAfter > wait {
+			wait = min(statusErr.retryAfter, maxRetryBackoff)
+		}
+		trackRetry(id, &retryState{backend: backend, attempt: attempt + 1, attempts: policy.attempts, err: err, until: time.Now().Add(wait)})
+		log.Printf("%s: %v; attempt %d of %d in %s", strings.ToLower(backend), err, attempt+1, policy.attempts, wait.Round(time.Millisecond))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// retryTransport retries HTTP requests that are safe to send again: those
+// with idempotent methods whose body, if any, can be replayed.
+type retryTransport struct {
+	backend string
+	base    http.RoundTripper
+}
+
+var idempotentMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPut: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+func (t retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !idempotentMethods[req.Method] || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return t.base.RoundTrip(req)
+	}
+
+	var resp *http.Response
+	first := true
+	err := withRetry(req.Context(), t.backend, func() error {
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			resp = nil
+		}
+		attempt := req
+		if !first && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			attempt = req.Clone(req.Context())
+			attempt.Body = body
+		}
+		first = false
+
+		var err error
+		resp, err = t.base.RoundTrip(attempt)
+		if err != nil {
+			return err
+		}
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+			return &retryStatusError{status: resp.Status, retryAfter: time.Duration(seconds) * time.Second}
+		}
+		return nil
+	})
+	// Out of attempts on a retryable status, the caller gets the response
+	var statusErr *retryStatusError
+	if errors.As(err, &statusErr) && resp != nil {
+		return resp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type retryTickMsg struct{}
+
+// retryTick redraws the status bar's countdown to the next attempt.
+func retryTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return retryTickMsg{} })
+}
+
+// renderRetries is the status bar line about calls waiting to be retried.
+func renderRetries() string {
+	retrying.Lock()
+	states := make([]retryState, 0, len(retrying.calls))
+	for _, state := range retrying.calls {
+		states = append(states, state)
+	}
+	retrying.Unlock()
+	if len(states) == 0 {
+		return ""
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].until.Before(states[j].until) })
+
+	var parts []string
+	for _, state := range states {
+		wait := time.Until(state.until).Round(time.Second)
+		if wait < 0 {
+			wait = 0
+		}
+		parts = append(parts, fmt.Sprintf("%s %s, attempt %d of %d in %s",
+			strings.ToLower(state.backend), truncateString(state.err.Error(), 60), state.attempt, state.attempts, wait))
+	}
+	return operationStyle.Render("🔁 " + strings.Join(parts, " · "))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Every user has a role, and each role may do what the ones before it may:
+// viewers only look, deployers also change deployments and open shells in
+// pods, admins also delete images and prune the registry. The REST API
+// checks the role of the authenticated user; the TUI that of the account
+// running it, so a TUI shared through one machine restricts each person to
+// their own role.
+
+const (
+	roleViewer   = "viewer"
+	roleDeployer = "deployer"
+	roleAdmin    = "admin"
+)
+
+var roles = []string{roleViewer, roleDeployer, roleAdmin}
+
+var (
+	permDeleteImages = permission{"delete", "registry", "images"}
+	permExecPods     = permission{"create", "", "pods/exec"}
+)
+
+// requiredRoles are the roles needed for the permissions of mutating
+// actions.
+var requiredRoles = map[permission]string{
+	permCreateDeployments: roleDeployer,
+	permUpdateDeployments: roleDeployer,
+	permPatchDeployments:  roleDeployer,
+	permDeleteDeployments: roleDeployer,
+	permExecPods:          roleDeployer,
+	permDeleteImages:      roleAdmin,
+}
+
+func validateRole(role string) error {
+	if !slices.Contains(roles, role) {
+		return fmt.Errorf("unknown role %q, expected one of %s", role, strings.Join(roles, ", "))
+	}
+	return nil
+}
+
+// roleAllows reports whether role includes required. Unknown roles allow
+// nothing.
+func roleAllows(role, required string) bool {
+	have := slices.Index(roles, role)
+	return have >= 0 && have >= slices.Index(roles, required)
+}
+
+type roleMsg struct {
+	role string
+	err  error
+}
+
+// localRole is the role of the account running the TUI, admin when the
+// users table is empty and the TUI isn't restricted. Accounts without a
+// user are viewers once there are users.
+func localRole(ctx context.Context) (string, error) {
+	dbCtx, cancel := withBackendTimeout(ctx, backendDatabase)
+	defer cancel()
+	var role string
+	err := db.QueryRowContext(dbCtx, "SELECT role FROM users WHERE username = ?", localActor()).Scan(&role)
+	if err == nil {
+		return role, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to look up the role of %s: %v", localActor(), err)
+	}
+	var users int
+	if err := db.QueryRowContext(dbCtx, "SELECT COUNT(*) FROM users").Scan(&users); err != nil {
+		return "", fmt.Errorf("failed to count users: %v", err)
+	}
+	if users == 0 {
+		return roleAdmin, nil
+	}
+	return roleViewer, nil
+}
+
+func (m model) loadRole() tea.Cmd {
+	ctx := m.ctx
+	return func() tea.Msg {
+		role, err := localRole(ctx)
+		if err != nil {
+			// The users can't be read: restrict rather than risk allowing
+			// too much
+			return roleMsg{role: roleViewer, err: err}
+		}
+		return roleMsg{role: role}
+	}
+}
+
+// roleDenied explains why the local user's role doesn't allow p, or returns
+// "" when it does. Until the role is known, e.g. without a database, the
+// user is a viewer.
+func (m model) roleDenied(p permission) string {
+	required, ok := requiredRoles[p]
+	role := cmp.Or(m.role, roleViewer)
+	if !ok || roleAllows(role, required) {
+		return ""
+	}
+	return fmt.Sprintf("🔒 %s is a %s; to %s %s you need the %s role", localActor(), role, p.verb, p.resource, required)
+}
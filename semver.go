@@ -0,0 +1,80 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Tags that are semantic versions, like 1.4.0, v1.4 or 2.0.0-rc.1, are
+// ordered as versions, so 1.10.0 comes after 1.9.0, and the highest one
+// without a pre-release part is each repository's latest stable release.
+// Other tags, like latest or sha-3f9c2ab, keep their lexical order ahead of
+// the versions.
+
+// semverTagPattern keeps dates and build numbers like 20240501 from being
+// read as a major version: a version has at least major.minor.
+var semverTagPattern = regexp.MustCompile(`^v?[0-9]+\.[0-9]+(\.[0-9]+)?([-+].*)?$`)
+
+// latestStableMarker follows the Tag of the latest stable release of its
+// repository on the Docker tab.
+const latestStableMarker = " ★"
+
+// semverTag reads a tag as a version; ok is false for other tags.
+func semverTag(tag string) (version *semver.Version, ok bool) {
+	if !semverTagPattern.MatchString(tag) {
+		return nil, false
+	}
+	version, err := semver.NewVersion(tag)
+	return version, err == nil
+}
+
+// isPrerelease reports whether tag is a version with a pre-release part,
+// e.g. 2.0.0-rc.1.
+func isPrerelease(tag string) bool {
+	version, ok := semverTag(tag)
+	return ok && version.Prerelease() != ""
+}
+
+// compareVersionTags orders other tags lexically, then versions by version; equal
+// versions, like v1.4 and 1.4.0, lexically.
+func compareVersionTags(a, b string) int {
+	versionA, okA := semverTag(a)
+	versionB, okB := semverTag(b)
+	switch {
+	case okA && okB:
+		if c := versionA.Compare(versionB); c != 0 {
+			return c
+		}
+	case okA:
+		return 1
+	case okB:
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+// latestStableImages are the references of the latest stable release of
+// each repository among images.
+func latestStableImages(images []TableData) map[string]bool {
+	latest := make(map[string]string) // host/repository → reference
+	for _, image := range images {
+		if image.ArtifactType != "" || image.ImageTag == "" || image.ImageTag == "N/A" {
+			continue
+		}
+		host, repository, tag := splitRegistryReference(image.ImageTag)
+		if version, ok := semverTag(tag); !ok || version.Prerelease() != "" {
+			continue
+		}
+		key := host + "/" + repository
+		if current, ok := latest[key]; !ok || compareVersionTags(tag, tagOf(current)) > 0 {
+			latest[key] = image.ImageTag
+		}
+	}
+	references := make(map[string]bool, len(latest))
+	for _, reference := range latest {
+		references[reference] = true
+	}
+	return references
+}
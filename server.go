@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// healthCheck probes one backend. Required checks decide readiness; the
+// others are reported but never take the daemon out of rotation.
+type healthCheck struct {
+	name     string
+	required bool
+	check    func(ctx context.Context) error
+}
+
+type healthResult struct {
+	OK       bool   `json:"ok"`
+	Required bool   `json:"required"`
+	Latency  string `json:"latency"`
+	Error    string `json:"error,omitempty"`
+}
+
+type healthReport struct {
+	Status string                  `json:"status"`
+	Checks map[string]healthResult `json:"checks"`
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := fs.String("listen", envOrDefault("SERVER_ADDR", ":8080"), "address to listen on")
+	require := fs.String("require", envOrDefault("SERVER_READY_CHECKS", "database,registry,kubernetes"), "comma-separated checks that must pass for /readyz")
+	web := fs.Bool("web", os.Getenv("SERVER_WEB") == "true", "serve the web dashboard at /")
+	pprofAddr := fs.String("pprof", os.Getenv("PPROF_ADDR"), "serve pprof profiles on this address, e.g. localhost:6060 (default: off)")
+	addRegistryFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	setupLogging()
+	fixKubeconfigPaths()
+
+	var err error
+	db, err = openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	if err := ensureSchema(ctx); err != nil {
+		log.Printf("failed to update database schema: %v", err)
+	}
+
+	startProfiling(ctx, *pprofAddr)
+	startAuditForwarding()
+	startJobs(ctx)
+	startCollectors(ctx)
+	startPushNotifications(ctx)
+	startAutoDeploy(ctx)
+
+	mux := http.NewServeMux()
+	registerHealthRoutes(mux, newHealthChecks(strings.Split(*require, ",")))
+	registerMetricsRoute(mux)
+	registerAPIRoutes(mux)
+	if webhookSecret() != "" {
+		startWebhookBuilds(ctx)
+		mux.Handle(webhookPath, webhookHandler())
+	}
+	if *web {
+		registerWebRoutes(mux)
+	}
+
+	server := &http.Server{Addr: *listen, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("🚀 Serving on %s\n", *listen)
+	if *web {
+		fmt.Printf("🌐 Web dashboard on http://%s/\n", dashboardHost(*listen))
+	}
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func newHealthChecks(required []string) []healthCheck {
+	isRequired := make(map[string]bool)
+	for _, name := range required {
+		isRequired[strings.TrimSpace(name)] = true
+	}
+
+	checks := []healthCheck{
+		{
+			name:     "database",
+			required: isRequired["database"],
+			check: func(ctx context.Context) error {
+				if db == nil {
+					return fmt.Errorf("database not configured")
+				}
+				ctx, cancel := withBackendTimeout(ctx, backendDatabase)
+				defer cancel()
+				return db.PingContext(ctx)
+			},
+		},
+		{
+			name:     "registry",
+			required: isRequired["registry"],
+			check: func(ctx context.Context) error {
+				return newRegistryClient(ctx, getRegistryHost()).Ping(ctx)
+			},
+		},
+	}
+	// Without Kubernetes readiness doesn't wait for a cluster
+	if !kubernetesEnabled() {
+		return checks
+	}
+	return append(checks,
+		healthCheck{
+			name:     "kubernetes",
+			required: isRequired["kubernetes"],
+			check: func(ctx context.Context) error {
+				ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+				defer cancel()
+				_, err := newCluster(ctx).ServerVersion(ctx)
+				return err
+			},
+		},
+	)
+}
+
+// registerHealthRoutes adds the liveness and readiness probes. /healthz only
+// says the process is serving; /readyz reports every backend check.
+func registerHealthRoutes(mux *http.ServeMux, checks []healthCheck) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintln(w, "ok")
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		report := runHealthChecks(r.Context(), checks)
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status != "ready" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+func runHealthChecks(ctx context.Context, checks []healthCheck) healthReport {
+	type namedResult struct {
+		name   string
+		result healthResult
+	}
+
+	// Run the checks concurrently so a probe costs the slowest backend, not the sum
+	results := make(chan namedResult, len(checks))
+	for _, hc := range checks {
+		go func(hc healthCheck) {
+			start := time.Now()
+			err := hc.check(ctx)
+			result := healthResult{
+				OK:       err == nil,
+				Required: hc.required,
+				Latency:  time.Since(start).Truncate(time.Millisecond).String(),
+			}
+			if err != nil {
+				result.Error = err.Error()
+				log.Printf("readiness check %s failed: %v", hc.name, err)
+			}
+			results <- namedResult{name: hc.name, result: result}
+		}(hc)
+	}
+
+	report := healthReport{Status: "ready", Checks: make(map[string]healthResult)}
+	for range checks {
+		r := <-results
+		report.Checks[r.name] = r.result
+		if r.result.Required && !r.result.OK {
+			report.Status = "not ready"
+		}
+	}
+	return report
+}
+
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
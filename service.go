@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const serviceName = "local-container-registry"
+
+const systemdUnitTemplate = `[Unit]
+Description=Local Container Registry daemon
+After=network-online.target docker.service
+Wants=network-online.target
+
+[Service]
+Type=simple
+WorkingDirectory={{.WorkingDir}}
+{{- if .EnvFile}}
+EnvironmentFile={{.EnvFile}}
+{{- end}}
+ExecStart={{quote .Executable}} serve --listen {{quote .Listen}}
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy={{if .System}}multi-user.target{{else}}default.target{{end}}
+`
+
+// launchd has no EnvironmentFile equivalent, so the .env contents are
+// copied into the plist at install time.
+const launchdPlistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{.Label}}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{xml .Executable}}</string>
+		<string>serve</string>
+		<string>--listen</string>
+		<string>{{xml .Listen}}</string>
+	</array>
+	<key>WorkingDirectory</key>
+	<string>{{xml .WorkingDir}}</string>
+{{- if .Env}}
+	<key>EnvironmentVariables</key>
+	<dict>
+{{- range .Env}}
+		<key>{{xml .Key}}</key>
+		<string>{{xml .Value}}</string>
+{{- end}}
+	</dict>
+{{- end}}
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>{{xml .WorkingDir}}/daemon.log</string>
+	<key>StandardErrorPath</key>
+	<string>{{xml .WorkingDir}}/daemon.log</string>
+</dict>
+</plist>
+`
+
+type serviceEnvVar struct {
+	Key   string
+	Value string
+}
+
+type serviceDefinition struct {
+	Label      string
+	Executable string
+	WorkingDir string
+	EnvFile    string
+	Env        []serviceEnvVar
+	Listen     string
+	System     bool
+}
+
+func runInstall(args []string) error {
+	fs := flag.NewFlagSet("install", flag.ContinueOnError)
+	service := fs.Bool("service", false, "install the daemon as a systemd (Linux) or launchd (macOS) service")
+	system := fs.Bool("system", false, "install system-wide instead of for the current user (needs root)")
+	envFile := fs.String("env-file", ".env", "environment file the service should load")
+	listen := fs.String("listen", envOrDefault("SERVER_ADDR", ":8080"), "address the daemon listens on")
+	dryRun := fs.Bool("dry-run", false, "print the service definition instead of installing it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*service {
+		return fmt.Errorf("install: nothing to install (did you mean --service?)")
+	}
+
+	def, err := newServiceDefinition(*envFile, *listen, *system)
+	if err != nil {
+		return err
+	}
+
+	path, content, err := renderServiceDefinition(def)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Printf("# %s\n%s", path, content)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// The launchd plist may embed secrets from .env, so keep it private
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	fmt.Printf("📝 Wrote %s\n", path)
+
+	if err := enableService(path, *system); err != nil {
+		return err
+	}
+	fmt.Println("✅ Service installed and started")
+	return nil
+}
+
+func runUninstall(args []string) error {
+	fs := flag.NewFlagSet("uninstall", flag.ContinueOnError)
+	service := fs.Bool("service", false, "remove the daemon service")
+	system := fs.Bool("system", false, "remove the system-wide service instead of the user one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*service {
+		return fmt.Errorf("uninstall: nothing to uninstall (did you mean --service?)")
+	}
+
+	path, err := serviceDefinitionPath(*system)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Errorf("no service installed at %s", path)
+	}
+
+	// Stopping may fail if the service is already stopped; removal still proceeds
+	if err := disableService(path, *system); err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	if runtime.GOOS == "linux" {
+		runServiceManager(*system, "daemon-reload")
+	}
+	fmt.Printf("🗑️  Removed %s\n", path)
+	return nil
+}
+
+func newServiceDefinition(envFile, listen string, system bool) (serviceDefinition, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return serviceDefinition{}, err
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return serviceDefinition{}, err
+	}
+
+	def := serviceDefinition{
+		Label:      "com." + serviceName,
+		Executable: executable,
+		WorkingDir: workingDir,
+		Listen:     listen,
+		System:     system,
+	}
+
+	if envFile != "" {
+		envPath, err := filepath.Abs(envFile)
+		if err != nil {
+			return def, err
+		}
+		if _, err := os.Stat(envPath); err == nil {
+			// The app loads .env from its working directory, so run it from there
+			def.EnvFile = envPath
+			def.WorkingDir = filepath.Dir(envPath)
+
+			values, err := godotenv.Read(envPath)
+			if err != nil {
+				return def, fmt.Errorf("failed to read %s: %v", envPath, err)
+			}
+			for key, value := range values {
+				def.Env = append(def.Env, serviceEnvVar{Key: key, Value: value})
+			}
+			sort.Slice(def.Env, func(i, j int) bool { return def.Env[i].Key < def.Env[j].Key })
+		}
+	}
+	return def, nil
+}
+
+func serviceDefinitionPath(system bool) (string, error) {
+	switch runtime.GOOS {
+	case "linux":
+		if system {
+			return filepath.Join("/etc/systemd/system", serviceName+".service"), nil
+		}
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(configDir, "systemd", "user", serviceName+".service"), nil
+	case "darwin":
+		if system {
+			return filepath.Join("/Library/LaunchDaemons", "com."+serviceName+".plist"), nil
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, "Library", "LaunchAgents", "com."+serviceName+".plist"), nil
+	default:
+		return "", fmt.Errorf("service install is not supported on %s", runtime.GOOS)
+	}
+}
+
+func renderServiceDefinition(def serviceDefinition) (string, string, error) {
+	path, err := serviceDefinitionPath(def.System)
+	if err != nil {
+		return "", "", err
+	}
+
+	text := systemdUnitTemplate
+	if runtime.GOOS == "darwin" {
+		text = launchdPlistTemplate
+	}
+	tmpl, err := template.New("service").Funcs(template.FuncMap{"xml": xmlEscape, "quote": systemdQuote}).Parse(text)
+	if err != nil {
+		return "", "", err
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, def); err != nil {
+		return "", "", err
+	}
+	return path, out.String(), nil
+}
+
+func enableService(path string, system bool) error {
+	if runtime.GOOS == "darwin" {
+		return runLaunchctl("load", "-w", path)
+	}
+	if err := runServiceManager(system, "daemon-reload"); err != nil {
+		return err
+	}
+	return runServiceManager(system, "enable", "--now", serviceName+".service")
+}
+
+func disableService(path string, system bool) error {
+	if runtime.GOOS == "darwin" {
+		return runLaunchctl("unload", "-w", path)
+	}
+	return runServiceManager(system, "disable", "--now", serviceName+".service")
+}
+
+func runServiceManager(system bool, args ...string) error {
+	if !system {
+		args = append([]string{"--user"}, args...)
+	}
+	return runServiceCommand("systemctl", args...)
+}
+
+func runLaunchctl(args ...string) error {
+	return runServiceCommand("launchctl", args...)
+}
+
+func runServiceCommand(name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %v\nOutput: %s", name, strings.Join(args, " "), err, string(output))
+	}
+	return nil
+}
+
+func xmlEscape(value string) string {
+	var out strings.Builder
+	xml.EscapeText(&out, []byte(value))
+	return out.String()
+}
+
+// systemdQuote quotes one word of a command line in a unit, so paths with
+// spaces stay one argument; % starts a specifier, so it is doubled.
+func systemdQuote(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%").Replace(value)
+	return `"` + value + `"`
+}
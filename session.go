@@ -0,0 +1,446 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Started with --record <file>, the TUI records the actions taken in it to
+// a session file: the tabs opened, the clusters and registries switched to,
+// and each pull, delete, deploy, restart and so on with what it was given.
+// Keystrokes aren't recorded, so a session replays the same whatever the
+// rows on screen. `replay <file>` takes the actions again without the TUI,
+// through the same code, printing the tabs and each action's outcome: to
+// reproduce a bug report, or to run a demo.
+
+// sessionVersion is the version of the session file format.
+const sessionVersion = 1
+
+// session is a recorded session file.
+type session struct {
+	Version  int           `json:"version"`
+	Started  time.Time     `json:"started"`
+	User     string        `json:"user"`
+	Registry string        `json:"registry"`
+	Cluster  string        `json:"cluster,omitempty"`
+	Steps    []sessionStep `json:"steps"`
+}
+
+// sessionStep is one action of a session with what it needs to be taken
+// again; which fields are set depends on the action.
+type sessionStep struct {
+	Time       time.Time     `json:"time"`
+	Action     string        `json:"action"`
+	Tab        string        `json:"tab,omitempty"`
+	Cluster    string        `json:"cluster,omitempty"`
+	Registry   string        `json:"registry,omitempty"`
+	Image      string        `json:"image,omitempty"`
+	LocalImage string        `json:"localImage,omitempty"` // of push and pull-local
+	Tag        string        `json:"tag,omitempty"`        // the new tag of a retag
+	Deployment string        `json:"deployment,omitempty"`
+	Namespace  string        `json:"namespace,omitempty"`
+	Container  string        `json:"container,omitempty"`
+	Env        *containerEnv `json:"env,omitempty"`
+	Replicas   int32         `json:"replicas,omitempty"` // of a canary
+	// Form is the create form as typed, by deployFieldKeys
+	Form            map[string]string `json:"form,omitempty"`
+	CreateNamespace bool              `json:"createNamespace,omitempty"`
+}
+
+// The actions of a session.
+const (
+	sessionTab              = "tab"
+	sessionCluster          = "cluster"
+	sessionRegistry         = "registry"
+	sessionPull             = "pull"
+	sessionDelete           = "delete"
+	sessionDeploy           = "deploy"
+	sessionCreateDeployment = "create-deployment"
+	sessionRestart          = "restart"
+	sessionRetag            = "retag"
+	sessionUndo             = "undo"
+	sessionDeleteDeployment = "delete-deployment"
+	sessionPush             = "push"
+	sessionPullLocal        = "pull-local"
+	sessionCanary           = "canary"
+	sessionPromoteCanary    = "promote-canary"
+	sessionRollbackCanary   = "rollback-canary"
+)
+
+// String describes the step for the replay's output.
+func (s sessionStep) String() string {
+	deployment := s.Namespace + "/" + s.Deployment
+	switch s.Action {
+	case sessionTab:
+		return "open the " + s.Tab + " tab"
+	case sessionCluster:
+		return "switch to cluster " + s.Cluster
+	case sessionRegistry:
+		return "switch to registry " + s.Registry
+	case sessionPull:
+		return "pull " + s.Image
+	case sessionDelete:
+		return "delete local image " + s.Image
+	case sessionDeploy:
+		if s.Container != "" {
+			return fmt.Sprintf("deploy %s to %s, container %s", s.Image, deployment, s.Container)
+		}
+		return fmt.Sprintf("deploy %s to %s", s.Image, deployment)
+	case sessionCreateDeployment:
+		return fmt.Sprintf("create deployment %s/%s from %s", s.Form["namespace"], s.Form["name"], s.Image)
+	case sessionRestart:
+		return "restart " + deployment
+	case sessionRetag:
+		return fmt.Sprintf("tag %s as %s", s.Image, s.Tag)
+	case sessionUndo:
+		return "undo the last action"
+	case sessionDeleteDeployment:
+		return "delete deployment " + deployment
+	case sessionPush:
+		return fmt.Sprintf("push local %s to %s", s.LocalImage, s.Image)
+	case sessionPullLocal:
+		return fmt.Sprintf("pull %s over local %s", s.Image, s.LocalImage)
+	case sessionCanary:
+		return fmt.Sprintf("start a canary of %s for %s", s.Image, deployment)
+	case sessionPromoteCanary:
+		return "promote the canary of " + deployment
+	case sessionRollbackCanary:
+		return "roll back the canary of " + deployment
+	}
+	return s.Action
+}
+
+// sessionRecording is the session the TUI records, with no path when it
+// doesn't.
+var sessionRecording struct {
+	sync.Mutex
+	path    string
+	session session
+}
+
+// startSessionRecording records the TUI's actions to path from now on.
+func startSessionRecording(path string) error {
+	sessionRecording.Lock()
+	defer sessionRecording.Unlock()
+	sessionRecording.path = path
+	sessionRecording.session = session{
+		Version:  sessionVersion,
+		Started:  time.Now().UTC(),
+		User:     localActor(),
+		Registry: getRegistryHost(),
+		Cluster:  currentCluster().name,
+		Steps:    []sessionStep{},
+	}
+	return writeSession(path, sessionRecording.session)
+}
+
+// recordSessionStep appends a step to the session being recorded, if any.
+// The file is written after each step, so a crash leaves every step before
+// it in there. Failing to write doesn't fail the action.
+func recordSessionStep(step sessionStep) {
+	sessionRecording.Lock()
+	defer sessionRecording.Unlock()
+	if sessionRecording.path == "" {
+		return
+	}
+	step.Time = time.Now().UTC()
+	sessionRecording.session.Steps = append(sessionRecording.session.Steps, step)
+	if err := writeSession(sessionRecording.path, sessionRecording.session); err != nil {
+		log.Printf("failed to record the session: %v", err)
+	}
+}
+
+func writeSession(path string, s session) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Deploys carry the env typed for them, secrets included
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+func readSession(path string) (session, error) {
+	var s session
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("%s is not a session file: %v", path, err)
+	}
+	if s.Version > sessionVersion {
+		return s, fmt.Errorf("%s was recorded by a newer version (session format %d)", path, s.Version)
+	}
+	return s, nil
+}
+
+// runReplay takes the actions of a recorded session again, without the TUI.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "list the steps without taking them")
+	keepGoing := fs.Bool("keep-going", false, "carry on after a step fails")
+	realtime := fs.Bool("realtime", false, "wait between steps as long as the recording did, e.g. for a demo")
+	addRegistryFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: replay [--dry-run] [--keep-going] [--realtime] <session.json>")
+	}
+	recorded, err := readSession(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🎬 Session of %s recorded %s against %s, %d steps\n", recorded.User, recorded.Started.Local().Format(time.DateTime), recorded.Registry, len(recorded.Steps))
+	if *dryRun {
+		for i, step := range recorded.Steps {
+			fmt.Printf("%3d  +%-8s %s\n", i+1, step.Time.Sub(recorded.Started).Round(time.Second), step)
+		}
+		return nil
+	}
+	if registry := getRegistryHost(); registry != recorded.Registry {
+		fmt.Printf("⚠️  Replaying against %s instead\n", registry)
+	}
+
+	fixKubeconfigPaths()
+	ctx, stop := signalContext()
+	defer stop()
+	connectDatabase(ctx)
+
+	m, cancel := newModel(backends{})
+	defer cancel()
+	m = m.runConcurrently(m.startupLoads())
+	if db != nil {
+		m = m.runConcurrently([]tea.Cmd{m.loadRole()})
+	}
+	m = m.runConcurrently([]tea.Cmd{m.loadPermissions()})
+	exitIfInterrupted(m.ctx)
+
+	var failed int
+	previous := recorded.Started
+	for i, step := range recorded.Steps {
+		if *realtime {
+			select {
+			case <-time.After(step.Time.Sub(previous)):
+			case <-ctx.Done():
+			}
+			previous = step.Time
+		}
+		exitIfInterrupted(ctx)
+		fmt.Printf("\n▶ %d/%d %s\n", i+1, len(recorded.Steps), step)
+		status := m.statusMessage
+		m, err = m.replayStep(step)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			failed++
+			if !*keepGoing {
+				return fmt.Errorf("step %d failed; --keep-going carries on after failures", i+1)
+			}
+		} else if m.statusMessage != status && m.statusMessage != "" {
+			fmt.Println(m.statusMessage)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d steps failed", failed, len(recorded.Steps))
+	}
+	fmt.Printf("\n✅ Replayed %d steps\n", len(recorded.Steps))
+	return nil
+}
+
+// replayStep takes step the way the TUI did, with the role and RBAC checks
+// the TUI makes before it.
+func (m model) replayStep(step sessionStep) (model, error) {
+	var cmd tea.Cmd
+	switch step.Action {
+	case sessionTab:
+		tab := tabIndex(step.Tab)
+		if tab < 0 || !tabAvailable(tab) {
+			return m, fmt.Errorf("no %s tab", step.Tab)
+		}
+		m.switchTab(tab)
+		m = m.runConcurrently([]tea.Cmd{m.loadTabData()})
+		m.printTab()
+		return m, nil
+	case sessionCluster:
+		for _, c := range configuredClusters() {
+			if c.name == step.Cluster || c.displayName() == step.Cluster {
+				m, err := m.replayCmd(m.switchCluster(c))
+				return m, err
+			}
+		}
+		return m, fmt.Errorf("no cluster %s in KUBE_CLUSTERS or the kubeconfig", step.Cluster)
+	case sessionRegistry:
+		registry := registryEndpoint{name: step.Registry, host: step.Registry}
+		for _, configured := range configuredRegistries() {
+			if configured.host == step.Registry {
+				registry = configured
+			}
+		}
+		cmd = m.switchRegistry(registry)
+	case sessionPull:
+		cmd = m.pullDockerImage(step.Image)
+	case sessionDelete:
+		if reason := m.denied(permDeleteImages); reason != "" {
+			return m, errors.New(reason)
+		}
+		cmd = m.deleteDockerImage(step.Image)
+	case sessionDeploy:
+		if reason := m.denied(m.deployPermission()); reason != "" {
+			return m, errors.New(reason)
+		}
+		m.selectDeployImage(step)
+		cmd = m.deployImageToPod(step.Image, step.Deployment, step.Namespace, step.Container, step.Env)
+	case sessionCreateDeployment:
+		if reason := m.denied(permCreateDeployments); reason != "" {
+			return m, errors.New(reason)
+		}
+		m.selectDeployImage(step)
+		m.initDeployForm()
+		for i, key := range deployFieldKeys {
+			if value, ok := step.Form[key]; ok {
+				m.deployInputs[i].SetValue(value)
+			}
+		}
+		params, err := m.deployFormParams()
+		if err != nil {
+			return m, err
+		}
+		params.CreateNamespace = step.CreateNamespace
+		cmd = m.createNewDeployment(step.Image, params)
+	case sessionRestart:
+		if reason := m.denied(permPatchDeployments); reason != "" {
+			return m, errors.New(reason)
+		}
+		cmd = m.restartDeployment(step.Deployment, step.Namespace)
+	case sessionRetag:
+		cmd = m.retagImage(step.Image, step.Tag)
+	case sessionUndo:
+		if reason := m.roleDenied(permUpdateDeployments); reason != "" {
+			return m, errors.New(reason)
+		}
+		cmd = m.undoLastAction()
+	case sessionDeleteDeployment:
+		if reason := m.denied(permDeleteDeployments); reason != "" {
+			return m, errors.New(reason)
+		}
+		cmd = m.deleteDeployment(step.Deployment, step.Namespace)
+	case sessionPush, sessionPullLocal:
+		direction := "push"
+		if step.Action == sessionPullLocal {
+			direction = "pull"
+		}
+		cmd = m.reconcileImage(TableData{ImageTag: step.Image, LocalRef: step.LocalImage}, direction)
+	case sessionCanary:
+		if reason := m.denied(permCreateDeployments); reason != "" {
+			return m, errors.New(reason)
+		}
+		m.selectDeployImage(step)
+		m.canaryReplicas = step.Replicas
+		cmd = m.startCanary(step.Deployment, step.Namespace)
+	case sessionPromoteCanary, sessionRollbackCanary:
+		promote := step.Action == sessionPromoteCanary
+		if reason := m.denied(m.deployPermission()); promote && reason != "" {
+			return m, errors.New(reason)
+		}
+		m.selectDeployImage(step)
+		m.canaryDeployment, m.canaryNamespace = step.Deployment, step.Namespace
+		cmd = m.finishCanary(promote)
+	default:
+		return m, fmt.Errorf("unknown action %q", step.Action)
+	}
+	return m.replayCmd(cmd)
+}
+
+// selectDeployImage puts the model where the deploy dialog leaves it for
+// the step's image, deployment and container.
+func (m *model) selectDeployImage(step sessionStep) {
+	m.selectedImage, m.deployContainer, m.deployEnv = step.Image, step.Container, step.Env
+	m.deployProfile = loadDeployProfile(m.tabCtx, step.Image)
+}
+
+// replayCmd runs cmd the way the TUI would and waits for the operations it
+// starts, returning their errors. The ticks redrawing the TUI are dropped,
+// as are the loads the results trigger.
+func (m model) replayCmd(cmd tea.Cmd) (model, error) {
+	var errs []error
+	for _, msg := range runCmds(cmd) {
+		switch msg := msg.(type) {
+		case operationTickMsg, pullTickMsg, canaryTickMsg:
+			continue
+		case operationDoneMsg:
+			if err := operationError(msg.msg); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		updated, _ := m.Update(msg)
+		m = updated.(model)
+	}
+	return m, errors.Join(errs...)
+}
+
+// runCmds runs cmd and, when it's a batch, the commands in it at the same
+// time, and returns their messages.
+func runCmds(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	msg := cmd()
+	batch, ok := msg.(tea.BatchMsg)
+	if !ok {
+		return []tea.Msg{msg}
+	}
+	results := make([][]tea.Msg, len(batch))
+	var wg sync.WaitGroup
+	for i, cmd := range batch {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runCmds(cmd)
+		}()
+	}
+	wg.Wait()
+	var msgs []tea.Msg
+	for _, result := range results {
+		msgs = append(msgs, result...)
+	}
+	return msgs
+}
+
+// operationError is the error an operation's result reports, nil when it
+// succeeded.
+func operationError(msg tea.Msg) error {
+	var err error
+	switch msg := msg.(type) {
+	case dockerDeleteMsg:
+		err = msg.err
+	case dockerPullMsg:
+		err = msg.err
+	case dockerRefreshMsg:
+		err = msg.err
+	case deploymentMsg:
+		err = msg.err
+	case restartMsg:
+		err = msg.err
+	case retagMsg:
+		err = msg.err
+	case reconcileMsg:
+		err = msg.err
+	case undoMsg:
+		err = msg.err
+	case deploymentDeletedMsg:
+		err = msg.err
+	case canaryStartedMsg:
+		err = msg.err
+	case canaryDoneMsg:
+		err = msg.err
+	}
+	return err
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// rootCtx is the context everything the process starts derives from. It is
+// cancelled on Ctrl+C or SIGTERM, and when the process shuts down.
+var rootCtx, cancelRoot = context.WithCancel(context.Background())
+
+// shutdown tears the process down in one place however it ends: main
+// returning, a fatal error or a signal. In-flight work is cancelled first,
+// so docker and helm child processes are killed, then cleanups run,
+// newest first.
+var shutdown struct {
+	sync.Mutex
+	hooks []shutdownHook
+	next  int
+	done  bool
+}
+
+type shutdownHook struct {
+	id  int
+	run func()
+}
+
+// onShutdown registers cleanup to run at shutdown. remove unregisters it,
+// for cleanups done once their work is over.
+func onShutdown(cleanup func()) (remove func()) {
+	shutdown.Lock()
+	defer shutdown.Unlock()
+	id := shutdown.next
+	shutdown.next++
+	shutdown.hooks = append(shutdown.hooks, shutdownHook{id: id, run: cleanup})
+	return func() {
+		shutdown.Lock()
+		defer shutdown.Unlock()
+		for i, hook := range shutdown.hooks {
+			if hook.id == id {
+				shutdown.hooks = append(shutdown.hooks[:i], shutdown.hooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// runShutdown cancels in-flight work and runs the cleanups. Only the first
+// call does anything.
+func runShutdown() {
+	shutdown.Lock()
+	if shutdown.done {
+		shutdown.Unlock()
+		return
+	}
+	shutdown.done = true
+	hooks := shutdown.hooks
+	shutdown.hooks = nil
+	shutdown.Unlock()
+
+	cancelRoot()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i].run()
+	}
+}
+
+// exit is os.Exit with the cleanups run first.
+func exit(code int) {
+	runShutdown()
+	os.Exit(code)
+}
+
+// fatal is log.Fatal with the cleanups run first.
+func fatal(v ...any) {
+	log.Print(v...)
+	exit(1)
+}
+
+// exitIfInterrupted ends startup quietly once Ctrl+C has cancelled ctx,
+// instead of going on with whatever the interrupted calls returned.
+func exitIfInterrupted(ctx context.Context) {
+	if ctx.Err() != nil {
+		exit(130)
+	}
+}
+
+// watchSignals turns Ctrl+C and SIGTERM into a shutdown. The first cancels
+// in-flight work so commands stop between steps; a second exits right away,
+// still cleaning up.
+func watchSignals() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancelRoot()
+		<-signals
+		fmt.Fprintln(os.Stderr, "\n🛑 Interrupted again, exiting")
+		exit(130)
+	}()
+}
+
+// closeDatabaseOnShutdown closes the global db handle at shutdown.
+func closeDatabaseOnShutdown() {
+	handle := db
+	onShutdown(func() { handle.Close() })
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/store"
+)
+
+// The size trend view charts a repository's builds as recorded in
+// image_records, oldest first, so an image that suddenly grows stands out
+// before it is deployed everywhere.
+
+// sizeTrendBuilds is how many of the latest builds the chart shows.
+const sizeTrendBuilds = 40
+
+// sizeTrendBarWidth is the width of the longest bar.
+const sizeTrendBarWidth = 30
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+type sizeTrendMsg struct {
+	registry   string
+	repository string
+	points     []store.SizePoint // nil while loading
+	err        error
+}
+
+// sizeGrowthWarning is the growth from one build to the next flagged as
+// bloat: SIZE_GROWTH_WARN percent, default 10.
+func sizeGrowthWarning() float64 {
+	if value, err := strconv.ParseFloat(os.Getenv("SIZE_GROWTH_WARN"), 64); err == nil && value > 0 {
+		return value / 100
+	}
+	return 0.10
+}
+
+// imageSizeHistory is the latest builds of repository with a known size,
+// oldest first.
+func imageSizeHistory(ctx context.Context, registry, repository string) ([]store.SizePoint, error) {
+	if dataStore(ctx) == nil {
+		return nil, fmt.Errorf("no database to read the size history from")
+	}
+	points, err := dataStore(ctx).SizeHistory(ctx, registry, repository, sizeTrendBuilds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the size history of %s: %v", repository, err)
+	}
+	return points, nil
+}
+
+func (m model) loadSizeTrend(registry, repository string) tea.Cmd {
+	ctx := m.tabCtx
+	return func() tea.Msg {
+		points, err := imageSizeHistory(ctx, registry, repository)
+		return sizeTrendMsg{registry: registry, repository: repository, points: points, err: err}
+	}
+}
+
+// openSizeTrend opens the chart of the highlighted image's repository.
+func (m *model) openSizeTrend(item TableData) tea.Cmd {
+	if imageRegistryHost(item.ImageTag) == "" || item.ImageDigest == "" {
+		m.statusMessage = "Only images in a registry have a size history"
+		return nil
+	}
+	registry, repository, _ := splitRegistryReference(item.ImageTag)
+	m.sizeTrend = &sizeTrendMsg{registry: registry, repository: repository}
+	m.showSizeTrend = true
+	return m.loadSizeTrend(registry, repository)
+}
+
+func (m model) updateSizeTrend(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "q", "z":
+		m.showSizeTrend = false
+	}
+	return m, nil
+}
+
+// sparkline draws values as block characters scaled between their minimum
+// and maximum.
+func sparkline(values []int64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	low, high := values[0], values[0]
+	for _, value := range values {
+		low, high = min(low, value), max(high, value)
+	}
+	var b strings.Builder
+	for _, value := range values {
+		level := len(sparkBlocks) - 1
+		if high > low {
+			level = int(float64(value-low) / float64(high-low) * float64(len(sparkBlocks)-1))
+		}
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
+
+// sizeTrendLines are the chart's rows: the sparkline, then a bar per build
+// with its growth over the build before, flagged past SIZE_GROWTH_WARN.
+func sizeTrendLines(points []store.SizePoint, warning float64) []string {
+	sizes := make([]int64, len(points))
+	var largest int64
+	for i, point := range points {
+		sizes[i] = point.Size
+		largest = max(largest, point.Size)
+	}
+	first, last := points[0].Size, points[len(points)-1].Size
+	lines := []string{
+		fmt.Sprintf("%s  %s → %s (%s) over %d builds", sparkline(sizes), formatBytes(first), formatBytes(last), formatSizeDelta(last-first), len(points)),
+		"",
+	}
+
+	for i, point := range points {
+		width := 1
+		if largest > 0 {
+			width = max(1, int(float64(point.Size)/float64(largest)*sizeTrendBarWidth))
+		}
+		growth := ""
+		if i > 0 && points[i-1].Size > 0 {
+			change := float64(point.Size-points[i-1].Size) / float64(points[i-1].Size)
+			growth = fmt.Sprintf("%+.1f%%", change*100)
+			if change > warning {
+				growth += " ⚠️"
+			}
+		}
+		// Padded by hand, as fmt pads bytes and the blocks take three
+		bar := strings.Repeat("█", width) + strings.Repeat(" ", sizeTrendBarWidth-width)
+		lines = append(lines, fmt.Sprintf("%-20s %s %9s %s %s",
+			truncateString(point.Tag, 20), point.PushedAt.Format("2006-01-02 15:04"), formatBytes(point.Size), bar, growth))
+	}
+	return lines
+}
+
+func (m model) renderSizeTrend() string {
+	var b strings.Builder
+	trend := m.sizeTrend
+	fmt.Fprintf(&b, "Image size of %s/%s\n\n", trend.registry, trend.repository)
+	switch {
+	case trend.err != nil:
+		fmt.Fprintf(&b, "⚠️  %v\n", trend.err)
+	case trend.points == nil:
+		b.WriteString("Reading the size history...\n")
+	case len(trend.points) == 0:
+		b.WriteString("No builds recorded yet; they are recorded each time the Docker tab loads\n")
+	default:
+		for _, line := range sizeTrendLines(trend.points, sizeGrowthWarning()) {
+			b.WriteString(line + "\n")
+		}
+	}
+	b.WriteString("\nESC to close")
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, modalStyle.Width(100).Height(0).Render(b.String()), lipgloss.WithWhitespaceChars("░"))
+}
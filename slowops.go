@@ -0,0 +1,137 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The slow ops report lists the slowest backend calls of the session: the
+// client spans of registry, Kubernetes and GitHub requests, docker commands
+// and database writes. They are recorded whether or not traces are exported.
+
+const slowOpsLimit = 10
+
+// slowOp is one backend call of the report.
+type slowOp struct {
+	name   string // the span name, e.g. "HTTP GET" or "docker images"
+	target string // what it was called on, e.g. the host and path
+	took   time.Duration
+	at     time.Time
+	failed bool
+}
+
+// slowOpsRecorder is a span processor keeping the slowest client spans.
+type slowOpsRecorder struct {
+	mu  sync.Mutex
+	ops []slowOp // slowest first, at most slowOpsLimit
+}
+
+var slowOps = &slowOpsRecorder{}
+
+func (r *slowOpsRecorder) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (r *slowOpsRecorder) OnEnd(span sdktrace.ReadOnlySpan) {
+	if span.SpanKind() != trace.SpanKindClient {
+		return
+	}
+	op := slowOp{
+		name:   span.Name(),
+		target: spanTarget(span.Attributes()),
+		took:   span.EndTime().Sub(span.StartTime()),
+		at:     span.StartTime(),
+		failed: span.Status().Code == codes.Error,
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.ops) == slowOpsLimit && op.took <= r.ops[len(r.ops)-1].took {
+		return
+	}
+	i, _ := slices.BinarySearchFunc(r.ops, op, func(a, b slowOp) int { return cmp.Compare(b.took, a.took) })
+	r.ops = slices.Insert(r.ops, i, op)
+	if len(r.ops) > slowOpsLimit {
+		r.ops = r.ops[:slowOpsLimit]
+	}
+}
+
+func (r *slowOpsRecorder) Shutdown(context.Context) error   { return nil }
+func (r *slowOpsRecorder) ForceFlush(context.Context) error { return nil }
+
+// slowest is the recorded calls, slowest first.
+func (r *slowOpsRecorder) slowest() []slowOp {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.ops)
+}
+
+// spanTarget describes what a client span called from its attributes.
+func spanTarget(attrs []attribute.KeyValue) string {
+	values := make(map[attribute.Key]attribute.Value)
+	for _, attr := range attrs {
+		values[attr.Key] = attr.Value
+	}
+	switch {
+	case values["server.address"].AsString() != "":
+		return values["server.address"].AsString() + values["url.path"].AsString()
+	case len(values["docker.args"].AsStringSlice()) > 1:
+		return strings.Join(values["docker.args"].AsStringSlice()[1:], " ")
+	case values["lcr.action"].AsString() != "":
+		return values["lcr.action"].AsString()
+	}
+	return ""
+}
+
+// slowOpsLines is the report as a table.
+func slowOpsLines(ops []slowOp) []string {
+	lines := []string{fmt.Sprintf("%-9s  %-8s  %-16s  %s", "TOOK", "AT", "CALL", "TARGET")}
+	for _, op := range ops {
+		target := op.target
+		if op.failed {
+			target += " (failed)"
+		}
+		if len(target) > 70 {
+			target = target[:67] + "..."
+		}
+		lines = append(lines, fmt.Sprintf("%-9s  %-8s  %-16s  %s", op.took.Round(time.Millisecond), op.at.Format("15:04:05"), op.name, target))
+	}
+	return lines
+}
+
+func (m model) updateSlowOps(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "q", "O":
+		m.showSlowOps = false
+	}
+	return m, nil
+}
+
+// renderSlowOps reads the recorder on every render, so the report keeps up
+// with the calls made while it is open.
+func (m model) renderSlowOps() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("The %d slowest backend calls of this session\n\n", slowOpsLimit))
+	if ops := slowOps.slowest(); len(ops) == 0 {
+		b.WriteString("No backend calls yet\n")
+	} else {
+		for _, line := range slowOpsLines(ops) {
+			b.WriteString(line + "\n")
+		}
+	}
+	b.WriteString("\nESC to close")
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, modalStyle.Width(110).Height(0).Render(b.String()), lipgloss.WithWhitespaceChars("░"))
+}
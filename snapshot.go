@@ -0,0 +1,290 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// startOptions are the flags of the TUI itself, as opposed to subcommands.
+type startOptions struct {
+	tabs     []int         // tabs to snapshot; the first one is where the TUI opens
+	plain    bool          // print a snapshot instead of starting the TUI
+	watch    bool          // keep printing rows as they change after the snapshot
+	interval time.Duration // how often to reload the tabs when watching
+	record   string        // session file the TUI's actions are recorded to
+}
+
+// defaultSnapshotTabs are the tabs whose data is collected at startup anyway.
+var defaultSnapshotTabs = []int{0, 1, 2}
+
+func parseStartOptions(args []string) (startOptions, error) {
+	fs := flag.NewFlagSet("local-container-registry", flag.ContinueOnError)
+	tab := fs.String("tab", "", "tab to open, or comma-separated tabs to print with --plain (git, docker, kubernetes, ..., all)")
+	plain := fs.Bool("plain", false, "print the tabs as plain text instead of starting the TUI; the default when stdout isn't a terminal")
+	var watch bool
+	fs.BoolVar(&watch, "watch", false, "after printing the tabs, keep printing rows as they change, like kubectl get -w")
+	fs.BoolVar(&watch, "w", false, "shorthand for --watch")
+	interval := fs.Duration("interval", 5*time.Second, "how often --watch reloads the tabs")
+	record := fs.String("record", "", "record the actions taken in the TUI to this session file, for replay")
+	addRegistryFlags(fs)
+	fs.Usage = printUsage
+	if err := fs.Parse(args); err != nil {
+		return startOptions{}, err
+	}
+	if fs.NArg() > 0 {
+		return startOptions{}, fmt.Errorf("unknown command %q, see help", fs.Arg(0))
+	}
+
+	if *interval <= 0 {
+		return startOptions{}, fmt.Errorf("--interval must be positive")
+	}
+	options := startOptions{plain: *plain || watch || !isTTYAvailable(), watch: watch, interval: *interval, record: *record}
+	if options.plain && options.record != "" {
+		return startOptions{}, fmt.Errorf("--record records the interactive TUI, which --plain and --watch don't start")
+	}
+	for _, i := range defaultSnapshotTabs {
+		if tabAvailable(i) {
+			options.tabs = append(options.tabs, i)
+		}
+	}
+	if *tab == "all" {
+		options.tabs = nil
+		for i := range tabNames {
+			if tabAvailable(i) {
+				options.tabs = append(options.tabs, i)
+			}
+		}
+	} else if *tab != "" {
+		options.tabs = nil
+		for _, name := range strings.Split(*tab, ",") {
+			index := tabIndex(strings.TrimSpace(name))
+			if index < 0 {
+				return startOptions{}, fmt.Errorf("unknown tab %q (have %s)", name, strings.ToLower(strings.Join(tabNames, ", ")))
+			}
+			if !tabAvailable(index) {
+				return startOptions{}, fmt.Errorf("no %s tab: %v", tabNames[index], errKubernetesDisabled)
+			}
+			options.tabs = append(options.tabs, index)
+		}
+	}
+	return options, nil
+}
+
+// tabIndex finds a tab by name, accepting k8s or pods for Kubernetes and
+// images for Docker; -1 if none.
+func tabIndex(name string) int {
+	switch strings.ToLower(name) {
+	case "k8s", "pods":
+		name = "kubernetes"
+	case "images":
+		name = "docker"
+	}
+	for i, tab := range tabNames {
+		if strings.EqualFold(tab, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// runConcurrently runs cmds the way the TUI does, at the same time, and
+// waits for all of them before updating m with their results.
+func (m model) runConcurrently(cmds []tea.Cmd) model {
+	msgs := make([]tea.Msg, len(cmds))
+	var wg sync.WaitGroup
+	for i, cmd := range cmds {
+		if cmd == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msgs[i] = cmd()
+		}()
+	}
+	wg.Wait()
+	for _, msg := range msgs {
+		if msg != nil {
+			updated, _ := m.Update(msg)
+			m = updated.(model)
+		}
+	}
+	return m
+}
+
+// printSnapshot prints tabs as plain-text tables with the line the TUI shows
+// about each, for CI logs and lcr | tee where no terminal can host the TUI.
+// With watch it then keeps going, see watchSnapshot.
+func printSnapshot(options startOptions) {
+	m, cancel := newModel(backends{})
+	defer cancel()
+
+	m = m.runConcurrently(m.startupLoads())
+	exitIfInterrupted(m.ctx)
+	if m.statusMessage != "" {
+		fmt.Println(m.statusMessage)
+	}
+
+	seen := make(map[int]map[string]bool)
+	for i, tab := range options.tabs {
+		m.switchTab(tab)
+		if cmd := m.loadTabData(); cmd != nil {
+			// The TUI loads these in the background; here there's time to wait
+			m = m.runConcurrently([]tea.Cmd{cmd})
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("== %s ==\n", m.tabs[tab])
+		if status := m.tabStatus(); status != "" {
+			fmt.Println(status)
+		}
+		rows := m.table.Rows()
+		seen[tab] = rowSet(m.table.Columns(), rows)
+		if len(rows) == 0 {
+			fmt.Println("Nothing to show")
+			continue
+		}
+		if options.watch {
+			// Later rows must line up without seeing the whole table
+			fmt.Println(fixedWidthRow(m.table.Columns(), columnTitles(m.table.Columns())))
+			for _, row := range rows {
+				fmt.Println(fixedWidthRow(m.table.Columns(), row))
+			}
+			continue
+		}
+		printTable(m.table.Columns(), rows)
+	}
+
+	if options.watch {
+		m.watchSnapshot(options, seen)
+	}
+}
+
+// printTab prints the active tab the way printSnapshot does.
+func (m model) printTab() {
+	fmt.Printf("== %s ==\n", m.tabs[m.activeTab])
+	if status := m.tabStatus(); status != "" {
+		fmt.Println(status)
+	}
+	if rows := m.table.Rows(); len(rows) > 0 {
+		printTable(m.table.Columns(), rows)
+	} else {
+		fmt.Println("Nothing to show")
+	}
+}
+
+func columnTitles(columns []table.Column) []string {
+	var titles []string
+	for _, column := range columns {
+		titles = append(titles, strings.ToUpper(column.Title))
+	}
+	return titles
+}
+
+// printTable prints rows aligned under the columns' titles.
+func printTable(columns []table.Column, rows []table.Row) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(columnTitles(columns), "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
+
+// watchSnapshot reloads the tabs every interval and prints the rows that
+// are new or changed since the last reload, like kubectl get -w. With
+// several tabs each batch is headed by its tab. Ctrl+C stops it.
+func (m model) watchSnapshot(options startOptions, seen map[int]map[string]bool) {
+	ticker := time.NewTicker(options.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, tab := range options.tabs {
+			m.switchTab(tab)
+			m = m.runConcurrently([]tea.Cmd{m.reloadTab()})
+			if m.ctx.Err() != nil {
+				return
+			}
+			rows := m.table.Rows()
+			var changed []table.Row
+			for _, row := range rows {
+				if !seen[tab][rowKey(m.table.Columns(), row)] {
+					changed = append(changed, row)
+				}
+			}
+			seen[tab] = rowSet(m.table.Columns(), rows)
+			if len(changed) == 0 {
+				continue
+			}
+			if len(options.tabs) > 1 {
+				fmt.Printf("== %s ==\n", m.tabs[tab])
+			}
+			for _, row := range changed {
+				fmt.Println(fixedWidthRow(m.table.Columns(), row))
+			}
+		}
+	}
+}
+
+// reloadTab loads the active tab's data again, including the tabs the TUI
+// only loads at startup.
+func (m model) reloadTab() tea.Cmd {
+	ctx := m.ctx
+	switch m.activeTab {
+	case 0:
+		return m.loadCommitPage(1)
+	case 1:
+		return func() tea.Msg { return loadDockerTable(ctx) }
+	case 2:
+		return m.loadKubePods()
+	}
+	return m.loadTabData()
+}
+
+// rowKey identifies a row by its cells, leaving out the Age column, which
+// changes with every reload without anything having changed.
+func rowKey(columns []table.Column, row table.Row) string {
+	var cells []string
+	for i, cell := range row {
+		if i < len(columns) && columns[i].Title == "Age" {
+			continue
+		}
+		cells = append(cells, cell)
+	}
+	return strings.Join(cells, "\x00")
+}
+
+func rowSet(columns []table.Column, rows []table.Row) map[string]bool {
+	set := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		set[rowKey(columns, row)] = true
+	}
+	return set
+}
+
+// fixedWidthRow pads each cell to its column's width, which cells are cut
+// to already, so rows printed apart still line up.
+func fixedWidthRow(columns []table.Column, row []string) string {
+	cells := make([]string, len(row))
+	for i, cell := range row {
+		if i < len(columns) {
+			cell += strings.Repeat(" ", max(0, columns[i].Width-len([]rune(cell))))
+		}
+		cells[i] = cell
+	}
+	return strings.TrimRight(strings.Join(cells, "  "), " ")
+}
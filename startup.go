@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/gitprovider"
+	"github.com/anthony-gilbert/local-container-registry/pkg/store"
+)
+
+// The Git, Docker and Kubernetes tabs are loaded when the TUI starts, from
+// all backends at once. Each tab shows a loading line until its data
+// streams in, so the TUI is up before the slowest backend answers.
+
+type databaseMsg struct {
+	err error
+}
+
+// startupLoads are the commands loading the first tabs, run concurrently.
+func (m model) startupLoads() []tea.Cmd {
+	ctx := m.ctx
+	return []tea.Cmd{
+		func() tea.Msg { return databaseMsg{err: pingDatabase(ctx)} },
+		m.loadCommitPage(1),
+		func() tea.Msg { return loadDockerTable(ctx) },
+		m.loadKubePods(),
+	}
+}
+
+// loadDockerTable loads the Docker tab's rows, or the last-known-good ones
+// when neither the registry nor the docker engine answers. Only rows from
+// the registry are kept, so local images listed while it is down don't
+// replace them.
+func loadDockerTable(ctx context.Context) dockerRefreshMsg {
+	images, err := getDockerImagesInfo(ctx)
+	if err != nil {
+		if rows, stale := lastKnownGood(ctx, 1, err); stale != nil {
+			return dockerRefreshMsg{data: rows, stale: stale}
+		}
+		return dockerRefreshMsg{err: err}
+	}
+	rows := dockerTableData(ctx, images)
+	if slices.ContainsFunc(images, func(image DockerImage) bool { return image.Digest != "" }) {
+		saveLastKnownGood(ctx, 1, rows)
+	}
+	return dockerRefreshMsg{data: rows}
+}
+
+// pingDatabase connects the db handle main opened and brings its schema up
+// to date.
+func pingDatabase(ctx context.Context) error {
+	pingCtx, cancel := withBackendTimeout(ctx, backendDatabase)
+	err := db.PingContext(pingCtx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if err := ensureSchema(ctx); err != nil {
+		log.Printf("failed to update database schema: %v", err)
+	}
+	return nil
+}
+
+// gitBranch is the branch of GITHUB_REPO the Git tab lists.
+const gitBranch = "master"
+
+// getGitCommits fetches a page of the commits of GITHUB_REPO's master branch
+// in the query's date range and path, newest first, and stores the messages
+// of new ones in the database. more reports whether there are older pages.
+func getGitCommits(ctx context.Context, query gitCommitQuery) (data []TableData, more bool, err error) {
+	ctx, span := startSpan(ctx, "load commits", attribute.Int("lcr.page", max(query.page, 1)))
+	defer func() { endSpan(span, err) }()
+	githubCtx, cancel := withBackendTimeout(ctx, backendGitHub)
+	page, err := newGitProvider(ctx).ListCommits(githubCtx, gitprovider.ListOptions{
+		Branch:  gitBranch,
+		Since:   query.since,
+		Until:   query.until,
+		Path:    query.path,
+		Page:    max(query.page, 1),
+		PerPage: gitCommitsPerPage,
+	})
+	cancel()
+	// The latest commits, unfiltered, are what the event bus carries
+	latest := query.page <= 1 && query.unfiltered()
+	if err != nil {
+		err = fmt.Errorf("failed to list commits of %s/%s: %v", os.Getenv("GITHUB_OWNER"), os.Getenv("GITHUB_REPO"), err)
+		if latest {
+			publishSnapshot[TableData](eventCommits, nil, err)
+		}
+		return nil, false, err
+	}
+
+	gitData := []TableData{}
+	for _, commit := range page.Commits {
+		// An unchanged page has been stored already
+		if !page.Cached {
+			storeCommit(ctx, commit.SHA, commit.Message, commit.Author, commit.Committer)
+		}
+
+		// Get PushedAt from individual commit date
+		pushedAt := "N/A"
+		if !commit.Date.IsZero() {
+			pushedAt = commit.Date.Format("2006-01-02 15:04:05")
+		}
+
+		gitData = append(gitData, TableData{
+			CommitSHA:     commit.SHA,
+			PRDescription: commit.Message,
+			Author:        commit.Author,
+			Committer:     commit.Committer,
+			PushedAt:      pushedAt,
+		})
+	}
+	if latest {
+		publishSnapshot(eventCommits, gitData, nil)
+	}
+	return gitData, page.More, nil
+}
+
+// storeCommit records a commit's message and author unless the commit is
+// stored already, filling in the author of one stored before authors were.
+// Like history, this is best effort.
+func storeCommit(ctx context.Context, sha, message, author, committer string) {
+	if dataStore(ctx) == nil {
+		return
+	}
+	commit := store.Commit{SHA: sha, Message: message, Author: author, Committer: committer}
+	if err := dataStore(ctx).RecordCommit(ctx, commit); err != nil {
+		log.Printf("failed to store commit %s: %v", sha, err)
+	}
+}
+
+func (m model) renderGitStatus() string {
+	switch {
+	case m.gitData == nil && m.gitErr == nil:
+		return fmt.Sprintf("Loading commits of %s/%s...", os.Getenv("GITHUB_OWNER"), os.Getenv("GITHUB_REPO"))
+	case m.gitErr != nil:
+		return fmt.Sprintf("⚠️  %v", m.gitErr)
+	}
+	if rate := renderGitHubRate(); rate != "" {
+		return m.renderCommitPaging() + "\n" + rate
+	}
+	return m.renderCommitPaging()
+}
+
+func (m model) renderDockerLoadStatus() string {
+	switch {
+	case m.dockerData == nil && m.dockerErr == nil:
+		return fmt.Sprintf("Loading images of %s...", registryName())
+	case m.dockerErr != nil:
+		return fmt.Sprintf("⚠️  %v", m.dockerErr)
+	}
+	if stats := registryRefreshStats(); stats != nil && stats.host == getRegistryHost() {
+		return stats.String()
+	}
+	return ""
+}
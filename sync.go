@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
+
+// syncChunkSize is how much of a blob is uploaded per PATCH. After a failure
+// at most one chunk is sent again.
+const syncChunkSize = 8 << 20
+
+// syncState remembers open upload sessions on the remote, keyed by
+// host/repository@digest, so an interrupted sync (even a killed process)
+// resumes a blob instead of uploading it from the start.
+type syncState struct {
+	mu      sync.Mutex
+	path    string
+	Uploads map[string]string `json:"uploads"`
+}
+
+func loadSyncState() *syncState {
+	state := &syncState{Uploads: make(map[string]string)}
+	if dir := registryCacheDir(); dir != "" {
+		state.path = filepath.Join(dir, "sync-state.json")
+		if data, err := os.ReadFile(state.path); err == nil {
+			json.Unmarshal(data, state)
+		}
+	}
+	return state
+}
+
+func (s *syncState) get(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Uploads[key]
+}
+
+func (s *syncState) set(key, location string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if location == "" {
+		delete(s.Uploads, key)
+	} else {
+		s.Uploads[key] = location
+	}
+	if s.path == "" {
+		return
+	}
+	if data, err := json.Marshal(s); err == nil {
+		if err := os.MkdirAll(filepath.Dir(s.path), 0755); err == nil {
+			writeFileAtomic(s.path, data)
+		}
+	}
+}
+
+type registrySyncer struct {
+	src, dst registryClient
+	state    *syncState
+	retries  int
+	parallel int // blobs uploaded at once
+}
+
+// syncRetries is SYNC_RETRIES, the attempts per blob, default 5.
+func syncRetries() int {
+	retries, err := strconv.Atoi(os.Getenv("SYNC_RETRIES"))
+	if err != nil || retries < 0 {
+		return 5
+	}
+	return retries
+}
+
+// uploadParallelism is UPLOAD_PARALLELISM, how many blobs of an image sync
+// and migrate upload at once, default 4.
+func uploadParallelism() int {
+	parallel, err := strconv.Atoi(os.Getenv("UPLOAD_PARALLELISM"))
+	if err != nil || parallel < 1 {
+		return 4
+	}
+	return parallel
+}
+
+func runSync(args []string) error {
+	retries := syncRetries()
+
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	from := fs.String("from", getRegistryHost(), "registry to mirror (host:port or URL)")
+	to := fs.String("to", os.Getenv("SYNC_REMOTE"), "remote registry to mirror into (host:port or URL)")
+	repos := fs.String("repos", os.Getenv("SYNC_REPOS"), "comma-separated repositories to mirror (default: all)")
+	every := fs.Duration("every", 0, "keep running and sync on this interval, e.g. 1h")
+	fs.IntVar(&retries, "retries", retries, "attempts per blob before giving up on an image")
+	dryRun := fs.Bool("dry-run", false, "show which images are out of date without copying")
+	parallel := fs.Int("parallel", uploadParallelism(), "blobs of an image to upload at once")
+	addRegistryFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *to == "" {
+		return fmt.Errorf("sync: --to (or SYNC_REMOTE) is required")
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	syncer := &registrySyncer{
+		src:      newRegistryClient(ctx, *from),
+		dst:      newRegistryClient(ctx, *to),
+		state:    loadSyncState(),
+		retries:  retries,
+		parallel: *parallel,
+	}
+	if syncer.src.BaseURL() == syncer.dst.BaseURL() {
+		return fmt.Errorf("sync: source and destination are the same registry")
+	}
+
+	connectDatabase(ctx)
+	printBandwidthLimit()
+
+	var repositories []string
+	if *repos != "" {
+		repositories = strings.Split(*repos, ",")
+	}
+
+	for {
+		if err := syncer.run(ctx, repositories, *dryRun); err != nil {
+			if *every == 0 {
+				return err
+			}
+			fmt.Printf("❌ %v\n", err)
+		}
+		if *every == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*every):
+		}
+	}
+}
+
+// run mirrors every tag of the repositories (all when empty). Tags whose
+// manifest digest already matches on the remote are skipped, so a re-run
+// after a failure only copies what is still missing.
+func (s *registrySyncer) run(ctx context.Context, repositories []string, dryRun bool) error {
+	if len(repositories) == 0 {
+		var err error
+		repositories, err = listRepositories(ctx, s.src)
+		if err != nil {
+			return fmt.Errorf("failed to list repositories on %s: %v", s.src.Host(), err)
+		}
+	}
+
+	fmt.Printf("🔁 Syncing %d repositories from %s to %s\n", len(repositories), s.src.Host(), s.dst.Host())
+
+	copied, current, failed := 0, 0, 0
+	for _, repo := range repositories {
+		repo = strings.TrimSpace(repo)
+		tags, err := s.src.Tags(ctx, repo)
+		if err != nil {
+			fmt.Printf("❌ %s: failed to list tags: %v\n", repo, err)
+			failed++
+			continue
+		}
+		for _, tag := range tags {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			name := fmt.Sprintf("%s:%s", repo, tag)
+			body, mediaType, err := s.src.Manifest(ctx, repo, tag)
+			if err != nil {
+				fmt.Printf("❌ %s: %v\n", name, err)
+				failed++
+				continue
+			}
+			digest := registry.ComputeDigest(body)
+			if remote, err := s.dst.ManifestDigest(ctx, repo, tag); err == nil && remote == digest {
+				current++
+				continue
+			}
+			if dryRun {
+				fmt.Printf("📦 %s would be copied (dry run)\n", name)
+				continue
+			}
+
+			err = s.copyManifestContent(ctx, repo, body)
+			if err == nil {
+				err = s.dst.PutManifest(ctx, repo, tag, mediaType, body)
+			}
+			if err != nil {
+				fmt.Printf("❌ %s: %v\n", name, err)
+				recordHistory(ctx, "sync", name, "failed", err.Error())
+				failed++
+				continue
+			}
+			fmt.Printf("✅ %s\n", name)
+			recordHistory(ctx, "sync", name, "copied", fmt.Sprintf("to %s; digest %s", s.dst.Host(), digest))
+			copied++
+		}
+	}
+
+	summary := fmt.Sprintf("copied %d images, %d already up to date, %d failed", copied, current, failed)
+	if dryRun {
+		fmt.Printf("Dry run: %d images up to date\n", current)
+		return nil
+	}
+	fmt.Printf("Sync finished: %s\n", summary)
+	recordHistory(ctx, "sync", s.dst.Host(), "completed", summary)
+	if failed > 0 {
+		return fmt.Errorf("%d images failed to sync", failed)
+	}
+	return nil
+}
+
+// copyBlob uploads a blob in chunks, retrying with backoff. Each retry asks
+// the remote how much it already has and continues from there.
+func (s *registrySyncer) copyBlob(ctx context.Context, repository string, blob registryDescriptor) error {
+	exists, err := s.dst.BlobExists(ctx, repository, blob.Digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = s.uploadBlob(ctx, repository, blob)
+		if err == nil || ctx.Err() != nil || attempt >= s.retries {
+			return err
+		}
+		wait := time.Duration(1<<attempt) * time.Second
+		if wait > 30*time.Second {
+			wait = 30 * time.Second
+		}
+		fmt.Printf("⚠️  %s: %v, retrying in %s\n", shortDigest(blob.Digest), err, wait)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (s *registrySyncer) uploadBlob(ctx context.Context, repository string, blob registryDescriptor) error {
+	key := fmt.Sprintf("%s/%s@%s", s.dst.Host(), repository, blob.Digest)
+
+	var offset int64
+	location := s.state.get(key)
+	if location != "" {
+		var err error
+		if offset, err = s.dst.UploadOffset(ctx, location); err != nil {
+			// The session expired or the remote restarted; start over
+			location = ""
+		}
+	}
+	if location == "" {
+		var err error
+		if location, err = s.dst.StartUpload(ctx, repository); err != nil {
+			return err
+		}
+		offset = 0
+		s.state.set(key, location)
+	}
+
+	// Everything arrived before the interruption; only completion is missing
+	var content io.ReadCloser = io.NopCloser(strings.NewReader(""))
+	if blob.Size == 0 || offset < blob.Size {
+		var err error
+		if content, err = s.src.GetBlobRange(ctx, repository, blob.Digest, offset); err != nil {
+			return err
+		}
+	}
+	defer content.Close()
+
+	// Lines of concurrent uploads can't share one redrawn line
+	live := isTTYAvailable() && s.parallel <= 1
+	progress := newTransferProgress(fmt.Sprintf("%s@%s", repository, shortDigest(blob.Digest)), blob.Size, offset, live)
+	chunk := make([]byte, syncChunkSize)
+	for {
+		n, readErr := io.ReadFull(content, chunk)
+		if n > 0 {
+			next, err := s.dst.PatchUpload(ctx, location, chunk[:n], offset)
+			if err != nil {
+				return err
+			}
+			location = next
+			offset += int64(n)
+			s.state.set(key, location)
+			progress.update(offset)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	if err := s.dst.CompleteUpload(ctx, location, blob.Digest, nil, 0); err != nil {
+		// A session that can't be completed (e.g. digest mismatch) is useless
+		s.state.set(key, "")
+		return err
+	}
+	s.state.set(key, "")
+	progress.done()
+	return nil
+}
+
+// transferProgress prints per-blob progress, redrawing one line when live
+// and only the final line otherwise.
+type transferProgress struct {
+	label   string
+	total   int64
+	current int64
+	tty     bool
+	drawn   time.Time
+}
+
+func newTransferProgress(label string, total, offset int64, live bool) *transferProgress {
+	p := &transferProgress{label: label, total: total, current: offset, tty: live}
+	if offset > 0 {
+		fmt.Printf("⏯️  %s resuming at %s\n", label, formatBytes(offset))
+	}
+	return p
+}
+
+func (p *transferProgress) update(current int64) {
+	p.current = current
+	if !p.tty || time.Since(p.drawn) < 200*time.Millisecond {
+		return
+	}
+	p.drawn = time.Now()
+	percent := 0
+	if p.total > 0 {
+		percent = int(p.current * 100 / p.total)
+	}
+	fmt.Printf("\r⬆️  %s %s / %s (%d%%)", p.label, formatBytes(p.current), formatBytes(p.total), percent)
+}
+
+func (p *transferProgress) done() {
+	if p.tty {
+		fmt.Print("\r\033[K")
+	}
+	fmt.Printf("⬆️  %s %s\n", p.label, formatBytes(p.current))
+}
+
+func shortDigest(digest string) string {
+	_, hexPart, _ := strings.Cut(digest, ":")
+	if len(hexPart) > 12 {
+		hexPart = hexPart[:12]
+	}
+	return hexPart
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/backend/mock"
+	"github.com/anthony-gilbert/local-container-registry/pkg/docker"
+	"github.com/anthony-gilbert/local-container-registry/pkg/gitprovider"
+	"github.com/anthony-gilbert/local-container-registry/pkg/kube"
+	"github.com/anthony-gilbert/local-container-registry/pkg/testenv"
+)
+
+// timings are the durations the status line reports, e.g. how long a
+// refresh took, which differ from run to run.
+var timings = regexp.MustCompile(`\b\d+(\.\d+)?(ns|µs|ms|s)\b`)
+
+// tabBackends are a repository with one commit, a local image, and a
+// cluster with a node, a deployment of two pods and a ConfigMap it uses.
+func tabBackends(t *testing.T) backends {
+	ctrl := gomock.NewController(t)
+	git := mock.NewMockGitProvider(ctrl)
+	git.EXPECT().ListCommits(gomock.Any(), gomock.Any()).Return(gitprovider.Page{Commits: []gitprovider.Commit{
+		{SHA: "a1b2c3d4e5f6", Message: "Add health check", Author: "dev"},
+	}}, nil).AnyTimes()
+
+	runtime := mock.NewMockContainerRuntime(ctrl)
+	runtime.EXPECT().Images(gomock.Any()).Return([]docker.Image{
+		{ID: "sha256:0123456789abcdef", Repository: "web", Tag: "dev", Size: "12MB"},
+	}, nil).AnyTimes()
+	runtime.EXPECT().Labels(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	runtime.EXPECT().Version(gomock.Any()).Return("27.0.0", nil).AnyTimes()
+
+	deployment := testenv.Deployment("default", "web", "localhost:5000/web:v1", 2)
+	deployment.Spec.Template.Spec.Containers[0].EnvFrom = []corev1.EnvFromSource{{
+		ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "web-config"}},
+	}}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4"), corev1.ResourceMemory: resource.MustParse("8Gi")},
+			Conditions:  []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			NodeInfo:    corev1.NodeSystemInfo{KubeletVersion: "v1.30.0"},
+		},
+	}
+	config := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-config", Namespace: "default"},
+		Data:       map[string]string{"LOG_LEVEL": "debug"},
+	}
+	objects := append(testenv.PodsFor(deployment, 2), deployment, node, config)
+
+	return backends{git: git, runtime: runtime, cluster: kube.New(testenv.FakeClientset(objects...))}
+}
+
+// TestTabs compares each tab, reached with Tab from the Git tab, with
+// testdata/<tab>-tab.golden.
+func TestTabs(t *testing.T) {
+	for tab, name := range tabNames {
+		t.Run(name, func(t *testing.T) {
+			env := testenv.Start(t, false)
+			env.Registry.PushImage("web", "v1", nil, []byte("layer"))
+			db, _ = openDatabase() // nothing answers; the TUI shows it as down
+
+			start, cancel := newModel(tabBackends(t))
+			defer cancel()
+			var keys []tea.Msg
+			for range tab {
+				keys = append(keys, testenv.Key("tab"))
+			}
+			m, err := testenv.RunTUI(start, 160, 30, time.Second, keys...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			view := timings.ReplaceAllString(m.View(), "1ms")
+			testenv.Golden(t, strings.ToLower(name)+"-tab", view, env.Registry.Host(), "registry:5000")
+		})
+	}
+}
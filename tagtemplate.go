@@ -0,0 +1,178 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IMAGE_TAG_TEMPLATE names the images built from commits after their git
+// metadata: webhook builds are pushed with the tag it gives, the image-tag
+// command prints it for builds elsewhere, and tags it gives are traced back
+// to their commit in the image inventory and deploy annotations. Its
+// placeholders are
+//
+//	{branch}    the branch, with characters a tag can't hold turned into -
+//	{sha}       the full commit SHA
+//	{shortsha}  its first seven characters
+//	{date}      the build's UTC date, YYYYMMDD
+//
+// e.g. "{branch}-{shortsha}". It must hold {sha} or {shortsha}, so every
+// tag names its commit.
+
+// defaultImageTagTemplate is how builds were always tagged; its tags are
+// traced back whatever the template is now.
+const defaultImageTagTemplate = "sha-{shortsha}"
+
+// tagPlaceholders are the placeholders' patterns in a tag; a commit is
+// captured.
+var tagPlaceholders = map[string]string{
+	"{branch}":   `[A-Za-z0-9_.-]+`,
+	"{sha}":      `([0-9a-f]{40})`,
+	"{shortsha}": `([0-9a-f]{7,40})`,
+	"{date}":     `[0-9]{8}`,
+}
+
+var tagPlaceholderPattern = regexp.MustCompile(`\{[a-z]*\}`)
+
+// invalidTagChars are what a tag can't hold: [A-Za-z0-9_.-] only.
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// tagTemplate is a parsed IMAGE_TAG_TEMPLATE.
+type tagTemplate struct {
+	text    string
+	pattern *regexp.Regexp // matches the template's tags, capturing the commit
+}
+
+// parseTagTemplate checks a template's placeholders and literal text.
+func parseTagTemplate(text string) (tagTemplate, error) {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	commits := 0
+	last := 0
+	for _, match := range tagPlaceholderPattern.FindAllStringIndex(text, -1) {
+		placeholder := text[match[0]:match[1]]
+		expr, ok := tagPlaceholders[placeholder]
+		if !ok {
+			return tagTemplate{}, fmt.Errorf("tag template %q has unknown placeholder %s; use {branch}, {sha}, {shortsha} or {date}", text, placeholder)
+		}
+		if placeholder == "{sha}" || placeholder == "{shortsha}" {
+			commits++
+		}
+		pattern.WriteString(regexp.QuoteMeta(text[last:match[0]]) + expr)
+		last = match[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(text[last:]) + "$")
+
+	literal := tagPlaceholderPattern.ReplaceAllString(text, "")
+	switch {
+	case commits == 0:
+		return tagTemplate{}, fmt.Errorf("tag template %q must hold {sha} or {shortsha}", text)
+	case commits > 1:
+		return tagTemplate{}, fmt.Errorf("tag template %q must hold the commit once", text)
+	case invalidTagChars.MatchString(literal) || strings.HasPrefix(text, ".") || strings.HasPrefix(text, "-"):
+		return tagTemplate{}, fmt.Errorf("tag template %q can only hold letters, digits, _, . and -, and not start with . or -", text)
+	}
+	return tagTemplate{text: text, pattern: regexp.MustCompile(pattern.String())}, nil
+}
+
+// imageTagTemplate is IMAGE_TAG_TEMPLATE, the default when unset or
+// malformed. It is parsed once, warning once when malformed.
+var imageTagTemplate = sync.OnceValue(func() tagTemplate {
+	text := envOrDefault("IMAGE_TAG_TEMPLATE", defaultImageTagTemplate)
+	template, err := parseTagTemplate(text)
+	if err != nil {
+		log.Printf("⚠️  Ignoring IMAGE_TAG_TEMPLATE: %v", err)
+		template, _ = parseTagTemplate(defaultImageTagTemplate)
+	}
+	return template
+})
+
+// commitTagTemplates are the templates whose tags name a commit: the
+// configured one and, when that isn't it, the default.
+var commitTagTemplates = sync.OnceValue(func() []tagTemplate {
+	templates := []tagTemplate{imageTagTemplate()}
+	if templates[0].text != defaultImageTagTemplate {
+		fallback, _ := parseTagTemplate(defaultImageTagTemplate)
+		templates = append(templates, fallback)
+	}
+	return templates
+})
+
+// tag is the tag of a build of commit sha on branch at now, cut to the 128
+// characters a tag can have.
+func (t tagTemplate) tag(branch, sha string, now time.Time) string {
+	branch = strings.Trim(invalidTagChars.ReplaceAllString(branch, "-"), ".-")
+	if branch == "" {
+		branch = "detached"
+	}
+	tag := strings.NewReplacer(
+		"{branch}", branch,
+		"{sha}", sha,
+		"{shortsha}", sha[:min(7, len(sha))],
+		"{date}", now.UTC().Format("20060102"),
+	).Replace(t.text)
+	return tag[:min(128, len(tag))]
+}
+
+// tagCommit is the commit a tag names, by IMAGE_TAG_TEMPLATE or as a
+// sha-<commit> tag; ok is false for other tags.
+func tagCommit(tag string) (sha string, ok bool) {
+	for _, template := range commitTagTemplates() {
+		if match := template.pattern.FindStringSubmatch(tag); match != nil {
+			return match[1], true
+		}
+	}
+	return "", false
+}
+
+// runImageTag prints the tag IMAGE_TAG_TEMPLATE gives a build of the
+// checked-out commit, or of --branch and --sha, for builds pushed by CI or
+// by hand.
+func runImageTag(args []string) error {
+	fs := flag.NewFlagSet("image-tag", flag.ContinueOnError)
+	branch := fs.String("branch", "", "branch of the build (default: the checked-out branch)")
+	sha := fs.String("sha", "", "commit of the build (default: HEAD)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: image-tag [--branch <branch>] [--sha <commit>]")
+	}
+	template, err := parseTagTemplate(envOrDefault("IMAGE_TAG_TEMPLATE", defaultImageTagTemplate))
+	if err != nil {
+		return err
+	}
+	if *sha == "" {
+		if *sha, err = gitRevParse("HEAD"); err != nil {
+			return err
+		}
+	}
+	if *branch == "" && strings.Contains(template.text, "{branch}") {
+		if *branch, err = gitRevParse("--abbrev-ref", "HEAD"); err != nil {
+			return err
+		}
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{7,40}$`).MatchString(*sha) {
+		return fmt.Errorf("commit %q is not a hex SHA", *sha)
+	}
+	fmt.Println(template.tag(*branch, *sha, time.Now()))
+	return nil
+}
+
+// gitRevParse runs git rev-parse in the working directory.
+func gitRevParse(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"rev-parse"}, args...)...)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse %s failed, pass --branch and --sha outside a checkout: %v", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
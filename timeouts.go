@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Backends that get their own timeout. Each can be overridden with
+// <BACKEND>_TIMEOUT using Go duration syntax, e.g. REGISTRY_TIMEOUT=30s.
+const (
+	backendRegistry   = "REGISTRY"
+	backendKubernetes = "KUBERNETES"
+	backendDocker     = "DOCKER"
+	backendGitHub     = "GITHUB"
+	backendDatabase   = "MYSQL"
+)
+
+var defaultBackendTimeouts = map[string]time.Duration{
+	backendRegistry:   10 * time.Second,
+	backendKubernetes: 15 * time.Second,
+	backendDocker:     5 * time.Minute, // pulls and minikube image loads are slow
+	backendGitHub:     15 * time.Second,
+	backendDatabase:   5 * time.Second,
+}
+
+func backendTimeout(backend string) time.Duration {
+	if value := os.Getenv(backend + "_TIMEOUT"); value != "" {
+		if timeout, err := time.ParseDuration(value); err == nil && timeout > 0 {
+			return timeout
+		}
+	}
+	return defaultBackendTimeouts[backend]
+}
+
+// withBackendTimeout bounds a single call to a backend. The parent context
+// still wins if it is cancelled first (e.g. the user switched tabs).
+func withBackendTimeout(ctx context.Context, backend string) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, backendTimeout(backend))
+}
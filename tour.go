@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var tourStyle = lipgloss.NewStyle().
+	BorderStyle(lipgloss.RoundedBorder()).
+	BorderForeground(lipgloss.Color("#7D56F4")).
+	Padding(0, 2)
+
+type tourStep struct {
+	title string
+	body  string
+	tab   int // tab shown behind the step, -1 keeps the current one
+}
+
+var tourSteps = []tourStep{
+	{
+		title: "Welcome to Local Container Registry",
+		body: "This short tour shows where everything lives. Tables are filled with\n" +
+			"sample data wherever a backend isn't configured yet.",
+		tab: -1,
+	},
+	{
+		title: "Git tab",
+		body: "Recent commits from GITHUB_OWNER/GITHUB_REPO. Every commit message\n" +
+			"is stored in the MySQL images table.",
+		tab: 0,
+	},
+	{
+		title: "Docker tab",
+		body: "Images in your registry (REGISTRY_HOST), falling back to local Docker\n" +
+			"images. In Use shows which workloads run each image; F lists only the\n" +
+			"unused ones. Ctrl+D deletes the selected image, Ctrl+P pulls it.",
+		tab: 1,
+	},
+	{
+		title: "Deploying an image",
+		body: "Press Enter on an image to open the deploy dialog. Pick an existing\n" +
+			"deployment to roll it to the image, or create a new deployment.",
+		tab: 1,
+	},
+	{
+		title: "Kubernetes tab",
+		body: "Pods in your cluster with status, restarts and node. Press Enter on\n" +
+			"a pod to see its full definition.",
+		tab: 2,
+	},
+	{
+		title: "Headless commands",
+		body: "The same binary has scripting commands: migrate, prune, serve and\n" +
+			"install. Run it with help to list them.",
+		tab: -1,
+	},
+	{
+		title: "That's it!",
+		body:  "Press ? any time to replay this tour.",
+		tab:   -1,
+	},
+}
+
+// activeTourSteps are the steps about what is shown, leaving out the tabs
+// that aren't.
+func activeTourSteps() []tourStep {
+	var steps []tourStep
+	for _, step := range tourSteps {
+		if step.tab < 0 || tabAvailable(step.tab) {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// Sample rows shown during the tour for tabs whose backend isn't configured
+var (
+	sampleGitData = []TableData{
+		{CommitSHA: "3f2c1a9e8d7b6c5a4f3e2d1c0b9a8f7e6d5c4b3a", PRDescription: "Add health endpoint", Author: "Jane Doe", PushedAt: "2024-05-02 14:21:07"},
+		{CommitSHA: "9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a0b", PRDescription: "Bump base image to alpine 3.20", Author: "Sam Lee", PushedAt: "2024-05-01 09:03:44"},
+	}
+	sampleDockerData = []TableData{
+		{ImageID: "registry-web-v1.2.0", ImageTag: "localhost:5000/web:v1.2.0", ImageSize: "48.3MB", CreatedAt: "2024-05-02 14:30:12", InUse: "web"},
+		{ImageID: "registry-api-latest", ImageTag: "localhost:5000/api:latest", ImageSize: "112.7MB", CreatedAt: "2024-05-01 09:10:55", InUse: "No"},
+	}
+	sampleKubesData = []TableData{
+		{PodName: "web-6d4cf56db6-x7k2p", Namespace: "default", Status: "Running", Restarts: "0", Age: "2h14m0s", NodeName: "minikube"},
+		{PodName: "api-7c9b8d6f5-q2w4e", Namespace: "default", Status: "Running", Restarts: "1", Age: "1d3h0m0s", NodeName: "minikube"},
+	}
+)
+
+func tourMarkerPath() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "local-container-registry", "tour-complete")
+}
+
+// shouldShowTour is true until the tour has been finished or skipped once.
+func shouldShowTour() bool {
+	path := tourMarkerPath()
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return os.IsNotExist(err)
+}
+
+func markTourComplete() {
+	path := tourMarkerPath()
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+		os.WriteFile(path, nil, 0644)
+	}
+}
+
+func (m *model) startTour() {
+	m.showTour = true
+	m.tourStep = 0
+	m.applyTourStep()
+}
+
+func (m *model) applyTourStep() {
+	if tab := activeTourSteps()[m.tourStep].tab; tab >= 0 {
+		m.switchTab(tab)
+	} else {
+		m.updateTableForTab()
+	}
+}
+
+func (m *model) endTour() {
+	m.showTour = false
+	markTourComplete()
+	// Swap the sample rows back out for the real data
+	m.updateTableForTab()
+}
+
+// updateTour handles keys while the tour is open; every other key is
+// swallowed so the tour can't leave the UI in a half-navigated state.
+func (m model) updateTour(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "right", "l", "n", "enter", " ":
+		if m.tourStep == len(activeTourSteps())-1 {
+			m.endTour()
+			return m, nil
+		}
+		m.tourStep++
+		m.applyTourStep()
+	case "left", "h", "p":
+		if m.tourStep > 0 {
+			m.tourStep--
+			m.applyTourStep()
+		}
+	case "esc", "s", "q":
+		m.endTour()
+	}
+	return m, nil
+}
+
+func (m model) renderTour() string {
+	step := activeTourSteps()[m.tourStep]
+	var content strings.Builder
+	content.WriteString(titleStyle.UnsetMarginBottom().Render(step.title))
+	content.WriteString("\n\n")
+	content.WriteString(step.body)
+	content.WriteString(fmt.Sprintf("\n\nStep %d of %d · →/Enter next · ← back · ESC skip", m.tourStep+1, len(activeTourSteps())))
+	return tourStyle.Render(content.String())
+}
+
+// tourData substitutes sample rows for a tab whose backend returned nothing
+// useful, but only while the tour is running.
+func (m model) tourData(data []TableData, sample []TableData, isPlaceholder func(TableData) bool) []TableData {
+	if !m.showTour {
+		return data
+	}
+	for _, item := range data {
+		if !isPlaceholder(item) {
+			return data
+		}
+	}
+	return sample
+}
+
+func isGitPlaceholder(item TableData) bool {
+	return item.CommitSHA == ""
+}
+
+func isDockerPlaceholder(item TableData) bool {
+	return item.ImageTag == "" || item.ImageTag == "N/A"
+}
+
+func isKubesPlaceholder(item TableData) bool {
+	return item.Status == "" || item.Status == "N/A" || item.Status == "Error"
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/kube"
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
+
+// Deploys stamp the pod template with who deployed which build and when, so
+// tooling on the cluster side can trace a running pod back to its deploy.
+// Pods inherit the annotations from the template; the pod details view shows
+// them.
+
+const (
+	deployedByAnnotation  = "lcr.dev/deployed-by"
+	imageDigestAnnotation = "lcr.dev/image-digest"
+	commitSHAAnnotation   = "lcr.dev/commit-sha"
+	deployedAtAnnotation  = "lcr.dev/deployed-at"
+)
+
+// deployAnnotationKeys are the annotations in the order pod details list them.
+var deployAnnotationKeys = []string{deployedByAnnotation, deployedAtAnnotation, imageDigestAnnotation, commitSHAAnnotation}
+
+// deployAnnotations are the annotations for deploying image now. The digest
+// and commit come from the registry, and are left out when it can't tell
+// them: a deploy never fails for want of its annotations.
+func deployAnnotations(ctx context.Context, image string) map[string]string {
+	annotations := map[string]string{
+		deployedByAnnotation: actorOf(ctx),
+		deployedAtAnnotation: time.Now().UTC().Format(time.RFC3339),
+	}
+	host, repository, reference := splitRegistryReference(image)
+	client := newRegistryClient(ctx, host)
+	body, _, err := client.Manifest(ctx, repository, reference)
+	if err != nil {
+		return annotations
+	}
+	annotations[imageDigestAnnotation] = registry.ComputeDigest(body)
+	labels, manifestAnnotations := imageMetadata(ctx, client, repository, body)
+	commit := cmp.Or(labels[revisionLabel], manifestAnnotations[revisionLabel])
+	if tagged, ok := tagCommit(reference); commit == "" && ok {
+		commit = tagged
+	}
+	if commit != "" {
+		annotations[commitSHAAnnotation] = commit
+	}
+	return annotations
+}
+
+// stampPodTemplate sets annotations on a pod template, replacing the stamp
+// of an earlier deploy.
+func stampPodTemplate(template *corev1.PodTemplateSpec, annotations map[string]string) {
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string)
+	}
+	for _, key := range deployAnnotationKeys {
+		delete(template.Annotations, key)
+	}
+	for key, value := range annotations {
+		template.Annotations[key] = value
+	}
+}
+
+// stampDeployment stamps updated, the deployment current becomes, unless the
+// deploy leaves it running the same image: a fresh timestamp would otherwise
+// restart pods that run what they should. A tag pushed again since the last
+// stamped deploy has a new digest, and is stamped.
+func stampDeployment(current, updated *appsv1.Deployment, container string, annotations map[string]string) {
+	before, after := current.Spec.Template, updated.Spec.Template
+	digest, newDigest := before.Annotations[imageDigestAnnotation], annotations[imageDigestAnnotation]
+	if i, err := kube.ContainerIndex(current, container); err == nil &&
+		before.Spec.Containers[i].Image == after.Spec.Containers[i].Image &&
+		(digest == "" || newDigest == "" || digest == newDigest) {
+		return
+	}
+	stampPodTemplate(&updated.Spec.Template, annotations)
+}
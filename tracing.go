@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracingServiceName = "local-container-registry"
+
+// tracer is backed by a no-op provider until setupTracing installs a real one
+var tracer = otel.Tracer("github.com/anthony-gilbert/local-container-registry")
+
+var shutdownTracing = func() {}
+
+// setupTracing records spans for the slow ops report, and exports them over
+// OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT (or the traces-only variant)
+// points at a collector, e.g. http://localhost:4318. The exporter reads the
+// remaining OTEL_* variables itself.
+func setupTracing(ctx context.Context) {
+	options := []sdktrace.TracerProviderOption{sdktrace.WithSpanProcessor(slowOps)}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		if exporter, err := otlptracehttp.New(ctx); err != nil {
+			log.Printf("trace export disabled: %v", err)
+		} else {
+			options = append(options, sdktrace.WithBatcher(exporter))
+		}
+	}
+	name := envOrDefault("OTEL_SERVICE_NAME", tracingServiceName)
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(name)))
+	if err != nil {
+		res = resource.Default()
+	}
+
+	provider := sdktrace.NewTracerProvider(append(options, sdktrace.WithResource(res))...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	shutdownTracing = func() {
+		// Flush whatever is still batched, but never hang the exit on a dead collector
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Printf("failed to flush traces: %v", err)
+		}
+	}
+}
+
+// startSpan starts a child of whatever span ctx carries.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// startBackendSpan starts a span for a call to a backend other than over
+// HTTP, e.g. a docker command, which the slow ops report lists.
+func startBackendSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endSpan ends the span, marking it failed when err is set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// withSpanOf returns ctx carrying the span of other. History is written with
+// the TUI's context so it survives a cancelled operation, but it should still
+// show up inside that operation's trace.
+func withSpanOf(ctx, other context.Context) context.Context {
+	return trace.ContextWithSpan(ctx, trace.SpanFromContext(other))
+}
+
+// traceDockerCommand is the docker client's Trace: each docker CLI command
+// is a span, named after its subcommand.
+func traceDockerCommand(ctx context.Context, args []string) (context.Context, func(error)) {
+	name := "docker"
+	if len(args) > 0 {
+		name += " " + args[0]
+	}
+	ctx, span := startBackendSpan(ctx, name, attribute.StringSlice("docker.args", args))
+	return ctx, func(err error) { endSpan(span, err) }
+}
+
+// tracedHTTPClient is used for registry calls so each request is a span.
+var tracedHTTPClient = &http.Client{Transport: tracingTransport{base: proxiedTransport(backendRegistry)}}
+
+// tracingTransport wraps HTTP calls to the registry, the Kubernetes and the
+// GitHub API in client spans and propagates the trace context to the server.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.ServerAddress(req.URL.Hostname()),
+			semconv.URLPath(req.URL.Path),
+		))
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		endSpan(span, err)
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	span.End()
+	return resp, nil
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/gitprovider"
 )
 
 var (
@@ -53,30 +59,140 @@ var (
 )
 
 type model struct {
-	table              table.Model
-	quitting           bool
-	activeTab          int
-	tabs               []string
-	gitData            []TableData
-	dockerData         []TableData
-	kubesData          []TableData
-	width              int
-	height             int
-	showModal          bool
-	selectedImage      string
-	showPodDef         bool
-	selectedPod        string
-	selectedPodNS      string
-	podDefTable        table.Model
-	deployments        []TableData
-	selectedDeployment int
-	deploymentPods     []TableData
-	selectedPod2       int
-	modalStep          int // 0 = deployment selection, 1 = pod selection, 2 = confirmation
+	table                table.Model
+	quitting             bool
+	activeTab            int
+	tabs                 []string
+	gitData              []TableData // nil while loading, like dockerData and kubesData
+	gitErr               error
+	gitQuery             gitCommitQuery // page is the last one loaded
+	gitMore              bool           // older commits are left to load
+	gitLoadingMore       bool
+	filteringCommits     bool // typing the Git tab's date range
+	commitFilterInput    textinput.Model
+	commitFilter         string                // the date range applied, as typed
+	commitService        gitService            // whose commits the Git tab lists, zero for all
+	showReleases         bool                  // the Git tab lists releases and tags instead of commits
+	releases             []gitprovider.Release // nil while loading
+	releasesErr          error
+	showRelease          bool
+	selectedRelease      gitprovider.Release
+	releaseCursor        int // of the images tagged with the release's version
+	dockerData           []TableData
+	dockerErr            error
+	kubesData            []TableData
+	stale                [3]*staleData // of the Git, Docker and Kubernetes tabs while they show last-known-good rows
+	width                int
+	height               int
+	showModal            bool
+	selectedImage        string
+	showPodDef           bool
+	selectedPod          string
+	selectedPodNS        string
+	podDefTable          table.Model
+	podContainers        []string // of the selected pod, for logs and exec
+	containerAction      string   // "logs" or "exec" while the container picker is open
+	containerCursor      int
+	showPodLogs          bool
+	podLogs              *podLogsMsg // nil while loading
+	podLogsOffset        int         // lines scrolled up from the end
+	showCrashDiagnosis   bool
+	diagnosis            *crashDiagnosisMsg // nil while loading
+	deployments          []TableData
+	selectedDeployment   int
+	deploymentPods       []TableData
+	selectedPod2         int
+	modalStep            int               // 0 = deployment selection, 1 = create form, 2 = confirmation, 3 = canary, 4 = container selection
+	deployContainer      string            // container of the selected deployment a deploy sets the image of, empty for the first
+	deployContainerIndex int               // cursor of the container selection
+	deployInputs         []textinput.Model // create form fields, see deployField*
+	deployFocus          int
+	deployFormErr        string
+	missingNamespace     *deploymentParams  // the form's deployment, waiting for its namespace to be confirmed
+	deployExposedPorts   []string           // ports the selected image's config exposes, e.g. 8080/tcp
+	backends             backends           // what ctx carries; the configured servers where nil
+	ctx                  context.Context    // cancelled when the program exits
+	tabCtx               context.Context    // cancelled whenever the active tab changes
+	cancelTab            context.CancelFunc // cancels tabCtx
+	danglingOnly         bool               // Docker tab shows only images the cluster doesn't use
+	hidePrereleases      bool               // Docker tab leaves out pre-release versions, e.g. 2.0.0-rc.1
+	showReconcile        bool
+	reconcileTarget      TableData
+	showTour             bool
+	tourStep             int
+	cacheData            []TableData
+	cacheStats           *proxyStats
+	cacheErr             error
+	argocdData           []TableData
+	argocdErr            error
+	nodesData            []TableData
+	nodesPending         []pendingPod
+	nodesMetrics         bool
+	nodesErr             error
+	configEntries        []configEntry
+	configErr            error
+	showConfigViewer     bool
+	configViewerTarget   configEntry
+	revealSecret         bool            // Secret values are masked in the config viewer until revealed
+	deployPreview        *deployPreview  // changes shown on the confirmation step, nil while loading
+	deployEnv            *containerEnv   // env the confirmed deploy sets on the container, nil to keep it
+	deployEnvInput       textinput.Model // env editor of the confirmation step
+	editingDeployEnv     bool
+	deployEnvErr         string
+	deployProfile        *deployProfile // how the selected image was last deployed, nil if never
+	canaryReplicas       int32          // size of the canary offered on the confirmation step
+	permissions          map[permission]accessDecision
+	permissionsNamespace string
+	role                 string // the local user's role, "" until it is read
+	canaryDeployment     string
+	canaryNamespace      string
+	canaryState          canaryState
+	canaryStarted        time.Time
+	workloadsData        []TableData
+	workloadsErr         error
+	showDeploymentDef    bool
+	detailDeployment     string
+	deploymentDefTable   table.Model
+	clusters             []cluster
+	clusterName          string // active cluster, see currentCluster
+	showClusterPicker    bool
+	clusterCursor        int
+	allClusters          bool // Kubernetes tab lists the pods of every cluster
+	registries           []registryEndpoint
+	showRegistryPicker   bool
+	registryCursor       int
+	showTagComparison    bool
+	comparisonRepository string
+	tagComparison        *tagComparison // nil while loading
+	compareBase          string         // image marked with M, compared with the next one marked
+	showImageComparison  bool
+	imageComparison      *imageComparisonMsg // lines nil while loading
+	showSizeTrend        bool
+	sizeTrend            *sizeTrendMsg // points nil while loading
+	showImageDef         bool
+	detailImage          string
+	imageDefTable        table.Model
+	searching            bool // typing in the Docker tab's search box
+	searchInput          textinput.Model
+	searchQuery          string // Docker tab filter, see matchesImageSearch
+	retagging            bool   // typing the new tag for retagSource
+	retagSource          string
+	retagInput           textinput.Model
+	operations           []operation // in-flight commands shown in the status bar
+	nextOperationID      int
+	startCmd             tea.Cmd         // loads the tab picked with --tab
+	statusMessage        string          // result of the last action, e.g. a new deployment's URL
+	registryHealth       *registryHealth // nil until first checked
+	pull                 *pullProgress   // the latest pull started with Ctrl+P
+	showPull             bool
+	showJobs             bool
+	jobs                 *jobsMsg // nil while loading
+	showSlowOps          bool
 }
 
 func (m model) Init() tea.Cmd {
-	return nil
+	// Learn up front which actions RBAC allows, to grey out the others
+	return tea.Batch(append(m.startupLoads(), m.loadPermissions(), m.startCmd, retryTick(), gitPollTick(), m.loadRegistryHealth())...)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -84,34 +200,256 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case deploymentsMsg:
 		m.deployments = msg.deployments
+		m.selectProfileDeployment()
+		return m, nil
+	case deployProfileMsg:
+		return m.updateDeployProfile(msg)
+	case cacheDataMsg:
+		if errors.Is(msg.err, context.Canceled) {
+			return m, nil
+		}
+		m.cacheData, m.cacheStats, m.cacheErr = msg.data, msg.stats, msg.err
+		if m.activeTab == 3 {
+			m.updateTableForTab()
+		}
+		return m, nil
+	case argocdDataMsg:
+		if errors.Is(msg.err, context.Canceled) {
+			return m, nil
+		}
+		m.argocdData, m.argocdErr = msg.data, msg.err
+		if m.activeTab == 4 {
+			m.updateTableForTab()
+		}
+		return m, nil
+	case nodesDataMsg:
+		if errors.Is(msg.err, context.Canceled) {
+			return m, nil
+		}
+		m.nodesData, m.nodesPending, m.nodesMetrics, m.nodesErr = msg.data, msg.pending, msg.metrics, msg.err
+		if m.activeTab == 5 {
+			m.updateTableForTab()
+		}
+		return m, nil
+	case configDataMsg:
+		if errors.Is(msg.err, context.Canceled) {
+			return m, nil
+		}
+		m.configEntries, m.configErr = msg.entries, msg.err
+		if m.activeTab == 6 {
+			m.updateTableForTab()
+		}
+		return m, nil
+	case workloadsDataMsg:
+		if errors.Is(msg.err, context.Canceled) {
+			return m, nil
+		}
+		m.workloadsData, m.workloadsErr = msg.data, msg.err
+		if m.activeTab == 7 {
+			m.updateTableForTab()
+		}
+		return m, nil
+	case undoMsg:
+		if msg.err != nil {
+			m.statusMessage = fmt.Sprintf("⚠️  Undo failed: %v", msg.err)
+			return m, nil
+		}
+		m.statusMessage = "↩️  " + msg.description
+		cmd := m.loadTabData()
+		return m, cmd
+	case deploymentDeletedMsg:
+		if msg.err != nil {
+			log.Printf("Deleting %s failed: %v", msg.deployment, msg.err)
+			return m, nil
+		}
+		m.statusMessage = fmt.Sprintf("🗑️  Deleted %s, press u to undo", msg.deployment)
+		cmd := m.loadTabData()
+		return m, cmd
+	case restartMsg:
+		if msg.err != nil {
+			log.Printf("Restart of %s failed: %v", msg.deployment, msg.err)
+			return m, nil
+		}
+		m.statusMessage = fmt.Sprintf("🔄 Restarted %s, new pods are rolling out", msg.deployment)
+		if m.activeTab == 7 {
+			cmd := m.loadTabData()
+			return m, cmd
+		}
+		return m, nil
+	case tagComparisonMsg:
+		m.tagComparison = &msg.comparison
+		return m, nil
+	case namespaceCheckMsg:
+		return m.updateNamespaceCheck(msg)
+	case exposedPortsMsg:
+		return m.updateExposedPorts(msg)
+	case jobsMsg:
+		m.jobs = &msg
+		return m, nil
+	case gitReleasesMsg:
+		m.updateReleases(msg)
+		return m, nil
+	case sizeTrendMsg:
+		if m.sizeTrend != nil && m.sizeTrend.registry == msg.registry && m.sizeTrend.repository == msg.repository {
+			m.sizeTrend = &msg
+		}
+		return m, nil
+	case imageComparisonMsg:
+		if m.imageComparison != nil && m.imageComparison.base == msg.base && m.imageComparison.target == msg.target {
+			m.imageComparison = &msg
+		}
+		return m, nil
+	case gitDataMsg:
+		m.updateGitData(msg)
+		return m, nil
+	case webhookMsg:
+		cmd := m.applyWebhookEvent(webhookEvent(msg))
+		return m, cmd
+	case webhookBuildMsg:
+		if msg.err != nil {
+			m.statusMessage = fmt.Sprintf("❌ Build of %s failed: %v", msg.image, msg.err)
+			return m, nil
+		}
+		m.statusMessage = fmt.Sprintf("✅ Built and pushed %s", msg.image)
+		cmd := m.refreshDockerData()
+		return m, cmd
+	case databaseMsg:
+		if msg.err != nil {
+			m.statusMessage = fmt.Sprintf("⚠️  Database unavailable, history will not be recorded and you act as a viewer: %v", msg.err)
+			return m, nil
+		}
+		return m, m.loadRole()
+	case roleMsg:
+		m.role = msg.role
+		if msg.err != nil {
+			m.statusMessage = fmt.Sprintf("⚠️  %v; acting as a %s", msg.err, msg.role)
+		}
+		return m, nil
+	case kubePodsMsg:
+		if msg.allClusters != m.allClusters {
+			return m, nil
+		}
+		m.kubesData, m.stale[2] = msg.data, msg.stale
+		if m.activeTab == 2 {
+			m.updateTableForTab()
+		}
+		return m, nil
+	case permissionsMsg:
+		m.permissions, m.permissionsNamespace = msg.decisions, msg.namespace
+		return m, nil
+	case deployPreviewMsg:
+		if m.showModal && m.modalStep == 2 && m.selectedDeployment >= 0 && m.selectedDeployment < len(m.deployments) &&
+			m.deployments[m.selectedDeployment].PodName == msg.deployment {
+			m.deployPreview = &msg.preview
+		}
+		return m, nil
+	case canaryStartedMsg:
+		if msg.err != nil {
+			m.canaryState.err = msg.err
+			return m, nil
+		}
+		return m, m.loadCanaryState()
+	case canaryStatusMsg:
+		if !m.showModal || m.modalStep != 3 {
+			return m, nil
+		}
+		m.canaryState = msg.state
+		return m, canaryTick()
+	case canaryTickMsg:
+		if m.showModal && m.modalStep == 3 {
+			return m, m.loadCanaryState()
+		}
+		return m, nil
+	case canaryDoneMsg:
+		if msg.err != nil {
+			log.Printf("Removing canary failed: %v", msg.err)
+			return m, nil
+		}
+		if !msg.promoted {
+			m.statusMessage = "↩️  Canary rolled back"
+		}
+		return m, nil
+	case deploymentDetailsMsg:
+		if errors.Is(msg.err, context.Canceled) {
+			return m, nil
+		}
+		rows := msg.rows
+		if msg.err != nil {
+			rows = []table.Row{{"Error", truncateString(msg.err.Error(), 70)}}
+		}
+		m.deploymentDefTable.SetRows(rows)
 		return m, nil
 	case deploymentPodsMsg:
 		m.deploymentPods = msg.pods
 		return m, nil
 	case podDetailsMsg:
-		if msg.err == nil {
-			m.initPodDefTable(msg.details)
-		} else {
-			m.initPodDefTable(nil)
+		if errors.Is(msg.err, context.Canceled) {
+			// The user left the tab before the details arrived
+			return m, nil
+		}
+		m.initPodDefTable(msg.details, msg.err)
+		return m, nil
+	case podLogsMsg:
+		if errors.Is(msg.err, context.Canceled) || !m.showPodLogs {
+			return m, nil
+		}
+		m.podLogs = &msg
+		return m, nil
+	case crashDiagnosisMsg:
+		if errors.Is(msg.err, context.Canceled) || !m.showCrashDiagnosis || msg.pod != m.selectedPod {
+			return m, nil
+		}
+		m.diagnosis = &msg
+		return m, nil
+	case podExecMsg:
+		if msg.err != nil {
+			m.statusMessage = "❌ " + msg.err.Error()
 		}
 		return m, nil
 	case dockerDeleteMsg:
 		if msg.success {
 			// Refresh Docker data after successful deletion
-			return m, m.refreshDockerData()
+			cmd := m.refreshDockerData()
+			return m, cmd
 		}
 		// Handle deletion error (could show a message to user)
 		return m, nil
 	case dockerPullMsg:
 		if msg.success {
 			// Refresh Docker data after successful pull
-			return m, m.refreshDockerData()
+			cmd := m.refreshDockerData()
+			return m, cmd
+		}
+		if !m.showPull && !errors.Is(msg.err, context.Canceled) {
+			m.statusMessage = fmt.Sprintf("❌ Pull of %s failed: %v", msg.imageTag, msg.err)
+		}
+		return m, nil
+	case pullTickMsg:
+		if m.showPull && m.pullRunning() {
+			return m, pullTick()
 		}
-		// Handle pull error (could show a message to user)
 		return m, nil
+	case retagMsg:
+		if msg.err != nil {
+			m.statusMessage = fmt.Sprintf("⚠️  Retag failed: %v", msg.err)
+			return m, nil
+		}
+		m.statusMessage = fmt.Sprintf("🏷️  Tagged %s as %s", msg.image, msg.newTag)
+		cmd := m.refreshDockerData()
+		return m, cmd
+	case reconcileMsg:
+		if msg.err != nil {
+			log.Printf("Reconcile (%s) of %s failed: %v", msg.direction, msg.image, msg.err)
+			return m, nil
+		}
+		cmd := m.refreshDockerData()
+		return m, cmd
 	case deploymentMsg:
 		// Handle deployment result and reset table selection
 		if msg.success {
+			if msg.status != "" {
+				m.statusMessage = msg.status
+			}
 			// Reset table cursor to first row after successful deployment
 			m.table.SetCursor(0)
 			// Refresh deployments list to show the new deployment
@@ -125,11 +463,28 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 	case dockerRefreshMsg:
 		// Update Docker data and refresh table
-		m.dockerData = msg.data
+		m.dockerData, m.dockerErr, m.stale[1] = msg.data, msg.err, msg.stale
 		if m.activeTab == 1 {
 			m.updateTableForTab()
 		}
 		return m, nil
+	case operationDoneMsg:
+		m.finishOperation(msg.id)
+		return m.Update(msg.msg)
+	case gitPollMsg:
+		return m, m.pollGitCommits()
+	case registryHealthMsg:
+		m.registryHealth = &msg.health
+		return m, registryHealthTick()
+	case registryHealthTickMsg:
+		return m, m.loadRegistryHealth()
+	case retryTickMsg:
+		return m, retryTick()
+	case operationTickMsg:
+		if len(m.operations) > 0 {
+			return m, operationTick()
+		}
+		return m, nil
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
@@ -140,9 +495,94 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.podDefTable.SetWidth(msg.Width)
 			m.podDefTable.SetHeight(msg.Height - 15)
 		}
+		if m.deploymentDefTable.Columns() != nil {
+			m.deploymentDefTable.SetWidth(msg.Width)
+			m.deploymentDefTable.SetHeight(msg.Height - 15)
+		}
+		if m.imageDefTable.Columns() != nil {
+			m.imageDefTable.SetWidth(msg.Width)
+			m.imageDefTable.SetHeight(msg.Height - 15)
+		}
 		return m, nil
 	case tea.KeyMsg:
+		if m.showTour {
+			return m.updateTour(msg)
+		}
+		if m.showReconcile {
+			return m.updateReconcile(msg)
+		}
+		if m.showDeploymentDef {
+			return m.updateDeploymentDef(msg)
+		}
+		if m.showImageDef {
+			return m.updateImageDef(msg)
+		}
+		if m.showPull {
+			return m.updatePullProgress(msg)
+		}
+		if m.showPodLogs {
+			return m.updatePodLogs(msg)
+		}
+		if m.showCrashDiagnosis {
+			return m.updateCrashDiagnosis(msg)
+		}
+		if m.containerAction != "" {
+			return m.updateContainerPicker(msg)
+		}
+		if m.searching {
+			return m.updateImageSearch(msg)
+		}
+		if m.filteringCommits {
+			return m.updateCommitFilter(msg)
+		}
+		if m.retagging {
+			return m.updateRetag(msg)
+		}
+		if m.showConfigViewer {
+			return m.updateConfigViewer(msg)
+		}
+		if m.showClusterPicker {
+			return m.updateClusterPicker(msg)
+		}
+		if m.showRegistryPicker {
+			return m.updateRegistryPicker(msg)
+		}
+		if m.showTagComparison {
+			return m.updateTagComparison(msg)
+		}
+		if m.showImageComparison {
+			return m.updateImageComparison(msg)
+		}
+		if m.showSizeTrend {
+			return m.updateSizeTrend(msg)
+		}
+		if m.showJobs {
+			return m.updateJobs(msg)
+		}
+		if m.showRelease {
+			return m.updateRelease(msg)
+		}
+		if m.showSlowOps {
+			return m.updateSlowOps(msg)
+		}
+		if m.showModal && m.modalStep == 3 {
+			return m.updateCanary(msg)
+		}
+		if m.showModal && m.modalStep == 1 {
+			return m.updateDeployForm(msg)
+		}
+		if m.showModal && m.modalStep == 4 {
+			return m.updateDeployContainerPicker(msg)
+		}
+		if m.showModal && m.modalStep == 2 && m.editingDeployEnv {
+			return m.updateDeployEnv(msg)
+		}
 		switch keypress := msg.String(); keypress {
+		case "?":
+			if !m.showModal && !m.showPodDef {
+				m.startTour()
+			}
+			return m, nil
 		case "ctrl+c", "q":
 			// Handle quitting the application
 			m.quitting = true
@@ -151,42 +591,55 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.showModal {
 				if m.modalStep == 0 {
 					if m.selectedDeployment == -1 {
+						if m.denied(permCreateDeployments) != "" {
+							return m, nil
+						}
 						// Create new deployment - move to creation step
 						m.modalStep = 1
-						return m, nil
+						m.initDeployForm()
+						return m, m.loadExposedPorts()
 					} else {
-						// Update existing deployment - move to confirmation step
-						m.modalStep = 2
-						return m, nil
+						// Update existing deployment - ask which container when
+						// it has several, then move to confirmation step
+						m.deployContainer = ""
+						if m.selectedDeployment < len(m.deployments) && len(m.deployments[m.selectedDeployment].Containers) > 1 {
+							m.modalStep = 4
+							m.deployContainerIndex = defaultDeployContainer(m.deployments[m.selectedDeployment], m.selectedImage)
+							// The container the image went to last time
+							if deployment := m.deployments[m.selectedDeployment]; m.isProfileDeployment(deployment) {
+								if i := slices.Index(deployment.Containers, m.deployProfile.Container); i >= 0 {
+									m.deployContainerIndex = i
+								}
+							}
+							return m, nil
+						}
+						return m, m.confirmDeploy()
 					}
-				} else if m.modalStep == 1 {
-					// Create new deployment
-					m.showModal = false
-					m.modalStep = 0
-					return m, m.createNewDeployment(m.selectedImage)
 				} else {
+					if m.denied(m.deployPermission()) != "" {
+						return m, nil
+					}
 					// Deploy to selected deployment
 					m.showModal = false
 					m.modalStep = 0
 					if len(m.deployments) > 0 && m.selectedDeployment < len(m.deployments) {
 						selectedDeployment := m.deployments[m.selectedDeployment]
-						return m, m.deployImageToPod(m.selectedImage, selectedDeployment.PodName, selectedDeployment.Namespace)
+						// Recorded here rather than in deployImageToPod, which
+						// promoting a canary deploys with too
+						recordSessionStep(sessionStep{Action: sessionDeploy, Image: m.selectedImage, Deployment: selectedDeployment.PodName, Namespace: selectedDeployment.Namespace, Container: m.deployContainer, Env: m.deployEnv})
+						cmd := m.deployImageToPod(m.selectedImage, selectedDeployment.PodName, selectedDeployment.Namespace, m.deployContainer, m.deployEnv)
+						return m, cmd
 					}
 					return m, nil
 				}
 			} else {
 				// Switch to Git tab
-				m.activeTab = 0
-				m.updateTableForTab()
+				m.switchTab(0)
 				return m, nil
 			}
 		case "2":
 			if m.showModal {
-				if m.modalStep == 1 {
-					// Go back to deployment selection from creation step
-					m.modalStep = 0
-					return m, nil
-				} else if m.modalStep == 2 {
+				if m.modalStep == 2 {
 					// Go back to deployment selection from confirmation step
 					m.modalStep = 0
 					return m, nil
@@ -198,48 +651,130 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			} else {
 				// Switch to Docker tab
-				m.activeTab = 1
-				m.updateTableForTab()
+				m.switchTab(1)
 				return m, nil
 			}
 		case "3":
 			if m.showModal {
-				// No action for 3 in modal
+				// Canary first from the confirmation step
+				if m.modalStep == 2 && m.denied(permCreateDeployments) == "" && m.selectedDeployment >= 0 && m.selectedDeployment < len(m.deployments) {
+					selectedDeployment := m.deployments[m.selectedDeployment]
+					cmd := m.startCanary(selectedDeployment.PodName, selectedDeployment.Namespace)
+					return m, cmd
+				}
 				return m, nil
 			} else {
 				// Switch to Kubernetes tab
-				m.activeTab = 2
-				m.updateTableForTab()
+				m.switchTab(2)
 				return m, nil
 			}
-		case "tab":
-			m.activeTab = (m.activeTab + 1) % len(m.tabs)
-			m.updateTableForTab()
+		case "4":
+			if !m.showModal {
+				// Switch to Cache tab
+				m.switchTab(3)
+				cmd := m.loadTabData()
+				return m, cmd
+			}
+			return m, nil
+		case "5":
+			if !m.showModal {
+				// Switch to Apps tab
+				m.switchTab(4)
+				cmd := m.loadTabData()
+				return m, cmd
+			}
+			return m, nil
+		case "6":
+			if !m.showModal {
+				// Switch to Nodes tab
+				m.switchTab(5)
+				cmd := m.loadTabData()
+				return m, cmd
+			}
 			return m, nil
+		case "7":
+			if !m.showModal {
+				// Switch to Config tab
+				m.switchTab(6)
+				cmd := m.loadTabData()
+				return m, cmd
+			}
+			return m, nil
+		case "8":
+			if !m.showModal {
+				// Switch to Workloads tab
+				m.switchTab(7)
+				cmd := m.loadTabData()
+				return m, cmd
+			}
+			return m, nil
+		case "d":
+			// Describe the deployment highlighted in the deployment picker
+			if m.showModal && m.modalStep == 0 && m.selectedDeployment >= 0 && m.selectedDeployment < len(m.deployments) {
+				deployment := m.deployments[m.selectedDeployment]
+				cmd := m.showDeploymentDetails(deployment.PodName, deployment.Namespace)
+				return m, cmd
+			}
+			// Filter the Git tab by date
+			if m.activeTab == 0 && !m.showModal && !m.showReleases {
+				cmd := m.startCommitFilter()
+				return m, cmd
+			}
+		case "tab":
+			next := (m.activeTab + 1) % len(m.tabs)
+			for !tabAvailable(next) {
+				next = (next + 1) % len(m.tabs)
+			}
+			m.switchTab(next)
+			cmd := m.loadTabData()
+			return m, cmd
 		case "enter":
 			// Show modal on Docker tab or pod definition on Kubernetes tab
-			if m.activeTab == 1 && len(m.dockerData) > 0 {
+			if dockerData := m.visibleDockerData(); m.activeTab == 1 && len(dockerData) > 0 {
 				selectedRow := m.table.Cursor()
-				if selectedRow < len(m.dockerData) {
-					imageData := m.dockerData[selectedRow]
-					m.selectedImage = imageData.ImageTag // Use full image name from registry
-					if m.selectedImage == "" {
-						m.selectedImage = imageData.ImageID
+				if selectedRow < len(dockerData) && dockerData[selectedRow].ArtifactType != "" {
+					m.statusMessage = artifactHint(dockerData[selectedRow])
+					return m, nil
+				}
+				if !kubernetesEnabled() {
+					m.statusMessage = fmt.Sprintf("⚠️  Can't deploy: %v", errKubernetesDisabled)
+					return m, nil
+				}
+				if selectedRow < len(dockerData) {
+					imageData := dockerData[selectedRow]
+					image := imageData.ImageTag // Use full image name from registry
+					if image == "" {
+						image = imageData.ImageID
 					}
-					m.modalStep = 0
-					m.selectedDeployment = -1 // Start with "Create New Deployment" selected
-					m.selectedPod2 = 0
-					m.showModal = true
-					return m, m.loadDeployments()
+					cmd := m.openDeployDialog(image)
+					return m, cmd
 				}
 			} else if m.activeTab == 2 && len(m.kubesData) > 0 {
 				selectedRow := m.table.Cursor()
+				if selectedRow < len(m.kubesData) && m.kubesData[selectedRow].Cluster != "" && m.kubesData[selectedRow].Cluster != m.clusterName {
+					m.statusMessage = fmt.Sprintf("Switch to cluster %s with K to see the pod's details", m.kubesData[selectedRow].Cluster)
+					return m, nil
+				}
 				if selectedRow < len(m.kubesData) {
 					m.selectedPod = m.kubesData[selectedRow].PodName
 					m.selectedPodNS = m.kubesData[selectedRow].Namespace
+					m.podContainers = nil
 					m.showPodDef = true
 					return m, m.loadPodDetails()
 				}
+			} else if m.activeTab == 0 && m.showReleases {
+				m.openRelease()
+			} else if m.activeTab == 6 && len(m.configEntries) > 0 {
+				if selectedRow := m.table.Cursor(); selectedRow < len(m.configEntries) {
+					m.configViewerTarget = m.configEntries[selectedRow]
+					m.showConfigViewer = true
+				}
+			} else if m.activeTab == 7 && len(m.workloadsData) > 0 {
+				if selectedRow := m.table.Cursor(); selectedRow < len(m.workloadsData) {
+					deployment := m.workloadsData[selectedRow]
+					cmd := m.showDeploymentDetails(deployment.PodName, deployment.Namespace)
+					return m, cmd
+				}
 			}
 			return m, nil
 		case "esc":
@@ -251,6 +786,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			} else if m.showPodDef {
 				m.showPodDef = false
 				return m, nil
+			} else if m.activeTab == 1 && m.searchQuery != "" {
+				m.searchQuery = ""
+				m.table.SetCursor(0)
+				m.updateTableForTab()
+				return m, nil
 			} else {
 				// No modal open, quit the application
 				m.quitting = true
@@ -272,23 +812,267 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				return m, nil
 			}
+		case "p":
+			// List the commits of the next monorepo service on the Git tab
+			if m.activeTab == 0 && !m.showModal && !m.showReleases {
+				cmd := m.nextGitService()
+				return m, cmd
+			}
+		case "f":
+			// Toggle showing only images no workload references
+			if m.activeTab == 1 && !m.showModal {
+				m.danglingOnly = !m.danglingOnly
+				m.table.SetCursor(0)
+				m.updateTableForTab()
+				return m, nil
+			}
+		case "v":
+			// Toggle hiding pre-release versions on the Docker tab
+			if m.activeTab == 1 && !m.showModal {
+				m.hidePrereleases = !m.hidePrereleases
+				m.table.SetCursor(0)
+				m.updateTableForTab()
+				return m, nil
+			}
+		case "c":
+			// Reconcile a registry image whose local copy has diverged
+			if dockerData := m.visibleDockerData(); m.activeTab == 1 && !m.showModal {
+				selectedRow := m.table.Cursor()
+				if selectedRow < len(dockerData) && dockerData[selectedRow].LocalState == localStateDiffers {
+					m.reconcileTarget = dockerData[selectedRow]
+					m.showReconcile = true
+				}
+				return m, nil
+			}
+		case "r":
+			// Rollout restart the deployment highlighted on the Workloads tab
+			// or in the deployment picker
+			if reason := m.denied(permPatchDeployments); reason != "" && (m.activeTab == 7 || m.showModal && m.modalStep == 0) {
+				m.statusMessage = reason
+				return m, nil
+			}
+			if m.activeTab == 7 && !m.showModal && len(m.workloadsData) > 0 {
+				if selectedRow := m.table.Cursor(); selectedRow < len(m.workloadsData) {
+					deployment := m.workloadsData[selectedRow]
+					cmd := m.restartDeployment(deployment.PodName, deployment.Namespace)
+					return m, cmd
+				}
+			}
+			if m.showModal && m.modalStep == 0 && m.selectedDeployment >= 0 && m.selectedDeployment < len(m.deployments) {
+				deployment := m.deployments[m.selectedDeployment]
+				m.showModal = false
+				cmd := m.restartDeployment(deployment.PodName, deployment.Namespace)
+				return m, cmd
+			}
+			// Switch the Git tab between commits and releases
+			if m.activeTab == 0 && !m.showModal {
+				cmd := m.toggleReleases()
+				return m, cmd
+			}
+		case "e":
+			// Edit the env the confirmed deploy sets
+			if m.showModal && m.modalStep == 2 {
+				return m.editDeployEnv()
+			}
+		case "+", "-":
+			// Resize the canary offered on the confirmation step
+			if m.showModal && m.modalStep == 2 {
+				if keypress == "+" {
+					m.canaryReplicas++
+				} else if m.canaryReplicas > 1 {
+					m.canaryReplicas--
+				}
+				return m, nil
+			}
+		case "s":
+			// Switch the registry the Docker tab lists and deploys from
+			if m.activeTab == 1 && !m.showModal {
+				m.registries = configuredRegistries()
+				m.registryCursor = 0
+				for i, registry := range m.registries {
+					if registry.host == getRegistryHost() {
+						m.registryCursor = i
+					}
+				}
+				m.showRegistryPicker = true
+				return m, nil
+			}
+		case "w":
+			// Compare the highlighted repository's tags across registries
+			if dockerData := m.visibleDockerData(); m.activeTab == 1 && !m.showModal {
+				selectedRow := m.table.Cursor()
+				if selectedRow < len(dockerData) && dockerData[selectedRow].ImageTag != "" && dockerData[selectedRow].ImageTag != "N/A" {
+					m.registries = configuredRegistries()
+					m.comparisonRepository = repositoryOf(dockerData[selectedRow].ImageTag)
+					m.tagComparison = nil
+					m.showTagComparison = true
+					return m, m.loadTagComparison(m.comparisonRepository)
+				}
+				return m, nil
+			}
+		case "/":
+			// Filter the Docker tab by name, label or annotation
+			if m.activeTab == 1 && !m.showModal {
+				cmd := m.startImageSearch()
+				return m, cmd
+			}
+		case "i":
+			// Show the highlighted image's labels and annotations
+			if dockerData := m.visibleDockerData(); m.activeTab == 1 && !m.showModal {
+				if selectedRow := m.table.Cursor(); selectedRow < len(dockerData) {
+					m.showImageDetails(dockerData[selectedRow])
+				}
+				return m, nil
+			}
+		case "t":
+			// Give the highlighted registry image another tag, server-side
+			if dockerData := m.visibleDockerData(); m.activeTab == 1 && !m.showModal {
+				if selectedRow := m.table.Cursor(); selectedRow < len(dockerData) {
+					cmd := m.startRetag(dockerData[selectedRow])
+					return m, cmd
+				}
+				return m, nil
+			}
+		case "m":
+			// Mark an image, then compare it with the next one marked
+			if dockerData := m.visibleDockerData(); m.activeTab == 1 && !m.showModal {
+				if selectedRow := m.table.Cursor(); selectedRow < len(dockerData) {
+					cmd := m.markForComparison(dockerData[selectedRow])
+					return m, cmd
+				}
+				return m, nil
+			}
+		case "z":
+			// Chart the sizes of the highlighted image's repository across builds
+			if dockerData := m.visibleDockerData(); m.activeTab == 1 && !m.showModal {
+				if selectedRow := m.table.Cursor(); selectedRow < len(dockerData) {
+					cmd := m.openSizeTrend(dockerData[selectedRow])
+					return m, cmd
+				}
+				return m, nil
+			}
+		case "L":
+			// Show the log of one of the pod's containers
+			if m.showPodDef {
+				return m.pickContainer("logs")
+			}
+		case "E":
+			// Open a shell in one of the pod's containers
+			if m.showPodDef {
+				return m.pickContainer("exec")
+			}
+		case "x":
+			// Diagnose why the highlighted or opened pod keeps crashing
+			if m.activeTab == 2 && !m.showModal && !m.showPodDef && len(m.kubesData) > 0 {
+				selectedRow := m.table.Cursor()
+				if selectedRow >= len(m.kubesData) {
+					return m, nil
+				}
+				if pod := m.kubesData[selectedRow]; pod.Cluster != "" && pod.Cluster != m.clusterName {
+					m.statusMessage = fmt.Sprintf("Switch to cluster %s with K to diagnose the pod", pod.Cluster)
+					return m, nil
+				}
+				m.selectedPod = m.kubesData[selectedRow].PodName
+				m.selectedPodNS = m.kubesData[selectedRow].Namespace
+			} else if !m.showPodDef {
+				return m, nil
+			}
+			m.showCrashDiagnosis = true
+			m.diagnosis = nil
+			return m, m.loadCrashDiagnosis()
+		case "K":
+			// Switch the cluster every Kubernetes view and action uses
+			if !m.showModal && !m.showPodDef {
+				if !kubernetesEnabled() {
+					m.statusMessage = fmt.Sprintf("⚠️  %v", errKubernetesDisabled)
+					return m, nil
+				}
+				m.clusters = configuredClusters()
+				m.clusterCursor = 0
+				for i, c := range m.clusters {
+					if c.name == m.clusterName {
+						m.clusterCursor = i
+					}
+				}
+				m.showClusterPicker = true
+				return m, nil
+			}
+		case "A":
+			// Toggle the pods of all clusters side by side on the Kubernetes tab
+			if m.activeTab == 2 && !m.showModal && !m.showPodDef {
+				m.allClusters = !m.allClusters
+				if m.allClusters {
+					m.clusters = configuredClusters()
+				}
+				m.kubesData = nil
+				m.updateTableForTab()
+				return m, m.loadKubePods()
+			}
+		case "J":
+			// Show the scheduled jobs with their last and next run
+			if !m.showModal && !m.showPodDef {
+				m.jobs = nil
+				m.showJobs = true
+				return m, m.loadJobs()
+			}
+		case "O":
+			// Show the slowest backend calls made since the TUI started
+			if !m.showModal && !m.showPodDef {
+				m.showSlowOps = true
+			}
+		case "u":
+			// Undo the most recent action recorded in the journal
+			if !m.showModal && !m.showPodDef {
+				if reason := m.roleDenied(permUpdateDeployments); reason != "" {
+					m.statusMessage = reason
+					return m, nil
+				}
+				cmd := m.undoLastAction()
+				return m, cmd
+			}
+		case "ctrl+x":
+			// Abort the most recent in-flight operation
+			m.cancelLatestOperation()
+			return m, nil
 		case "ctrl+d":
+			// Delete the deployment highlighted on the Workloads tab
+			if m.activeTab == 7 && len(m.workloadsData) > 0 && !m.showModal {
+				if reason := m.denied(permDeleteDeployments); reason != "" {
+					m.statusMessage = reason
+					return m, nil
+				}
+				if selectedRow := m.table.Cursor(); selectedRow < len(m.workloadsData) {
+					deployment := m.workloadsData[selectedRow]
+					cmd := m.deleteDeployment(deployment.PodName, deployment.Namespace)
+					return m, cmd
+				}
+			}
 			// Delete Docker image when on Docker tab
-			if m.activeTab == 1 && len(m.dockerData) > 0 && !m.showModal {
+			if dockerData := m.visibleDockerData(); m.activeTab == 1 && len(dockerData) > 0 && !m.showModal {
+				if reason := m.denied(permDeleteImages); reason != "" {
+					m.statusMessage = reason
+					return m, nil
+				}
 				selectedRow := m.table.Cursor()
-				if selectedRow < len(m.dockerData) {
-					imageID := m.dockerData[selectedRow].ImageID
-					return m, m.deleteDockerImage(imageID)
+				if selectedRow < len(dockerData) {
+					imageID := dockerData[selectedRow].ImageID
+					cmd := m.deleteDockerImage(imageID)
+					return m, cmd
 				}
 			}
 		case "ctrl+p":
 			// Pull Docker image from registry when on Docker tab
-			if m.activeTab == 1 && len(m.dockerData) > 0 && !m.showModal {
+			if dockerData := m.visibleDockerData(); m.activeTab == 1 && len(dockerData) > 0 && !m.showModal {
 				selectedRow := m.table.Cursor()
-				if selectedRow < len(m.dockerData) {
-					imageTag := m.dockerData[selectedRow].ImageTag
+				if selectedRow < len(dockerData) {
+					imageTag := dockerData[selectedRow].ImageTag
+					if dockerData[selectedRow].ArtifactType != "" {
+						m.statusMessage = artifactHint(dockerData[selectedRow])
+						return m, nil
+					}
 					if imageTag != "" && imageTag != "N/A" {
-						return m, m.pullDockerImage(imageTag)
+						cmd := m.pullDockerImage(imageTag)
+						return m, cmd
 					}
 				}
 			}
@@ -300,20 +1084,60 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.podDefTable, cmd = m.podDefTable.Update(msg)
 	} else {
 		m.table, cmd = m.table.Update(msg)
+		// Scrolling onto the last commit loads the next page
+		if more := m.loadMoreCommits(); more != nil {
+			cmd = tea.Batch(cmd, more)
+		}
 	}
 	return m, cmd
 }
 
-func (m *model) updateTableForTab() {
-	// Add panic recovery to prevent unexpected exits
-	defer func() {
-		if r := recover(); r != nil {
-			// If there's a panic, just return without doing anything
-			// This prevents the program from crashing
-			return
+// openDeployDialog opens the deploy modal for image at the deployment
+// picker, with "Create New Deployment" selected.
+func (m *model) openDeployDialog(image string) tea.Cmd {
+	m.selectedImage = image
+	m.modalStep = 0
+	m.selectedDeployment = -1
+	m.selectedPod2 = 0
+	m.showModal = true
+	m.deployProfile = nil
+	return tea.Batch(m.loadDeployments(), m.loadDeployProfile())
+}
+
+// visibleDockerData is the Docker tab's rows in display order, so the table
+// cursor indexes into it directly.
+func (m model) visibleDockerData() []TableData {
+	data := m.tourData(m.dockerData, sampleDockerData, isDockerPlaceholder)
+	if !m.danglingOnly && !m.hidePrereleases && m.searchQuery == "" {
+		return data
+	}
+	var visible []TableData
+	for _, item := range data {
+		if (!m.danglingOnly || isDangling(item)) && (!m.hidePrereleases || !isPrerelease(tagOf(item.ImageTag))) && matchesImageSearch(item, m.searchQuery) {
+			visible = append(visible, item)
 		}
-	}()
+	}
+	return visible
+}
 
+// switchTab activates a tab and cancels any loads still running for the
+// previous one so a hung backend can't deliver stale results later.
+func (m *model) switchTab(tab int) {
+	if !tabAvailable(tab) {
+		return
+	}
+	if tab != m.activeTab {
+		recordSessionStep(sessionStep{Action: sessionTab, Tab: tabNames[tab]})
+	}
+	if m.cancelTab != nil {
+		m.cancelTab()
+	}
+	m.tabCtx, m.cancelTab = context.WithCancel(m.ctx)
+	m.activeTab = tab
+	m.updateTableForTab()
+}
+
+func (m *model) updateTableForTab() {
 	// Validate that we have a valid table
 	if m.table.Columns() == nil {
 		return
@@ -324,28 +1148,34 @@ func (m *model) updateTableForTab() {
 
 	switch m.activeTab {
 	case 0: // Git tab
-		columns = []table.Column{
-			{Title: "Commit SHA", Width: 42},
-			{Title: "PR Description", Width: 40},
-			{Title: "Author", Width: 20},
-			{Title: "PushedAt", Width: 20},
-		}
-		if len(m.gitData) > 0 {
-			for _, item := range m.gitData {
-				rows = append(rows, table.Row{
-					item.CommitSHA,
-					truncateString(item.PRDescription, 40),
-					"N/A", // Placeholder for author
-					item.PushedAt,
-				})
+		if m.showReleases {
+			columns = []table.Column{
+				{Title: "Tag", Width: 20},
+				{Title: "Release", Width: 30},
+				{Title: "Published", Width: 16},
+				{Title: "Assets", Width: 6},
+				{Title: "Image", Width: 50},
 			}
-		} else {
+			rows = m.releaseRows()
+			if len(rows) == 0 && m.releases != nil {
+				rows = append(rows, table.Row{"No releases or tags", "", "", "", ""})
+			}
+			break
+		}
+		columns = gitColumns
+		gitData := m.tourData(m.gitData, sampleGitData, isGitPlaceholder)
+		if len(gitData) > 0 {
+			for _, item := range gitData {
+				rows = append(rows, commitRow(item))
+			}
+		} else if m.gitData != nil {
 			// Add a placeholder row if no data
 			rows = append(rows, table.Row{
 				"No data available",
 				"",
 				"",
 				"",
+				"",
 			})
 		}
 	case 1: // Docker tab
@@ -355,8 +1185,34 @@ func (m *model) updateTableForTab() {
 			{Title: "Tag", Width: 15},
 			{Title: "Size", Width: 12},
 			{Title: "Created", Width: 25},
+			{Title: "In Use", Width: 15},
+			{Title: "Local", Width: 9},
+			{Title: "Digest", Width: 20},
+		}
+		// Only Harbor and zot scan images, and most registries hold
+		// nothing but images
+		scanned, artifacts := false, false
+		for _, item := range m.visibleDockerData() {
+			scanned = scanned || item.Scan != ""
+			artifacts = artifacts || item.ArtifactType != ""
+		}
+		if artifacts {
+			columns = append(columns, table.Column{Title: "Type", Width: 24})
+		}
+		if scanned {
+			columns = append(columns, table.Column{Title: "Scan", Width: 14})
 		}
-		for _, item := range m.dockerData {
+		// Tags sharing a digest are one image under several names
+		aliases := dockerTabAliases(m.dockerData)
+		aliased := false
+		for _, item := range m.visibleDockerData() {
+			aliased = aliased || len(aliases[item.ImageTag]) > 0
+		}
+		if aliased {
+			columns = append(columns, table.Column{Title: "Same As", Width: 24})
+		}
+		latestStable := latestStableImages(m.dockerData)
+		for _, item := range m.visibleDockerData() {
 			// Extract repository and tag from RepoTags
 			repository := "N/A"
 			tag := "N/A"
@@ -379,13 +1235,128 @@ func (m *model) updateTableForTab() {
 					tag = "latest"
 				}
 			}
+			if latestStable[item.ImageTag] {
+				tag += latestStableMarker
+			}
 
+			row := table.Row{
+				item.ImageID,
+				repository,
+				tag,
+				item.ImageSize,
+				item.CreatedAt,
+				item.InUse,
+				item.LocalState,
+				strings.TrimPrefix(item.ImageDigest, "sha256:"),
+			}
+			if artifacts {
+				row = append(row, artifactLabel(item))
+			}
+			if scanned {
+				row = append(row, item.Scan)
+			}
+			if aliased {
+				row = append(row, aliasesCell(aliases[item.ImageTag]))
+			}
+			rows = append(rows, row)
+		}
+	case 3: // Cache tab
+		columns = []table.Column{
+			{Title: "Image", Width: 45},
+			{Title: "Source", Width: 20},
+			{Title: "Digest", Width: 20},
+			{Title: "Size", Width: 12},
+		}
+		for _, item := range m.cacheData {
 			rows = append(rows, table.Row{
-				truncateString(item.ImageID, 20),
-				truncateString(repository, 30),
-				truncateString(tag, 15),
-				truncateString(item.ImageSize, 12),
-				truncateString(item.CreatedAt, 25),
+				item.ImageTag,
+				item.Source,
+				strings.TrimPrefix(item.ImageDigest, "sha256:"),
+				item.ImageSize,
+			})
+		}
+	case 4: // Apps tab
+		columns = []table.Column{
+			{Title: "Application", Width: 25},
+			{Title: "Namespace", Width: 15},
+			{Title: "Sync", Width: 10},
+			{Title: "Health", Width: 12},
+			{Title: "Revision", Width: 10},
+			{Title: "Image", Width: 40},
+			{Title: "Registry", Width: 20},
+		}
+		for _, item := range m.argocdData {
+			rows = append(rows, table.Row{
+				item.AppName,
+				item.Namespace,
+				item.Status,
+				item.Health,
+				item.CommitSHA,
+				item.ImageTag,
+				item.Drift,
+			})
+		}
+	case 5: // Nodes tab
+		columns = []table.Column{
+			{Title: "Node", Width: 25},
+			{Title: "Status", Width: 15},
+			{Title: "Kubelet", Width: 10},
+			{Title: "CPU Requests", Width: 17},
+			{Title: "Memory Requests", Width: 24},
+			{Title: "CPU Used", Width: 17},
+			{Title: "Memory Used", Width: 24},
+		}
+		for _, item := range m.nodesData {
+			rows = append(rows, table.Row{
+				item.NodeName,
+				item.Status,
+				item.KubeletVersion,
+				item.NodeCPU,
+				item.NodeMemory,
+				item.NodeCPUUsage,
+				item.NodeMemoryUsage,
+			})
+		}
+	case 6: // Config tab
+		columns = []table.Column{
+			{Title: "Kind", Width: 10},
+			{Title: "Name", Width: 35},
+			{Title: "Keys", Width: 6},
+			{Title: "Status", Width: 18},
+			{Title: "Used By", Width: 45},
+		}
+		for _, entry := range m.configEntries {
+			keys := fmt.Sprint(len(entry.data))
+			if entry.missing {
+				keys = "-"
+			}
+			rows = append(rows, table.Row{
+				entry.kind,
+				entry.name,
+				keys,
+				entry.state(),
+				strings.Join(entry.usedBy, ", "),
+			})
+		}
+	case 7: // Workloads tab
+		columns = []table.Column{
+			{Title: "Deployment", Width: 30},
+			{Title: "Namespace", Width: 15},
+			{Title: "Status", Width: 12},
+			{Title: "Ready", Width: 7},
+			{Title: "Image", Width: 45},
+			{Title: "Age", Width: 15},
+			{Title: "Helm Release", Width: 20},
+		}
+		for _, item := range m.workloadsData {
+			rows = append(rows, table.Row{
+				item.PodName,
+				item.Namespace,
+				item.Status,
+				item.Restarts,
+				item.ImageTag,
+				item.Age,
+				item.HelmRelease,
 			})
 		}
 	case 2: // Kubernetes tab
@@ -397,61 +1368,41 @@ func (m *model) updateTableForTab() {
 			{Title: "Age", Width: 15},
 			{Title: "Node", Width: 20},
 		}
+		if m.allClusters {
+			columns = append([]table.Column{{Title: "Cluster", Width: 15}}, columns...)
+		}
 		// Real Kubernetes data
-		for _, item := range m.kubesData {
-			rows = append(rows, table.Row{
-				truncateString(item.PodName, 35),
+		for _, item := range m.tourData(m.kubesData, sampleKubesData, isKubesPlaceholder) {
+			row := table.Row{
+				item.PodName,
 				item.Namespace,
 				item.Status,
 				item.Restarts,
 				item.Age,
-				truncateString(item.NodeName, 20),
-			})
+				item.NodeName,
+			}
+			if m.allClusters {
+				row = append(table.Row{item.Cluster}, row...)
+			}
+			rows = append(rows, row)
 		}
 	default:
 		// Default to Git tab if something goes wrong
-		columns = []table.Column{
-			{Title: "Commit SHA", Width: 42},
-			{Title: "PR Description", Width: 40},
-			{Title: "Author", Width: 20},
-			{Title: "PushedAt", Width: 20},
-		}
+		columns = gitColumns
 		for _, item := range m.gitData {
-			rows = append(rows, table.Row{
-				item.CommitSHA,
-				truncateString(item.PRDescription, 40),
-				"N/A", // Placeholder for author
-				item.PushedAt,
-			})
+			rows = append(rows, commitRow(item))
 		}
 	}
 
-	// Safely update table with error handling
-	if len(columns) > 0 {
-		// Use defer to catch any panics from SetColumns
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					// If SetColumns panics, just ignore it
-					return
-				}
-			}()
-			m.table.SetColumns(columns)
-		}()
-	}
+	columns, rows = layoutColumns(tabNames[min(m.activeTab, len(tabNames)-1)], columns, rows)
 
-	if len(rows) >= 0 {
-		// Use defer to catch any panics from SetRows
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					// If SetRows panics, just ignore it
-					return
-				}
-			}()
-			m.table.SetRows(rows)
-		}()
+	if len(columns) > 0 {
+		// The table renders as soon as the columns change, so the previous
+		// tab's rows, which may have fewer cells, go first
+		m.table.SetRows(nil)
+		m.table.SetColumns(columns)
 	}
+	m.table.SetRows(rows)
 }
 
 func (m model) View() string {
@@ -478,21 +1429,49 @@ func (m model) View() string {
 	// Render tabs with spacing
 	var tabsRender []string
 	for i, tab := range m.tabs {
+		if !tabAvailable(i) {
+			continue
+		}
+		// Add space between tabs
+		if len(tabsRender) > 0 {
+			tabsRender = append(tabsRender, " ")
+		}
+		if i < len(m.stale) && m.stale[i] != nil {
+			tab += " 📴"
+		}
 		if i == m.activeTab {
 			tabsRender = append(tabsRender, activeTabStyle.Render(tab))
 		} else {
 			tabsRender = append(tabsRender, inactiveTabStyle.Render(tab))
 		}
-		// Add space after each tab except the last one
-		if i < len(m.tabs)-1 {
-			tabsRender = append(tabsRender, " ")
-		}
 	}
 
 	tabsRow := lipgloss.JoinHorizontal(lipgloss.Top, tabsRender...)
 	tabs := tabContainerStyle.Render(tabsRow)
 
-	instructions := "Press 1-3 to switch tabs, Tab to cycle, Enter to deploy/view, Ctrl+D to delete, Ctrl+P to pull (Docker), 'q' or ESC to quit"
+	instructions := "Press 1-8 to switch tabs, Tab to cycle, Enter to deploy/view, Ctrl+D to delete, Ctrl+P to pull (Docker), 'K' to switch cluster, 'u' to undo, 'J' for jobs, 'O' for slow ops, '?' for the tour, 'q' or ESC to quit"
+	if m.activeTab == 0 && m.filteringCommits {
+		instructions = m.renderCommitFilterPrompt() + "\n" + instructions
+	}
+	if m.activeTab == 1 {
+		instructions = fmt.Sprintf("📦 Registry %s%s · s to switch registry, w to compare tags across registries, m on two tags to diff them\n", registryName(), flavorSuffix(cachedFlavor(m.ctx, getRegistryHost()))+quirksNote(m.ctx, getRegistryHost())) +
+			"f to toggle unused images only, v to hide pre-releases, c to reconcile a differing local copy, / to search, i for labels, t to retag, z for size trend · ★ marks each repository's latest stable version · " + instructions
+		if m.danglingOnly {
+			instructions = "Showing images not used by the cluster (safe to prune) · " + instructions
+		}
+		if m.hidePrereleases {
+			instructions = "Hiding pre-release versions · " + instructions
+		}
+		if m.searching || m.searchQuery != "" {
+			instructions = m.renderImageSearchStatus() + "\n" + instructions
+		}
+		if m.retagging {
+			instructions = m.renderRetagPrompt() + "\n" + instructions
+		}
+	}
+	if m.showTour {
+		instructions = m.renderTour()
+	}
 
 	// Create border style with proper width that encompasses both tabs and table
 	containerStyle := baseStyle.Width(m.width - 2) // Account for border padding
@@ -509,14 +1488,99 @@ func (m model) View() string {
 	tabsAndTable := lipgloss.JoinVertical(lipgloss.Left, tabs, separator, m.table.View())
 	borderedContainer := containerStyle.Render(tabsAndTable)
 
+	if status := m.tabStatus(); status != "" {
+		instructions = status + "\n" + instructions
+	}
+
+	if m.statusMessage != "" {
+		instructions = m.statusMessage + "\n" + instructions
+	}
+
+	if status := m.renderOperations(); status != "" {
+		instructions = status + "\n" + instructions
+	}
+
+	if status := renderRetries(); status != "" {
+		instructions = status + "\n" + instructions
+	}
+
+	if status := m.renderRegistryHealth(); status != "" {
+		instructions = status + "\n" + instructions
+	}
+
 	mainView := fmt.Sprintf("%s\n\n%s\n\n%s", styledArt, borderedContainer, instructions)
 
+	// The detail screen can be opened from the deployment picker, so it goes first
+	if m.showDeploymentDef {
+		return m.renderDefinitionView("Deployment: "+m.detailDeployment, m.deploymentDefTable, "")
+	}
+
+	if m.showImageDef {
+		return m.renderDefinitionView("Image: "+m.detailImage, m.imageDefTable, "")
+	}
+
 	// Show modal if active
 	if m.showModal {
 		modal := m.renderModal()
 		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, modal, lipgloss.WithWhitespaceChars("░"))
 	}
 
+	if m.showReconcile {
+		return m.renderReconcile()
+	}
+
+	if m.showConfigViewer {
+		return m.renderConfigViewer()
+	}
+
+	if m.showClusterPicker {
+		return m.renderClusterPicker()
+	}
+
+	if m.showRegistryPicker {
+		return m.renderRegistryPicker()
+	}
+
+	if m.showTagComparison {
+		return m.renderTagComparison()
+	}
+
+	if m.showImageComparison {
+		return m.renderImageComparison()
+	}
+
+	if m.showSizeTrend {
+		return m.renderSizeTrend()
+	}
+
+	if m.showJobs {
+		return m.renderJobs()
+	}
+
+	if m.showRelease {
+		return m.renderRelease()
+	}
+
+	if m.showSlowOps {
+		return m.renderSlowOps()
+	}
+
+	if m.showPull {
+		return m.renderPullProgress()
+	}
+
+	if m.showPodLogs {
+		return m.renderPodLogs()
+	}
+
+	if m.showCrashDiagnosis {
+		return m.renderCrashDiagnosis()
+	}
+
+	if m.containerAction != "" {
+		return m.renderContainerPicker()
+	}
+
 	// Show pod definition view if active
 	if m.showPodDef {
 		return m.renderPodDefView()
@@ -536,75 +1600,58 @@ func (m model) renderModal() string {
 			modalContent.WriteString("Loading deployments...\n\n")
 			// Still show "Create New Deployment" option even when loading
 			prefix := "→ " // Always selected when no deployments
-			modalContent.WriteString(fmt.Sprintf("%s[Create New Deployment]\n\n", prefix))
+			modalContent.WriteString(prefix + m.option("[Create New Deployment]", permCreateDeployments) + "\n\n")
 		} else {
 			// Add "Create New Deployment" option at the top
 			prefix := "  "
 			if m.selectedDeployment == -1 {
 				prefix = "→ "
 			}
-			modalContent.WriteString(fmt.Sprintf("%s[Create New Deployment]\n", prefix))
+			modalContent.WriteString(prefix + m.option("[Create New Deployment]", permCreateDeployments) + "\n")
 
 			for i, deployment := range m.deployments {
 				prefix = "  "
 				if i == m.selectedDeployment {
 					prefix = "→ "
 				}
-				modalContent.WriteString(fmt.Sprintf("%s%s (%s) - %s\n",
-					prefix, deployment.PodName, deployment.Namespace, deployment.Status))
+				last := ""
+				if m.isProfileDeployment(deployment) {
+					last = " ↺ last deployed here"
+				}
+				modalContent.WriteString(fmt.Sprintf("%s%s (%s) - %s%s%s\n",
+					prefix, deployment.PodName, deployment.Namespace, deployment.Status, helmSuffix(deployment), last))
 			}
 			modalContent.WriteString("\n")
 		}
 
-		modalContent.WriteString("Use ↑/↓ to navigate, Enter/1 to select, 2 to cancel, ESC to close")
+		modalContent.WriteString("Use ↑/↓ to navigate, Enter/1 to select, d for details, r to restart, 2 to cancel, ESC to close")
 
 		return modalStyle.Render(modalContent.String())
 	} else if m.modalStep == 1 {
-		// New deployment creation step - use same logic as createNewDeployment
-		deploymentName := strings.ToLower(m.selectedImage)
-		// Replace invalid characters with hyphens
-		deploymentName = strings.ReplaceAll(deploymentName, ":", "-")
-		deploymentName = strings.ReplaceAll(deploymentName, "/", "-")
-		deploymentName = strings.ReplaceAll(deploymentName, "_", "-")
-		deploymentName = strings.ReplaceAll(deploymentName, ".", "-")
-
-		// Remove any leading/trailing hyphens and ensure it's not empty
-		deploymentName = strings.Trim(deploymentName, "-")
-		if deploymentName == "" || deploymentName == "latest" {
-			deploymentName = "new-deployment"
-		}
-
-		// Ensure it starts with a letter (Kubernetes requirement)
-		if len(deploymentName) > 0 && (deploymentName[0] < 'a' || deploymentName[0] > 'z') {
-			deploymentName = "app-" + deploymentName
-		}
-
-		modalContent := fmt.Sprintf(`Create New Deployment
-
-Image: %s
-Deployment Name: %s
-Namespace: default
-Port: 80
-Replicas: 1
-
-This will create a new Kubernetes deployment with:
-- 1 replica pod running your image
-- Container port 80 exposed
-- ImagePullPolicy set to "Never" for local registry
-- App label: %s
-
-Options:
-[1] Create Deployment
-[2] Go Back
-
-Press 1 to create, 2 to go back, or ESC to cancel`, m.selectedImage, deploymentName, deploymentName)
-
-		return modalStyle.Render(modalContent)
+		return m.renderDeployForm()
+	} else if m.modalStep == 3 {
+		return m.renderCanary()
+	} else if m.modalStep == 4 {
+		return m.renderDeployContainerPicker()
+	} else if m.editingDeployEnv {
+		return m.renderDeployEnv()
 	} else {
 		// Confirmation step for existing deployment
-		selectedDep := ""
+		selectedDep, release := "", ""
 		if len(m.deployments) > 0 && m.selectedDeployment < len(m.deployments) {
 			selectedDep = m.deployments[m.selectedDeployment].PodName
+			release = m.deployments[m.selectedDeployment].HelmRelease
+		}
+		if m.deployContainer != "" {
+			selectedDep += ", container " + m.deployContainer
+		}
+
+		effect := `This will update the deployment's container image and trigger a rolling update.
+All pods in this deployment will be updated with the new image.`
+		if release != "" {
+			repositoryKey, tagKey := helmImageKeys()
+			effect = fmt.Sprintf(`The deployment belongs to Helm release %s, so the release is upgraded
+with %s and %s set to the new image instead.`, release, repositoryKey, tagKey)
 		}
 
 		modalContent := fmt.Sprintf(`Confirm Deployment
@@ -612,21 +1659,45 @@ Press 1 to create, 2 to go back, or ESC to cancel`, m.selectedImage, deploymentN
 Image: %s
 Deployment: %s
 
-This will update the deployment's container image and trigger a rolling update.
-All pods in this deployment will be updated with the new image.
+%s
 Make sure the image is available in your registry!
 
+Changes:
+%s
+
 Options:
-[1] Confirm Deploy
+%s
 [2] Go Back
+%s
+%s
 
-Press 1 to confirm, 2 to go back, or ESC to cancel`, m.selectedImage, selectedDep)
+Press 1 to confirm, 2 to go back, 3 for a canary, e to edit env, or ESC to cancel`, m.selectedImage, selectedDep, effect, m.renderDeployPreview(),
+			m.option("[1] Confirm Deploy", m.deployPermission()),
+			m.option(fmt.Sprintf("[3] Canary first: %d replicas of the new image next to it (+/- to resize)", m.canaryReplicas), permCreateDeployments),
+			m.renderDeployEnvOption())
 
-		return modalStyle.Render(modalContent)
+		return modalStyle.Width(72).Height(0).Render(modalContent)
 	}
 }
 
+func helmSuffix(deployment TableData) string {
+	if deployment.HelmRelease == "" {
+		return ""
+	}
+	return " ⎈ " + deployment.HelmRelease
+}
+
 func (m model) renderPodDefView() string {
+	hint := "L for a container's logs, E for a shell in it, x to diagnose a crash"
+	if m.statusMessage != "" {
+		hint = m.statusMessage + "\n" + hint
+	}
+	return m.renderDefinitionView(fmt.Sprintf("Pod Definition: %s", m.selectedPod), m.podDefTable, hint)
+}
+
+// renderDefinitionView is the full-screen key/value view shared by pod and
+// deployment details, with hint above the instructions when set.
+func (m model) renderDefinitionView(title string, details table.Model, hint string) string {
 	asciiArt := `
 ██╗            ██████╗           ██████╗ 
 ██║           ██╔════╝           ██╔══██╗
@@ -643,14 +1714,16 @@ func (m model) renderPodDefView() string {
 
 	styledArt := artStyle.Render(asciiArt)
 
-	title := fmt.Sprintf("Pod Definition: %s", m.selectedPod)
 	titleStyled := titleStyle.Render(title)
 
 	instructions := "Press ESC to go back to main view"
+	if hint != "" {
+		instructions = hint + "\n" + instructions
+	}
 
 	// Create border style with proper width
 	containerStyle := baseStyle.Width(m.width - 2)
-	borderedTable := containerStyle.Render(m.podDefTable.View())
+	borderedTable := containerStyle.Render(details.View())
 
 	return fmt.Sprintf("%s\n\n%s\n\n%s\n\n%s", styledArt, titleStyled, borderedTable, instructions)
 }
@@ -665,27 +1738,117 @@ type deploymentPodsMsg struct {
 }
 
 type podDetailsMsg struct {
-	details map[string]string
+	details podDetails
 	err     error
 }
 
+type cacheDataMsg struct {
+	data  []TableData
+	stats *proxyStats
+	err   error
+}
+
+// tabStatus is the line about the active tab's data shown above the key
+// help, empty for tabs without one.
+func (m model) tabStatus() string {
+	if m.activeTab < len(m.stale) && m.stale[m.activeTab] != nil {
+		return m.stale[m.activeTab].String()
+	}
+	switch m.activeTab {
+	case 0:
+		if m.showReleases {
+			return m.renderReleasesStatus()
+		}
+		return m.renderGitStatus()
+	case 1:
+		return m.renderDockerLoadStatus()
+	case 2:
+		return m.renderKubernetesStatus()
+	case 3:
+		return m.renderCacheStats()
+	case 4:
+		return m.renderArgoCDStatus()
+	case 5:
+		return m.renderNodesStatus()
+	case 6:
+		return m.renderConfigStatus()
+	case 7:
+		return m.renderWorkloadsStatus()
+	}
+	return ""
+}
+
+// loadTabData fetches data for tabs that load on demand rather than at startup.
+func (m model) loadTabData() tea.Cmd {
+	ctx := m.tabCtx
+	switch m.activeTab {
+	case 3:
+		return func() tea.Msg {
+			data, err := getCacheInventory(ctx)
+			stats, statsErr := getProxyStats(ctx)
+			if err == nil {
+				err = statsErr
+			}
+			return cacheDataMsg{data: data, stats: stats, err: err}
+		}
+	case 4:
+		if !argocdConfigured() {
+			return nil
+		}
+		return func() tea.Msg {
+			data, err := getArgoCDInventory(ctx)
+			return argocdDataMsg{data: data, err: err}
+		}
+	case 5:
+		return func() tea.Msg {
+			return getNodeOverview(ctx)
+		}
+	case 6:
+		return func() tea.Msg {
+			entries, err := getConfigInventory(ctx)
+			return configDataMsg{entries: entries, err: err}
+		}
+	case 7:
+		return func() tea.Msg {
+			data, err := getWorkloads(ctx)
+			return workloadsDataMsg{data: data, err: err}
+		}
+	}
+	return nil
+}
+
+func (m model) renderCacheStats() string {
+	upstream := upstreamRegistryURL()
+	switch {
+	case m.cacheData == nil && m.cacheErr == nil:
+		return fmt.Sprintf("Loading cache inventory (upstream %s)...", upstream)
+	case m.cacheErr != nil:
+		return fmt.Sprintf("⚠️  %v", m.cacheErr)
+	case m.cacheStats == nil:
+		return fmt.Sprintf("Upstream %s · set REGISTRY_DEBUG_ADDR to the registry's debug listener for hit rates", upstream)
+	}
+	s := m.cacheStats
+	return fmt.Sprintf("Upstream %s · manifests %d requests, %s hits · blobs %d requests, %s hits · %s pulled from upstream",
+		upstream, s.Manifests.Requests, s.Manifests.hitRate(), s.Blobs.Requests, s.Blobs.hitRate(), formatBytes(int64(s.Blobs.BytesPulled)))
+}
+
 func (m model) loadDeployments() tea.Cmd {
 	return func() tea.Msg {
-		deployments, _ := getKubernetesDeployments()
+		deployments, _ := getKubernetesDeployments(m.tabCtx)
 		return deploymentsMsg{deployments: deployments}
 	}
 }
 
 func (m model) loadPodsForDeployment(deploymentName, namespace string) tea.Cmd {
 	return func() tea.Msg {
-		pods, _ := getPodsForDeployment(deploymentName, namespace)
+		pods, _ := getPodsForDeployment(m.tabCtx, deploymentName, namespace)
 		return deploymentPodsMsg{pods: pods}
 	}
 }
 
 func (m model) loadPodDetails() tea.Cmd {
 	return func() tea.Msg {
-		details, err := getKubernetesPodDetails(m.selectedPod, m.selectedPodNS)
+		details, err := getKubernetesPodDetails(m.tabCtx, m.selectedPod, m.selectedPodNS)
 		return podDetailsMsg{
 			details: details,
 			err:     err,
@@ -693,51 +1856,22 @@ func (m model) loadPodDetails() tea.Cmd {
 	}
 }
 
-func (m *model) initPodDefTable(details map[string]string) {
+func (m *model) initPodDefTable(details podDetails, err error) {
+	rows := details.rows
+	if err != nil {
+		rows = []table.Row{{"Error", "Failed to load pod details"}}
+	}
+	m.podContainers = details.containers
+	m.podDefTable = newDetailTable(rows)
+}
+
+// newDetailTable is a Key/Value table for the detail screens.
+func newDetailTable(rows []table.Row) table.Model {
 	columns := []table.Column{
 		{Title: "Key", Width: 35},
 		{Title: "Value", Width: 70},
 	}
-
-	var rows []table.Row
-
-	if details != nil {
-		// Add details in a logical order
-		orderedKeys := []string{
-			"Name", "Namespace", "Status", "Node", "Pod IP", "Host IP",
-			"Created", "Start Time", "Service Account", "Restart Policy", "DNS Policy",
-			"Container Name", "Container Image", "Image Pull Policy", "Container Ports",
-			"CPU Request", "Memory Request", "CPU Limit", "Memory Limit",
-			"Container Ready", "Restart Count", "Container ID",
-			"Ready Condition", "Scheduled Condition", "Initialized Condition",
-			"Labels", "Annotations",
-		}
-
-		for _, key := range orderedKeys {
-			if value, exists := details[key]; exists && value != "" {
-				rows = append(rows, table.Row{key, truncateString(value, 70)})
-			}
-		}
-
-		// Add any remaining details not in the ordered list
-		for key, value := range details {
-			found := false
-			for _, orderedKey := range orderedKeys {
-				if key == orderedKey {
-					found = true
-					break
-				}
-			}
-			if !found && value != "" {
-				rows = append(rows, table.Row{key, truncateString(value, 70)})
-			}
-		}
-	} else {
-		// Error loading details
-		rows = append(rows, table.Row{"Error", "Failed to load pod details"})
-	}
-
-	m.podDefTable = table.New(
+	t := table.New(
 		table.WithColumns(columns),
 		table.WithRows(rows),
 		table.WithFocused(true),
@@ -754,7 +1888,8 @@ func (m *model) initPodDefTable(details map[string]string) {
 		Foreground(lipgloss.Color("229")).
 		Background(lipgloss.Color("57")).
 		Bold(false)
-	m.podDefTable.SetStyles(s)
+	t.SetStyles(s)
+	return t
 }
 
 // Message types for async operations
@@ -773,115 +1908,162 @@ type dockerPullMsg struct {
 type deploymentMsg struct {
 	success bool
 	err     error
+	status  string // shown after success, e.g. where the deployment is reachable
 }
 
-func (m model) deleteDockerImage(imageID string) tea.Cmd {
-	return func() tea.Msg {
-		// Execute docker rmi command
-		cmd := exec.Command("docker", "rmi", "-f", imageID)
-		err := cmd.Run()
+func (m *model) deleteDockerImage(imageID string) tea.Cmd {
+	recordSessionStep(sessionStep{Action: sessionDelete, Image: imageID})
+	historyCtx := m.ctx
+	return m.startOperation("delete", imageID, func(ctx context.Context) tea.Msg {
+		ctx, cancel := withBackendTimeout(ctx, backendDocker)
+		defer cancel()
+
+		err := containerRuntime(ctx).Remove(ctx, imageID)
+		recordActionResult(withSpanOf(historyCtx, ctx), "delete", imageID, err, "")
 
 		return dockerDeleteMsg{
 			success: err == nil,
 			imageID: imageID,
 			err:     err,
 		}
-	}
+	})
 }
 
-func (m model) pullDockerImage(imageTag string) tea.Cmd {
-	return func() tea.Msg {
-		// Execute docker pull command
-		cmd := exec.Command("docker", "pull", imageTag)
-		err := cmd.Run()
+func (m *model) pullDockerImage(imageTag string) tea.Cmd {
+	recordSessionStep(sessionStep{Action: sessionPull, Image: imageTag})
+	historyCtx := m.ctx
+	progress := newPullProgress(imageTag)
+	cmd := m.startOperation("pull", imageTag, func(ctx context.Context) tea.Msg {
+		ctx, cancel := withBackendTimeout(ctx, backendDocker)
+		defer cancel()
+
+		err := pullImage(ctx, imageTag, progress)
+		recordActionResult(withSpanOf(historyCtx, ctx), "pull", imageTag, err, "")
+		progress.finish(err)
 
 		return dockerPullMsg{
 			success:  err == nil,
 			imageTag: imageTag,
 			err:      err,
 		}
-	}
+	})
+	progress.operationID = m.nextOperationID
+	m.pull = progress
+	m.showPull = true
+	return tea.Batch(cmd, pullTick())
 }
 
-func (m model) deployImageToPod(imageName, deploymentName, namespace string) tea.Cmd {
-	return func() tea.Msg {
-		err := deployImageToPod(imageName, deploymentName, namespace)
+func (m *model) deployImageToPod(imageName, deploymentName, namespace, container string, env *containerEnv) tea.Cmd {
+	historyCtx, profile := m.ctx, m.deployedProfile(deploymentName, namespace, container)
+	return m.startOperation("deploy", deploymentName, func(ctx context.Context) tea.Msg {
+		if gitopsEnabled() {
+			// The cluster is left to the GitOps controller
+			status, err := exportDeployment(ctx, clusterImageName(ctx, imageName), deploymentName, namespace, container)
+			recordActionResult(withSpanOf(historyCtx, ctx), "export", namespace+"/"+deploymentName, err, status)
+			if err == nil {
+				saveDeployProfile(ctx, imageName, profile)
+			}
+			return deploymentMsg{success: err == nil, err: err, status: status}
+		}
+		err := deployImageToPod(ctx, imageName, deploymentName, namespace, container, env)
+		details := "image " + imageName
+		if env != nil {
+			details += "; env " + envNames(*env)
+		}
+		recordActionResult(withSpanOf(historyCtx, ctx), "deploy", namespace+"/"+deploymentName, err, details)
+		if err == nil {
+			saveDeployProfile(ctx, imageName, profile)
+		}
 		return deploymentMsg{
 			success: err == nil,
 			err:     err,
 		}
-	}
+	})
 }
 
-func (m model) createNewDeployment(imageName string) tea.Cmd {
-	return func() tea.Msg {
-		// Generate a deployment name based on the image name
-		deploymentName := strings.ToLower(imageName)
-		// Replace invalid characters with hyphens
-		deploymentName = strings.ReplaceAll(deploymentName, ":", "-")
-		deploymentName = strings.ReplaceAll(deploymentName, "/", "-")
-		deploymentName = strings.ReplaceAll(deploymentName, "_", "-")
-		deploymentName = strings.ReplaceAll(deploymentName, ".", "-")
-
-		// Remove any leading/trailing hyphens and ensure it's not empty
-		deploymentName = strings.Trim(deploymentName, "-")
-		if deploymentName == "" || deploymentName == "latest" {
-			deploymentName = "new-deployment"
+func (m *model) createNewDeployment(imageName string, params deploymentParams) tea.Cmd {
+	historyCtx, profile := m.ctx, m.formProfile(params)
+	recordSessionStep(sessionStep{Action: sessionCreateDeployment, Image: imageName, Form: profile.Form, CreateNamespace: params.CreateNamespace})
+	return m.startOperation("create deployment", params.Name, func(ctx context.Context) tea.Msg {
+		url, err := createKubernetesDeployment(ctx, imageName, params)
+		if err == nil {
+			journal(journalEntry{Kind: journalCreateDeployment, Namespace: params.Namespace, Name: params.Name, Service: params.Service != "", Ingress: params.IngressHost != ""})
+			saveDeployProfile(ctx, imageName, profile)
 		}
-
-		// Ensure it starts with a letter (Kubernetes requirement)
-		if len(deploymentName) > 0 && (deploymentName[0] < 'a' || deploymentName[0] > 'z') {
-			deploymentName = "app-" + deploymentName
+		details := fmt.Sprintf("image %s; %d replicas; port %d", imageName, params.Replicas, params.Port)
+		if requests := resourceListString(params.Resources.Requests); requests != "" {
+			details += "; requests " + requests
 		}
-
-		err := createKubernetesDeployment(imageName, deploymentName, "default")
+		if params.CreateNamespace {
+			details += "; created namespace " + params.Namespace
+		}
+		var status string
+		if url != "" {
+			details += "; " + url
+			status = "🚀 Deployed, reachable at " + url
+		}
+		recordActionResult(withSpanOf(historyCtx, ctx), "create-deployment", params.Namespace+"/"+params.Name, err, details)
 		return deploymentMsg{
 			success: err == nil,
 			err:     err,
+			status:  status,
 		}
-	}
+	})
 }
 
-func (m model) refreshDockerData() tea.Cmd {
-	return func() tea.Msg {
+func (m *model) refreshDockerData() tea.Cmd {
+	return m.startOperation("refresh", "images", func(ctx context.Context) tea.Msg {
 		// Get fresh Docker data
-		dockerImages, err := getDockerImagesInfo()
-		if err != nil {
-			return dockerDeleteMsg{success: false, err: err}
+		msg := loadDockerTable(ctx)
+		if msg.err != nil {
+			return dockerDeleteMsg{success: false, err: msg.err}
 		}
+		return msg
+	})
+}
 
-		// Convert to table data format
-		var dockerTableData []TableData
-		for _, dockerImg := range dockerImages {
-			imageID := dockerImg.ID
-			if len(imageID) > 20 {
-				imageID = imageID[:20]
-			}
+type dockerRefreshMsg struct {
+	data  []TableData
+	err   error
+	stale *staleData // set when data is the last-known-good
+}
 
-			imageTag := "N/A"
-			if len(dockerImg.RepoTags) > 0 && dockerImg.RepoTags[0] != "<none>:<none>" {
-				imageTag = dockerImg.RepoTags[0]
-			}
+// dockerTableData turns images into Docker tab rows, marking the ones the
+// cluster runs and the ones whose local copy differs. Never nil, as nil
+// means the tab is still loading.
+func dockerTableData(ctx context.Context, images []DockerImage) []TableData {
+	data := []TableData{}
+	for _, dockerImg := range images {
+		imageID := dockerImg.ID
+		if len(imageID) > 20 {
+			imageID = imageID[:20]
+		}
 
-			imageSize := dockerImg.Size
-			if dockerImg.Size == "" || dockerImg.Size == "N/A" {
-				imageSize = "N/A"
-			}
+		imageTag := "N/A"
+		if len(dockerImg.RepoTags) > 0 && dockerImg.RepoTags[0] != "<none>:<none>" {
+			imageTag = dockerImg.RepoTags[0]
+		}
 
-			dockerTableData = append(dockerTableData, TableData{
-				ImageID:   imageID,
-				ImageSize: imageSize,
-				ImageTag:  imageTag,
-				CreatedAt: dockerImg.CreatedAt,
-			})
+		imageSize := dockerImg.Size
+		if dockerImg.Size == "" || dockerImg.Size == "N/A" {
+			imageSize = "N/A"
 		}
 
-		return dockerRefreshMsg{data: dockerTableData}
+		data = append(data, TableData{
+			ImageID:        imageID,
+			ImageSize:      imageSize,
+			ImageTag:       imageTag,
+			CreatedAt:      dockerImg.CreatedAt,
+			ImageDigest:    dockerImg.Digest,
+			Scan:           dockerImg.Scan,
+			ArtifactType:   dockerImg.ArtifactType,
+			ArtifactDetail: dockerImg.ArtifactDetail,
+			Labels:         dockerImg.Labels,
+			Annotations:    dockerImg.Annotations,
+		})
 	}
-}
-
-type dockerRefreshMsg struct {
-	data []TableData
+	data = markImagesInUse(ctx, data)
+	return markLocalConflicts(ctx, data)
 }
 
 func truncateString(s string, maxLen int) string {
@@ -891,31 +2073,28 @@ func truncateString(s string, maxLen int) string {
 	return s[:maxLen-3] + "..."
 }
 
-func startTUI(gitData []TableData, dockerData []TableData, kubernetesData []TableData) {
-	// Initialize tabs
-	tabs := []string{"Git", "Docker", "Kubernetes"}
+// tabNames are the TUI's tabs in order; --tab takes them case-insensitively.
+var tabNames = []string{"Git", "Docker", "Kubernetes", "Cache", "Apps", "Nodes", "Config", "Workloads"}
+
+// kubernetesTabs are the tabs showing the cluster, left out when
+// Kubernetes is disabled.
+var kubernetesTabs = []int{2, 5, 6, 7}
+
+// tabAvailable reports whether a tab is shown.
+func tabAvailable(tab int) bool {
+	return kubernetesEnabled() || !slices.Contains(kubernetesTabs, tab)
+}
+
+// newModel builds the TUI's state; Init loads the data of the first tabs.
+// Cancelling the returned function cancels everything started from it.
+func newModel(b backends) (model, context.CancelFunc) {
+	tabs := tabNames
 
 	// Initialize Git tab columns and rows
-	gitColumns := []table.Column{
-		{Title: "Commit SHA", Width: 42},
-		{Title: "PR Description", Width: 40},
-		{Title: "Author", Width: 20},
-		{Title: "PushedAt", Width: 20},
-	}
-
-	var gitRows []table.Row
-	for _, item := range gitData {
-		gitRows = append(gitRows, table.Row{
-			item.CommitSHA,
-			truncateString(item.PRDescription, 40),
-			"N/A", // Placeholder for author
-			item.PushedAt,
-		})
-	}
+	columns, _ := layoutColumns(tabNames[0], gitColumns, nil)
 
 	t := table.New(
-		table.WithColumns(gitColumns),
-		table.WithRows(gitRows),
+		table.WithColumns(columns),
 		table.WithFocused(true),
 		table.WithHeight(10),
 	)
@@ -932,18 +2111,54 @@ func startTUI(gitData []TableData, dockerData []TableData, kubernetesData []Tabl
 		Bold(false)
 	t.SetStyles(s)
 
+	ctx, cancel := context.WithCancel(withBackends(rootCtx, b))
+	tabCtx, cancelTab := context.WithCancel(ctx)
+
 	m := model{
-		table:      t,
-		activeTab:  0,
-		tabs:       tabs,
-		gitData:    gitData,
-		dockerData: dockerData,
-		kubesData:  kubernetesData,
+		table:       t,
+		activeTab:   0,
+		tabs:        tabs,
+		clusterName: currentCluster().displayName(),
+		backends:    b,
+		ctx:         ctx,
+		tabCtx:      tabCtx,
+		cancelTab:   cancelTab,
+	}
+	// GIT_SERVICE starts the Git tab on one service's commits
+	if name := os.Getenv("GIT_SERVICE"); name != "" {
+		if service, err := findGitService(name); err == nil {
+			m.commitService, m.gitQuery.path = service, service.path
+		} else {
+			log.Printf("⚠️  Ignoring GIT_SERVICE: %v", err)
+		}
+	}
+	return m, cancel
+}
+
+func startTUI(startTab int) {
+	// Everything started from the TUI is cancelled once the program exits
+	m, cancel := newModel(backends{})
+	defer cancel()
+	if startTab != 0 {
+		m.switchTab(startTab)
+		m.startCmd = m.loadTabData()
+	}
+	if shouldShowTour() {
+		m.startTour()
 	}
 
-	p := tea.NewProgram(m, tea.WithAltScreen())
+	// Panics end in a crash report rather than Bubble Tea's stack trace
+	p := tea.NewProgram(guardedModel{m}, tea.WithAltScreen(), tea.WithoutCatchPanics())
+	startWebhookReceiver()
+	startAuditForwarding()
+	forwardEvents(p)
+	// Bubble Tea restores the terminal when it quits; this is for exits
+	// that don't wait for it, like a second Ctrl+C during startup work or
+	// a crash
+	defer onShutdown(func() { p.ReleaseTerminal() })()
+	defer recoverCrash()
 	if _, err := p.Run(); err != nil {
 		fmt.Println("Error running program:", err)
-		os.Exit(1)
+		exit(1)
 	}
 }
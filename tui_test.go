@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/backend/mock"
+	"github.com/anthony-gilbert/local-container-registry/pkg/kube"
+)
+
+// loadPods runs the Kubernetes tab's pod listing against cluster and hands
+// its result to Update, as the program would.
+func loadPods(t *testing.T, cluster *mock.MockClusterAPI) model {
+	t.Helper()
+	m, cancel := newModel(backends{cluster: cluster})
+	t.Cleanup(cancel)
+	m.switchTab(2)
+	updated, _ := m.Update(m.loadKubePods()())
+	return updated.(model)
+}
+
+func TestKubernetesTabListsClusterPods(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cluster := mock.NewMockClusterAPI(ctrl)
+	cluster.EXPECT().Pods(gomock.Any(), metav1.NamespaceAll).Return([]kube.Pod{
+		{Name: "web-7d4f9", Namespace: "default", Phase: "Running", Node: "node-1", Restarts: 2, Created: time.Now()},
+		{Name: "worker-x2c8", Namespace: "jobs", Phase: "Pending"},
+	}, nil)
+
+	// No kubeconfig exists (see TestMain), so only the mock can answer
+	m := loadPods(t, cluster)
+	rows := m.table.Rows()
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2: %v", len(rows), rows)
+	}
+	for i, want := range [][]string{
+		{"web-7d4f9", "default", "Running", "2"},
+		{"worker-x2c8", "jobs", "Pending", "0"},
+	} {
+		for j, cell := range want {
+			if rows[i][j] != cell {
+				t.Errorf("row %d column %d is %q, want %q", i, j, rows[i][j], cell)
+			}
+		}
+	}
+}
+
+func TestKubernetesTabExplainsUnreachableCluster(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cluster := mock.NewMockClusterAPI(ctrl)
+	cluster.EXPECT().Pods(gomock.Any(), metav1.NamespaceAll).
+		Return(nil, errors.New("dial tcp 192.168.49.2:8443: connect: connection refused"))
+
+	m := loadPods(t, cluster)
+	if len(m.kubesData) != 1 || m.kubesData[0].PodName != "Kubernetes cluster not accessible. Check Minikube status." {
+		t.Fatalf("got %v, want the unreachable cluster explained", m.kubesData)
+	}
+	if status := m.table.Rows()[0][2]; status != "Error" {
+		t.Errorf("status is %q, want Error", status)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"helm.sh/helm/v3/pkg/action"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/kube"
+)
+
+// Kinds of journal entries, each recording what its action replaced
+const (
+	journalDeploy           = "deploy"            // Container, Image, PullPolicy: the container's previous image; Env, if the deploy changed it
+	journalHelmUpgrade      = "helm-upgrade"      // Revision: the release's revision before the upgrade
+	journalCreateDeployment = "create-deployment" // Service, Ingress: objects created alongside it
+	journalDeleteDeployment = "delete-deployment" // Deployment: the deleted object
+	journalDeleteTag        = "delete-tag"        // Manifest, MediaType: what the tag pointed at
+	journalRetag            = "retag"             // Manifest, MediaType: what the moved tag pointed at
+)
+
+// maxJournalEntries bounds the journal; older actions can't be undone.
+const maxJournalEntries = 50
+
+// journalEntry is one undoable action with the state needed to invert it.
+type journalEntry struct {
+	Time       time.Time       `json:"time"`
+	Kind       string          `json:"kind"`
+	Cluster    string          `json:"cluster,omitempty"` // kube context or KUBE_CLUSTERS name
+	Namespace  string          `json:"namespace,omitempty"`
+	Name       string          `json:"name,omitempty"` // deployment, release or repository:tag
+	Container  string          `json:"container,omitempty"`
+	Image      string          `json:"image,omitempty"`
+	PullPolicy string          `json:"pullPolicy,omitempty"`
+	Env        *containerEnv   `json:"env,omitempty"`
+	Revision   int             `json:"revision,omitempty"`
+	Service    bool            `json:"service,omitempty"`
+	Ingress    bool            `json:"ingress,omitempty"`
+	Deployment json.RawMessage `json:"deployment,omitempty"`
+	Registry   string          `json:"registry,omitempty"`
+	Repository string          `json:"repository,omitempty"`
+	Tag        string          `json:"tag,omitempty"`
+	MediaType  string          `json:"mediaType,omitempty"`
+	Manifest   []byte          `json:"manifest,omitempty"`
+	Undone     bool            `json:"undone,omitempty"`
+}
+
+// journalMu serializes journal writes from concurrent operations.
+var journalMu sync.Mutex
+
+// journalPath is next to the tour marker, so undo works across sessions and
+// for actions of headless commands such as prune.
+func journalPath() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "local-container-registry", "journal.jsonl")
+}
+
+func readJournal() []journalEntry {
+	file, err := os.Open(journalPath())
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	var entries []journalEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry journalEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func writeJournal(entries []journalEntry) error {
+	if len(entries) > maxJournalEntries {
+		entries = entries[len(entries)-maxJournalEntries:]
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	path := journalPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Deleted deployments may carry secrets in their env
+	return os.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// journal records an action for undo. Failing to record doesn't fail the
+// action, it only makes it impossible to undo.
+func journal(entry journalEntry) {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	if journalPath() == "" {
+		return
+	}
+	entry.Time = time.Now()
+	// Promotions name the cluster they rolled, which needn't be the current one
+	if entry.Kind != journalDeleteTag && entry.Kind != journalRetag && entry.Cluster == "" {
+		entry.Cluster = currentCluster().displayName()
+	}
+	if err := writeJournal(append(readJournal(), entry)); err != nil {
+		log.Printf("Failed to journal %s of %s: %v", entry.Kind, entry.Name, err)
+	}
+}
+
+// undoLast inverts the most recent action that hasn't been undone and
+// returns a description of what it did.
+func undoLast(ctx context.Context) (string, error) {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+
+	entries := readJournal()
+	last := -1
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !entries[i].Undone {
+			last = i
+			break
+		}
+	}
+	if last < 0 {
+		return "", fmt.Errorf("nothing to undo")
+	}
+
+	entry := entries[last]
+	if cluster := currentCluster().displayName(); entry.Cluster != "" && entry.Cluster != cluster {
+		return "", fmt.Errorf("the last action was on cluster %s, not %s; switch to it with K to undo it", entry.Cluster, cluster)
+	}
+	description, err := undoEntry(ctx, entry)
+	if err != nil {
+		return "", err
+	}
+	entries[last].Undone = true
+	if err := writeJournal(entries); err != nil {
+		log.Printf("Failed to mark %s of %s undone: %v", entry.Kind, entry.Name, err)
+	}
+	return description, nil
+}
+
+func undoEntry(ctx context.Context, entry journalEntry) (string, error) {
+	if entry.Kind == journalDeleteTag {
+		// The registry only accepts the manifest while its layers are still
+		// there, i.e. until the next garbage collection
+		client := newRegistryClient(ctx, entry.Registry)
+		if err := client.PutManifest(ctx, entry.Repository, entry.Tag, entry.MediaType, entry.Manifest); err != nil {
+			return "", fmt.Errorf("failed to restore %s (its layers may have been garbage-collected): %v", entry.Name, err)
+		}
+		return fmt.Sprintf("Restored tag %s", entry.Name), nil
+	}
+	if entry.Kind == journalRetag {
+		client := newRegistryClient(ctx, entry.Registry)
+		if err := client.PutManifest(ctx, entry.Repository, entry.Tag, entry.MediaType, entry.Manifest); err != nil {
+			return "", fmt.Errorf("failed to move %s back: %v", entry.Name, err)
+		}
+		return fmt.Sprintf("Moved tag %s back to its previous image", entry.Name), nil
+	}
+	if entry.Kind == journalHelmUpgrade {
+		config, err := newHelmConfig(entry.Namespace)
+		if err != nil {
+			return "", err
+		}
+		rollback := action.NewRollback(config)
+		rollback.Version = entry.Revision
+		if err := rollback.Run(entry.Name); err != nil {
+			return "", fmt.Errorf("helm rollback of %s failed: %v", entry.Name, err)
+		}
+		return fmt.Sprintf("Rolled Helm release %s back to revision %d", entry.Name, entry.Revision), nil
+	}
+
+	cluster := newCluster(ctx)
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	switch entry.Kind {
+	case journalDeploy:
+		deployment, err := cluster.Deployment(ctx, entry.Namespace, entry.Name)
+		if err != nil {
+			return "", fmt.Errorf("error getting deployment %s: %v", entry.Name, err)
+		}
+		// Entries from before containers were recorded are of the first one
+		restored, err := kube.WithImage(deployment, entry.Container, entry.Image, corev1.PullPolicy(entry.PullPolicy))
+		if err != nil {
+			return "", err
+		}
+		if entry.Env != nil {
+			if restored, err = kube.WithEnv(restored, entry.Container, entry.Env.Env, entry.Env.EnvFrom); err != nil {
+				return "", err
+			}
+		}
+		if _, err := cluster.UpdateDeployment(ctx, restored); err != nil {
+			return "", fmt.Errorf("error updating deployment %s: %v", entry.Name, err)
+		}
+		return fmt.Sprintf("Restored %s/%s to %s", entry.Namespace, entry.Name, entry.Image), nil
+
+	case journalCreateDeployment:
+		if err := cluster.DeleteDeployment(ctx, entry.Namespace, entry.Name, ""); err != nil && !apierrors.IsNotFound(err) {
+			return "", fmt.Errorf("failed to delete deployment %s: %v", entry.Name, err)
+		}
+		if entry.Service {
+			if err := cluster.DeleteService(ctx, entry.Namespace, entry.Name); err != nil && !apierrors.IsNotFound(err) {
+				return "", fmt.Errorf("failed to delete service %s: %v", entry.Name, err)
+			}
+		}
+		if entry.Ingress {
+			if err := cluster.DeleteIngress(ctx, entry.Namespace, entry.Name); err != nil && !apierrors.IsNotFound(err) {
+				return "", fmt.Errorf("failed to delete ingress %s: %v", entry.Name, err)
+			}
+		}
+		return fmt.Sprintf("Removed new deployment %s/%s", entry.Namespace, entry.Name), nil
+
+	case journalDeleteDeployment:
+		var deployment appsv1.Deployment
+		if err := json.Unmarshal(entry.Deployment, &deployment); err != nil {
+			return "", fmt.Errorf("saved spec of %s is unreadable: %v", entry.Name, err)
+		}
+		// Server-populated fields would make the create fail
+		deployment.ObjectMeta = metav1.ObjectMeta{
+			Name:        deployment.Name,
+			Namespace:   deployment.Namespace,
+			Labels:      deployment.Labels,
+			Annotations: deployment.Annotations,
+		}
+		deployment.Status = appsv1.DeploymentStatus{}
+		if _, err := cluster.CreateDeployment(ctx, &deployment); err != nil {
+			return "", fmt.Errorf("failed to re-create deployment %s: %v", entry.Name, err)
+		}
+		return fmt.Sprintf("Re-created deployment %s/%s", entry.Namespace, entry.Name), nil
+	}
+	return "", fmt.Errorf("don't know how to undo %s", entry.Kind)
+}
+
+// deleteDeployment deletes a deployment after saving it to the journal, so
+// undo can re-create it.
+func deleteDeployment(ctx context.Context, name, namespace string) error {
+	cluster := newCluster(ctx)
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	deployment, err := cluster.Delete(ctx, namespace, name)
+	if err != nil {
+		return err
+	}
+	saved, err := json.Marshal(deployment)
+	if err != nil {
+		return err
+	}
+	journal(journalEntry{Kind: journalDeleteDeployment, Namespace: namespace, Name: name, Deployment: saved})
+	return nil
+}
+
+type undoMsg struct {
+	description string
+	err         error
+}
+
+type deploymentDeletedMsg struct {
+	deployment string
+	err        error
+}
+
+func (m *model) undoLastAction() tea.Cmd {
+	recordSessionStep(sessionStep{Action: sessionUndo})
+	historyCtx := m.ctx
+	return m.startOperation("undo", "last action", func(ctx context.Context) tea.Msg {
+		description, err := undoLast(ctx)
+		recordActionResult(withSpanOf(historyCtx, ctx), "undo", "last action", err, description)
+		return undoMsg{description: description, err: err}
+	})
+}
+
+func (m *model) deleteDeployment(name, namespace string) tea.Cmd {
+	recordSessionStep(sessionStep{Action: sessionDeleteDeployment, Deployment: name, Namespace: namespace})
+	historyCtx := m.ctx
+	return m.startOperation("delete deployment", name, func(ctx context.Context) tea.Msg {
+		err := deleteDeployment(ctx, name, namespace)
+		recordActionResult(withSpanOf(historyCtx, ctx), "delete-deployment", namespace+"/"+name, err, "")
+		return deploymentDeletedMsg{deployment: name, err: err}
+	})
+}
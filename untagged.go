@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/registry"
+)
+
+// untaggedManifest is a manifest that still exists in the registry but that
+// no tag reaches, e.g. the previous image after a tag was pushed again.
+type untaggedManifest struct {
+	repository  string
+	digest      string
+	mediaType   string
+	reclaimable int64 // bytes of blobs no tagged manifest shares
+}
+
+// findUntaggedManifests returns the untagged manifests of a repository.
+//
+// The distribution API can't enumerate manifests, only tags, so candidates
+// are the digests this client has seen before (every tag lookup is recorded
+// in the registry cache) plus referrers of those digests. A candidate is
+// untagged when the registry still has it and it isn't reachable from a
+// current tag, either directly, as a child of a tagged index, or as a
+// referrer of a tagged image.
+func findUntaggedManifests(ctx context.Context, client registryClient, repository string) ([]untaggedManifest, error) {
+	tags, err := client.Tags(ctx, repository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %v", err)
+	}
+
+	reachable := make(map[string]bool)
+	taggedBlobs := make(map[string]bool)
+	var walk func(digest string)
+	walk = func(digest string) {
+		if reachable[digest] {
+			return
+		}
+		reachable[digest] = true
+		body, _, err := client.Manifest(ctx, repository, digest)
+		if err != nil {
+			return
+		}
+		manifest := parseRegistryManifest(body)
+		taggedBlobs[manifest.Config.Digest] = true
+		for _, layer := range manifest.Layers {
+			taggedBlobs[layer.Digest] = true
+		}
+		for _, child := range manifest.Manifests {
+			walk(child.Digest)
+		}
+		referrers, _ := client.Referrers(ctx, repository, digest)
+		for _, referrer := range referrers {
+			walk(referrer.Digest)
+		}
+	}
+	for _, tag := range tags {
+		body, _, err := client.Manifest(ctx, repository, tag)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get manifest for tag %s: %v", tag, err)
+		}
+		walk(registry.ComputeDigest(body))
+	}
+
+	// Referrers of an untagged manifest are dangling as well
+	candidates := client.KnownDigests(repository)
+	seen := make(map[string]bool)
+	countedBlobs := make(map[string]bool)
+	var untagged []untaggedManifest
+	for i := 0; i < len(candidates); i++ {
+		digest := candidates[i]
+		if reachable[digest] || seen[digest] {
+			continue
+		}
+		seen[digest] = true
+
+		exists, err := client.ManifestExists(ctx, repository, digest)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+
+		body, mediaType, err := client.Manifest(ctx, repository, digest)
+		if err != nil {
+			return nil, err
+		}
+		manifest := parseRegistryManifest(body)
+		entry := untaggedManifest{repository: repository, digest: digest, mediaType: mediaType}
+		for _, blob := range append([]registryDescriptor{manifest.Config}, manifest.Layers...) {
+			if blob.Digest == "" || taggedBlobs[blob.Digest] || countedBlobs[blob.Digest] {
+				continue
+			}
+			countedBlobs[blob.Digest] = true
+			entry.reclaimable += blob.Size
+		}
+		untagged = append(untagged, entry)
+
+		for _, child := range manifest.Manifests {
+			candidates = append(candidates, child.Digest)
+		}
+		referrers, _ := client.Referrers(ctx, repository, digest)
+		for _, referrer := range referrers {
+			candidates = append(candidates, referrer.Digest)
+		}
+	}
+
+	sort.Slice(untagged, func(i, j int) bool { return untagged[i].digest < untagged[j].digest })
+	return untagged, nil
+}
+
+func parseRegistryManifest(body []byte) registryManifest {
+	var manifest registryManifest
+	json.Unmarshal(body, &manifest)
+	return manifest
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// imageUsage maps image references used by the cluster to the workloads
+// using them. References are keyed both by repository:tag (without the
+// registry host, since the cluster often pulls the same registry under a
+// different name) and by digest where the kubelet reported one.
+type imageUsage map[string][]string
+
+// getClusterImageUsage collects the images of all pods and deployments.
+// Deployments are included so an image stays "in use" while scaled to zero.
+func getClusterImageUsage(ctx context.Context) (imageUsage, error) {
+	cluster := newCluster(ctx)
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	pods, err := cluster.ListPods(ctx, metav1.NamespaceAll, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %v", err)
+	}
+	deployments, err := cluster.Deployments(ctx, metav1.NamespaceAll)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %v", err)
+	}
+
+	usage := make(imageUsage)
+	for _, pod := range pods {
+		user := podWorkload(pod)
+		usage.addContainers(user, pod.Spec.InitContainers, pod.Spec.Containers)
+		statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			// imageID is e.g. docker-pullable://localhost:5000/web@sha256:...
+			if _, digest, ok := strings.Cut(status.ImageID, "@"); ok {
+				usage.add(digest, user)
+			}
+		}
+	}
+	for _, deployment := range deployments {
+		user := fmt.Sprintf("deployment/%s/%s", deployment.Namespace, deployment.Name)
+		usage.addContainers(user, deployment.Spec.Template.Spec.InitContainers, deployment.Spec.Template.Spec.Containers)
+	}
+	return usage, nil
+}
+
+// podWorkload names the workload a pod belongs to: its deployment when a
+// deployment's ReplicaSet made it, so each replica and the deployment itself
+// count as one user of an image, and the pod otherwise.
+func podWorkload(pod corev1.Pod) string {
+	for _, owner := range pod.OwnerReferences {
+		hash := pod.Labels["pod-template-hash"]
+		if owner.Kind == "ReplicaSet" && hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+			return fmt.Sprintf("deployment/%s/%s", pod.Namespace, strings.TrimSuffix(owner.Name, "-"+hash))
+		}
+	}
+	return fmt.Sprintf("pod/%s/%s", pod.Namespace, pod.Name)
+}
+
+func (u imageUsage) addContainers(user string, containerLists ...[]corev1.Container) {
+	for _, containers := range containerLists {
+		for _, container := range containers {
+			name, digest := normalizeImageReference(container.Image)
+			if digest != "" {
+				u.add(digest, user)
+			} else {
+				u.add(name, user)
+			}
+		}
+	}
+}
+
+func (u imageUsage) add(key, user string) {
+	for _, existing := range u[key] {
+		if existing == user {
+			return
+		}
+	}
+	u[key] = append(u[key], user)
+}
+
+// users returns the workloads using an image by tag or by digest.
+func (u imageUsage) users(imageTag, digest string) []string {
+	name, _ := normalizeImageReference(imageTag)
+	users := append([]string(nil), u[name]...)
+	if digest != "" {
+		for _, user := range u[digest] {
+			found := false
+			for _, existing := range users {
+				found = found || existing == user
+			}
+			if !found {
+				users = append(users, user)
+			}
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// normalizeImageReference reduces an image reference to repository:tag with
+// the registry host and Docker Hub's library/ prefix removed, plus the digest
+// if the reference is pinned. "localhost:5000/web" and "registry:5000/web:latest"
+// both become "web:latest".
+func normalizeImageReference(ref string) (string, string) {
+	name, digest, _ := strings.Cut(ref, "@")
+
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		name = rest
+	}
+	name = strings.TrimPrefix(name, "library/")
+
+	// A colon after the last slash separates the tag
+	if strings.LastIndex(name, ":") <= strings.LastIndex(name, "/") {
+		name += ":latest"
+	}
+	return name, digest
+}
+
+// markImagesInUse fills the InUse column. When the cluster can't be reached
+// the column says so rather than claiming every image is unused.
+func markImagesInUse(ctx context.Context, data []TableData) []TableData {
+	if !kubernetesEnabled() {
+		return data
+	}
+	usage, err := getClusterImageUsage(ctx)
+	for i := range data {
+		if data[i].ImageTag == "" || data[i].ImageTag == "N/A" {
+			continue
+		}
+		// Charts and signatures don't run in pods
+		if data[i].ArtifactType != "" {
+			data[i].InUse = "-"
+			continue
+		}
+		switch users := usage.users(data[i].ImageTag, data[i].ImageDigest); {
+		case err != nil:
+			data[i].InUse = "Unknown"
+		case len(users) == 0:
+			data[i].InUse = "No"
+		case len(users) == 1:
+			data[i].InUse = users[0][strings.LastIndex(users[0], "/")+1:]
+		default:
+			data[i].InUse = fmt.Sprintf("%d workloads", len(users))
+		}
+	}
+	return data
+}
+
+// isDangling reports images the cluster definitely doesn't reference, which
+// makes them candidates for deletion or pruning.
+func isDangling(item TableData) bool {
+	return item.InUse == "No"
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+// Accounts for the REST API of serve. Passwords are kept as bcrypt hashes
+// and API tokens as their SHA-256, so the database alone grants no access.
+// Tokens made with "user token" last until revoked; those handed out by
+// logging in expire after API_SESSION_TTL. What a user may do is up to
+// their role, see roles.go.
+
+type User struct {
+	ID           int    `json:"id"`
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
+	PasswordHash string `json:"-"`
+}
+
+// minPasswordLength is the shortest password accounts may have.
+const minPasswordLength = 8
+
+// apiTokenPrefix starts every API token, so leaked ones are easy to scan for.
+const apiTokenPrefix = "lcr_"
+
+var errInvalidCredentials = errors.New("invalid username, password or token")
+
+// sessionTTL is how long a token from logging in is valid: API_SESSION_TTL,
+// default 12h.
+func sessionTTL() time.Duration {
+	if value, err := time.ParseDuration(os.Getenv("API_SESSION_TTL")); err == nil && value > 0 {
+		return value
+	}
+	return 12 * time.Hour
+}
+
+func createUser(ctx context.Context, username, email, password, role string) error {
+	if username == "" || strings.ContainsAny(username, " :/") {
+		return fmt.Errorf("invalid username %q", username)
+	}
+	if err := validateRole(role); err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	dbCtx, cancel := withBackendTimeout(ctx, backendDatabase)
+	defer cancel()
+	if _, err := db.ExecContext(dbCtx, "INSERT INTO users (username, email, password_hash, role) VALUES (?, ?, ?, ?)", username, email, hash, role); err != nil {
+		return fmt.Errorf("failed to create user %s: %v", username, err)
+	}
+	return nil
+}
+
+func setUserPassword(ctx context.Context, username, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	dbCtx, cancel := withBackendTimeout(ctx, backendDatabase)
+	defer cancel()
+	result, err := db.ExecContext(dbCtx, "UPDATE users SET password_hash = ? WHERE username = ?", hash, username)
+	if err != nil {
+		return fmt.Errorf("failed to set the password of %s: %v", username, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("no user %s", username)
+	}
+	return nil
+}
+
+func setUserRole(ctx context.Context, username, role string) error {
+	if err := validateRole(role); err != nil {
+		return err
+	}
+	dbCtx, cancel := withBackendTimeout(ctx, backendDatabase)
+	defer cancel()
+	result, err := db.ExecContext(dbCtx, "UPDATE users SET role = ? WHERE username = ?", role, username)
+	if err != nil {
+		return fmt.Errorf("failed to set the role of %s: %v", username, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		if _, err := findUser(ctx, username); err != nil {
+			return fmt.Errorf("no user %s", username)
+		}
+	}
+	return nil
+}
+
+// deleteUser removes the account and, through the foreign key, its tokens.
+func deleteUser(ctx context.Context, username string) error {
+	dbCtx, cancel := withBackendTimeout(ctx, backendDatabase)
+	defer cancel()
+	result, err := db.ExecContext(dbCtx, "DELETE FROM users WHERE username = ?", username)
+	if err != nil {
+		return fmt.Errorf("failed to delete user %s: %v", username, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("no user %s", username)
+	}
+	return nil
+}
+
+func listUsers(ctx context.Context) ([]User, error) {
+	dbCtx, cancel := withBackendTimeout(ctx, backendDatabase)
+	defer cancel()
+	rows, err := db.QueryContext(dbCtx, "SELECT id, username, email, role FROM users ORDER BY username")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %v", err)
+	}
+	defer rows.Close()
+	var users []User
+	for rows.Next() {
+		var user User
+		var email sql.NullString
+		if err := rows.Scan(&user.ID, &user.Username, &email, &user.Role); err != nil {
+			return nil, fmt.Errorf("failed to list users: %v", err)
+		}
+		user.Email = email.String
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func findUser(ctx context.Context, username string) (User, error) {
+	dbCtx, cancel := withBackendTimeout(ctx, backendDatabase)
+	defer cancel()
+	var user User
+	var email sql.NullString
+	err := db.QueryRowContext(dbCtx, "SELECT id, username, email, role, password_hash FROM users WHERE username = ?", username).
+		Scan(&user.ID, &user.Username, &email, &user.Role, &user.PasswordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, errInvalidCredentials
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("failed to look up user %s: %v", username, err)
+	}
+	user.Email = email.String
+	return user, nil
+}
+
+// dummyPasswordHash is compared against for unknown usernames, so they take
+// as long to refuse as a wrong password and don't give away which exist.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	random := make([]byte, 20)
+	rand.Read(random)
+	hash, _ := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(random)), bcrypt.DefaultCost)
+	return hash
+})
+
+// authenticatePassword is the user with this username and password, asked
+// for from client, e.g. r.RemoteAddr. After too many failures for the
+// client or the username it's errTooManyLogins, whatever the password.
+func authenticatePassword(ctx context.Context, client, username, password string) (User, error) {
+	keys := loginThrottleKeys(client, username)
+	if wait := failedLogins.blocked(keys); wait > 0 {
+		return User{}, fmt.Errorf("%w, try again in %s", errTooManyLogins, wait.Round(time.Second))
+	}
+	user, err := findUser(ctx, username)
+	if errors.Is(err, errInvalidCredentials) {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		failedLogins.fail(keys)
+		return User{}, err
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		failedLogins.fail(keys)
+		return User{}, errInvalidCredentials
+	}
+	failedLogins.reset(keys)
+	return user, nil
+}
+
+var errTooManyLogins = errors.New("too many failed logins")
+
+const (
+	// maxFailedLogins is how many passwords a client, or a username, may get
+	// wrong within loginThrottleWindow before it's refused.
+	maxFailedLogins     = 5
+	loginThrottleWindow = 15 * time.Minute
+)
+
+// loginThrottleKeys are what failed logins are counted by: the client's
+// address without its port, and the username.
+func loginThrottleKeys(client, username string) []string {
+	if host, _, err := net.SplitHostPort(client); err == nil {
+		client = host
+	}
+	return []string{"client:" + client, "user:" + username}
+}
+
+// loginThrottle counts the failed logins of each key, from its first
+// failure on until loginThrottleWindow later.
+type loginThrottle struct {
+	sync.Mutex
+	failures map[string]failedLogin
+}
+
+type failedLogin struct {
+	count int
+	first time.Time
+}
+
+var failedLogins = loginThrottle{failures: make(map[string]failedLogin)}
+
+// blocked is how long until one of keys may try again, 0 when all may.
+func (t *loginThrottle) blocked(keys []string) time.Duration {
+	t.Lock()
+	defer t.Unlock()
+	var wait time.Duration
+	for _, key := range keys {
+		failure, ok := t.failures[key]
+		if !ok {
+			continue
+		}
+		remaining := time.Until(failure.first.Add(loginThrottleWindow))
+		if remaining <= 0 {
+			delete(t.failures, key)
+			continue
+		}
+		if failure.count >= maxFailedLogins {
+			wait = max(wait, remaining)
+		}
+	}
+	return wait
+}
+
+func (t *loginThrottle) fail(keys []string) {
+	t.Lock()
+	defer t.Unlock()
+	now := time.Now()
+	for _, key := range keys {
+		failure := t.failures[key]
+		if now.Sub(failure.first) > loginThrottleWindow {
+			failure = failedLogin{first: now}
+		}
+		failure.count++
+		t.failures[key] = failure
+	}
+	// Clients that gave up would otherwise stay until they try again
+	for key, failure := range t.failures {
+		if now.Sub(failure.first) > loginThrottleWindow {
+			delete(t.failures, key)
+		}
+	}
+}
+
+// reset forgets the failures of the username of a login that succeeded.
+// The client's still count, so logging in to one account doesn't buy
+// more guesses at others.
+func (t *loginThrottle) reset(keys []string) {
+	t.Lock()
+	defer t.Unlock()
+	for _, key := range keys {
+		if strings.HasPrefix(key, "user:") {
+			delete(t.failures, key)
+		}
+	}
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("passwords need at least %d characters", minPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createAPIToken makes a token for user, valid for ttl or, when 0, until
+// revoked. Only its hash is stored: the token can't be shown again.
+func createAPIToken(ctx context.Context, user User, name string, ttl time.Duration) (string, error) {
+	random := make([]byte, 20)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	token := apiTokenPrefix + hex.EncodeToString(random)
+	var expires *time.Time
+	if ttl > 0 {
+		at := time.Now().Add(ttl).UTC()
+		expires = &at
+	}
+	dbCtx, cancel := withBackendTimeout(ctx, backendDatabase)
+	defer cancel()
+	if _, err := db.ExecContext(dbCtx, "INSERT INTO api_tokens (user_id, name, token_hash, expires_at) VALUES (?, ?, ?, ?)",
+		user.ID, name, hashToken(token), expires); err != nil {
+		return "", fmt.Errorf("failed to create a token for %s: %v", user.Username, err)
+	}
+	return token, nil
+}
+
+// authenticateToken is the user an unexpired token belongs to.
+func authenticateToken(ctx context.Context, token string) (User, error) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return User{}, errInvalidCredentials
+	}
+	dbCtx, cancel := withBackendTimeout(ctx, backendDatabase)
+	defer cancel()
+	var user User
+	var email sql.NullString
+	var tokenID int
+	err := db.QueryRowContext(dbCtx, "SELECT u.id, u.username, u.email, u.role, t.id FROM api_tokens t JOIN users u ON u.id = t.user_id "+
+		"WHERE t.token_hash = ? AND (t.expires_at IS NULL OR t.expires_at > UTC_TIMESTAMP())", hashToken(token)).
+		Scan(&user.ID, &user.Username, &email, &user.Role, &tokenID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, errInvalidCredentials
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("failed to check token: %v", err)
+	}
+	user.Email = email.String
+	db.ExecContext(dbCtx, "UPDATE api_tokens SET last_used = CURRENT_TIMESTAMP WHERE id = ?", tokenID)
+	return user, nil
+}
+
+// revokeAPITokens deletes the user's tokens, or only those with this name.
+func revokeAPITokens(ctx context.Context, username, name string) (int64, error) {
+	query := "DELETE t FROM api_tokens t JOIN users u ON u.id = t.user_id WHERE u.username = ?"
+	args := []any{username}
+	if name != "" {
+		query += " AND t.name = ?"
+		args = append(args, name)
+	}
+	dbCtx, cancel := withBackendTimeout(ctx, backendDatabase)
+	defer cancel()
+	result, err := db.ExecContext(dbCtx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke tokens of %s: %v", username, err)
+	}
+	return result.RowsAffected()
+}
+
+// requireDatabase connects the db handle for commands that can't do without
+// it, unlike connectDatabase's best-effort history.
+func requireDatabase(ctx context.Context) error {
+	handle, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	pingCtx, cancel := withBackendTimeout(ctx, backendDatabase)
+	err = handle.PingContext(pingCtx)
+	cancel()
+	if err != nil {
+		handle.Close()
+		return fmt.Errorf("database unavailable: %v", err)
+	}
+	db = handle
+	closeDatabaseOnShutdown()
+	return ensureSchema(ctx)
+}
+
+// readPassword prompts for a password without echoing it, or reads the
+// first line of stdin when it isn't a terminal.
+func readPassword(prompt string, confirm bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read the password from stdin: %v", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Repeat it: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(again) != string(password) {
+			return "", fmt.Errorf("passwords don't match")
+		}
+	}
+	return string(password), nil
+}
+
+func runUser(args []string) error {
+	usage := fmt.Errorf("usage: user add|passwd|role|delete|list|token|revoke [flags] [username] [role]")
+	if len(args) == 0 {
+		return usage
+	}
+	fs := flag.NewFlagSet("user "+args[0], flag.ContinueOnError)
+	email := fs.String("email", "", "email address of the new user (add)")
+	role := fs.String("role", roleViewer, "role of the new user: "+strings.Join(roles, ", ")+" (add)")
+	name := fs.String("name", "", `name of the token, default "cli" (token), or the only tokens to revoke (revoke)`)
+	expires := fs.Duration("expires", 0, "lifetime of the token, e.g. 720h; 0 lasts until revoked (token)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	username := fs.Arg(0)
+	if args[0] != "list" && username == "" {
+		return usage
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	if err := requireDatabase(ctx); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "add":
+		password, err := readPassword(fmt.Sprintf("Password for %s: ", username), true)
+		if err != nil {
+			return err
+		}
+		if err := createUser(ctx, username, *email, password, *role); err != nil {
+			return err
+		}
+		recordHistory(ctx, "user-add", username, "succeeded", "role "+*role)
+		fmt.Printf("✅ Created user %s, a %s\n", username, *role)
+	case "passwd":
+		password, err := readPassword(fmt.Sprintf("New password for %s: ", username), true)
+		if err != nil {
+			return err
+		}
+		if err := setUserPassword(ctx, username, password); err != nil {
+			return err
+		}
+		recordHistory(ctx, "user-passwd", username, "succeeded", "")
+		fmt.Printf("✅ Changed the password of %s\n", username)
+	case "role":
+		if fs.NArg() < 2 {
+			return usage
+		}
+		if err := setUserRole(ctx, username, fs.Arg(1)); err != nil {
+			return err
+		}
+		recordHistory(ctx, "user-role", username, "succeeded", "role "+fs.Arg(1))
+		fmt.Printf("✅ %s is now a %s\n", username, fs.Arg(1))
+	case "delete":
+		if err := deleteUser(ctx, username); err != nil {
+			return err
+		}
+		recordHistory(ctx, "user-delete", username, "succeeded", "")
+		fmt.Printf("🗑️  Deleted user %s and their tokens\n", username)
+	case "list":
+		users, err := listUsers(ctx)
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			fmt.Println("No users yet; add one with: user add <username>")
+		}
+		for _, user := range users {
+			fmt.Printf("%-24s %-9s %s\n", user.Username, user.Role, user.Email)
+		}
+	case "token":
+		user, err := findUser(ctx, username)
+		if err != nil {
+			return fmt.Errorf("no user %s", username)
+		}
+		tokenName := cmp.Or(*name, "cli")
+		token, err := createAPIToken(ctx, user, tokenName, *expires)
+		if err != nil {
+			return err
+		}
+		recordHistory(ctx, "token-create", username, "succeeded", "token "+tokenName)
+		fmt.Printf("🔑 Token %q for %s, shown only this once:\n%s\n", tokenName, username, token)
+	case "revoke":
+		revoked, err := revokeAPITokens(ctx, username, *name)
+		if err != nil {
+			return err
+		}
+		recordHistory(ctx, "token-revoke", username, "succeeded", fmt.Sprintf("%d tokens", revoked))
+		fmt.Printf("🗑️  Revoked %d tokens of %s\n", revoked, username)
+	default:
+		return usage
+	}
+	return nil
+}
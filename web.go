@@ -0,0 +1,33 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// The web dashboard of serve --web, for teammates who won't use the TUI. It
+// is a static page talking only to /api/v1, so it can do no more than the
+// signed-in user's role allows there.
+
+//go:embed web
+var webAssets embed.FS
+
+// registerWebRoutes serves the dashboard at /, below every other route.
+func registerWebRoutes(mux *http.ServeMux) {
+	assets, err := fs.Sub(webAssets, "web")
+	if err != nil {
+		panic(err)
+	}
+	mux.Handle("/", http.FileServerFS(assets))
+}
+
+// dashboardHost is where the dashboard listening on addr is reached from
+// this machine.
+func dashboardHost(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
+}
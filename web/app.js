@@ -0,0 +1,326 @@
+// Dashboard of serve --web. Everything goes through the REST API with the
+// token from signing in, kept for the browser tab's session only, and the
+// tab shown reloads as the server's event stream reports changes.
+
+const roles = ["viewer", "deployer", "admin"];
+const $ = (selector) => document.querySelector(selector);
+
+let user = null;
+let tab = "images";
+let stream = null;
+
+// The tabs each kind of event on /api/v1/events updates
+const eventTabs = {
+  images: ["images"],
+  pods: ["pods", "deployments"],
+  commits: ["commits"],
+  history: ["history"],
+};
+
+function may(role) {
+  return user && roles.indexOf(user.role) >= roles.indexOf(role);
+}
+
+async function api(method, path, body) {
+  const response = await fetch("/api/v1" + path, {
+    method,
+    headers: {
+      "Authorization": "Bearer " + sessionStorage.getItem("token"),
+      "Content-Type": "application/json",
+    },
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+  if (response.status === 401) {
+    signOut();
+    throw new Error("Your session expired, sign in again");
+  }
+  const result = await response.json();
+  if (!response.ok) {
+    throw new Error(result.error);
+  }
+  return result;
+}
+
+function setStatus(message, error) {
+  $("#status").textContent = message;
+  $("#status").className = error ? "error" : "";
+}
+
+function cell(row, value, code) {
+  const td = row.insertCell();
+  if (code) {
+    const element = document.createElement("code");
+    element.textContent = value ?? "";
+    td.append(element);
+  } else {
+    td.textContent = value ?? "";
+  }
+  return td;
+}
+
+function button(td, label, role, action) {
+  const element = document.createElement("button");
+  element.type = "button";
+  element.textContent = label;
+  if (!may(role)) {
+    element.disabled = true;
+    element.title = "Needs the " + role + " role";
+  }
+  element.addEventListener("click", action);
+  td.append(element);
+}
+
+function render(columns, rows, fill) {
+  const head = $("#table thead");
+  const body = $("#table tbody");
+  head.replaceChildren();
+  body.replaceChildren();
+  const header = head.insertRow();
+  for (const column of columns) {
+    const th = document.createElement("th");
+    th.textContent = column;
+    header.append(th);
+  }
+  for (const item of rows) {
+    fill(body.insertRow(), item);
+  }
+}
+
+const tabs = {
+  async images() {
+    const images = await api("GET", "/images");
+    render(["Image", "Digest", "Same As", "Size", "Created", ""], images, (row, image) => {
+      cell(row, image.reference, true);
+      cell(row, (image.digest || "").slice(0, 19), true);
+      cell(row, (image.aliases || []).join(", "));
+      cell(row, image.size);
+      cell(row, image.created);
+      const actions = cell(row, "");
+      if (!image.artifact) {
+        button(actions, "Deploy", "deployer", () => openDeploy(image.reference));
+      }
+      button(actions, "Delete", "admin", () => deleteImage(image.reference, image.aliases || []));
+    });
+    return images.length + " images";
+  },
+  async pods() {
+    const pods = await api("GET", "/pods");
+    render(["Pod", "Namespace", "Status", "Restarts", "Age", "Node"], pods, (row, pod) => {
+      cell(row, pod.name);
+      cell(row, pod.namespace);
+      cell(row, pod.status);
+      cell(row, pod.restarts);
+      cell(row, pod.age);
+      cell(row, pod.node);
+    });
+    return pods.length + " pods";
+  },
+  async deployments() {
+    const deployments = await api("GET", "/deployments");
+    render(["Deployment", "Namespace", "Status", "Ready", "Images", ""], deployments, (row, deployment) => {
+      cell(row, deployment.name);
+      cell(row, deployment.namespace);
+      cell(row, deployment.status);
+      cell(row, deployment.ready);
+      cell(row, deployment.images, true);
+      button(cell(row, ""), "Restart", "deployer", () => restart(deployment));
+    });
+    return deployments.length + " deployments";
+  },
+  async commits() {
+    const page = await api("GET", "/commits");
+    render(["Commit", "Description", "Author", "Pushed"], page.commits, (row, commit) => {
+      cell(row, commit.sha.slice(0, 7), true);
+      cell(row, commit.description);
+      cell(row, commit.author || "");
+      cell(row, commit.pushed_at);
+    });
+    return page.commits.length + " latest commits";
+  },
+  async history() {
+    const entries = await api("GET", "/history");
+    render(["Time", "User", "Action", "Target", "Status", "Details"], entries, (row, entry) => {
+      cell(row, new Date(entry.time).toLocaleString());
+      cell(row, entry.user);
+      cell(row, entry.action);
+      cell(row, entry.target, true);
+      cell(row, entry.status);
+      cell(row, entry.details);
+    });
+    return entries.length + " latest actions";
+  },
+};
+
+async function load() {
+  setStatus("Loading " + tab + "...");
+  try {
+    setStatus(await tabs[tab]());
+  } catch (error) {
+    setStatus("⚠️ " + error.message, true);
+  }
+}
+
+async function openDeploy(reference) {
+  $("#deploy-image").textContent = reference;
+  const select = $("#deploy-target");
+  select.replaceChildren();
+  try {
+    for (const deployment of await api("GET", "/deployments")) {
+      const option = document.createElement("option");
+      option.value = deployment.namespace + "/" + deployment.name;
+      option.textContent = option.value + " (" + deployment.images + ")";
+      select.append(option);
+    }
+  } catch (error) {
+    setStatus("⚠️ " + error.message, true);
+    return;
+  }
+  $("#deploy").dataset.image = reference;
+  $("#deploy").showModal();
+}
+
+async function deploy(target, image) {
+  setStatus("Deploying " + image + " to " + target + "...");
+  try {
+    await api("PUT", "/deployments/" + target + "/image", { image });
+    setStatus("✅ Deployed " + image + " to " + target);
+  } catch (error) {
+    setStatus("❌ Deploy failed: " + error.message, true);
+  }
+}
+
+async function deleteImage(reference, aliases) {
+  let question = "Delete " + reference + "?";
+  if (aliases.length > 0) {
+    question += " It's the same image as " + aliases.join(", ") + ", which go with it.";
+  }
+  if (!confirm(question)) {
+    return;
+  }
+  try {
+    await api("DELETE", "/images/" + reference + (aliases.length > 0 ? "?force=true" : ""));
+    await load();
+    setStatus("🗑️ Deleted " + reference);
+  } catch (error) {
+    setStatus("❌ Delete failed: " + error.message, true);
+  }
+}
+
+async function restart(deployment) {
+  try {
+    await api("POST", "/deployments/" + deployment.namespace + "/" + deployment.name + "/restart");
+    setStatus("🔄 Restarting " + deployment.namespace + "/" + deployment.name);
+  } catch (error) {
+    setStatus("❌ Restart failed: " + error.message, true);
+  }
+}
+
+// watchEvents reloads the tab when the server publishes an update for it.
+// EventSource can't send the token, so the stream is read with fetch.
+async function watchEvents() {
+  stream = new AbortController();
+  try {
+    const response = await fetch("/api/v1/events?kind=images,pods,commits,history", {
+      headers: { "Authorization": "Bearer " + sessionStorage.getItem("token") },
+      signal: stream.signal,
+    });
+    if (response.status === 401) {
+      signOut();
+      return;
+    }
+    const reader = response.body.pipeThrough(new TextDecoderStream()).getReader();
+    let buffer = "";
+    for (;;) {
+      const { value, done } = await reader.read();
+      if (done) {
+        break;
+      }
+      buffer += value;
+      const messages = buffer.split("\n\n");
+      buffer = messages.pop();
+      for (const message of messages) {
+        const kind = message.match(/^event: (.*)$/m);
+        if (kind && (eventTabs[kind[1]] || []).includes(tab)) {
+          load();
+        }
+      }
+    }
+  } catch (error) {
+    if (error.name === "AbortError") {
+      return;
+    }
+  }
+  // Reconnect after the server restarted or the connection dropped
+  if (user) {
+    setTimeout(watchEvents, 5000);
+  }
+}
+
+function showDashboard() {
+  $("#login").hidden = true;
+  $("#session").hidden = false;
+  $("#dashboard").hidden = false;
+  $("#whoami").textContent = user.username + " (" + user.role + ")";
+  load();
+  watchEvents();
+}
+
+function signOut() {
+  sessionStorage.removeItem("token");
+  user = null;
+  if (stream) {
+    stream.abort();
+    stream = null;
+  }
+  $("#session").hidden = true;
+  $("#dashboard").hidden = true;
+  $("#login").hidden = false;
+}
+
+$("#login").addEventListener("submit", async (event) => {
+  event.preventDefault();
+  const form = new FormData(event.target);
+  const response = await fetch("/api/v1/login", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ username: form.get("username"), password: form.get("password") }),
+  });
+  const result = await response.json();
+  if (!response.ok) {
+    $("#login-error").textContent = result.error;
+    return;
+  }
+  $("#login-error").textContent = "";
+  sessionStorage.setItem("token", result.token);
+  user = result.user;
+  event.target.reset();
+  showDashboard();
+});
+
+$("#logout").addEventListener("click", signOut);
+$("#refresh").addEventListener("click", load);
+
+for (const element of document.querySelectorAll("nav button[data-tab]")) {
+  element.addEventListener("click", () => {
+    document.querySelector("nav button.active").classList.remove("active");
+    element.classList.add("active");
+    tab = element.dataset.tab;
+    load();
+  });
+}
+
+$("#deploy").addEventListener("close", () => {
+  if ($("#deploy").returnValue === "deploy") {
+    deploy($("#deploy-target").value, $("#deploy").dataset.image);
+  }
+});
+
+// Pick up the session of an earlier page load in this tab
+if (sessionStorage.getItem("token")) {
+  api("GET", "/me").then((me) => {
+    user = me;
+    showDashboard();
+  }).catch(signOut);
+} else {
+  signOut();
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/go-github/v63/github"
+)
+
+// With GITHUB_WEBHOOK_SECRET set, GitHub can push events to
+// /webhooks/github instead of being polled: serve mounts the endpoint
+// next to its probes, and the TUI listens on GITHUB_WEBHOOK_ADDR. Pushes
+// to the branch the Git tab lists add their commits to the database and
+// the tab as they happen, pull requests set the description of their head
+// commit, and with GITHUB_WEBHOOK_BUILD=true each push is built and pushed
+// to the registry.
+
+const webhookPath = "/webhooks/github"
+
+// maxWebhookPayload is the largest payload GitHub delivers.
+const maxWebhookPayload = 25 << 20
+
+// webhookEvent is what a delivery changed, published on the event bus for
+// the TUI to show and builds to start from.
+type webhookEvent struct {
+	commits []TableData         // pushed to the branch, newest first
+	files   map[string][]string // files each pushed commit touches, by SHA
+	updated []TableData         // commits whose PR description changed
+	build   string              // commit to build and push
+}
+
+type webhookMsg webhookEvent
+
+type webhookBuildMsg struct {
+	image string
+	err   error
+}
+
+func webhookSecret() string {
+	return os.Getenv("GITHUB_WEBHOOK_SECRET")
+}
+
+// webhookReceiverAddr is where the TUI receives webhooks, empty when it
+// doesn't.
+func webhookReceiverAddr() string {
+	if webhookSecret() == "" {
+		return ""
+	}
+	return os.Getenv("GITHUB_WEBHOOK_ADDR")
+}
+
+func webhookBuildEnabled() bool {
+	return os.Getenv("GITHUB_WEBHOOK_BUILD") == "true"
+}
+
+// webhookHandler verifies deliveries against GITHUB_WEBHOOK_SECRET, stores
+// what they carry and publishes it.
+func webhookHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxWebhookPayload)
+		payload, err := github.ValidatePayload(r, []byte(webhookSecret()))
+		if err != nil {
+			log.Printf("rejected webhook from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		event, err := github.ParseWebHook(github.WebHookType(r), payload)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid payload: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		var received webhookEvent
+		switch event := event.(type) {
+		case *github.PingEvent:
+			fmt.Fprintln(w, "pong")
+			return
+		case *github.PushEvent:
+			if !isWebhookRepo(event.GetRepo().GetFullName()) || event.GetRef() != "refs/heads/"+gitBranch || event.GetDeleted() {
+				ignoreWebhook(w, "not a push to "+gitBranch)
+				return
+			}
+			received = pushedCommits(r.Context(), event)
+			if !queueWebhookBuild(received.build) {
+				log.Printf("not building %s: %d builds are queued already", received.build, maxQueuedWebhookBuilds)
+				http.Error(w, "too many builds queued, redeliver later", http.StatusServiceUnavailable)
+				return
+			}
+		case *github.PullRequestEvent:
+			switch event.GetAction() {
+			case "opened", "edited", "reopened", "synchronize":
+			default:
+				ignoreWebhook(w, "pull request "+event.GetAction())
+				return
+			}
+			if !isWebhookRepo(event.GetRepo().GetFullName()) {
+				ignoreWebhook(w, "other repository")
+				return
+			}
+			pr := event.GetPullRequest()
+			description := pullRequestDescription(pr)
+			storePullRequestDescription(r.Context(), pr.GetHead().GetSHA(), description)
+			received.updated = []TableData{{CommitSHA: pr.GetHead().GetSHA(), PRDescription: description}}
+		default:
+			ignoreWebhook(w, github.WebHookType(r)+" events aren't handled")
+			return
+		}
+		bus.publish(busEvent{Kind: eventWebhook, Count: len(received.commits), Data: received})
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+func ignoreWebhook(w http.ResponseWriter, reason string) {
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "ignored: %s\n", reason)
+}
+
+func isWebhookRepo(fullName string) bool {
+	return strings.EqualFold(fullName, os.Getenv("GITHUB_OWNER")+"/"+os.Getenv("GITHUB_REPO"))
+}
+
+// pushedCommits stores the commits of a push and lists them newest first,
+// like the Git tab; the payload has them oldest first.
+func pushedCommits(ctx context.Context, event *github.PushEvent) webhookEvent {
+	received := webhookEvent{files: make(map[string][]string)}
+	for i := len(event.Commits) - 1; i >= 0; i-- {
+		commit := event.Commits[i]
+		storeCommit(ctx, commit.GetID(), commit.GetMessage(), commit.GetAuthor().GetName(), commit.GetCommitter().GetName())
+		received.files[commit.GetID()] = slices.Concat(commit.Added, commit.Removed, commit.Modified)
+		received.commits = append(received.commits, TableData{
+			CommitSHA:     commit.GetID(),
+			PRDescription: commit.GetMessage(),
+			Author:        commit.GetAuthor().GetName(),
+			Committer:     commit.GetCommitter().GetName(),
+			PushedAt:      commit.GetTimestamp().Format("2006-01-02 15:04:05"),
+		})
+	}
+	if webhookBuildEnabled() && event.GetAfter() != "" {
+		received.build = event.GetAfter()
+	}
+	return received
+}
+
+func pullRequestDescription(pr *github.PullRequest) string {
+	description := fmt.Sprintf("#%d %s", pr.GetNumber(), pr.GetTitle())
+	if body := strings.TrimSpace(pr.GetBody()); body != "" {
+		description += "\n\n" + body
+	}
+	return description
+}
+
+// storePullRequestDescription records a pull request's description for its
+// head commit, replacing the commit message stored for it.
+func storePullRequestDescription(ctx context.Context, sha, description string) {
+	if dataStore(ctx) == nil {
+		return
+	}
+	if err := dataStore(ctx).SetPullRequestDescription(ctx, sha, description); err != nil {
+		log.Printf("failed to store the pull request description of %s: %v", sha, err)
+	}
+}
+
+// webhookBuilds runs one build at a time, so a burst of pushes doesn't start
+// a docker build for each at once.
+var webhookBuilds sync.Mutex
+
+// buildAndPushCommit builds commit straight from GitHub with the repository's
+// Dockerfile and pushes it to the registry tagged by IMAGE_TAG_TEMPLATE,
+// sha-<short sha> by default. The image is named GITHUB_WEBHOOK_IMAGE, by
+// default after the repository.
+func buildAndPushCommit(ctx context.Context, sha string) (string, error) {
+	webhookBuilds.Lock()
+	defer webhookBuilds.Unlock()
+
+	owner, repo := os.Getenv("GITHUB_OWNER"), os.Getenv("GITHUB_REPO")
+	tag := imageTagTemplate().tag(gitBranch, sha, time.Now())
+	image := fmt.Sprintf("%s/%s:%s", getRegistryHost(), envOrDefault("GITHUB_WEBHOOK_IMAGE", strings.ToLower(repo)), tag)
+	buildCtx, cancel := withBackendTimeout(ctx, backendDocker)
+	defer cancel()
+
+	args := []string{"build", "-t", image}
+	token := githubToken()
+	if token != "" {
+		// BuildKit clones private repositories with this secret; passed by
+		// name it stays out of the process list
+		args = append(args, "--secret", "id=GIT_AUTH_TOKEN,env=GITHUB_AUTH_TOKEN")
+	}
+	args = append(args, fmt.Sprintf("https://github.com/%s/%s.git#%s", owner, repo, sha))
+	cmd := exec.CommandContext(buildCtx, "docker", args...)
+	if token != "" {
+		cmd.Env = append(os.Environ(), "GITHUB_AUTH_TOKEN="+token)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return image, fmt.Errorf("docker build failed: %v\nOutput: %s", err, string(output))
+	}
+	return image, pushLocalImage(ctx, image, image)
+}
+
+// startWebhookReceiver serves webhooks on GITHUB_WEBHOOK_ADDR while the TUI
+// runs.
+func startWebhookReceiver() {
+	addr := webhookReceiverAddr()
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle(webhookPath, webhookHandler())
+	server := &http.Server{Addr: addr, Handler: mux}
+	onShutdown(func() { server.Close() })
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("webhook receiver on %s stopped: %v", addr, err)
+		}
+	}()
+}
+
+// maxQueuedWebhookBuilds is how many pushes serve holds to build. A push
+// beyond that is answered 503, for GitHub to show as failed and redeliver.
+const maxQueuedWebhookBuilds = 64
+
+// webhookBuildQueue are the commits serve is to build, in the order they
+// were pushed; nil in the TUI, which builds what the bus brings.
+var webhookBuildQueue chan string
+
+// queueWebhookBuild hands sha to serve's builds; false when the queue is
+// full.
+func queueWebhookBuild(sha string) bool {
+	if webhookBuildQueue == nil || sha == "" {
+		return true
+	}
+	select {
+	case webhookBuildQueue <- sha:
+		return true
+	default:
+		return false
+	}
+}
+
+// startWebhookBuilds is serve's webhook handling: storing is done by the
+// handler, so all that's left is building the pushes it queues.
+func startWebhookBuilds(ctx context.Context) {
+	webhookBuildQueue = make(chan string, maxQueuedWebhookBuilds)
+	go func() {
+		for {
+			var sha string
+			select {
+			case <-ctx.Done():
+				return
+			case sha = <-webhookBuildQueue:
+			}
+			image, err := buildAndPushCommit(ctx, sha)
+			recordActionResult(ctx, "webhook-build", image, err, "commit "+sha)
+			if err != nil {
+				log.Printf("build of %s failed: %v", sha, err)
+				continue
+			}
+			log.Printf("built and pushed %s", image)
+		}
+	}()
+}
+
+// applyWebhookEvent shows a delivery on the Git tab and starts its build.
+// Pushes are newer than any date range ending in the past, so they are left
+// out of one, as are those not touching the service the tab lists.
+func (m *model) applyWebhookEvent(event webhookEvent) tea.Cmd {
+	commits := event.commits
+	if m.gitQuery.path != "" {
+		commits = slices.DeleteFunc(slices.Clone(commits), func(commit TableData) bool {
+			return !touchesPath(event.files[commit.CommitSHA], m.gitQuery.path)
+		})
+	}
+	if len(commits) > 0 && m.gitQuery.until.IsZero() {
+		m.gitData = mergeCommits(commits, m.gitData)
+	}
+	for _, update := range event.updated {
+		for i := range m.gitData {
+			if m.gitData[i].CommitSHA == update.CommitSHA {
+				m.gitData[i].PRDescription = update.PRDescription
+			}
+		}
+	}
+	if m.activeTab == 0 {
+		m.updateTableForTab()
+	}
+	if event.build == "" {
+		return nil
+	}
+
+	historyCtx, sha := m.ctx, event.build
+	return m.startOperation("build", sha[:min(7, len(sha))], func(ctx context.Context) tea.Msg {
+		image, err := buildAndPushCommit(ctx, sha)
+		recordActionResult(withSpanOf(historyCtx, ctx), "webhook-build", image, err, "commit "+sha)
+		return webhookBuildMsg{image: image, err: err}
+	})
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/anthony-gilbert/local-container-registry/pkg/kube"
+)
+
+// revisionAnnotation numbers a deployment's rollouts and their replica sets
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+type workloadsDataMsg struct {
+	data []TableData
+	err  error
+}
+
+type deploymentDetailsMsg struct {
+	rows []table.Row
+	err  error
+}
+
+// getWorkloads lists the deployments in KUBERNETES_NAMESPACE for the
+// Workloads tab.
+func getWorkloads(ctx context.Context) ([]TableData, error) {
+	cluster := newCluster(ctx)
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	namespace := envOrDefault("KUBERNETES_NAMESPACE", "default")
+	deployments, err := cluster.Deployments(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %v", err)
+	}
+
+	rows := []TableData{}
+	for _, deployment := range deployments {
+		images := kube.Images(deployment)
+		release, _ := helmRelease(&deployment)
+		rows = append(rows, TableData{
+			PodName:     deployment.Name,
+			Namespace:   deployment.Namespace,
+			Status:      kube.Condition(deployment),
+			Restarts:    fmt.Sprintf("%d/%d", deployment.Status.ReadyReplicas, kube.DesiredReplicas(deployment)),
+			ImageTag:    strings.Join(images, ", "),
+			Age:         time.Since(deployment.CreationTimestamp.Time).Truncate(time.Second).String(),
+			HelmRelease: release,
+		})
+	}
+	return rows, nil
+}
+
+// getDeploymentDetails is the equivalent of `kubectl describe deployment`:
+// strategy, selector, conditions, replica sets, container specs and recent
+// events, as key/value rows for the detail screen.
+func getDeploymentDetails(ctx context.Context, name, namespace string) ([]table.Row, error) {
+	cluster := newCluster(ctx)
+	ctx, cancel := withBackendTimeout(ctx, backendKubernetes)
+	defer cancel()
+
+	deployment, err := cluster.Deployment(ctx, namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("error getting deployment: %v", err)
+	}
+
+	var rows []table.Row
+	add := func(key, value string) {
+		rows = append(rows, table.Row{key, truncateString(value, 70)})
+	}
+
+	add("Name", deployment.Name)
+	add("Namespace", deployment.Namespace)
+	add("Created", deployment.CreationTimestamp.Format("2006-01-02 15:04:05"))
+	if release, _ := helmRelease(deployment); release != "" {
+		add("Helm Release", release)
+	}
+	add("Selector", metav1.FormatLabelSelector(deployment.Spec.Selector))
+	add("Replicas", fmt.Sprintf("%d desired | %d updated | %d total | %d available | %d unavailable",
+		kube.DesiredReplicas(*deployment), deployment.Status.UpdatedReplicas, deployment.Status.Replicas,
+		deployment.Status.AvailableReplicas, deployment.Status.UnavailableReplicas))
+	add("Strategy", deploymentStrategy(deployment.Spec.Strategy))
+	add("Min Ready Seconds", strconv.Itoa(int(deployment.Spec.MinReadySeconds)))
+	if deadline := deployment.Spec.ProgressDeadlineSeconds; deadline != nil {
+		add("Progress Deadline", fmt.Sprintf("%ds", *deadline))
+	}
+	if deployment.Spec.Paused {
+		add("Paused", "true")
+	}
+
+	for _, condition := range deployment.Status.Conditions {
+		add("Condition "+string(condition.Type), fmt.Sprintf("%s (%s) %s", condition.Status, condition.Reason, condition.Message))
+	}
+
+	replicaSets, err := cluster.ReplicaSets(ctx, namespace, metav1.FormatLabelSelector(deployment.Spec.Selector))
+	if err == nil {
+		var owned []appsv1.ReplicaSet
+		for _, rs := range replicaSets {
+			if metav1.IsControlledBy(&rs, deployment) {
+				owned = append(owned, rs)
+			}
+		}
+		// Newest revision first
+		sort.Slice(owned, func(i, j int) bool {
+			a, _ := strconv.Atoi(owned[i].Annotations[revisionAnnotation])
+			b, _ := strconv.Atoi(owned[j].Annotations[revisionAnnotation])
+			return a > b
+		})
+		current := deployment.Annotations[revisionAnnotation]
+		for _, rs := range owned {
+			revision := rs.Annotations[revisionAnnotation]
+			marker := ""
+			if revision == current {
+				marker = " (current)"
+			}
+			var replicas int32
+			if rs.Spec.Replicas != nil {
+				replicas = *rs.Spec.Replicas
+			}
+			add("ReplicaSet "+rs.Name, fmt.Sprintf("rev %s%s · %d/%d ready", revision, marker, rs.Status.ReadyReplicas, replicas))
+		}
+	}
+
+	template := deployment.Spec.Template.Spec
+	for _, container := range append(append([]corev1.Container{}, template.InitContainers...), template.Containers...) {
+		add("Container "+container.Name, container.Image)
+		if container.ImagePullPolicy != "" {
+			add("  Pull Policy", string(container.ImagePullPolicy))
+		}
+		if len(container.Ports) > 0 {
+			var ports []string
+			for _, port := range container.Ports {
+				ports = append(ports, fmt.Sprintf("%d/%s", port.ContainerPort, port.Protocol))
+			}
+			add("  Ports", strings.Join(ports, ", "))
+		}
+		if len(container.Command) > 0 || len(container.Args) > 0 {
+			add("  Command", strings.Join(append(append([]string{}, container.Command...), container.Args...), " "))
+		}
+		if requests := container.Resources.Requests; len(requests) > 0 {
+			add("  Requests", resourceListString(requests))
+		}
+		if limits := container.Resources.Limits; len(limits) > 0 {
+			add("  Limits", resourceListString(limits))
+		}
+		if len(container.Env) > 0 || len(container.EnvFrom) > 0 {
+			var names []string
+			for _, env := range container.Env {
+				names = append(names, env.Name)
+			}
+			for _, from := range container.EnvFrom {
+				switch {
+				case from.ConfigMapRef != nil:
+					names = append(names, "configmap/"+from.ConfigMapRef.Name)
+				case from.SecretRef != nil:
+					names = append(names, "secret/"+from.SecretRef.Name)
+				}
+			}
+			add("  Environment", strings.Join(names, ", "))
+		}
+		for _, probe := range []struct {
+			name  string
+			probe *corev1.Probe
+		}{{"  Liveness", container.LivenessProbe}, {"  Readiness", container.ReadinessProbe}, {"  Startup", container.StartupProbe}} {
+			if probe.probe != nil {
+				add(probe.name, probeString(probe.probe))
+			}
+		}
+		if len(container.VolumeMounts) > 0 {
+			var mounts []string
+			for _, mount := range container.VolumeMounts {
+				mounts = append(mounts, mount.Name+"→"+mount.MountPath)
+			}
+			add("  Mounts", strings.Join(mounts, ", "))
+		}
+	}
+
+	events, err := cluster.Events(ctx, namespace, fields.Set{"involvedObject.kind": "Deployment", "involvedObject.name": name}.String())
+	if err == nil {
+		items := events
+		sort.Slice(items, func(i, j int) bool { return eventTime(items[i]).After(eventTime(items[j])) })
+		if len(items) > 10 {
+			items = items[:10]
+		}
+		for _, event := range items {
+			age := time.Since(eventTime(event)).Truncate(time.Second).String()
+			add("Event "+age+" ago", fmt.Sprintf("%s %s: %s", event.Type, event.Reason, event.Message))
+		}
+		if len(items) == 0 {
+			add("Events", "none recently")
+		}
+	}
+
+	return rows, nil
+}
+
+func deploymentStrategy(strategy appsv1.DeploymentStrategy) string {
+	if strategy.Type != appsv1.RollingUpdateDeploymentStrategyType || strategy.RollingUpdate == nil {
+		return string(strategy.Type)
+	}
+	return fmt.Sprintf("RollingUpdate (max surge %s, max unavailable %s)",
+		intOrDefault(strategy.RollingUpdate.MaxSurge), intOrDefault(strategy.RollingUpdate.MaxUnavailable))
+}
+
+func intOrDefault(value *intstr.IntOrString) string {
+	if value == nil {
+		return "default"
+	}
+	return value.String()
+}
+
+func resourceListString(list corev1.ResourceList) string {
+	var parts []string
+	for name, quantity := range list {
+		parts = append(parts, fmt.Sprintf("%s=%s", name, quantity.String()))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+func probeString(probe *corev1.Probe) string {
+	var handler string
+	switch {
+	case probe.HTTPGet != nil:
+		handler = fmt.Sprintf("http-get %s :%s", probe.HTTPGet.Path, probe.HTTPGet.Port.String())
+	case probe.TCPSocket != nil:
+		handler = "tcp-socket :" + probe.TCPSocket.Port.String()
+	case probe.Exec != nil:
+		handler = "exec " + strings.Join(probe.Exec.Command, " ")
+	case probe.GRPC != nil:
+		handler = fmt.Sprintf("grpc :%d", probe.GRPC.Port)
+	}
+	return fmt.Sprintf("%s delay=%ds period=%ds failure=%d", handler, probe.InitialDelaySeconds, probe.PeriodSeconds, probe.FailureThreshold)
+}
+
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// showDeploymentDetails opens the detail screen for a deployment. It is
+// drawn over whatever was open, so closing it returns to the deployment
+// picker when it was opened from there.
+func (m *model) showDeploymentDetails(name, namespace string) tea.Cmd {
+	m.detailDeployment = name
+	m.showDeploymentDef = true
+	m.deploymentDefTable = newDetailTable([]table.Row{{"Loading", "Fetching deployment details..."}})
+	if m.height > 0 {
+		m.deploymentDefTable.SetWidth(m.width)
+		m.deploymentDefTable.SetHeight(m.height - 15)
+	}
+	ctx := m.tabCtx
+	return func() tea.Msg {
+		rows, err := getDeploymentDetails(ctx, name, namespace)
+		return deploymentDetailsMsg{rows: rows, err: err}
+	}
+}
+
+// updateDeploymentDef handles keys while the deployment detail screen is open.
+func (m model) updateDeploymentDef(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "q":
+		m.showDeploymentDef = false
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.deploymentDefTable, cmd = m.deploymentDefTable.Update(msg)
+	return m, cmd
+}
+
+func (m model) renderWorkloadsStatus() string {
+	namespace := envOrDefault("KUBERNETES_NAMESPACE", "default")
+	switch {
+	case m.workloadsData == nil && m.workloadsErr == nil:
+		return fmt.Sprintf("Loading deployments in %s...", namespace)
+	case m.workloadsErr != nil:
+		return fmt.Sprintf("⚠️  %v", m.workloadsErr)
+	}
+	status := fmt.Sprintf("Namespace %s · %d deployments · Enter to describe", namespace, len(m.workloadsData))
+	if m.denied(permPatchDeployments) == "" {
+		status += ", r to rollout restart"
+	}
+	if m.denied(permDeleteDeployments) == "" {
+		status += ", Ctrl+D to delete"
+	}
+	if m.denied(permPatchDeployments) != "" || m.denied(permDeleteDeployments) != "" {
+		status += " · 🔒 read-only for your credentials"
+	}
+	return status
+}